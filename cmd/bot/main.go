@@ -7,11 +7,17 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/smysle/sakura-embyboss-go/internal/bot"
+	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
+	"github.com/smysle/sakura-embyboss-go/internal/bot/session"
 	"github.com/smysle/sakura-embyboss-go/internal/config"
 	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
 	"github.com/smysle/sakura-embyboss-go/internal/scheduler"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/internal/web"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
@@ -44,6 +50,23 @@ func main() {
 	defer database.Close()
 	logger.Info().Msg("✅ 数据库连接成功")
 
+	// 初始化会话存储（持久化存储可在重启后恢复进行中的对话）
+	sessionTTL := time.Duration(cfg.Session.TTL) * time.Minute
+	if cfg.Session.Store == "memory" {
+		session.Init(session.NewMemoryStore(), sessionTTL)
+	} else {
+		session.Init(session.NewDBStore(), sessionTTL)
+	}
+	logger.Info().Str("store", cfg.Session.Store).Msg("✅ 会话存储初始化完成")
+
+	// 启用本地播放记录时作为排行榜的首选数据源
+	if cfg.PlaybackHistory.Enabled {
+		emby.GetClient().SetLocalSource(service.NewPlaybackHistoryService().UserStats)
+	}
+
+	// 探测播放统计数据源（插件可用性），不阻塞启动
+	go emby.GetClient().ProbeDataSources()
+
 	// 初始化定时任务调度器
 	sched := scheduler.New(cfg)
 	sched.Start()
@@ -66,6 +89,15 @@ func main() {
 	}
 	logger.Info().Str("bot", cfg.BotName).Msg("✅ Telegram Bot 初始化完成")
 
+	// 初始化通知渠道（Telegram 需要 Bot 实例）
+	notify.Init(cfg, tgBot.Bot, keyboards.NotifyMarkup)
+
+	// 定时任务需要通过 Bot 推送排行榜
+	sched.SetBot(tgBot.Bot)
+
+	// 广播需要 Bot 实例，并继续重启前未完成的广播
+	service.InitBroadcast(tgBot.Bot)
+
 	// 监听系统信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
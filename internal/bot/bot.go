@@ -45,6 +45,9 @@ func New(cfg *config.Config) (*Bot, error) {
 		return nil, err
 	}
 
+	// 反皮套人：在进入处理器之前拦截群组中以频道身份发送的消息
+	b.Poller = tele.NewMiddlewarePoller(b.Poller, handlers.FilterChannelMessages(b))
+
 	bot := &Bot{
 		Bot: b,
 		cfg: cfg,
@@ -85,6 +88,7 @@ func (b *Bot) registerHandlers() {
 	b.Handle("/count", handlers.Count)
 	b.Handle("/red", handlers.RedEnvelope)
 	b.Handle("/srank", handlers.ScoreRank)
+	b.Handle("/checkin", handlers.CheckinCmd)
 
 	// 注册排行榜命令
 	handlers.RegisterLeaderboardHandlers(b.Bot)
@@ -99,6 +103,7 @@ func (b *Bot) registerHandlers() {
 	adminGroup.Handle("/kk", handlers.KK)
 	adminGroup.Handle("/score", handlers.Score)
 	adminGroup.Handle("/coins", handlers.Coins)
+	adminGroup.Handle("/ledger", handlers.Ledger)
 	adminGroup.Handle("/renew", handlers.Renew)
 	adminGroup.Handle("/rmemby", handlers.RemoveEmby)
 	adminGroup.Handle("/prouser", handlers.ProUser)
@@ -107,6 +112,9 @@ func (b *Bot) registerHandlers() {
 	adminGroup.Handle("/syncunbound", handlers.SyncUnbound)
 	adminGroup.Handle("/bindall_id", handlers.BindAllIDs)
 	adminGroup.Handle("/renewall", handlers.RenewAll)
+	adminGroup.Handle("/applylimits", handlers.ApplyLimits)
+	adminGroup.Handle("/servers", handlers.Servers)
+	adminGroup.Handle("/syncservers", handlers.SyncServers)
 	adminGroup.Handle("/check_ex", handlers.CheckExpiredManual)
 	adminGroup.Handle("/check_activity", handlers.CheckActivityManual)
 	adminGroup.Handle("/uranks", handlers.UserRanks)
@@ -125,11 +133,17 @@ func (b *Bot) registerHandlers() {
 	adminGroup.Handle("/auditip", handlers.AuditIP)
 	adminGroup.Handle("/auditdevice", handlers.AuditDevice)
 	adminGroup.Handle("/auditclient", handlers.AuditClient)
+	adminGroup.Handle("/audititem", handlers.AuditItem)
+	adminGroup.Handle("/auditshared", handlers.AuditShared)
+	adminGroup.Handle("/sharecheck", handlers.ShareCheck)
+	adminGroup.Handle("/invites", handlers.Invites)
+	adminGroup.Handle("/checkinstat", handlers.CheckinStat)
 
 	// 额外管理命令
 	adminGroup.Handle("/uinfo", handlers.UInfo)
 	adminGroup.Handle("/coinsall", handlers.CoinsAll)
 	adminGroup.Handle("/callall", handlers.CallAll)
+	adminGroup.Handle("/broadcast", handlers.Broadcast)
 	adminGroup.Handle("/ucr", handlers.UCr)
 	adminGroup.Handle("/urm", handlers.URm)
 	adminGroup.Handle("/deleted", handlers.Deleted)
@@ -194,9 +208,11 @@ func (b *Bot) setCommands() {
 		{Text: "count", Description: "[用户] 媒体库数量"},
 		{Text: "red", Description: "[用户] 发红包"},
 		{Text: "srank", Description: "[用户] 查看计分"},
+		{Text: "checkin", Description: "[用户] 签到与签到日历"},
 		{Text: "rank", Description: "[用户] 查看排行榜"},
 		{Text: "dayrank", Description: "[用户] 今日播放榜"},
 		{Text: "weekrank", Description: "[用户] 本周播放榜"},
+		{Text: "mediarank", Description: "[用户] 热门电影与剧集"},
 	}
 
 	// 管理员命令
@@ -204,17 +220,27 @@ func (b *Bot) setCommands() {
 		{Text: "kk", Description: "管理用户 [管理]"},
 		{Text: "score", Description: "加/减积分 [管理]"},
 		{Text: "coins", Description: "加/减花币 [管理]"},
+		{Text: "ledger", Description: "查看积分流水 [管理]"},
 		{Text: "renew", Description: "调整到期时间 [管理]"},
 		{Text: "rmemby", Description: "删除用户 [管理]"},
 		{Text: "prouser", Description: "增加白名单 [管理]"},
 		{Text: "revuser", Description: "减少白名单 [管理]"},
 		{Text: "check_ex", Description: "手动到期检测 [管理]"},
+		{Text: "applylimits", Description: "重新应用并发限制 [管理]"},
+		{Text: "servers", Description: "查看服务器列表 [管理]"},
+		{Text: "syncservers", Description: "同步附加服务器账户 [管理]"},
 		{Text: "auditip", Description: "IP 审计 [管理]"},
 		{Text: "auditdevice", Description: "设备审计 [管理]"},
 		{Text: "auditclient", Description: "客户端审计 [管理]"},
+		{Text: "audititem", Description: "媒体审计 [管理]"},
+		{Text: "auditshared", Description: "共用 IP/设备检测 [管理]"},
+		{Text: "sharecheck", Description: "账户共享检测 [管理]"},
+		{Text: "invites", Description: "邀请排行与邀请树 [管理]"},
+		{Text: "checkinstat", Description: "签到参与统计 [管理]"},
 		{Text: "uinfo", Description: "查询用户信息 [管理]"},
 		{Text: "coinsall", Description: "批量发放积分 [管理]"},
 		{Text: "callall", Description: "广播消息 [管理]"},
+		{Text: "broadcast", Description: "按条件广播 [管理]"},
 		{Text: "ucr", Description: "创建非TG用户 [管理]"},
 		{Text: "urm", Description: "删除指定用户 [管理]"},
 		{Text: "deleted", Description: "清理死号 [管理]"},
@@ -228,6 +254,7 @@ func (b *Bot) setCommands() {
 		{Text: "proadmin", Description: "添加bot管理 [owner]"},
 		{Text: "revadmin", Description: "移除bot管理 [owner]"},
 		{Text: "backup_db", Description: "手动备份数据库 [owner]"},
+		{Text: "restore_from_db", Description: "从备份恢复数据库 [owner]"},
 		{Text: "banall", Description: "禁用所有用户 [owner]"},
 		{Text: "unbanall", Description: "解除所有用户禁用 [owner]"},
 		{Text: "paolu", Description: "跑路! 删除所有用户 [owner]"},
@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
@@ -85,6 +86,7 @@ func showUserInfo(c tele.Context, user *models.Emby) error {
 			"**· 等级** | %s\n"+
 			"**· 积分** | %d %s\n"+
 			"**· 到期时间** | %s\n"+
+			"**· 账户状态** | %s\n"+
 			"**· 邀请次数** | %d\n",
 		user.TG,
 		getEmbyName(user.Name),
@@ -92,11 +94,12 @@ func showUserInfo(c tele.Context, user *models.Emby) error {
 		user.GetLevelName(),
 		user.Us, cfg.Money,
 		expiryText,
+		user.GetStatusName(),
 		user.Iv,
 	)
 
 	// 检查是否配置了额外媒体库
-	hasExtraLibs := len(cfg.Emby.ExtraLibs) > 0
+	hasExtraLibs := len(cfg.Emby.Main().ExtraLibs) > 0
 
 	// 检查用户额外媒体库状态
 	extraLibsEnabled := false
@@ -109,7 +112,7 @@ func showUserInfo(c tele.Context, user *models.Emby) error {
 			// 如果额外库不在阻止列表中，则认为已启用
 			extraLibsEnabled = true
 			for _, blocked := range embyUser.Policy.BlockedFolders {
-				for _, extraLib := range cfg.Emby.ExtraLibs {
+				for _, extraLib := range cfg.Emby.Main().ExtraLibs {
 					if blocked == extraLib {
 						extraLibsEnabled = false
 						break
@@ -186,13 +189,32 @@ func Score(c tele.Context) error {
 		return c.Send("❌ 未找到该用户")
 	}
 
-	newScore := user.Us + score
-	if newScore < 0 {
-		newScore = 0
+	if score == 0 {
+		return c.Send("❌ 积分变动不能为 0")
 	}
 
-	if err := repo.UpdateFields(tgID, map[string]interface{}{"us": newScore}); err != nil {
-		return c.Send("❌ 更新积分失败")
+	// 扣减时最多扣到 0（按扣减时的余额计算）
+	change := &service.PointsChange{
+		TG:       tgID,
+		Currency: models.CurrencyScore,
+		Amount:   score,
+		Reason:   models.LedgerReasonAdmin,
+		Actor:    c.Sender().ID,
+	}
+	pointsSvc := service.NewPointsService()
+	var entry *models.PointsLedger
+	if score > 0 {
+		entry, err = pointsSvc.Credit(change)
+	} else {
+		change.Amount = -score
+		entry, err = pointsSvc.DebitUpTo(change)
+	}
+	if err != nil {
+		return c.Send("❌ 更新积分失败: " + err.Error())
+	}
+	oldScore, newScore := user.Us, user.Us
+	if entry != nil {
+		oldScore, newScore = entry.Balance-entry.Amount, entry.Balance
 	}
 
 	userName := "未知"
@@ -201,7 +223,7 @@ func Score(c tele.Context) error {
 	}
 
 	cfg := config.Get()
-	return c.Send(fmt.Sprintf("✅ 用户 %s (ID: %d) 积分已更新: %d -> %d %s", userName, tgID, user.Us, newScore, cfg.Money))
+	return c.Send(fmt.Sprintf("✅ 用户 %s (ID: %d) 积分已更新: %d -> %d %s", userName, tgID, oldScore, newScore, cfg.Money))
 }
 
 // Coins /coins 花币命令（同 Score）
@@ -272,6 +294,7 @@ func Renew(c tele.Context) error {
 	if err := repo.UpdateFields(tgID, map[string]interface{}{"ex": newExpiry}); err != nil {
 		return c.Send("❌ 更新到期时间失败")
 	}
+	renewed(tgID)
 
 	userName := "未知"
 	if user.Name != nil {
@@ -281,6 +304,13 @@ func Renew(c tele.Context) error {
 	return c.Send(fmt.Sprintf("✅ 用户 %s (ID: %d) 到期时间已更新为: %s", userName, tgID, newExpiry.Format("2006-01-02 15:04:05")))
 }
 
+// renewed 续期后恢复过期阶段中的账户并结算邀请续期奖励
+func renewed(tgID int64) {
+	if err := service.NewExpiryService().Renewed(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("恢复续期用户失败")
+	}
+}
+
 // RemoveEmby /rmemby 删除用户命令
 func RemoveEmby(c tele.Context) error {
 	args := c.Args()
@@ -312,6 +342,7 @@ func RemoveEmby(c tele.Context) error {
 			logger.Warn().Err(err).Str("embyID", *user.EmbyID).Msg("删除 Emby 账户失败")
 		}
 	}
+	service.DeleteServerAccounts(user.TG)
 
 	// 删除数据库记录
 	if err := repo.Delete(user.TG); err != nil {
@@ -337,6 +368,8 @@ func ProUser(c tele.Context) error {
 	if err := repo.UpdateFields(tgID, map[string]interface{}{"lv": models.LevelA}); err != nil {
 		return c.Send("❌ 设置白名单失败")
 	}
+	applyStreamLimit(tgID)
+	service.SyncServers(tgID)
 
 	return c.Send(fmt.Sprintf("✅ 用户 %d 已设为白名单", tgID))
 }
@@ -357,6 +390,8 @@ func RevUser(c tele.Context) error {
 	if err := repo.UpdateFields(tgID, map[string]interface{}{"lv": models.LevelD}); err != nil {
 		return c.Send("❌ 取消白名单失败")
 	}
+	applyStreamLimit(tgID)
+	service.SyncServers(tgID)
 
 	return c.Send(fmt.Sprintf("✅ 用户 %d 已取消白名单", tgID))
 }
@@ -379,7 +414,7 @@ func CheckExpired(c tele.Context) error {
 			text += fmt.Sprintf("\n... 还有 %d 人", len(expiredUsers)-20)
 			break
 		}
-		text += fmt.Sprintf("%d. `%d` - %s\n", i+1, u.TG, getEmbyName(u.Name))
+		text += fmt.Sprintf("%d. `%d` - %s (%s)\n", i+1, u.TG, getEmbyName(u.Name), u.GetStatusName())
 	}
 
 	return c.Send(text, keyboards.CloseKeyboard(), tele.ModeMarkdown)
@@ -391,6 +426,9 @@ func UserRanks(c tele.Context) error {
 
 	leaderboardSvc := service.NewLeaderboardService()
 	stats, err := leaderboardSvc.GetUserPlayStats(20)
+	if errors.Is(err, emby.ErrNoPlaybackData) {
+		return c.Send("📊 暂无播放数据")
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("获取用户播放统计失败")
 		return c.Send("❌ 获取播放统计失败: " + err.Error())
@@ -414,6 +452,9 @@ func DayRanks(c tele.Context) error {
 
 	leaderboardSvc := service.NewLeaderboardService()
 	imgPath, err := leaderboardSvc.GenerateDailyRank()
+	if errors.Is(err, emby.ErrNoPlaybackData) {
+		return c.Send("📊 暂无排行数据")
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("生成日榜失败")
 		return c.Send("❌ 生成日榜失败: " + err.Error())
@@ -430,6 +471,9 @@ func WeekRanks(c tele.Context) error {
 
 	leaderboardSvc := service.NewLeaderboardService()
 	imgPath, err := leaderboardSvc.GenerateWeeklyRank()
+	if errors.Is(err, emby.ErrNoPlaybackData) {
+		return c.Send("📊 暂无排行数据")
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("生成周榜失败")
 		return c.Send("❌ 生成周榜失败: " + err.Error())
@@ -13,6 +13,7 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -173,11 +174,26 @@ func CoinsAll(c tele.Context) error {
 		return c.Reply("❓ 未找到符合条件的用户")
 	}
 
-	// 批量更新积分
+	// 批量更新积分（负数为扣减，余额不足的用户会失败）
+	pointsSvc := service.NewPointsService()
+	refID := fmt.Sprintf("coinsall:%d", time.Now().Unix())
 	successCount := 0
 	for _, user := range users {
-		newIV := user.Iv + coins
-		if err := repo.UpdateFields(user.TG, map[string]interface{}{"iv": newIV}); err != nil {
+		change := &service.PointsChange{
+			TG:       user.TG,
+			Currency: models.CurrencyCoin,
+			Amount:   coins,
+			Reason:   models.LedgerReasonAdminBatch,
+			Actor:    c.Sender().ID,
+			RefID:    refID,
+		}
+		if coins >= 0 {
+			_, err = pointsSvc.Credit(change)
+		} else {
+			change.Amount = -coins
+			_, err = pointsSvc.Debit(change)
+		}
+		if err != nil {
 			logger.Error().Err(err).Int64("tg", user.TG).Msg("更新用户积分失败")
 		} else {
 			successCount++
@@ -202,94 +218,6 @@ func CoinsAll(c tele.Context) error {
 	), tele.ModeMarkdown)
 }
 
-// CallAll 广播消息 /callall <消息内容>
-func CallAll(c tele.Context) error {
-	cfg := config.Get()
-	if !cfg.IsAdmin(c.Sender().ID) {
-		return c.Reply("❌ 您没有权限使用此命令")
-	}
-
-	// 获取消息内容（支持回复消息或直接输入）
-	var message string
-	if c.Message().ReplyTo != nil {
-		message = c.Message().ReplyTo.Text
-	} else {
-		args := c.Args()
-		if len(args) < 1 {
-			return c.Reply("📝 **用法：** `/callall <消息内容>`\n\n" +
-				"或者回复一条消息并使用 `/callall`\n\n" +
-				"注意：消息会发送给所有有 Emby 账户的用户", tele.ModeMarkdown)
-		}
-		message = strings.Join(args, " ")
-	}
-
-	if message == "" {
-		return c.Reply("❌ 消息内容不能为空")
-	}
-
-	repo := repository.NewEmbyRepository()
-	users, err := repo.GetActiveUsers()
-	if err != nil {
-		return c.Reply("❌ 获取用户列表失败")
-	}
-
-	if len(users) == 0 {
-		return c.Reply("❓ 没有可发送的用户")
-	}
-
-	// 发送提示
-	status, _ := c.Bot().Reply(c.Message(), fmt.Sprintf("📤 正在发送消息给 %d 个用户...", len(users)))
-
-	// 广播消息
-	successCount := 0
-	failCount := 0
-	
-	broadcastText := fmt.Sprintf(
-		"📢 **系统通知**\n\n%s\n\n—— %s",
-		message,
-		time.Now().Format("2006-01-02 15:04"),
-	)
-
-	for _, user := range users {
-		chat := &tele.Chat{ID: user.TG}
-		_, err := c.Bot().Send(chat, broadcastText, tele.ModeMarkdown)
-		if err != nil {
-			failCount++
-			logger.Debug().Err(err).Int64("tg", user.TG).Msg("发送广播失败")
-		} else {
-			successCount++
-		}
-		
-		// 避免触发 Telegram API 限制
-		time.Sleep(50 * time.Millisecond)
-	}
-
-	// 更新状态消息
-	resultText := fmt.Sprintf(
-		"✅ **广播完成**\n\n"+
-			"成功: %d\n"+
-			"失败: %d\n"+
-			"总计: %d",
-		successCount, failCount, len(users),
-	)
-
-	if status != nil {
-		if _, err := c.Bot().Edit(status, resultText, tele.ModeMarkdown); err != nil {
-			logger.Debug().Err(err).Msg("Edit status failed")
-		}
-	} else {
-		c.Reply(resultText, tele.ModeMarkdown)
-	}
-
-	logger.Info().
-		Int("success", successCount).
-		Int("fail", failCount).
-		Int64("admin", c.Sender().ID).
-		Msg("广播消息完成")
-
-	return nil
-}
-
 // UCr 创建非TG用户 /ucr <用户名> <天数>
 func UCr(c tele.Context) error {
 	cfg := config.Get()
@@ -367,6 +295,7 @@ func URm(c tele.Context) error {
 		if err := client.DeleteUser(*user.EmbyID); err != nil {
 			logger.Warn().Err(err).Str("embyID", *user.EmbyID).Msg("删除 Emby 账户失败")
 		}
+		service.DeleteServerAccounts(user.TG)
 
 		// 清空数据库记录
 		if err := repo.UpdateFields(user.TG, map[string]interface{}{
@@ -446,10 +375,19 @@ func CoinsClear(c tele.Context) error {
 	}
 
 	// 批量清空积分
+	pointsSvc := service.NewPointsService()
+	refID := fmt.Sprintf("coinsclear:%d", time.Now().Unix())
 	successCount := 0
 	for _, user := range users {
 		if user.Iv > 0 {
-			if err := repo.UpdateFields(user.TG, map[string]interface{}{"iv": 0}); err != nil {
+			if _, err := pointsSvc.Debit(&service.PointsChange{
+				TG:       user.TG,
+				Currency: models.CurrencyCoin,
+				Amount:   user.Iv,
+				Reason:   models.LedgerReasonAdminClear,
+				Actor:    c.Sender().ID,
+				RefID:    refID,
+			}); err != nil {
 				logger.Error().Err(err).Int64("tg", user.TG).Msg("清空用户积分失败")
 			} else {
 				successCount++
@@ -530,6 +468,7 @@ func Deleted(c tele.Context) error {
 					deletedNames = append(deletedNames, *user.Name)
 				}
 			}
+			service.DeleteServerAccounts(user.TG)
 
 			// 清理数据库记录
 			if err := repo.UpdateFields(user.TG, map[string]interface{}{
@@ -633,6 +572,7 @@ func LowActivity(c tele.Context) error {
 
 				// 更新用户等级为封禁
 				repo.UpdateFields(user.TG, map[string]interface{}{"lv": models.LevelE})
+				service.DisableServers(user.TG)
 			}
 			inactiveCount++
 		}
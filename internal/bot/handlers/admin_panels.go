@@ -2,9 +2,11 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	tele "gopkg.in/telebot.v3"
 
@@ -31,7 +33,15 @@ func handleOpenMenu(c tele.Context) error {
 	text := "⭕ **注册状态设置**\n\n" +
 		"在这里可以控制用户注册相关的设置"
 
-	return editOrReply(c, text, keyboards.OpenMenuKeyboard(cfg), tele.ModeMarkdown)
+	window, err := service.NewRegisterWindowService().Current()
+	if err != nil {
+		logger.Error().Err(err).Msg("查询定时注册失败")
+	}
+	if window != nil {
+		text += "\n\n⏰ 定时注册：" + service.DescribeRegisterWindow(window)
+	}
+
+	return editOrReply(c, text, keyboards.OpenMenuKeyboard(cfg, window), tele.ModeMarkdown)
 }
 
 // handleOpenStat 切换自由注册状态
@@ -74,7 +84,21 @@ func handleOpenTiming(c tele.Context) error {
 	// 设置会话状态等待输入
 	session.Set(c.Sender().ID, session.StateWaitingOpenTiming, nil)
 
-	return c.Send("请输入定时注册参数：`时长(分钟) 人数`\n\n例如：`30 10` 表示开放30分钟，限制10人\n\n发送 `0` 取消定时注册", tele.ModeMarkdown)
+	text := "请输入定时注册参数：`时长(分钟) 人数 [开始时间]`\n\n" +
+		"例如：\n" +
+		"`30 10` 立即开放 30 分钟，限 10 人\n" +
+		"`60 0 20:00` 今天 20:00 开放 60 分钟，不限人数\n" +
+		"`0 50 2026-01-01 00:00` 指定时间开放，50 人注册满即关闭\n\n" +
+		"时间到或名额用完时自动关闭，开放与关闭时会在群组公告"
+
+	window, err := service.NewRegisterWindowService().Current()
+	if err != nil {
+		logger.Error().Err(err).Msg("查询定时注册失败")
+	}
+	if window != nil {
+		text += "\n\n当前：" + service.DescribeRegisterWindow(window) + "\n发送 `0` 取消定时注册"
+	}
+	return c.Send(text, tele.ModeMarkdown)
 }
 
 // handleOpenDays 设置注册天数
@@ -115,10 +139,12 @@ func handleCrLink(c tele.Context) error {
 
 	return c.Send(
 		"🎟️ **创建注册/续期码**\n\n"+
-			"请输入参数：`天数 数量`\n\n"+
+			"请输入参数：`天数 数量 [参数...]`\n\n"+
+			codeOptionsUsage+"\n\n"+
 			"例如：\n"+
 			"• `30 5` - 生成5个30天的注册码\n"+
-			"• `90 10` - 生成10个90天的注册码\n\n"+
+			"• `90 10` - 生成10个90天的注册码\n"+
+			"• `30 500 label=春节活动 expire=7` - 生成7天内有效的活动批次\n\n"+
 			"发送 `取消` 取消操作",
 		tele.ModeMarkdown,
 	)
@@ -304,9 +330,12 @@ func handleSchedAll(c tele.Context) error {
 		"• 播放周榜: 每周日 23:59\n" +
 		"• 观影日榜: 每日 23:00\n" +
 		"• 观影周榜: 每周日 23:00\n" +
+		"• 媒体日榜: 每日 22:00\n" +
+		"• 媒体周榜: 每周日 22:00\n" +
 		"• 到期检测: 每日 01:30\n" +
 		"• 活跃检测: 每日 08:30\n" +
-		"• 自动备份: 每日 02:30"
+		"• 自动备份: 每日 02:30\n" +
+		"• 到期预警: 每日 10:00"
 
 	return editOrReply(c, text, keyboards.SchedAllKeyboard(cfg), tele.ModeMarkdown)
 }
@@ -338,6 +367,14 @@ func handleSchedToggle(c tele.Context, action string) error {
 		cfg.Scheduler.WeekPlayRank = !cfg.Scheduler.WeekPlayRank
 		enabled = &cfg.Scheduler.WeekPlayRank
 		taskName = "观影周榜"
+	case "sched_daymediarank":
+		cfg.Scheduler.DayMediaRank = !cfg.Scheduler.DayMediaRank
+		enabled = &cfg.Scheduler.DayMediaRank
+		taskName = "媒体日榜"
+	case "sched_weekmediarank":
+		cfg.Scheduler.WeekMediaRank = !cfg.Scheduler.WeekMediaRank
+		enabled = &cfg.Scheduler.WeekMediaRank
+		taskName = "媒体周榜"
 	case "sched_check_ex":
 		cfg.Scheduler.CheckExpired = !cfg.Scheduler.CheckExpired
 		enabled = &cfg.Scheduler.CheckExpired
@@ -350,6 +387,10 @@ func handleSchedToggle(c tele.Context, action string) error {
 		cfg.Scheduler.BackupDB = !cfg.Scheduler.BackupDB
 		enabled = &cfg.Scheduler.BackupDB
 		taskName = "自动备份"
+	case "sched_expiry_warning":
+		cfg.Scheduler.ExpiryWarning = !cfg.Scheduler.ExpiryWarning
+		enabled = &cfg.Scheduler.ExpiryWarning
+		taskName = "到期预警"
 	}
 
 	if err := config.Save(); err != nil {
@@ -509,12 +550,12 @@ func handleCfgSetLine(c tele.Context, action string) error {
 
 	if action == "cfg_set_line" {
 		state = session.StateWaitingLine
-		prompt = "请输入普通用户线路信息：\n\n当前：\n" + cfg.Emby.Line
+		prompt = "请输入普通用户线路信息：\n\n当前：\n" + cfg.Emby.Main().Line
 	} else {
 		state = session.StateWaitingWhitelistLine
 		wlLine := ""
-		if cfg.Emby.WhitelistLine != nil {
-			wlLine = *cfg.Emby.WhitelistLine
+		if cfg.Emby.Main().WhitelistLine != nil {
+			wlLine = *cfg.Emby.Main().WhitelistLine
 		}
 		prompt = "请输入白名单用户线路信息：\n\n当前：\n" + wlLine
 	}
@@ -570,43 +611,38 @@ func getStatusText(enabled bool) string {
 
 // handleOpenTimingInput 处理定时注册输入
 func handleOpenTimingInput(c tele.Context, text string) error {
-	cfg := config.Get()
 	session.Clear(c.Sender().ID)
+	svc := service.NewRegisterWindowService()
+	svc.SetBot(c.Bot())
 
 	if text == "0" {
 		// 取消定时注册
-		cfg.Open.Timing = 0
-		if err := config.Save(); err != nil {
-			return c.Send("❌ 保存配置失败")
+		window, err := svc.Cancel()
+		if err != nil {
+			logger.Error().Err(err).Msg("取消定时注册失败")
+			return c.Send("❌ 取消定时注册失败")
+		}
+		if window == nil {
+			return c.Send("ℹ️ 当前没有定时注册")
 		}
 		return c.Send("✅ 已取消定时注册")
 	}
 
-	// 解析参数：时长 人数
-	parts := strings.Fields(text)
-	if len(parts) < 2 {
-		return c.Send("❌ 格式错误\n\n请输入：`时长(分钟) 人数`", tele.ModeMarkdown)
-	}
-
-	minutes, err := strconv.Atoi(parts[0])
-	if err != nil || minutes <= 0 {
-		return c.Send("❌ 时长必须是正整数")
-	}
-
-	limit, err := strconv.Atoi(parts[1])
-	if err != nil || limit <= 0 {
-		return c.Send("❌ 人数必须是正整数")
+	plan, err := service.ParseRegisterWindow(text, time.Now())
+	if err != nil {
+		return c.Send("❌ " + err.Error())
 	}
 
-	cfg.Open.Timing = minutes
-	cfg.Open.MaxUsers = limit
-	cfg.Open.Status = true
-
-	if err := config.Save(); err != nil {
-		return c.Send("❌ 保存配置失败")
+	window, err := svc.Schedule(c.Sender().ID, plan)
+	if err != nil {
+		if errors.Is(err, service.ErrRegisterWindowExists) {
+			return c.Send("❌ " + err.Error())
+		}
+		logger.Error().Err(err).Msg("创建定时注册失败")
+		return c.Send("❌ 创建定时注册失败")
 	}
 
-	return c.Send(fmt.Sprintf("✅ 定时注册已设置\n\n开放时长：%d 分钟\n人数限制：%d 人", minutes, limit))
+	return c.Send("✅ 定时注册已设置\n\n" + service.DescribeRegisterWindow(window))
 }
 
 // handleOpenDaysInput 处理注册天数输入
@@ -656,38 +692,26 @@ func handleCodeCreateInput(c tele.Context, text string) error {
 		return c.Send("✅ 已取消操作")
 	}
 
-	// 解析参数：天数 数量
+	// 解析参数：天数 数量 [参数...]
 	parts := strings.Fields(text)
 	if len(parts) < 2 {
-		return c.Send("❌ 格式错误\n\n请输入：`天数 数量`", tele.ModeMarkdown)
-	}
-
-	days, err := strconv.Atoi(parts[0])
-	if err != nil || days <= 0 {
-		return c.Send("❌ 天数必须是正整数")
+		return c.Send("❌ 格式错误\n\n请输入：`天数 数量 [参数...]`", tele.ModeMarkdown)
 	}
 
-	count, err := strconv.Atoi(parts[1])
-	if err != nil || count <= 0 || count > 100 {
-		return c.Send("❌ 数量必须是 1-100 的正整数")
+	opts, out, err := parseCodeArgs(parts)
+	if err != nil {
+		return c.Send("❌ " + err.Error())
 	}
+	opts.CreatedBy = c.Sender().ID
 
 	// 生成注册码
 	codeSvc := service.NewCodeService()
-	result, err := codeSvc.GenerateCodes(c.Sender().ID, days, count)
+	result, err := codeSvc.GenerateBatch(opts)
 	if err != nil {
 		return c.Send(fmt.Sprintf("❌ 生成注册码失败: %s", err.Error()))
 	}
 
-	// 构建回复
-	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🎟️ **生成 %d 个注册码成功**\n\n", count))
-	sb.WriteString(fmt.Sprintf("有效期：%d 天\n\n", days))
-	for i, code := range result.Codes {
-		sb.WriteString(fmt.Sprintf("%d. `%s`\n", i+1, code))
-	}
-
-	return c.Send(sb.String(), tele.ModeMarkdown)
+	return sendGeneratedCodes(c, result, out)
 }
 
 // handleGiftDaysInput 处理赠送天数输入
@@ -749,7 +773,7 @@ func handleLineInput(c tele.Context, text string) error {
 	cfg := config.Get()
 	session.Clear(c.Sender().ID)
 
-	cfg.Emby.Line = text
+	cfg.Emby.Main().Line = text
 	if err := config.Save(); err != nil {
 		return c.Send("❌ 保存配置失败")
 	}
@@ -762,7 +786,7 @@ func handleWhitelistLineInput(c tele.Context, text string) error {
 	cfg := config.Get()
 	session.Clear(c.Sender().ID)
 
-	cfg.Emby.WhitelistLine = &text
+	cfg.Emby.Main().WhitelistLine = &text
 	if err := config.Save(); err != nil {
 		return c.Send("❌ 保存配置失败")
 	}
@@ -5,14 +5,128 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	tele "gopkg.in/telebot.v3"
 
+	botutils "github.com/smysle/sakura-embyboss-go/internal/bot/utils"
 	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
 )
 
-// UnbanChannel /unban_channel 解封频道账号
+// FilterChannelMessages 轮询过滤器：处理群组中以频道身份发送的消息
+// 需要封禁时返回 false 丢弃该更新，警告模式下消息照常交给后续处理器
+func FilterChannelMessages(b *tele.Bot) func(*tele.Update) bool {
+	return func(u *tele.Update) bool {
+		msg := u.Message
+		action := service.AntiChannelActionFor(config.Get(), msg)
+		if action == service.AntiChannelIgnore {
+			return true
+		}
+
+		// 更新中的群组信息不含关联频道，查询后再判断一次，群组自己的关联频道自动豁免
+		if linked := linkedChatID(b, msg.Chat.ID); linked != 0 {
+			msg.Chat.LinkedChatID = linked
+			if action = service.AntiChannelActionFor(config.Get(), msg); action == service.AntiChannelIgnore {
+				return true
+			}
+		}
+
+		// 在轮询协程外调用 Telegram API，避免阻塞更新拉取
+		go enforceAntiChannel(b, msg, action)
+		return action != service.AntiChannelBan
+	}
+}
+
+// linkedChatTTL 群组关联频道的缓存时间
+const linkedChatTTL = time.Hour
+
+// linkedChatCache 缓存群组的关联频道 ID，避免每条频道消息都调用 getChat
+var linkedChatCache = struct {
+	sync.Mutex
+	entries map[int64]linkedChatEntry
+}{entries: make(map[int64]linkedChatEntry)}
+
+// linkedChatEntry 关联频道缓存条目
+type linkedChatEntry struct {
+	id        int64
+	fetchedAt time.Time
+}
+
+// linkedChatID 获取群组的关联频道 ID，没有关联频道或查询失败时返回 0
+// 查询失败也会缓存，避免 Telegram 接口异常时每条消息都重试
+func linkedChatID(b *tele.Bot, groupID int64) int64 {
+	linkedChatCache.Lock()
+	defer linkedChatCache.Unlock()
+
+	if entry, ok := linkedChatCache.entries[groupID]; ok && time.Since(entry.fetchedAt) < linkedChatTTL {
+		return entry.id
+	}
+
+	var id int64
+	chat, err := b.ChatByID(groupID)
+	if err != nil {
+		logger.Warn().Err(err).Int64("group", groupID).Msg("获取群组关联频道失败")
+	} else {
+		id = chat.LinkedChatID
+	}
+	linkedChatCache.entries[groupID] = linkedChatEntry{id: id, fetchedAt: time.Now()}
+	return id
+}
+
+// enforceAntiChannel 删除频道身份发送的消息并封禁该频道，或在警告模式下提醒
+func enforceAntiChannel(b *tele.Bot, msg *tele.Message, action service.AntiChannelAction) {
+	cfg := config.Get()
+	sender := msg.SenderChat
+	log := logger.Info().
+		Int64("group", msg.Chat.ID).
+		Int64("channel", sender.ID).
+		Str("title", sender.Title).
+		Int("message", msg.ID)
+
+	var notice string
+	switch action {
+	case service.AntiChannelWarn:
+		log.Str("action", "warn").Msg("反皮套人：频道身份发言（仅警告）")
+		notice = fmt.Sprintf("⚠️ 检测到频道 %s 发言，本群禁止使用频道身份发言，请切换为个人账号。", channelLabel(sender))
+	case service.AntiChannelBan:
+		if err := b.Delete(msg); err != nil {
+			logger.Warn().Err(err).Int64("group", msg.Chat.ID).Int("message", msg.ID).Msg("删除频道消息失败")
+		}
+		if err := b.BanSenderChat(msg.Chat, sender); err != nil {
+			logger.Error().Err(err).Int64("group", msg.Chat.ID).Int64("channel", sender.ID).Msg("封禁频道失败")
+			return
+		}
+		log.Str("action", "ban").Msg("反皮套人：已删除消息并封禁频道")
+		notice = fmt.Sprintf("🚫 检测到频道 %s 发言，已删除消息并封禁该频道。", channelLabel(sender))
+	default:
+		return
+	}
+
+	opts := &tele.SendOptions{}
+	if action == service.AntiChannelWarn {
+		opts.ReplyTo = msg
+	}
+	sent, err := b.Send(msg.Chat, notice, opts)
+	if err != nil {
+		logger.Warn().Err(err).Int64("group", msg.Chat.ID).Msg("发送反皮套人提示失败")
+		return
+	}
+	botutils.DeleteAfter(b, sent, cfg.AntiChannel.NoticeSeconds)
+}
+
+// channelLabel 频道显示名称
+func channelLabel(chat *tele.Chat) string {
+	if chat.Username != "" {
+		return fmt.Sprintf("%s (@%s)", chat.Title, chat.Username)
+	}
+	return fmt.Sprintf("%s (%d)", chat.Title, chat.ID)
+}
+
+// UnbanChannel /unban_channel 在所有群组中解封频道
 func UnbanChannel(c tele.Context) error {
 	args := c.Args()
 	if len(args) == 0 {
@@ -25,26 +139,28 @@ func UnbanChannel(c tele.Context) error {
 	}
 
 	cfg := config.Get()
-	
-	// 获取主群组
 	if len(cfg.Groups) == 0 {
 		return c.Send("❌ 未配置群组")
 	}
-	chat := &tele.Chat{ID: cfg.Groups[0]}
 
-	// 解封频道
-	member := &tele.ChatMember{
-		User: &tele.User{ID: channelID},
+	var failed []string
+	for _, groupID := range cfg.Groups {
+		if err := c.Bot().UnbanSenderChat(&tele.Chat{ID: groupID}, &tele.Chat{ID: channelID}); err != nil {
+			logger.Error().Err(err).Int64("group", groupID).Int64("channel", channelID).Msg("解封频道失败")
+			// 错误信息常含下划线（如 CHAT_ADMIN_REQUIRED），需转义后再以 Markdown 发送
+			failed = append(failed, fmt.Sprintf("`%d`: %s", groupID, utils.EscapeMarkdown(err.Error())))
+		}
 	}
-
-	err = c.Bot().Unban(chat, member.User)
-	if err != nil {
-		logger.Error().Err(err).Int64("channel", channelID).Msg("解封频道失败")
-		return c.Send(fmt.Sprintf("❌ 解封失败: %s", err.Error()))
+	if len(failed) == len(cfg.Groups) {
+		return c.Send("❌ 解封失败\n"+strings.Join(failed, "\n"), tele.ModeMarkdown)
 	}
 
 	logger.Info().Int64("channel", channelID).Int64("admin", c.Sender().ID).Msg("解封频道")
-	return c.Send(fmt.Sprintf("✅ 已解封频道 `%d`", channelID), tele.ModeMarkdown)
+	text := fmt.Sprintf("✅ 已解封频道 `%d`", channelID)
+	if len(failed) > 0 {
+		text += "\n\n⚠️ 部分群组解封失败:\n" + strings.Join(failed, "\n")
+	}
+	return c.Send(text, tele.ModeMarkdown)
 }
 
 // WhiteChannel /white_channel 添加频道白名单
@@ -119,10 +235,11 @@ func RevWhiteChannel(c tele.Context) error {
 		return c.Send("❌ 保存配置失败")
 	}
 
-	// 可选：封禁该频道
-	if len(cfg.Groups) > 0 {
-		chat := &tele.Chat{ID: cfg.Groups[0]}
-		c.Bot().Ban(chat, &tele.ChatMember{User: &tele.User{ID: channelID}})
+	// 移出白名单后在所有群组中封禁该频道
+	for _, groupID := range cfg.Groups {
+		if err := c.Bot().BanSenderChat(&tele.Chat{ID: groupID}, &tele.Chat{ID: channelID}); err != nil {
+			logger.Warn().Err(err).Int64("group", groupID).Int64("channel", channelID).Msg("封禁频道失败")
+		}
 	}
 
 	logger.Info().Int64("channel", channelID).Int64("admin", c.Sender().ID).Msg("移除频道白名单并封禁")
@@ -146,8 +263,15 @@ func ListWhiteChannels(c tele.Context) error {
 	return c.Send(sb.String(), tele.ModeMarkdown)
 }
 
-// ToggleAntiChannel /anti_channel 开关频道过滤
+// ToggleAntiChannel /anti_channel 开关频道过滤；/anti_channel warn [群组ID] 切换群组的仅警告模式
 func ToggleAntiChannel(c tele.Context) error {
+	if args := c.Args(); len(args) > 0 {
+		if args[0] != "warn" {
+			return c.Send("用法:\n/anti_channel - 开关频道过滤\n/anti_channel warn [群组ID] - 切换群组的仅警告模式（群内使用时默认当前群组）")
+		}
+		return toggleAntiChannelWarn(c, args[1:])
+	}
+
 	cfg := config.Get()
 
 	newStatus := !cfg.AntiChannel.Enabled
@@ -168,3 +292,53 @@ func ToggleAntiChannel(c tele.Context) error {
 	logger.Info().Bool("enabled", newStatus).Int64("admin", c.Sender().ID).Msg("切换反皮套人开关")
 	return c.Send(fmt.Sprintf("✅ 反皮套人功能 %s", status))
 }
+
+// toggleAntiChannelWarn 切换群组的仅警告模式
+func toggleAntiChannelWarn(c tele.Context, args []string) error {
+	var groupID int64
+	switch {
+	case len(args) > 0:
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return c.Send("❌ 无效的群组ID")
+		}
+		groupID = id
+	case c.Chat().Type == tele.ChatGroup || c.Chat().Type == tele.ChatSuperGroup:
+		groupID = c.Chat().ID
+	default:
+		return c.Send("用法: /anti_channel warn <群组ID>")
+	}
+
+	cfg := config.Get()
+	if !cfg.IsInGroup(groupID) {
+		return c.Send("❌ 该群组不在配置的群组列表中")
+	}
+
+	warnOnly := false
+	var newList []int64
+	for _, id := range cfg.AntiChannel.WarnOnly {
+		if id == groupID {
+			warnOnly = true
+			continue
+		}
+		newList = append(newList, id)
+	}
+	if !warnOnly {
+		newList = append(newList, groupID)
+	}
+
+	err := config.UpdateAndSave(func(cfg *config.Config) {
+		cfg.AntiChannel.WarnOnly = newList
+	})
+	if err != nil {
+		return c.Send("❌ 保存配置失败")
+	}
+
+	mode := "删除消息并封禁频道"
+	if !warnOnly {
+		mode = "仅警告"
+	}
+
+	logger.Info().Int64("group", groupID).Bool("warn_only", !warnOnly).Int64("admin", c.Sender().ID).Msg("切换反皮套人群组模式")
+	return c.Send(fmt.Sprintf("✅ 群组 `%d` 的反皮套人模式已切换为: %s", groupID, mode), tele.ModeMarkdown)
+}
@@ -12,20 +12,23 @@ import (
 
 	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
 )
 
 // AuditIP /auditip 根据 IP 地址审计用户活动
-// 用法: /auditip <IP地址> [天数]
+// 用法: /auditip <IP地址> [天数|开始~结束]
 func AuditIP(c tele.Context) error {
 	args := c.Args()
 	if len(args) == 0 {
 		return c.Send(
 			"🔍 **IP 审计**\n\n"+
-				"用法: `/auditip <IP地址> [天数]`\n\n"+
+				"用法: `/auditip <IP地址> [天数|开始~结束]`\n\n"+
 				"示例:\n"+
 				"- `/auditip 192.168.1.100` - 查询所有时间\n"+
-				"- `/auditip 192.168.1.100 30` - 查询最近 30 天",
+				"- `/auditip 192.168.1.100 30` - 查询最近 30 天\n"+
+				"- `/auditip 192.168.1.100 2024-01-01~2024-01-31` - 查询指定日期",
 			tele.ModeMarkdown,
 		)
 	}
@@ -37,34 +40,33 @@ func AuditIP(c tele.Context) error {
 		return c.Send("❌ 无效的 IP 地址格式，请输入有效的 IPv4 或 IPv6 地址")
 	}
 
-	// 解析天数
-	days := 0
+	// 解析时间范围
+	var rng service.AuditRange
 	if len(args) > 1 {
-		var err error
-		days, err = strconv.Atoi(args[1])
-		if err != nil || days < 0 {
-			return c.Send("❌ 无效的天数")
+		var ok bool
+		if rng, ok = service.ParseAuditRange(args[1], time.Now()); !ok {
+			return c.Send("❌ 无效的时间范围，请输入天数或 `开始日期~结束日期`", tele.ModeMarkdown)
 		}
 	}
 
 	c.Send("⏳ 正在查询...")
 
-	client := emby.GetClient()
-	results, err := client.GetUsersByIP(ipAddress, days)
+	auditSvc := service.NewAuditService()
+	results, err := auditSvc.Query(emby.AuditFilter{IP: ipAddress, Since: rng.Since, Until: rng.Until})
 	if err != nil {
 		logger.Error().Err(err).Str("ip", ipAddress).Msg("IP 审计查询失败")
 		return c.Send("❌ 查询失败: " + err.Error())
 	}
 
 	if len(results) == 0 {
-		return c.Send(fmt.Sprintf("📋 未找到使用 IP `%s` 的用户记录", ipAddress), tele.ModeMarkdown)
+		return c.Send(fmt.Sprintf("📋 未找到使用 IP %s 的用户记录", utils.MarkdownCode(ipAddress)), tele.ModeMarkdown)
 	}
 
 	// 构建报告
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🔍 **IP 审计报告**\n\n**IP**: `%s`\n", ipAddress))
-	if days > 0 {
-		sb.WriteString(fmt.Sprintf("**时间范围**: 最近 %d 天\n", days))
+	sb.WriteString(fmt.Sprintf("🔍 **IP 审计报告**\n\n**IP**: %s\n", utils.MarkdownCode(ipAddress)))
+	if rng.Label != "" {
+		sb.WriteString(fmt.Sprintf("**时间范围**: %s\n", rng.Label))
 	}
 	sb.WriteString(fmt.Sprintf("**匹配用户**: %d 人\n\n", len(results)))
 
@@ -77,8 +79,8 @@ func AuditIP(c tele.Context) error {
 			"%d. **%s**\n"+
 				"   设备: %s | 客户端: %s\n"+
 				"   活动次数: %d | 最后活动: %s\n\n",
-			i+1, r.Username,
-			r.DeviceName, r.ClientName,
+			i+1, utils.EscapeMarkdown(r.Username),
+			utils.EscapeMarkdown(r.DeviceName), utils.EscapeMarkdown(r.ClientName),
 			r.ActivityCount, r.LastActivity.Format("2006-01-02 15:04"),
 		))
 	}
@@ -87,13 +89,13 @@ func AuditIP(c tele.Context) error {
 }
 
 // AuditDevice /auditdevice 根据设备名审计用户
-// 用法: /auditdevice <设备名关键词> [天数]
+// 用法: /auditdevice <设备名关键词> [天数|开始~结束]
 func AuditDevice(c tele.Context) error {
 	args := c.Args()
 	if len(args) == 0 {
 		return c.Send(
 			"🔍 **设备审计**\n\n"+
-				"用法: `/auditdevice <设备名关键词> [天数]`\n\n"+
+				"用法: `/auditdevice <设备名关键词> [天数|开始~结束]`\n\n"+
 				"示例:\n"+
 				"- `/auditdevice Chrome` - 查询 Chrome 设备\n"+
 				"- `/auditdevice iPhone 7` - 查询最近 7 天的 iPhone",
@@ -101,41 +103,26 @@ func AuditDevice(c tele.Context) error {
 		)
 	}
 
-	deviceKeyword := args[0]
-
-	// 解析天数
-	days := 0
-	if len(args) > 1 {
-		var err error
-		days, err = strconv.Atoi(args[len(args)-1])
-		if err != nil {
-			// 如果最后一个参数不是数字，把它也当作关键词的一部分
-			deviceKeyword = strings.Join(args, " ")
-			days = 0
-		} else {
-			// 排除最后的天数参数
-			deviceKeyword = strings.Join(args[:len(args)-1], " ")
-		}
-	}
+	deviceKeyword, rng := splitAuditArgs(args)
 
 	c.Send("⏳ 正在查询...")
 
-	client := emby.GetClient()
-	results, err := client.GetUsersByDeviceName(deviceKeyword, days)
+	auditSvc := service.NewAuditService()
+	results, err := auditSvc.Query(emby.AuditFilter{Device: deviceKeyword, Since: rng.Since, Until: rng.Until})
 	if err != nil {
 		logger.Error().Err(err).Str("device", deviceKeyword).Msg("设备审计查询失败")
 		return c.Send("❌ 查询失败: " + err.Error())
 	}
 
 	if len(results) == 0 {
-		return c.Send(fmt.Sprintf("📋 未找到使用设备 `%s` 的用户记录", deviceKeyword), tele.ModeMarkdown)
+		return c.Send(fmt.Sprintf("📋 未找到使用设备 %s 的用户记录", utils.MarkdownCode(deviceKeyword)), tele.ModeMarkdown)
 	}
 
 	// 构建报告
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🔍 **设备审计报告**\n\n**设备关键词**: `%s`\n", deviceKeyword))
-	if days > 0 {
-		sb.WriteString(fmt.Sprintf("**时间范围**: 最近 %d 天\n", days))
+	sb.WriteString(fmt.Sprintf("🔍 **设备审计报告**\n\n**设备关键词**: %s\n", utils.MarkdownCode(deviceKeyword)))
+	if rng.Label != "" {
+		sb.WriteString(fmt.Sprintf("**时间范围**: %s\n", rng.Label))
 	}
 	sb.WriteString(fmt.Sprintf("**匹配用户**: %d 人\n\n", len(results)))
 
@@ -148,9 +135,9 @@ func AuditDevice(c tele.Context) error {
 			"%d. **%s**\n"+
 				"   设备: %s | 客户端: %s\n"+
 				"   IP: %s | 活动次数: %d\n\n",
-			i+1, r.Username,
-			r.DeviceName, r.ClientName,
-			r.RemoteAddress, r.ActivityCount,
+			i+1, utils.EscapeMarkdown(r.Username),
+			utils.EscapeMarkdown(r.DeviceName), utils.EscapeMarkdown(r.ClientName),
+			utils.EscapeMarkdown(r.RemoteAddress), r.ActivityCount,
 		))
 	}
 
@@ -158,13 +145,13 @@ func AuditDevice(c tele.Context) error {
 }
 
 // AuditClient /auditclient 根据客户端名审计用户
-// 用法: /auditclient <客户端名关键词> [天数]
+// 用法: /auditclient <客户端名关键词> [天数|开始~结束]
 func AuditClient(c tele.Context) error {
 	args := c.Args()
 	if len(args) == 0 {
 		return c.Send(
 			"🔍 **客户端审计**\n\n"+
-				"用法: `/auditclient <客户端名关键词> [天数]`\n\n"+
+				"用法: `/auditclient <客户端名关键词> [天数|开始~结束]`\n\n"+
 				"示例:\n"+
 				"- `/auditclient Emby` - 查询 Emby 客户端\n"+
 				"- `/auditclient Infuse 30` - 查询最近 30 天的 Infuse",
@@ -172,39 +159,26 @@ func AuditClient(c tele.Context) error {
 		)
 	}
 
-	clientKeyword := args[0]
-
-	// 解析天数
-	days := 0
-	if len(args) > 1 {
-		var err error
-		days, err = strconv.Atoi(args[len(args)-1])
-		if err != nil {
-			clientKeyword = strings.Join(args, " ")
-			days = 0
-		} else {
-			clientKeyword = strings.Join(args[:len(args)-1], " ")
-		}
-	}
+	clientKeyword, rng := splitAuditArgs(args)
 
 	c.Send("⏳ 正在查询...")
 
-	client := emby.GetClient()
-	results, err := client.GetUsersByClientName(clientKeyword, days)
+	auditSvc := service.NewAuditService()
+	results, err := auditSvc.Query(emby.AuditFilter{Client: clientKeyword, Since: rng.Since, Until: rng.Until})
 	if err != nil {
 		logger.Error().Err(err).Str("client", clientKeyword).Msg("客户端审计查询失败")
 		return c.Send("❌ 查询失败: " + err.Error())
 	}
 
 	if len(results) == 0 {
-		return c.Send(fmt.Sprintf("📋 未找到使用客户端 `%s` 的用户记录", clientKeyword), tele.ModeMarkdown)
+		return c.Send(fmt.Sprintf("📋 未找到使用客户端 %s 的用户记录", utils.MarkdownCode(clientKeyword)), tele.ModeMarkdown)
 	}
 
 	// 构建报告
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("🔍 **客户端审计报告**\n\n**客户端关键词**: `%s`\n", clientKeyword))
-	if days > 0 {
-		sb.WriteString(fmt.Sprintf("**时间范围**: 最近 %d 天\n", days))
+	sb.WriteString(fmt.Sprintf("🔍 **客户端审计报告**\n\n**客户端关键词**: %s\n", utils.MarkdownCode(clientKeyword)))
+	if rng.Label != "" {
+		sb.WriteString(fmt.Sprintf("**时间范围**: %s\n", rng.Label))
 	}
 	sb.WriteString(fmt.Sprintf("**匹配用户**: %d 人\n\n", len(results)))
 
@@ -217,9 +191,9 @@ func AuditClient(c tele.Context) error {
 			"%d. **%s**\n"+
 				"   设备: %s | 客户端: %s\n"+
 				"   IP: %s | 活动次数: %d\n\n",
-			i+1, r.Username,
-			r.DeviceName, r.ClientName,
-			r.RemoteAddress, r.ActivityCount,
+			i+1, utils.EscapeMarkdown(r.Username),
+			utils.EscapeMarkdown(r.DeviceName), utils.EscapeMarkdown(r.ClientName),
+			utils.EscapeMarkdown(r.RemoteAddress), r.ActivityCount,
 		))
 	}
 
@@ -231,15 +205,15 @@ func AuditClient(c tele.Context) error {
 func UserIP(c tele.Context, username string) error {
 	c.Send("⏳ 正在查询用户 IP 信息...")
 
-	client := emby.GetClient()
-	results, err := client.GetUserActivityByName(username, 30)
+	auditSvc := service.NewAuditService()
+	results, err := auditSvc.UserActivity(username, 30)
 	if err != nil {
 		logger.Error().Err(err).Str("username", username).Msg("用户 IP 查询失败")
 		return c.Send("❌ 查询失败: " + err.Error())
 	}
 
 	if len(results) == 0 {
-		return c.Send(fmt.Sprintf("📋 未找到用户 `%s` 的活动记录", username), tele.ModeMarkdown)
+		return c.Send(fmt.Sprintf("📋 未找到用户 %s 的活动记录", utils.MarkdownCode(username)), tele.ModeMarkdown)
 	}
 
 	// 统计 IP 使用情况
@@ -272,7 +246,7 @@ func UserIP(c tele.Context, username string) error {
 
 	// 构建报告
 	var sb strings.Builder
-	sb.WriteString(fmt.Sprintf("👤 **用户 IP 信息**\n\n**用户名**: `%s`\n", username))
+	sb.WriteString(fmt.Sprintf("👤 **用户 IP 信息**\n\n**用户名**: %s\n", utils.MarkdownCode(username)))
 	sb.WriteString(fmt.Sprintf("**使用 IP 数**: %d 个\n\n", len(ipStats)))
 
 	i := 0
@@ -285,14 +259,14 @@ func UserIP(c tele.Context, username string) error {
 
 		devices := make([]string, 0, len(stat.Devices))
 		for d := range stat.Devices {
-			devices = append(devices, d)
+			devices = append(devices, utils.EscapeMarkdown(d))
 		}
 
 		sb.WriteString(fmt.Sprintf(
 			"**%s**\n"+
 				"  活动次数: %d | 最后活动: %s\n"+
 				"  设备: %s\n\n",
-			ip,
+			utils.EscapeMarkdown(ip),
 			stat.Count, stat.LastActive.Format("01-02 15:04"),
 			strings.Join(devices, ", "),
 		))
@@ -300,3 +274,109 @@ func UserIP(c tele.Context, username string) error {
 
 	return c.Send(sb.String(), keyboards.CloseKeyboard(), tele.ModeMarkdown)
 }
+
+// AuditItem /audititem 查询观看过指定媒体的用户
+// 用法: /audititem <媒体ID或名称关键词> [天数|开始~结束]
+func AuditItem(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(
+			"🔍 **媒体审计**\n\n"+
+				"用法: `/audititem <媒体ID或名称关键词> [天数|开始~结束]`\n\n"+
+				"示例:\n"+
+				"- `/audititem 12345` - 按媒体 ID 查询\n"+
+				"- `/audititem 三体 7` - 查询最近 7 天观看三体的用户",
+			tele.ModeMarkdown,
+		)
+	}
+
+	keyword, rng := splitAuditArgs(args)
+	filter := emby.AuditFilter{Since: rng.Since, Until: rng.Until}
+	if _, err := strconv.ParseInt(keyword, 10, 64); err == nil {
+		filter.ItemID = keyword
+	} else {
+		filter.ItemName = keyword
+	}
+
+	c.Send("⏳ 正在查询...")
+
+	results, err := service.NewAuditService().Query(filter)
+	if err != nil {
+		logger.Error().Err(err).Str("item", keyword).Msg("媒体审计查询失败")
+		return c.Send("❌ 查询失败: " + err.Error())
+	}
+
+	if len(results) == 0 {
+		return c.Send(fmt.Sprintf("📋 未找到媒体 %s 的播放记录", utils.MarkdownCode(keyword)), tele.ModeMarkdown)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔍 **媒体审计报告**\n\n**媒体**: %s\n", utils.MarkdownCode(keyword)))
+	if rng.Label != "" {
+		sb.WriteString(fmt.Sprintf("**时间范围**: %s\n", rng.Label))
+	}
+	sb.WriteString(fmt.Sprintf("**匹配记录**: %d 条\n\n", len(results)))
+
+	for i, r := range results {
+		if i >= 20 {
+			sb.WriteString(fmt.Sprintf("\n... 还有 %d 条记录", len(results)-20))
+			break
+		}
+		sb.WriteString(fmt.Sprintf(
+			"%d. **%s**\n"+
+				"   设备: %s | 客户端: %s\n"+
+				"   IP: %s | 播放次数: %d | 最后: %s\n\n",
+			i+1, utils.EscapeMarkdown(r.Username),
+			utils.EscapeMarkdown(r.DeviceName), utils.EscapeMarkdown(r.ClientName),
+			utils.EscapeMarkdown(r.RemoteAddress), r.ActivityCount, r.LastActivity.Format("01-02 15:04"),
+		))
+	}
+
+	return c.Send(sb.String(), keyboards.CloseKeyboard(), tele.ModeMarkdown)
+}
+
+// AuditShared /auditshared 检测最近 N 天被多个用户共用的 IP 或设备
+// 用法: /auditshared [ip|device] [天数]
+func AuditShared(c tele.Context) error {
+	by := emby.SharedByIP
+	days := 7
+
+	for _, arg := range c.Args() {
+		switch strings.ToLower(arg) {
+		case "ip":
+			by = emby.SharedByIP
+		case "device", "设备":
+			by = emby.SharedByDevice
+		default:
+			n, err := strconv.Atoi(arg)
+			if err != nil || n <= 0 || n > 365 {
+				return c.Send("用法: `/auditshared [ip|device] [天数]`\n\n天数范围 1-365，默认检测最近 7 天的共用 IP", tele.ModeMarkdown)
+			}
+			days = n
+		}
+	}
+
+	c.Send("⏳ 正在查询...")
+
+	clusters, err := service.NewAuditService().SharedUsage(by, days)
+	if err != nil {
+		logger.Error().Err(err).Str("by", by).Msg("共用检测查询失败")
+		return c.Send("❌ 查询失败: " + err.Error())
+	}
+
+	if len(clusters) == 0 {
+		return c.Send(fmt.Sprintf("✅ 最近 %d 天未发现多个用户共用的记录", days))
+	}
+
+	return c.Send(service.FormatSharedClusters(clusters, by, days), keyboards.CloseKeyboard(), tele.ModeMarkdown)
+}
+
+// splitAuditArgs 拆分关键词与末尾的时间范围参数（末尾参数不是时间范围时视为关键词的一部分）
+func splitAuditArgs(args []string) (string, service.AuditRange) {
+	if len(args) > 1 {
+		if rng, ok := service.ParseAuditRange(args[len(args)-1], time.Now()); ok {
+			return strings.Join(args[:len(args)-1], " "), rng
+		}
+	}
+	return strings.Join(args, " "), service.AuditRange{}
+}
@@ -0,0 +1,286 @@
+// Package handlers 数据库备份恢复处理器
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// restoreListLimit 恢复列表最多显示的备份数
+const restoreListLimit = 10
+
+// newBackupService 创建备份服务（Telegram 异地备份目标使用当前 Bot）
+func newBackupService(c tele.Context) *service.BackupService {
+	svc := service.NewBackupService()
+	svc.SetBot(c.Bot())
+	return svc
+}
+
+// RestoreFromDB /restore_from_db 选择备份文件恢复数据库；/restore_from_db emby true 在 Emby 上重建账户
+func RestoreFromDB(c tele.Context) error {
+	if args := c.Args(); len(args) > 0 && args[0] == "emby" {
+		return restoreEmbyAccounts(c, args[1:])
+	}
+
+	backups, err := newBackupService(c).ListBackups()
+	if err != nil {
+		logger.Error().Err(err).Msg("获取备份列表失败")
+		return c.Send("❌ 获取备份列表失败: " + err.Error())
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, b := range backups {
+		kind := "JSON"
+		switch service.BackupEngineOf(b.Filename) {
+		case service.BackupEngineJSON:
+		case service.BackupEngineMysqldump:
+			kind = "SQL"
+		default:
+			continue
+		}
+		label := fmt.Sprintf("%s · %s · %s", b.CreatedAt.Format("2006-01-02 15:04"), kind, service.FormatSize(b.Size))
+		rows = append(rows, markup.Row(markup.Data(label, "restore_db", "preview", b.Filename)))
+		if len(rows) >= restoreListLimit {
+			break
+		}
+	}
+
+	// 仅存在于异地的备份（本地已清理或本机为新部署）
+	local := make(map[string]bool, len(backups))
+	for _, b := range backups {
+		local[b.Filename] = true
+	}
+	remoteRows := 0
+	for _, b := range newBackupService(c).ListRemoteBackups() {
+		idx := backupTargetIndex(b.Target)
+		if local[b.Filename] || idx < 0 {
+			continue
+		}
+		label := fmt.Sprintf("☁️ %s · %s · %s", b.Target, b.CreatedAt.Format("2006-01-02 15:04"), service.FormatSize(b.Size))
+		rows = append(rows, markup.Row(markup.Data(label, "restore_db", "fetch", strconv.Itoa(idx), b.Filename)))
+		local[b.Filename] = true
+		if remoteRows++; remoteRows >= restoreListLimit {
+			break
+		}
+	}
+
+	if len(rows) == 0 {
+		return c.Send("📭 没有可用的备份文件，请先使用 /backup_db 备份")
+	}
+	rows = append(rows, markup.Row(markup.Data("❌ 关闭", "close")))
+	markup.Inline(rows...)
+
+	return c.Send("🗄 **从备份恢复数据库**\n\n"+
+		"请选择备份文件，确认前会先预览与当前数据的差异。\n"+
+		"JSON 备份会在事务中替换备份包含的表，未包含的表保持不变；SQL 备份通过 mysql 导入。\n"+
+		"☁️ 为仅存在于异地的备份，选择后会先下载到本地。\n\n"+
+		"如需根据数据库记录在 Emby 上重建账户，请使用 `/restore_from_db emby`", markup, tele.ModeMarkdown)
+}
+
+// backupTargetIndex 异地备份目标在配置中的序号（回调数据长度有限，不直接携带名称）
+func backupTargetIndex(name string) int {
+	for i, t := range config.Get().Database.BackupTargets {
+		if t.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// handleRestoreDB 备份恢复按钮（restore_db|preview|<文件名>、restore_db|confirm|<文件名>、
+// restore_db|fetch|<目标序号>|<文件名>）
+func handleRestoreDB(c tele.Context, parts []string) error {
+	if !config.Get().IsOwner(c.Sender().ID) {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 此操作仅限 Owner", ShowAlert: true})
+	}
+	if len(parts) < 3 {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	if parts[1] == "fetch" {
+		return handleFetchRemoteBackup(c, parts)
+	}
+	filename := parts[2]
+	if service.IsDumpBackup(filename) {
+		return handleRestoreDump(c, parts[1], filename)
+	}
+	svc := newBackupService(c)
+
+	switch parts[1] {
+	case "preview":
+		plan, err := svc.PlanRestore(filename)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		c.Respond()
+
+		markup := &tele.ReplyMarkup{}
+		markup.Inline(
+			markup.Row(markup.Data("✅ 确认恢复", "restore_db", "confirm", filename)),
+			markup.Row(markup.Data("❌ 取消", "close")),
+		)
+		text := formatRestorePlan(plan) + "\n⚠️ 确认后将在一个事务中替换上述表的数据，恢复前会自动备份当前数据。"
+		return c.Edit(text, markup, tele.ModeMarkdown)
+
+	case "confirm":
+		c.Respond(&tele.CallbackResponse{Text: "⏳ 正在恢复..."})
+		c.Edit("⏳ 正在备份当前数据并恢复，请稍候...")
+
+		// 恢复前先备份当前数据，便于回滚
+		safety, err := svc.Backup(true)
+		if err != nil {
+			logger.Error().Err(err).Msg("恢复前备份失败")
+			return c.Edit("❌ 恢复前备份当前数据失败，已取消恢复: " + err.Error())
+		}
+
+		plan, err := svc.Restore(filename)
+		if err != nil {
+			logger.Error().Err(err).Str("file", filename).Msg("数据库恢复失败")
+			if errors.Is(err, service.ErrBackupNotFound) || errors.Is(err, service.ErrBackupSchemaNewer) ||
+				errors.Is(err, service.ErrBackupFormatUnsupported) {
+				return c.Edit("❌ " + err.Error())
+			}
+			return c.Edit("❌ 恢复失败，数据库未做任何修改: " + err.Error())
+		}
+
+		logger.Info().Str("file", filename).Str("safety", safety.Filename).Int64("owner", c.Sender().ID).Msg("从备份恢复数据库")
+		return c.Edit(fmt.Sprintf("✅ **恢复完成**\n\n%s\n💾 恢复前的数据已备份为 `%s`",
+			formatRestorePlan(plan), safety.Filename), tele.ModeMarkdown)
+	}
+
+	return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+}
+
+// handleRestoreDump 恢复 mysqldump 备份：SQL 文件无法预览差异，确认后先导出当前数据再导入
+func handleRestoreDump(c tele.Context, action, filename string) error {
+	svc := newBackupService(c)
+
+	switch action {
+	case "preview":
+		c.Respond()
+		markup := &tele.ReplyMarkup{}
+		markup.Inline(
+			markup.Row(markup.Data("✅ 确认导入", "restore_db", "confirm", filename)),
+			markup.Row(markup.Data("❌ 取消", "close")),
+		)
+		return c.Edit(fmt.Sprintf("🗄 **SQL 备份** `%s`\n\n"+
+			"将通过 mysql 导入该文件，文件中包含的表会被删除并重建。\n"+
+			"SQL 导入无法预览差异，也不在单个事务中执行；确认后会先用 mysqldump 备份当前数据。", filename),
+			markup, tele.ModeMarkdown)
+
+	case "confirm":
+		c.Respond(&tele.CallbackResponse{Text: "⏳ 正在导入..."})
+		c.Edit("⏳ 正在备份当前数据并导入，请稍候...")
+
+		safety, err := svc.Dump()
+		if err != nil {
+			logger.Error().Err(err).Msg("导入前备份失败")
+			return c.Edit("❌ 导入前备份当前数据失败，已取消导入: " + err.Error())
+		}
+		if err := svc.RestoreDump(filename); err != nil {
+			logger.Error().Err(err).Str("file", filename).Msg("SQL 备份导入失败")
+			return c.Edit(fmt.Sprintf("❌ 导入失败: %s\n\n恢复前的数据已备份为 %s", err.Error(), safety.Filename))
+		}
+
+		logger.Info().Str("file", filename).Str("safety", safety.Filename).Int64("owner", c.Sender().ID).Msg("从 SQL 备份恢复数据库")
+		return c.Edit(fmt.Sprintf("✅ **导入完成**\n\n备份: `%s`\n💾 恢复前的数据已备份为 `%s`", filename, safety.Filename), tele.ModeMarkdown)
+	}
+
+	return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+}
+
+// handleFetchRemoteBackup 下载异地备份到本地后进入预览
+func handleFetchRemoteBackup(c tele.Context, parts []string) error {
+	if len(parts) < 4 {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	targets := config.Get().Database.BackupTargets
+	idx, err := strconv.Atoi(parts[2])
+	if err != nil || idx < 0 || idx >= len(targets) {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 异地备份目标不存在", ShowAlert: true})
+	}
+	target, filename := targets[idx].Name, parts[3]
+
+	c.Edit(fmt.Sprintf("⏳ 正在从 %s 下载 %s ...", target, filename))
+	if err := newBackupService(c).FetchRemoteBackup(target, filename); err != nil {
+		logger.Error().Err(err).Str("target", target).Str("file", filename).Msg("下载异地备份失败")
+		c.Respond(&tele.CallbackResponse{Text: "❌ 下载失败", ShowAlert: true})
+		return c.Edit("❌ " + err.Error())
+	}
+	return handleRestoreDB(c, []string{"restore_db", "preview", filename})
+}
+
+// formatTargetUploads 格式化异地备份上传结果（未配置目标时为空）
+func formatTargetUploads(uploads []service.TargetUpload) string {
+	if len(uploads) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n☁️ 异地备份:")
+	for _, u := range uploads {
+		switch {
+		case u.Err != nil:
+			sb.WriteString(fmt.Sprintf("\n  ❌ %s: %s", u.Target, u.Err.Error()))
+		case u.Pruned > 0:
+			sb.WriteString(fmt.Sprintf("\n  ✅ %s（清理 %d 个旧备份）", u.Target, u.Pruned))
+		default:
+			sb.WriteString(fmt.Sprintf("\n  ✅ %s", u.Target))
+		}
+	}
+	return sb.String()
+}
+
+// formatRestorePlan 格式化恢复差异预览
+func formatRestorePlan(plan *service.RestorePlan) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🗄 **备份** `%s`\n", plan.Filename))
+	if plan.FormatVersion == 0 {
+		sb.WriteString("格式: 1.0（旧格式，仅含用户、注册码与红包）\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("格式: v%d · 表结构: v%d\n", plan.FormatVersion, plan.SchemaVersion))
+	}
+	if !plan.CreatedAt.IsZero() {
+		sb.WriteString(fmt.Sprintf("时间: %s\n", plan.CreatedAt.Format("2006-01-02 15:04:05")))
+	}
+
+	sb.WriteString("\n```\n表名                 当前 → 备份  +新增 -删除 ~修改\n")
+	var skipped []string
+	for _, d := range plan.Tables {
+		if d.Skipped {
+			skipped = append(skipped, d.Table)
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("%-20s %4d → %-4d  +%d -%d ~%d\n", d.Table, d.Current, d.Backup, d.Added, d.Removed, d.Changed))
+	}
+	sb.WriteString("```\n")
+
+	if len(skipped) > 0 {
+		sb.WriteString(fmt.Sprintf("\n⏭ 备份中没有、保持不变: %s\n", codeList(skipped)))
+	}
+	for _, d := range plan.Tables {
+		if len(d.UnknownColumns) > 0 {
+			sb.WriteString(fmt.Sprintf("⚠️ `%s` 忽略未知列: %s\n", d.Table, codeList(d.UnknownColumns)))
+		}
+	}
+	if len(plan.UnknownTables) > 0 {
+		sb.WriteString(fmt.Sprintf("⚠️ 忽略当前版本不存在的表: %s\n", codeList(plan.UnknownTables)))
+	}
+	return sb.String()
+}
+
+// codeList 以行内代码格式列出名称（表名、列名含下划线，避免破坏 Markdown）
+func codeList(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = "`" + name + "`"
+	}
+	return strings.Join(quoted, ", ")
+}
@@ -39,7 +39,6 @@ func SyncUnbound(c tele.Context) error {
 	c.Send("⏳ 正在检查未绑定 Bot 的 Emby 用户...")
 
 	batchSvc := service.NewBatchService()
-	batchSvc.SetBot(c.Bot())
 
 	result, err := batchSvc.SyncUnbound(dryRun)
 	if err != nil {
@@ -129,7 +128,6 @@ func CheckExpiredManual(c tele.Context) error {
 	c.Send("⏳ 正在执行到期检测...")
 
 	expirySvc := service.NewExpiryService()
-	expirySvc.SetBot(c.Bot())
 
 	result, err := expirySvc.CheckExpired()
 	if err != nil {
@@ -164,7 +162,6 @@ func CheckActivityManual(c tele.Context) error {
 	c.Send("⏳ 正在执行活跃度检测...")
 
 	activitySvc := service.NewActivityService()
-	activitySvc.SetBot(c.Bot())
 
 	result, err := activitySvc.CheckLowActivity()
 	if err != nil {
@@ -13,6 +13,7 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -196,11 +197,10 @@ func OnlyRmRecord(c tele.Context) error {
 	return c.Send(fmt.Sprintf("✅ 已删除数据库记录\n\nTG ID: `%d`\n用户名: `%s`\n\n⚠️ Emby 服务器账户已保留", tgID, userName), tele.ModeMarkdown)
 }
 
-// RestoreFromDB /restore_from_db 从数据库恢复账户
-func RestoreFromDB(c tele.Context) error {
-	args := c.Args()
+// restoreEmbyAccounts /restore_from_db emby true 根据数据库记录在 Emby 服务器上重建账户
+func restoreEmbyAccounts(c tele.Context, args []string) error {
 	if len(args) == 0 || args[0] != "true" {
-		return c.Send("⚠️ 此命令将根据数据库记录在 Emby 服务器上重建账户\n\n确认执行请发送: `/restore_from_db true`", tele.ModeMarkdown)
+		return c.Send("⚠️ 此命令将根据数据库记录在 Emby 服务器上重建账户\n\n确认执行请发送: `/restore_from_db emby true`", tele.ModeMarkdown)
 	}
 
 	c.Delete()
@@ -250,10 +250,11 @@ func RestoreFromDB(c tele.Context) error {
 		}
 
 		// 更新数据库
-		repo.UpdateFields(u.TG, map[string]interface{}{
+		repo.BindAccount(u.TG, map[string]interface{}{
 			"embyid": result.UserID,
 			"pwd":    result.Password,
 		})
+		service.SyncServers(u.TG)
 
 		restored++
 
@@ -268,7 +269,7 @@ func RestoreFromDB(c tele.Context) error {
 			*u.Name,
 			result.Password,
 			getSecurityCode(u.Pwd2),
-			cfg.Emby.Line,
+			cfg.Emby.Main().Line,
 		)
 		c.Bot().Send(userChat, notifyMsg, tele.ModeMarkdown)
 	}
@@ -0,0 +1,178 @@
+// Package handlers 广播处理器
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// blockedListLimit 屏蔽用户列表最多显示的数量
+const blockedListLimit = 50
+
+// broadcastUsage 广播用法说明
+const broadcastUsage = "📝 **用法：** `/broadcast [受众条件...] <消息模板>`\n" +
+	"或回复一条消息并使用 `/broadcast [受众条件...]`\n\n" +
+	"**受众条件**（可组合，默认所有用户）：\n" +
+	"`lv=a,b` 按等级 · `emby=1|0` 是否有 Emby 账户\n" +
+	"`expire=7` 7 天内到期 · `expired=30` 过期 30 天内\n" +
+	"`active=7` 7 天内活跃 · `inactive=30` 30 天未活跃\n\n" +
+	"**模板变量**：`{name}` `{tg}` `{level}` `{expiry}` `{days}` `{balance}` `{coins}`\n" +
+	"模板按 HTML 发送，可使用 `<b>` `<i>` `<a href>` 等标签。\n\n" +
+	"示例：`/broadcast emby=1 expire=7 {name}，您的账户将于 {expiry} 到期`\n" +
+	"`/broadcast blocked` 查看已屏蔽 Bot、待清理的用户"
+
+// Broadcast /broadcast 创建广播任务，确认后按速率发送
+func Broadcast(c tele.Context) error {
+	payload := strings.TrimSpace(c.Message().Payload)
+	if payload == "blocked" {
+		return listBlockedUsers(c)
+	}
+	if payload == "" && c.Message().ReplyTo == nil {
+		return sendBroadcastList(c)
+	}
+	return createBroadcast(c, payload, nil)
+}
+
+// CallAll /callall 广播给所有有 Emby 账户的用户（未指定 emby 条件时）
+func CallAll(c tele.Context) error {
+	if strings.TrimSpace(c.Message().Payload) == "" && c.Message().ReplyTo == nil {
+		return c.Reply(broadcastUsage, tele.ModeMarkdown)
+	}
+	hasEmby := true
+	return createBroadcast(c, c.Message().Payload, &hasEmby)
+}
+
+// createBroadcast 解析参数、创建任务并发送预览
+func createBroadcast(c tele.Context, payload string, defaultHasEmby *bool) error {
+	audience, template, err := service.ParseBroadcastArgs(payload)
+	if err != nil {
+		return c.Reply("❌ " + err.Error())
+	}
+	if audience.HasEmby == nil {
+		audience.HasEmby = defaultHasEmby
+	}
+	if reply := c.Message().ReplyTo; reply != nil && template == "" {
+		// 回复的消息按纯文本发送
+		template = html.EscapeString(reply.Text)
+		if template == "" {
+			template = html.EscapeString(reply.Caption)
+		}
+	}
+
+	svc := service.NewBroadcastService()
+	job, err := svc.Create(c.Sender().ID, template, audience)
+	if err != nil {
+		if errors.Is(err, service.ErrBroadcastEmpty) || errors.Is(err, service.ErrBroadcastNoUsers) {
+			return c.Reply("❌ " + err.Error())
+		}
+		logger.Error().Err(err).Msg("创建广播任务失败")
+		return c.Reply("❌ 创建广播任务失败: " + err.Error())
+	}
+
+	// 预览同时校验 HTML 模板，格式错误时直接取消
+	if _, err := c.Bot().Send(c.Chat(), svc.Preview(job), tele.ModeHTML, tele.NoPreview); err != nil {
+		svc.Cancel(job.ID)
+		return c.Reply("❌ 模板无法发送，请检查 HTML 标签: " + err.Error())
+	}
+	return c.Send("👆 以上为第一位接收者看到的内容\n\n"+service.FormatBroadcastProgress(job), service.BroadcastControls(job))
+}
+
+// sendBroadcastList 用法与最近的广播任务
+func sendBroadcastList(c tele.Context) error {
+	jobs, err := service.NewBroadcastService().Recent(8)
+	if err != nil {
+		logger.Error().Err(err).Msg("获取广播任务失败")
+		return c.Reply(broadcastUsage, tele.ModeMarkdown)
+	}
+	if len(jobs) == 0 {
+		return c.Reply(broadcastUsage, tele.ModeMarkdown)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var rows []tele.Row
+	for _, job := range jobs {
+		label := fmt.Sprintf("#%d %s %d/%d · %s", job.ID, service.BroadcastStatusText(job.Status),
+			job.Processed(), job.Total, job.CreatedAt.Format("01-02 15:04"))
+		rows = append(rows, markup.Row(markup.Data(label, "broadcast", "view", strconv.FormatUint(uint64(job.ID), 10))))
+	}
+	markup.Inline(rows...)
+	return c.Reply(broadcastUsage+"\n\n**最近的广播：**", markup, tele.ModeMarkdown)
+}
+
+// listBlockedUsers 已屏蔽 Bot 的用户（用户重新 /start 后自动取消标记）
+func listBlockedUsers(c tele.Context) error {
+	users, total, err := repository.NewEmbyRepository().ListBlocked(1, blockedListLimit)
+	if err != nil {
+		return c.Reply("❌ 查询失败: " + err.Error())
+	}
+	if total == 0 {
+		return c.Reply("✅ 没有已屏蔽 Bot 的用户")
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🚫 **已屏蔽 Bot 的用户**（共 %d 个）\n\n", total))
+	for _, u := range users {
+		name := "-"
+		if u.Name != nil && *u.Name != "" {
+			name = *u.Name
+		}
+		sb.WriteString(fmt.Sprintf("`%d` `%s` · %s\n", u.TG, name, u.BlockedAt.Format("2006-01-02")))
+	}
+	if total > int64(len(users)) {
+		sb.WriteString(fmt.Sprintf("\n仅显示最近 %d 个", len(users)))
+	}
+	sb.WriteString("\n这些用户不会再收到广播，有 Emby 账户的可用 /kk 等命令清理。")
+	return c.Reply(sb.String(), tele.ModeMarkdown)
+}
+
+// handleBroadcast 广播控制按钮（broadcast|start|id、broadcast|pause|id、broadcast|cancel|id、broadcast|view|id）
+func handleBroadcast(c tele.Context, parts []string) error {
+	if !config.Get().IsAdmin(c.Sender().ID) {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 仅管理员可操作", ShowAlert: true})
+	}
+	if len(parts) < 3 {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	jobID := uint(id)
+	svc := service.NewBroadcastService()
+
+	var actionErr error
+	switch parts[1] {
+	case "start":
+		_, actionErr = svc.Start(jobID, c.Chat().ID, c.Message().ID)
+	case "pause":
+		actionErr = svc.Pause(jobID)
+	case "cancel":
+		actionErr = svc.Cancel(jobID)
+	case "view":
+	default:
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	if actionErr != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ " + actionErr.Error(), ShowAlert: true})
+	}
+	if parts[1] != "view" {
+		logger.Info().Uint("job", jobID).Str("action", parts[1]).Int64("admin", c.Sender().ID).Msg("广播操作")
+	}
+
+	job, err := svc.Get(jobID)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 广播任务不存在", ShowAlert: true})
+	}
+	c.Respond()
+	return editOrReply(c, service.FormatBroadcastProgress(job), service.BroadcastControls(job))
+}
@@ -18,6 +18,7 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
 	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
 )
 
 // isPublicAction 判断是否是公共操作（任何人都可以点击的按钮）
@@ -116,6 +117,8 @@ func OnCallback(c tele.Context) error {
 		return handleClose(c)
 	case "myinfo":
 		return MyInfo(c)
+	case "invitees":
+		return handleInvitees(c, parts)
 	case "count":
 		return Count(c)
 	case "register":
@@ -128,6 +131,15 @@ func OnCallback(c tele.Context) error {
 		return handleResetPwd(c)
 	case "checkin":
 		return handleCheckin(c)
+	case "checkin_cal":
+		c.Respond()
+		return sendCheckinCalendar(c, "", false)
+	case "checkin_repair":
+		return handleCheckinRepair(c, parts)
+	case "restore_db":
+		return handleRestoreDB(c, parts)
+	case "broadcast":
+		return handleBroadcast(c, parts)
 	case "admin_panel":
 		return handleAdminPanel(c)
 	// 注册状态面板
@@ -174,7 +186,7 @@ func OnCallback(c tele.Context) error {
 	// 定时任务面板
 	case "schedall":
 		return handleSchedAll(c)
-	case "sched_dayrank", "sched_weekrank", "sched_dayplayrank", "sched_weekplayrank", "sched_check_ex", "sched_low_activity", "sched_backup_db":
+	case "sched_dayrank", "sched_weekrank", "sched_dayplayrank", "sched_weekplayrank", "sched_daymediarank", "sched_weekmediarank", "sched_check_ex", "sched_low_activity", "sched_backup_db", "sched_expiry_warning":
 		return handleSchedToggle(c, action)
 	// 白名单列表、设备列表
 	case "admin_whitelist":
@@ -292,6 +304,10 @@ func OnCallback(c tele.Context) error {
 		return handleDevicesPage(c, parts)
 	case "codes_page":
 		return handleCodesPage(c, parts)
+	case "code_batch":
+		return handleCodeBatch(c, parts)
+	case "ledger_page":
+		return handleLedgerPage(c, parts)
 	// /kk 面板的用户管理按钮
 	case "user_ban":
 		if len(parts) >= 2 {
@@ -356,6 +372,9 @@ func OnCallback(c tele.Context) error {
 	// 查看管理员注册码
 	case "ch_admin_link":
 		return handleChAdminLink(c, parts)
+	// 疑似共享账户处理
+	case "sharing":
+		return handleSharingDecision(c, parts)
 	default:
 		// 检查是否是 changetg_xxx_xxx 格式（管理员审核）
 		if strings.HasPrefix(data, "changetg_") || strings.HasPrefix(data, "nochangetg_") {
@@ -401,14 +420,6 @@ func handleClose(c tele.Context) error {
 func handleRegister(c tele.Context) error {
 	cfg := config.Get()
 
-	// 检查注册是否开放
-	if !cfg.Open.Status {
-		return c.Respond(&tele.CallbackResponse{
-			Text:      "❌ 注册暂未开放",
-			ShowAlert: true,
-		})
-	}
-
 	// 检查是否已有账户
 	repo := repository.NewEmbyRepository()
 	user, _ := repo.GetByTG(c.Sender().ID)
@@ -419,10 +430,28 @@ func handleRegister(c tele.Context) error {
 		})
 	}
 
-	// 检查席位
-	if cfg.Open.Temp >= cfg.Open.MaxUsers {
+	// 注册天数未正确配置时拒绝注册，避免创建即过期的账户
+	if cfg.Open.Temp <= 0 {
+		logger.Error().Int("days", cfg.Open.Temp).Msg("注册天数配置无效")
 		return c.Respond(&tele.CallbackResponse{
-			Text:      "❌ 注册席位已满",
+			Text:      "❌ 注册天数未设置，请联系管理员",
+			ShowAlert: true,
+		})
+	}
+
+	// 检查注册是否开放并占用席位（定时注册的名额在数据库中原子扣减）
+	windowSvc := service.NewRegisterWindowService()
+	windowSvc.SetBot(c.Bot())
+	window, err := windowSvc.Reserve()
+	if err != nil {
+		text := "❌ " + err.Error()
+		if !errors.Is(err, service.ErrRegisterClosed) && !errors.Is(err, service.ErrRegisterWindowFull) &&
+			!errors.Is(err, service.ErrRegisterLimitReached) {
+			logger.Error().Err(err).Msg("占用注册席位失败")
+			text = "❌ 注册失败，请稍后重试"
+		}
+		return c.Respond(&tele.CallbackResponse{
+			Text:      text,
 			ShowAlert: true,
 		})
 	}
@@ -433,6 +462,7 @@ func handleRegister(c tele.Context) error {
 	client := emby.GetClient()
 	result, err := client.CreateUser(c.Sender().Username, cfg.Open.Temp)
 	if err != nil {
+		windowSvc.Release(window)
 		logger.Error().Err(err).Msg("创建 Emby 账户失败")
 		return editOrReply(c, "❌ 创建账户失败，请稍后重试")
 	}
@@ -445,11 +475,10 @@ func handleRegister(c tele.Context) error {
 		"ex":     result.ExpiryDate,
 		"cr":     result.ExpiryDate.AddDate(0, 0, -cfg.Open.Temp),
 	}
-	repo.UpdateFields(c.Sender().ID, updates)
-
-	// 更新临时计数
-	cfg.Open.Temp++
-	cfg.Save("config.json")
+	repo.BindAccount(c.Sender().ID, updates)
+	service.SyncServers(c.Sender().ID)
+	service.NewReferralService().OnRegistered(c.Sender().ID)
+	windowSvc.Registered(window)
 
 	text := fmt.Sprintf(
 		"✅ **账户创建成功!**\n\n"+
@@ -460,7 +489,7 @@ func handleRegister(c tele.Context) error {
 		c.Sender().Username,
 		result.Password,
 		result.ExpiryDate.Format("2006-01-02"),
-		cfg.Emby.Line,
+		cfg.Emby.Main().Line,
 	)
 
 	return editOrReply(c, text, keyboards.BackKeyboard("back_start"), tele.ModeMarkdown)
@@ -523,8 +552,9 @@ func handleAccountInfo(c tele.Context) error {
 		getPassword(user.Pwd),
 		user.GetLevelName(),
 		expiryText,
-		cfg.Emby.Line,
+		cfg.Emby.Main().Line,
 	)
+	text += formatServerAccounts(user)
 
 	c.Respond()
 	return editOrReply(c, text, keyboards.AccountInfoKeyboard(), tele.ModeMarkdown)
@@ -607,7 +637,7 @@ func handleCheckin(c tele.Context) error {
 	)
 
 	c.Respond(&tele.CallbackResponse{Text: "🎯 签到成功！"})
-	return editOrReply(c, text, keyboards.BackKeyboard("back_start"), tele.ModeMarkdown)
+	return editOrReply(c, text, keyboards.CheckinResultKeyboard(), tele.ModeMarkdown)
 }
 
 func handleAdminPanel(c tele.Context) error {
@@ -621,7 +651,26 @@ func handleAdminPanel(c tele.Context) error {
 
 	c.Respond()
 	isOwner := cfg.IsOwner(c.Sender().ID)
-	return editOrReply(c, "⚙️ **管理面板**\n\n请选择操作:", keyboards.AdminPanelKeyboard(isOwner), tele.ModeMarkdown)
+	text := "⚙️ **管理面板**\n\n" + formatDataSources() + "\n请选择操作:"
+	return editOrReply(c, text, keyboards.AdminPanelKeyboard(isOwner), tele.ModeMarkdown)
+}
+
+// formatDataSources 格式化播放统计数据源的可用性
+func formatDataSources() string {
+	statuses := emby.GetClient().DataSourceStatus()
+	if len(statuses) == 0 {
+		return "📡 播放数据源: 尚未探测\n"
+	}
+
+	text := "📡 **播放数据源**\n"
+	for _, st := range statuses {
+		state := "✅"
+		if !st.Available {
+			state = "❌"
+		}
+		text += fmt.Sprintf("%s %s\n", state, utils.EscapeMarkdown(st.Source.Label()))
+	}
+	return text
 }
 
 func handleSetLevel(c tele.Context, parts []string) error {
@@ -652,6 +701,8 @@ func handleSetLevel(c tele.Context, parts []string) error {
 	if err := repo.UpdateFields(tgID, map[string]interface{}{"lv": level}); err != nil {
 		return c.Respond(&tele.CallbackResponse{Text: "更新失败"})
 	}
+	applyStreamLimit(tgID)
+	service.SyncServers(tgID)
 
 	levelNames := map[string]string{
 		"a": "白名单",
@@ -981,11 +1032,12 @@ func handleAdminCodes(c tele.Context) error {
 	
 	text := "📝 **注册码管理**\n\n" +
 		"使用命令管理注册码:\n" +
-		"• `/code 天数 数量` - 生成注册码\n" +
+		"• `/code 天数 数量 [参数]` - 生成注册码批次\n" +
 		"• `/codestat` - 查看注册码统计\n" +
 		"• `/mycode` - 查看我的注册码\n" +
-		"• `/delcode 类型` - 删除注册码"
-	return editOrReply(c, text, keyboards.BackKeyboard("admin_panel"), tele.ModeMarkdown)
+		"• `/delcode 类型` - 删除注册码\n\n" +
+		"在批次列表中可查看、导出或作废整个批次"
+	return editOrReply(c, text, keyboards.AdminCodesKeyboard(), tele.ModeMarkdown)
 }
 
 // handleAdminStats 统计信息
@@ -1021,7 +1073,6 @@ func handleAdminCheckEx(c tele.Context) error {
 	// 直接执行到期检测
 	go func() {
 		svc := service.NewExpiryService()
-		svc.SetBot(c.Bot())
 		result, err := svc.CheckExpired()
 		if err != nil {
 			c.Send("❌ 到期检测失败: " + err.Error())
@@ -1056,6 +1107,10 @@ func handleAdminDayRanks(c tele.Context) error {
 	go func() {
 		leaderboardSvc := service.NewLeaderboardService()
 		imgPath, err := leaderboardSvc.GenerateDailyRank()
+		if errors.Is(err, emby.ErrNoPlaybackData) {
+			c.Send("📊 暂无排行数据")
+			return
+		}
 		if err != nil {
 			logger.Error().Err(err).Msg("生成日榜失败")
 			c.Send("❌ 生成日榜失败: " + err.Error())
@@ -1079,6 +1134,10 @@ func handleAdminWeekRanks(c tele.Context) error {
 	go func() {
 		leaderboardSvc := service.NewLeaderboardService()
 		imgPath, err := leaderboardSvc.GenerateWeeklyRank()
+		if errors.Is(err, emby.ErrNoPlaybackData) {
+			c.Send("📊 暂无排行数据")
+			return
+		}
 		if err != nil {
 			logger.Error().Err(err).Msg("生成周榜失败")
 			c.Send("❌ 生成周榜失败: " + err.Error())
@@ -1290,13 +1349,40 @@ func handleChangeTGApprove(c tele.Context, action string, parts []string) error
 		"lv":     "d",
 		"cr":     nil,
 		"ex":     nil,
-		"us":     0,
-		"iv":     0,
 	}); err != nil {
 		logger.Error().Err(err).Int64("oldTG", oldTG).Msg("清空原账户失败")
 		return c.Respond(&tele.CallbackResponse{Text: "处理失败", ShowAlert: true})
 	}
 
+	// 花币随账户转移到新 TG，积分清零
+	pointsSvc := service.NewPointsService()
+	refID := fmt.Sprintf("changetg:%d:%d", oldTG, newTG)
+	if oldUser.Iv > 0 {
+		if _, err := pointsSvc.Transfer(&service.TransferRequest{
+			FromTG:   oldTG,
+			ToTG:     newTG,
+			Currency: models.CurrencyCoin,
+			Amount:   oldUser.Iv,
+			Reason:   models.LedgerReasonChangeTG,
+			Actor:    c.Sender().ID,
+			RefID:    refID,
+		}); err != nil {
+			logger.Error().Err(err).Int64("oldTG", oldTG).Int64("newTG", newTG).Msg("转移花币失败")
+		}
+	}
+	if oldUser.Us > 0 {
+		if _, err := pointsSvc.Debit(&service.PointsChange{
+			TG:       oldTG,
+			Currency: models.CurrencyScore,
+			Amount:   oldUser.Us,
+			Reason:   models.LedgerReasonChangeTG,
+			Actor:    c.Sender().ID,
+			RefID:    refID,
+		}); err != nil {
+			logger.Error().Err(err).Int64("oldTG", oldTG).Msg("清空原账户积分失败")
+		}
+	}
+
 	// 将账户转移到新TG
 	if err := repo.UpdateFields(newTG, map[string]interface{}{
 		"embyid": oldUser.EmbyID,
@@ -1306,7 +1392,12 @@ func handleChangeTGApprove(c tele.Context, action string, parts []string) error
 		"lv":     oldUser.Lv,
 		"cr":     oldUser.Cr,
 		"ex":     oldUser.Ex,
-		"iv":     oldUser.Iv,
+		// 生命周期状态随账户一起转移
+		"status":      oldUser.CurrentStatus(),
+		"grace_at":    oldUser.GraceAt,
+		"disabled_at": oldUser.DisabledAt,
+		"frozen_at":   oldUser.FrozenAt,
+		"removed_at":  oldUser.RemovedAt,
 	}); err != nil {
 		logger.Error().Err(err).Int64("newTG", newTG).Msg("转移账户失败")
 		return c.Respond(&tele.CallbackResponse{Text: "转移失败", ShowAlert: true})
@@ -1331,7 +1422,7 @@ func handleChangeTGApprove(c tele.Context, action string, parts []string) error
 		getPassword(oldUser.Pwd),
 		getSecurityCode(oldUser.Pwd2),
 		formatExpiryTime(oldUser.Ex),
-		cfg.Emby.Line,
+		cfg.Emby.Main().Line,
 	)
 
 	userChat := &tele.Chat{ID: newTG}
@@ -1391,6 +1482,7 @@ func handleUserBan(c tele.Context, tgIDStr string) error {
 	if err := repo.UpdateFields(tgID, map[string]interface{}{"lv": "e"}); err != nil {
 		logger.Error().Err(err).Int64("tg", tgID).Msg("更新用户等级失败")
 	}
+	service.DisableServers(tgID)
 
 	c.Respond(&tele.CallbackResponse{Text: "✅ 用户已禁用", ShowAlert: true})
 	return c.Edit(fmt.Sprintf("✅ 用户 %d 的 Emby 账户已禁用", tgID))
@@ -1425,6 +1517,7 @@ func handleUserUnban(c tele.Context, tgIDStr string) error {
 	if err := repo.UpdateFields(tgID, map[string]interface{}{"lv": "b"}); err != nil {
 		logger.Error().Err(err).Int64("tg", tgID).Msg("更新用户等级失败")
 	}
+	service.SyncServers(tgID)
 
 	c.Respond(&tele.CallbackResponse{Text: "✅ 用户已解除禁用", ShowAlert: true})
 	return c.Edit(fmt.Sprintf("✅ 用户 %d 的 Emby 账户已解除禁用", tgID))
@@ -1456,6 +1549,7 @@ func handleUserDelete(c tele.Context, tgIDStr string) error {
 			return c.Respond(&tele.CallbackResponse{Text: "❌ 删除Emby账户失败: " + err.Error(), ShowAlert: true})
 		}
 	}
+	service.DeleteServerAccounts(tgID)
 
 	// 清空数据库记录（保留 TG 记录，清空 Emby 相关字段）
 	if err := repo.UpdateFields(tgID, map[string]interface{}{
@@ -1501,7 +1595,7 @@ func handleUserGift(c tele.Context, tgIDStr string) error {
 	}
 
 	// 发送给目标用户
-	link := fmt.Sprintf("https://t.me/%s?start=%s", c.Bot().Me.Username, code)
+	link := service.CodeDeepLink(c.Bot().Me.Username, code)
 	text := fmt.Sprintf(
 		"🎁 **您收到了一份注册资格**\n\n"+
 			"来自管理员的赠送，请点击下方链接注册：\n\n"+
@@ -1550,13 +1644,14 @@ func handleUserGiftWhitelist(c tele.Context, tgIDStr string) error {
 	if user.EmbyID != nil && *user.EmbyID != "" {
 		client := emby.GetClient()
 		// 白名单用户可能有特殊线路
-		if cfg.Emby.WhitelistLine != nil && *cfg.Emby.WhitelistLine != "" {
+		if cfg.Emby.Main().WhitelistLine != nil && *cfg.Emby.Main().WhitelistLine != "" {
 			// 可以在这里添加白名单特殊处理
 			logger.Info().Str("embyID", *user.EmbyID).Msg("白名单用户已设置")
 		}
 		// 确保账户处于启用状态
 		client.EnableUser(*user.EmbyID)
 	}
+	service.SyncServers(tgID)
 
 	// 通知用户
 	notifyText := "🎉 **恭喜！**\n\n您已被管理员设置为 **👑 白名单用户**！\n\n您将享有以下特权：\n• 永不过期\n• 专属线路\n• 优先支持"
@@ -1599,6 +1694,7 @@ func handleUserKick(c tele.Context, tgIDStr string) error {
 		client := emby.GetClient()
 		client.DisableUser(*user.EmbyID)
 		repo.UpdateFields(tgID, map[string]interface{}{"lv": "e"})
+		service.DisableServers(tgID)
 	}
 
 	c.Respond(&tele.CallbackResponse{Text: "✅ 用户已踢出并封禁", ShowAlert: true})
@@ -0,0 +1,188 @@
+// Package handlers 签到日历与补签处理器
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/imggen"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
+)
+
+// CheckinCmd /checkin 签到并查看本月签到日历
+func CheckinCmd(c tele.Context) error {
+	cfg := config.Get()
+	if !cfg.Open.Checkin {
+		return c.Send("❌ 签到功能已关闭")
+	}
+
+	var header string
+	result, err := service.NewCheckinService().Checkin(c.Sender().ID)
+	switch {
+	case err == nil:
+		header = fmt.Sprintf("%s\n🎁 获得积分: +%d（连续 %d 天）", result.Message, result.Reward, result.Consecutive)
+		if result.LevelBonus > 0 {
+			header += fmt.Sprintf("\n⭐ 等级加成: +%d", result.LevelBonus)
+		}
+	case errors.Is(err, service.ErrAlreadyCheckedIn):
+		header = "📅 今日已签到，明天再来吧~"
+	case errors.Is(err, service.ErrLevelNotAllowed):
+		return c.Send("❌ 您的等级不允许签到")
+	case errors.Is(err, service.ErrUserNotFound):
+		return c.Send("❌ 请先 /start 初始化账户")
+	default:
+		logger.Error().Err(err).Int64("tg", c.Sender().ID).Msg("签到失败")
+		return c.Send("❌ 签到失败，请稍后重试")
+	}
+
+	return sendCheckinCalendar(c, header, false)
+}
+
+// sendCheckinCalendar 发送（或替换为）本月签到日历图片
+func sendCheckinCalendar(c tele.Context, header string, edit bool) error {
+	cfg := config.Get()
+	now := utils.TimeNowCST()
+
+	cal, err := service.NewCheckinService().Calendar(c.Sender().ID, now.Year(), now.Month())
+	if err != nil {
+		if errors.Is(err, service.ErrUserNotFound) {
+			return c.Send("❌ 请先 /start 初始化账户")
+		}
+		logger.Error().Err(err).Int64("tg", c.Sender().ID).Msg("获取签到日历失败")
+		return c.Send("❌ 获取签到日历失败")
+	}
+
+	imgData, err := imggen.GenerateCheckinCalendar(imggen.CheckinCalendarConfig{
+		Title:  "Check-in Calendar",
+		Year:   cal.Year,
+		Month:  cal.Month,
+		Days:   cal.Days,
+		Today:  cal.Today,
+		Footer: fmt.Sprintf("Streak: %d  |  This month: %d", cal.Streak, len(cal.Days)),
+	})
+	if err != nil {
+		logger.Error().Err(err).Msg("生成签到日历失败")
+		return c.Send("❌ 生成签到日历失败")
+	}
+
+	var sb strings.Builder
+	if header != "" {
+		sb.WriteString(header + "\n\n")
+	}
+	sb.WriteString(fmt.Sprintf("📅 %d年%d月 已签到 %d 天\n", cal.Year, cal.Month, len(cal.Days)))
+	sb.WriteString(fmt.Sprintf("🔥 当前连续: %d 天\n", cal.Streak))
+	if !cal.CheckedIn {
+		sb.WriteString("⏰ 今日尚未签到\n")
+	}
+
+	repairCost := cfg.Checkin.RepairCost
+	if repairCost > 0 {
+		sb.WriteString(fmt.Sprintf("\n🩹 补签卡: %d 张（%d 积分/张，可补最近 %d 天）\n", cal.RepairCards, repairCost, cfg.Checkin.RepairDays))
+		if cal.RepairDay != "" {
+			sb.WriteString(fmt.Sprintf("可补签日期: %s\n", cal.RepairDay))
+		}
+	}
+
+	photo := &tele.Photo{
+		File:    tele.FromReader(bytes.NewReader(imgData)),
+		Caption: sb.String(),
+	}
+	markup := keyboards.CheckinCalendarKeyboard(repairCost > 0, cal.RepairCards > 0 && cal.RepairDay != "")
+	if edit && c.Message() != nil && c.Message().Photo != nil {
+		return c.Edit(photo, markup)
+	}
+	return c.Send(photo, markup)
+}
+
+// handleCheckinRepair 购买或使用补签卡（checkin_repair|buy / checkin_repair|use）
+func handleCheckinRepair(c tele.Context, parts []string) error {
+	if len(parts) < 2 {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+
+	svc := service.NewCheckinService()
+	tgID := c.Sender().ID
+
+	var text string
+	switch parts[1] {
+	case "buy":
+		cards, balance, err := svc.BuyRepairCard(tgID)
+		if err != nil {
+			if !errors.Is(err, service.ErrRepairDisabled) && !errors.Is(err, service.ErrInsufficientBalance) &&
+				!errors.Is(err, service.ErrUserNotFound) {
+				logger.Error().Err(err).Int64("tg", tgID).Msg("购买补签卡失败")
+			}
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		text = fmt.Sprintf("✅ 购买成功，当前补签卡 %d 张，剩余积分 %d", cards, balance)
+	case "use":
+		result, err := svc.RepairStreak(tgID)
+		if err != nil {
+			if !errors.Is(err, service.ErrNoRepairCard) && !errors.Is(err, service.ErrNothingToRepair) &&
+				!errors.Is(err, service.ErrRepairDisabled) && !errors.Is(err, service.ErrCheckinDisabled) {
+				logger.Error().Err(err).Int64("tg", tgID).Msg("补签失败")
+			}
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		text = fmt.Sprintf("✅ 已补签 %s，当前连续 %d 天", result.Day, result.Streak)
+	default:
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+
+	c.Respond(&tele.CallbackResponse{Text: text, ShowAlert: true})
+	return sendCheckinCalendar(c, text, true)
+}
+
+// CheckinStat /checkinstat [天数] 签到参与统计
+func CheckinStat(c tele.Context) error {
+	days := 7
+	if args := c.Args(); len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n <= 0 || n > 90 {
+			return c.Send("用法: /checkinstat [天数]\n天数范围 1-90，默认 7")
+		}
+		days = n
+	}
+
+	stats, err := service.NewCheckinService().Stats(days)
+	if err != nil {
+		logger.Error().Err(err).Msg("获取签到统计失败")
+		return c.Send("❌ 获取签到统计失败: " + err.Error())
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📊 **最近 %d 天签到统计**\n\n", stats.Days))
+	sb.WriteString(fmt.Sprintf("签到用户: %d / %d 个账户（参与率 %.1f%%）\n\n", stats.ActiveUsers, stats.Accounts, stats.Participation))
+
+	if len(stats.Daily) == 0 {
+		sb.WriteString("暂无签到记录\n")
+	} else {
+		sb.WriteString("```\n日期        人数  奖励  补签\n")
+		for _, d := range stats.Daily {
+			sb.WriteString(fmt.Sprintf("%-10s  %4d  %4d  %4d\n", d.Day, d.Users-d.Repaired, d.Rewards, d.Repaired))
+		}
+		sb.WriteString("```\n")
+	}
+
+	if len(stats.TopStreaks) > 0 {
+		sb.WriteString("\n🔥 **连续签到榜**\n")
+		for i, u := range stats.TopStreaks {
+			name := strconv.FormatInt(u.TG, 10)
+			if u.Name != nil && *u.Name != "" {
+				name = *u.Name
+			}
+			sb.WriteString(fmt.Sprintf("%d. `%s` — %d 天\n", i+1, name, u.Ck))
+		}
+	}
+
+	return c.Send(sb.String(), tele.ModeMarkdown)
+}
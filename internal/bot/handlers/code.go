@@ -2,66 +2,413 @@
 package handlers
 
 import (
+	"archive/zip"
+	"bytes"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	tele "gopkg.in/telebot.v3"
 
 	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/imggen"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
+// codeOptionsUsage 生成注册码的附加参数说明
+const codeOptionsUsage = "可选参数（空格分隔）:\n" +
+	"- `label=活动名` 批次标签（空格用 _ 代替）\n" +
+	"- `uses=5` 每个码可使用次数（默认 1）\n" +
+	"- `lv=a` 目标等级 a/b/c/d（a 为白名单，默认 b）\n" +
+	"- `expire=30` 或 `expire=2024-12-31` 注册码失效时间\n" +
+	"- `out=txt`、`out=csv` 或 `out=qr` 以文件或二维码卡片返回"
+
+// codeListLimit 生成结果直接列在消息中的最大数量，超出时发送 CSV 文件
+const codeListLimit = 50
+
+// 二维码卡片限制
+const (
+	maxCodeQRCards = 100 // 单次最多生成的二维码卡片数
+	codeAlbumLimit = 10  // 不超过该数量时以相册发送，否则打包为 zip
+)
+
+// codeExportQR 二维码卡片导出格式
+const codeExportQR = "qr"
+
 // GenerateCode /code 生成注册码命令
-// 用法: /code <天数> [数量]
+// 用法: /code <天数> [数量] [label=标签] [uses=次数] [lv=等级] [expire=天数或日期]
 func GenerateCode(c tele.Context) error {
 	args := c.Args()
 	if len(args) == 0 {
 		return c.Send(
 			"📝 **生成注册码**\n\n"+
-				"用法: `/code <天数> [数量]`\n\n"+
+				"用法: `/code <天数> [数量] [参数...]`\n\n"+
+				codeOptionsUsage+"\n\n"+
 				"示例:\n"+
 				"- `/code 30` - 生成 1 个 30 天注册码\n"+
 				"- `/code 90 5` - 生成 5 个 90 天注册码\n"+
-				"- `/code 365 10` - 生成 10 个年卡注册码",
+				"- `/code 365 10` - 生成 10 个年卡注册码\n"+
+				"- `/code 30 200 label=春节活动 expire=2025-02-15` - 生成带标签和失效日期的批次\n"+
+				"- `/code 30 1 uses=100 lv=a` - 生成可使用 100 次的白名单码",
 			tele.ModeMarkdown,
 		)
 	}
 
-	// 解析天数
+	opts, out, err := parseCodeArgs(args)
+	if err != nil {
+		return c.Send("❌ " + err.Error())
+	}
+	opts.CreatedBy = c.Sender().ID
+
+	// 生成注册码
+	codeSvc := service.NewCodeService()
+	result, err := codeSvc.GenerateBatch(opts)
+	if err != nil {
+		logger.Error().Err(err).Msg("生成注册码失败")
+		return c.Send("❌ 生成注册码失败: " + err.Error())
+	}
+
+	return sendGeneratedCodes(c, result, out)
+}
+
+// parseCodeArgs 解析 `天数 [数量] [参数...]`，返回生成参数与输出格式（out=）
+func parseCodeArgs(args []string) (service.CodeBatchOptions, string, error) {
 	days, err := strconv.Atoi(args[0])
 	if err != nil || days <= 0 {
-		return c.Send("❌ 无效的天数")
+		return service.CodeBatchOptions{}, "", fmt.Errorf("无效的天数")
 	}
+	args = args[1:]
 
-	// 解析数量
 	count := 1
-	if len(args) >= 2 {
-		count, err = strconv.Atoi(args[1])
-		if err != nil || count <= 0 || count > 100 {
-			return c.Send("❌ 数量应在 1-100 之间")
+	if len(args) > 0 && !strings.Contains(args[0], "=") {
+		count, err = strconv.Atoi(args[0])
+		if err != nil || count <= 0 || count > service.MaxCodeBatchSize {
+			return service.CodeBatchOptions{}, "", fmt.Errorf("数量应在 1-%d 之间", service.MaxCodeBatchSize)
 		}
+		args = args[1:]
 	}
 
-	// 生成注册码
-	codeSvc := service.NewCodeService()
-	result, err := codeSvc.GenerateCodes(c.Sender().ID, days, count)
+	out := ""
+	rest := make([]string, 0, len(args))
+	for _, arg := range args {
+		value, ok := strings.CutPrefix(arg, "out=")
+		if !ok {
+			rest = append(rest, arg)
+			continue
+		}
+		switch value = strings.ToLower(value); value {
+		case service.CodeExportTXT, service.CodeExportCSV, codeExportQR:
+			out = value
+		default:
+			return service.CodeBatchOptions{}, "", fmt.Errorf("无效的输出格式 %q（可选 txt/csv/qr）", value)
+		}
+	}
+
+	opts, err := service.ParseCodeOptions(rest, time.Now())
 	if err != nil {
-		logger.Error().Err(err).Msg("生成注册码失败")
-		return c.Send("❌ 生成注册码失败: " + err.Error())
+		return opts, "", err
 	}
+	opts.Days = days
+	opts.Count = count
+	return opts, out, nil
+}
 
-	// 构建回复消息
+// sendGeneratedCodes 发送生成结果：指定输出格式时发送文件或二维码卡片，数量较多时默认发送 CSV 文件
+func sendGeneratedCodes(c tele.Context, result *service.GenerateResult, out string) error {
 	var sb strings.Builder
 	sb.WriteString(fmt.Sprintf("✅ **成功生成 %d 个注册码**\n", result.Count))
-	sb.WriteString(fmt.Sprintf("📅 有效期: %d 天\n\n", result.Days))
+	sb.WriteString(formatBatchRules(result.BatchID, result.Label, result.Days, result.MaxUses, result.Level, result.ExpiresAt))
+	sb.WriteString("\n")
+
+	if out == "" && result.Count > codeListLimit {
+		out = service.CodeExportCSV
+		sb.WriteString("注册码较多，已生成 CSV 文件")
+	}
+	if out != "" {
+		if err := c.Send(sb.String(), keyboards.CodeExportKeyboard(result.BatchID), tele.ModeMarkdown); err != nil {
+			return err
+		}
+		return sendBatchOutput(c, result.BatchID, out)
+	}
 
 	for i, code := range result.Codes {
 		sb.WriteString(fmt.Sprintf("%d. `%s`\n", i+1, code))
 	}
+	return c.Send(sb.String(), keyboards.CodeExportKeyboard(result.BatchID), tele.ModeMarkdown)
+}
+
+// sendBatchOutput 按格式发送批次
+func sendBatchOutput(c tele.Context, batchID uint, format string) error {
+	if format == codeExportQR {
+		return sendBatchQRCards(c, batchID)
+	}
+	return sendBatchExport(c, batchID, format)
+}
+
+// formatBatchRules 格式化批次规则
+func formatBatchRules(batchID uint, label string, days, maxUses int, level string, expiresAt *time.Time) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📦 批次: #%d", batchID))
+	if label != "" {
+		sb.WriteString(" " + label)
+	}
+	sb.WriteString(fmt.Sprintf("\n📅 有效期: %d 天\n", days))
+	if maxUses > 1 {
+		sb.WriteString(fmt.Sprintf("🔁 每码可用: %d 次\n", maxUses))
+	}
+	if level != "" {
+		sb.WriteString(fmt.Sprintf("👑 目标等级: %s\n", keyboards.GetLevelName(level)))
+	}
+	if expiresAt != nil {
+		sb.WriteString(fmt.Sprintf("⌛ 失效时间: %s\n", expiresAt.Format("2006-01-02 15:04")))
+	}
+	return sb.String()
+}
+
+// formatCodeInfo 格式化注册码列表项
+func formatCodeInfo(code repository.CodeInfo) string {
+	status := "🟢 可用"
+	switch {
+	case code.Revoked:
+		status = "⚫ 已作废"
+	case code.ExpiresAt != nil && !time.Now().Before(*code.ExpiresAt):
+		status = "⌛ 已过期"
+	case code.Used:
+		status = "🔴 已用"
+	}
+
+	text := fmt.Sprintf("`%s` %s (%d天", code.Code, status, code.Days)
+	if code.MaxUses > 1 {
+		text += fmt.Sprintf(" · %d/%d 次", code.UseCount, code.MaxUses)
+	}
+	if code.Level != "" {
+		text += " · " + keyboards.GetLevelName(code.Level)
+	}
+	return text + ")"
+}
+
+// batchLabel 批次显示名称
+func batchLabel(batch *models.CodeBatch) string {
+	if batch.Label != "" {
+		return fmt.Sprintf("#%d %s", batch.ID, batch.Label)
+	}
+	return fmt.Sprintf("#%d", batch.ID)
+}
 
-	return c.Send(sb.String(), keyboards.CloseKeyboard(), tele.ModeMarkdown)
+// showCodeBatches 显示注册码批次列表
+func showCodeBatches(c tele.Context, page int) error {
+	pageSize := 10
+	batches, total, err := service.NewCodeService().ListBatches(page, pageSize)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 获取批次列表失败"})
+	}
+	if total == 0 {
+		return editOrReply(c, "📦 暂无注册码批次", keyboards.BackKeyboard("admin_codes"), tele.ModeMarkdown)
+	}
+
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📦 **注册码批次** (第 %d/%d 页)\n\n", page, totalPages))
+	buttons := make([]keyboards.CodeBatchButton, 0, len(batches))
+	for _, b := range batches {
+		sb.WriteString(fmt.Sprintf("**%s** · %d天 × %d · 已用 %d/%d 次",
+			batchLabel(&b.CodeBatch), b.Days, b.Count, b.Uses, b.Count*max(b.MaxUses, 1)))
+		if b.ExpiresAt != nil {
+			sb.WriteString(" · 至 " + b.ExpiresAt.Format("2006-01-02"))
+		}
+		if b.RevokedAt != nil {
+			sb.WriteString(" · 🚫 已作废")
+		}
+		sb.WriteString(fmt.Sprintf("\n   %s 由 `%d` 创建\n", b.CreatedAt.Format("2006-01-02 15:04"), b.TG))
+
+		buttons = append(buttons, keyboards.CodeBatchButton{ID: b.ID, Text: "📦 " + batchLabel(&b.CodeBatch)})
+	}
+	sb.WriteString(fmt.Sprintf("\n共 %d 个批次", total))
+
+	return editOrReply(c, sb.String(), keyboards.CodeBatchesPagination(page, totalPages, buttons), tele.ModeMarkdown)
+}
+
+// showBatchCodes 显示批次详情与批次内注册码
+func showBatchCodes(c tele.Context, batchID uint, page int) error {
+	batch, err := service.NewCodeService().GetBatch(batchID)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+	}
+
+	pageSize := 10
+	codes, total, err := repository.NewCodeRepository().ListByBatch(batchID, page, pageSize)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 获取注册码列表失败"})
+	}
+	totalPages := int((total + int64(pageSize) - 1) / int64(pageSize))
+	if totalPages == 0 {
+		totalPages = 1
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📦 **批次详情** (第 %d/%d 页)\n\n", page, totalPages))
+	sb.WriteString(formatBatchRules(batch.ID, batch.Label, batch.Days, batch.MaxUses, batch.Level, batch.ExpiresAt))
+	if batch.RevokedAt != nil {
+		sb.WriteString(fmt.Sprintf("🚫 已于 %s 作废\n", batch.RevokedAt.Format("2006-01-02 15:04")))
+	}
+	sb.WriteString("\n")
+	for i, code := range codes {
+		idx := (page-1)*pageSize + i + 1
+		sb.WriteString(fmt.Sprintf("%d. %s\n", idx, formatCodeInfo(code)))
+	}
+	sb.WriteString(fmt.Sprintf("\n共 %d 个注册码", total))
+
+	kb := keyboards.CodeBatchPagination(page, totalPages, batchID, batch.RevokedAt != nil)
+	return editOrReply(c, sb.String(), kb, tele.ModeMarkdown)
+}
+
+// sendBatchExport 发送批次的 CSV/TXT 文件
+func sendBatchExport(c tele.Context, batchID uint, format string) error {
+	batch, data, err := service.NewCodeService().ExportBatch(batchID, format)
+	if err != nil {
+		return c.Send("❌ 导出失败: " + err.Error())
+	}
+
+	doc := &tele.Document{
+		File:     tele.FromReader(bytes.NewReader(data)),
+		FileName: fmt.Sprintf("codes_batch_%d.%s", batch.ID, format),
+		Caption:  fmt.Sprintf("🎫 注册码批次 %s（%d 个）", batchLabel(batch), batch.Count),
+	}
+	return c.Send(doc)
+}
+
+// sendBatchQRCards 为批次内仍可用的注册码生成二维码卡片（内容为 Bot 深链接）
+func sendBatchQRCards(c tele.Context, batchID uint) error {
+	cfg := config.Get()
+	if cfg.BotName == "" {
+		return c.Send("❌ 未配置 bot_name，无法生成深链接二维码")
+	}
+
+	batch, codes, err := service.NewCodeService().BatchCodes(batchID)
+	if err != nil {
+		return c.Send("❌ 导出失败: " + err.Error())
+	}
+
+	now := time.Now()
+	usable := make([]models.Code, 0, len(codes))
+	for _, code := range codes {
+		if service.CheckCode(&code, now) == nil {
+			usable = append(usable, code)
+		}
+	}
+	if len(usable) == 0 {
+		return c.Send("📭 该批次没有可用的注册码")
+	}
+	if len(usable) > maxCodeQRCards {
+		return c.Send(fmt.Sprintf("❌ 可用注册码 %d 个，超过二维码卡片上限 %d 个，请导出 TXT/CSV", len(usable), maxCodeQRCards))
+	}
+
+	c.Notify(tele.UploadingPhoto)
+
+	title := cfg.Ranks.Logo
+	if title == "" {
+		title = "Sakura EmbyBoss"
+	}
+	cards := make([][]byte, len(usable))
+	for i, code := range usable {
+		subtitle := fmt.Sprintf("Valid for %d days", code.Us)
+		if code.ExpiresAt != nil {
+			subtitle += " | Redeem before " + code.ExpiresAt.Format("2006-01-02")
+		}
+		cards[i], err = imggen.GenerateCodeCard(imggen.CodeCardConfig{
+			Title:    title,
+			Code:     code.Code,
+			Link:     service.CodeDeepLink(cfg.BotName, code.Code),
+			Subtitle: subtitle,
+		})
+		if err != nil {
+			logger.Error().Err(err).Str("code", code.Code).Msg("生成注册码卡片失败")
+			return c.Send("❌ 生成二维码卡片失败: " + err.Error())
+		}
+	}
+
+	if len(cards) <= codeAlbumLimit {
+		album := make(tele.Album, len(cards))
+		for i, card := range cards {
+			album[i] = &tele.Photo{File: tele.FromReader(bytes.NewReader(card)), Caption: "`" + usable[i].Code + "`"}
+		}
+		_, err := c.Bot().SendAlbum(c.Recipient(), album, tele.ModeMarkdown)
+		return err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for i, card := range cards {
+		w, err := zw.Create(usable[i].Code + ".png")
+		if err != nil {
+			return c.Send("❌ 打包失败: " + err.Error())
+		}
+		if _, err := w.Write(card); err != nil {
+			logger.Error().Err(err).Str("code", usable[i].Code).Msg("写入二维码压缩包失败")
+			return c.Send("❌ 打包失败: " + err.Error())
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return c.Send("❌ 打包失败: " + err.Error())
+	}
+
+	return c.Send(&tele.Document{
+		File:     tele.FromReader(&buf),
+		FileName: fmt.Sprintf("codes_batch_%d_qr.zip", batch.ID),
+		Caption:  fmt.Sprintf("🔳 注册码批次 %s 二维码卡片（%d 张）", batchLabel(batch), len(cards)),
+	})
+}
+
+// handleCodeBatch 批次操作回调（code_batch|export|<ID>|<格式>、code_batch|revoke|<ID>、code_batch|revoke_ok|<ID>）
+func handleCodeBatch(c tele.Context, parts []string) error {
+	cfg := config.Get()
+	if !cfg.IsAdmin(c.Sender().ID) {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 您没有权限", ShowAlert: true})
+	}
+	if len(parts) < 3 {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "无效的批次ID"})
+	}
+	batchID := uint(id)
+
+	switch parts[1] {
+	case "export":
+		format := service.CodeExportCSV
+		if len(parts) > 3 {
+			format = parts[3]
+		}
+		c.Respond(&tele.CallbackResponse{Text: "📤 正在导出..."})
+		return sendBatchOutput(c, batchID, format)
+	case "revoke":
+		batch, err := service.NewCodeService().GetBatch(batchID)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		c.Respond()
+		return editOrReply(c,
+			fmt.Sprintf("⚠️ **确认作废批次 %s？**\n\n批次内 %d 个注册码将全部无法使用，已创建的账户不受影响。", batchLabel(batch), batch.Count),
+			keyboards.ConfirmKeyboard(fmt.Sprintf("code_batch|revoke_ok|%d", batchID), fmt.Sprintf("codes_page|1|batch_%d", batchID)),
+			tele.ModeMarkdown,
+		)
+	case "revoke_ok":
+		count, err := service.NewCodeService().RevokeBatch(batchID, c.Sender().ID)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		}
+		c.Respond(&tele.CallbackResponse{Text: fmt.Sprintf("✅ 已作废 %d 个注册码", count), ShowAlert: true})
+		return showBatchCodes(c, batchID, 1)
+	default:
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
 }
 
 // CodeStats /codestat 注册码统计命令
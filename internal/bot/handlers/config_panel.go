@@ -213,7 +213,7 @@ func handleLineConfig(c tele.Context) error {
 		fmt.Sprintf("💠 **设置普通用户线路**\n\n"+
 			"当前线路: `%s`\n\n"+
 			"请发送新的线路地址\n\n"+
-			"_发送 /cancel 取消操作_", cfg.Emby.Line),
+			"_发送 /cancel 取消操作_", cfg.Emby.Main().Line),
 		keyboards.BackKeyboard("owner_config"),
 		tele.ModeMarkdown,
 	)
@@ -228,8 +228,8 @@ func handleWhitelistLineConfig(c tele.Context) error {
 	
 	cfg := config.Get()
 	currentLine := "未设置"
-	if cfg.Emby.WhitelistLine != nil {
-		currentLine = *cfg.Emby.WhitelistLine
+	if cfg.Emby.Main().WhitelistLine != nil {
+		currentLine = *cfg.Emby.Main().WhitelistLine
 	}
 	
 	return editOrReply(c,
@@ -249,15 +249,15 @@ func handleBlockLibsConfig(c tele.Context) error {
 	cfg := config.Get()
 	
 	var blockedText string
-	if len(cfg.Emby.BlockedLibs) > 0 {
-		blockedText = strings.Join(cfg.Emby.BlockedLibs, ", ")
+	if len(cfg.Emby.Main().BlockedLibs) > 0 {
+		blockedText = strings.Join(cfg.Emby.Main().BlockedLibs, ", ")
 	} else {
 		blockedText = "无"
 	}
 	
 	var extraText string
-	if len(cfg.Emby.ExtraLibs) > 0 {
-		extraText = strings.Join(cfg.Emby.ExtraLibs, ", ")
+	if len(cfg.Emby.Main().ExtraLibs) > 0 {
+		extraText = strings.Join(cfg.Emby.Main().ExtraLibs, ", ")
 	} else {
 		extraText = "无"
 	}
@@ -416,14 +416,14 @@ func handleConfigSet(c tele.Context, key string) error {
 		prompt = fmt.Sprintf("📝 **设置签到权限等级**\n\n当前值: %s\n\n可选值: a, b, c, d\n\n请输入等级:", cfg.Open.CheckinLevel)
 	case "blocked_libs":
 		current := "无"
-		if len(cfg.Emby.BlockedLibs) > 0 {
-			current = strings.Join(cfg.Emby.BlockedLibs, ", ")
+		if len(cfg.Emby.Main().BlockedLibs) > 0 {
+			current = strings.Join(cfg.Emby.Main().BlockedLibs, ", ")
 		}
 		prompt = fmt.Sprintf("📝 **设置普通库隐藏列表**\n\n当前: %s\n\n请输入库名列表，用逗号分隔:", current)
 	case "extra_libs":
 		current := "无"
-		if len(cfg.Emby.ExtraLibs) > 0 {
-			current = strings.Join(cfg.Emby.ExtraLibs, ", ")
+		if len(cfg.Emby.Main().ExtraLibs) > 0 {
+			current = strings.Join(cfg.Emby.Main().ExtraLibs, ", ")
 		}
 		prompt = fmt.Sprintf("📝 **设置额外库列表**\n\n当前: %s\n\n请输入库名列表，用逗号分隔:", current)
 	default:
@@ -516,12 +516,12 @@ func ProcessConfigInput(c tele.Context, action string) error {
 		msg = "探针配置已更新"
 		
 	case "cfg_line":
-		cfg.Emby.Line = input
+		cfg.Emby.Main().Line = input
 		success = true
 		msg = "普通用户线路已更新"
 		
 	case "cfg_whitelist_line":
-		cfg.Emby.WhitelistLine = &input
+		cfg.Emby.Main().WhitelistLine = &input
 		success = true
 		msg = "白名单线路已更新"
 		
@@ -563,13 +563,13 @@ func ProcessConfigInput(c tele.Context, action string) error {
 		
 	case "cfg_blocked_libs":
 		libs := parseLibList(input)
-		cfg.Emby.BlockedLibs = libs
+		cfg.Emby.Main().BlockedLibs = libs
 		success = true
 		msg = fmt.Sprintf("普通库隐藏列表已更新 (%d 个)", len(libs))
 		
 	case "cfg_extra_libs":
 		libs := parseLibList(input)
-		cfg.Emby.ExtraLibs = libs
+		cfg.Emby.Main().ExtraLibs = libs
 		success = true
 		msg = fmt.Sprintf("额外库列表已更新 (%d 个)", len(libs))
 		
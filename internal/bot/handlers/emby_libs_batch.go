@@ -91,7 +91,7 @@ func ExtraEmbyLibsBlockAll(c tele.Context) error {
 		return c.Send("❌ 您没有权限执行此操作")
 	}
 
-	if len(cfg.Emby.ExtraLibs) == 0 {
+	if len(cfg.Emby.Main().ExtraLibs) == 0 {
 		return c.Send("❌ 未配置额外媒体库")
 	}
 
@@ -113,10 +113,11 @@ func ExtraEmbyLibsBlockAll(c tele.Context) error {
 		}
 
 		// 隐藏额外媒体库
-		if err := client.HideFolders(*user.EmbyID, cfg.Emby.ExtraLibs); err != nil {
+		if err := client.HideFolders(*user.EmbyID, cfg.Emby.Main().ExtraLibs); err != nil {
 			logger.Warn().Err(err).Int64("tg", user.TG).Msg("隐藏额外媒体库失败")
 			failed++
 		} else {
+			setServerFolders(user.TG, cfg.Emby.Main().ExtraLibs, false)
 			success++
 		}
 	}
@@ -131,7 +132,7 @@ func ExtraEmbyLibsUnblockAll(c tele.Context) error {
 		return c.Send("❌ 您没有权限执行此操作")
 	}
 
-	if len(cfg.Emby.ExtraLibs) == 0 {
+	if len(cfg.Emby.Main().ExtraLibs) == 0 {
 		return c.Send("❌ 未配置额外媒体库")
 	}
 
@@ -153,10 +154,11 @@ func ExtraEmbyLibsUnblockAll(c tele.Context) error {
 		}
 
 		// 显示额外媒体库
-		if err := client.ShowFolders(*user.EmbyID, cfg.Emby.ExtraLibs); err != nil {
+		if err := client.ShowFolders(*user.EmbyID, cfg.Emby.Main().ExtraLibs); err != nil {
 			logger.Warn().Err(err).Int64("tg", user.TG).Msg("显示额外媒体库失败")
 			failed++
 		} else {
+			setServerFolders(user.TG, cfg.Emby.Main().ExtraLibs, true)
 			success++
 		}
 	}
@@ -10,6 +10,8 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -92,28 +94,27 @@ func OnChatMember(c tele.Context) error {
 	repo.UpdateFields(user.ID, map[string]interface{}{
 		"lv": models.LevelE,
 	})
+	service.DisableServers(user.ID)
 
-	// 通知 Owner
-	if cfg.Owner != 0 {
-		ownerChat := &tele.Chat{ID: cfg.Owner}
-		userName := user.FirstName
-		if user.Username != "" {
-			userName = "@" + user.Username
-		}
+	// 通知管理员
+	userName := user.FirstName
+	if user.Username != "" {
+		userName = "@" + user.Username
+	}
 
-		notifyMsg := fmt.Sprintf(
-			"⚠️ **用户退群通知**\n\n"+
-				"用户: %s (ID: `%d`)\n"+
-				"Emby用户名: `%s`\n"+
-				"操作: 已自动禁用账户",
-			userName,
-			user.ID,
-			getEmbyName(embyUser.Name),
-		)
-
-		if bot := c.Bot(); bot != nil {
-			bot.Send(ownerChat, notifyMsg, tele.ModeMarkdown)
-		}
+	notifyMsg := fmt.Sprintf(
+		"⚠️ **用户退群通知**\n\n"+
+			"用户: %s (ID: `%d`)\n"+
+			"Emby用户名: `%s`\n"+
+			"操作: 已自动禁用账户",
+		userName,
+		user.ID,
+		getEmbyName(embyUser.Name),
+	)
+
+	msg := &notify.Message{Event: notify.EventMemberLeft, Title: "用户退群通知", Text: notifyMsg}
+	if err := notify.Get().NotifyAdmins(msg); err != nil {
+		logger.Debug().Err(err).Int64("tg", user.ID).Msg("发送退群通知失败")
 	}
 
 	return nil
@@ -4,6 +4,7 @@ package handlers
 import (
 	"fmt"
 	"strconv"
+	"strings"
 
 	tele "gopkg.in/telebot.v3"
 
@@ -37,7 +38,7 @@ func handleExtraLibToggle(c tele.Context, tgIDStr string, show bool) error {
 	}
 
 	// 获取额外库列表
-	extraLibs := cfg.Emby.ExtraLibs
+	extraLibs := cfg.Emby.Main().ExtraLibs
 	if len(extraLibs) == 0 {
 		return c.Respond(&tele.CallbackResponse{
 			Text:      "❌ 未配置额外媒体库",
@@ -62,6 +63,7 @@ func handleExtraLibToggle(c tele.Context, tgIDStr string, show bool) error {
 		}
 		c.Respond(&tele.CallbackResponse{Text: "✅ 已为用户关闭额外媒体库"})
 	}
+	setServerFolders(tgID, extraLibs, show)
 
 	// 刷新用户信息面板
 	return showUserInfo(c, user)
@@ -288,7 +290,19 @@ func handleCodesPage(c tele.Context, parts []string) error {
 }
 
 // showCodesList 显示注册码列表
+// filter 为 used/unused 时按状态过滤，batches 显示批次列表，batch_<ID> 显示指定批次的注册码
 func showCodesList(c tele.Context, page int, filter string) error {
+	if filter == "batches" {
+		return showCodeBatches(c, page)
+	}
+	if idStr, ok := strings.CutPrefix(filter, "batch_"); ok {
+		id, err := strconv.ParseUint(idStr, 10, 64)
+		if err != nil {
+			return c.Respond(&tele.CallbackResponse{Text: "参数错误"})
+		}
+		return showBatchCodes(c, uint(id), page)
+	}
+
 	repo := repository.NewCodeRepository()
 	pageSize := 10
 
@@ -306,11 +320,7 @@ func showCodesList(c tele.Context, page int, filter string) error {
 	text := fmt.Sprintf("🎫 **注册码列表** (第 %d/%d 页)\n\n", page, totalPages)
 	for i, code := range codes {
 		idx := (page-1)*pageSize + i + 1
-		status := "🟢 可用"
-		if code.Used {
-			status = "🔴 已用"
-		}
-		text += fmt.Sprintf("%d. `%s` %s (%d天)\n", idx, code.Code, status, code.Days)
+		text += fmt.Sprintf("%d. %s\n", idx, formatCodeInfo(code))
 	}
 
 	text += fmt.Sprintf("\n共 %d 个注册码", total)
@@ -3,8 +3,14 @@ package handlers
 
 import (
 	"bytes"
+	"errors"
+	"strconv"
 	"time"
 
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
 	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/imggen"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
@@ -14,12 +20,14 @@ import (
 // LeaderboardHandler 排行榜处理器
 type LeaderboardHandler struct {
 	service *service.LeaderboardService
+	cfg     *config.Config
 }
 
 // NewLeaderboardHandler 创建排行榜处理器
 func NewLeaderboardHandler() *LeaderboardHandler {
 	return &LeaderboardHandler{
 		service: service.NewLeaderboardService(),
+		cfg:     config.Get(),
 	}
 }
 
@@ -66,13 +74,13 @@ func (h *LeaderboardHandler) sendRankImage(c tele.Context, rankType service.Rank
 		result, err = h.service.GetDayRank(10)
 	}
 
-	if err != nil {
+	if err != nil && !errors.Is(err, emby.ErrNoPlaybackData) {
 		logger.Error().Err(err).Msg("获取排行榜数据失败")
 		return c.Send("❌ 获取排行榜数据失败，请稍后重试")
 	}
 
 	// 检查是否有数据
-	if len(result.Items) == 0 {
+	if err != nil || len(result.Items) == 0 {
 		if msg != nil {
 			c.Bot().Delete(msg)
 		}
@@ -101,7 +109,7 @@ func (h *LeaderboardHandler) sendRankImage(c tele.Context, rankType service.Rank
 	// 发送图片
 	photo := &tele.Photo{
 		File:    tele.FromReader(bytes.NewReader(imgData)),
-		Caption: getCaption(rankType),
+		Caption: getCaption(rankType) + sourceNote(result.Source),
 	}
 
 	return c.Send(photo)
@@ -120,6 +128,10 @@ func (h *LeaderboardHandler) SendRankToChat(bot *tele.Bot, chatID int64, rankTyp
 		result, err = h.service.GetDayRank(10)
 	}
 
+	if errors.Is(err, emby.ErrNoPlaybackData) {
+		logger.Info().Err(err).Msg("排行榜无数据，跳过发送")
+		return nil
+	}
 	if err != nil {
 		logger.Error().Err(err).Msg("定时任务获取排行榜数据失败")
 		return err
@@ -144,13 +156,124 @@ func (h *LeaderboardHandler) SendRankToChat(bot *tele.Bot, chatID int64, rankTyp
 	// 发送图片
 	photo := &tele.Photo{
 		File:    tele.FromReader(bytes.NewReader(imgData)),
-		Caption: getCaption(rankType),
+		Caption: getCaption(rankType) + sourceNote(result.Source),
 	}
 
 	_, err = bot.Send(chat, photo)
 	return err
 }
 
+// HandleMediaRank 处理媒体榜命令 /mediarank [day|week]
+func (h *LeaderboardHandler) HandleMediaRank(c tele.Context) error {
+	rankType := service.RankTypeDay
+	if args := c.Args(); len(args) > 0 {
+		switch args[0] {
+		case "week", "w", "周":
+			rankType = service.RankTypeWeek
+		}
+	}
+
+	msg, _ := c.Bot().Send(c.Chat(), "🎬 正在生成媒体榜，请稍候...")
+	if msg != nil {
+		defer c.Bot().Delete(msg)
+	}
+
+	mediaSvc := service.NewMediaRankService()
+	result, err := mediaSvc.GetMediaRank(rankType, 10)
+	if err != nil {
+		logger.Error().Err(err).Msg("获取媒体榜数据失败")
+		return c.Send("❌ 获取媒体榜数据失败，请稍后重试")
+	}
+	if result.IsEmpty() {
+		return c.Send("🎬 暂无播放数据")
+	}
+
+	imgData, err := mediaSvc.RenderImage(result)
+	if err != nil {
+		logger.Error().Err(err).Msg("生成媒体榜图片失败")
+		return c.Send(result.FormatRankText(), tele.ModeMarkdown)
+	}
+
+	return c.Send(&tele.Photo{
+		File:    tele.FromReader(bytes.NewReader(imgData)),
+		Caption: getMediaCaption(rankType),
+	})
+}
+
+// SendMediaRankToChat 推送媒体榜到指定群组（供定时任务调用）
+// 同一周期内重复推送时编辑上次的消息；进入新周期时发送新消息，并按配置置顶新榜、取消置顶旧榜
+func (h *LeaderboardHandler) SendMediaRankToChat(bot *tele.Bot, chatID int64, rankType service.RankType) error {
+	mediaSvc := service.NewMediaRankService()
+	result, err := mediaSvc.GetMediaRank(rankType, 10)
+	if err != nil {
+		return err
+	}
+	if result.IsEmpty() {
+		logger.Info().Str("type", string(rankType)).Msg("媒体榜无数据，跳过发送")
+		return nil
+	}
+
+	// 图片生成失败时改用文本，编辑、置顶与记录流程相同
+	var content interface{}
+	var opts []interface{}
+	if imgData, err := mediaSvc.RenderImage(result); err != nil {
+		logger.Error().Err(err).Msg("生成媒体榜图片失败，使用文本模式")
+		content = result.FormatRankText()
+		opts = append(opts, tele.ModeMarkdown)
+	} else {
+		content = &tele.Photo{
+			File:    tele.FromReader(bytes.NewReader(imgData)),
+			Caption: getMediaCaption(rankType),
+		}
+	}
+
+	kind := "media_" + string(rankType)
+	period := service.RankPeriod(rankType, time.Now())
+	repo := repository.NewRankMessageRepository()
+	prev, _ := repo.Get(kind)
+
+	// 同一周期：编辑原消息（图片与文本互换时编辑失败，改为发送新消息）
+	if prev != nil && prev.ChatID == chatID && prev.Period == period {
+		stored := tele.StoredMessage{MessageID: strconv.Itoa(prev.MessageID), ChatID: chatID}
+		_, err := bot.Edit(stored, content, opts...)
+		if err == nil {
+			return repo.Save(prev)
+		}
+		logger.Warn().Err(err).Int("message_id", prev.MessageID).Msg("编辑媒体榜失败，改为发送新消息")
+	}
+
+	msg, err := bot.Send(&tele.Chat{ID: chatID}, content, opts...)
+	if err != nil {
+		return err
+	}
+
+	if h.cfg.Ranks.PinMedia {
+		if prev != nil {
+			if err := bot.Unpin(&tele.Chat{ID: prev.ChatID}, prev.MessageID); err != nil {
+				logger.Debug().Err(err).Int("message_id", prev.MessageID).Msg("取消置顶旧媒体榜失败")
+			}
+		}
+		if err := bot.Pin(msg, tele.Silent); err != nil {
+			logger.Warn().Err(err).Msg("置顶媒体榜失败")
+		}
+	}
+
+	return repo.Save(&models.RankMessage{
+		Kind:      kind,
+		ChatID:    chatID,
+		MessageID: msg.ID,
+		Period:    period,
+	})
+}
+
+// getMediaCaption 获取媒体榜图片说明
+func getMediaCaption(rankType service.RankType) string {
+	if rankType == service.RankTypeWeek {
+		return "🎬 本周热门电影与剧集 #MediaRank"
+	}
+	return "🎬 今日热门电影与剧集 #MediaRank"
+}
+
 // convertToImgConfig 转换为图片生成配置
 func convertToImgConfig(result *service.RankResult) imggen.LeaderboardConfig {
 	items := make([]imggen.RankData, len(result.Items))
@@ -177,6 +300,14 @@ func convertToImgConfig(result *service.RankResult) imggen.LeaderboardConfig {
 	}
 }
 
+// sourceNote 图片说明中的数据来源
+func sourceNote(source emby.DataSource) string {
+	if source == "" {
+		return ""
+	}
+	return "\n📡 数据来源: " + source.Label()
+}
+
 // getCaption 获取图片说明
 func getCaption(rankType service.RankType) string {
 	if rankType == service.RankTypeWeek {
@@ -192,6 +323,7 @@ func RegisterLeaderboardHandlers(bot *tele.Bot) {
 	bot.Handle("/rank", h.HandleRank)
 	bot.Handle("/dayrank", h.HandleDayRank)
 	bot.Handle("/weekrank", h.HandleWeekRank)
+	bot.Handle("/mediarank", h.HandleMediaRank)
 	
 	// 中文别名
 	bot.Handle("/日榜", h.HandleDayRank)
@@ -0,0 +1,123 @@
+// Package handlers 积分流水命令处理器
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+)
+
+const ledgerPageSize = 10
+
+// Ledger /ledger 查看用户积分流水
+// 支持: /ledger <用户ID/@用户名> 或回复消息 /ledger
+func Ledger(c tele.Context) error {
+	args := c.Args()
+
+	var target string
+	if c.Message().ReplyTo != nil && c.Message().ReplyTo.Sender != nil {
+		target = strconv.FormatInt(c.Message().ReplyTo.Sender.ID, 10)
+	} else if len(args) > 0 {
+		target = args[0]
+	} else {
+		return c.Send("用法: /ledger <用户ID/@用户名>\n\n或回复某人消息后发送:\n/ledger")
+	}
+
+	repo := repository.NewEmbyRepository()
+	var user *models.Emby
+	var err error
+	if tgID, parseErr := strconv.ParseInt(target, 10, 64); parseErr == nil {
+		user, err = repo.GetByTG(tgID)
+	} else {
+		user, err = repo.GetByName(strings.TrimPrefix(target, "@"))
+	}
+	if err != nil {
+		return c.Send("❌ 未找到该用户")
+	}
+
+	text, kb, err := buildLedgerPage(user, 1)
+	if err != nil {
+		return c.Send("❌ 获取流水失败")
+	}
+	return c.Send(text, kb, tele.ModeMarkdown)
+}
+
+// handleLedgerPage 积分流水分页 ledger_page|{tg}|{page}
+func handleLedgerPage(c tele.Context, parts []string) error {
+	if !config.Get().IsAdmin(c.Sender().ID) {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 仅管理员可用", ShowAlert: true})
+	}
+	if len(parts) < 3 {
+		return c.Respond(&tele.CallbackResponse{Text: "参数错误"})
+	}
+
+	tgID, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "无效的用户ID"})
+	}
+	page, err := strconv.Atoi(parts[2])
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	user, err := repository.NewEmbyRepository().GetByTG(tgID)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 未找到该用户"})
+	}
+
+	text, kb, err := buildLedgerPage(user, page)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 获取流水失败"})
+	}
+	c.Respond()
+	return editOrReply(c, text, kb, tele.ModeMarkdown)
+}
+
+// buildLedgerPage 构建流水页面
+func buildLedgerPage(user *models.Emby, page int) (string, *tele.ReplyMarkup, error) {
+	cfg := config.Get()
+	entries, total, err := service.NewPointsService().History(user.TG, page, ledgerPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📒 **积分流水** | %s (`%d`)\n\n", getEmbyName(user.Name), user.TG))
+	sb.WriteString(fmt.Sprintf("积分: %d | %s: %d\n\n", user.Us, cfg.Money, user.Iv))
+
+	if total == 0 {
+		sb.WriteString("_暂无流水记录_")
+		return sb.String(), keyboards.CloseKeyboard(), nil
+	}
+
+	totalPages := int((total + ledgerPageSize - 1) / ledgerPageSize)
+	for _, e := range entries {
+		unit := "积分"
+		if e.Currency == models.CurrencyCoin {
+			unit = cfg.Money
+		}
+		line := fmt.Sprintf("`%s` %+d %s → %d | %s",
+			e.CreatedAt.Format("01-02 15:04"),
+			e.Amount, unit, e.Balance,
+			service.LedgerReasonName(e.Reason),
+		)
+		if e.Counterparty != nil {
+			line += fmt.Sprintf(" ↔ `%d`", *e.Counterparty)
+		}
+		if e.Actor != 0 && e.Actor != e.TG {
+			line += fmt.Sprintf(" (操作人 `%d`)", e.Actor)
+		}
+		sb.WriteString(line + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("\n第 %d/%d 页，共 %d 条", page, totalPages, total))
+
+	return sb.String(), keyboards.LedgerPagination(user.TG, page, totalPages), nil
+}
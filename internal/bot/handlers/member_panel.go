@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -14,6 +15,7 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -36,6 +38,9 @@ func handleMembersPanel(c tele.Context) error {
 	}
 
 	lvStr := user.GetLevelName()
+	if user.HasEmbyAccount() && user.CurrentStatus() != models.StatusActive {
+		lvStr += " · " + user.GetStatusName()
+	}
 	
 	exStr := "无"
 	if user.Ex != nil {
@@ -206,6 +211,7 @@ func handleConfirmDelMe(c tele.Context, embyID string) error {
 		logger.Error().Err(err).Str("embyID", *user.EmbyID).Msg("删除 Emby 账户失败")
 		return editOrReply(c, "❌ 删除 Emby 账户失败，请联系管理员")
 	}
+	service.DeleteServerAccounts(c.Sender().ID)
 
 	// 清空数据库记录
 	if err := repo.UpdateFields(c.Sender().ID, map[string]interface{}{
@@ -276,8 +282,12 @@ func handleStoreRenew(c tele.Context) error {
 	}
 
 	// 扣除积分
-	newIV := user.Iv - cost
-	
+	entry, err := debitStoreCoins(c.Sender().ID, cost, models.LedgerReasonStoreRenew)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: storeDebitErrorText(err, cost), ShowAlert: true})
+	}
+	newIV := entry.Balance
+
 	// 续期 1 天
 	var newEx time.Time
 	if user.Ex != nil && user.Ex.After(time.Now()) {
@@ -287,11 +297,12 @@ func handleStoreRenew(c tele.Context) error {
 	}
 
 	if err := repo.UpdateFields(c.Sender().ID, map[string]interface{}{
-		"iv": newIV,
 		"ex": newEx,
 	}); err != nil {
+		refundStoreCoins(c.Sender().ID, cost, models.LedgerReasonStoreRenew)
 		return c.Respond(&tele.CallbackResponse{Text: "兑换失败，请重试", ShowAlert: true})
 	}
+	renewed(c.Sender().ID)
 
 	c.Respond(&tele.CallbackResponse{Text: "✅ 兑换成功！续期 1 天"})
 
@@ -332,13 +343,20 @@ func handleStoreWhitelist(c tele.Context) error {
 	}
 
 	// 扣除积分并升级
-	newIV := user.Iv - cost
+	entry, err := debitStoreCoins(c.Sender().ID, cost, models.LedgerReasonStoreWhite)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: storeDebitErrorText(err, cost), ShowAlert: true})
+	}
+	newIV := entry.Balance
+
 	if err := repo.UpdateFields(c.Sender().ID, map[string]interface{}{
-		"iv": newIV,
 		"lv": models.LevelA,
 	}); err != nil {
+		refundStoreCoins(c.Sender().ID, cost, models.LedgerReasonStoreWhite)
 		return c.Respond(&tele.CallbackResponse{Text: "兑换失败，请重试", ShowAlert: true})
 	}
+	applyStreamLimit(c.Sender().ID)
+	service.SyncServers(c.Sender().ID)
 
 	c.Respond(&tele.CallbackResponse{Text: "✅ 成功升级为白名单！"})
 
@@ -381,23 +399,30 @@ func handleStoreReborn(c tele.Context) error {
 		})
 	}
 
+	// 先扣除积分
+	entry, err := debitStoreCoins(c.Sender().ID, cost, models.LedgerReasonStoreReborn)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: storeDebitErrorText(err, cost), ShowAlert: true})
+	}
+	newIV := entry.Balance
+
 	// 解封 Emby 账户
 	client := emby.GetClient()
 	if err := client.EnableUser(*user.EmbyID); err != nil {
 		logger.Error().Err(err).Str("embyID", *user.EmbyID).Msg("解封 Emby 账户失败")
+		refundStoreCoins(c.Sender().ID, cost, models.LedgerReasonStoreReborn)
 		return c.Respond(&tele.CallbackResponse{Text: "解封失败，请联系管理员", ShowAlert: true})
 	}
 
 	// 更新数据库
-	newIV := user.Iv - cost
 	newEx := time.Now().AddDate(0, 0, 7) // 解封后给 7 天有效期
 	if err := repo.UpdateFields(c.Sender().ID, map[string]interface{}{
-		"iv": newIV,
 		"lv": models.LevelB,
 		"ex": newEx,
 	}); err != nil {
 		logger.Error().Err(err).Int64("tg", c.Sender().ID).Msg("更新用户状态失败")
 	}
+	service.SyncServers(c.Sender().ID)
 
 	c.Respond(&tele.CallbackResponse{Text: "✅ 账户已解封！"})
 
@@ -415,6 +440,39 @@ func handleStoreReborn(c tele.Context) error {
 	return editOrReply(c, text, keyboards.BackKeyboard("members"))
 }
 
+// debitStoreCoins 商城扣除花币
+func debitStoreCoins(tgID int64, cost int, reason string) (*models.PointsLedger, error) {
+	return service.NewPointsService().Debit(&service.PointsChange{
+		TG:       tgID,
+		Currency: models.CurrencyCoin,
+		Amount:   cost,
+		Reason:   reason,
+		Actor:    tgID,
+	})
+}
+
+// refundStoreCoins 商城兑换失败时退还花币
+func refundStoreCoins(tgID int64, cost int, reason string) {
+	if _, err := service.NewPointsService().Credit(&service.PointsChange{
+		TG:       tgID,
+		Currency: models.CurrencyCoin,
+		Amount:   cost,
+		Reason:   models.LedgerReasonStoreRefund,
+		Actor:    tgID,
+		RefID:    reason,
+	}); err != nil {
+		logger.Error().Err(err).Int64("tg", tgID).Int("cost", cost).Msg("商城退款失败")
+	}
+}
+
+// storeDebitErrorText 商城扣款失败提示
+func storeDebitErrorText(err error, cost int) string {
+	if errors.Is(err, service.ErrInsufficientBalance) {
+		return fmt.Sprintf("积分不足，需要 %d %s", cost, config.Get().Money)
+	}
+	return "兑换失败，请重试"
+}
+
 // handleEmbyBlock 媒体库管理
 func handleEmbyBlock(c tele.Context) error {
 	repo := repository.NewEmbyRepository()
@@ -487,6 +545,7 @@ func handleToggleLibrary(c tele.Context, libID string, show bool) error {
 		logger.Error().Err(actionErr).Str("libID", libID).Bool("show", show).Msg("切换媒体库失败")
 		return c.Respond(&tele.CallbackResponse{Text: "操作失败，请重试", ShowAlert: true})
 	}
+	setServerFolders(c.Sender().ID, []string{libName}, show)
 
 	action := "显示"
 	if !show {
@@ -514,9 +573,9 @@ func handleServerInfo(c tele.Context) error {
 	}
 
 	// 确定线路
-	line := cfg.Emby.Line
-	if user != nil && user.Lv == models.LevelA && cfg.Emby.WhitelistLine != nil {
-		line = *cfg.Emby.WhitelistLine
+	line := cfg.Emby.Main().Line
+	if user != nil && user.Lv == models.LevelA && cfg.Emby.Main().WhitelistLine != nil {
+		line = *cfg.Emby.Main().WhitelistLine
 	}
 
 	text := fmt.Sprintf(
@@ -529,6 +588,9 @@ func handleServerInfo(c tele.Context) error {
 			"3. 使用您的用户名和密码登录",
 		line, pwd,
 	)
+	if user != nil {
+		text += "\n" + formatServerAccounts(user)
+	}
 
 	return editOrReply(c, text, keyboards.BackKeyboard("members"), tele.ModeMarkdown)
 }
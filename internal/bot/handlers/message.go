@@ -2,6 +2,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,6 +13,7 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
 	"github.com/smysle/sakura-embyboss-go/internal/bot/session"
 	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
 	"github.com/smysle/sakura-embyboss-go/internal/service"
@@ -220,7 +222,7 @@ func handleCreateInfoInput(c tele.Context, input string) error {
 		securityCode,
 		result.Days,
 		result.ExpiryDate.Format("2006-01-02"),
-		cfg.Emby.Line,
+		cfg.Emby.Main().Line,
 	)
 
 	if waitMsg != nil {
@@ -276,7 +278,7 @@ func handleNameInput(c tele.Context, username string) error {
 		securityCode,
 		result.Days,
 		result.ExpiryDate.Format("2006-01-02"),
-		cfg.Emby.Line,
+		cfg.Emby.Main().Line,
 	)
 
 	return c.Send(text, keyboards.BackKeyboard("back_start"), tele.ModeMarkdown)
@@ -415,6 +417,7 @@ func handleDeleteConfirmInput(c tele.Context, input string) error {
 		}
 		return c.Send("❌ 删除 Emby 账户失败，请联系管理员")
 	}
+	service.DeleteServerAccounts(userID)
 
 	// 清空数据库记录
 	if err := repo.UpdateFields(userID, map[string]interface{}{
@@ -560,7 +563,7 @@ func handleBindTGInfoInput(c tele.Context, input string) error {
 		"lv":     "b",
 	}
 
-	if err := repo.UpdateFields(userID, updates); err != nil {
+	if err := repo.BindAccount(userID, updates); err != nil {
 		sessionMgr.ClearSession(userID)
 		return c.Send("❌ 绑定失败，请稍后重试")
 	}
@@ -573,7 +576,7 @@ func handleBindTGInfoInput(c tele.Context, input string) error {
 			"**用户名**: `%s`\n"+
 			"**安全码**: `%s` (仅此一次显示)\n\n"+
 			"🔗 **登录地址**: %s",
-		embyName, securityCode, cfg.Emby.Line,
+		embyName, securityCode, cfg.Emby.Main().Line,
 	)
 
 	logger.Info().Int64("tg", userID).Str("embyName", embyName).Msg("用户绑定Emby账户")
@@ -675,18 +678,22 @@ func handleInviteInfoInput(c tele.Context, input string) error {
 	}
 
 	// 扣除积分
-	newIV := user.Iv - cost
-	if err := repo.UpdateFields(userID, map[string]interface{}{"iv": newIV}); err != nil {
+	entry, err := debitStoreCoins(userID, cost, models.LedgerReasonStoreInvite)
+	if err != nil {
 		sessionMgr.ClearSession(userID)
+		if errors.Is(err, service.ErrInsufficientBalance) {
+			return c.Send(fmt.Sprintf("❌ 积分不足\n\n需要: %d %s", cost, cfg.Money))
+		}
 		return c.Send("❌ 扣除积分失败")
 	}
+	newIV := entry.Balance
 
 	// 生成注册码
 	codeService := service.NewCodeService()
 	result, err := codeService.GenerateCodes(userID, days, count)
 	if err != nil {
-		// 回滚积分
-		repo.UpdateFields(userID, map[string]interface{}{"iv": user.Iv})
+		// 退还积分
+		refundStoreCoins(userID, cost, models.LedgerReasonStoreInvite)
 		sessionMgr.ClearSession(userID)
 		return c.Send(fmt.Sprintf("❌ 生成注册码失败: %s", err.Error()))
 	}
@@ -2,10 +2,10 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strconv"
-	"sync"
 
 	tele "gopkg.in/telebot.v3"
 
@@ -15,23 +15,35 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/moviepilot"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
 const mpItemsPerPage = 10
 
-// 用户搜索数据缓存
-var (
-	userSearchData = make(map[int64]*MPSearchSession)
-	searchDataLock sync.RWMutex
-)
+// mpSearchKey 搜索结果在会话数据中的键
+const mpSearchKey = "mp_search"
 
-// MPSearchSession 搜索会话
+// MPSearchSession 搜索会话（保存在用户会话数据中，重启后可恢复）
 type MPSearchSession struct {
-	Keyword     string
-	Results     []moviepilot.SearchResult
-	CurrentPage int
-	TotalPages  int
+	Keyword     string                    `json:"keyword"`
+	Results     []moviepilot.SearchResult `json:"results"`
+	CurrentPage int                       `json:"current_page"`
+	TotalPages  int                       `json:"total_pages"`
+}
+
+// loadMPSearch 读取用户的搜索会话
+func loadMPSearch(userID int64) (*MPSearchSession, bool) {
+	var sess MPSearchSession
+	if !session.GetManager().GetDataAs(userID, mpSearchKey, &sess) {
+		return nil, false
+	}
+	return &sess, true
+}
+
+// saveMPSearch 保存用户的搜索会话
+func saveMPSearch(userID int64, sess *MPSearchSession) {
+	session.GetManager().SetData(userID, mpSearchKey, sess)
 }
 
 // HandleDownloadCenter 处理点播中心回调
@@ -122,14 +134,14 @@ func HandleMoviePilotSearchInput(c tele.Context) error {
 
 	// 保存搜索结果
 	totalPages := int(math.Ceil(float64(len(results)) / float64(mpItemsPerPage)))
-	searchDataLock.Lock()
-	userSearchData[userID] = &MPSearchSession{
-		Keyword:     keyword,
-		Results:     results,
-		CurrentPage: 1,
-		TotalPages:  totalPages,
-	}
-	searchDataLock.Unlock()
+	session.GetManager().SetStateWithData(userID, session.StateMoviePilotSelectMedia, map[string]interface{}{
+		mpSearchKey: &MPSearchSession{
+			Keyword:     keyword,
+			Results:     results,
+			CurrentPage: 1,
+			TotalPages:  totalPages,
+		},
+	})
 
 	// 发送第一页结果
 	return sendMPSearchResults(c, userID, 1)
@@ -137,9 +149,7 @@ func HandleMoviePilotSearchInput(c tele.Context) error {
 
 // sendMPSearchResults 发送搜索结果
 func sendMPSearchResults(c tele.Context, userID int64, page int) error {
-	searchDataLock.RLock()
-	sess, exists := userSearchData[userID]
-	searchDataLock.RUnlock()
+	sess, exists := loadMPSearch(userID)
 
 	if !exists {
 		return c.Send("❌ 搜索会话已过期，请重新搜索")
@@ -179,9 +189,7 @@ func sendMPSearchResults(c tele.Context, userID int64, page int) error {
 func HandleMPPagePrev(c tele.Context) error {
 	userID := c.Sender().ID
 
-	searchDataLock.RLock()
-	sess, exists := userSearchData[userID]
-	searchDataLock.RUnlock()
+	sess, exists := loadMPSearch(userID)
 
 	if !exists {
 		return c.Respond(&tele.CallbackResponse{
@@ -195,6 +203,7 @@ func HandleMPPagePrev(c tele.Context) error {
 	}
 
 	sess.CurrentPage--
+	saveMPSearch(userID, sess)
 	c.Respond(&tele.CallbackResponse{Text: fmt.Sprintf("📃 加载第 %d 页", sess.CurrentPage)})
 
 	return sendMPSearchResults(c, userID, sess.CurrentPage)
@@ -204,9 +213,7 @@ func HandleMPPagePrev(c tele.Context) error {
 func HandleMPPageNext(c tele.Context) error {
 	userID := c.Sender().ID
 
-	searchDataLock.RLock()
-	sess, exists := userSearchData[userID]
-	searchDataLock.RUnlock()
+	sess, exists := loadMPSearch(userID)
 
 	if !exists {
 		return c.Respond(&tele.CallbackResponse{
@@ -220,6 +227,7 @@ func HandleMPPageNext(c tele.Context) error {
 	}
 
 	sess.CurrentPage++
+	saveMPSearch(userID, sess)
 	c.Respond(&tele.CallbackResponse{Text: fmt.Sprintf("📃 加载第 %d 页", sess.CurrentPage)})
 
 	return sendMPSearchResults(c, userID, sess.CurrentPage)
@@ -236,9 +244,7 @@ func HandleMPSelectDownload(c tele.Context) error {
 		return c.Send("❌ 请输入有效的资源编号")
 	}
 
-	searchDataLock.RLock()
-	sess, exists := userSearchData[userID]
-	searchDataLock.RUnlock()
+	sess, exists := loadMPSearch(userID)
 
 	if !exists {
 		session.GetManager().ClearSession(userID)
@@ -274,23 +280,42 @@ func HandleMPSelectDownload(c tele.Context) error {
 
 	c.Send("⏳ 正在添加下载任务...")
 
+	// 先扣除费用，添加失败再退还
+	pointsSvc := service.NewPointsService()
+	entry, err := pointsSvc.Debit(&service.PointsChange{
+		TG:       userID,
+		Currency: models.CurrencyCoin,
+		Amount:   needCost,
+		Reason:   models.LedgerReasonMoviePilot,
+		Actor:    userID,
+		RefID:    result.Title,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInsufficientBalance) {
+			return c.Send(fmt.Sprintf("❌ %s 不足\n\n此资源需要: %d %s", money, needCost, money))
+		}
+		return c.Send("❌ 扣除费用失败: " + err.Error())
+	}
+
 	// 添加下载任务
 	mpClient := moviepilot.GetClient()
 	downloadID, err := mpClient.AddDownload(result.TorrentInfo)
 	if err != nil {
 		logger.Error().Err(err).Msg("添加下载任务失败")
+		if _, refundErr := pointsSvc.Credit(&service.PointsChange{
+			TG:       userID,
+			Currency: models.CurrencyCoin,
+			Amount:   needCost,
+			Reason:   models.LedgerReasonMPRefund,
+			Actor:    userID,
+			RefID:    result.Title,
+		}); refundErr != nil {
+			logger.Error().Err(refundErr).Int64("user", userID).Msg("求片退款失败")
+		}
 		return c.Send("❌ 添加下载任务失败: " + err.Error())
 	}
 
-	// 扣除费用
-	embyRepo.UpdateFields(userID, map[string]interface{}{
-		"iv": embyUser.Iv - needCost,
-	})
-
 	// 清除搜索会话
-	searchDataLock.Lock()
-	delete(userSearchData, userID)
-	searchDataLock.Unlock()
 	session.GetManager().ClearSession(userID)
 
 	logger.Info().
@@ -309,7 +334,7 @@ func HandleMPSelectDownload(c tele.Context) error {
 		result.Title,
 		downloadID,
 		needCost, money,
-		embyUser.Iv-needCost, money,
+		entry.Balance, money,
 	), tele.ModeMarkdown)
 }
 
@@ -317,9 +342,6 @@ func HandleMPSelectDownload(c tele.Context) error {
 func HandleMPCancelSearch(c tele.Context) error {
 	userID := c.Sender().ID
 
-	searchDataLock.Lock()
-	delete(userSearchData, userID)
-	searchDataLock.Unlock()
 	session.GetManager().ClearSession(userID)
 
 	c.Respond(&tele.CallbackResponse{Text: "已取消"})
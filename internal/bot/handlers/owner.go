@@ -101,17 +101,27 @@ func RevAdmin(c tele.Context) error {
 	return c.Send(fmt.Sprintf("✅ 用户 %d 已移除管理员权限", tgID))
 }
 
-// BackupDB /backup_db 备份数据库
+// BackupDB /backup_db [json|mysqldump] 备份数据库
 func BackupDB(c tele.Context) error {
+	var engine string
+	if args := c.Args(); len(args) > 0 {
+		engine = args[0]
+	}
+
 	c.Send("⏳ 正在备份数据库...")
 
-	backupSvc := service.NewBackupService()
-	result, err := backupSvc.Backup(true) // 压缩备份
+	backupSvc := newBackupService(c)
+	result, err := backupSvc.Run(engine)
 	if err != nil {
-		logger.Error().Err(err).Msg("数据库备份失败")
+		logger.Error().Err(err).Str("engine", engine).Msg("数据库备份失败")
 		return c.Send("❌ 备份失败: " + err.Error())
 	}
 
+	summary := fmt.Sprintf("表: %d | 记录: %d", len(result.Tables), result.Records)
+	if result.Tables == nil {
+		summary = "mysqldump 导出"
+	}
+
 	// 发送备份文件
 	file, err := os.Open(result.FilePath)
 	if err != nil {
@@ -119,12 +129,13 @@ func BackupDB(c tele.Context) error {
 			"✅ 备份完成\n"+
 				"文件: %s\n"+
 				"大小: %s\n"+
-				"记录数: %d\n"+
-				"耗时: %v",
+				"%s\n"+
+				"耗时: %v%s",
 			result.Filename,
 			service.FormatSize(result.Size),
-			result.Records,
+			summary,
 			result.Duration,
+			formatTargetUploads(result.Uploads),
 		))
 	}
 	defer file.Close()
@@ -133,9 +144,10 @@ func BackupDB(c tele.Context) error {
 		File:     tele.FromReader(file),
 		FileName: result.Filename,
 		Caption: fmt.Sprintf(
-			"💾 数据库备份\n大小: %s | 记录: %d",
+			"💾 数据库备份\n大小: %s | %s\n使用 /restore_from_db 恢复%s",
 			service.FormatSize(result.Size),
-			result.Records,
+			summary,
+			formatTargetUploads(result.Uploads),
 		),
 	}
 
@@ -169,6 +181,7 @@ func BanAll(c tele.Context) error {
 
 		// 更新数据库状态
 		repo.UpdateFields(user.TG, map[string]interface{}{"lv": models.LevelE})
+		service.DisableServers(user.TG)
 		successCount++
 	}
 
@@ -202,6 +215,7 @@ func UnbanAll(c tele.Context) error {
 
 		// 更新数据库状态
 		repo.UpdateFields(user.TG, map[string]interface{}{"lv": models.LevelD})
+		service.SyncServers(user.TG)
 		successCount++
 	}
 
@@ -0,0 +1,159 @@
+// Package handlers 邀请返利处理器
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/bot/keyboards"
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+const inviteesPageSize = 10
+
+// handleReferralStart 处理邀请链接 /start ref_<id>
+func handleReferralStart(c tele.Context, inviter int64) error {
+	err := service.NewReferralService().Bind(inviter, c.Sender().ID, models.ReferralSourceLink)
+	switch {
+	case err == nil:
+		cfg := config.Get()
+		c.Send(fmt.Sprintf("🎉 **已接受邀请**\n\n注册账户后邀请人将获得 %s 的奖励，快去创建账户吧～",
+			service.FormatReferralReward(cfg, cfg.Referral.RegisterReward)), tele.ModeMarkdown)
+	case errors.Is(err, service.ErrReferralDisabled):
+	case errors.Is(err, service.ErrReferralSelf), errors.Is(err, service.ErrReferralInviter),
+		errors.Is(err, service.ErrReferralIneligible), errors.Is(err, service.ErrReferralExists):
+		c.Send("ℹ️ " + err.Error())
+	default:
+		logger.Error().Err(err).Int64("inviter", inviter).Int64("tg", c.Sender().ID).Msg("记录邀请关系失败")
+	}
+	return showStartPanel(c)
+}
+
+// handleInvitees 我的邀请页面 invitees|{page}
+func handleInvitees(c tele.Context, parts []string) error {
+	cfg := config.Get()
+	if !cfg.Referral.Enabled {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 邀请功能未开启", ShowAlert: true})
+	}
+
+	page := 1
+	if len(parts) > 1 {
+		if p, err := strconv.Atoi(parts[1]); err == nil && p > 0 {
+			page = p
+		}
+	}
+
+	tgID := c.Sender().ID
+	user, err := repository.NewEmbyRepository().GetByTG(tgID)
+	if err != nil || !user.HasEmbyAccount() {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 注册账户后才能邀请好友", ShowAlert: true})
+	}
+
+	svc := service.NewReferralService()
+	stats, err := svc.Stats(tgID)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 获取邀请信息失败"})
+	}
+	invitees, total, err := svc.Invitees(tgID, page, inviteesPageSize)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 获取邀请信息失败"})
+	}
+	c.Respond()
+
+	var sb strings.Builder
+	sb.WriteString("👥 **我的邀请**\n\n")
+	sb.WriteString(fmt.Sprintf("🔗 专属邀请链接:\n`%s`\n\n", service.ReferralLink(cfg.BotName, tgID)))
+	sb.WriteString(fmt.Sprintf("🎁 好友注册: %s\n", service.FormatReferralReward(cfg, cfg.Referral.RegisterReward)))
+	sb.WriteString(fmt.Sprintf("🎁 好友首次续期: %s\n\n", service.FormatReferralReward(cfg, cfg.Referral.RenewReward)))
+	sb.WriteString(fmt.Sprintf("已邀请 %d 人 · 已注册 %d · 已续期 %d\n", stats.Total, stats.Registered, stats.Renewed))
+
+	if total > 0 {
+		sb.WriteString("\n")
+		for i, inv := range invitees {
+			idx := (page-1)*inviteesPageSize + i + 1
+			name := inv.Name
+			if name == "" {
+				name = service.MaskTG(inv.InviteeTG)
+			}
+			sb.WriteString(fmt.Sprintf("%d. %s `%s` · %s\n", idx, service.ReferralStatusIcon(&inv.Referral), name, inv.CreatedAt.Format("2006-01-02")))
+		}
+		sb.WriteString("\n⏳ 未注册 · ✅ 已注册 · 🔁 已续期")
+	}
+
+	totalPages := int((total + inviteesPageSize - 1) / inviteesPageSize)
+	return editOrReply(c, sb.String(), keyboards.InviteesPagination(page, totalPages), tele.ModeMarkdown)
+}
+
+// Invites /invites [用户] 查看邀请排行或用户的邀请树
+func Invites(c tele.Context) error {
+	args := c.Args()
+	svc := service.NewReferralService()
+
+	if len(args) == 0 {
+		stats, names, err := svc.TopInviters(20)
+		if err != nil {
+			return c.Send("❌ 获取邀请排行失败: " + err.Error())
+		}
+		if len(stats) == 0 {
+			return c.Send("📭 暂无邀请记录")
+		}
+
+		var sb strings.Builder
+		sb.WriteString("🌳 **邀请排行**\n\n")
+		for i, st := range stats {
+			name := names[st.InviterTG]
+			if name == "" {
+				name = "-"
+			}
+			rate := float64(st.Registered) * 100 / float64(st.Total)
+			sb.WriteString(fmt.Sprintf("%d. `%s` (%d) 邀请 %d · 注册 %d (%.0f%%) · 续期 %d\n",
+				i+1, name, st.InviterTG, st.Total, st.Registered, rate, st.Renewed))
+		}
+		sb.WriteString("\n使用 `/invites <用户ID/@用户名>` 查看邀请树")
+		return c.Send(sb.String(), tele.ModeMarkdown)
+	}
+
+	repo := repository.NewEmbyRepository()
+	var user *models.Emby
+	var err error
+	if tgID, parseErr := strconv.ParseInt(args[0], 10, 64); parseErr == nil {
+		user, err = repo.GetByTG(tgID)
+	} else {
+		user, err = repo.GetByName(strings.TrimPrefix(args[0], "@"))
+	}
+	if err != nil {
+		return c.Send("❌ 未找到该用户")
+	}
+
+	tree, err := svc.Tree(user.TG)
+	if err != nil {
+		return c.Send("❌ 获取邀请树失败: " + err.Error())
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🌳 **邀请树**\n\n")
+	if up := svc.Upline(user.TG); up != nil {
+		sb.WriteString(fmt.Sprintf("⬆️ 邀请人: `%d`（%s，%s）\n\n", up.InviterTG, referralSourceName(up.Source), up.CreatedAt.Format("2006-01-02")))
+	}
+	sb.WriteString(service.FormatReferralTree(tree))
+	if tree.Nodes > 0 {
+		sb.WriteString("\n⏳ 未注册 · ✅ 已注册 · 🔁 已续期")
+	}
+	return c.Send(sb.String(), tele.ModeMarkdown)
+}
+
+// referralSourceName 邀请来源显示名称
+func referralSourceName(source string) string {
+	if source == models.ReferralSourceCode {
+		return "邀请码"
+	}
+	return "邀请链接"
+}
@@ -0,0 +1,100 @@
+// Package handlers 多服务器命令处理器
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// Servers /servers 查看已配置的服务器与账户数
+func Servers(c tele.Context) error {
+	cfg := config.Get()
+	counts, err := repository.NewEmbyAccountRepository().CountByServer()
+	if err != nil {
+		logger.Warn().Err(err).Msg("统计附加服务器账户失败")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🖥 **服务器列表**\n\n")
+	sb.WriteString(fmt.Sprintf("• `%s`（主服务器）: 全部等级\n", cfg.Emby.Main().Name))
+
+	servers := emby.GetAdditionalServers()
+	for _, srv := range servers {
+		levels := "全部等级"
+		if len(srv.Config.Levels) > 0 {
+			levels = strings.Join(srv.Config.Levels, ", ")
+		}
+		sb.WriteString(fmt.Sprintf("• `%s`: %s · 账户 %d\n", srv.Config.Name, levels, counts[srv.Config.Name]))
+	}
+	if len(servers) == 0 {
+		sb.WriteString("\n未配置附加服务器（在 emby 列表中添加）")
+	}
+
+	return c.Send(sb.String(), tele.ModeMarkdown)
+}
+
+// SyncServers /syncservers 为所有用户同步附加服务器账户
+func SyncServers(c tele.Context) error {
+	svc := service.NewServerService()
+	if !svc.Enabled() {
+		return c.Send("❌ 未配置附加服务器")
+	}
+
+	c.Send("⏳ 正在同步附加服务器账户...")
+
+	result, err := svc.SyncAll()
+	if err != nil {
+		logger.Error().Err(err).Msg("同步附加服务器账户失败")
+		return c.Send("❌ 操作失败: " + err.Error())
+	}
+
+	return c.Send(result.FormatResult("同步附加服务器"), tele.ModeMarkdown)
+}
+
+// setServerFolders 媒体库开关同步到附加服务器，失败只记录日志
+func setServerFolders(tgID int64, folders []string, show bool) {
+	if err := service.NewServerService().SetFolders(tgID, folders, show); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("同步附加服务器媒体库失败")
+	}
+}
+
+// formatServerAccounts 格式化用户在附加服务器上的线路与账户，未配置时返回空串
+func formatServerAccounts(user *models.Emby) string {
+	svc := service.NewServerService()
+	if !svc.Enabled() {
+		return ""
+	}
+
+	accounts, err := svc.Accounts(user)
+	if err != nil {
+		logger.Warn().Err(err).Int64("tg", user.TG).Msg("获取附加服务器账户失败")
+	}
+
+	var sb strings.Builder
+	for _, account := range accounts {
+		if account.Main {
+			continue
+		}
+		state := "✅"
+		if account.Disabled {
+			state = "🚫"
+		}
+		sb.WriteString(fmt.Sprintf("\n%s **%s**\n", state, account.Server))
+		sb.WriteString(fmt.Sprintf("   线路: `%s`\n", account.Line))
+		sb.WriteString(fmt.Sprintf("   用户名: `%s`\n", account.Name))
+		sb.WriteString(fmt.Sprintf("   密码: `%s`\n", account.Password))
+	}
+	if sb.Len() == 0 {
+		return ""
+	}
+	return "\n**附加服务器**" + sb.String()
+}
@@ -0,0 +1,59 @@
+// Package handlers 账户共享检测处理器
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// ShareCheck /sharecheck 立即执行一次账户共享检测
+func ShareCheck(c tele.Context) error {
+	c.Send("⏳ 正在检测账户共享...")
+
+	result, err := service.NewSharingDetector().Run()
+	if err != nil {
+		logger.Error().Err(err).Msg("账户共享检测失败")
+		return c.Send("❌ 检测失败: " + err.Error())
+	}
+
+	return c.Send(fmt.Sprintf("🕵️ **账户共享检测完成**\n\n"+
+		"检查账户: %d\n超过阈值: %d\n新上报: %d\n窗口内已上报: %d\n失败: %d",
+		result.Checked, result.Flagged, result.Reported, result.Skipped, result.Failed), tele.ModeMarkdown)
+}
+
+// handleSharingDecision 处理疑似共享账户上报的按钮（sharing|<action>|<id>）
+func handleSharingDecision(c tele.Context, parts []string) error {
+	cfg := config.Get()
+	if !cfg.IsAdmin(c.Sender().ID) {
+		return c.Respond(&tele.CallbackResponse{Text: "❌ 您没有权限", ShowAlert: true})
+	}
+	if len(parts) < 3 {
+		return c.Respond(&tele.CallbackResponse{Text: "无效操作"})
+	}
+	id, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "无效的记录ID"})
+	}
+
+	decision, err := service.NewSharingDetector().Decide(uint(id), parts[1], c.Sender().ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, service.ErrSharingDecided), errors.Is(err, service.ErrSharingDecisionNotFound),
+			errors.Is(err, service.ErrInvalidSharingAction):
+			return c.Respond(&tele.CallbackResponse{Text: "❌ " + err.Error(), ShowAlert: true})
+		default:
+			logger.Error().Err(err).Uint64("id", id).Str("action", parts[1]).Msg("处理疑似共享账户失败")
+			return c.Respond(&tele.CallbackResponse{Text: "❌ 操作失败: " + err.Error(), ShowAlert: true})
+		}
+	}
+
+	c.Respond(&tele.CallbackResponse{Text: service.SharingActionLabel(decision.Action)})
+	return c.Edit(service.FormatSharingDecision(decision, cfg.Sharing), tele.ModeMarkdown)
+}
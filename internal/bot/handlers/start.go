@@ -3,6 +3,7 @@ package handlers
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,7 +20,6 @@ import (
 
 // Start /start 命令处理器
 func Start(c tele.Context) error {
-	cfg := config.Get()
 	user := c.Sender()
 
 	// 检查是否在群组
@@ -31,12 +31,25 @@ func Start(c tele.Context) error {
 		)
 	}
 
+	// 用户重新与 Bot 交互，取消广播时记录的屏蔽标记
+	if err := repository.NewEmbyRepository().ClearBlocked(user.ID); err != nil {
+		logger.Warn().Err(err).Int64("tg", user.ID).Msg("清除屏蔽标记失败")
+	}
+
 	// 处理 /start 参数（如注册码）
 	args := c.Args()
 	if len(args) > 0 {
 		return handleStartArgs(c, args[0])
 	}
 
+	return showStartPanel(c)
+}
+
+// showStartPanel 发送用户面板
+func showStartPanel(c tele.Context) error {
+	cfg := config.Get()
+	user := c.Sender()
+
 	// 确保用户存在于数据库
 	repo := repository.NewEmbyRepository()
 	embyUser, err := repo.EnsureExists(user.ID)
@@ -61,11 +74,18 @@ func Start(c tele.Context) error {
 		keyboard = keyboards.StartPanelKeyboardWithAccount(isAdmin)
 	} else {
 		// 获取开放注册信息
+		registerOpen, remaining, err := service.NewRegisterWindowService().Remaining()
+		if err != nil {
+			logger.Error().Err(err).Msg("查询可注册席位失败")
+		}
 		statText := "❌ 关闭"
-		if cfg.Open.Status {
+		if registerOpen {
 			statText = "✅ 开放"
 		}
-		remaining := cfg.Open.MaxUsers - cfg.Open.Temp
+		seatsText := "不限"
+		if remaining >= 0 {
+			seatsText = strconv.Itoa(remaining)
+		}
 
 		text = fmt.Sprintf(
 			"▎__欢迎进入用户面板！%s__\n\n"+
@@ -74,13 +94,13 @@ func Start(c tele.Context) error {
 				"**· 🍒 积分%s** | %d\n"+
 				"**· ®️ 注册状态** | %s\n"+
 				"**· 🎫 总注册限制** | %d\n"+
-				"**· 🎟️ 可注册席位** | %d\n",
+				"**· 🎟️ 可注册席位** | %s\n",
 			user.FirstName, user.ID,
 			embyUser.GetLevelName(),
 			cfg.Money, embyUser.Us,
 			statText,
 			cfg.Open.MaxUsers,
-			remaining,
+			seatsText,
 		)
 		keyboard = keyboards.StartPanelKeyboard(isAdmin)
 	}
@@ -108,8 +128,16 @@ func handleStartArgs(c tele.Context, arg string) error {
 		return handleUserIP(c, name)
 	}
 
-	// 检查是否是注册码
-	if strings.HasPrefix(arg, "SAKURA-") || strings.HasPrefix(arg, cfg.BotName) {
+	// 检查是否是邀请链接
+	if inviter, ok := service.ParseReferralArg(arg); ok {
+		return handleReferralStart(c, inviter)
+	}
+
+	// 检查是否是注册码（前缀含非 ASCII 字符时深链接参数经过编码）
+	if code, ok := service.ParseCodeStartArg(arg); ok {
+		return handleRegisterCode(c, code)
+	}
+	if strings.HasPrefix(arg, "SAKURA-") || strings.HasPrefix(arg, service.CodePrefix()+"-") || strings.HasPrefix(arg, cfg.BotName) {
 		return handleRegisterCode(c, arg)
 	}
 
@@ -242,9 +270,16 @@ func MyInfo(c tele.Context) error {
 
 	markup := &tele.ReplyMarkup{}
 	closeBtn := markup.Data("❌ 删除消息", "closeit")
-	markup.Inline(
-		markup.Row(closeBtn),
-	)
+	if cfg.Referral.Enabled {
+		markup.Inline(
+			markup.Row(markup.Data("👥 我的邀请", "invitees|1")),
+			markup.Row(closeBtn),
+		)
+	} else {
+		markup.Inline(
+			markup.Row(closeBtn),
+		)
+	}
 
 	// 发送消息并60秒后自动删除
 	msg, err := c.Bot().Send(c.Chat(), text, markup, tele.ModeMarkdown)
@@ -0,0 +1,29 @@
+// Package handlers 并发播放限制命令处理器
+package handlers
+
+import (
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// ApplyLimits /applylimits 按等级为所有用户重新应用并发播放限制
+func ApplyLimits(c tele.Context) error {
+	c.Send("⏳ 正在按等级重新应用并发播放限制...")
+
+	result, err := service.NewStreamLimitService().ApplyAll()
+	if err != nil {
+		logger.Error().Err(err).Msg("重新应用并发播放限制失败")
+		return c.Send("❌ 操作失败: " + err.Error())
+	}
+
+	return c.Send(result.FormatResult("应用并发限制"), tele.ModeMarkdown)
+}
+
+// applyStreamLimit 用户等级变更后同步 Emby 并发播放限制
+func applyStreamLimit(tgID int64) {
+	if err := service.NewStreamLimitService().ApplyByTG(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("同步并发播放限制失败")
+	}
+}
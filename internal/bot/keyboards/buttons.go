@@ -7,6 +7,8 @@ import (
 	tele "gopkg.in/telebot.v3"
 
 	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
 )
 
 // JoinGroupKeyboard 加入群组键盘
@@ -141,8 +143,8 @@ func AdminPanelKeyboard(isOwner bool) *tele.ReplyMarkup {
 	return markup
 }
 
-// OpenMenuKeyboard 注册状态面板键盘
-func OpenMenuKeyboard(cfg *config.Config) *tele.ReplyMarkup {
+// OpenMenuKeyboard 注册状态面板键盘，window 为当前定时注册（没有时为 nil）
+func OpenMenuKeyboard(cfg *config.Config, window *models.RegisterWindow) *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
 
 	// 自由注册状态
@@ -153,7 +155,12 @@ func OpenMenuKeyboard(cfg *config.Config) *tele.ReplyMarkup {
 
 	// 定时注册状态
 	timingText := "❎ 定时注册"
-	// TODO: 检查定时注册状态
+	if window != nil {
+		timingText = "⏳ 定时注册"
+		if window.Status == models.RegisterWindowOpen {
+			timingText = "✅ 定时注册"
+		}
+	}
 
 	var rows []tele.Row
 
@@ -263,6 +270,11 @@ func SchedAllKeyboard(cfg *config.Config) *tele.ReplyMarkup {
 		markup.Data(fmt.Sprintf("%s 观影周榜", getStatus(cfg.Scheduler.WeekPlayRank)), "sched_weekplayrank"),
 	))
 
+	rows = append(rows, markup.Row(
+		markup.Data(fmt.Sprintf("%s 媒体日榜", getStatus(cfg.Scheduler.DayMediaRank)), "sched_daymediarank"),
+		markup.Data(fmt.Sprintf("%s 媒体周榜", getStatus(cfg.Scheduler.WeekMediaRank)), "sched_weekmediarank"),
+	))
+
 	rows = append(rows, markup.Row(
 		markup.Data(fmt.Sprintf("%s 到期检测", getStatus(cfg.Scheduler.CheckExpired)), "sched_check_ex"),
 		markup.Data(fmt.Sprintf("%s 活跃检测", getStatus(cfg.Scheduler.LowActivity)), "sched_low_activity"),
@@ -270,6 +282,7 @@ func SchedAllKeyboard(cfg *config.Config) *tele.ReplyMarkup {
 
 	rows = append(rows, markup.Row(
 		markup.Data(fmt.Sprintf("%s 自动备份", getStatus(cfg.Scheduler.BackupDB)), "sched_backup_db"),
+		markup.Data(fmt.Sprintf("%s 到期预警", getStatus(cfg.Scheduler.ExpiryWarning)), "sched_expiry_warning"),
 	))
 
 	rows = append(rows, markup.Row(
@@ -503,7 +516,7 @@ func UserManageKeyboard(userTG int64, hasExtraLibs bool, extraLibsEnabled bool,
 	))
 
 	// 额外媒体库控制（如果配置了额外库）
-	if hasExtraLibs && len(cfg.Emby.ExtraLibs) > 0 && hasEmby {
+	if hasExtraLibs && len(cfg.Emby.Main().ExtraLibs) > 0 && hasEmby {
 		if extraLibsEnabled {
 			rows = append(rows, markup.Row(
 				markup.Data("🎬 关闭额外媒体库", fmt.Sprintf("embyextralib_block|%d", userTG)),
@@ -542,6 +555,22 @@ func UserManageKeyboard(userTG int64, hasExtraLibs bool, extraLibsEnabled bool,
 	return markup
 }
 
+// AdminCodesKeyboard 注册码管理键盘
+func AdminCodesKeyboard() *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("📋 注册码列表", "codes_page|1|"),
+			markup.Data("📦 批次列表", "codes_page|1|batches"),
+		),
+		markup.Row(
+			markup.Data("« 返回", "admin_panel"),
+		),
+	)
+	return markup
+}
+
 // CodeDaysKeyboard 注册码天数选择键盘
 func CodeDaysKeyboard() *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
@@ -608,6 +637,22 @@ func MembersPanelKeyboard(hasAccount bool, isAdmin bool) *tele.ReplyMarkup {
 	return markup
 }
 
+// ExpiryWarningKeyboard 到期预警续期键盘
+func ExpiryWarningKeyboard() *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("📅 积分续期", "store_renew"),
+			markup.Data("🎫 注册码续期", "use_code"),
+		),
+		markup.Row(
+			markup.Data("🏪 积分商城", "store"),
+		),
+	)
+	return markup
+}
+
 // StoreKeyboard 积分商城键盘
 func StoreKeyboard() *tele.ReplyMarkup {
 	markup := &tele.ReplyMarkup{}
@@ -687,8 +732,72 @@ func ChangeTGApproveKeyboard(newTG, oldTG int64) *tele.ReplyMarkup {
 	return markup
 }
 
+// SharingDecisionKeyboard 疑似共享账户处理键盘
+func SharingDecisionKeyboard(decisionID uint) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("⚠️ 警告", fmt.Sprintf("sharing|warn|%d", decisionID)),
+			markup.Data("🔑 重置密码", fmt.Sprintf("sharing|reset|%d", decisionID)),
+		),
+		markup.Row(
+			markup.Data("💢 禁用账户", fmt.Sprintf("sharing|disable|%d", decisionID)),
+			markup.Data("🙈 忽略", fmt.Sprintf("sharing|ignore|%d", decisionID)),
+		),
+	)
+	return markup
+}
+
+// NotifyMarkup 通知消息的操作按钮（注入 notify 的 Telegram 渠道）
+func NotifyMarkup(action *notify.Action) *tele.ReplyMarkup {
+	switch action.Kind {
+	case notify.ActionRenew:
+		return ExpiryWarningKeyboard()
+	case notify.ActionSharingDecision:
+		return SharingDecisionKeyboard(action.ID)
+	}
+	return nil
+}
+
 // BackToMemberKeyboard 返回用户面板键盘
 func BackToMemberKeyboard() *tele.ReplyMarkup {
 	return BackKeyboard("members")
 }
 
+
+// CheckinResultKeyboard 签到结果键盘
+func CheckinResultKeyboard() *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	markup.Inline(
+		markup.Row(
+			markup.Data("📅 签到日历", "checkin_cal"),
+		),
+		markup.Row(
+			markup.Data("« 返回", "back_start"),
+		),
+	)
+	return markup
+}
+
+// CheckinCalendarKeyboard 签到日历键盘（购买/使用补签卡）
+func CheckinCalendarKeyboard(canBuy, canRepair bool) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+
+	var row tele.Row
+	if canBuy {
+		row = append(row, markup.Data("🛒 购买补签卡", "checkin_repair|buy"))
+	}
+	if canRepair {
+		row = append(row, markup.Data("🩹 使用补签卡", "checkin_repair|use"))
+	}
+
+	rows := []tele.Row{}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, markup.Row(markup.Data("❌ 关闭", "close")))
+	markup.Inline(rows...)
+	return markup
+}
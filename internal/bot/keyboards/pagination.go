@@ -246,3 +246,68 @@ func CodesPagination(page, total int, filter string) *tele.ReplyMarkup {
 		tele.Row{tele.Btn{Text: "« 返回", Data: "admin_codes"}},
 	)
 }
+
+// CodeBatchButton 批次列表中的批次按钮
+type CodeBatchButton struct {
+	ID   uint
+	Text string
+}
+
+// CodeBatchesPagination 注册码批次列表分页键盘，每个批次一行
+func CodeBatchesPagination(page, total int, batches []CodeBatchButton) *tele.ReplyMarkup {
+	rows := make([]tele.Row, 0, len(batches)+1)
+	for _, b := range batches {
+		rows = append(rows, tele.Row{tele.Btn{Text: b.Text, Data: fmt.Sprintf("codes_page|1|batch_%d", b.ID)}})
+	}
+	rows = append(rows, tele.Row{tele.Btn{Text: "« 返回", Data: "admin_codes"}})
+
+	p := NewPaginator(total, page, "codes_page|%d|batches")
+	return p.BuildKeyboardWithExtra(rows...)
+}
+
+// CodeBatchPagination 批次内注册码分页键盘（导出、作废）
+func CodeBatchPagination(page, total int, batchID uint, revoked bool) *tele.ReplyMarkup {
+	rows := []tele.Row{codeExportRow(batchID)}
+	if !revoked {
+		rows = append(rows, tele.Row{tele.Btn{Text: "🚫 作废批次", Data: fmt.Sprintf("code_batch|revoke|%d", batchID)}})
+	}
+	rows = append(rows, tele.Row{tele.Btn{Text: "« 批次列表", Data: "codes_page|1|batches"}})
+
+	p := NewPaginator(total, page, fmt.Sprintf("codes_page|%%d|batch_%d", batchID))
+	return p.BuildKeyboardWithExtra(rows...)
+}
+
+// codeExportRow 批次导出按钮行
+func codeExportRow(batchID uint) tele.Row {
+	return tele.Row{
+		tele.Btn{Text: "📄 TXT", Data: fmt.Sprintf("code_batch|export|%d|txt", batchID)},
+		tele.Btn{Text: "📊 CSV", Data: fmt.Sprintf("code_batch|export|%d|csv", batchID)},
+		tele.Btn{Text: "🔳 二维码", Data: fmt.Sprintf("code_batch|export|%d|qr", batchID)},
+	}
+}
+
+// CodeExportKeyboard 注册码生成结果键盘（导出 TXT/CSV/二维码卡片）
+func CodeExportKeyboard(batchID uint) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	markup.Inline(
+		codeExportRow(batchID),
+		markup.Row(markup.Data("❌ 关闭", "close")),
+	)
+	return markup
+}
+
+// InviteesPagination 我的邀请分页键盘
+func InviteesPagination(page, total int) *tele.ReplyMarkup {
+	p := NewPaginator(total, page, "invitees|%d")
+	return p.BuildKeyboardWithExtra(
+		tele.Row{tele.Btn{Text: "❌ 关闭", Data: "closeit"}},
+	)
+}
+
+// LedgerPagination 积分流水分页键盘
+func LedgerPagination(tgID int64, page, total int) *tele.ReplyMarkup {
+	p := NewPaginator(total, page, fmt.Sprintf("ledger_page|%d|%%d", tgID))
+	return p.BuildKeyboardWithExtra(
+		tele.Row{tele.Btn{Text: "❌ 关闭", Data: "close"}},
+	)
+}
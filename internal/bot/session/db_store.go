@@ -0,0 +1,120 @@
+// Package session 数据库会话存储
+package session
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// DBStore 数据库会话存储
+// 读写均经过内存缓存，写入时同步落库，重启后从数据库恢复
+type DBStore struct {
+	repo  *repository.SessionRepository
+	cache *MemoryStore
+}
+
+// NewDBStore 创建数据库会话存储
+func NewDBStore() *DBStore {
+	return &DBStore{
+		repo:  repository.NewSessionRepository(),
+		cache: NewMemoryStore(),
+	}
+}
+
+// Load 读取会话，缓存未命中时从数据库加载
+func (s *DBStore) Load(userID int64) *UserSession {
+	if sess := s.cache.Load(userID); sess != nil {
+		return sess
+	}
+
+	record, err := s.repo.GetByTG(userID)
+	if err != nil {
+		return nil
+	}
+
+	sess, err := decodeSession(record)
+	if err != nil {
+		logger.Warn().Err(err).Int64("user", userID).Msg("解析会话数据失败，已丢弃")
+		s.repo.Delete(userID)
+		return nil
+	}
+
+	s.cache.Save(userID, sess)
+	return sess
+}
+
+// Save 保存会话
+func (s *DBStore) Save(userID int64, sess *UserSession) {
+	s.cache.Save(userID, sess)
+
+	record, err := encodeSession(userID, sess)
+	if err != nil {
+		logger.Warn().Err(err).Int64("user", userID).Msg("序列化会话数据失败，仅保存在内存中")
+		return
+	}
+	if err := s.repo.Save(record); err != nil {
+		logger.Warn().Err(err).Int64("user", userID).Msg("保存会话失败")
+	}
+}
+
+// Delete 删除会话
+func (s *DBStore) Delete(userID int64) {
+	s.cache.Delete(userID)
+	if err := s.repo.Delete(userID); err != nil {
+		logger.Warn().Err(err).Int64("user", userID).Msg("删除会话失败")
+	}
+}
+
+// DeleteExpired 删除过期会话
+func (s *DBStore) DeleteExpired(before time.Time) int {
+	count := s.cache.DeleteExpired(before)
+	deleted, err := s.repo.DeleteBefore(before)
+	if err != nil {
+		logger.Warn().Err(err).Msg("清理过期会话失败")
+		return count
+	}
+	if int(deleted) > count {
+		count = int(deleted)
+	}
+	return count
+}
+
+// encodeSession 将会话转换为数据库记录
+func encodeSession(userID int64, sess *UserSession) (*models.BotSession, error) {
+	data, err := json.Marshal(sess.Data)
+	if err != nil {
+		return nil, err
+	}
+	return &models.BotSession{
+		TG:        userID,
+		State:     string(sess.State),
+		Action:    string(sess.Action),
+		Data:      string(data),
+		MessageID: sess.MessageID,
+		UpdatedAt: sess.UpdatedAt,
+	}, nil
+}
+
+// decodeSession 将数据库记录还原为会话
+func decodeSession(record *models.BotSession) (*UserSession, error) {
+	data := make(map[string]interface{})
+	if record.Data != "" {
+		if err := json.Unmarshal([]byte(record.Data), &data); err != nil {
+			return nil, err
+		}
+	}
+	if data == nil {
+		data = make(map[string]interface{})
+	}
+	return &UserSession{
+		State:     State(record.State),
+		Action:    ActionType(record.Action),
+		Data:      data,
+		UpdatedAt: record.UpdatedAt,
+		MessageID: record.MessageID,
+	}, nil
+}
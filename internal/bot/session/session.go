@@ -2,6 +2,7 @@
 package session
 
 import (
+	"encoding/json"
 	"sync"
 	"time"
 )
@@ -70,45 +71,76 @@ type UserSession struct {
 
 // Manager 会话管理器
 type Manager struct {
-	sessions map[int64]*UserSession
-	mu       sync.RWMutex
-	ttl      time.Duration
+	store Store
+	mu    sync.Mutex
+	ttl   time.Duration
 }
 
+// DefaultTTL 默认会话超时时间
+const DefaultTTL = 5 * time.Minute
+
 var (
 	instance *Manager
 	once     sync.Once
 )
 
-// GetManager 获取会话管理器单例
-func GetManager() *Manager {
+// Init 使用指定存储初始化会话管理器（需在首次使用前调用）
+func Init(store Store, ttl time.Duration) {
 	once.Do(func() {
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
 		instance = &Manager{
-			sessions: make(map[int64]*UserSession),
-			ttl:      5 * time.Minute, // 会话超时时间缩短到5分钟
+			store: store,
+			ttl:   ttl,
 		}
-
-		// 启动清理协程
-		go instance.cleanup()
 	})
+}
+
+// GetManager 获取会话管理器单例（未初始化时使用内存存储）
+func GetManager() *Manager {
+	Init(NewMemoryStore(), DefaultTTL)
 	return instance
 }
 
+// load 读取未过期的会话（调用方需持有锁）
+func (m *Manager) load(userID int64) *UserSession {
+	session := m.store.Load(userID)
+	if session == nil {
+		return nil
+	}
+	if time.Since(session.UpdatedAt) > m.ttl {
+		m.store.Delete(userID)
+		return nil
+	}
+	if session.Data == nil {
+		session.Data = make(map[string]interface{})
+	}
+	return session
+}
+
+// update 读取或创建会话，执行修改后保存（调用方需持有锁）
+func (m *Manager) update(userID int64, fn func(session *UserSession)) {
+	session := m.load(userID)
+	if session == nil {
+		session = &UserSession{
+			State: StateNone,
+			Data:  make(map[string]interface{}),
+		}
+	}
+	fn(session)
+	session.UpdatedAt = time.Now()
+	m.store.Save(userID, session)
+}
+
 // SetState 设置用户状态
 func (m *Manager) SetState(userID int64, state State) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	m.update(userID, func(session *UserSession) {
 		session.State = state
-		session.UpdatedAt = time.Now()
-	} else {
-		m.sessions[userID] = &UserSession{
-			State:     state,
-			Data:      make(map[string]interface{}),
-			UpdatedAt: time.Now(),
-		}
-	}
+	})
 }
 
 // SetStateWithData 设置用户状态和数据
@@ -120,17 +152,10 @@ func (m *Manager) SetStateWithData(userID int64, state State, data map[string]in
 		data = make(map[string]interface{})
 	}
 
-	if session, ok := m.sessions[userID]; ok {
+	m.update(userID, func(session *UserSession) {
 		session.State = state
 		session.Data = data
-		session.UpdatedAt = time.Now()
-	} else {
-		m.sessions[userID] = &UserSession{
-			State:     state,
-			Data:      data,
-			UpdatedAt: time.Now(),
-		}
-	}
+	})
 }
 
 // SetStateWithAction 设置用户状态和操作类型
@@ -138,18 +163,10 @@ func (m *Manager) SetStateWithAction(userID int64, state State, action ActionTyp
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	m.update(userID, func(session *UserSession) {
 		session.State = state
 		session.Action = action
-		session.UpdatedAt = time.Now()
-	} else {
-		m.sessions[userID] = &UserSession{
-			State:     state,
-			Action:    action,
-			Data:      make(map[string]interface{}),
-			UpdatedAt: time.Now(),
-		}
-	}
+	})
 }
 
 // SetStateWithStringAction 设置用户状态和字符串操作类型（用于配置面板等动态 action）
@@ -157,37 +174,23 @@ func (m *Manager) SetStateWithStringAction(userID int64, state State, action str
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	m.update(userID, func(session *UserSession) {
 		session.State = state
 		session.Data["string_action"] = action
-		session.UpdatedAt = time.Now()
-	} else {
-		m.sessions[userID] = &UserSession{
-			State:     state,
-			Data:      map[string]interface{}{"string_action": action},
-			UpdatedAt: time.Now(),
-		}
-	}
+	})
 }
 
 // GetStringAction 获取字符串操作类型
 func (m *Manager) GetStringAction(userID int64) string {
-	val, ok := m.GetData(userID, "string_action")
-	if !ok {
-		return ""
-	}
-	if s, ok := val.(string); ok {
-		return s
-	}
-	return ""
+	return m.GetDataString(userID, "string_action")
 }
 
 // GetState 获取用户状态
 func (m *Manager) GetState(userID int64) State {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	if session := m.load(userID); session != nil {
 		return session.State
 	}
 	return StateNone
@@ -195,10 +198,10 @@ func (m *Manager) GetState(userID int64) State {
 
 // GetAction 获取用户当前操作类型
 func (m *Manager) GetAction(userID int64) ActionType {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	if session := m.load(userID); session != nil {
 		return session.Action
 	}
 	return ActionNone
@@ -206,13 +209,10 @@ func (m *Manager) GetAction(userID int64) ActionType {
 
 // GetSession 获取完整的会话信息
 func (m *Manager) GetSession(userID int64) *UserSession {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
-		return session
-	}
-	return nil
+	return m.load(userID)
 }
 
 // SetData 设置会话数据
@@ -220,24 +220,17 @@ func (m *Manager) SetData(userID int64, key string, value interface{}) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	m.update(userID, func(session *UserSession) {
 		session.Data[key] = value
-		session.UpdatedAt = time.Now()
-	} else {
-		m.sessions[userID] = &UserSession{
-			State:     StateNone,
-			Data:      map[string]interface{}{key: value},
-			UpdatedAt: time.Now(),
-		}
-	}
+	})
 }
 
 // GetData 获取会话数据
 func (m *Manager) GetData(userID int64, key string) (interface{}, bool) {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	if session := m.load(userID); session != nil {
 		val, exists := session.Data[key]
 		return val, exists
 	}
@@ -257,34 +250,55 @@ func (m *Manager) GetDataString(userID int64, key string) string {
 }
 
 // GetDataInt 获取整数类型的会话数据
+// 从数据库恢复的会话中数字会被解码为 float64，这里一并兼容
 func (m *Manager) GetDataInt(userID int64, key string) int {
 	val, ok := m.GetData(userID, key)
 	if !ok {
 		return 0
 	}
-	if i, ok := val.(int); ok {
-		return i
+	switch v := val.(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
 	}
 	return 0
 }
 
+// GetDataAs 将会话数据解码到 out（out 必须为指针）
+// 兼容内存中的原始结构体与从数据库恢复的 map
+func (m *Manager) GetDataAs(userID int64, key string, out interface{}) bool {
+	val, ok := m.GetData(userID, key)
+	if !ok || val == nil {
+		return false
+	}
+	raw, err := json.Marshal(val)
+	if err != nil {
+		return false
+	}
+	return json.Unmarshal(raw, out) == nil
+}
+
 // SetMessageID 设置消息ID
 func (m *Manager) SetMessageID(userID int64, msgID int) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	if session := m.load(userID); session != nil {
 		session.MessageID = msgID
 		session.UpdatedAt = time.Now()
+		m.store.Save(userID, session)
 	}
 }
 
 // GetMessageID 获取消息ID
 func (m *Manager) GetMessageID(userID int64) int {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	if session, ok := m.sessions[userID]; ok {
+	if session := m.load(userID); session != nil {
 		return session.MessageID
 	}
 	return 0
@@ -294,7 +308,7 @@ func (m *Manager) GetMessageID(userID int64) int {
 func (m *Manager) ClearSession(userID int64) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
-	delete(m.sessions, userID)
+	m.store.Delete(userID)
 }
 
 // ClearState 清除用户状态（ClearSession 的别名）
@@ -304,31 +318,21 @@ func (m *Manager) ClearState(userID int64) {
 
 // HasActiveSession 检查用户是否有活跃会话
 func (m *Manager) HasActiveSession(userID int64) bool {
-	m.mu.RLock()
-	defer m.mu.RUnlock()
+	m.mu.Lock()
+	defer m.mu.Unlock()
 
-	session, ok := m.sessions[userID]
-	if !ok {
+	session := m.load(userID)
+	if session == nil {
 		return false
 	}
 	return session.State != StateNone
 }
 
-// cleanup 定期清理过期会话
-func (m *Manager) cleanup() {
-	ticker := time.NewTicker(1 * time.Minute)
-	defer ticker.Stop()
-
-	for range ticker.C {
-		m.mu.Lock()
-		now := time.Now()
-		for userID, session := range m.sessions {
-			if now.Sub(session.UpdatedAt) > m.ttl {
-				delete(m.sessions, userID)
-			}
-		}
-		m.mu.Unlock()
-	}
+// CleanupExpired 清理过期会话（由定时任务调用），返回清理数量
+func (m *Manager) CleanupExpired() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.store.DeleteExpired(time.Now().Add(-m.ttl))
 }
 
 // 包级别便捷函数
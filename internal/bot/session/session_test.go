@@ -0,0 +1,92 @@
+// Package session 会话管理测试
+package session
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestManager(ttl time.Duration) *Manager {
+	return &Manager{store: NewMemoryStore(), ttl: ttl}
+}
+
+func TestManagerExpiry(t *testing.T) {
+	m := newTestManager(time.Minute)
+	m.SetState(1, StateWaitingCode)
+
+	if got := m.GetState(1); got != StateWaitingCode {
+		t.Fatalf("GetState = %q, want %q", got, StateWaitingCode)
+	}
+
+	// 模拟会话超时
+	m.store.Load(1).UpdatedAt = time.Now().Add(-2 * time.Minute)
+	if got := m.GetState(1); got != StateNone {
+		t.Errorf("过期会话 GetState = %q, want 空", got)
+	}
+	if m.store.Load(1) != nil {
+		t.Error("过期会话应被删除")
+	}
+}
+
+func TestManagerCleanupExpired(t *testing.T) {
+	m := newTestManager(time.Minute)
+	m.SetState(1, StateWaitingCode)
+	m.SetState(2, StateWaitingName)
+	m.store.Load(1).UpdatedAt = time.Now().Add(-2 * time.Minute)
+
+	if n := m.CleanupExpired(); n != 1 {
+		t.Errorf("CleanupExpired = %d, want 1", n)
+	}
+	if !m.HasActiveSession(2) {
+		t.Error("未过期会话不应被清理")
+	}
+}
+
+func TestSessionRoundTrip(t *testing.T) {
+	type payload struct {
+		Keyword string `json:"keyword"`
+		Page    int    `json:"page"`
+	}
+
+	orig := &UserSession{
+		State:     StateMoviePilotSelectMedia,
+		Action:    ActionChangeTG,
+		Data:      map[string]interface{}{"code": "SAKURA-1", "days": 30, "search": &payload{"test", 2}},
+		UpdatedAt: time.Now(),
+		MessageID: 42,
+	}
+
+	record, err := encodeSession(7, orig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restored, err := decodeSession(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟从数据库恢复
+	m := newTestManager(time.Minute)
+	m.store.Save(7, restored)
+
+	if got := m.GetState(7); got != orig.State {
+		t.Errorf("State = %q, want %q", got, orig.State)
+	}
+	if got := m.GetAction(7); got != orig.Action {
+		t.Errorf("Action = %q, want %q", got, orig.Action)
+	}
+	if got := m.GetMessageID(7); got != 42 {
+		t.Errorf("MessageID = %d, want 42", got)
+	}
+	if got := m.GetDataString(7, "code"); got != "SAKURA-1" {
+		t.Errorf("code = %q, want SAKURA-1", got)
+	}
+	if got := m.GetDataInt(7, "days"); got != 30 {
+		t.Errorf("days = %d, want 30", got)
+	}
+
+	var p payload
+	if !m.GetDataAs(7, "search", &p) || p.Keyword != "test" || p.Page != 2 {
+		t.Errorf("search = %+v, want {test 2}", p)
+	}
+}
@@ -0,0 +1,68 @@
+// Package session 会话存储
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+// Store 会话存储接口
+type Store interface {
+	// Load 读取会话，不存在时返回 nil
+	Load(userID int64) *UserSession
+	// Save 保存会话
+	Save(userID int64, sess *UserSession)
+	// Delete 删除会话
+	Delete(userID int64)
+	// DeleteExpired 删除指定时间之前未更新的会话，返回删除数量
+	DeleteExpired(before time.Time) int
+}
+
+// MemoryStore 内存会话存储（进程重启后丢失）
+type MemoryStore struct {
+	sessions map[int64]*UserSession
+	mu       sync.RWMutex
+}
+
+// NewMemoryStore 创建内存会话存储
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: make(map[int64]*UserSession),
+	}
+}
+
+// Load 读取会话
+func (s *MemoryStore) Load(userID int64) *UserSession {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.sessions[userID]
+}
+
+// Save 保存会话
+func (s *MemoryStore) Save(userID int64, sess *UserSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[userID] = sess
+}
+
+// Delete 删除会话
+func (s *MemoryStore) Delete(userID int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, userID)
+}
+
+// DeleteExpired 删除过期会话
+func (s *MemoryStore) DeleteExpired(before time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for userID, sess := range s.sessions {
+		if sess.UpdatedAt.Before(before) {
+			delete(s.sessions, userID)
+			count++
+		}
+	}
+	return count
+}
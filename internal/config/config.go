@@ -2,8 +2,10 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"os"
+	"strings"
 	"sync"
 )
 
@@ -19,7 +21,7 @@ type Config struct {
 	Admins    []int64 `json:"admins"`
 	Money     string  `json:"money"`
 
-	Emby       EmbyConfig       `json:"emby"`
+	Emby       EmbyServers      `json:"emby"` // Emby/Jellyfin 服务器列表，其中一台为主服务器
 	Database   DatabaseConfig   `json:"database"`
 	Open       OpenConfig       `json:"open"`
 	Ranks      RanksConfig      `json:"ranks"`
@@ -31,23 +33,133 @@ type Config struct {
 	RedEnvelope RedEnvelopeConfig `json:"red_envelope"`
 	AntiChannel AntiChannelConfig `json:"anti_channel"`
 	Nezha       NezhaConfig       `json:"nezha"`
+	Session     SessionConfig     `json:"session"`
+	StreamLimit StreamLimitConfig `json:"stream_limit"`
+	Lifecycle   LifecycleConfig   `json:"lifecycle"`
+	Notify      NotifyConfig      `json:"notify"`
+
+	PlaybackHistory PlaybackHistoryConfig `json:"playback_history"`
+	Sharing         SharingConfig         `json:"sharing"`
+	Referral        ReferralConfig        `json:"referral"`
+	Checkin         CheckinConfig         `json:"checkin"`
+	Broadcast       BroadcastConfig       `json:"broadcast"`
+
+	LegacyServers []EmbyServerConfig `json:"emby_servers,omitempty"` // 旧版附加服务器列表，加载时并入 emby
 
 	KKGiftDays        int `json:"kk_gift_days"`
 	ActivityCheckDays int `json:"activity_check_days"`
 	FreezeDays        int `json:"freeze_days"`
 }
 
-// EmbyConfig Emby 服务器配置
-type EmbyConfig struct {
-	APIKey                 string   `json:"api_key"`
-	URL                    string   `json:"url"`
-	Line                   string   `json:"line"`
-	WhitelistLine          *string  `json:"whitelist_line"`
-	BlockedLibs            []string `json:"blocked_libs"`
-	ExtraLibs              []string `json:"extra_libs"`
-	BlockedClients         []string `json:"blocked_clients"`
-	TerminateOnFilter      bool     `json:"terminate_session_on_filter"`
-	BlockUserOnFilter      bool     `json:"block_user_on_filter"`
+// EmbyServerConfig Emby/Jellyfin 服务器配置
+// 主服务器账户记录在 emby 表，其余服务器的账户记录在 emby_accounts 表；
+// 用户名与密码与主服务器保持一致，到期、封禁、媒体库开关同步到所有可用服务器
+type EmbyServerConfig struct {
+	Name                string   `json:"name"` // 唯一名称，如 "main"、"4k"，Webhook 按名称区分来源
+	Main                bool     `json:"main"` // 是否为主服务器，有且只有一台
+	URL                 string   `json:"url"`
+	APIKey              string   `json:"api_key"`
+	Line                string   `json:"line"`
+	WhitelistLine       *string  `json:"whitelist_line"`
+	BlockedLibs         []string `json:"blocked_libs"`
+	ExtraLibs           []string `json:"extra_libs,omitempty"` // 额外媒体库（各服务器同名，以主服务器配置为准）
+	Levels              []string `json:"levels,omitempty"` // 可使用该服务器的用户等级（a/b/c/d），为空表示全部，主服务器忽略
+	BlockedClients      []string `json:"blocked_clients,omitempty"`
+	TerminateOnFilter   bool     `json:"terminate_session_on_filter,omitempty"`
+	BlockUserOnFilter   bool     `json:"block_user_on_filter,omitempty"`
+	WebhookNotifyEvents []string `json:"webhook_notify_events,omitempty"` // 转发给管理员的 Webhook 事件类型（"*" 表示全部）
+}
+
+// EmbyServers 服务器列表
+type EmbyServers []EmbyServerConfig
+
+// UnmarshalJSON 兼容旧版配置：emby 为单个对象时视为唯一的主服务器
+func (s *EmbyServers) UnmarshalJSON(data []byte) error {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		var single EmbyServerConfig
+		if err := json.Unmarshal(trimmed, &single); err != nil {
+			return err
+		}
+		single.Main = true
+		*s = EmbyServers{single}
+		return nil
+	}
+	var list []EmbyServerConfig
+	if err := json.Unmarshal(data, &list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// Main 获取主服务器配置（返回列表内元素的指针，修改会写回配置）
+func (s EmbyServers) Main() *EmbyServerConfig {
+	for i := range s {
+		if s[i].Main {
+			return &s[i]
+		}
+	}
+	if len(s) > 0 {
+		return &s[0]
+	}
+	return &EmbyServerConfig{}
+}
+
+// Get 按名称获取服务器配置，不存在时返回 nil
+func (s EmbyServers) Get(name string) *EmbyServerConfig {
+	for i := range s {
+		if s[i].Name == name {
+			return &s[i]
+		}
+	}
+	return nil
+}
+
+// normalize 合并旧版附加服务器列表，补全名称并保证有且只有一台主服务器
+func (s *EmbyServers) normalize(legacy []EmbyServerConfig) {
+	list := append(*s, legacy...)
+	if len(list) == 0 {
+		list = EmbyServers{{Main: true}}
+	}
+
+	mainIdx := 0
+	for i := range list {
+		if list[i].Main {
+			mainIdx = i
+			break
+		}
+	}
+	for i := range list {
+		list[i].Main = i == mainIdx
+	}
+	if list[mainIdx].Name == "" {
+		list[mainIdx].Name = "main"
+	}
+	*s = list
+}
+
+// Allows 指定等级是否可以使用该服务器
+func (s *EmbyServerConfig) Allows(level string) bool {
+	if level == "e" {
+		return false
+	}
+	if len(s.Levels) == 0 {
+		return true
+	}
+	for _, lv := range s.Levels {
+		if lv == level {
+			return true
+		}
+	}
+	return false
+}
+
+// LineFor 获取指定等级用户的线路（白名单优先使用白名单线路）
+func (s *EmbyServerConfig) LineFor(level string) string {
+	if level == "a" && s.WhitelistLine != nil && *s.WhitelistLine != "" {
+		return *s.WhitelistLine
+	}
+	return s.Line
 }
 
 // DatabaseConfig 数据库配置
@@ -60,15 +172,40 @@ type DatabaseConfig struct {
 	IsDocker       bool   `json:"is_docker"`
 	DockerName     string `json:"docker_name"`
 	BackupDir      string `json:"backup_dir"`
-	BackupMaxCount int    `json:"backup_max_count"`
+	BackupMaxCount int    `json:"backup_max_count"` // 每种备份最多保留的文件数
+	BackupEngine   string `json:"backup_engine"`    // 备份方式: json（GORM 导出）或 mysqldump
+
+	BackupTargets []BackupTargetConfig `json:"backup_targets"` // 异地备份目标，每次备份后上传
+}
+
+// BackupTargetConfig 异地备份目标配置
+type BackupTargetConfig struct {
+	Name string `json:"name"` // 唯一名称，如 "minio"
+	Type string `json:"type"` // s3 / webdav / telegram
+	Keep int    `json:"keep"` // 远程最多保留的备份数（按备份方式分别计算），0 表示不清理
+
+	// S3 / MinIO
+	Endpoint  string `json:"endpoint"` // 如 https://s3.amazonaws.com、http://127.0.0.1:9000
+	Region    string `json:"region"`
+	Bucket    string `json:"bucket"`
+	AccessKey string `json:"access_key"`
+	SecretKey string `json:"secret_key"`
+	PathStyle bool   `json:"path_style"` // 使用路径风格访问（MinIO 通常需要开启）
+
+	// WebDAV
+	URL      string `json:"url"` // 备份目录地址
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	Prefix string `json:"prefix"`  // S3 对象键前缀 / WebDAV 子目录
+	ChatID int64  `json:"chat_id"` // Telegram 接收备份的会话，为空时发送给 Owner
 }
 
 // OpenConfig 开放注册配置
 type OpenConfig struct {
 	Status        bool   `json:"status"`
-	MaxUsers      int    `json:"max_users"`
-	Timing        int    `json:"timing"`
-	Temp          int    `json:"temp"`
+	MaxUsers      int    `json:"max_users"` // 总注册限制（有 Emby 账户的用户数），0 表示不限
+	Temp          int    `json:"temp"`      // 自助注册获得的账户天数
 	Checkin       bool   `json:"checkin"`
 	CheckinLevel  string `json:"checkin_level"`
 	Exchange      bool   `json:"exchange"`
@@ -89,18 +226,23 @@ type OpenConfig struct {
 type RanksConfig struct {
 	Logo     string `json:"logo"`
 	Backdrop bool   `json:"backdrop"`
+	PinMedia bool   `json:"pin_media"` // 置顶最新一期媒体榜，并取消置顶上一期
 }
 
 // SchedulerConfig 定时任务配置
 type SchedulerConfig struct {
-	DayRank       bool `json:"day_rank"`
-	WeekRank      bool `json:"week_rank"`
-	DayPlayRank   bool `json:"day_play_rank"`
-	WeekPlayRank  bool `json:"week_play_rank"`
-	CheckExpired  bool `json:"check_expired"`
-	LowActivity   bool `json:"low_activity"`
-	BackupDB      bool `json:"backup_db"`
-	SyncFavorites bool `json:"sync_favorites"` // 同步收藏到数据库
+	DayRank       bool  `json:"day_rank"`
+	WeekRank      bool  `json:"week_rank"`
+	DayPlayRank   bool  `json:"day_play_rank"`
+	WeekPlayRank  bool  `json:"week_play_rank"`
+	CheckExpired  bool  `json:"check_expired"`
+	LowActivity   bool  `json:"low_activity"`
+	BackupDB      bool  `json:"backup_db"`
+	SyncFavorites bool  `json:"sync_favorites"` // 同步收藏到数据库
+	ExpiryWarning bool  `json:"expiry_warning"`  // 到期预警（缺省时开启）
+	WarningDays   []int `json:"warning_days"`    // 提前预警天数，如 [7, 3, 1]
+	DayMediaRank  bool  `json:"day_media_rank"`  // 电影/剧集日榜
+	WeekMediaRank bool  `json:"week_media_rank"` // 电影/剧集周榜
 
 	// 运行时状态（不序列化）
 	DayRanksMsgID  int64 `json:"-"`
@@ -136,10 +278,54 @@ type AutoUpdateConfig struct {
 
 // APIConfig Web API 配置
 type APIConfig struct {
-	Enabled      bool     `json:"enabled"`
-	Host         string   `json:"host"`
-	Port         int      `json:"port"`
-	AllowOrigins []string `json:"allow_origins"`
+	Enabled       bool       `json:"enabled"`
+	Host          string     `json:"host"`
+	Port          int        `json:"port"`
+	AllowOrigins  []string   `json:"allow_origins"`
+	Tokens        []APIToken `json:"tokens"`         // API 访问令牌
+	WebhookSecret string     `json:"webhook_secret"` // Webhook 共享密钥（HMAC 签名或 ?secret= 参数）
+}
+
+// API 令牌权限范围
+const (
+	ScopeAll       = "*"
+	ScopeReadUser  = "read:user"
+	ScopeReadStats = "read:stats"
+	ScopeAdmin     = "admin" // 管理员接口 /api/v1/admin
+)
+
+// APIToken API 访问令牌
+type APIToken struct {
+	Name   string   `json:"name"`
+	Token  string   `json:"token"`
+	Scopes []string `json:"scopes"`
+}
+
+// placeholderPrefix 示例配置中占位密钥的前缀
+const placeholderPrefix = "change_me"
+
+// PlaceholderSecrets 仍使用示例占位值的令牌与密钥名称
+func (c *APIConfig) PlaceholderSecrets() []string {
+	var names []string
+	for _, t := range c.Tokens {
+		if strings.HasPrefix(t.Token, placeholderPrefix) {
+			names = append(names, "tokens."+t.Name)
+		}
+	}
+	if strings.HasPrefix(c.WebhookSecret, placeholderPrefix) {
+		names = append(names, "webhook_secret")
+	}
+	return names
+}
+
+// HasScope 判断令牌是否拥有指定权限
+func (t *APIToken) HasScope(scope string) bool {
+	for _, s := range t.Scopes {
+		if s == ScopeAll || s == scope {
+			return true
+		}
+	}
+	return false
 }
 
 // RedEnvelopeConfig 红包配置
@@ -150,8 +336,10 @@ type RedEnvelopeConfig struct {
 
 // AntiChannelConfig 反皮套人配置
 type AntiChannelConfig struct {
-	Enabled   bool    `json:"enabled"`
-	WhiteList []int64 `json:"white_list"`
+	Enabled       bool    `json:"enabled"`
+	WhiteList     []int64 `json:"white_list"`
+	WarnOnly      []int64 `json:"warn_only"`      // 仅警告（不删除、不封禁）的群组
+	NoticeSeconds int     `json:"notice_seconds"` // 群内提示消息自动删除的秒数
 }
 
 // NezhaConfig 探针配置
@@ -161,6 +349,155 @@ type NezhaConfig struct {
 	MonitorID string `json:"monitor_id"`
 }
 
+// SessionConfig Bot 会话配置
+type SessionConfig struct {
+	Store string `json:"store"` // 存储方式: db（持久化，默认）或 memory
+	TTL   int    `json:"ttl"`   // 会话超时时间（分钟）
+}
+
+// LifecycleConfig 过期账户生命周期配置
+// 到期 → 宽限期(GraceDays) → 禁用(DisableDays) → 封存(FreezeDays) → 删除
+type LifecycleConfig struct {
+	GraceDays   int  `json:"grace_days"`   // 过期后仍可使用的天数（0 表示立即禁用）
+	DisableDays int  `json:"disable_days"` // 禁用后进入封存前的天数
+	KeepFrozen  bool `json:"keep_frozen"`  // 封存后保留账户，不自动删除
+}
+
+// NotifyConfig 通知渠道配置
+type NotifyConfig struct {
+	Webhook NotifyWebhookConfig    `json:"webhook"`
+	SMTP    SMTPConfig             `json:"smtp"`
+	Ntfy    NtfyConfig             `json:"ntfy"`
+	Bark    BarkConfig             `json:"bark"`
+	Routes  map[string]NotifyRoute `json:"routes"` // key 为事件类型，"*" 为默认路由
+}
+
+// NotifyRoute 事件路由：管理员通知与用户通知分别发送到的渠道
+type NotifyRoute struct {
+	Admin []string `json:"admin"` // 如 ["telegram", "ntfy"]
+	User  []string `json:"user"`  // 如 ["telegram", "webhook"]
+}
+
+// NotifyWebhookConfig 通用 JSON Webhook 渠道
+type NotifyWebhookConfig struct {
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+}
+
+// SMTPConfig 邮件渠道
+type SMTPConfig struct {
+	Host     string   `json:"host"`
+	Port     int      `json:"port"` // 465 使用 SSL，其余端口尝试 STARTTLS
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	From     string   `json:"from"`
+	To       []string `json:"to"`
+}
+
+// NtfyConfig ntfy 推送渠道
+type NtfyConfig struct {
+	Server string `json:"server"` // 默认 https://ntfy.sh
+	Topic  string `json:"topic"`
+	Token  string `json:"token"`
+}
+
+// BarkConfig Bark 推送渠道
+type BarkConfig struct {
+	Server string `json:"server"` // 默认 https://api.day.app
+	Key    string `json:"key"`
+}
+
+// RouteFor 获取事件的路由，未配置时使用 "*"，仍未配置则全部走 Telegram
+func (c *NotifyConfig) RouteFor(event string) NotifyRoute {
+	if route, ok := c.Routes[event]; ok {
+		return route
+	}
+	if route, ok := c.Routes["*"]; ok {
+		return route
+	}
+	return NotifyRoute{Admin: []string{"telegram"}, User: []string{"telegram"}}
+}
+
+// DefaultStreamLimit 未配置等级限制时的默认同时播放数
+const DefaultStreamLimit = 2
+
+// StreamLimitConfig 并发播放限制配置
+type StreamLimitConfig struct {
+	Enabled  bool                  `json:"enabled"`  // 是否启用会话巡检（超限自动终止）
+	Interval int                   `json:"interval"` // 巡检间隔（秒）
+	Levels   map[string]LevelLimit `json:"levels"`   // 各等级限制，key 为 a/b/c/d
+}
+
+// LevelLimit 单个等级的限制（0 表示不限）
+type LevelLimit struct {
+	Streams int `json:"streams"` // 最大同时播放数
+	Devices int `json:"devices"` // 最大同时在线设备数
+}
+
+// ForLevel 获取指定等级的限制，未配置时使用默认同时播放数
+func (c *StreamLimitConfig) ForLevel(level string) LevelLimit {
+	if limit, ok := c.Levels[level]; ok {
+		return limit
+	}
+	return LevelLimit{Streams: DefaultStreamLimit}
+}
+
+// PlaybackHistoryConfig 本地播放记录配置
+type PlaybackHistoryConfig struct {
+	Enabled       bool `json:"enabled"`        // 是否记录到 playback_sessions 并用于排行、审计与活跃度检测
+	PollInterval  int  `json:"poll_interval"`  // /Sessions 轮询间隔（秒）
+	RetentionDays int  `json:"retention_days"` // 保留天数（0 表示永久保留）
+}
+
+// SharingConfig 账户共享检测配置
+// 得分 = 超出免检数的网段数 × 网段权重 + 超出免检数的设备数 × 设备权重 + 并发重叠次数 × 重叠权重
+type SharingConfig struct {
+	Enabled       bool    `json:"enabled"`        // 是否启用每日共享检测
+	WindowDays    int     `json:"window_days"`    // 滚动窗口（天）
+	Threshold     float64 `json:"threshold"`      // 得分达到该值时上报管理员
+	FreeSubnets   int     `json:"free_subnets"`   // 不计分的 IP 网段数（IPv4 /24、IPv6 /64）
+	FreeDevices   int     `json:"free_devices"`   // 不计分的设备数
+	SubnetWeight  float64 `json:"subnet_weight"`  // 每个额外网段的分值
+	DeviceWeight  float64 `json:"device_weight"`  // 每个额外设备的分值
+	OverlapWeight float64 `json:"overlap_weight"` // 每次不同设备同时播放的分值
+}
+
+// ReferralConfig 邀请返利配置
+type ReferralConfig struct {
+	Enabled        bool           `json:"enabled"`         // 是否启用邀请链接与返利
+	Currency       string         `json:"currency"`        // 返利币种：us（积分）或 iv（花币）
+	RegisterReward ReferralReward `json:"register_reward"` // 被邀请人注册后发放给邀请人
+	RenewReward    ReferralReward `json:"renew_reward"`    // 被邀请人首次续期后发放给邀请人
+}
+
+// ReferralReward 邀请奖励（积分与天数可同时发放，0 表示不发放）
+type ReferralReward struct {
+	Points int `json:"points"`
+	Days   int `json:"days"`
+}
+
+// CheckinConfig 签到规则配置（开关与等级限制见 open.checkin、open.checkin_level）
+type CheckinConfig struct {
+	Tiers      []CheckinTier  `json:"tiers"`       // 按连续天数的奖励档位，取达到的最高档；为空时按 open.checkin_reward 与默认连签加成
+	LevelBonus map[string]int `json:"level_bonus"` // 按用户等级（a/b/c/d）额外奖励的积分
+	RepairCost int            `json:"repair_cost"` // 补签卡价格（积分），0 表示不出售
+	RepairDays int            `json:"repair_days"` // 可补签最近多少天内的漏签
+}
+
+// BroadcastConfig 广播配置
+type BroadcastConfig struct {
+	Rate            int `json:"rate"`             // 每秒最多发送的消息数（Telegram 限制约 30 条/秒）
+	ProgressSeconds int `json:"progress_seconds"` // 进度消息刷新间隔（秒）
+	MaxRetries      int `json:"max_retries"`      // 触发 429 限流后单个用户的最大重试次数
+}
+
+// CheckinTier 签到奖励档位
+type CheckinTier struct {
+	Streak int `json:"streak"` // 连续签到达到的天数
+	Min    int `json:"min"`    // 最少奖励
+	Max    int `json:"max"`    // 最多奖励
+}
+
 var (
 	cfg     *Config
 	cfgOnce sync.Once
@@ -174,18 +511,29 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
+	config, err := parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfgLock.Lock()
+	cfg = config
+	cfgLock.Unlock()
+
+	return config, nil
+}
+
+// parse 解析配置并设置默认值
+// 默认开启的布尔项在解析前设置，配置中缺省时保持开启，显式设为 false 时关闭
+func parse(data []byte) (*Config, error) {
 	var config Config
+	config.Scheduler.ExpiryWarning = true
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, err
 	}
 
 	// 设置默认值
 	config.setDefaults()
-
-	cfgLock.Lock()
-	cfg = &config
-	cfgLock.Unlock()
-
 	return &config, nil
 }
 
@@ -225,6 +573,9 @@ func (c *Config) setDefaults() {
 	if c.Database.BackupMaxCount == 0 {
 		c.Database.BackupMaxCount = 7
 	}
+	if c.Database.BackupEngine == "" {
+		c.Database.BackupEngine = "json"
+	}
 	if c.API.Port == 0 {
 		c.API.Port = 8838
 	}
@@ -237,9 +588,71 @@ func (c *Config) setDefaults() {
 	if c.Open.InviteLevel == "" {
 		c.Open.InviteLevel = "b"
 	}
+	if c.Open.Temp == 0 {
+		c.Open.Temp = 30
+	}
 	if c.Ranks.Logo == "" {
 		c.Ranks.Logo = "SAKURA"
 	}
+	if c.Session.Store == "" {
+		c.Session.Store = "db"
+	}
+	if c.Session.TTL == 0 {
+		c.Session.TTL = 5
+	}
+	if c.StreamLimit.Interval == 0 {
+		c.StreamLimit.Interval = 60
+	}
+	c.Emby.normalize(c.LegacyServers)
+	c.LegacyServers = nil
+	if c.Lifecycle.DisableDays == 0 {
+		c.Lifecycle.DisableDays = 7
+	}
+	if c.PlaybackHistory.PollInterval == 0 {
+		c.PlaybackHistory.PollInterval = 60
+	}
+	if c.Sharing.WindowDays == 0 {
+		c.Sharing.WindowDays = 7
+	}
+	if c.Sharing.Threshold == 0 {
+		c.Sharing.Threshold = 6
+	}
+	if c.Sharing.FreeSubnets == 0 {
+		c.Sharing.FreeSubnets = 2
+	}
+	if c.Sharing.FreeDevices == 0 {
+		c.Sharing.FreeDevices = 3
+	}
+	if c.Sharing.SubnetWeight == 0 {
+		c.Sharing.SubnetWeight = 2
+	}
+	if c.Sharing.DeviceWeight == 0 {
+		c.Sharing.DeviceWeight = 1
+	}
+	if c.Sharing.OverlapWeight == 0 {
+		c.Sharing.OverlapWeight = 3
+	}
+	if c.AntiChannel.NoticeSeconds == 0 {
+		c.AntiChannel.NoticeSeconds = 30
+	}
+	if c.Checkin.RepairDays == 0 {
+		c.Checkin.RepairDays = 7
+	}
+	if c.Broadcast.Rate == 0 {
+		c.Broadcast.Rate = 20
+	}
+	if c.Broadcast.ProgressSeconds == 0 {
+		c.Broadcast.ProgressSeconds = 3
+	}
+	if c.Broadcast.MaxRetries == 0 {
+		c.Broadcast.MaxRetries = 3
+	}
+	if c.Referral.Currency == "" {
+		c.Referral.Currency = "us"
+	}
+	if len(c.Scheduler.WarningDays) == 0 {
+		c.Scheduler.WarningDays = []int{7, 3, 1}
+	}
 }
 
 // IsAdmin 判断是否是管理员
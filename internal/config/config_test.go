@@ -2,6 +2,7 @@
 package config
 
 import (
+	"encoding/json"
 	"testing"
 )
 
@@ -124,6 +125,14 @@ func TestConfig_SetDefaults(t *testing.T) {
 		t.Errorf("默认 FreezeDays 应该是 5，实际是 %d", cfg.FreezeDays)
 	}
 
+	if cfg.Lifecycle.DisableDays != 7 {
+		t.Errorf("默认 Lifecycle.DisableDays 应该是 7，实际是 %d", cfg.Lifecycle.DisableDays)
+	}
+
+	if len(cfg.Emby) != 1 || !cfg.Emby[0].Main || cfg.Emby.Main().Name != "main" {
+		t.Errorf("默认应有一台名为 'main' 的主服务器，实际是 %+v", cfg.Emby)
+	}
+
 	if cfg.Database.Port != 3306 {
 		t.Errorf("默认数据库端口应该是 3306，实际是 %d", cfg.Database.Port)
 	}
@@ -132,3 +141,146 @@ func TestConfig_SetDefaults(t *testing.T) {
 		t.Errorf("默认 API 端口应该是 8838，实际是 %d", cfg.API.Port)
 	}
 }
+
+func TestEmbyServers_Load(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected []string // 服务器名称，主服务器在前加 *
+	}{
+		{"服务器列表", `{"emby":[{"name":"4k","url":"u2"},{"name":"hd","main":true,"url":"u1"}]}`, []string{"4k", "*hd"}},
+		{"未标记主服务器时取第一台", `{"emby":[{"name":"hd"},{"name":"4k"}]}`, []string{"*hd", "4k"}},
+		{"多台标记为主服务器时取第一台", `{"emby":[{"name":"hd","main":true},{"name":"4k","main":true}]}`, []string{"*hd", "4k"}},
+		{"旧版单服务器", `{"emby":{"url":"u1","line":"l"}}`, []string{"*main"}},
+		{"旧版附加服务器并入列表", `{"emby":{"name":"hd"},"emby_servers":[{"name":"4k"}]}`, []string{"*hd", "4k"}},
+		{"未配置服务器", `{}`, []string{"*main"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var cfg Config
+			if err := json.Unmarshal([]byte(tt.data), &cfg); err != nil {
+				t.Fatalf("解析配置失败: %v", err)
+			}
+			cfg.setDefaults()
+
+			var got []string
+			for _, srv := range cfg.Emby {
+				name := srv.Name
+				if srv.Main {
+					name = "*" + name
+				}
+				got = append(got, name)
+			}
+			if len(got) != len(tt.expected) {
+				t.Fatalf("服务器列表 = %v, want %v", got, tt.expected)
+			}
+			for i := range got {
+				if got[i] != tt.expected[i] {
+					t.Fatalf("服务器列表 = %v, want %v", got, tt.expected)
+				}
+			}
+			if cfg.LegacyServers != nil {
+				t.Errorf("旧版附加服务器列表应在加载后清空")
+			}
+		})
+	}
+}
+
+func TestParse_ExpiryWarningDefault(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"未配置定时任务", `{}`, true},
+		{"缺省到期预警", `{"scheduler":{"check_expired":true}}`, true},
+		{"显式关闭", `{"scheduler":{"expiry_warning":false}}`, false},
+		{"显式开启", `{"scheduler":{"expiry_warning":true}}`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := parse([]byte(tt.data))
+			if err != nil {
+				t.Fatalf("解析配置失败: %v", err)
+			}
+			if cfg.Scheduler.ExpiryWarning != tt.expected {
+				t.Errorf("ExpiryWarning = %v, want %v", cfg.Scheduler.ExpiryWarning, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmbyServers_MainIsWritable(t *testing.T) {
+	servers := EmbyServers{{Name: "4k"}, {Name: "main", Main: true}}
+	servers.Main().Line = "new.example.com"
+	if servers[1].Line != "new.example.com" {
+		t.Errorf("修改 Main() 返回值应写回列表")
+	}
+	if servers.Get("4k") == nil || servers.Get("none") != nil {
+		t.Errorf("Get() 按名称查找服务器失败")
+	}
+}
+
+func TestEmbyServerConfig_Allows(t *testing.T) {
+	tests := []struct {
+		name     string
+		levels   []string
+		level    string
+		expected bool
+	}{
+		{"未限制等级时普通用户可用", nil, "b", true},
+		{"未限制等级时封禁用户不可用", nil, "e", false},
+		{"等级在列表中", []string{"a", "b"}, "a", true},
+		{"等级不在列表中", []string{"a"}, "b", false},
+		{"封禁用户即使在列表中也不可用", []string{"e"}, "e", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &EmbyServerConfig{Name: "4k", Levels: tt.levels}
+			if got := srv.Allows(tt.level); got != tt.expected {
+				t.Errorf("Allows(%q) = %v, want %v", tt.level, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEmbyServerConfig_LineFor(t *testing.T) {
+	whitelist := "https://vip.example.com"
+	srv := &EmbyServerConfig{Line: "https://4k.example.com", WhitelistLine: &whitelist}
+
+	if got := srv.LineFor("a"); got != whitelist {
+		t.Errorf("白名单用户应使用白名单线路，实际是 %s", got)
+	}
+	if got := srv.LineFor("b"); got != srv.Line {
+		t.Errorf("普通用户应使用普通线路，实际是 %s", got)
+	}
+
+	srv.WhitelistLine = nil
+	if got := srv.LineFor("a"); got != srv.Line {
+		t.Errorf("未配置白名单线路时应使用普通线路，实际是 %s", got)
+	}
+}
+
+func TestAPIConfig_PlaceholderSecrets(t *testing.T) {
+	cfg := &APIConfig{
+		Tokens: []APIToken{
+			{Name: "dashboard", Token: "change_me_to_a_long_random_string"},
+			{Name: "admin", Token: "8f2c1d0e9b7a"},
+			{Name: "empty", Token: ""},
+		},
+		WebhookSecret: "change_me_webhook_secret",
+	}
+	got := cfg.PlaceholderSecrets()
+	if len(got) != 2 || got[0] != "tokens.dashboard" || got[1] != "webhook_secret" {
+		t.Errorf("PlaceholderSecrets() = %v", got)
+	}
+
+	cfg.Tokens[0].Token = "a3b9c7"
+	cfg.WebhookSecret = "hook-secret"
+	if got := cfg.PlaceholderSecrets(); len(got) != 0 {
+		t.Errorf("修改后不应再有占位密钥，实际是 %v", got)
+	}
+}
@@ -56,27 +56,65 @@ func Init(cfg *config.DatabaseConfig) error {
 	return nil
 }
 
-// autoMigrate 自动迁移表结构
-func autoMigrate(db *gorm.DB) error {
-	// 核心表 - 必须迁移
-	coreTables := []interface{}{
+// SchemaVersion 数据库表结构版本，新增表或调整字段时递增，备份恢复时据此校验兼容性
+const SchemaVersion = 5
+
+// CoreModels 必须迁移的数据表模型
+func CoreModels() []interface{} {
+	return []interface{}{
 		&models.Emby{},
 		&models.Code{},
+		&models.CodeBatch{},
+		&models.CodeRedemption{},
 		&models.RedEnvelope{},
 		&models.RedEnvelopeRecord{},
+		&models.PointsLedger{},
+		&models.PlaybackEvent{},
+		&models.BotSession{},
+		&models.ExpiryWarning{},
+		&models.EmbyAccount{},
+		&models.RankMessage{},
+		&models.PlaybackSession{},
+		&models.SharingDecision{},
+		&models.Referral{},
+		&models.CheckinLog{},
+		&models.BroadcastJob{},
+		&models.BroadcastRecipient{},
+		&models.RegisterWindow{},
 	}
+}
+
+// OptionalModels 可选数据表模型（表已存在时跳过迁移）
+func OptionalModels() []interface{} {
+	return []interface{}{
+		&models.Favorites{},
+		&models.RequestRecord{},
+	}
+}
+
+// AllModels 所有数据表模型
+func AllModels() []interface{} {
+	return append(CoreModels(), OptionalModels()...)
+}
+
+// autoMigrate 自动迁移表结构
+func autoMigrate(db *gorm.DB) error {
+	// 旧版本没有生命周期字段，迁移后需要转换旧的到期封禁
+	legacyExpiry := db.Migrator().HasTable(&models.Emby{}) && !db.Migrator().HasColumn(&models.Emby{}, "status")
 
-	if err := db.AutoMigrate(coreTables...); err != nil {
+	// 核心表 - 必须迁移
+	if err := db.AutoMigrate(CoreModels()...); err != nil {
 		return err
 	}
 
-	// 可选表 - 如果已存在则跳过，不存在则创建
-	optionalTables := []interface{}{
-		&models.Favorites{},
-		&models.RequestRecord{},
+	if legacyExpiry {
+		if err := migrateLegacyExpiry(db); err != nil {
+			return fmt.Errorf("转换旧版到期封禁失败: %w", err)
+		}
 	}
 
-	for _, table := range optionalTables {
+	// 可选表 - 如果已存在则跳过，不存在则创建
+	for _, table := range OptionalModels() {
 		tableName := ""
 		switch table.(type) {
 		case *models.Favorites:
@@ -98,6 +136,28 @@ func autoMigrate(db *gorm.DB) error {
 	return nil
 }
 
+// migrateLegacyExpiry 旧版到期检测把过期用户的等级改为 e（封禁），与管理员封禁无法区分，
+// 导致续期后无法恢复。这里把已过期的 e 级用户转为 b 级并标记为已禁用，
+// 之后由生命周期继续推进（封存、删除），禁用期从迁移时起算，续期时自动恢复
+func migrateLegacyExpiry(db *gorm.DB) error {
+	now := time.Now()
+	result := db.Model(&models.Emby{}).
+		Where("lv = ? AND ex IS NOT NULL AND ex <= ? AND embyid IS NOT NULL AND embyid != ''", models.LevelE, now).
+		Updates(map[string]interface{}{
+			"lv":          models.LevelB,
+			"status":      models.StatusDisabled,
+			"grace_at":    now,
+			"disabled_at": now,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		logger.Info().Int64("users", result.RowsAffected).Msg("已将旧版到期封禁转换为生命周期禁用状态")
+	}
+	return nil
+}
+
 // Close 关闭数据库连接
 func Close() error {
 	if DB != nil {
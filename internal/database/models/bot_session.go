@@ -0,0 +1,19 @@
+// Package models 数据模型 - Bot 会话
+package models
+
+import "time"
+
+// BotSession Bot 对话会话（持久化，重启后可恢复）
+type BotSession struct {
+	TG        int64     `gorm:"column:tg;primaryKey;autoIncrement:false" json:"tg"`
+	State     string    `gorm:"column:state;size:64" json:"state"`
+	Action    string    `gorm:"column:action;size:32" json:"action"`
+	Data      string    `gorm:"column:data;type:mediumtext" json:"data"` // JSON 编码的会话数据
+	MessageID int       `gorm:"column:message_id" json:"message_id"`
+	UpdatedAt time.Time `gorm:"column:updated_at;index" json:"updated_at"`
+}
+
+// TableName 表名
+func (BotSession) TableName() string {
+	return "bot_sessions"
+}
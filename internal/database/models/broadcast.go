@@ -0,0 +1,73 @@
+// Package models 数据模型 - 广播任务
+package models
+
+import (
+	"time"
+)
+
+// BroadcastStatus 广播任务状态
+type BroadcastStatus string
+
+const (
+	BroadcastDraft     BroadcastStatus = "draft"     // 已创建，等待确认发送
+	BroadcastRunning   BroadcastStatus = "running"   // 发送中（重启后自动继续）
+	BroadcastPaused    BroadcastStatus = "paused"    // 已暂停
+	BroadcastCancelled BroadcastStatus = "cancelled" // 已取消
+	BroadcastDone      BroadcastStatus = "done"      // 已完成
+)
+
+// 接收者发送状态
+const (
+	RecipientPending = "pending"
+	RecipientSent    = "sent"
+	RecipientFailed  = "failed"
+	RecipientBlocked = "blocked" // 用户已屏蔽 Bot 或账号已注销
+)
+
+// BroadcastJob 广播任务表
+type BroadcastJob struct {
+	ID         uint            `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Creator    int64           `gorm:"column:creator;index" json:"creator"`
+	Template   string          `gorm:"column:template;type:text" json:"template"` // 消息模板（HTML，支持 {name} 等变量）
+	Audience   string          `gorm:"column:audience;size:255" json:"audience"`  // 受众条件（与 /broadcast 参数格式相同）
+	Status     BroadcastStatus `gorm:"column:status;size:16;index" json:"status"`
+	Total      int             `gorm:"column:total" json:"total"`
+	Sent       int             `gorm:"column:sent" json:"sent"`
+	Failed     int             `gorm:"column:failed" json:"failed"`
+	Blocked    int             `gorm:"column:blocked" json:"blocked"`
+	ChatID     int64           `gorm:"column:chat_id" json:"chat_id"`       // 进度消息所在会话
+	MessageID  int             `gorm:"column:message_id" json:"message_id"` // 进度消息 ID
+	CreatedAt  time.Time       `gorm:"column:created_at" json:"created_at"`
+	StartedAt  *time.Time      `gorm:"column:started_at" json:"started_at,omitempty"`
+	FinishedAt *time.Time      `gorm:"column:finished_at" json:"finished_at,omitempty"`
+}
+
+// TableName 表名
+func (BroadcastJob) TableName() string {
+	return "broadcast_jobs"
+}
+
+// Processed 已处理的接收者数量
+func (j *BroadcastJob) Processed() int {
+	return j.Sent + j.Failed + j.Blocked
+}
+
+// IsFinished 是否已结束（完成或取消）
+func (j *BroadcastJob) IsFinished() bool {
+	return j.Status == BroadcastDone || j.Status == BroadcastCancelled
+}
+
+// BroadcastRecipient 广播接收者表，创建任务时按受众条件生成，用于断点续发
+type BroadcastRecipient struct {
+	ID     uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	JobID  uint       `gorm:"column:job_id;uniqueIndex:idx_broadcast_recipient;index:idx_broadcast_status,priority:1" json:"job_id"`
+	TG     int64      `gorm:"column:tg;uniqueIndex:idx_broadcast_recipient" json:"tg"`
+	Status string     `gorm:"column:status;size:16;index:idx_broadcast_status,priority:2" json:"status"`
+	Error  string     `gorm:"column:error;size:255" json:"error,omitempty"`
+	SentAt *time.Time `gorm:"column:sent_at" json:"sent_at,omitempty"`
+}
+
+// TableName 表名
+func (BroadcastRecipient) TableName() string {
+	return "broadcast_recipients"
+}
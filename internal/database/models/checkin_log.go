@@ -0,0 +1,25 @@
+// Package models 数据模型 - 签到记录
+package models
+
+import (
+	"time"
+)
+
+// CheckinDayLayout 签到日期格式（北京时间）
+const CheckinDayLayout = "2006-01-02"
+
+// CheckinLog 签到记录表，每个用户每天一条
+type CheckinLog struct {
+	ID        uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	TG        int64     `gorm:"column:tg;uniqueIndex:idx_checkin_tg_day" json:"tg"`
+	Day       string    `gorm:"column:day;size:10;uniqueIndex:idx_checkin_tg_day;index" json:"day"` // 签到日期 YYYY-MM-DD
+	Reward    int       `gorm:"column:reward" json:"reward"`                                        // 获得的积分（补签为 0）
+	Streak    int       `gorm:"column:streak" json:"streak"`                                        // 截至当天的连续签到天数
+	Repaired  bool      `gorm:"column:repaired;default:false" json:"repaired"`                      // 是否为补签
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName 表名
+func (CheckinLog) TableName() string {
+	return "checkin_logs"
+}
@@ -7,11 +7,18 @@ import (
 
 // Code 注册码表
 type Code struct {
-	Code     string     `gorm:"column:code;primaryKey;size:50" json:"code"`
-	TG       int64      `gorm:"column:tg;index" json:"tg"`
-	Us       int        `gorm:"column:us" json:"us"`                  // 有效天数
-	Used     *int64     `gorm:"column:used" json:"used,omitempty"`    // 使用者 TG ID
-	UsedTime *time.Time `gorm:"column:usedtime" json:"used_time,omitempty"` // 使用时间
+	Code      string     `gorm:"column:code;primaryKey;size:50" json:"code"`
+	TG        int64      `gorm:"column:tg;index" json:"tg"`
+	Us        int        `gorm:"column:us" json:"us"`                        // 有效天数
+	Used      *int64     `gorm:"column:used" json:"used,omitempty"`          // 使用者 TG ID（多次码为最近一次使用者）
+	UsedTime  *time.Time `gorm:"column:usedtime" json:"used_time,omitempty"` // 使用时间
+	BatchID   *uint      `gorm:"column:batch_id;index" json:"batch_id,omitempty"`
+	MaxUses   int        `gorm:"column:max_uses;default:1" json:"max_uses"`     // 可使用次数
+	UseCount  int        `gorm:"column:use_count;default:0" json:"use_count"`   // 已使用次数
+	Level     string     `gorm:"column:lv;size:1" json:"lv,omitempty"`          // 目标等级（为空时按默认等级）
+	ExpiresAt *time.Time `gorm:"column:expires_at" json:"expires_at,omitempty"` // 注册码失效时间（为空表示不过期）
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt *time.Time `gorm:"column:created_at;index" json:"created_at,omitempty"`
 }
 
 // TableName 表名
@@ -19,7 +26,52 @@ func (Code) TableName() string {
 	return "Rcode"
 }
 
-// IsUsed 是否已使用
+// IsUsed 是否已用完：单次码使用过一次，多次码达到可使用次数
 func (c *Code) IsUsed() bool {
-	return c.Used != nil
+	if c.MaxUses <= 1 {
+		return c.Used != nil
+	}
+	return c.UseCount >= c.MaxUses
+}
+
+// IsExpired 是否已过期
+func (c *Code) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// IsRevoked 是否已作废
+func (c *Code) IsRevoked() bool {
+	return c.RevokedAt != nil
+}
+
+// CodeBatch 注册码批次，记录一次生成的标签与使用规则
+type CodeBatch struct {
+	ID        uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Label     string     `gorm:"column:label;size:100" json:"label"`
+	TG        int64      `gorm:"column:tg;index" json:"tg"` // 创建者
+	Days      int        `gorm:"column:days" json:"days"`
+	Count     int        `gorm:"column:count" json:"count"`
+	MaxUses   int        `gorm:"column:max_uses" json:"max_uses"`
+	Level     string     `gorm:"column:lv;size:1" json:"lv,omitempty"`
+	ExpiresAt *time.Time `gorm:"column:expires_at" json:"expires_at,omitempty"`
+	RevokedAt *time.Time `gorm:"column:revoked_at" json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName 表名
+func (CodeBatch) TableName() string {
+	return "code_batches"
+}
+
+// CodeRedemption 注册码使用记录，同一用户对同一注册码只能使用一次
+type CodeRedemption struct {
+	ID     uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Code   string    `gorm:"column:code;size:50;uniqueIndex:idx_code_redemption" json:"code"`
+	TG     int64     `gorm:"column:tg;uniqueIndex:idx_code_redemption;index" json:"tg"`
+	UsedAt time.Time `gorm:"column:used_at" json:"used_at"`
+}
+
+// TableName 表名
+func (CodeRedemption) TableName() string {
+	return "code_redemptions"
 }
@@ -0,0 +1,57 @@
+// Package models 注册码模型测试
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCode_IsUsed(t *testing.T) {
+	tg := int64(123)
+
+	tests := []struct {
+		name     string
+		code     Code
+		expected bool
+	}{
+		{"单次码未使用", Code{MaxUses: 1}, false},
+		{"单次码已使用", Code{MaxUses: 1, Used: &tg}, true},
+		{"旧数据 max_uses 为 0", Code{Used: &tg}, true},
+		{"多次码未用完", Code{MaxUses: 3, UseCount: 2, Used: &tg}, false},
+		{"多次码已用完", Code{MaxUses: 3, UseCount: 3, Used: &tg}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.code.IsUsed(); got != tt.expected {
+				t.Errorf("IsUsed() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCode_IsExpired(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	past := now.Add(-time.Second)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		expiresAt *time.Time
+		expected  bool
+	}{
+		{"不过期", nil, false},
+		{"未到期", &future, false},
+		{"恰好到期", &now, true},
+		{"已过期", &past, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Code{ExpiresAt: tt.expiresAt}
+			if got := c.IsExpired(now); got != tt.expected {
+				t.Errorf("IsExpired() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -16,6 +16,17 @@ const (
 	LevelE UserLevel = "e" // 封禁用户
 )
 
+// LifecycleStatus 账户生命周期状态（到期后依次推进）
+type LifecycleStatus string
+
+const (
+	StatusActive   LifecycleStatus = "active"   // 正常
+	StatusGrace    LifecycleStatus = "grace"    // 已过期，宽限期内仍可使用
+	StatusDisabled LifecycleStatus = "disabled" // 已禁用 Emby 账户
+	StatusFrozen   LifecycleStatus = "frozen"   // 已封存，等待删除
+	StatusDeleted  LifecycleStatus = "deleted"  // 已删除 Emby 账户
+)
+
 // Emby 用户表
 type Emby struct {
 	TG      int64      `gorm:"column:tg;primaryKey;autoIncrement:false" json:"tg"`
@@ -27,9 +38,20 @@ type Emby struct {
 	Cr      *time.Time `gorm:"column:cr" json:"cr,omitempty"`         // 创建时间
 	Ex      *time.Time `gorm:"column:ex" json:"ex,omitempty"`         // 过期时间
 	Us      int        `gorm:"column:us;default:0" json:"us"`         // 积分
-	Iv      int        `gorm:"column:iv;default:0" json:"iv"`         // 邀请次数
+	Iv      int        `gorm:"column:iv;default:0" json:"iv"`         // 花币（商城、求片等消费使用）
 	Ch      *time.Time `gorm:"column:ch" json:"ch,omitempty"`         // 签到时间
 	Ck      int        `gorm:"column:ck;default:0" json:"ck"`         // 连续签到天数
+
+	RepairCards int `gorm:"column:repair_cards;default:0" json:"repair_cards"` // 持有的补签卡数量
+
+	// 生命周期（与封禁 lv=e 相互独立）
+	Status     LifecycleStatus `gorm:"column:status;size:16;default:'active';index" json:"status"`
+	GraceAt    *time.Time      `gorm:"column:grace_at" json:"grace_at,omitempty"`       // 进入宽限期时间
+	DisabledAt *time.Time      `gorm:"column:disabled_at" json:"disabled_at,omitempty"` // 禁用时间
+	FrozenAt   *time.Time      `gorm:"column:frozen_at" json:"frozen_at,omitempty"`     // 封存时间
+	RemovedAt  *time.Time      `gorm:"column:removed_at" json:"removed_at,omitempty"`   // 删除时间
+
+	BlockedAt *time.Time `gorm:"column:blocked_at" json:"blocked_at,omitempty"` // 广播时发现用户已屏蔽 Bot 的时间，待清理
 }
 
 // TableName 表名
@@ -50,11 +72,43 @@ func (e *Emby) IsExpired() bool {
 	return time.Now().After(*e.Ex)
 }
 
-// IsBanned 是否被封禁
+// IsBanned 是否被管理员封禁（到期停用见 IsSuspended）
 func (e *Emby) IsBanned() bool {
 	return e.Lv == LevelE
 }
 
+// CurrentStatus 当前生命周期状态（空值视为正常）
+func (e *Emby) CurrentStatus() LifecycleStatus {
+	if e.Status == "" {
+		return StatusActive
+	}
+	return e.Status
+}
+
+// IsSuspended 是否因到期被停用（禁用或封存）
+func (e *Emby) IsSuspended() bool {
+	s := e.CurrentStatus()
+	return s == StatusDisabled || s == StatusFrozen
+}
+
+// GetStatusName 获取生命周期状态名称
+func (e *Emby) GetStatusName() string {
+	switch e.CurrentStatus() {
+	case StatusActive:
+		return "✅ 正常"
+	case StatusGrace:
+		return "⏳ 宽限期"
+	case StatusDisabled:
+		return "⛔ 已停用"
+	case StatusFrozen:
+		return "❄️ 已封存"
+	case StatusDeleted:
+		return "🗑 已删除"
+	default:
+		return "❓ 未知"
+	}
+}
+
 // IsWhitelist 是否是白名单用户
 func (e *Emby) IsWhitelist() bool {
 	return e.Lv == LevelA
@@ -0,0 +1,22 @@
+// Package models 数据模型 - 附加服务器账户
+package models
+
+import "time"
+
+// EmbyAccount 用户在附加服务器上的账户
+// 主服务器账户仍保存在 emby 表，此表按 (tg, server) 唯一
+type EmbyAccount struct {
+	ID        uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	TG        int64     `gorm:"column:tg;uniqueIndex:idx_emby_account_server" json:"tg"`
+	Server    string    `gorm:"column:server;size:64;uniqueIndex:idx_emby_account_server" json:"server"`
+	EmbyID    string    `gorm:"column:embyid;size:255;index" json:"emby_id"`
+	Name      string    `gorm:"column:name;size:255" json:"name"`
+	Pwd       string    `gorm:"column:pwd;size:255" json:"-"`
+	Disabled  bool      `gorm:"column:disabled;default:false" json:"disabled"`
+	CreatedAt time.Time `gorm:"column:created_at" json:"created_at"`
+}
+
+// TableName 表名
+func (EmbyAccount) TableName() string {
+	return "emby_accounts"
+}
@@ -72,6 +72,33 @@ func TestEmby_IsBanned(t *testing.T) {
 	}
 }
 
+func TestEmby_IsSuspended(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   LifecycleStatus
+		expected bool
+	}{
+		{"未设置状态", "", false},
+		{"正常", StatusActive, false},
+		{"宽限期", StatusGrace, false},
+		{"已禁用", StatusDisabled, true},
+		{"已封存", StatusFrozen, true},
+		{"已删除", StatusDeleted, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &Emby{Lv: LevelB, Status: tt.status}
+			if got := e.IsSuspended(); got != tt.expected {
+				t.Errorf("IsSuspended() = %v, want %v", got, tt.expected)
+			}
+			if e.IsBanned() {
+				t.Error("到期停用不应视为封禁")
+			}
+		})
+	}
+}
+
 func TestEmby_IsWhitelist(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -0,0 +1,19 @@
+// Package models 数据模型 - 到期预警记录
+package models
+
+import "time"
+
+// ExpiryWarning 到期预警发送记录
+// 以 (tg, expiry_at, lead_days) 唯一，续期后到期时间变化会重新预警
+type ExpiryWarning struct {
+	ID       uint      `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	TG       int64     `gorm:"column:tg;uniqueIndex:idx_expiry_warning" json:"tg"`
+	ExpiryAt time.Time `gorm:"column:expiry_at;uniqueIndex:idx_expiry_warning" json:"expiry_at"` // 预警对应的到期时间
+	LeadDays int       `gorm:"column:lead_days;uniqueIndex:idx_expiry_warning" json:"lead_days"` // 提前天数
+	SentAt   time.Time `gorm:"column:sent_at" json:"sent_at"`
+}
+
+// TableName 表名
+func (ExpiryWarning) TableName() string {
+	return "expiry_warnings"
+}
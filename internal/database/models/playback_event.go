@@ -0,0 +1,59 @@
+// Package models 数据模型 - Emby Webhook 事件
+package models
+
+import (
+	"time"
+)
+
+// Webhook 事件类型
+const (
+	EventPlaybackStart     = "playback.start"
+	EventPlaybackStop      = "playback.stop"
+	EventPlaybackPause     = "playback.pause"
+	EventPlaybackUnpause   = "playback.unpause"
+	EventUserAuthenticated = "user.authenticated"
+)
+
+// 事件处理动作
+const (
+	EventActionNone       = ""           // 未处理
+	EventActionTerminated = "terminated" // 已终止会话
+	EventActionBlocked    = "blocked"    // 已终止会话并禁用用户
+	EventActionFlagged    = "flagged"    // 命中过滤规则但未配置处理
+)
+
+// PlaybackEvent Emby Webhook 事件记录表
+type PlaybackEvent struct {
+	ID            uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Event         string    `gorm:"column:event;size:50;index" json:"event"`
+	Server        string    `gorm:"column:server;size:64;index" json:"server"` // 来源服务器名称
+	EmbyUserID    string    `gorm:"column:emby_user_id;size:64;index" json:"emby_user_id"`
+	UserName      string    `gorm:"column:user_name;size:255" json:"user_name"`
+	TG            int64     `gorm:"column:tg;index" json:"tg"` // 关联的 TG 用户（未绑定为 0）
+	ItemID        string    `gorm:"column:item_id;size:64" json:"item_id"`
+	ItemName      string    `gorm:"column:item_name;size:500" json:"item_name"`
+	ItemType      string    `gorm:"column:item_type;size:50" json:"item_type"`
+	Client        string    `gorm:"column:client;size:255" json:"client"`
+	DeviceName    string    `gorm:"column:device_name;size:255" json:"device_name"`
+	DeviceID      string    `gorm:"column:device_id;size:255" json:"device_id"`
+	ClientIP      string    `gorm:"column:client_ip;size:64;index" json:"client_ip"`
+	SessionID     string    `gorm:"column:session_id;size:64" json:"session_id"`
+	PositionTicks int64     `gorm:"column:position_ticks" json:"position_ticks"`
+	Filtered      bool      `gorm:"column:filtered;default:false" json:"filtered"` // 是否命中客户端黑名单
+	MatchedRule   string    `gorm:"column:matched_rule;size:255" json:"matched_rule,omitempty"`
+	Action        string    `gorm:"column:action;size:20" json:"action,omitempty"`
+	CreatedAt     time.Time `gorm:"column:created_at;index" json:"created_at"`
+}
+
+// TableName 表名
+func (PlaybackEvent) TableName() string {
+	return "playback_events"
+}
+
+// DisplayUser 显示用的用户名
+func (e *PlaybackEvent) DisplayUser() string {
+	if e.UserName != "" {
+		return e.UserName
+	}
+	return e.EmbyUserID
+}
@@ -0,0 +1,64 @@
+// Package models 数据模型 - 本地播放记录
+package models
+
+import "time"
+
+// 播放记录来源
+const (
+	SessionSourceWebhook = "webhook" // Emby Webhook 推送
+	SessionSourcePoller  = "poller"  // 定时轮询 /Sessions 发现
+)
+
+// PlaybackSession 本地播放记录表，每次播放（会话 + 媒体）一行
+// 由 Webhook 与 /Sessions 轮询共同维护，排行、审计、活跃度检测可直接查询此表
+type PlaybackSession struct {
+	ID            uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	SessionID     string     `gorm:"column:session_id;size:64;index:idx_playback_session_item" json:"session_id"`
+	EmbyUserID    string     `gorm:"column:emby_user_id;size:64;index:idx_playback_user_started" json:"emby_user_id"`
+	UserName      string     `gorm:"column:user_name;size:255" json:"user_name"`
+	TG            int64      `gorm:"column:tg;index" json:"tg"` // 关联的 TG 用户（未绑定为 0）
+	ItemID        string     `gorm:"column:item_id;size:64;index:idx_playback_session_item;index" json:"item_id"`
+	ItemName      string     `gorm:"column:item_name;size:500" json:"item_name"`
+	ItemType      string     `gorm:"column:item_type;size:50" json:"item_type"`
+	Client        string     `gorm:"column:client;size:255;index" json:"client"`
+	DeviceName    string     `gorm:"column:device_name;size:255;index" json:"device_name"`
+	DeviceID      string     `gorm:"column:device_id;size:255" json:"device_id"`
+	ClientIP      string     `gorm:"column:client_ip;size:64;index" json:"client_ip"`
+	StartedAt     time.Time  `gorm:"column:started_at;index;index:idx_playback_user_started" json:"started_at"`
+	StoppedAt     *time.Time `gorm:"column:stopped_at;index" json:"stopped_at"` // 为空表示仍在播放
+	LastSeenAt    time.Time  `gorm:"column:last_seen_at" json:"last_seen_at"`   // 最近一次确认仍在播放
+	PositionTicks int64      `gorm:"column:position_ticks" json:"position_ticks"`
+	Duration      int64      `gorm:"column:duration" json:"duration"` // 播放时长（秒）
+	Source        string     `gorm:"column:source;size:20" json:"source"`
+}
+
+// TableName 表名
+func (PlaybackSession) TableName() string {
+	return "playback_sessions"
+}
+
+// IsOpen 是否仍在播放
+func (s *PlaybackSession) IsOpen() bool {
+	return s.StoppedAt == nil
+}
+
+// Touch 更新最近播放时间与进度，并重新计算播放时长
+func (s *PlaybackSession) Touch(at time.Time, positionTicks int64) {
+	if at.After(s.LastSeenAt) {
+		s.LastSeenAt = at
+	}
+	if positionTicks > 0 {
+		s.PositionTicks = positionTicks
+	}
+	s.Duration = int64(s.LastSeenAt.Sub(s.StartedAt).Seconds())
+	if s.Duration < 0 {
+		s.Duration = 0
+	}
+}
+
+// Stop 结束播放
+func (s *PlaybackSession) Stop(at time.Time, positionTicks int64) {
+	s.Touch(at, positionTicks)
+	stopped := s.LastSeenAt
+	s.StoppedAt = &stopped
+}
@@ -0,0 +1,34 @@
+// Package models 本地播放记录测试
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlaybackSession_TouchAndStop(t *testing.T) {
+	start := time.Date(2024, 1, 1, 20, 0, 0, 0, time.UTC)
+	s := &PlaybackSession{StartedAt: start, LastSeenAt: start}
+
+	s.Touch(start.Add(10*time.Minute), 6000)
+	if s.Duration != 600 || s.PositionTicks != 6000 {
+		t.Errorf("Touch 后 Duration=%d PositionTicks=%d", s.Duration, s.PositionTicks)
+	}
+
+	// 乱序到达的旧事件不回退时间与进度
+	s.Touch(start.Add(5*time.Minute), 0)
+	if s.Duration != 600 || s.PositionTicks != 6000 {
+		t.Errorf("旧事件不应回退: Duration=%d PositionTicks=%d", s.Duration, s.PositionTicks)
+	}
+	if !s.IsOpen() {
+		t.Error("未结束的记录 IsOpen() 应为 true")
+	}
+
+	s.Stop(start.Add(30*time.Minute), 9000)
+	if s.IsOpen() || !s.StoppedAt.Equal(start.Add(30*time.Minute)) {
+		t.Errorf("Stop 后 StoppedAt = %v", s.StoppedAt)
+	}
+	if s.Duration != 1800 || s.PositionTicks != 9000 {
+		t.Errorf("Stop 后 Duration=%d PositionTicks=%d", s.Duration, s.PositionTicks)
+	}
+}
@@ -0,0 +1,60 @@
+// Package models 数据模型 - 积分流水
+package models
+
+import (
+	"time"
+)
+
+// Currency 余额类型，取值即 emby 表中对应的列名
+type Currency string
+
+const (
+	CurrencyScore Currency = "us" // 积分
+	CurrencyCoin  Currency = "iv" // 花币（商城、求片等消费使用）
+)
+
+// 流水原因
+const (
+	LedgerReasonAdmin       = "admin"        // 管理员调整
+	LedgerReasonAdminBatch  = "admin_batch"  // 管理员批量发放
+	LedgerReasonAdminClear  = "admin_clear"  // 管理员清空
+	LedgerReasonCheckin     = "checkin"      // 签到奖励
+	LedgerReasonRepairCard  = "repair_card"  // 购买补签卡
+	LedgerReasonRedEnvelope = "red_envelope" // 发红包
+	LedgerReasonRedGrab     = "red_grab"     // 抢红包
+	LedgerReasonRedRefund   = "red_refund"   // 红包退款
+	LedgerReasonStoreRenew  = "store_renew"  // 商城续期
+	LedgerReasonStoreWhite  = "store_white"  // 商城白名单
+	LedgerReasonStoreReborn = "store_reborn" // 商城解封
+	LedgerReasonStoreInvite = "store_invite" // 商城邀请码
+	LedgerReasonStoreRefund = "store_refund" // 商城退款
+	LedgerReasonMoviePilot  = "moviepilot"   // 求片下载
+	LedgerReasonMPRefund    = "mp_refund"    // 求片退款
+	LedgerReasonPlayRank    = "play_rank"    // 播放榜奖励
+	LedgerReasonChangeTG    = "change_tg"    // 换绑转移
+	LedgerReasonReferral    = "referral"     // 邀请返利
+)
+
+// PointsLedger 积分流水表
+type PointsLedger struct {
+	ID           uint      `gorm:"primaryKey;autoIncrement" json:"id"`
+	TG           int64     `gorm:"column:tg;index:idx_ledger_tg_time" json:"tg"`
+	Currency     Currency  `gorm:"column:currency;size:4" json:"currency"`
+	Amount       int       `gorm:"column:amount" json:"amount"`                       // 变动值（正为收入，负为支出）
+	Balance      int       `gorm:"column:balance" json:"balance"`                     // 变动后余额
+	Reason       string    `gorm:"column:reason;size:32;index" json:"reason"`         // 变动原因
+	Actor        int64     `gorm:"column:actor" json:"actor"`                         // 操作者 TG ID（0 表示系统）
+	Counterparty *int64    `gorm:"column:counterparty" json:"counterparty,omitempty"` // 交易对方 TG ID
+	RefID        string    `gorm:"column:ref_id;size:64;index" json:"ref_id"`         // 关联业务 ID（红包 UUID、下载 ID 等）
+	CreatedAt    time.Time `gorm:"column:created_at;index:idx_ledger_tg_time" json:"created_at"`
+}
+
+// TableName 表名
+func (PointsLedger) TableName() string {
+	return "points_ledger"
+}
+
+// IsIncome 是否为收入
+func (l *PointsLedger) IsIncome() bool {
+	return l.Amount > 0
+}
@@ -0,0 +1,19 @@
+// Package models 数据模型 - 榜单消息记录
+package models
+
+import "time"
+
+// RankMessage 最近一次推送的榜单消息，每种榜单一条
+// 同一周期内重新生成时编辑原消息，进入新周期时取消置顶旧消息
+type RankMessage struct {
+	Kind      string    `gorm:"column:kind;primaryKey;size:32" json:"kind"` // 榜单类型，如 media_day
+	ChatID    int64     `gorm:"column:chat_id" json:"chat_id"`
+	MessageID int       `gorm:"column:message_id" json:"message_id"`
+	Period    string    `gorm:"column:period;size:16" json:"period"` // 榜单周期，如 2024-01-02、2024-W01
+	UpdatedAt time.Time `gorm:"column:updated_at" json:"updated_at"`
+}
+
+// TableName 表名
+func (RankMessage) TableName() string {
+	return "rank_messages"
+}
@@ -0,0 +1,38 @@
+// Package models 数据模型 - 邀请关系
+package models
+
+import (
+	"time"
+)
+
+// 邀请来源
+const (
+	ReferralSourceLink = "link" // 通过 /start ref_<id> 邀请链接
+	ReferralSourceCode = "code" // 使用了他人购买的邀请码
+)
+
+// Referral 邀请关系表，每个被邀请人只能有一个邀请人
+type Referral struct {
+	ID           uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	InviterTG    int64      `gorm:"column:inviter_tg;index" json:"inviter_tg"`
+	InviteeTG    int64      `gorm:"column:invitee_tg;uniqueIndex" json:"invitee_tg"`
+	Source       string     `gorm:"column:source;size:8" json:"source"`
+	CreatedAt    time.Time  `gorm:"column:created_at" json:"created_at"`
+	RegisteredAt *time.Time `gorm:"column:registered_at" json:"registered_at,omitempty"` // 被邀请人注册时间（注册奖励已发放）
+	RenewedAt    *time.Time `gorm:"column:renewed_at" json:"renewed_at,omitempty"`       // 被邀请人首次续期时间（续期奖励已发放）
+}
+
+// TableName 表名
+func (Referral) TableName() string {
+	return "referrals"
+}
+
+// IsRegistered 被邀请人是否已注册
+func (r *Referral) IsRegistered() bool {
+	return r.RegisteredAt != nil
+}
+
+// IsRenewed 被邀请人是否已续期
+func (r *Referral) IsRenewed() bool {
+	return r.RenewedAt != nil
+}
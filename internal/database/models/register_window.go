@@ -0,0 +1,67 @@
+// Package models 数据模型 - 定时注册窗口
+package models
+
+import (
+	"time"
+)
+
+// RegisterWindowStatus 注册窗口状态
+type RegisterWindowStatus string
+
+const (
+	RegisterWindowScheduled RegisterWindowStatus = "scheduled" // 等待开放
+	RegisterWindowOpen      RegisterWindowStatus = "open"      // 开放中
+	RegisterWindowClosed    RegisterWindowStatus = "closed"    // 已关闭（包括开放前取消）
+)
+
+// 注册窗口关闭原因
+const (
+	RegisterCloseTimeout   = "timeout"   // 开放时长已到
+	RegisterCloseFull      = "full"      // 名额已满
+	RegisterCloseCancelled = "cancelled" // 管理员取消
+)
+
+// RegisterWindow 定时注册窗口表，名额计数在数据库中原子累加
+type RegisterWindow struct {
+	ID          uint                 `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	Creator     int64                `gorm:"column:creator" json:"creator"`
+	OpensAt     time.Time            `gorm:"column:opens_at;index" json:"opens_at"`
+	Minutes     int                  `gorm:"column:minutes" json:"minutes"` // 开放时长，0 表示直到名额用完
+	Seats       int                  `gorm:"column:seats" json:"seats"`     // 名额，0 表示不限（直到时长结束）
+	Taken       int                  `gorm:"column:taken" json:"taken"`     // 已注册人数
+	Status      RegisterWindowStatus `gorm:"column:status;size:16;index" json:"status"`
+	CloseReason string               `gorm:"column:close_reason;size:16" json:"close_reason,omitempty"`
+	CreatedAt   time.Time            `gorm:"column:created_at" json:"created_at"`
+	OpenedAt    *time.Time           `gorm:"column:opened_at" json:"opened_at,omitempty"`
+	ClosedAt    *time.Time           `gorm:"column:closed_at" json:"closed_at,omitempty"`
+}
+
+// TableName 表名
+func (RegisterWindow) TableName() string {
+	return "register_windows"
+}
+
+// ClosesAt 计划关闭时间（开放时间 + 时长），未限制时长时返回 nil
+func (w *RegisterWindow) ClosesAt() *time.Time {
+	if w.Minutes <= 0 {
+		return nil
+	}
+	t := w.OpensAt.Add(time.Duration(w.Minutes) * time.Minute)
+	return &t
+}
+
+// Remaining 剩余名额，不限名额时返回 -1
+func (w *RegisterWindow) Remaining() int {
+	if w.Seats <= 0 {
+		return -1
+	}
+	if w.Taken >= w.Seats {
+		return 0
+	}
+	return w.Seats - w.Taken
+}
+
+// IsFull 名额是否已满
+func (w *RegisterWindow) IsFull() bool {
+	return w.Seats > 0 && w.Taken >= w.Seats
+}
@@ -0,0 +1,40 @@
+// Package models 数据模型 - 账户共享检测记录
+package models
+
+import "time"
+
+// 共享检测处理结果
+const (
+	SharingActionPending = "pending" // 待管理员处理
+	SharingActionWarn    = "warn"    // 已警告用户
+	SharingActionReset   = "reset"   // 已重置密码
+	SharingActionDisable = "disable" // 已禁用账户
+	SharingActionIgnore  = "ignore"  // 已忽略
+)
+
+// SharingDecision 账户共享检测记录，每次上报一行，管理员处理后记录处理结果
+type SharingDecision struct {
+	ID        uint       `gorm:"column:id;primaryKey;autoIncrement" json:"id"`
+	TG        int64      `gorm:"column:tg;index" json:"tg"`
+	EmbyID    string     `gorm:"column:embyid;size:64" json:"embyid"`
+	Name      string     `gorm:"column:name;size:255" json:"name"`
+	Score     float64    `gorm:"column:score" json:"score"`
+	Subnets   int        `gorm:"column:subnets" json:"subnets"`   // 不同 IP 网段数
+	Devices   int        `gorm:"column:devices" json:"devices"`   // 不同设备数
+	Overlaps  int        `gorm:"column:overlaps" json:"overlaps"` // 不同设备同时播放次数
+	Detail    string     `gorm:"column:detail;size:2000" json:"detail"`
+	Action    string     `gorm:"column:action;size:20;index" json:"action"`
+	AdminID   int64      `gorm:"column:admin_id" json:"admin_id"` // 处理的管理员
+	CreatedAt time.Time  `gorm:"column:created_at;index" json:"created_at"`
+	DecidedAt *time.Time `gorm:"column:decided_at" json:"decided_at"`
+}
+
+// TableName 表名
+func (SharingDecision) TableName() string {
+	return "sharing_decisions"
+}
+
+// IsPending 是否待处理
+func (d *SharingDecision) IsPending() bool {
+	return d.Action == SharingActionPending
+}
@@ -0,0 +1,133 @@
+// Package repository 广播任务数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// BroadcastRepository 广播任务仓库
+type BroadcastRepository struct {
+	db *gorm.DB
+}
+
+// NewBroadcastRepository 创建广播任务仓库
+func NewBroadcastRepository() *BroadcastRepository {
+	return &BroadcastRepository{db: database.GetDB()}
+}
+
+// Create 创建广播任务及其接收者
+func (r *BroadcastRepository) Create(job *models.BroadcastJob, tgs []int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		job.Total = len(tgs)
+		if err := tx.Create(job).Error; err != nil {
+			return err
+		}
+		if len(tgs) == 0 {
+			return nil
+		}
+		recipients := make([]models.BroadcastRecipient, len(tgs))
+		for i, tg := range tgs {
+			recipients[i] = models.BroadcastRecipient{JobID: job.ID, TG: tg, Status: models.RecipientPending}
+		}
+		return tx.CreateInBatches(recipients, 500).Error
+	})
+}
+
+// GetByID 获取广播任务
+func (r *BroadcastRepository) GetByID(id uint) (*models.BroadcastJob, error) {
+	var job models.BroadcastJob
+	if err := r.db.First(&job, id).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// ListRecent 获取最近的广播任务
+func (r *BroadcastRepository) ListRecent(limit int) ([]models.BroadcastJob, error) {
+	var jobs []models.BroadcastJob
+	err := r.db.Order("id DESC").Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// ListByStatus 获取指定状态的广播任务
+func (r *BroadcastRepository) ListByStatus(status models.BroadcastStatus) ([]models.BroadcastJob, error) {
+	var jobs []models.BroadcastJob
+	err := r.db.Where("status = ?", status).Order("id ASC").Find(&jobs).Error
+	return jobs, err
+}
+
+// UpdateStatus 从 from 中的状态切换到 to，状态不符时返回 false
+func (r *BroadcastRepository) UpdateStatus(id uint, to models.BroadcastStatus, from ...models.BroadcastStatus) (bool, error) {
+	updates := map[string]interface{}{"status": to}
+	now := time.Now()
+	switch to {
+	case models.BroadcastRunning:
+		updates["started_at"] = gorm.Expr("COALESCE(started_at, ?)", now)
+	case models.BroadcastDone, models.BroadcastCancelled:
+		updates["finished_at"] = now
+	}
+
+	query := r.db.Model(&models.BroadcastJob{}).Where("id = ?", id)
+	if len(from) > 0 {
+		query = query.Where("status IN ?", from)
+	}
+	result := query.Updates(updates)
+	return result.RowsAffected > 0, result.Error
+}
+
+// SetProgressMessage 记录进度消息位置
+func (r *BroadcastRepository) SetProgressMessage(id uint, chatID int64, messageID int) error {
+	return r.db.Model(&models.BroadcastJob{}).Where("id = ?", id).
+		Updates(map[string]interface{}{"chat_id": chatID, "message_id": messageID}).Error
+}
+
+// NextPending 获取待发送的接收者
+func (r *BroadcastRepository) NextPending(jobID uint, limit int) ([]models.BroadcastRecipient, error) {
+	var recipients []models.BroadcastRecipient
+	err := r.db.Where("job_id = ? AND status = ?", jobID, models.RecipientPending).
+		Order("id ASC").Limit(limit).Find(&recipients).Error
+	return recipients, err
+}
+
+// FirstRecipient 获取任务的第一个接收者（用于预览）
+func (r *BroadcastRepository) FirstRecipient(jobID uint) (*models.BroadcastRecipient, error) {
+	var recipient models.BroadcastRecipient
+	if err := r.db.Where("job_id = ?", jobID).Order("id ASC").First(&recipient).Error; err != nil {
+		return nil, err
+	}
+	return &recipient, nil
+}
+
+// recipientCounter 接收者状态对应的任务计数列
+var recipientCounter = map[string]string{
+	models.RecipientSent:    "sent",
+	models.RecipientFailed:  "failed",
+	models.RecipientBlocked: "blocked",
+}
+
+// MarkRecipient 记录接收者发送结果并累加任务计数（已处理过的接收者不重复计数）
+func (r *BroadcastRepository) MarkRecipient(recipient *models.BroadcastRecipient, status, errMsg string) error {
+	column, ok := recipientCounter[status]
+	if !ok {
+		return nil
+	}
+	if len(errMsg) > 255 {
+		errMsg = errMsg[:255]
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.BroadcastRecipient{}).
+			Where("id = ? AND status = ?", recipient.ID, models.RecipientPending).
+			Updates(map[string]interface{}{"status": status, "error": errMsg, "sent_at": &now})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return tx.Model(&models.BroadcastJob{}).Where("id = ?", recipient.JobID).
+			Update(column, gorm.Expr(column+" + 1")).Error
+	})
+}
@@ -0,0 +1,127 @@
+// Package repository 签到记录数据仓库
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CheckinLogRepository 签到记录仓库
+type CheckinLogRepository struct {
+	db *gorm.DB
+}
+
+// NewCheckinLogRepository 创建签到记录仓库
+func NewCheckinLogRepository() *CheckinLogRepository {
+	return &CheckinLogRepository{db: database.GetDB()}
+}
+
+// CheckinDayStat 单日签到统计
+type CheckinDayStat struct {
+	Day      string `json:"day"`
+	Users    int64  `json:"users"`
+	Rewards  int64  `json:"rewards"`
+	Repaired int64  `json:"repaired"`
+}
+
+// Create 写入签到记录，当天已有记录时返回 false
+func (r *CheckinLogRepository) Create(log *models.CheckinLog) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(log)
+	return result.RowsAffected > 0, result.Error
+}
+
+// Checkin 在同一事务中占用今日签到、写入签到记录并发放奖励流水
+// 以条件更新占用今日签到（ch 早于今日零点），今日已签到时返回 false 且不做任何修改
+func (r *CheckinLogRepository) Checkin(log *models.CheckinLog, todayStart time.Time, reward *models.PointsLedger) (bool, error) {
+	checked := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Emby{}).
+			Where("tg = ? AND (ch IS NULL OR ch < ?)", log.TG, todayStart).
+			Updates(map[string]interface{}{"ch": log.CreatedAt, "ck": log.Streak})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		created := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(log)
+		if created.Error != nil {
+			return created.Error
+		}
+		if created.RowsAffected == 0 {
+			// 今日已有签到记录，回滚签到时间的更新
+			return errAlreadyCheckedIn
+		}
+
+		if err := applyLedger(tx, []*models.PointsLedger{reward}); err != nil {
+			return err
+		}
+		checked = true
+		return nil
+	})
+	if errors.Is(err, errAlreadyCheckedIn) {
+		return false, nil
+	}
+	return checked, err
+}
+
+// errAlreadyCheckedIn 用于回滚重复签到的事务
+var errAlreadyCheckedIn = errors.New("今日已签到")
+
+// GetByDay 获取用户某天的签到记录
+func (r *CheckinLogRepository) GetByDay(tg int64, day string) (*models.CheckinLog, error) {
+	var log models.CheckinLog
+	if err := r.db.Where("tg = ? AND day = ?", tg, day).First(&log).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// GetFirst 获取用户最早的签到记录
+func (r *CheckinLogRepository) GetFirst(tg int64) (*models.CheckinLog, error) {
+	var log models.CheckinLog
+	if err := r.db.Where("tg = ?", tg).Order("day").First(&log).Error; err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// ListRange 获取用户在 [from, to] 日期范围内的签到记录（按日期升序）
+func (r *CheckinLogRepository) ListRange(tg int64, from, to string) ([]models.CheckinLog, error) {
+	var logs []models.CheckinLog
+	err := r.db.Where("tg = ? AND day >= ? AND day <= ?", tg, from, to).
+		Order("day").
+		Find(&logs).Error
+	return logs, err
+}
+
+// UpdateStreak 更新记录的连续天数
+func (r *CheckinLogRepository) UpdateStreak(id uint, streak int) error {
+	return r.db.Model(&models.CheckinLog{}).Where("id = ?", id).Update("streak", streak).Error
+}
+
+// DailyStats 按天统计 from 以来的签到人数、奖励与补签数（按日期升序）
+func (r *CheckinLogRepository) DailyStats(from string) ([]CheckinDayStat, error) {
+	var stats []CheckinDayStat
+	err := r.db.Model(&models.CheckinLog{}).
+		Select("day, COUNT(*) AS users, COALESCE(SUM(reward), 0) AS rewards, "+
+			"SUM(CASE WHEN repaired THEN 1 ELSE 0 END) AS repaired").
+		Where("day >= ?", from).
+		Group("day").
+		Order("day").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// CountUsersSince 统计 from 以来签到过的用户数
+func (r *CheckinLogRepository) CountUsersSince(from string) (int64, error) {
+	var count int64
+	err := r.db.Model(&models.CheckinLog{}).
+		Where("day >= ? AND repaired = ?", from, false).
+		Distinct("tg").
+		Count(&count).Error
+	return count, err
+}
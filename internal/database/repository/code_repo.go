@@ -2,6 +2,7 @@
 package repository
 
 import (
+	"errors"
 	"time"
 
 	"github.com/smysle/sakura-embyboss-go/internal/database"
@@ -9,6 +10,20 @@ import (
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrCodeUnavailable 注册码已作废、已过期或已用完（条件更新未命中）
+	ErrCodeUnavailable = errors.New("注册码不可用")
+	// ErrCodeRedeemed 用户已使用过该注册码
+	ErrCodeRedeemed = errors.New("已使用过该注册码")
+)
+
+// codeRedeemableSQL 注册码可使用的条件：未作废、未过期，单次码未被使用或多次码未达到可使用次数
+const codeRedeemableSQL = "revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?) AND " +
+	"((max_uses <= 1 AND used IS NULL) OR (max_uses > 1 AND use_count < max_uses))"
+
+// codeBatchInsertSize 批量写入注册码时每条 INSERT 的行数
+const codeBatchInsertSize = 500
+
 // CodeRepository 注册码仓库
 type CodeRepository struct {
 	db *gorm.DB
@@ -24,17 +39,17 @@ func (r *CodeRepository) Create(code *models.Code) error {
 	return r.db.Create(code).Error
 }
 
-// BatchCreate 批量创建注册码
-func (r *CodeRepository) BatchCreate(codes []string, tg int64, days int) error {
-	var codeModels []models.Code
-	for _, c := range codes {
-		codeModels = append(codeModels, models.Code{
-			Code: c,
-			TG:   tg,
-			Us:   days,
-		})
-	}
-	return r.db.Create(&codeModels).Error
+// CreateBatch 在同一事务中创建批次及其注册码，注册码的 BatchID 由批次 ID 填充
+func (r *CodeRepository) CreateBatch(batch *models.CodeBatch, codes []models.Code) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(batch).Error; err != nil {
+			return err
+		}
+		for i := range codes {
+			codes[i].BatchID = &batch.ID
+		}
+		return tx.CreateInBatches(codes, codeBatchInsertSize).Error
+	})
 }
 
 // GetByCode 根据注册码获取
@@ -47,13 +62,53 @@ func (r *CodeRepository) GetByCode(code string) (*models.Code, error) {
 	return &c, nil
 }
 
-// MarkUsed 标记注册码已使用
-func (r *CodeRepository) MarkUsed(code string, usedBy int64) error {
-	now := time.Now()
-	return r.db.Model(&models.Code{}).Where("code = ?", code).Updates(map[string]interface{}{
-		"used":     usedBy,
-		"usedtime": now,
-	}).Error
+// Redeem 在同一事务中占用一次注册码并写入使用记录
+// 用户已使用过该码返回 ErrCodeRedeemed，注册码不可用返回 ErrCodeUnavailable
+func (r *CodeRepository) Redeem(code string, tg int64, at time.Time) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.CodeRedemption{}).Where("code = ? AND tg = ?", code, tg).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrCodeRedeemed
+		}
+
+		result := tx.Model(&models.Code{}).
+			Where("code = ? AND "+codeRedeemableSQL, code, at).
+			Updates(map[string]interface{}{
+				"used":      tg,
+				"usedtime":  at,
+				"use_count": gorm.Expr("use_count + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrCodeUnavailable
+		}
+
+		return tx.Create(&models.CodeRedemption{Code: code, TG: tg, UsedAt: at}).Error
+	})
+}
+
+// Unredeem 撤销用户的一次使用（后续步骤失败时归还）
+func (r *CodeRepository) Unredeem(code string, tg int64) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("code = ? AND tg = ?", code, tg).Delete(&models.CodeRedemption{})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Model(&models.Code{}).
+			Where("code = ? AND use_count > 0", code).
+			Update("use_count", gorm.Expr("use_count - 1")).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.Code{}).
+			Where("code = ? AND use_count = 0", code).
+			Updates(map[string]interface{}{"used": nil, "usedtime": nil}).Error
+	})
 }
 
 // CountStats 统计注册码
@@ -179,30 +234,154 @@ func (r *CodeRepository) ListWithPagination(page, pageSize int, filter string) (
 
 	// 分页查询
 	offset := (page - 1) * pageSize
-	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&codes).Error
+	err := query.Order("created_at DESC, code").Offset(offset).Limit(pageSize).Find(&codes).Error
 	if err != nil {
 		return nil, 0, err
 	}
 
-	// 转换为 CodeInfo
-	var infos []CodeInfo
+	return toCodeInfos(codes), total, nil
+}
+
+// ListByBatch 分页获取批次内的注册码
+func (r *CodeRepository) ListByBatch(batchID uint, page, pageSize int) ([]CodeInfo, int64, error) {
+	var codes []models.Code
+	var total int64
+
+	query := r.db.Model(&models.Code{}).Where("batch_id = ?", batchID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("code").Offset(offset).Limit(pageSize).Find(&codes).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return toCodeInfos(codes), total, nil
+}
+
+// GetByBatch 获取批次内的所有注册码
+func (r *CodeRepository) GetByBatch(batchID uint) ([]models.Code, error) {
+	var codes []models.Code
+	err := r.db.Where("batch_id = ?", batchID).Order("code").Find(&codes).Error
+	return codes, err
+}
+
+// toCodeInfos 转换为 CodeInfo
+func toCodeInfos(codes []models.Code) []CodeInfo {
+	infos := make([]CodeInfo, 0, len(codes))
 	for _, c := range codes {
 		infos = append(infos, CodeInfo{
-			Code: c.Code,
-			Days: c.Us,
-			Used: c.Used != nil,
+			Code:      c.Code,
+			Days:      c.Us,
+			Used:      c.IsUsed(),
+			UsedBy:    c.Used,
+			BatchID:   c.BatchID,
+			MaxUses:   c.MaxUses,
+			UseCount:  c.UseCount,
+			Level:     c.Level,
+			ExpiresAt: c.ExpiresAt,
+			Revoked:   c.IsRevoked(),
 		})
 	}
-
-	return infos, total, nil
+	return infos
 }
 
 // CodeInfo 注册码信息（用于显示）
 type CodeInfo struct {
-	Code   string
-	Days   int
-	Used   bool
-	UsedBy *int64
+	Code      string
+	Days      int
+	Used      bool
+	UsedBy    *int64
+	BatchID   *uint
+	MaxUses   int
+	UseCount  int
+	Level     string
+	ExpiresAt *time.Time
+	Revoked   bool
+}
+
+// CodeBatchInfo 批次信息与使用统计
+type CodeBatchInfo struct {
+	models.CodeBatch
+	Uses int64 // 已使用次数
+}
+
+// GetBatch 根据 ID 获取批次
+func (r *CodeRepository) GetBatch(id uint) (*models.CodeBatch, error) {
+	var batch models.CodeBatch
+	if err := r.db.First(&batch, id).Error; err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+// ListBatches 分页获取批次列表（按创建时间倒序）
+func (r *CodeRepository) ListBatches(page, pageSize int) ([]CodeBatchInfo, int64, error) {
+	var batches []models.CodeBatch
+	var total int64
+
+	query := r.db.Model(&models.CodeBatch{})
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&batches).Error; err != nil {
+		return nil, 0, err
+	}
+	if len(batches) == 0 {
+		return nil, total, nil
+	}
+
+	ids := make([]uint, 0, len(batches))
+	for _, b := range batches {
+		ids = append(ids, b.ID)
+	}
+	var uses []struct {
+		BatchID uint
+		Uses    int64
+	}
+	if err := r.db.Model(&models.Code{}).
+		Select("batch_id, COALESCE(SUM(use_count), 0) AS uses").
+		Where("batch_id IN ?", ids).
+		Group("batch_id").
+		Scan(&uses).Error; err != nil {
+		return nil, 0, err
+	}
+	usesByBatch := make(map[uint]int64, len(uses))
+	for _, u := range uses {
+		usesByBatch[u.BatchID] = u.Uses
+	}
+
+	infos := make([]CodeBatchInfo, 0, len(batches))
+	for _, b := range batches {
+		infos = append(infos, CodeBatchInfo{CodeBatch: b, Uses: usesByBatch[b.ID]})
+	}
+	return infos, total, nil
+}
+
+// RevokeBatch 作废批次及其所有注册码，返回作废的注册码数量
+func (r *CodeRepository) RevokeBatch(id uint, at time.Time) (int64, error) {
+	var revoked int64
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.CodeBatch{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", at)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		result = tx.Model(&models.Code{}).
+			Where("batch_id = ? AND revoked_at IS NULL", id).
+			Update("revoked_at", at)
+		revoked = result.RowsAffected
+		return result.Error
+	})
+	return revoked, err
 }
 
 // GetByCreator 获取某用户创建的所有注册码
@@ -0,0 +1,84 @@
+// Package repository 附加服务器账户数据仓库
+package repository
+
+import (
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// EmbyAccountRepository 附加服务器账户仓库
+type EmbyAccountRepository struct {
+	db *gorm.DB
+}
+
+// NewEmbyAccountRepository 创建附加服务器账户仓库
+func NewEmbyAccountRepository() *EmbyAccountRepository {
+	return &EmbyAccountRepository{db: database.GetDB()}
+}
+
+// ListByTG 获取用户在所有附加服务器上的账户
+func (r *EmbyAccountRepository) ListByTG(tg int64) ([]models.EmbyAccount, error) {
+	var accounts []models.EmbyAccount
+	err := r.db.Where("tg = ?", tg).Order("id").Find(&accounts).Error
+	return accounts, err
+}
+
+// GetByEmbyID 按服务器和 Emby 用户 ID 获取账户
+func (r *EmbyAccountRepository) GetByEmbyID(server, embyID string) (*models.EmbyAccount, error) {
+	var account models.EmbyAccount
+	if err := r.db.Where("server = ? AND embyid = ?", server, embyID).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetByName 按服务器和用户名获取账户
+func (r *EmbyAccountRepository) GetByName(server, name string) (*models.EmbyAccount, error) {
+	var account models.EmbyAccount
+	if err := r.db.Where("server = ? AND name = ?", server, name).First(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// Create 创建账户记录
+func (r *EmbyAccountRepository) Create(account *models.EmbyAccount) error {
+	return r.db.Create(account).Error
+}
+
+// SetDisabled 更新账户禁用状态
+func (r *EmbyAccountRepository) SetDisabled(id uint, disabled bool) error {
+	return r.db.Model(&models.EmbyAccount{}).Where("id = ?", id).Update("disabled", disabled).Error
+}
+
+// SetPassword 更新账户密码记录
+func (r *EmbyAccountRepository) SetPassword(id uint, pwd string) error {
+	return r.db.Model(&models.EmbyAccount{}).Where("id = ?", id).Update("pwd", pwd).Error
+}
+
+// Delete 删除账户记录
+func (r *EmbyAccountRepository) Delete(id uint) error {
+	return r.db.Delete(&models.EmbyAccount{}, id).Error
+}
+
+// CountByServer 统计各服务器的账户数
+func (r *EmbyAccountRepository) CountByServer() (map[string]int64, error) {
+	var rows []struct {
+		Server string
+		Count  int64
+	}
+	err := r.db.Model(&models.EmbyAccount{}).
+		Select("server, COUNT(*) AS count").
+		Group("server").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Server] = row.Count
+	}
+	return counts, nil
+}
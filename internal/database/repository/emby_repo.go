@@ -2,6 +2,8 @@
 package repository
 
 import (
+	"time"
+
 	"github.com/smysle/sakura-embyboss-go/internal/database"
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"gorm.io/gorm"
@@ -32,6 +34,16 @@ func (r *EmbyRepository) GetByTG(tg int64) (*models.Emby, error) {
 	return &emby, nil
 }
 
+// GetByTGs 根据多个 TG ID 获取用户
+func (r *EmbyRepository) GetByTGs(tgs []int64) ([]models.Emby, error) {
+	var users []models.Emby
+	if len(tgs) == 0 {
+		return users, nil
+	}
+	err := r.db.Where("tg IN ?", tgs).Find(&users).Error
+	return users, err
+}
+
 // GetByEmbyID 根据 Emby ID 获取用户
 func (r *EmbyRepository) GetByEmbyID(embyID string) (*models.Emby, error) {
 	var emby models.Emby
@@ -72,6 +84,52 @@ func (r *EmbyRepository) UpdateFields(tg int64, updates map[string]interface{})
 	return r.db.Model(&models.Emby{}).Where("tg = ?", tg).Updates(updates).Error
 }
 
+// lifecycleResetFields 重置生命周期状态的字段
+func lifecycleResetFields() map[string]interface{} {
+	return map[string]interface{}{
+		"status":      models.StatusActive,
+		"grace_at":    nil,
+		"disabled_at": nil,
+		"frozen_at":   nil,
+		"removed_at":  nil,
+	}
+}
+
+// ResetLifecycle 将用户恢复为正常生命周期状态（续期恢复时）
+func (r *EmbyRepository) ResetLifecycle(tg int64) error {
+	return r.UpdateFields(tg, lifecycleResetFields())
+}
+
+// BindAccount 新建或绑定 Emby 账户时更新用户记录并重置生命周期状态
+// 曾被生命周期删除的用户重新注册后从正常状态开始计算
+func (r *EmbyRepository) BindAccount(tg int64, updates map[string]interface{}) error {
+	fields := lifecycleResetFields()
+	for k, v := range updates {
+		fields[k] = v
+	}
+	return r.UpdateFields(tg, fields)
+}
+
+// AddRepairCards 增加补签卡
+func (r *EmbyRepository) AddRepairCards(tg int64, n int) error {
+	return r.db.Model(&models.Emby{}).Where("tg = ?", tg).
+		Update("repair_cards", gorm.Expr("repair_cards + ?", n)).Error
+}
+
+// UseRepairCard 消耗一张补签卡，没有补签卡时返回 false
+func (r *EmbyRepository) UseRepairCard(tg int64) (bool, error) {
+	result := r.db.Model(&models.Emby{}).Where("tg = ? AND repair_cards > 0", tg).
+		Update("repair_cards", gorm.Expr("repair_cards - 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetTopStreaks 获取自 since 以来签到过的用户中连续签到天数最多的用户
+func (r *EmbyRepository) GetTopStreaks(since time.Time, limit int) ([]models.Emby, error) {
+	var users []models.Emby
+	err := r.db.Where("ch >= ? AND ck > 0", since).Order("ck DESC").Limit(limit).Find(&users).Error
+	return users, err
+}
+
 // Delete 删除用户
 func (r *EmbyRepository) Delete(tg int64) error {
 	return r.db.Delete(&models.Emby{}, "tg = ?", tg).Error
@@ -136,26 +194,6 @@ func (r *EmbyRepository) CountStats() (total int64, withEmby int64, whitelist in
 	return
 }
 
-// BatchUpdateIV 批量更新邀请次数
-func (r *EmbyRepository) BatchUpdateIV(updates []struct {
-	TG int64
-	Iv int
-}) error {
-	tx := r.db.Begin()
-	for _, u := range updates {
-		if err := tx.Model(&models.Emby{}).Where("tg = ?", u.TG).Update("iv", u.Iv).Error; err != nil {
-			tx.Rollback()
-			return err
-		}
-	}
-	return tx.Commit().Error
-}
-
-// ClearAllIV 清空所有用户的邀请次数
-func (r *EmbyRepository) ClearAllIV() error {
-	return r.db.Model(&models.Emby{}).Where("1 = 1").Update("iv", 0).Error
-}
-
 // Exists 检查用户是否存在
 func (r *EmbyRepository) Exists(tg int64) bool {
 	var count int64
@@ -281,3 +319,61 @@ func (r *EmbyRepository) GetWhitelistUsers(page, pageSize int) ([]models.Emby, i
 	err := query.Order("tg DESC").Offset(offset).Limit(pageSize).Find(&embies).Error
 	return embies, total, err
 }
+
+// BroadcastFilter 广播受众查询条件（零值表示不限），已屏蔽 Bot 的用户始终排除
+type BroadcastFilter struct {
+	Levels     []models.UserLevel
+	HasEmby    *bool
+	ExpireFrom *time.Time // ex >= ExpireFrom
+	ExpireTo   *time.Time // ex < ExpireTo
+}
+
+// ListForBroadcast 按条件获取广播受众（按 TG ID 排序）
+func (r *EmbyRepository) ListForBroadcast(f BroadcastFilter) ([]models.Emby, error) {
+	query := r.db.Where("blocked_at IS NULL")
+	if len(f.Levels) > 0 {
+		query = query.Where("lv IN ?", f.Levels)
+	}
+	if f.HasEmby != nil {
+		if *f.HasEmby {
+			query = query.Where("embyid IS NOT NULL AND embyid != ''")
+		} else {
+			query = query.Where("embyid IS NULL OR embyid = ''")
+		}
+	}
+	if f.ExpireFrom != nil {
+		query = query.Where("ex >= ?", *f.ExpireFrom)
+	}
+	if f.ExpireTo != nil {
+		query = query.Where("ex < ?", *f.ExpireTo)
+	}
+
+	var embies []models.Emby
+	err := query.Order("tg ASC").Find(&embies).Error
+	return embies, err
+}
+
+// MarkBlocked 标记用户已屏蔽 Bot
+func (r *EmbyRepository) MarkBlocked(tg int64, at time.Time) error {
+	return r.db.Model(&models.Emby{}).Where("tg = ? AND blocked_at IS NULL", tg).Update("blocked_at", at).Error
+}
+
+// ClearBlocked 用户重新与 Bot 交互后取消屏蔽标记
+func (r *EmbyRepository) ClearBlocked(tg int64) error {
+	return r.db.Model(&models.Emby{}).Where("tg = ? AND blocked_at IS NOT NULL", tg).Update("blocked_at", nil).Error
+}
+
+// ListBlocked 分页获取已屏蔽 Bot 的用户（按标记时间倒序）
+func (r *EmbyRepository) ListBlocked(page, pageSize int) ([]models.Emby, int64, error) {
+	var embies []models.Emby
+	var total int64
+
+	query := r.db.Model(&models.Emby{}).Where("blocked_at IS NOT NULL")
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("blocked_at DESC").Offset(offset).Limit(pageSize).Find(&embies).Error
+	return embies, total, err
+}
@@ -0,0 +1,41 @@
+// Package repository 到期预警记录数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExpiryWarningRepository 到期预警记录仓库
+type ExpiryWarningRepository struct {
+	db *gorm.DB
+}
+
+// NewExpiryWarningRepository 创建到期预警记录仓库
+func NewExpiryWarningRepository() *ExpiryWarningRepository {
+	return &ExpiryWarningRepository{db: database.GetDB()}
+}
+
+// Exists 判断是否已发送过该预警
+func (r *ExpiryWarningRepository) Exists(tg int64, expiryAt time.Time, leadDays int) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.ExpiryWarning{}).
+		Where("tg = ? AND expiry_at = ? AND lead_days = ?", tg, expiryAt, leadDays).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Create 记录已发送的预警（重复记录忽略）
+func (r *ExpiryWarningRepository) Create(warning *models.ExpiryWarning) error {
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(warning).Error
+}
+
+// DeleteBefore 删除到期时间早于指定时间的记录
+func (r *ExpiryWarningRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("expiry_at < ?", before).Delete(&models.ExpiryWarning{})
+	return result.RowsAffected, result.Error
+}
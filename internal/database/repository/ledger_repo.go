@@ -0,0 +1,137 @@
+// Package repository 积分流水数据仓库
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrBalanceNotEnough 余额不足（条件更新未命中）
+var ErrBalanceNotEnough = errors.New("余额不足")
+
+// LedgerRepository 积分流水仓库
+type LedgerRepository struct {
+	db *gorm.DB
+}
+
+// NewLedgerRepository 创建积分流水仓库
+func NewLedgerRepository() *LedgerRepository {
+	return &LedgerRepository{db: database.GetDB()}
+}
+
+// Apply 在同一事务中依次应用余额变动并写入流水
+// 每条变动都使用条件更新（余额不得为负），任意一条失败则整体回滚
+func (r *LedgerRepository) Apply(entries []*models.PointsLedger) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		return applyLedger(tx, entries)
+	})
+}
+
+// DebitUpTo 扣减余额，余额不足时扣到 0（entry.Amount 为负数）
+// 在事务内锁定用户行后按当前余额计算实际扣减数额并写回 entry.Amount，余额为 0 时不扣减并返回 false
+func (r *LedgerRepository) DebitUpTo(entry *models.PointsLedger) (bool, error) {
+	column, err := currencyColumn(entry.Currency)
+	if err != nil {
+		return false, err
+	}
+
+	debited := false
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		var balances []int
+		if err := tx.Model(&models.Emby{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("tg = ?", entry.TG).Pluck(column, &balances).Error; err != nil {
+			return err
+		}
+		if len(balances) == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if balances[0] <= 0 {
+			return nil
+		}
+		if -entry.Amount > balances[0] {
+			entry.Amount = -balances[0]
+		}
+		debited = true
+		return applyLedger(tx, []*models.PointsLedger{entry})
+	})
+	return debited, err
+}
+
+// applyLedger 在事务 tx 中依次应用余额变动并写入流水
+func applyLedger(tx *gorm.DB, entries []*models.PointsLedger) error {
+	now := time.Now()
+	for _, entry := range entries {
+		column, err := currencyColumn(entry.Currency)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.Emby{}).
+			Where("tg = ? AND "+column+" + ? >= 0", entry.TG, entry.Amount).
+			Update(column, gorm.Expr(column+" + ?", entry.Amount))
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&models.Emby{}).Where("tg = ?", entry.TG).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return gorm.ErrRecordNotFound
+			}
+			return ErrBalanceNotEnough
+		}
+
+		var balance int
+		if err := tx.Model(&models.Emby{}).Select(column).Where("tg = ?", entry.TG).Scan(&balance).Error; err != nil {
+			return err
+		}
+
+		entry.Balance = balance
+		entry.CreatedAt = now
+		if err := tx.Create(entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListByTG 分页获取用户流水（按时间倒序）
+func (r *LedgerRepository) ListByTG(tg int64, page, pageSize int) ([]models.PointsLedger, int64, error) {
+	var entries []models.PointsLedger
+	var total int64
+
+	query := r.db.Model(&models.PointsLedger{}).Where("tg = ?", tg)
+
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&entries).Error
+	return entries, total, err
+}
+
+// GetByRefID 根据关联业务 ID 获取流水
+func (r *LedgerRepository) GetByRefID(refID string) ([]models.PointsLedger, error) {
+	var entries []models.PointsLedger
+	err := r.db.Where("ref_id = ?", refID).Order("id ASC").Find(&entries).Error
+	return entries, err
+}
+
+// currencyColumn 将余额类型映射为 emby 表列名（仅允许白名单列）
+func currencyColumn(currency models.Currency) (string, error) {
+	switch currency {
+	case models.CurrencyScore, models.CurrencyCoin:
+		return string(currency), nil
+	default:
+		return "", fmt.Errorf("未知的余额类型: %s", currency)
+	}
+}
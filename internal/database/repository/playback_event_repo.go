@@ -0,0 +1,45 @@
+// Package repository Emby Webhook 事件数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// PlaybackEventRepository Webhook 事件仓库
+type PlaybackEventRepository struct {
+	db *gorm.DB
+}
+
+// NewPlaybackEventRepository 创建 Webhook 事件仓库
+func NewPlaybackEventRepository() *PlaybackEventRepository {
+	return &PlaybackEventRepository{db: database.GetDB()}
+}
+
+// Create 创建事件记录
+func (r *PlaybackEventRepository) Create(event *models.PlaybackEvent) error {
+	return r.db.Create(event).Error
+}
+
+// GetRecentByUser 获取用户最近的事件
+func (r *PlaybackEventRepository) GetRecentByUser(embyUserID string, limit int) ([]models.PlaybackEvent, error) {
+	var events []models.PlaybackEvent
+	err := r.db.Where("emby_user_id = ?", embyUserID).Order("id DESC").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// GetFiltered 获取指定时间之后命中过滤规则的事件
+func (r *PlaybackEventRepository) GetFiltered(since time.Time) ([]models.PlaybackEvent, error) {
+	var events []models.PlaybackEvent
+	err := r.db.Where("filtered = ? AND created_at >= ?", true, since).Order("id DESC").Find(&events).Error
+	return events, err
+}
+
+// DeleteBefore 删除指定时间之前的事件
+func (r *PlaybackEventRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("created_at < ?", before).Delete(&models.PlaybackEvent{})
+	return result.RowsAffected, result.Error
+}
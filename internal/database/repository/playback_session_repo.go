@@ -0,0 +1,221 @@
+// Package repository 本地播放记录数据仓库
+package repository
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// PlaybackSessionRepository 本地播放记录仓库
+type PlaybackSessionRepository struct {
+	db *gorm.DB
+}
+
+// NewPlaybackSessionRepository 创建本地播放记录仓库
+func NewPlaybackSessionRepository() *PlaybackSessionRepository {
+	return &PlaybackSessionRepository{db: database.GetDB()}
+}
+
+// Create 创建播放记录
+func (r *PlaybackSessionRepository) Create(session *models.PlaybackSession) error {
+	return r.db.Create(session).Error
+}
+
+// Save 保存播放记录
+func (r *PlaybackSessionRepository) Save(session *models.PlaybackSession) error {
+	return r.db.Save(session).Error
+}
+
+// GetOpen 获取会话中指定媒体仍在播放的记录
+func (r *PlaybackSessionRepository) GetOpen(sessionID, itemID string) (*models.PlaybackSession, error) {
+	var session models.PlaybackSession
+	err := r.db.Where("session_id = ? AND item_id = ? AND stopped_at IS NULL", sessionID, itemID).
+		Order("id DESC").
+		First(&session).Error
+	if err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ListOpen 获取所有仍在播放的记录
+func (r *PlaybackSessionRepository) ListOpen() ([]models.PlaybackSession, error) {
+	var sessions []models.PlaybackSession
+	err := r.db.Where("stopped_at IS NULL").Order("id").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListOpenBySession 获取会话中仍在播放的记录
+func (r *PlaybackSessionRepository) ListOpenBySession(sessionID string) ([]models.PlaybackSession, error) {
+	var sessions []models.PlaybackSession
+	err := r.db.Where("session_id = ? AND stopped_at IS NULL", sessionID).Order("id").Find(&sessions).Error
+	return sessions, err
+}
+
+// ListByUser 获取用户指定时间以来开始的播放记录，按开始时间排序
+func (r *PlaybackSessionRepository) ListByUser(embyUserID string, since time.Time) ([]models.PlaybackSession, error) {
+	var sessions []models.PlaybackSession
+	err := r.db.Where("emby_user_id = ? AND started_at >= ?", embyUserID, since).
+		Order("started_at").
+		Find(&sessions).Error
+	return sessions, err
+}
+
+// PlaybackUserStat 按用户汇总的播放统计
+type PlaybackUserStat struct {
+	EmbyUserID string
+	UserName   string
+	PlayCount  int
+	TotalTime  int64 // 秒
+}
+
+// UserStats 统计时间范围内开始的播放，按播放时长降序
+func (r *PlaybackSessionRepository) UserStats(start, end time.Time) ([]PlaybackUserStat, error) {
+	var stats []PlaybackUserStat
+	err := r.db.Model(&models.PlaybackSession{}).
+		Select("emby_user_id, MAX(user_name) AS user_name, COUNT(*) AS play_count, SUM(duration) AS total_time").
+		Where("started_at >= ? AND started_at < ? AND emby_user_id <> ''", start, end).
+		Group("emby_user_id").
+		Order("total_time DESC").
+		Scan(&stats).Error
+	return stats, err
+}
+
+// PlaybackSessionFilter 审计查询条件，零值字段不参与过滤
+type PlaybackSessionFilter struct {
+	EmbyUserID string
+	ClientIP   string    // 精确匹配
+	Device     string    // 设备名模糊匹配
+	Client     string    // 客户端名模糊匹配
+	ItemID     string    // 媒体 ID 精确匹配
+	ItemName   string    // 媒体名模糊匹配
+	Since      time.Time // 开始时间下限（含）
+	Until      time.Time // 开始时间上限（不含）
+}
+
+// PlaybackSessionGroup 按用户、设备、客户端、IP 分组的审计结果
+type PlaybackSessionGroup struct {
+	EmbyUserID   string
+	UserName     string
+	DeviceName   string
+	Client       string
+	ClientIP     string
+	LastActivity time.Time
+	Count        int
+}
+
+// Audit 按条件分组查询播放记录，按最后活动时间降序
+func (r *PlaybackSessionRepository) Audit(filter PlaybackSessionFilter, limit int) ([]PlaybackSessionGroup, error) {
+	query := r.db.Model(&models.PlaybackSession{}).
+		Select("emby_user_id, MAX(user_name) AS user_name, device_name, client, client_ip, " +
+			"MAX(started_at) AS last_activity, COUNT(*) AS count")
+
+	if filter.EmbyUserID != "" {
+		query = query.Where("emby_user_id = ?", filter.EmbyUserID)
+	}
+	if filter.ClientIP != "" {
+		query = query.Where("client_ip = ?", filter.ClientIP)
+	}
+	if filter.Device != "" {
+		query = query.Where("device_name LIKE ?", "%"+escapeLike(filter.Device)+"%")
+	}
+	if filter.Client != "" {
+		query = query.Where("client LIKE ?", "%"+escapeLike(filter.Client)+"%")
+	}
+	if filter.ItemID != "" {
+		query = query.Where("item_id = ?", filter.ItemID)
+	}
+	if filter.ItemName != "" {
+		query = query.Where("item_name LIKE ?", "%"+escapeLike(filter.ItemName)+"%")
+	}
+	if !filter.Since.IsZero() {
+		query = query.Where("started_at >= ?", filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where("started_at < ?", filter.Until)
+	}
+
+	var groups []PlaybackSessionGroup
+	err := query.Group("emby_user_id, device_name, client, client_ip").
+		Order("last_activity DESC").
+		Limit(limit).
+		Scan(&groups).Error
+	return groups, err
+}
+
+// 共用检测维度对应的分组列与显示列
+const (
+	ShareByIP     = "client_ip" // 按 IP
+	ShareByDevice = "device_id" // 按设备 ID
+)
+
+// PlaybackSessionShare 单个用户在某个 IP / 设备上的播放次数
+type PlaybackSessionShare struct {
+	Key          string
+	Label        string
+	EmbyUserID   string
+	UserName     string
+	Count        int
+	LastActivity time.Time
+}
+
+// Shares 按 IP 或设备与用户分组统计指定时间以来的播放
+func (r *PlaybackSessionRepository) Shares(by string, since time.Time) ([]PlaybackSessionShare, error) {
+	label := "client_ip"
+	switch by {
+	case ShareByIP:
+	case ShareByDevice:
+		label = "device_name"
+	default:
+		return nil, fmt.Errorf("未知的共用检测维度: %s", by)
+	}
+
+	var shares []PlaybackSessionShare
+	err := r.db.Model(&models.PlaybackSession{}).
+		Select(fmt.Sprintf("%s AS `key`, MAX(%s) AS label, emby_user_id, MAX(user_name) AS user_name, "+
+			"COUNT(*) AS count, MAX(started_at) AS last_activity", by, label)).
+		Where(fmt.Sprintf("%s <> '' AND emby_user_id <> '' AND started_at >= ?", by), since).
+		Group(by + ", emby_user_id").
+		Scan(&shares).Error
+	return shares, err
+}
+
+// LastPlayed 获取各用户最近一次播放时间（key 为 Emby 用户 ID）
+func (r *PlaybackSessionRepository) LastPlayed() (map[string]time.Time, error) {
+	var rows []struct {
+		EmbyUserID string
+		LastSeen   time.Time
+	}
+	err := r.db.Model(&models.PlaybackSession{}).
+		Select("emby_user_id, MAX(last_seen_at) AS last_seen").
+		Where("emby_user_id <> ''").
+		Group("emby_user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	last := make(map[string]time.Time, len(rows))
+	for _, row := range rows {
+		last[row.EmbyUserID] = row.LastSeen
+	}
+	return last, nil
+}
+
+// DeleteBefore 删除指定时间之前结束的播放记录
+func (r *PlaybackSessionRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("stopped_at IS NOT NULL AND stopped_at < ?", before).Delete(&models.PlaybackSession{})
+	return result.RowsAffected, result.Error
+}
+
+// escapeLike 转义 LIKE 通配符，关键词按字面匹配
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,32 @@
+// Package repository 榜单消息记录数据仓库
+package repository
+
+import (
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// RankMessageRepository 榜单消息记录仓库
+type RankMessageRepository struct {
+	db *gorm.DB
+}
+
+// NewRankMessageRepository 创建榜单消息记录仓库
+func NewRankMessageRepository() *RankMessageRepository {
+	return &RankMessageRepository{db: database.GetDB()}
+}
+
+// Get 获取指定榜单最近一次推送的消息
+func (r *RankMessageRepository) Get(kind string) (*models.RankMessage, error) {
+	var msg models.RankMessage
+	if err := r.db.Where("kind = ?", kind).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Save 保存榜单消息（存在则覆盖）
+func (r *RankMessageRepository) Save(msg *models.RankMessage) error {
+	return r.db.Save(msg).Error
+}
@@ -37,14 +37,31 @@ func (r *RedEnvelopeRepository) Update(envelope *models.RedEnvelope) error {
 	return r.db.Save(envelope).Error
 }
 
-// UpdateRemain 更新剩余金额和数量（原子操作）
-func (r *RedEnvelopeRepository) UpdateRemain(uuid string, amount int) error {
-	return r.db.Model(&models.RedEnvelope{}).
-		Where("uuid = ? AND remain_count > 0 AND remain_amount >= ?", uuid, amount).
-		Updates(map[string]interface{}{
-			"remain_amount": gorm.Expr("remain_amount - ?", amount),
-			"remain_count":  gorm.Expr("remain_count - 1"),
-		}).Error
+// Grab 在同一事务中扣减红包剩余、写入领取记录并为领取者入账（条件更新）
+// 红包剩余不足（已被抢完）时返回 false 且不做任何修改
+func (r *RedEnvelopeRepository) Grab(record *models.RedEnvelopeRecord, entry *models.PointsLedger) (bool, error) {
+	grabbed := false
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RedEnvelope{}).
+			Where("uuid = ? AND remain_count > 0 AND remain_amount >= ?", record.EnvelopeUUID, record.Amount).
+			Updates(map[string]interface{}{
+				"remain_amount": gorm.Expr("remain_amount - ?", record.Amount),
+				"remain_count":  gorm.Expr("remain_count - 1"),
+			})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		if err := tx.Create(record).Error; err != nil {
+			return err
+		}
+		if err := applyLedger(tx, []*models.PointsLedger{entry}); err != nil {
+			return err
+		}
+		grabbed = true
+		return nil
+	})
+	return grabbed, err
 }
 
 // SetFinished 设置红包为已完成
@@ -61,11 +78,6 @@ func (r *RedEnvelopeRepository) SetExpired(uuid string) error {
 		Update("status", "expired").Error
 }
 
-// CreateRecord 创建领取记录
-func (r *RedEnvelopeRepository) CreateRecord(record *models.RedEnvelopeRecord) error {
-	return r.db.Create(record).Error
-}
-
 // GetRecordsByEnvelope 获取红包的所有领取记录
 func (r *RedEnvelopeRepository) GetRecordsByEnvelope(uuid string) ([]models.RedEnvelopeRecord, error) {
 	var records []models.RedEnvelopeRecord
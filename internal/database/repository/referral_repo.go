@@ -0,0 +1,114 @@
+// Package repository 邀请关系数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ReferralRepository 邀请关系仓库
+type ReferralRepository struct {
+	db *gorm.DB
+}
+
+// NewReferralRepository 创建邀请关系仓库
+func NewReferralRepository() *ReferralRepository {
+	return &ReferralRepository{db: database.GetDB()}
+}
+
+// ReferralStats 邀请统计
+type ReferralStats struct {
+	InviterTG  int64 `json:"inviter_tg"`
+	Total      int64 `json:"total"`
+	Registered int64 `json:"registered"`
+	Renewed    int64 `json:"renewed"`
+}
+
+// referralStatsSelect 邀请统计的聚合列
+const referralStatsSelect = "inviter_tg, COUNT(*) AS total, " +
+	"SUM(CASE WHEN registered_at IS NOT NULL THEN 1 ELSE 0 END) AS registered, " +
+	"SUM(CASE WHEN renewed_at IS NOT NULL THEN 1 ELSE 0 END) AS renewed"
+
+// Create 创建邀请关系，被邀请人已有邀请人时返回 false
+func (r *ReferralRepository) Create(referral *models.Referral) (bool, error) {
+	result := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(referral)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetByInvitee 获取被邀请人的邀请关系
+func (r *ReferralRepository) GetByInvitee(tg int64) (*models.Referral, error) {
+	var referral models.Referral
+	if err := r.db.Where("invitee_tg = ?", tg).First(&referral).Error; err != nil {
+		return nil, err
+	}
+	return &referral, nil
+}
+
+// ClaimRegistered 标记被邀请人已注册，已标记过时返回 false
+func (r *ReferralRepository) ClaimRegistered(invitee int64, at time.Time) (bool, error) {
+	result := r.db.Model(&models.Referral{}).
+		Where("invitee_tg = ? AND registered_at IS NULL", invitee).
+		Update("registered_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClaimRenewed 标记被邀请人已首次续期（须已注册），已标记过时返回 false
+func (r *ReferralRepository) ClaimRenewed(invitee int64, at time.Time) (bool, error) {
+	result := r.db.Model(&models.Referral{}).
+		Where("invitee_tg = ? AND registered_at IS NOT NULL AND renewed_at IS NULL", invitee).
+		Update("renewed_at", at)
+	return result.RowsAffected > 0, result.Error
+}
+
+// ListByInviter 分页获取邀请人邀请的用户（按邀请时间倒序）
+func (r *ReferralRepository) ListByInviter(inviter int64, page, pageSize int) ([]models.Referral, int64, error) {
+	var referrals []models.Referral
+	var total int64
+
+	query := r.db.Model(&models.Referral{}).Where("inviter_tg = ?", inviter)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	offset := (page - 1) * pageSize
+	err := query.Order("id DESC").Offset(offset).Limit(pageSize).Find(&referrals).Error
+	return referrals, total, err
+}
+
+// ListByInviters 获取多个邀请人的全部下级（按邀请时间排序）
+func (r *ReferralRepository) ListByInviters(inviters []int64) ([]models.Referral, error) {
+	var referrals []models.Referral
+	if len(inviters) == 0 {
+		return referrals, nil
+	}
+	err := r.db.Where("inviter_tg IN ?", inviters).Order("id").Find(&referrals).Error
+	return referrals, err
+}
+
+// StatsByInviter 获取邀请人的邀请统计
+func (r *ReferralRepository) StatsByInviter(inviter int64) (*ReferralStats, error) {
+	stats := &ReferralStats{InviterTG: inviter}
+	err := r.db.Model(&models.Referral{}).
+		Select(referralStatsSelect).
+		Where("inviter_tg = ?", inviter).
+		Group("inviter_tg").
+		Scan(stats).Error
+	stats.InviterTG = inviter
+	return stats, err
+}
+
+// TopInviters 按邀请人数排序的邀请人统计
+func (r *ReferralRepository) TopInviters(limit int) ([]ReferralStats, error) {
+	var stats []ReferralStats
+	err := r.db.Model(&models.Referral{}).
+		Select(referralStatsSelect).
+		Group("inviter_tg").
+		Order("total DESC").
+		Limit(limit).
+		Scan(&stats).Error
+	return stats, err
+}
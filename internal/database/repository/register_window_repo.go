@@ -0,0 +1,73 @@
+// Package repository 定时注册窗口数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// RegisterWindowRepository 定时注册窗口仓库
+type RegisterWindowRepository struct {
+	db *gorm.DB
+}
+
+// NewRegisterWindowRepository 创建定时注册窗口仓库
+func NewRegisterWindowRepository() *RegisterWindowRepository {
+	return &RegisterWindowRepository{db: database.GetDB()}
+}
+
+// Create 创建注册窗口
+func (r *RegisterWindowRepository) Create(w *models.RegisterWindow) error {
+	return r.db.Create(w).Error
+}
+
+// GetByID 获取注册窗口
+func (r *RegisterWindowRepository) GetByID(id uint) (*models.RegisterWindow, error) {
+	var w models.RegisterWindow
+	if err := r.db.First(&w, id).Error; err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// ListByStatus 获取指定状态的注册窗口，按开放时间排序
+func (r *RegisterWindowRepository) ListByStatus(status ...models.RegisterWindowStatus) ([]models.RegisterWindow, error) {
+	var windows []models.RegisterWindow
+	err := r.db.Where("status IN ?", status).Order("opens_at ASC, id ASC").Find(&windows).Error
+	return windows, err
+}
+
+// Open 把等待中的窗口切换为开放，状态不符时返回 false
+func (r *RegisterWindowRepository) Open(id uint, now time.Time) (bool, error) {
+	result := r.db.Model(&models.RegisterWindow{}).
+		Where("id = ? AND status = ?", id, models.RegisterWindowScheduled).
+		Updates(map[string]interface{}{"status": models.RegisterWindowOpen, "opened_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Close 关闭等待中或开放中的窗口，已关闭时返回 false（保证关闭通知只发送一次）
+func (r *RegisterWindowRepository) Close(id uint, reason string, now time.Time) (bool, error) {
+	result := r.db.Model(&models.RegisterWindow{}).
+		Where("id = ? AND status IN ?", id, []models.RegisterWindowStatus{models.RegisterWindowScheduled, models.RegisterWindowOpen}).
+		Updates(map[string]interface{}{"status": models.RegisterWindowClosed, "close_reason": reason, "closed_at": now})
+	return result.RowsAffected > 0, result.Error
+}
+
+// ClaimSeat 原子占用一个名额，窗口未开放或名额已满时返回 false
+func (r *RegisterWindowRepository) ClaimSeat(id uint) (bool, error) {
+	result := r.db.Model(&models.RegisterWindow{}).
+		Where("id = ? AND status = ? AND (seats = 0 OR taken < seats)", id, models.RegisterWindowOpen).
+		Update("taken", gorm.Expr("taken + 1"))
+	return result.RowsAffected > 0, result.Error
+}
+
+// ReleaseSeat 归还开放中窗口的名额（创建账户失败时），窗口已关闭时返回 false
+func (r *RegisterWindowRepository) ReleaseSeat(id uint) (bool, error) {
+	result := r.db.Model(&models.RegisterWindow{}).
+		Where("id = ? AND status = ? AND taken > 0", id, models.RegisterWindowOpen).
+		Update("taken", gorm.Expr("taken - 1"))
+	return result.RowsAffected > 0, result.Error
+}
@@ -0,0 +1,45 @@
+// Package repository Bot 会话数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// SessionRepository Bot 会话仓库
+type SessionRepository struct {
+	db *gorm.DB
+}
+
+// NewSessionRepository 创建 Bot 会话仓库
+func NewSessionRepository() *SessionRepository {
+	return &SessionRepository{db: database.GetDB()}
+}
+
+// GetByTG 根据 TG ID 获取会话
+func (r *SessionRepository) GetByTG(tg int64) (*models.BotSession, error) {
+	var sess models.BotSession
+	if err := r.db.Where("tg = ?", tg).First(&sess).Error; err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+// Save 保存会话（存在则覆盖）
+func (r *SessionRepository) Save(sess *models.BotSession) error {
+	return r.db.Save(sess).Error
+}
+
+// Delete 删除会话
+func (r *SessionRepository) Delete(tg int64) error {
+	return r.db.Delete(&models.BotSession{}, "tg = ?", tg).Error
+}
+
+// DeleteBefore 删除指定时间之前未更新的会话
+func (r *SessionRepository) DeleteBefore(before time.Time) (int64, error) {
+	result := r.db.Where("updated_at < ?", before).Delete(&models.BotSession{})
+	return result.RowsAffected, result.Error
+}
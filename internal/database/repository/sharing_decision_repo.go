@@ -0,0 +1,66 @@
+// Package repository 账户共享检测记录数据仓库
+package repository
+
+import (
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"gorm.io/gorm"
+)
+
+// SharingDecisionRepository 账户共享检测记录仓库
+type SharingDecisionRepository struct {
+	db *gorm.DB
+}
+
+// NewSharingDecisionRepository 创建账户共享检测记录仓库
+func NewSharingDecisionRepository() *SharingDecisionRepository {
+	return &SharingDecisionRepository{db: database.GetDB()}
+}
+
+// Create 创建检测记录
+func (r *SharingDecisionRepository) Create(decision *models.SharingDecision) error {
+	return r.db.Create(decision).Error
+}
+
+// GetByID 根据 ID 获取检测记录
+func (r *SharingDecisionRepository) GetByID(id uint) (*models.SharingDecision, error) {
+	var decision models.SharingDecision
+	if err := r.db.First(&decision, id).Error; err != nil {
+		return nil, err
+	}
+	return &decision, nil
+}
+
+// ReportedSince 指定时间以来是否上报过该用户
+func (r *SharingDecisionRepository) ReportedSince(tg int64, since time.Time) (bool, error) {
+	var count int64
+	err := r.db.Model(&models.SharingDecision{}).
+		Where("tg = ? AND created_at >= ?", tg, since).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Claim 将待处理记录标记为指定处理结果，已被处理时返回 false
+func (r *SharingDecisionRepository) Claim(id uint, action string, adminID int64, at time.Time) (bool, error) {
+	result := r.db.Model(&models.SharingDecision{}).
+		Where("id = ? AND action = ?", id, models.SharingActionPending).
+		Updates(map[string]interface{}{
+			"action":     action,
+			"admin_id":   adminID,
+			"decided_at": at,
+		})
+	return result.RowsAffected > 0, result.Error
+}
+
+// Release 处理失败时恢复为待处理
+func (r *SharingDecisionRepository) Release(id uint) error {
+	return r.db.Model(&models.SharingDecision{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"action":     models.SharingActionPending,
+			"admin_id":   0,
+			"decided_at": nil,
+		}).Error
+}
@@ -0,0 +1,306 @@
+// Package emby PlaybackActivity 查询构建器
+// 通过 user_usage_stats 插件的 submit_custom_query 查询 playback_reporting 的 SQLite 表，
+// 列名与别名走白名单，字面量经过校验与转义，LIMIT 有上限，调用方不再拼接 SQL
+package emby
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ActivityColumn PlaybackActivity 表的列
+type ActivityColumn string
+
+const (
+	ColDateCreated    ActivityColumn = "DateCreated"
+	ColUserID         ActivityColumn = "UserId"
+	ColItemID         ActivityColumn = "ItemId"
+	ColItemType       ActivityColumn = "ItemType"
+	ColItemName       ActivityColumn = "ItemName"
+	ColPlaybackMethod ActivityColumn = "PlaybackMethod"
+	ColClientName     ActivityColumn = "ClientName"
+	ColDeviceName     ActivityColumn = "DeviceName"
+	ColPlayDuration   ActivityColumn = "PlayDuration"
+	ColRemoteAddress  ActivityColumn = "RemoteAddress"
+)
+
+// activityColumns 允许查询的列
+var activityColumns = map[ActivityColumn]bool{
+	ColDateCreated:    true,
+	ColUserID:         true,
+	ColItemID:         true,
+	ColItemType:       true,
+	ColItemName:       true,
+	ColPlaybackMethod: true,
+	ColClientName:     true,
+	ColDeviceName:     true,
+	ColPlayDuration:   true,
+	ColRemoteAddress:  true,
+}
+
+// 查询限制
+const (
+	DefaultActivityLimit = 100  // 未指定 LIMIT 时的默认值
+	MaxActivityLimit     = 1000 // LIMIT 上限
+	maxLiteralLength     = 128  // 字符串字面量最大长度
+)
+
+// activityTimeLayout DateCreated 的存储格式
+const activityTimeLayout = "2006-01-02 15:04:05"
+
+var (
+	aliasPattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{0,31}$`)
+	idPattern    = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+)
+
+// ErrInvalidQuery 查询参数不合法
+var ErrInvalidQuery = errors.New("无效的查询参数")
+
+// ActivityQuery PlaybackActivity 查询构建器，出错后后续调用不再生效，由 Build 返回第一个错误
+type ActivityQuery struct {
+	selects []string
+	aliases map[string]bool
+	where   []string
+	groupBy []string
+	orderBy []string
+	limit   int
+	err     error
+}
+
+// NewActivityQuery 创建查询构建器
+func NewActivityQuery() *ActivityQuery {
+	return &ActivityQuery{aliases: make(map[string]bool), limit: DefaultActivityLimit}
+}
+
+// fail 记录第一个错误
+func (q *ActivityQuery) fail(format string, args ...interface{}) *ActivityQuery {
+	if q.err == nil {
+		q.err = fmt.Errorf("%w: %s", ErrInvalidQuery, fmt.Sprintf(format, args...))
+	}
+	return q
+}
+
+// column 校验列名
+func (q *ActivityQuery) column(col ActivityColumn) (string, bool) {
+	if !activityColumns[col] {
+		q.fail("不允许的列 %q", col)
+		return "", false
+	}
+	return string(col), true
+}
+
+// Columns 查询原始列
+func (q *ActivityQuery) Columns(cols ...ActivityColumn) *ActivityQuery {
+	for _, col := range cols {
+		if name, ok := q.column(col); ok {
+			q.selects = append(q.selects, name)
+			q.aliases[name] = true
+		}
+	}
+	return q
+}
+
+// aggregate 添加聚合列
+func (q *ActivityQuery) aggregate(expr, alias string) *ActivityQuery {
+	if !aliasPattern.MatchString(alias) || activityColumns[ActivityColumn(alias)] {
+		return q.fail("不允许的别名 %q", alias)
+	}
+	q.selects = append(q.selects, fmt.Sprintf("%s AS %s", expr, alias))
+	q.aliases[alias] = true
+	return q
+}
+
+// Count 统计行数
+func (q *ActivityQuery) Count(alias string) *ActivityQuery {
+	return q.aggregate("COUNT(1)", alias)
+}
+
+// CountDistinct 统计不同值的个数
+func (q *ActivityQuery) CountDistinct(col ActivityColumn, alias string) *ActivityQuery {
+	if name, ok := q.column(col); ok {
+		return q.aggregate(fmt.Sprintf("COUNT(DISTINCT %s)", name), alias)
+	}
+	return q
+}
+
+// Max 取最大值
+func (q *ActivityQuery) Max(col ActivityColumn, alias string) *ActivityQuery {
+	if name, ok := q.column(col); ok {
+		return q.aggregate(fmt.Sprintf("MAX(%s)", name), alias)
+	}
+	return q
+}
+
+// Sum 求和
+func (q *ActivityQuery) Sum(col ActivityColumn, alias string) *ActivityQuery {
+	if name, ok := q.column(col); ok {
+		return q.aggregate(fmt.Sprintf("SUM(%s)", name), alias)
+	}
+	return q
+}
+
+// Eq 精确匹配
+func (q *ActivityQuery) Eq(col ActivityColumn, value string) *ActivityQuery {
+	name, ok := q.column(col)
+	if !ok {
+		return q
+	}
+	lit, err := literalFor(col, value)
+	if err != nil {
+		return q.fail("%s: %v", col, err)
+	}
+	q.where = append(q.where, fmt.Sprintf("%s = %s", name, lit))
+	return q
+}
+
+// Contains 包含关键词（不区分大小写，% 与 _ 按字面匹配）
+func (q *ActivityQuery) Contains(col ActivityColumn, keyword string) *ActivityQuery {
+	name, ok := q.column(col)
+	if !ok {
+		return q
+	}
+	if err := validateText(keyword); err != nil {
+		return q.fail("%s: %v", col, err)
+	}
+	pattern := "%" + likeEscaper.Replace(keyword) + "%"
+	q.where = append(q.where, fmt.Sprintf(`%s LIKE %s ESCAPE '\'`, name, quote(pattern)))
+	return q
+}
+
+// Since DateCreated 不早于指定时间
+func (q *ActivityQuery) Since(t time.Time) *ActivityQuery {
+	if !t.IsZero() {
+		q.where = append(q.where, fmt.Sprintf("%s >= '%s'", ColDateCreated, t.Format(activityTimeLayout)))
+	}
+	return q
+}
+
+// Until DateCreated 早于指定时间
+func (q *ActivityQuery) Until(t time.Time) *ActivityQuery {
+	if !t.IsZero() {
+		q.where = append(q.where, fmt.Sprintf("%s < '%s'", ColDateCreated, t.Format(activityTimeLayout)))
+	}
+	return q
+}
+
+// NotEmpty 列不为空
+func (q *ActivityQuery) NotEmpty(col ActivityColumn) *ActivityQuery {
+	if name, ok := q.column(col); ok {
+		q.where = append(q.where, fmt.Sprintf("%s IS NOT NULL AND %s <> ''", name, name))
+	}
+	return q
+}
+
+// GroupBy 分组
+func (q *ActivityQuery) GroupBy(cols ...ActivityColumn) *ActivityQuery {
+	for _, col := range cols {
+		if name, ok := q.column(col); ok {
+			q.groupBy = append(q.groupBy, name)
+		}
+	}
+	return q
+}
+
+// OrderBy 排序，只能使用已查询的列或聚合别名
+func (q *ActivityQuery) OrderBy(name string, desc bool) *ActivityQuery {
+	if !q.aliases[name] {
+		return q.fail("不允许的排序字段 %q", name)
+	}
+	if desc {
+		name += " DESC"
+	}
+	q.orderBy = append(q.orderBy, name)
+	return q
+}
+
+// Limit 限制返回行数，超出上限时取上限
+func (q *ActivityQuery) Limit(n int) *ActivityQuery {
+	switch {
+	case n <= 0:
+		q.limit = DefaultActivityLimit
+	case n > MaxActivityLimit:
+		q.limit = MaxActivityLimit
+	default:
+		q.limit = n
+	}
+	return q
+}
+
+// Build 生成 SQL
+func (q *ActivityQuery) Build() (string, error) {
+	if q.err != nil {
+		return "", q.err
+	}
+	if len(q.selects) == 0 {
+		return "", fmt.Errorf("%w: 未指定查询列", ErrInvalidQuery)
+	}
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	sb.WriteString(strings.Join(q.selects, ", "))
+	sb.WriteString(" FROM PlaybackActivity")
+	if len(q.where) > 0 {
+		sb.WriteString(" WHERE ")
+		sb.WriteString(strings.Join(q.where, " AND "))
+	}
+	if len(q.groupBy) > 0 {
+		sb.WriteString(" GROUP BY ")
+		sb.WriteString(strings.Join(q.groupBy, ", "))
+	}
+	if len(q.orderBy) > 0 {
+		sb.WriteString(" ORDER BY ")
+		sb.WriteString(strings.Join(q.orderBy, ", "))
+	}
+	sb.WriteString(fmt.Sprintf(" LIMIT %d", q.limit))
+	return sb.String(), nil
+}
+
+// literalFor 按列类型校验值并生成字面量
+func literalFor(col ActivityColumn, value string) (string, error) {
+	switch col {
+	case ColUserID, ColItemID:
+		if !idPattern.MatchString(value) {
+			return "", fmt.Errorf("无效的 ID %q", value)
+		}
+	case ColRemoteAddress:
+		if net.ParseIP(value) == nil {
+			return "", fmt.Errorf("无效的 IP 地址 %q", value)
+		}
+	case ColDateCreated, ColPlayDuration:
+		return "", errors.New("该列不支持字符串匹配")
+	default:
+		if err := validateText(value); err != nil {
+			return "", err
+		}
+	}
+	return quote(value), nil
+}
+
+// validateText 校验文本字面量：非空、长度受限、不含控制字符
+func validateText(s string) error {
+	if s == "" {
+		return errors.New("值不能为空")
+	}
+	if len([]rune(s)) > maxLiteralLength {
+		return fmt.Errorf("值超过 %d 个字符", maxLiteralLength)
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return errors.New("值包含控制字符")
+		}
+	}
+	return nil
+}
+
+// quote 生成单引号字符串字面量
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// likeEscaper 转义 LIKE 通配符（配合 ESCAPE '\'）
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -0,0 +1,186 @@
+// Package emby PlaybackActivity 查询构建器测试
+package emby
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestActivityQuery_Build(t *testing.T) {
+	since := time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)
+	until := time.Date(2024, 3, 8, 0, 0, 0, 0, time.Local)
+
+	sql, err := NewActivityQuery().
+		Columns(ColUserID, ColDeviceName).
+		Max(ColDateCreated, "LastActivity").
+		Count("Plays").
+		Eq(ColRemoteAddress, "10.0.0.1").
+		Contains(ColClientName, "Infuse").
+		Since(since).
+		Until(until).
+		GroupBy(ColUserID, ColDeviceName).
+		OrderBy("LastActivity", true).
+		Limit(20).
+		Build()
+	if err != nil {
+		t.Fatalf("Build() 错误: %v", err)
+	}
+
+	want := "SELECT UserId, DeviceName, MAX(DateCreated) AS LastActivity, COUNT(1) AS Plays FROM PlaybackActivity" +
+		" WHERE RemoteAddress = '10.0.0.1' AND ClientName LIKE '%Infuse%' ESCAPE '\\'" +
+		" AND DateCreated >= '2024-03-01 00:00:00' AND DateCreated < '2024-03-08 00:00:00'" +
+		" GROUP BY UserId, DeviceName ORDER BY LastActivity DESC LIMIT 20"
+	if sql != want {
+		t.Errorf("Build() =\n%s\nwant\n%s", sql, want)
+	}
+}
+
+func TestActivityQuery_Limit(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		want  string
+	}{
+		{"未指定", 0, "LIMIT 100"},
+		{"负数", -5, "LIMIT 100"},
+		{"正常", 50, "LIMIT 50"},
+		{"超出上限", 1000000, "LIMIT 1000"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := NewActivityQuery().Columns(ColUserID).Limit(tt.limit).Build()
+			if err != nil {
+				t.Fatalf("Build() 错误: %v", err)
+			}
+			if !strings.HasSuffix(sql, tt.want) {
+				t.Errorf("Build() = %q, want suffix %q", sql, tt.want)
+			}
+		})
+	}
+}
+
+func TestActivityQuery_Injection(t *testing.T) {
+	tests := []struct {
+		name  string
+		build func() *ActivityQuery
+	}{
+		{"IP 字段注入", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Eq(ColRemoteAddress, "1.1.1.1' OR '1'='1")
+		}},
+		{"用户 ID 注入", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Eq(ColUserID, "abc'; DROP TABLE PlaybackActivity; --")
+		}},
+		{"媒体 ID 注入", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Eq(ColItemID, "1 OR 1=1")
+		}},
+		{"未知列", func() *ActivityQuery {
+			return NewActivityQuery().Columns("UserId FROM sqlite_master --")
+		}},
+		{"别名注入", func() *ActivityQuery {
+			return NewActivityQuery().Count("n FROM sqlite_master;--")
+		}},
+		{"别名与列名冲突", func() *ActivityQuery {
+			return NewActivityQuery().Count("UserId")
+		}},
+		{"排序字段注入", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).OrderBy("(SELECT 1)", false)
+		}},
+		{"排序未查询的列", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).OrderBy(string(ColItemName), false)
+		}},
+		{"关键词含控制字符", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Contains(ColDeviceName, "a\x00b")
+		}},
+		{"关键词为空", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Contains(ColDeviceName, "")
+		}},
+		{"关键词过长", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Contains(ColDeviceName, strings.Repeat("a", 200))
+		}},
+		{"数值列字符串匹配", func() *ActivityQuery {
+			return NewActivityQuery().Columns(ColUserID).Eq(ColPlayDuration, "0 OR 1=1")
+		}},
+		{"未指定查询列", func() *ActivityQuery {
+			return NewActivityQuery()
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := tt.build().Build()
+			if !errors.Is(err, ErrInvalidQuery) {
+				t.Errorf("期望 ErrInvalidQuery，实际 sql=%q err=%v", sql, err)
+			}
+		})
+	}
+}
+
+func TestActivityQuery_EscapesLiterals(t *testing.T) {
+	tests := []struct {
+		name    string
+		keyword string
+		want    string
+	}{
+		{"单引号", "it's'; DROP TABLE x; --", `DeviceName LIKE '%it''s''; DROP TABLE x; --%' ESCAPE '\'`},
+		{"通配符按字面匹配", "100%_done", `DeviceName LIKE '%100\%\_done%' ESCAPE '\'`},
+		{"反斜杠", `a\b`, `DeviceName LIKE '%a\\b%' ESCAPE '\'`},
+		{"中文", "客厅电视", `DeviceName LIKE '%客厅电视%' ESCAPE '\'`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sql, err := NewActivityQuery().Columns(ColUserID).Contains(ColDeviceName, tt.keyword).Build()
+			if err != nil {
+				t.Fatalf("Build() 错误: %v", err)
+			}
+			if !strings.Contains(sql, tt.want) {
+				t.Errorf("Build() = %q, want contains %q", sql, tt.want)
+			}
+			// 去掉合法的转义单引号后，字面量之外不应再出现单引号
+			if strings.Count(strings.ReplaceAll(sql, "''", ""), "'")%2 != 0 {
+				t.Errorf("单引号未配对: %q", sql)
+			}
+		})
+	}
+}
+
+func TestAuditQuery(t *testing.T) {
+	sql, err := auditQuery(AuditFilter{ItemID: "12345", Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.Local)})
+	if err != nil {
+		t.Fatalf("auditQuery() 错误: %v", err)
+	}
+	for _, want := range []string{
+		"SELECT UserId, DeviceName, ClientName, RemoteAddress, MAX(DateCreated) AS LastActivity, COUNT(1) AS ActivityCount",
+		"ItemId = '12345'",
+		"DateCreated >= '2024-01-01 00:00:00'",
+		"GROUP BY UserId, DeviceName, ClientName, RemoteAddress",
+		"LIMIT 100",
+	} {
+		if !strings.Contains(sql, want) {
+			t.Errorf("auditQuery() = %q, want contains %q", sql, want)
+		}
+	}
+
+	if _, err := auditQuery(AuditFilter{IP: "not-an-ip"}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("无效 IP 应返回 ErrInvalidQuery，实际 %v", err)
+	}
+}
+
+func TestSharedUsageQuery(t *testing.T) {
+	sql, err := sharedUsageQuery(SharedByDevice, time.Time{})
+	if err != nil {
+		t.Fatalf("sharedUsageQuery() 错误: %v", err)
+	}
+	want := "SELECT DeviceName, UserId, COUNT(1) AS Plays, MAX(DateCreated) AS LastActivity FROM PlaybackActivity" +
+		" WHERE DeviceName IS NOT NULL AND DeviceName <> '' GROUP BY DeviceName, UserId ORDER BY DeviceName LIMIT 1000"
+	if sql != want {
+		t.Errorf("sharedUsageQuery() =\n%s\nwant\n%s", sql, want)
+	}
+
+	if _, err := sharedUsageQuery("ItemName", time.Time{}); !errors.Is(err, ErrInvalidQuery) {
+		t.Errorf("未知维度应返回 ErrInvalidQuery，实际 %v", err)
+	}
+}
@@ -0,0 +1,307 @@
+// Package emby 播放审计
+// 基于 ActivityQuery 查询 PlaybackActivity，需要 user_usage_stats 与 playback_reporting 插件
+package emby
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// AuditResult 审计结果
+type AuditResult struct {
+	UserID        string
+	Username      string
+	DeviceName    string
+	ClientName    string
+	RemoteAddress string
+	LastActivity  time.Time
+	ActivityCount int
+}
+
+// AuditFilter 审计查询条件，零值字段不参与过滤
+type AuditFilter struct {
+	UserID   string
+	IP       string    // 精确匹配
+	Device   string    // 设备名关键词
+	Client   string    // 客户端名关键词
+	ItemID   string    // 媒体 ID 精确匹配
+	ItemName string    // 媒体名关键词
+	Since    time.Time // 开始时间（含）
+	Until    time.Time // 结束时间（不含）
+	Limit    int
+}
+
+// AuditActivity 按条件查询播放活动，按用户、设备、客户端、IP 分组
+func (c *Client) AuditActivity(filter AuditFilter) ([]AuditResult, error) {
+	sql, err := auditQuery(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.ExecuteCustomQuery(sql, false)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+
+	results := parseAuditRows(rows)
+	names := c.userNameResolver()
+	for i := range results {
+		results[i].Username = names(results[i].UserID)
+	}
+	return results, nil
+}
+
+// GetUsersByIP 根据 IP 地址查询使用该 IP 的用户信息
+func (c *Client) GetUsersByIP(ipAddress string, days int) ([]AuditResult, error) {
+	return c.AuditActivity(AuditFilter{IP: ipAddress, Since: daysAgo(days)})
+}
+
+// GetUsersByDeviceName 根据设备名关键词查询用户
+func (c *Client) GetUsersByDeviceName(deviceKeyword string, days int) ([]AuditResult, error) {
+	return c.AuditActivity(AuditFilter{Device: deviceKeyword, Since: daysAgo(days)})
+}
+
+// GetUsersByClientName 根据客户端名关键词查询用户
+func (c *Client) GetUsersByClientName(clientKeyword string, days int) ([]AuditResult, error) {
+	return c.AuditActivity(AuditFilter{Client: clientKeyword, Since: daysAgo(days)})
+}
+
+// GetUserActivityByName 根据用户名查询活动记录
+func (c *Client) GetUserActivityByName(username string, days int) ([]AuditResult, error) {
+	user, err := c.GetUserByName(username)
+	if err != nil {
+		return nil, fmt.Errorf("用户不存在: %w", err)
+	}
+	return c.AuditActivity(AuditFilter{UserID: user.ID, Since: daysAgo(days)})
+}
+
+// GetUserIPHistory 获取用户的 IP 和设备历史
+func (c *Client) GetUserIPHistory(userID string, days int) ([]AuditResult, error) {
+	return c.AuditActivity(AuditFilter{UserID: userID, Since: daysAgo(days), Limit: 50})
+}
+
+// auditQuery 构建审计查询，列顺序与 parseAuditRows 一致
+func auditQuery(f AuditFilter) (string, error) {
+	q := NewActivityQuery().
+		Columns(ColUserID, ColDeviceName, ColClientName, ColRemoteAddress).
+		Max(ColDateCreated, "LastActivity").
+		Count("ActivityCount")
+
+	if f.UserID != "" {
+		q.Eq(ColUserID, f.UserID)
+	}
+	if f.IP != "" {
+		q.Eq(ColRemoteAddress, f.IP)
+	}
+	if f.Device != "" {
+		q.Contains(ColDeviceName, f.Device)
+	}
+	if f.Client != "" {
+		q.Contains(ColClientName, f.Client)
+	}
+	if f.ItemID != "" {
+		q.Eq(ColItemID, f.ItemID)
+	}
+	if f.ItemName != "" {
+		q.Contains(ColItemName, f.ItemName)
+	}
+
+	return q.Since(f.Since).
+		Until(f.Until).
+		GroupBy(ColUserID, ColDeviceName, ColClientName, ColRemoteAddress).
+		OrderBy("LastActivity", true).
+		Limit(f.Limit).
+		Build()
+}
+
+// parseAuditRows 解析审计查询结果
+func parseAuditRows(rows [][]interface{}) []AuditResult {
+	results := make([]AuditResult, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 6 {
+			continue
+		}
+		lastActivity, _ := time.ParseInLocation(activityTimeLayout, cellString(row[4]), time.Local)
+		results = append(results, AuditResult{
+			UserID:        cellString(row[0]),
+			DeviceName:    cellString(row[1]),
+			ClientName:    cellString(row[2]),
+			RemoteAddress: cellString(row[3]),
+			LastActivity:  lastActivity,
+			ActivityCount: int(cellInt(row[5])),
+		})
+	}
+	return results
+}
+
+// 共用检测维度
+const (
+	SharedByIP     = "ip"     // 按 IP
+	SharedByDevice = "device" // 按设备
+)
+
+// SharedUsage 单个用户在某个 IP / 设备上的使用情况
+type SharedUsage struct {
+	Key          string // 分组键（IP、设备名或设备 ID）
+	Label        string // 显示名称
+	UserID       string
+	UserName     string
+	Count        int
+	LastActivity time.Time
+}
+
+// SharedCluster 多个用户共用的 IP / 设备
+type SharedCluster struct {
+	Key          string
+	Label        string
+	Users        []SharedUsage // 按使用次数降序
+	Total        int           // 总播放次数
+	LastActivity time.Time
+}
+
+// GetSharedUsage 查询指定时间以来被多个用户共用的 IP 或设备
+func (c *Client) GetSharedUsage(by string, since time.Time) ([]SharedCluster, error) {
+	sql, err := sharedUsageQuery(by, since)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := c.ExecuteCustomQuery(sql, false)
+	if err != nil {
+		return nil, fmt.Errorf("查询失败: %w", err)
+	}
+
+	names := c.userNameResolver()
+	usage := make([]SharedUsage, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 4 {
+			continue
+		}
+		key := cellString(row[0])
+		lastActivity, _ := time.ParseInLocation(activityTimeLayout, cellString(row[3]), time.Local)
+		usage = append(usage, SharedUsage{
+			Key:          key,
+			Label:        key,
+			UserID:       cellString(row[1]),
+			Count:        int(cellInt(row[2])),
+			LastActivity: lastActivity,
+		})
+	}
+
+	clusters := ClusterSharedUsage(usage, 2)
+	for i := range clusters {
+		for j := range clusters[i].Users {
+			clusters[i].Users[j].UserName = names(clusters[i].Users[j].UserID)
+		}
+	}
+	return clusters, nil
+}
+
+// sharedUsageQuery 构建共用检测查询：按 IP/设备与用户分组
+func sharedUsageQuery(by string, since time.Time) (string, error) {
+	col := ColRemoteAddress
+	switch by {
+	case SharedByIP:
+	case SharedByDevice:
+		col = ColDeviceName
+	default:
+		return "", fmt.Errorf("%w: 未知的共用检测维度 %q", ErrInvalidQuery, by)
+	}
+
+	return NewActivityQuery().
+		Columns(col, ColUserID).
+		Count("Plays").
+		Max(ColDateCreated, "LastActivity").
+		NotEmpty(col).
+		Since(since).
+		GroupBy(col, ColUserID).
+		OrderBy(string(col), false).
+		Limit(MaxActivityLimit).
+		Build()
+}
+
+// ClusterSharedUsage 将使用记录按 Key 聚类，只保留至少 minUsers 个不同用户的分组
+// 结果按用户数、总次数降序排列
+func ClusterSharedUsage(usage []SharedUsage, minUsers int) []SharedCluster {
+	byKey := make(map[string]*SharedCluster)
+	users := make(map[string]map[string]int) // key -> userID -> Users 下标
+	for _, u := range usage {
+		if u.Key == "" || u.UserID == "" {
+			continue
+		}
+		cluster, ok := byKey[u.Key]
+		if !ok {
+			label := u.Label
+			if label == "" {
+				label = u.Key
+			}
+			cluster = &SharedCluster{Key: u.Key, Label: label}
+			byKey[u.Key] = cluster
+			users[u.Key] = make(map[string]int)
+		}
+
+		if idx, ok := users[u.Key][u.UserID]; ok {
+			existing := &cluster.Users[idx]
+			existing.Count += u.Count
+			if u.LastActivity.After(existing.LastActivity) {
+				existing.LastActivity = u.LastActivity
+			}
+		} else {
+			users[u.Key][u.UserID] = len(cluster.Users)
+			cluster.Users = append(cluster.Users, u)
+		}
+		cluster.Total += u.Count
+		if u.LastActivity.After(cluster.LastActivity) {
+			cluster.LastActivity = u.LastActivity
+		}
+	}
+
+	clusters := make([]SharedCluster, 0, len(byKey))
+	for _, cluster := range byKey {
+		if len(cluster.Users) < minUsers {
+			continue
+		}
+		sort.Slice(cluster.Users, func(i, j int) bool {
+			if cluster.Users[i].Count != cluster.Users[j].Count {
+				return cluster.Users[i].Count > cluster.Users[j].Count
+			}
+			return cluster.Users[i].UserID < cluster.Users[j].UserID
+		})
+		clusters = append(clusters, *cluster)
+	}
+	sort.Slice(clusters, func(i, j int) bool {
+		if len(clusters[i].Users) != len(clusters[j].Users) {
+			return len(clusters[i].Users) > len(clusters[j].Users)
+		}
+		if clusters[i].Total != clusters[j].Total {
+			return clusters[i].Total > clusters[j].Total
+		}
+		return clusters[i].Key < clusters[j].Key
+	})
+	return clusters
+}
+
+// userNameResolver 返回带缓存的用户名查询函数
+func (c *Client) userNameResolver() func(userID string) string {
+	cache := make(map[string]string)
+	return func(userID string) string {
+		if name, ok := cache[userID]; ok {
+			return name
+		}
+		name := "未知用户"
+		if user, err := c.GetUser(userID); err == nil {
+			name = user.Name
+		}
+		cache[userID] = name
+		return name
+	}
+}
+
+// daysAgo 最近 N 天的起始时间，N <= 0 表示不限
+func daysAgo(days int) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	return time.Now().AddDate(0, 0, -days)
+}
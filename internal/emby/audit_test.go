@@ -0,0 +1,67 @@
+// Package emby 播放审计测试
+package emby
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClusterSharedUsage(t *testing.T) {
+	base := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	usage := []SharedUsage{
+		{Key: "1.1.1.1", UserID: "u1", Count: 5, LastActivity: base},
+		{Key: "1.1.1.1", UserID: "u2", Count: 8, LastActivity: base.Add(time.Hour)},
+		{Key: "2.2.2.2", UserID: "u3", Count: 20, LastActivity: base},
+		{Key: "3.3.3.3", UserID: "u1", Count: 1, LastActivity: base},
+		{Key: "3.3.3.3", UserID: "u4", Count: 1, LastActivity: base},
+		{Key: "3.3.3.3", UserID: "u5", Count: 1, LastActivity: base},
+		{Key: "3.3.3.3", UserID: "u5", Count: 2, LastActivity: base.Add(2 * time.Hour)},
+		{Key: "", UserID: "u6", Count: 9},
+	}
+
+	clusters := ClusterSharedUsage(usage, 2)
+	if len(clusters) != 2 {
+		t.Fatalf("期望 2 个共用分组，实际 %d: %+v", len(clusters), clusters)
+	}
+
+	first := clusters[0]
+	if first.Key != "3.3.3.3" || len(first.Users) != 3 || first.Total != 5 {
+		t.Errorf("第一个分组错误: %+v", first)
+	}
+	if first.Users[0].UserID != "u5" || first.Users[0].Count != 3 {
+		t.Errorf("同一用户应合并并按次数排序: %+v", first.Users)
+	}
+	if !first.LastActivity.Equal(base.Add(2 * time.Hour)) {
+		t.Errorf("LastActivity = %v", first.LastActivity)
+	}
+	if first.Label != "3.3.3.3" {
+		t.Errorf("未设置 Label 时应使用 Key，实际 %q", first.Label)
+	}
+
+	second := clusters[1]
+	if second.Key != "1.1.1.1" || second.Users[0].UserID != "u2" || second.Total != 13 {
+		t.Errorf("第二个分组错误: %+v", second)
+	}
+}
+
+func TestParseAuditRows(t *testing.T) {
+	rows := [][]interface{}{
+		{"u1", "iPhone", "Infuse", "10.0.0.1", "2024-03-01 20:30:00", float64(7)},
+		{"u2", "TV", "Emby", "10.0.0.2", "2024-03-02 08:00:00", "3"},
+		{"短行"},
+	}
+
+	got := parseAuditRows(rows)
+	if len(got) != 2 {
+		t.Fatalf("期望 2 条结果，实际 %d", len(got))
+	}
+	if got[0].UserID != "u1" || got[0].ClientName != "Infuse" || got[0].ActivityCount != 7 {
+		t.Errorf("第一条结果错误: %+v", got[0])
+	}
+	if got[0].LastActivity.Format(activityTimeLayout) != "2024-03-01 20:30:00" {
+		t.Errorf("LastActivity = %v", got[0].LastActivity)
+	}
+	if got[1].ActivityCount != 3 {
+		t.Errorf("字符串数字应被解析，实际 %d", got[1].ActivityCount)
+	}
+}
@@ -21,6 +21,8 @@ type Client struct {
 	apiKey     string
 	httpClient *resty.Client
 	mu         sync.RWMutex
+	sources    map[DataSource]SourceStatus // 播放统计数据源探测结果
+	local      LocalStatsFunc              // 本地播放记录数据源（未启用为 nil）
 }
 
 var (
@@ -32,7 +34,7 @@ var (
 func GetClient() *Client {
 	once.Do(func() {
 		cfg := config.Get()
-		instance = NewClient(cfg.Emby.URL, cfg.Emby.APIKey)
+		instance = NewClient(cfg.Emby.Main().URL, cfg.Emby.Main().APIKey)
 	})
 	return instance
 }
@@ -151,14 +153,15 @@ func (c *Client) CreateUser(name string, days int) (*CreateUserResult, error) {
 		return nil, fmt.Errorf("设置密码失败: %v", err)
 	}
 
-	// 3. 设置用户策略
-	if err := c.SetUserPolicy(userID, false, false); err != nil {
+	// 3. 设置用户策略（新用户按普通用户等级限制并发）
+	streamLimit := config.Get().StreamLimit.ForLevel("b").Streams
+	if err := c.setUserPolicy(userID, false, false, streamLimit); err != nil {
 		logger.Warn().Str("userID", userID).Err(err).Msg("设置用户策略失败")
 	}
 
 	// 4. 隐藏额外媒体库
 	cfg := config.Get()
-	blockedLibs := append(cfg.Emby.BlockedLibs, cfg.Emby.ExtraLibs...)
+	blockedLibs := append(cfg.Emby.Main().BlockedLibs, cfg.Emby.Main().ExtraLibs...)
 	if err := c.HideFolders(userID, blockedLibs); err != nil {
 		logger.Warn().Str("userID", userID).Err(err).Msg("隐藏媒体库失败")
 	}
@@ -173,6 +176,41 @@ func (c *Client) CreateUser(name string, days int) (*CreateUserResult, error) {
 	}, nil
 }
 
+// CreateUserWithPassword 使用指定密码创建用户（用于附加服务器与主服务器保持相同密码）
+func (c *Client) CreateUserWithPassword(name, password string, blockedLibs []string, streamLimit int) (string, error) {
+	result, err := c.request(http.MethodPost, "/emby/Users/New", map[string]string{"Name": name})
+	if err != nil || !result.Success {
+		return "", fmt.Errorf("创建用户失败: %v", err)
+	}
+
+	userData, ok := result.Data.(map[string]interface{})
+	if !ok {
+		return "", fmt.Errorf("无法解析用户数据")
+	}
+	userID := getString(userData, "Id")
+	if userID == "" {
+		return "", fmt.Errorf("无法获取用户 ID")
+	}
+
+	if password != "" {
+		if err := c.SetPassword(userID, password); err != nil {
+			c.DeleteUser(userID)
+			return "", fmt.Errorf("设置密码失败: %v", err)
+		}
+	}
+
+	if err := c.setUserPolicy(userID, false, false, streamLimit); err != nil {
+		logger.Warn().Str("userID", userID).Err(err).Msg("设置用户策略失败")
+	}
+	if len(blockedLibs) > 0 {
+		if err := c.HideFolders(userID, blockedLibs); err != nil {
+			logger.Warn().Str("userID", userID).Err(err).Msg("隐藏媒体库失败")
+		}
+	}
+
+	return userID, nil
+}
+
 // CreateUserResult 创建用户结果
 type CreateUserResult struct {
 	UserID     string
@@ -227,9 +265,18 @@ func (c *Client) ResetPassword(userID string) error {
 	return nil
 }
 
-// SetUserPolicy 设置用户策略
+// SetUserPolicy 设置用户策略（保留用户当前的并发播放限制）
 func (c *Client) SetUserPolicy(userID string, isAdmin, isDisabled bool) error {
-	policy := c.createPolicy(isAdmin, isDisabled, 2, nil)
+	streamLimit := config.DefaultStreamLimit
+	if user, err := c.GetUser(userID); err == nil && user.Policy != nil {
+		streamLimit = user.Policy.StreamLimit
+	}
+	return c.setUserPolicy(userID, isAdmin, isDisabled, streamLimit)
+}
+
+// setUserPolicy 按指定并发限制设置用户策略
+func (c *Client) setUserPolicy(userID string, isAdmin, isDisabled bool, streamLimit int) error {
+	policy := c.createPolicy(isAdmin, isDisabled, streamLimit, nil)
 
 	result, err := c.request(http.MethodPost, "/emby/Users/"+userID+"/Policy", policy)
 	if err != nil || !result.Success {
@@ -252,7 +299,7 @@ func (c *Client) DisableUser(userID string) error {
 func (c *Client) createPolicy(isAdmin, isDisabled bool, streamLimit int, blockedFolders []string) map[string]interface{} {
 	if blockedFolders == nil {
 		cfg := config.Get()
-		blockedFolders = append([]string{"播放列表"}, cfg.Emby.ExtraLibs...)
+		blockedFolders = append([]string{"播放列表"}, cfg.Emby.Main().ExtraLibs...)
 	}
 
 	return map[string]interface{}{
@@ -361,6 +408,7 @@ type UserPolicy struct {
 	EnableAllFolders bool
 	EnabledFolders  []string
 	BlockedFolders  []string
+	StreamLimit     int // 同时播放数限制（0 表示不限）
 }
 
 func parseUser(data map[string]interface{}) *User {
@@ -374,6 +422,7 @@ func parseUser(data map[string]interface{}) *User {
 			IsAdmin:          getBool(policy, "IsAdministrator"),
 			IsDisabled:       getBool(policy, "IsDisabled"),
 			EnableAllFolders: getBool(policy, "EnableAllFolders"),
+			StreamLimit:      getInt(policy, "SimultaneousStreamLimit"),
 		}
 
 		if folders, ok := policy["EnabledFolders"].([]interface{}); ok {
@@ -927,11 +976,13 @@ func (c *Client) SetUserAdminPolicy(userID string, isAdmin bool) error {
 	}
 
 	isDisabled := false
+	streamLimit := config.DefaultStreamLimit
 	if user.Policy != nil {
 		isDisabled = user.Policy.IsDisabled
+		streamLimit = user.Policy.StreamLimit
 	}
 
-	policy := c.createPolicy(isAdmin, isDisabled, 2, nil)
+	policy := c.createPolicy(isAdmin, isDisabled, streamLimit, nil)
 
 	result, err := c.request(http.MethodPost, "/emby/Users/"+userID+"/Policy", policy)
 	if err != nil || !result.Success {
@@ -975,54 +1026,6 @@ func (c *Client) ExecuteCustomQuery(sql string, replaceUserID bool) ([][]interfa
 	return rows, nil
 }
 
-// GetUserIPHistory 获取用户的IP和设备历史
-func (c *Client) GetUserIPHistory(userID string, days int) ([]AuditResult, error) {
-	sql := fmt.Sprintf(`
-		SELECT DISTINCT 
-			RemoteEndPoint as ip_address,
-			DeviceName as device_name,
-			ClientName as client_name,
-			MAX(DateCreated) as last_seen
-		FROM PlaybackActivity 
-		WHERE UserId = '%s' 
-		AND DateCreated >= date('now', '-%d days')
-		GROUP BY RemoteEndPoint, DeviceName, ClientName
-		ORDER BY last_seen DESC
-		LIMIT 50
-	`, userID, days)
-
-	rows, err := c.ExecuteCustomQuery(sql, true)
-	if err != nil {
-		// 如果插件不可用，返回空结果
-		logger.Warn().Err(err).Msg("执行用户IP历史查询失败，可能缺少 user_usage_stats 插件")
-		return nil, nil
-	}
-
-	var results []AuditResult
-	for _, row := range rows {
-		if len(row) >= 4 {
-			result := AuditResult{}
-			if v, ok := row[0].(string); ok {
-				result.RemoteAddress = v
-			}
-			if v, ok := row[1].(string); ok {
-				result.DeviceName = v
-			}
-			if v, ok := row[2].(string); ok {
-				result.ClientName = v
-			}
-			if v, ok := row[3].(string); ok {
-				if t, err := time.Parse("2006-01-02 15:04:05", v); err == nil {
-					result.LastActivity = t
-				}
-			}
-			results = append(results, result)
-		}
-	}
-
-	return results, nil
-}
-
 // AddFavorite 添加收藏
 func (c *Client) AddFavorite(userID, itemID string) error {
 	url := fmt.Sprintf("%s/emby/Users/%s/FavoriteItems/%s", c.baseURL, userID, itemID)
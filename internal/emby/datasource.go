@@ -0,0 +1,288 @@
+// Package emby 播放统计数据源
+// 依次尝试本地播放记录（已注册时）、user_usage_stats 插件、playback_reporting 插件与原生 API，并记录各数据源的可用性
+package emby
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// DataSource 播放统计数据源
+type DataSource string
+
+const (
+	SourceLocal             DataSource = "local"              // 本地 playback_sessions 表
+	SourceUsageStats        DataSource = "user_usage_stats"   // user_usage_stats 插件
+	SourcePlaybackReporting DataSource = "playback_reporting" // playback_reporting 插件
+	SourceNative            DataSource = "native"             // Emby 原生 API（按已播放条目估算）
+)
+
+// dataSourceOrder Emby 侧数据源优先级
+var dataSourceOrder = []DataSource{SourceUsageStats, SourcePlaybackReporting, SourceNative}
+
+// LocalStatsFunc 本地播放记录统计，由 service 层注册（emby 包不直接依赖数据库）
+type LocalStatsFunc func(startDate, endDate time.Time) ([]PlaybackStats, error)
+
+// SetLocalSource 注册本地播放记录数据源，注册后优先于插件使用
+func (c *Client) SetLocalSource(fn LocalStatsFunc) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.local = fn
+}
+
+// sourceOrder 当前生效的数据源优先级
+func (c *Client) sourceOrder() []DataSource {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.local == nil {
+		return dataSourceOrder
+	}
+	return append([]DataSource{SourceLocal}, dataSourceOrder...)
+}
+
+// Label 数据源显示名称
+func (d DataSource) Label() string {
+	switch d {
+	case SourceLocal:
+		return "本地播放记录"
+	case SourceUsageStats:
+		return "user_usage_stats 插件"
+	case SourcePlaybackReporting:
+		return "playback_reporting 插件"
+	case SourceNative:
+		return "原生 API"
+	default:
+		return string(d)
+	}
+}
+
+// ErrNoPlaybackData 所有数据源均无可用播放数据
+var ErrNoPlaybackData = errors.New("暂无可用的播放数据")
+
+// SourceAttempt 单个数据源的查询结果，Err 为空表示查询成功但没有数据
+type SourceAttempt struct {
+	Source DataSource
+	Err    error
+}
+
+// NoPlaybackDataError 所有数据源都失败或为空时返回，可用 errors.Is(err, ErrNoPlaybackData) 判断
+type NoPlaybackDataError struct {
+	Attempts []SourceAttempt
+}
+
+// Error 实现 error 接口
+func (e *NoPlaybackDataError) Error() string {
+	parts := make([]string, 0, len(e.Attempts))
+	for _, a := range e.Attempts {
+		reason := "无数据"
+		if a.Err != nil {
+			reason = a.Err.Error()
+		}
+		parts = append(parts, fmt.Sprintf("%s: %s", a.Source, reason))
+	}
+	return fmt.Sprintf("%s（%s）", ErrNoPlaybackData.Error(), strings.Join(parts, "; "))
+}
+
+// Is 支持 errors.Is(err, ErrNoPlaybackData)
+func (e *NoPlaybackDataError) Is(target error) bool {
+	return target == ErrNoPlaybackData
+}
+
+// SourceStatus 数据源探测结果
+type SourceStatus struct {
+	Source    DataSource `json:"source"`
+	Available bool       `json:"available"`
+	Error     string     `json:"error,omitempty"`
+	CheckedAt time.Time  `json:"checked_at"`
+}
+
+// ProbeDataSources 探测各数据源是否可用（启动时调用），结果保存在客户端中，之后随每次查询更新
+func (c *Client) ProbeDataSources() []SourceStatus {
+	now := time.Now()
+	probes := map[DataSource]func() error{
+		SourceLocal: func() error {
+			_, err := c.fetchUserStats(SourceLocal, now.AddDate(0, 0, -1), now)
+			return err
+		},
+		SourceUsageStats: func() error {
+			_, err := c.getUserUsageStatsFromPlugin(now.AddDate(0, 0, -1), now)
+			return err
+		},
+		SourcePlaybackReporting: func() error {
+			_, err := c.GetPlaybackReport(now, now)
+			return err
+		},
+		SourceNative: func() error {
+			_, err := c.GetUsers()
+			return err
+		},
+	}
+
+	statuses := make(map[DataSource]SourceStatus, len(probes))
+	for _, src := range c.sourceOrder() {
+		status := SourceStatus{Source: src, Available: true, CheckedAt: time.Now()}
+		if err := probes[src](); err != nil {
+			status.Available = false
+			status.Error = err.Error()
+		}
+		statuses[src] = status
+
+		logger.Info().
+			Str("source", string(src)).
+			Bool("available", status.Available).
+			Str("error", status.Error).
+			Msg("播放统计数据源探测")
+	}
+
+	c.mu.Lock()
+	c.sources = statuses
+	c.mu.Unlock()
+
+	return c.DataSourceStatus()
+}
+
+// DataSourceStatus 获取各数据源最近一次探测或查询结果（按优先级排列），未探测时返回 nil
+func (c *Client) DataSourceStatus() []SourceStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.sources == nil {
+		return nil
+	}
+	order := dataSourceOrder
+	if _, ok := c.sources[SourceLocal]; ok {
+		order = append([]DataSource{SourceLocal}, dataSourceOrder...)
+	}
+	list := make([]SourceStatus, 0, len(order))
+	for _, src := range order {
+		if status, ok := c.sources[src]; ok {
+			list = append(list, status)
+		}
+	}
+	return list
+}
+
+// sourceRetryInterval 探测或查询失败的数据源在此时间内跳过，之后重新尝试
+const sourceRetryInterval = 10 * time.Minute
+
+// skipSource 数据源最近一次探测或查询失败且未超过重试间隔时返回跳过原因
+// 启动探测结果只作为参考，插件恢复后下次查询即可重新使用
+func (c *Client) skipSource(src DataSource, now time.Time) error {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	status, ok := c.sources[src]
+	if !ok || status.Available || now.Sub(status.CheckedAt) >= sourceRetryInterval {
+		return nil
+	}
+	return fmt.Errorf("%s 检测不可用: %s", status.CheckedAt.Format("15:04"), status.Error)
+}
+
+// recordSource 按实际查询结果更新数据源状态
+func (c *Client) recordSource(src DataSource, err error, now time.Time) {
+	status := SourceStatus{Source: src, Available: err == nil, CheckedAt: now}
+	if err != nil {
+		status.Error = err.Error()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.sources == nil {
+		c.sources = make(map[DataSource]SourceStatus)
+	}
+	c.sources[src] = status
+}
+
+// GetAllUsersPlaybackStats 获取所有用户的播放统计，返回实际使用的数据源
+// 数据源按优先级依次尝试，全部失败或为空时返回 *NoPlaybackDataError
+func (c *Client) GetAllUsersPlaybackStats(startDate, endDate time.Time) ([]PlaybackStats, DataSource, error) {
+	logger.Debug().
+		Time("start", startDate).
+		Time("end", endDate).
+		Msg("获取所有用户播放统计")
+
+	var attempts []SourceAttempt
+	for _, src := range c.sourceOrder() {
+		if err := c.skipSource(src, time.Now()); err != nil {
+			attempts = append(attempts, SourceAttempt{Source: src, Err: err})
+			continue
+		}
+
+		stats, err := c.fetchUserStats(src, startDate, endDate)
+		c.recordSource(src, err, time.Now())
+		if err == nil && len(stats) > 0 {
+			return stats, src, nil
+		}
+
+		attempts = append(attempts, SourceAttempt{Source: src, Err: err})
+		logger.Debug().Err(err).Str("source", string(src)).Msg("数据源无可用播放数据，尝试下一个")
+	}
+
+	return nil, "", &NoPlaybackDataError{Attempts: attempts}
+}
+
+// fetchUserStats 从指定数据源获取按用户汇总的播放统计
+func (c *Client) fetchUserStats(src DataSource, startDate, endDate time.Time) ([]PlaybackStats, error) {
+	switch src {
+	case SourceLocal:
+		c.mu.RLock()
+		local := c.local
+		c.mu.RUnlock()
+		if local == nil {
+			return nil, errors.New("未启用本地播放记录")
+		}
+		return local(startDate, endDate)
+	case SourceUsageStats:
+		return c.getUserUsageStatsFromPlugin(startDate, endDate)
+	case SourcePlaybackReporting:
+		report, err := c.GetPlaybackReport(startDate, endDate)
+		if err != nil {
+			return nil, err
+		}
+		return aggregateReportByUser(report), nil
+	case SourceNative:
+		return c.getUserPlaybackStatsNative(startDate, endDate)
+	default:
+		return nil, fmt.Errorf("未知数据源: %s", src)
+	}
+}
+
+// aggregateReportByUser 将播放报告按用户汇总，按观看时长降序
+func aggregateReportByUser(report []PlaybackReportItem) []PlaybackStats {
+	byUser := make(map[string]*PlaybackStats)
+	for _, r := range report {
+		if r.UserID == "" {
+			continue
+		}
+		stats, ok := byUser[r.UserID]
+		if !ok {
+			stats = &PlaybackStats{UserID: r.UserID, UserName: r.UserName}
+			byUser[r.UserID] = stats
+		}
+		count := r.PlayCount
+		if count <= 0 {
+			count = 1
+		}
+		stats.PlayCount += count
+		stats.TotalTime += int64(r.PlayDuration)
+	}
+
+	list := make([]PlaybackStats, 0, len(byUser))
+	for _, stats := range byUser {
+		stats.TotalPlayTime = stats.TotalTime
+		list = append(list, *stats)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].TotalTime != list[j].TotalTime {
+			return list[i].TotalTime > list[j].TotalTime
+		}
+		return list[i].UserID < list[j].UserID
+	})
+	return list
+}
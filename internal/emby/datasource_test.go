@@ -0,0 +1,143 @@
+// Package emby 播放统计数据源测试
+package emby
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAggregateReportByUser(t *testing.T) {
+	report := []PlaybackReportItem{
+		{UserID: "u1", UserName: "alice", PlayDuration: 600},
+		{UserID: "u2", UserName: "bob", PlayDuration: 3600, PlayCount: 2},
+		{UserID: "u1", UserName: "alice", PlayDuration: 1200},
+		{UserID: "", UserName: "未知", PlayDuration: 9999},
+	}
+
+	got := aggregateReportByUser(report)
+	if len(got) != 2 {
+		t.Fatalf("期望 2 个用户，实际 %d", len(got))
+	}
+	if got[0].UserID != "u2" || got[0].PlayCount != 2 || got[0].TotalTime != 3600 {
+		t.Errorf("第一名错误: %+v", got[0])
+	}
+	if got[1].UserID != "u1" || got[1].PlayCount != 2 || got[1].TotalTime != 1800 {
+		t.Errorf("第二名错误: %+v", got[1])
+	}
+	if got[1].TotalPlayTime != got[1].TotalTime {
+		t.Errorf("TotalPlayTime = %d, want %d", got[1].TotalPlayTime, got[1].TotalTime)
+	}
+}
+
+func TestNoPlaybackDataError(t *testing.T) {
+	err := &NoPlaybackDataError{Attempts: []SourceAttempt{
+		{Source: SourceUsageStats, Err: errors.New("HTTP 404")},
+		{Source: SourceNative},
+	}}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"直接匹配", err, true},
+		{"包装后匹配", fmt.Errorf("获取排行失败: %w", err), true},
+		{"其他错误", errors.New("HTTP 500"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errors.Is(tt.err, ErrNoPlaybackData); got != tt.want {
+				t.Errorf("errors.Is() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	want := "暂无可用的播放数据（user_usage_stats: HTTP 404; native: 无数据）"
+	if err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+}
+
+func TestGetAllUsersPlaybackStats_SkipsUnavailable(t *testing.T) {
+	c := &Client{sources: make(map[DataSource]SourceStatus)}
+	for _, src := range dataSourceOrder {
+		c.sources[src] = SourceStatus{Source: src, Available: false, Error: "探测失败", CheckedAt: time.Now()}
+	}
+
+	stats, source, err := c.GetAllUsersPlaybackStats(time.Now().AddDate(0, 0, -1), time.Now())
+	if !errors.Is(err, ErrNoPlaybackData) {
+		t.Fatalf("期望 ErrNoPlaybackData，实际 %v", err)
+	}
+	if stats != nil || source != "" {
+		t.Errorf("期望无数据，实际 stats=%v source=%q", stats, source)
+	}
+
+	var noData *NoPlaybackDataError
+	if !errors.As(err, &noData) || len(noData.Attempts) != len(dataSourceOrder) {
+		t.Errorf("期望记录 %d 次尝试，实际 %+v", len(dataSourceOrder), noData)
+	}
+}
+
+func TestDataSourceStatus(t *testing.T) {
+	c := &Client{}
+	if got := c.DataSourceStatus(); got != nil {
+		t.Errorf("未探测时应返回 nil，实际 %v", got)
+	}
+
+	c.sources = map[DataSource]SourceStatus{
+		SourceNative:     {Source: SourceNative, Available: true},
+		SourceUsageStats: {Source: SourceUsageStats, Available: false},
+	}
+	got := c.DataSourceStatus()
+	if len(got) != 2 || got[0].Source != SourceUsageStats || got[1].Source != SourceNative {
+		t.Errorf("应按优先级排列，实际 %+v", got)
+	}
+}
+
+func TestGetAllUsersPlaybackStats_PrefersLocal(t *testing.T) {
+	c := &Client{}
+	c.SetLocalSource(func(start, end time.Time) ([]PlaybackStats, error) {
+		return []PlaybackStats{{UserID: "u1", PlayCount: 3, TotalTime: 600}}, nil
+	})
+
+	stats, source, err := c.GetAllUsersPlaybackStats(time.Now().AddDate(0, 0, -1), time.Now())
+	if err != nil {
+		t.Fatalf("不应返回错误: %v", err)
+	}
+	if source != SourceLocal || len(stats) != 1 || stats[0].UserID != "u1" {
+		t.Errorf("期望使用本地数据源，实际 source=%q stats=%+v", source, stats)
+	}
+}
+
+func TestGetAllUsersPlaybackStats_RetriesAfterInterval(t *testing.T) {
+	calls := 0
+	c := &Client{}
+	c.SetLocalSource(func(start, end time.Time) ([]PlaybackStats, error) {
+		calls++
+		return []PlaybackStats{{UserID: "u1", PlayCount: 1, TotalTime: 60}}, nil
+	})
+
+	// 启动时探测失败，重试间隔内跳过
+	c.sources = map[DataSource]SourceStatus{
+		SourceLocal: {Source: SourceLocal, Available: false, Error: "探测失败", CheckedAt: time.Now()},
+	}
+	for _, src := range dataSourceOrder {
+		c.sources[src] = SourceStatus{Source: src, Available: false, Error: "探测失败", CheckedAt: time.Now()}
+	}
+	if _, _, err := c.GetAllUsersPlaybackStats(time.Now().AddDate(0, 0, -1), time.Now()); !errors.Is(err, ErrNoPlaybackData) || calls != 0 {
+		t.Fatalf("重试间隔内应跳过，err=%v calls=%d", err, calls)
+	}
+
+	// 超过重试间隔后重新尝试，成功后状态恢复为可用
+	c.sources[SourceLocal] = SourceStatus{Source: SourceLocal, Available: false, CheckedAt: time.Now().Add(-sourceRetryInterval)}
+	stats, source, err := c.GetAllUsersPlaybackStats(time.Now().AddDate(0, 0, -1), time.Now())
+	if err != nil || source != SourceLocal || len(stats) != 1 || calls != 1 {
+		t.Fatalf("应重新使用本地数据源，source=%q err=%v calls=%d", source, err, calls)
+	}
+	if status := c.DataSourceStatus()[0]; status.Source != SourceLocal || !status.Available {
+		t.Errorf("查询成功后应标记为可用，实际 %+v", status)
+	}
+}
@@ -0,0 +1,236 @@
+// Package emby 媒体播放排行
+// 基于 playback_reporting 插件的播放记录，按电影或剧集统计播放次数
+package emby
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// 媒体排行类型
+const (
+	MediaTypeMovie  = "Movie"  // 电影，按 ItemId 分组
+	MediaTypeSeries = "Series" // 剧集，按剧名聚合所有单集
+)
+
+// MediaRankItem 媒体排行项
+type MediaRankItem struct {
+	ItemID    string // 电影 ID；剧集为其中任意一集的 ID
+	Name      string // 电影名或剧名
+	PlayCount int
+	Duration  int64 // 秒
+	Users     int   // 观看人数
+}
+
+// GetMediaRanking 获取时间范围内播放最多的电影或剧集
+// 优先通过自定义 SQL 在插件侧聚合，失败时回退到 GetPlaybackReport 在本地聚合
+func (c *Client) GetMediaRanking(startDate, endDate time.Time, mediaType string, limit int) ([]MediaRankItem, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	rows, err := c.ExecuteCustomQuery(mediaRankSQL(startDate, endDate, mediaType, limit), false)
+	if err == nil {
+		return parseMediaRankRows(rows), nil
+	}
+	logger.Debug().Err(err).Str("type", mediaType).Msg("自定义查询不可用，回退到播放报告")
+
+	report, err := c.GetPlaybackReport(startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	return AggregateMediaRanking(report, mediaType, limit), nil
+}
+
+// mediaRankSQL 构建媒体排行查询
+// 单集的 ItemName 格式为 "剧名 - S01E01 - 标题"，按第一个 " - " 之前的剧名分组，
+// 没有 " - " 时使用完整的 ItemName（与 SeriesName 一致）
+func mediaRankSQL(startDate, endDate time.Time, mediaType string, limit int) string {
+	itemType, idColumn, nameColumn, groupBy := "Movie", "ItemId", "ItemName", "ItemId"
+	if mediaType == MediaTypeSeries {
+		itemType = "Episode"
+		idColumn = "MAX(ItemId)"
+		nameColumn = "CASE WHEN instr(ItemName, ' - ') > 1 THEN trim(substr(ItemName, 1, instr(ItemName, ' - ') - 1)) ELSE trim(ItemName) END"
+		groupBy = "Name"
+	}
+
+	return fmt.Sprintf(`SELECT %s AS ItemId, %s AS Name, COUNT(1) AS PlayCount, SUM(PlayDuration) AS Duration, COUNT(DISTINCT UserId) AS Users
+FROM PlaybackActivity
+WHERE ItemType = '%s' AND DateCreated >= '%s' AND DateCreated < '%s'
+GROUP BY %s
+ORDER BY PlayCount DESC, Duration DESC
+LIMIT %d`,
+		idColumn, nameColumn, itemType,
+		startDate.Format("2006-01-02 15:04:05"), endDate.Format("2006-01-02 15:04:05"),
+		groupBy, limit)
+}
+
+// parseMediaRankRows 解析自定义查询结果，列顺序与 mediaRankSQL 一致
+func parseMediaRankRows(rows [][]interface{}) []MediaRankItem {
+	items := make([]MediaRankItem, 0, len(rows))
+	for _, row := range rows {
+		if len(row) < 5 {
+			continue
+		}
+		name := cellString(row[1])
+		if name == "" {
+			continue
+		}
+		items = append(items, MediaRankItem{
+			ItemID:    cellString(row[0]),
+			Name:      name,
+			PlayCount: int(cellInt(row[2])),
+			Duration:  cellInt(row[3]),
+			Users:     int(cellInt(row[4])),
+		})
+	}
+	return items
+}
+
+// AggregateMediaRanking 将播放报告按电影 ID 或剧名聚合并排序
+func AggregateMediaRanking(report []PlaybackReportItem, mediaType string, limit int) []MediaRankItem {
+	type bucket struct {
+		item  MediaRankItem
+		users map[string]bool
+	}
+
+	buckets := make(map[string]*bucket)
+	for _, r := range report {
+		var key, name string
+		switch {
+		case mediaType == MediaTypeMovie && r.ItemType == "Movie":
+			key, name = r.ItemID, r.ItemName
+			if key == "" {
+				key = name
+			}
+		case mediaType == MediaTypeSeries && r.ItemType == "Episode":
+			name = SeriesName(r.ItemName)
+			key = name
+		default:
+			continue
+		}
+		if key == "" {
+			continue
+		}
+
+		b, ok := buckets[key]
+		if !ok {
+			b = &bucket{item: MediaRankItem{ItemID: r.ItemID, Name: name}, users: make(map[string]bool)}
+			buckets[key] = b
+		}
+		count := r.PlayCount
+		if count <= 0 {
+			count = 1
+		}
+		b.item.PlayCount += count
+		b.item.Duration += int64(r.PlayDuration)
+		if r.UserID != "" {
+			b.users[r.UserID] = true
+		}
+	}
+
+	items := make([]MediaRankItem, 0, len(buckets))
+	for _, b := range buckets {
+		b.item.Users = len(b.users)
+		items = append(items, b.item)
+	}
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].PlayCount != items[j].PlayCount {
+			return items[i].PlayCount > items[j].PlayCount
+		}
+		if items[i].Duration != items[j].Duration {
+			return items[i].Duration > items[j].Duration
+		}
+		return items[i].Name < items[j].Name
+	})
+
+	if limit > 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return items
+}
+
+// SeriesName 从单集名称中提取剧名
+func SeriesName(itemName string) string {
+	if i := strings.Index(itemName, " - "); i > 0 {
+		return strings.TrimSpace(itemName[:i])
+	}
+	return strings.TrimSpace(itemName)
+}
+
+// GetSeriesID 获取单集所属剧集的 ID（用于获取剧集海报）
+func (c *Client) GetSeriesID(itemID string) (string, error) {
+	resp, err := c.httpClient.R().
+		SetQueryParams(map[string]string{
+			"Ids":    itemID,
+			"Fields": "SeriesId",
+		}).
+		Get(c.baseURL + "/emby/Items")
+	if err != nil {
+		return "", fmt.Errorf("获取项目信息失败: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return "", fmt.Errorf("获取项目信息失败: HTTP %d", resp.StatusCode())
+	}
+
+	var result struct {
+		Items []struct {
+			SeriesID string `json:"SeriesId"`
+		} `json:"Items"`
+	}
+	if err := json.Unmarshal(resp.Body(), &result); err != nil {
+		return "", fmt.Errorf("解析项目信息失败: %w", err)
+	}
+	if len(result.Items) == 0 || result.Items[0].SeriesID == "" {
+		return "", fmt.Errorf("项目 %s 不属于任何剧集", itemID)
+	}
+	return result.Items[0].SeriesID, nil
+}
+
+// GetImage 下载媒体图片
+func (c *Client) GetImage(itemID, imageType string, maxHeight, maxWidth int) ([]byte, error) {
+	resp, err := c.httpClient.R().
+		SetHeader("Accept", "image/*").
+		Get(c.GetImageURL(itemID, imageType, maxHeight, maxWidth))
+	if err != nil {
+		return nil, fmt.Errorf("下载图片失败: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return nil, fmt.Errorf("下载图片失败: HTTP %d", resp.StatusCode())
+	}
+	return resp.Body(), nil
+}
+
+// cellString 自定义查询结果单元格转字符串
+func cellString(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// cellInt 自定义查询结果单元格转整数（插件可能以字符串返回数字）
+func cellInt(v interface{}) int64 {
+	switch val := v.(type) {
+	case float64:
+		return int64(val)
+	case string:
+		n, _ := strconv.ParseFloat(val, 64)
+		return int64(n)
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,108 @@
+// Package emby 媒体播放排行测试
+package emby
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSeriesName(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"Breaking Bad - s01e01 - Pilot", "Breaking Bad"},
+		{"三体 - s01e02 - 第2集", "三体"},
+		{"Movie Without Separator", "Movie Without Separator"},
+		{" - s01e01", "- s01e01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := SeriesName(tt.input); got != tt.expected {
+				t.Errorf("SeriesName(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAggregateMediaRanking(t *testing.T) {
+	report := []PlaybackReportItem{
+		{UserID: "u1", ItemID: "m1", ItemName: "电影A", ItemType: "Movie", PlayDuration: 3600},
+		{UserID: "u2", ItemID: "m1", ItemName: "电影A", ItemType: "Movie", PlayDuration: 1800},
+		{UserID: "u1", ItemID: "m2", ItemName: "电影B", ItemType: "Movie", PlayDuration: 600, PlayCount: 3},
+		{UserID: "u1", ItemID: "e1", ItemName: "剧集X - s01e01 - 第1集", ItemType: "Episode", PlayDuration: 1200},
+		{UserID: "u1", ItemID: "e2", ItemName: "剧集X - s01e02 - 第2集", ItemType: "Episode", PlayDuration: 1200},
+		{UserID: "u2", ItemID: "e3", ItemName: "剧集Y - s01e01 - 第1集", ItemType: "Episode", PlayDuration: 1200},
+		{UserID: "u2", ItemID: "a1", ItemName: "音乐", ItemType: "Audio", PlayDuration: 200},
+	}
+
+	t.Run("电影按 ItemId 分组", func(t *testing.T) {
+		got := AggregateMediaRanking(report, MediaTypeMovie, 10)
+		if len(got) != 2 {
+			t.Fatalf("电影数量 = %d, want 2", len(got))
+		}
+		if got[0].ItemID != "m2" || got[0].PlayCount != 3 {
+			t.Errorf("第一名 = %+v, want m2 播放 3 次", got[0])
+		}
+		if got[1].ItemID != "m1" || got[1].PlayCount != 2 || got[1].Users != 2 || got[1].Duration != 5400 {
+			t.Errorf("第二名 = %+v, want m1 播放 2 次、2 人、5400 秒", got[1])
+		}
+	})
+
+	t.Run("剧集按剧名聚合", func(t *testing.T) {
+		got := AggregateMediaRanking(report, MediaTypeSeries, 10)
+		if len(got) != 2 {
+			t.Fatalf("剧集数量 = %d, want 2", len(got))
+		}
+		if got[0].Name != "剧集X" || got[0].PlayCount != 2 || got[0].Users != 1 {
+			t.Errorf("第一名 = %+v, want 剧集X 播放 2 次、1 人", got[0])
+		}
+	})
+
+	t.Run("限制数量", func(t *testing.T) {
+		if got := AggregateMediaRanking(report, MediaTypeMovie, 1); len(got) != 1 {
+			t.Errorf("数量 = %d, want 1", len(got))
+		}
+	})
+}
+
+func TestParseMediaRankRows(t *testing.T) {
+	rows := [][]interface{}{
+		{"m1", "电影A", "5", "7200", "3"},
+		{"m2", "", "1", "60", "1"},
+		{"m3", "电影C", float64(2), float64(300), float64(1)},
+		{"short"},
+	}
+
+	got := parseMediaRankRows(rows)
+	if len(got) != 2 {
+		t.Fatalf("解析数量 = %d, want 2", len(got))
+	}
+	if got[0].PlayCount != 5 || got[0].Duration != 7200 || got[0].Users != 3 {
+		t.Errorf("字符串数字解析错误: %+v", got[0])
+	}
+	if got[1].Name != "电影C" || got[1].PlayCount != 2 {
+		t.Errorf("数字解析错误: %+v", got[1])
+	}
+}
+
+func TestMediaRankSQL(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 1)
+
+	movie := mediaRankSQL(start, end, MediaTypeMovie, 10)
+	if !strings.Contains(movie, "ItemType = 'Movie'") || !strings.Contains(movie, "GROUP BY ItemId") {
+		t.Errorf("电影查询错误: %s", movie)
+	}
+
+	series := mediaRankSQL(start, end, MediaTypeSeries, 5)
+	if !strings.Contains(series, "ItemType = 'Episode'") || !strings.Contains(series, "GROUP BY Name") || !strings.Contains(series, "LIMIT 5") {
+		t.Errorf("剧集查询错误: %s", series)
+	}
+	// 单集名称中没有 " - " 时回退到完整名称，不能被分组丢弃
+	if !strings.Contains(series, "ELSE trim(ItemName) END") {
+		t.Errorf("剧集查询缺少完整名称回退: %s", series)
+	}
+}
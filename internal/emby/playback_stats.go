@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/http"
 	"sort"
-	"strings"
 	"time"
 
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
@@ -118,25 +117,6 @@ func (c *Client) GetUserPlaybackStats(userID string, days int) (*PlaybackStats,
 	return stats, nil
 }
 
-// GetAllUsersPlaybackStats 获取所有用户的播放统计
-func (c *Client) GetAllUsersPlaybackStats(startDate, endDate time.Time) ([]PlaybackStats, error) {
-	logger.Debug().
-		Time("start", startDate).
-		Time("end", endDate).
-		Msg("获取所有用户播放统计")
-
-	// 首先尝试使用 user_usage_stats 插件 API
-	stats, err := c.getUserUsageStatsFromPlugin(startDate, endDate)
-	if err == nil && len(stats) > 0 {
-		return stats, nil
-	}
-
-	logger.Debug().Msg("user_usage_stats 插件不可用，使用原生 API")
-
-	// 回退到原生 API
-	return c.getUserPlaybackStatsNative(startDate, endDate)
-}
-
 // getUserUsageStatsFromPlugin 从 user_usage_stats 插件获取统计
 func (c *Client) getUserUsageStatsFromPlugin(startDate, endDate time.Time) ([]PlaybackStats, error) {
 	// user_usage_stats 插件端点
@@ -261,6 +241,7 @@ func (c *Client) GetPlaybackReport(startDate, endDate time.Time) ([]PlaybackRepo
 			items = append(items, PlaybackReportItem{
 				UserID:       getString(row, "UserId"),
 				UserName:     getString(row, "UserName"),
+				ItemID:       getString(row, "ItemId"),
 				ItemName:     getString(row, "ItemName"),
 				ItemType:     getString(row, "ItemType"),
 				PlayDuration: getInt(row, "PlayDuration"),
@@ -276,17 +257,18 @@ func (c *Client) GetPlaybackReport(startDate, endDate time.Time) ([]PlaybackRepo
 type PlaybackReportItem struct {
 	UserID       string
 	UserName     string
+	ItemID       string
 	ItemName     string
 	ItemType     string
 	PlayDuration int // 秒
 	PlayCount    int
 }
 
-// GetUserRanking 获取用户播放排行
-func (c *Client) GetUserRanking(startDate, endDate time.Time, limit int) ([]RankingItem, error) {
-	stats, err := c.GetAllUsersPlaybackStats(startDate, endDate)
+// GetUserRanking 获取用户播放排行，返回实际使用的数据源
+func (c *Client) GetUserRanking(startDate, endDate time.Time, limit int) ([]RankingItem, DataSource, error) {
+	stats, source, err := c.GetAllUsersPlaybackStats(startDate, endDate)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if limit <= 0 {
@@ -307,7 +289,7 @@ func (c *Client) GetUserRanking(startDate, endDate time.Time, limit int) ([]Rank
 		})
 	}
 
-	return ranking, nil
+	return ranking, source, nil
 }
 
 // RankingItem 排行项
@@ -329,179 +311,3 @@ func FormatWatchTime(seconds int64) string {
 	}
 	return fmt.Sprintf("%d分钟", minutes)
 }
-
-// AuditResult 审计结果
-type AuditResult struct {
-	UserID        string
-	Username      string
-	DeviceName    string
-	ClientName    string
-	RemoteAddress string
-	LastActivity  time.Time
-	ActivityCount int
-}
-
-// GetUsersByIP 根据 IP 地址查询使用该 IP 的用户信息
-func (c *Client) GetUsersByIP(ipAddress string, days int) ([]AuditResult, error) {
-	return c.executeAuditQuery("RemoteAddress", ipAddress, days, true)
-}
-
-// GetUsersByDeviceName 根据设备名关键词查询用户
-func (c *Client) GetUsersByDeviceName(deviceKeyword string, days int) ([]AuditResult, error) {
-	return c.executeAuditQuery("DeviceName", deviceKeyword, days, false)
-}
-
-// GetUsersByClientName 根据客户端名关键词查询用户
-func (c *Client) GetUsersByClientName(clientKeyword string, days int) ([]AuditResult, error) {
-	return c.executeAuditQuery("ClientName", clientKeyword, days, false)
-}
-
-// GetUserActivityByName 根据用户名查询活动记录
-func (c *Client) GetUserActivityByName(username string, days int) ([]AuditResult, error) {
-	// 首先获取用户 ID
-	user, err := c.GetUserByName(username)
-	if err != nil {
-		return nil, fmt.Errorf("用户不存在: %w", err)
-	}
-
-	return c.executeAuditQueryByUserID(user.ID, days)
-}
-
-// executeAuditQuery 执行审计查询
-func (c *Client) executeAuditQuery(field, value string, days int, exactMatch bool) ([]AuditResult, error) {
-	// 构建 SQL 查询
-	var whereClause string
-	if exactMatch {
-		// 精确匹配（用于 IP 地址）
-		whereClause = fmt.Sprintf("%s = '%s'", field, sanitizeSQL(value))
-	} else {
-		// 模糊匹配（用于设备名、客户端名）
-		whereClause = fmt.Sprintf("%s LIKE '%%%s%%'", field, sanitizeSQL(value))
-	}
-
-	sql := fmt.Sprintf(`
-		SELECT DISTINCT UserId, DeviceName, ClientName, RemoteAddress,
-			   MAX(DateCreated) AS LastActivity, COUNT(*) AS ActivityCount
-		FROM PlaybackActivity 
-		WHERE %s`, whereClause)
-
-	if days > 0 {
-		sql += fmt.Sprintf(" AND DateCreated >= datetime('now', '-%d days')", days)
-	}
-
-	sql += " GROUP BY UserId, DeviceName, ClientName, RemoteAddress ORDER BY LastActivity DESC"
-
-	return c.executeAuditSQL(sql)
-}
-
-// executeAuditQueryByUserID 根据用户 ID 查询活动
-func (c *Client) executeAuditQueryByUserID(userID string, days int) ([]AuditResult, error) {
-	sql := fmt.Sprintf(`
-		SELECT UserId, DeviceName, ClientName, RemoteAddress,
-			   MAX(DateCreated) AS LastActivity, COUNT(*) AS ActivityCount
-		FROM PlaybackActivity 
-		WHERE UserId = '%s'`, sanitizeSQL(userID))
-
-	if days > 0 {
-		sql += fmt.Sprintf(" AND DateCreated >= datetime('now', '-%d days')", days)
-	}
-
-	sql += " GROUP BY DeviceName, ClientName, RemoteAddress ORDER BY LastActivity DESC"
-
-	return c.executeAuditSQL(sql)
-}
-
-// executeAuditSQL 执行审计 SQL 查询
-func (c *Client) executeAuditSQL(sql string) ([]AuditResult, error) {
-	// 通过 user_usage_stats 插件提交自定义 SQL 查询
-	endpoint := fmt.Sprintf("/emby/user_usage_stats/submit_custom_query?api_key=%s", c.apiKey)
-	payload := map[string]interface{}{
-		"CustomQueryString": sql,
-		"ReplaceUserId":     false,
-	}
-
-	result, err := c.request("POST", endpoint, payload)
-	if err != nil {
-		return nil, fmt.Errorf("查询失败: %w", err)
-	}
-
-	if !result.Success {
-		return nil, fmt.Errorf("查询失败")
-	}
-
-	// 解析返回数据
-	data, ok := result.Data.(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("无法解析响应数据")
-	}
-
-	results, ok := data["results"].([]interface{})
-	if !ok {
-		// 尝试直接作为数组解析
-		if arrData, ok := result.Data.([]interface{}); ok {
-			results = arrData
-		} else {
-			return nil, fmt.Errorf("无法解析查询结果")
-		}
-	}
-
-	var auditResults []AuditResult
-
-	// 创建用户名缓存
-	userCache := make(map[string]string)
-
-	for _, row := range results {
-		rowData, ok := row.([]interface{})
-		if !ok || len(rowData) < 6 {
-			continue
-		}
-
-		userID := fmt.Sprintf("%v", rowData[0])
-
-		// 获取用户名（带缓存）
-		username := userCache[userID]
-		if username == "" {
-			if user, err := c.GetUser(userID); err == nil {
-				username = user.Name
-			} else {
-				username = "未知用户"
-			}
-			userCache[userID] = username
-		}
-
-		// 解析最后活动时间
-		var lastActivity time.Time
-		if timeStr, ok := rowData[4].(string); ok {
-			lastActivity, _ = time.Parse("2006-01-02 15:04:05", timeStr)
-		}
-
-		// 解析活动次数
-		activityCount := 0
-		if count, ok := rowData[5].(float64); ok {
-			activityCount = int(count)
-		}
-
-		auditResults = append(auditResults, AuditResult{
-			UserID:        userID,
-			Username:      username,
-			DeviceName:    fmt.Sprintf("%v", rowData[1]),
-			ClientName:    fmt.Sprintf("%v", rowData[2]),
-			RemoteAddress: fmt.Sprintf("%v", rowData[3]),
-			LastActivity:  lastActivity,
-			ActivityCount: activityCount,
-		})
-	}
-
-	return auditResults, nil
-}
-
-// sanitizeSQL 清理 SQL 输入，防止注入
-func sanitizeSQL(input string) string {
-	// 替换危险字符
-	input = strings.ReplaceAll(input, "'", "''")
-	input = strings.ReplaceAll(input, ";", "")
-	input = strings.ReplaceAll(input, "--", "")
-	input = strings.ReplaceAll(input, "/*", "")
-	input = strings.ReplaceAll(input, "*/", "")
-	return input
-}
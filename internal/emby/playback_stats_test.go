@@ -30,25 +30,3 @@ func TestFormatWatchTime(t *testing.T) {
 		})
 	}
 }
-
-func TestSanitizeSQL(t *testing.T) {
-	tests := []struct {
-		input    string
-		expected string
-	}{
-		{"normal", "normal"},
-		{"it's", "it''s"},
-		{"test; DROP TABLE", "test DROP TABLE"},
-		{"test--comment", "testcomment"},
-		{"test/*comment*/", "testcomment"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.input, func(t *testing.T) {
-			got := sanitizeSQL(tt.input)
-			if got != tt.expected {
-				t.Errorf("sanitizeSQL(%q) = %q, want %q", tt.input, got, tt.expected)
-			}
-		})
-	}
-}
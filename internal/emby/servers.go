@@ -0,0 +1,65 @@
+// Package emby 多服务器
+package emby
+
+import (
+	"sync"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// Server 配置中的一台 Emby/Jellyfin 服务器
+type Server struct {
+	Config config.EmbyServerConfig
+	Client *Client
+}
+
+// IsMain 是否为主服务器
+func (s *Server) IsMain() bool {
+	return s.Config.Main
+}
+
+var (
+	servers     []*Server
+	serversOnce sync.Once
+)
+
+// GetServers 获取配置中的所有服务器（按配置顺序，主服务器复用 GetClient）
+func GetServers() []*Server {
+	serversOnce.Do(func() {
+		for _, sc := range config.Get().Emby {
+			if sc.Main {
+				servers = append(servers, &Server{Config: sc, Client: GetClient()})
+				continue
+			}
+			if sc.Name == "" || sc.URL == "" {
+				continue
+			}
+			servers = append(servers, &Server{
+				Config: sc,
+				Client: NewClient(sc.URL, sc.APIKey),
+			})
+		}
+	})
+	return servers
+}
+
+// GetAdditionalServers 获取除主服务器外的服务器
+func GetAdditionalServers() []*Server {
+	var list []*Server
+	for _, s := range GetServers() {
+		if !s.IsMain() {
+			list = append(list, s)
+		}
+	}
+	return list
+}
+
+// GetServer 按名称获取服务器，名称为空时返回主服务器
+func GetServer(name string) *Server {
+	for _, s := range GetServers() {
+		if s.Config.Name == name || (name == "" && s.IsMain()) {
+			return s
+		}
+	}
+	return nil
+}
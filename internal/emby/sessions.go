@@ -0,0 +1,114 @@
+// Package emby 会话与并发限制
+package emby
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Session Emby 活动会话
+type Session struct {
+	ID             string
+	UserID         string
+	UserName       string
+	Client         string
+	DeviceName     string
+	DeviceID       string
+	RemoteEndPoint string
+	NowPlaying     string // 正在播放的媒体名称（为空表示空闲）
+	NowPlayingID   string // 正在播放的媒体 ID
+	NowPlayingType string // 正在播放的媒体类型（Movie、Episode 等）
+	PositionTicks  int64  // 播放进度
+	LastActivity   time.Time
+}
+
+// IsPlaying 是否正在播放
+func (s *Session) IsPlaying() bool {
+	return s.NowPlaying != ""
+}
+
+// GetSessions 获取所有已登录用户的活动会话
+func (c *Client) GetSessions() ([]Session, error) {
+	result, err := c.request(http.MethodGet, "/emby/Sessions", nil)
+	if err != nil || !result.Success {
+		return nil, fmt.Errorf("获取会话失败: %v", result.Error)
+	}
+
+	data, ok := result.Data.([]interface{})
+	if !ok {
+		return []Session{}, nil
+	}
+
+	sessions := make([]Session, 0, len(data))
+	for _, item := range data {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		userID := getString(m, "UserId")
+		if userID == "" {
+			continue
+		}
+
+		session := Session{
+			ID:             getString(m, "Id"),
+			UserID:         userID,
+			UserName:       getString(m, "UserName"),
+			Client:         getString(m, "Client"),
+			DeviceName:     getString(m, "DeviceName"),
+			DeviceID:       getString(m, "DeviceId"),
+			RemoteEndPoint: getString(m, "RemoteEndPoint"),
+		}
+		if playing, ok := m["NowPlayingItem"].(map[string]interface{}); ok {
+			session.NowPlayingID = getString(playing, "Id")
+			session.NowPlayingType = getString(playing, "Type")
+			session.NowPlaying = getString(playing, "Name")
+			if series := getString(playing, "SeriesName"); series != "" && session.NowPlaying != "" {
+				session.NowPlaying = series + " - " + session.NowPlaying
+			}
+			if session.NowPlaying == "" {
+				session.NowPlaying = session.NowPlayingID
+			}
+		}
+		if state, ok := m["PlayState"].(map[string]interface{}); ok {
+			if ticks, ok := state["PositionTicks"].(float64); ok {
+				session.PositionTicks = int64(ticks)
+			}
+		}
+		if t, err := time.Parse(time.RFC3339, getString(m, "LastActivityDate")); err == nil {
+			session.LastActivity = t
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, nil
+}
+
+// SetStreamLimit 设置用户同时播放数限制（0 表示不限），其余策略保持不变
+func (c *Client) SetStreamLimit(userID string, limit int) error {
+	result, err := c.request(http.MethodGet, "/emby/Users/"+userID, nil)
+	if err != nil || !result.Success {
+		return fmt.Errorf("获取用户失败: %v", result.Error)
+	}
+
+	data, ok := result.Data.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("无法解析用户数据")
+	}
+	policy, ok := data["Policy"].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("无法解析用户策略")
+	}
+
+	if getInt(policy, "SimultaneousStreamLimit") == limit {
+		return nil
+	}
+	policy["SimultaneousStreamLimit"] = limit
+
+	result, err = c.request(http.MethodPost, "/emby/Users/"+userID+"/Policy", policy)
+	if err != nil || !result.Success {
+		return fmt.Errorf("设置并发限制失败: %v", result.Error)
+	}
+	return nil
+}
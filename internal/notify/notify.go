@@ -0,0 +1,99 @@
+// Package notify 通知渠道与路由
+// 服务层通过 Sender 发送通知，由 Router 按配置把事件分发到 Telegram、Webhook、邮件、ntfy、Bark 等渠道
+package notify
+
+import (
+	"errors"
+	"strings"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// Event 通知事件类型（对应配置 notify.routes 的 key）
+type Event string
+
+const (
+	EventExpiryWarning Event = "expiry_warning" // 到期预警
+	EventLifecycle     Event = "lifecycle"      // 到期后生命周期变化（宽限、停用、封存、删除）
+	EventInactive      Event = "inactive"       // 不活跃禁用 / 删除
+	EventStreamLimit   Event = "stream_limit"   // 超出并发限制被终止播放
+	EventMemberLeft    Event = "member_left"    // 用户退群
+	EventSharing       Event = "sharing"        // 疑似账户共享上报与处理
+	EventReferral      Event = "referral"       // 邀请返利到账
+	EventEmbyWebhook   Event = "emby_webhook"   // Emby Webhook 事件
+	EventReport        Event = "report"         // 定时任务报告
+)
+
+// Audience 通知对象
+type Audience string
+
+const (
+	AudienceAdmin Audience = "admin" // Owner 与管理员
+	AudienceOwner Audience = "owner" // 仅 Owner（使用管理员路由）
+	AudienceUser  Audience = "user"  // 单个用户
+)
+
+// 渠道名称
+const (
+	ChannelTelegram = "telegram"
+	ChannelWebhook  = "webhook"
+	ChannelSMTP     = "smtp"
+	ChannelNtfy     = "ntfy"
+	ChannelBark     = "bark"
+)
+
+var (
+	ErrNoChannel     = errors.New("没有可用的通知渠道")
+	ErrNotConfigured = errors.New("通知渠道未配置")
+)
+
+// 消息附带的操作
+const (
+	ActionRenew           = "renew"            // 续期入口（积分续期、注册码续期、积分商城）
+	ActionSharingDecision = "sharing_decision" // 处理疑似账户共享（ID 为判定记录）
+)
+
+// Action 消息附带的操作，Telegram 渠道通过 MarkupBuilder 生成内联按钮，其他渠道忽略
+type Action struct {
+	Kind string
+	ID   uint
+}
+
+// MarkupBuilder 按操作生成 Telegram 内联键盘，由 Bot 层在初始化时提供
+type MarkupBuilder func(action *Action) *tele.ReplyMarkup
+
+// Message 通知消息
+type Message struct {
+	Event  Event
+	Title  string  // 标题（邮件主题、推送标题）
+	Text   string  // 正文，Telegram Markdown 格式
+	Action *Action // 附带的操作按钮，仅 Telegram 渠道使用
+}
+
+// PlainText 去除 Markdown 标记后的正文，用于非 Telegram 渠道
+func (m *Message) PlainText() string {
+	return markdownReplacer.Replace(m.Text)
+}
+
+var markdownReplacer = strings.NewReplacer("**", "", "__", "", "`", "")
+
+// Target 通知目标
+type Target struct {
+	Audience Audience
+	UserID   int64 // 用户 TG ID（Audience 为 user 时有效）
+}
+
+// Notifier 通知渠道
+type Notifier interface {
+	// Name 渠道名称，与路由配置中的名称对应
+	Name() string
+	// Send 发送通知
+	Send(to Target, msg *Message) error
+}
+
+// Sender 服务层使用的通知接口，测试时可替换为假实现
+type Sender interface {
+	NotifyUser(tgID int64, msg *Message) error
+	NotifyAdmins(msg *Message) error
+	NotifyOwner(msg *Message) error
+}
@@ -0,0 +1,117 @@
+// Package notify ntfy 与 Bark 推送渠道
+package notify
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// newPushClient 创建推送渠道共用的 HTTP 客户端
+func newPushClient() *resty.Client {
+	client := resty.New()
+	client.SetTimeout(10 * time.Second)
+	client.SetRetryCount(2)
+	client.SetRetryWaitTime(1 * time.Second)
+	return client
+}
+
+// pushTitle 推送标题，用户通知附带 TG ID 以便区分
+func pushTitle(to Target, msg *Message) string {
+	if to.Audience == AudienceUser {
+		return fmt.Sprintf("%s (TG: %d)", msg.Title, to.UserID)
+	}
+	return msg.Title
+}
+
+// Ntfy ntfy 推送
+type Ntfy struct {
+	url        string
+	token      string
+	httpClient *resty.Client
+}
+
+// NewNtfy 创建 ntfy 渠道
+func NewNtfy(cfg config.NtfyConfig) *Ntfy {
+	server := cfg.Server
+	if server == "" {
+		server = "https://ntfy.sh"
+	}
+	return &Ntfy{
+		url:        strings.TrimSuffix(server, "/") + "/" + cfg.Topic,
+		token:      cfg.Token,
+		httpClient: newPushClient(),
+	}
+}
+
+// Name 渠道名称
+func (n *Ntfy) Name() string {
+	return ChannelNtfy
+}
+
+// Send 发送推送
+func (n *Ntfy) Send(to Target, msg *Message) error {
+	req := n.httpClient.R().
+		SetHeader("Title", mime.BEncoding.Encode("UTF-8", pushTitle(to, msg))).
+		SetHeader("Tags", string(msg.Event)).
+		SetBody(msg.PlainText())
+	if n.token != "" {
+		req.SetAuthToken(n.token)
+	}
+
+	resp, err := req.Post(n.url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("ntfy 返回状态码 %d", resp.StatusCode())
+	}
+	return nil
+}
+
+// Bark Bark 推送
+type Bark struct {
+	url        string
+	httpClient *resty.Client
+}
+
+// NewBark 创建 Bark 渠道
+func NewBark(cfg config.BarkConfig) *Bark {
+	server := cfg.Server
+	if server == "" {
+		server = "https://api.day.app"
+	}
+	return &Bark{
+		url:        strings.TrimSuffix(server, "/") + "/" + cfg.Key,
+		httpClient: newPushClient(),
+	}
+}
+
+// Name 渠道名称
+func (b *Bark) Name() string {
+	return ChannelBark
+}
+
+// Send 发送推送
+func (b *Bark) Send(to Target, msg *Message) error {
+	resp, err := b.httpClient.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]string{
+			"title": pushTitle(to, msg),
+			"body":  msg.PlainText(),
+			"group": string(msg.Event),
+		}).
+		Post(b.url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("bark 返回状态码 %d", resp.StatusCode())
+	}
+	return nil
+}
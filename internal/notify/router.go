@@ -0,0 +1,144 @@
+// Package notify 通知路由
+package notify
+
+import (
+	"errors"
+	"sync"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// Router 按事件类型与通知对象把消息分发到对应渠道
+type Router struct {
+	channels map[string]Notifier
+	cfg      *config.NotifyConfig
+	mu       sync.RWMutex
+}
+
+var (
+	instance *Router
+	once     sync.Once
+)
+
+// NewRouter 创建通知路由
+func NewRouter(cfg *config.NotifyConfig) *Router {
+	if cfg == nil {
+		cfg = &config.NotifyConfig{}
+	}
+	return &Router{
+		channels: make(map[string]Notifier),
+		cfg:      cfg,
+	}
+}
+
+// Init 根据配置初始化全局路由并注册已配置的渠道，markup 为 Telegram 渠道生成操作按钮
+func Init(cfg *config.Config, bot *tele.Bot, markup MarkupBuilder) *Router {
+	r := Get()
+	r.mu.Lock()
+	r.cfg = &cfg.Notify
+	r.mu.Unlock()
+
+	if bot != nil {
+		r.Register(NewTelegram(bot, cfg, markup))
+	}
+	if cfg.Notify.Webhook.URL != "" {
+		r.Register(NewWebhook(cfg.Notify.Webhook))
+	}
+	if cfg.Notify.SMTP.Host != "" && len(cfg.Notify.SMTP.To) > 0 {
+		r.Register(NewSMTP(cfg.Notify.SMTP))
+	}
+	if cfg.Notify.Ntfy.Topic != "" {
+		r.Register(NewNtfy(cfg.Notify.Ntfy))
+	}
+	if cfg.Notify.Bark.Key != "" {
+		r.Register(NewBark(cfg.Notify.Bark))
+	}
+
+	logger.Info().Strs("channels", r.Channels()).Msg("通知渠道初始化完成")
+	return r
+}
+
+// Get 获取全局路由（未初始化时不包含任何渠道）
+func Get() *Router {
+	once.Do(func() {
+		instance = NewRouter(nil)
+	})
+	return instance
+}
+
+// Register 注册渠道，同名渠道会被替换
+func (r *Router) Register(n Notifier) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.channels[n.Name()] = n
+}
+
+// Channels 已注册的渠道名称
+func (r *Router) Channels() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.channels))
+	for name := range r.channels {
+		names = append(names, name)
+	}
+	return names
+}
+
+// NotifyUser 通知单个用户
+func (r *Router) NotifyUser(tgID int64, msg *Message) error {
+	return r.dispatch(Target{Audience: AudienceUser, UserID: tgID}, msg)
+}
+
+// NotifyAdmins 通知 Owner 与管理员
+func (r *Router) NotifyAdmins(msg *Message) error {
+	return r.dispatch(Target{Audience: AudienceAdmin}, msg)
+}
+
+// NotifyOwner 只通知 Owner
+func (r *Router) NotifyOwner(msg *Message) error {
+	return r.dispatch(Target{Audience: AudienceOwner}, msg)
+}
+
+// dispatch 发送到路由配置的所有渠道，至少一个渠道成功即视为成功
+func (r *Router) dispatch(to Target, msg *Message) error {
+	r.mu.RLock()
+	route := r.cfg.RouteFor(string(msg.Event))
+	names := route.User
+	if to.Audience != AudienceUser {
+		names = route.Admin
+	}
+	targets := make([]Notifier, 0, len(names))
+	for _, name := range names {
+		if n, ok := r.channels[name]; ok {
+			targets = append(targets, n)
+		} else {
+			logger.Debug().Str("channel", name).Str("event", string(msg.Event)).Msg("通知渠道未启用，已跳过")
+		}
+	}
+	r.mu.RUnlock()
+
+	if len(targets) == 0 {
+		return ErrNoChannel
+	}
+
+	var errs []error
+	for _, n := range targets {
+		if err := n.Send(to, msg); err != nil {
+			logger.Debug().
+				Err(err).
+				Str("channel", n.Name()).
+				Str("event", string(msg.Event)).
+				Int64("user", to.UserID).
+				Msg("发送通知失败")
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == len(targets) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+// Package notify 通知路由测试
+package notify
+
+import (
+	"errors"
+	"sort"
+	"testing"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// fakeNotifier 记录收到的通知
+type fakeNotifier struct {
+	name string
+	err  error
+	sent []Target
+}
+
+func (f *fakeNotifier) Name() string { return f.name }
+
+func (f *fakeNotifier) Send(to Target, msg *Message) error {
+	f.sent = append(f.sent, to)
+	return f.err
+}
+
+func newTestRouter(routes map[string]config.NotifyRoute, channels ...*fakeNotifier) *Router {
+	r := NewRouter(&config.NotifyConfig{Routes: routes})
+	for _, n := range channels {
+		r.Register(n)
+	}
+	return r
+}
+
+func TestRouterDispatch(t *testing.T) {
+	routes := map[string]config.NotifyRoute{
+		"*":                   {Admin: []string{ChannelTelegram}, User: []string{ChannelTelegram}},
+		string(EventReport):   {Admin: []string{ChannelTelegram, ChannelNtfy}},
+		string(EventInactive): {User: []string{ChannelWebhook, ChannelBark}},
+	}
+
+	tests := []struct {
+		name     string
+		event    Event
+		admin    bool
+		wantSent []string
+	}{
+		{"默认路由通知用户", EventExpiryWarning, false, []string{ChannelTelegram}},
+		{"默认路由通知管理员", EventMemberLeft, true, []string{ChannelTelegram}},
+		{"管理员多渠道", EventReport, true, []string{ChannelNtfy, ChannelTelegram}},
+		{"事件未配置用户渠道", EventReport, false, nil},
+		{"未注册的渠道被跳过", EventInactive, false, []string{ChannelWebhook}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tg := &fakeNotifier{name: ChannelTelegram}
+			ntfy := &fakeNotifier{name: ChannelNtfy}
+			webhook := &fakeNotifier{name: ChannelWebhook}
+			r := newTestRouter(routes, tg, ntfy, webhook)
+
+			msg := &Message{Event: tt.event, Text: "test"}
+			var err error
+			if tt.admin {
+				err = r.NotifyAdmins(msg)
+			} else {
+				err = r.NotifyUser(42, msg)
+			}
+
+			var got []string
+			for _, n := range []*fakeNotifier{tg, ntfy, webhook} {
+				if len(n.sent) > 0 {
+					got = append(got, n.name)
+				}
+			}
+			sort.Strings(got)
+
+			if len(got) != len(tt.wantSent) {
+				t.Fatalf("sent to %v, want %v", got, tt.wantSent)
+			}
+			for i := range got {
+				if got[i] != tt.wantSent[i] {
+					t.Errorf("sent to %v, want %v", got, tt.wantSent)
+				}
+			}
+			if len(tt.wantSent) == 0 && !errors.Is(err, ErrNoChannel) {
+				t.Errorf("err = %v, want ErrNoChannel", err)
+			}
+		})
+	}
+}
+
+func TestRouterPartialFailure(t *testing.T) {
+	routes := map[string]config.NotifyRoute{
+		"*": {User: []string{ChannelTelegram, ChannelWebhook}},
+	}
+	failErr := errors.New("failed")
+
+	tg := &fakeNotifier{name: ChannelTelegram, err: failErr}
+	webhook := &fakeNotifier{name: ChannelWebhook}
+	if err := newTestRouter(routes, tg, webhook).NotifyUser(1, &Message{}); err != nil {
+		t.Errorf("部分渠道成功应视为成功, err = %v", err)
+	}
+
+	webhook.err = failErr
+	if err := newTestRouter(routes, tg, webhook).NotifyUser(1, &Message{}); err == nil {
+		t.Error("全部渠道失败应返回错误")
+	}
+
+	if got := tg.sent[0]; got.Audience != AudienceUser || got.UserID != 1 {
+		t.Errorf("target = %+v, want user 1", got)
+	}
+}
+
+func TestRouterDefaultRoute(t *testing.T) {
+	tg := &fakeNotifier{name: ChannelTelegram}
+	if err := newTestRouter(nil, tg).NotifyAdmins(&Message{Event: EventReport}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tg.sent) != 1 {
+		t.Errorf("未配置路由时应发送到 Telegram")
+	}
+}
+
+func TestRouterOwnerUsesAdminRoute(t *testing.T) {
+	routes := map[string]config.NotifyRoute{
+		"*": {Admin: []string{ChannelWebhook}, User: []string{ChannelTelegram}},
+	}
+	tg := &fakeNotifier{name: ChannelTelegram}
+	webhook := &fakeNotifier{name: ChannelWebhook}
+	if err := newTestRouter(routes, tg, webhook).NotifyOwner(&Message{Event: EventReport}); err != nil {
+		t.Fatal(err)
+	}
+	if len(tg.sent) != 0 || len(webhook.sent) != 1 || webhook.sent[0].Audience != AudienceOwner {
+		t.Errorf("Owner 通知应按管理员路由发送, telegram = %+v, webhook = %+v", tg.sent, webhook.sent)
+	}
+}
+
+func TestMessagePlainText(t *testing.T) {
+	msg := &Message{Text: "⚠️ **账户已过期**\n\n用户 `test` __提醒__"}
+	if got, want := msg.PlainText(), "⚠️ 账户已过期\n\n用户 test 提醒"; got != want {
+		t.Errorf("PlainText() = %q, want %q", got, want)
+	}
+}
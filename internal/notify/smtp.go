@@ -0,0 +1,106 @@
+// Package notify SMTP 邮件渠道
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// SMTP 通过邮件发送通知，收件人为配置中的固定地址
+type SMTP struct {
+	cfg config.SMTPConfig
+}
+
+// NewSMTP 创建邮件渠道
+func NewSMTP(cfg config.SMTPConfig) *SMTP {
+	if cfg.Port == 0 {
+		cfg.Port = 465
+	}
+	if cfg.From == "" {
+		cfg.From = cfg.Username
+	}
+	return &SMTP{cfg: cfg}
+}
+
+// Name 渠道名称
+func (s *SMTP) Name() string {
+	return ChannelSMTP
+}
+
+// Send 发送邮件
+func (s *SMTP) Send(to Target, msg *Message) error {
+	subject := msg.Title
+	if to.Audience == AudienceUser {
+		subject = fmt.Sprintf("%s (TG: %d)", subject, to.UserID)
+	}
+	body := buildMail(s.cfg.From, s.cfg.To, subject, msg.PlainText())
+
+	addr := net.JoinHostPort(s.cfg.Host, strconv.Itoa(s.cfg.Port))
+	var auth smtp.Auth
+	if s.cfg.Username != "" {
+		auth = smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)
+	}
+
+	// 非 465 端口由 SendMail 自动协商 STARTTLS
+	if s.cfg.Port != 465 {
+		return smtp.SendMail(addr, auth, s.cfg.From, s.cfg.To, body)
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: 10 * time.Second}, "tcp", addr, &tls.Config{ServerName: s.cfg.Host})
+	if err != nil {
+		return err
+	}
+	client, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if auth != nil {
+		if err := client.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	for _, rcpt := range s.cfg.To {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// buildMail 组装纯文本邮件
+func buildMail(from string, to []string, subject, text string) []byte {
+	var sb strings.Builder
+	sb.WriteString("From: " + from + "\r\n")
+	sb.WriteString("To: " + strings.Join(to, ", ") + "\r\n")
+	sb.WriteString("Subject: " + mime.BEncoding.Encode("UTF-8", subject) + "\r\n")
+	sb.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	sb.WriteString("MIME-Version: 1.0\r\n")
+	sb.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	sb.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	sb.WriteString(strings.ReplaceAll(text, "\n", "\r\n"))
+	return []byte(sb.String())
+}
@@ -0,0 +1,66 @@
+// Package notify Telegram 渠道
+package notify
+
+import (
+	"errors"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// Telegram 通过 Bot 私聊发送通知
+type Telegram struct {
+	bot    *tele.Bot
+	cfg    *config.Config
+	markup MarkupBuilder
+}
+
+// NewTelegram 创建 Telegram 渠道，markup 为空时不附带按钮
+func NewTelegram(bot *tele.Bot, cfg *config.Config, markup MarkupBuilder) *Telegram {
+	return &Telegram{bot: bot, cfg: cfg, markup: markup}
+}
+
+// Name 渠道名称
+func (t *Telegram) Name() string {
+	return ChannelTelegram
+}
+
+// Send 发送通知；管理员通知发送给 Owner 与所有管理员，Owner 通知只发送给 Owner
+func (t *Telegram) Send(to Target, msg *Message) error {
+	opts := []interface{}{tele.ModeMarkdown}
+	if msg.Action != nil && t.markup != nil {
+		if markup := t.markup(msg.Action); markup != nil {
+			opts = append(opts, markup)
+		}
+	}
+
+	if to.Audience == AudienceUser {
+		_, err := t.bot.Send(&tele.Chat{ID: to.UserID}, msg.Text, opts...)
+		return err
+	}
+
+	recipients := []int64{t.cfg.Owner}
+	if to.Audience == AudienceAdmin {
+		recipients = append(recipients, t.cfg.Admins...)
+	}
+
+	var errs []error
+	sent := make(map[int64]bool)
+	for _, id := range recipients {
+		if id == 0 || sent[id] {
+			continue
+		}
+		sent[id] = true
+		if _, err := t.bot.Send(&tele.Chat{ID: id}, msg.Text, opts...); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(sent) == 0 {
+		return ErrNotConfigured
+	}
+	if len(errs) == len(sent) {
+		return errors.Join(errs...)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+// Package notify 通用 JSON Webhook 渠道
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// Webhook 以 JSON POST 推送通知
+type Webhook struct {
+	url        string
+	httpClient *resty.Client
+}
+
+// WebhookPayload 推送内容
+type WebhookPayload struct {
+	Event    Event    `json:"event"`
+	Audience Audience `json:"audience"`
+	UserID   int64    `json:"user_id,omitempty"`
+	Title    string   `json:"title"`
+	Text     string   `json:"text"`
+	Time     string   `json:"time"`
+}
+
+// NewWebhook 创建 Webhook 渠道
+func NewWebhook(cfg config.NotifyWebhookConfig) *Webhook {
+	client := resty.New()
+	client.SetTimeout(10 * time.Second)
+	client.SetRetryCount(2)
+	client.SetRetryWaitTime(1 * time.Second)
+	client.SetHeader("Content-Type", "application/json")
+	client.SetHeaders(cfg.Headers)
+
+	return &Webhook{url: cfg.URL, httpClient: client}
+}
+
+// Name 渠道名称
+func (w *Webhook) Name() string {
+	return ChannelWebhook
+}
+
+// Send 发送通知
+func (w *Webhook) Send(to Target, msg *Message) error {
+	payload := WebhookPayload{
+		Event:    msg.Event,
+		Audience: to.Audience,
+		UserID:   to.UserID,
+		Title:    msg.Title,
+		Text:     msg.PlainText(),
+		Time:     time.Now().Format(time.RFC3339),
+	}
+
+	resp, err := w.httpClient.R().SetBody(payload).Post(w.url)
+	if err != nil {
+		return err
+	}
+	if resp.IsError() {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode())
+	}
+	return nil
+}
@@ -9,7 +9,9 @@ import (
 	tele "gopkg.in/telebot.v3"
 
 	"github.com/smysle/sakura-embyboss-go/internal/bot/handlers"
+	"github.com/smysle/sakura-embyboss-go/internal/bot/session"
 	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
 	"github.com/smysle/sakura-embyboss-go/internal/service"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
@@ -19,6 +21,9 @@ type Scheduler struct {
 	cron *gocron.Scheduler
 	cfg  *config.Config
 	bot  *tele.Bot
+
+	streamLimit     *service.StreamLimitService     // 并发巡检需要跨次保留会话首次出现时间
+	playbackHistory *service.PlaybackHistoryService // 本地播放记录轮询
 }
 
 var instance *Scheduler
@@ -74,6 +79,12 @@ func (s *Scheduler) registerJobs() {
 		logger.Info().Msg("已注册: 到期检测任务 (每天 01:00)")
 	}
 
+	// 到期预警 - 每天上午 10 点
+	if cfg.ExpiryWarning {
+		s.cron.Every(1).Day().At("10:00").Do(s.checkExpiryWarnings)
+		logger.Info().Ints("days", cfg.WarningDays).Msg("已注册: 到期预警任务 (每天 10:00)")
+	}
+
 	// 日榜 - 每天晚上 22 点
 	if cfg.DayRank {
 		s.cron.Every(1).Day().At("22:00").Do(s.generateDayRanks)
@@ -86,6 +97,18 @@ func (s *Scheduler) registerJobs() {
 		logger.Info().Msg("已注册: 周榜任务 (每周日 22:00)")
 	}
 
+	// 媒体日榜 - 每天晚上 22 点
+	if cfg.DayMediaRank {
+		s.cron.Every(1).Day().At("22:00").Do(s.generateDayMediaRanks)
+		logger.Info().Msg("已注册: 媒体日榜任务 (每天 22:00)")
+	}
+
+	// 媒体周榜 - 每周日晚上 22 点
+	if cfg.WeekMediaRank {
+		s.cron.Every(1).Week().Sunday().At("22:00").Do(s.generateWeekMediaRanks)
+		logger.Info().Msg("已注册: 媒体周榜任务 (每周日 22:00)")
+	}
+
 	// 活跃度检测 - 每天凌晨 2 点
 	if cfg.LowActivity {
 		s.cron.Every(1).Day().At("02:00").Do(s.checkLowActivity)
@@ -115,6 +138,35 @@ func (s *Scheduler) registerJobs() {
 		s.cron.Every(4).Hours().Do(s.syncFavorites)
 		logger.Info().Msg("已注册: 收藏同步任务 (每 4 小时)")
 	}
+
+	// 定时注册 - 每分钟开放到时间的窗口、关闭超时的窗口
+	s.cron.Every(1).Minute().Do(s.tickRegisterWindows)
+	logger.Info().Msg("已注册: 定时注册任务 (每分钟)")
+
+	// 并发播放巡检
+	if s.cfg.StreamLimit.Enabled {
+		s.streamLimit = service.NewStreamLimitService()
+		s.cron.Every(s.cfg.StreamLimit.Interval).Seconds().Do(s.enforceStreamLimits)
+		logger.Info().Int("interval", s.cfg.StreamLimit.Interval).Msg("已注册: 并发播放巡检任务")
+	}
+
+	// 本地播放记录轮询与清理
+	if s.cfg.PlaybackHistory.Enabled {
+		s.playbackHistory = service.NewPlaybackHistoryService()
+		s.cron.Every(s.cfg.PlaybackHistory.PollInterval).Seconds().Do(s.pollPlaybackSessions)
+		s.cron.Every(1).Day().At("04:00").Do(s.cleanupPlaybackHistory)
+		logger.Info().Int("interval", s.cfg.PlaybackHistory.PollInterval).Msg("已注册: 播放会话轮询任务")
+	}
+
+	// 账户共享检测 - 每天 05:00
+	if s.cfg.Sharing.Enabled {
+		s.cron.Every(1).Day().At("05:00").Do(s.detectSharing)
+		logger.Info().Int("window_days", s.cfg.Sharing.WindowDays).Msg("已注册: 账户共享检测任务 (每天 05:00)")
+	}
+
+	// 过期会话清理 - 每分钟
+	s.cron.Every(1).Minute().Do(s.cleanupSessions)
+	logger.Info().Msg("已注册: 过期会话清理任务 (每分钟)")
 }
 
 // AddJob 添加自定义任务
@@ -128,12 +180,83 @@ func (s *Scheduler) RemoveJob(tag string) {
 	s.cron.RemoveByTag(tag)
 }
 
+// enforceStreamLimits 巡检活动会话，终止超出等级限制的播放
+func (s *Scheduler) enforceStreamLimits() {
+	result, err := s.streamLimit.Enforce()
+	if err != nil {
+		logger.Warn().Err(err).Msg("并发播放巡检失败")
+		return
+	}
+	if result.Terminated > 0 || result.Failed > 0 {
+		logger.Info().
+			Int("sessions", result.Sessions).
+			Int("users", result.Users).
+			Int("terminated", result.Terminated).
+			Int("failed", result.Failed).
+			Msg("并发播放巡检完成")
+	}
+}
+
+// pollPlaybackSessions 轮询 Emby 活动会话，更新本地播放记录
+func (s *Scheduler) pollPlaybackSessions() {
+	result, err := s.playbackHistory.Poll()
+	if err != nil {
+		logger.Warn().Err(err).Msg("播放会话轮询失败")
+		return
+	}
+	if result.Started > 0 || result.Stopped > 0 {
+		logger.Debug().
+			Int("sessions", result.Sessions).
+			Int("started", result.Started).
+			Int("updated", result.Updated).
+			Int("stopped", result.Stopped).
+			Msg("播放会话轮询完成")
+	}
+}
+
+// cleanupPlaybackHistory 清理超过保留天数的播放记录
+func (s *Scheduler) cleanupPlaybackHistory() {
+	count, err := s.playbackHistory.Cleanup()
+	if err != nil {
+		logger.Warn().Err(err).Msg("清理播放记录失败")
+		return
+	}
+	if count > 0 {
+		logger.Info().Int64("count", count).Msg("已清理过期播放记录")
+	}
+}
+
+// detectSharing 检测疑似共享的账户并上报管理员
+func (s *Scheduler) detectSharing() {
+	logger.Info().Msg("执行定时任务: 账户共享检测")
+
+	result, err := service.NewSharingDetector().Run()
+	if err != nil {
+		logger.Error().Err(err).Msg("账户共享检测失败")
+		return
+	}
+
+	logger.Info().
+		Int("checked", result.Checked).
+		Int("flagged", result.Flagged).
+		Int("reported", result.Reported).
+		Int("skipped", result.Skipped).
+		Int("failed", result.Failed).
+		Msg("账户共享检测完成")
+}
+
+// cleanupSessions 清理过期的 Bot 会话
+func (s *Scheduler) cleanupSessions() {
+	if count := session.GetManager().CleanupExpired(); count > 0 {
+		logger.Debug().Int("count", count).Msg("已清理过期会话")
+	}
+}
+
 // checkExpired 检查过期用户
 func (s *Scheduler) checkExpired() {
 	logger.Info().Msg("执行定时任务: 到期检测")
 
 	expirySvc := service.NewExpiryService()
-	expirySvc.SetBot(s.bot)
 
 	// 检测并处理过期用户
 	result, err := expirySvc.CheckExpired()
@@ -145,36 +268,57 @@ func (s *Scheduler) checkExpired() {
 	logger.Info().
 		Int("checked", result.Checked).
 		Int("expired", result.Expired).
+		Int("grace", result.Grace).
 		Int("disabled", result.Disabled).
+		Int("frozen", result.Frozen).
+		Int("deleted", result.Deleted).
+		Int("restored", result.Restored).
 		Int("failed", result.Failed).
 		Msg("到期检测完成")
 
-	// 发送预警（提前 3 天）
-	warningResult, err := expirySvc.CheckWarning(3)
-	if err != nil {
-		logger.Warn().Err(err).Msg("发送预警失败")
-	} else if warningResult.WarningSent > 0 {
-		logger.Info().Int("sent", warningResult.WarningSent).Msg("已发送过期预警")
-	}
-
-	// 向 Owner 发送报告
-	if s.bot != nil && s.cfg.Owner != 0 && result.Expired > 0 {
+	// 向管理员发送报告
+	if result.Expired > 0 || result.Failed > 0 {
 		report := fmt.Sprintf(
 			"📊 **到期检测报告**\n\n"+
 				"检测用户: %d\n"+
-				"过期用户: %d\n"+
+				"状态变更: %d\n"+
+				"进入宽限: %d\n"+
 				"成功禁用: %d\n"+
-				"禁用失败: %d",
+				"封存账户: %d\n"+
+				"删除账户: %d\n"+
+				"续期恢复: %d\n"+
+				"处理失败: %d",
 			result.Checked,
 			result.Expired,
+			result.Grace,
 			result.Disabled,
+			result.Frozen,
+			result.Deleted,
+			result.Restored,
 			result.Failed,
 		)
-		chat := &tele.Chat{ID: s.cfg.Owner}
-		s.bot.Send(chat, report, tele.ModeMarkdown)
+		s.sendReport("到期检测报告", report)
 	}
 }
 
+// checkExpiryWarnings 向即将到期的用户发送续期提醒
+func (s *Scheduler) checkExpiryWarnings() {
+	logger.Info().Msg("执行定时任务: 到期预警")
+
+	expirySvc := service.NewExpiryService()
+
+	result, err := expirySvc.CheckWarning(s.cfg.Scheduler.WarningDays)
+	if err != nil {
+		logger.Error().Err(err).Msg("发送到期预警失败")
+		return
+	}
+
+	logger.Info().
+		Int("checked", result.Checked).
+		Int("sent", result.WarningSent).
+		Msg("到期预警完成")
+}
+
 // generateDayRanks 生成并发送日榜
 func (s *Scheduler) generateDayRanks() {
 	logger.Info().Msg("执行定时任务: 生成日榜")
@@ -231,12 +375,47 @@ func (s *Scheduler) generateWeekRanks() {
 	}
 }
 
+// generateDayMediaRanks 生成并发送电影/剧集日榜
+func (s *Scheduler) generateDayMediaRanks() {
+	logger.Info().Msg("执行定时任务: 生成媒体日榜")
+	s.sendMediaRanks(service.RankTypeDay)
+}
+
+// generateWeekMediaRanks 生成并发送电影/剧集周榜
+func (s *Scheduler) generateWeekMediaRanks() {
+	logger.Info().Msg("执行定时任务: 生成媒体周榜")
+	s.sendMediaRanks(service.RankTypeWeek)
+}
+
+// sendMediaRanks 推送媒体榜到群组
+func (s *Scheduler) sendMediaRanks(rankType service.RankType) {
+	if s.bot == nil {
+		logger.Error().Msg("Bot 未设置，无法发送媒体榜")
+		return
+	}
+
+	var chatID int64
+	if len(s.cfg.Groups) > 0 {
+		chatID = s.cfg.Groups[0]
+	}
+	if chatID == 0 {
+		logger.Warn().Msg("未配置群组 ID，跳过媒体榜推送")
+		return
+	}
+
+	handler := handlers.NewLeaderboardHandler()
+	if err := handler.SendMediaRankToChat(s.bot, chatID, rankType); err != nil {
+		logger.Error().Err(err).Str("type", string(rankType)).Msg("发送媒体榜失败")
+	} else {
+		logger.Info().Int64("chat_id", chatID).Str("type", string(rankType)).Msg("媒体榜发送成功")
+	}
+}
+
 // checkLowActivity 检查低活跃用户
 func (s *Scheduler) checkLowActivity() {
 	logger.Info().Msg("执行定时任务: 活跃度检测")
 
 	activitySvc := service.NewActivityService()
-	activitySvc.SetBot(s.bot)
 
 	result, err := activitySvc.CheckLowActivity()
 	if err != nil {
@@ -251,10 +430,24 @@ func (s *Scheduler) checkLowActivity() {
 		Int("deleted", result.Deleted).
 		Msg("活跃度检测完成")
 
-	// 向 Owner 发送报告
-	if s.bot != nil && s.cfg.Owner != 0 && (result.Inactive > 0 || result.Deleted > 0) {
-		chat := &tele.Chat{ID: s.cfg.Owner}
-		s.bot.Send(chat, result.FormatResult(), tele.ModeMarkdown)
+	// 向管理员发送报告
+	if result.Inactive > 0 || result.Deleted > 0 {
+		s.sendReport("活跃度检测报告", result.FormatResult())
+	}
+}
+
+// tickRegisterWindows 开放 / 关闭定时注册
+func (s *Scheduler) tickRegisterWindows() {
+	windowSvc := service.NewRegisterWindowService()
+	windowSvc.SetBot(s.bot)
+	windowSvc.Tick(time.Now())
+}
+
+// sendReport 通过通知路由向管理员发送定时任务报告
+func (s *Scheduler) sendReport(title, text string) {
+	msg := &notify.Message{Event: notify.EventReport, Title: title, Text: text}
+	if err := notify.Get().NotifyAdmins(msg); err != nil {
+		logger.Debug().Err(err).Str("report", title).Msg("发送定时任务报告失败")
 	}
 }
 
@@ -263,9 +456,10 @@ func (s *Scheduler) backupDatabase() {
 	logger.Info().Msg("执行定时任务: 数据库备份")
 
 	backupSvc := service.NewBackupService()
+	backupSvc.SetBot(s.bot)
 
-	// 执行备份
-	result, err := backupSvc.Backup(true)
+	// 执行备份（按 database.backup_engine，按 backup_max_count 清理旧备份并上传到异地备份目标）
+	result, err := backupSvc.Run("")
 	if err != nil {
 		logger.Error().Err(err).Msg("定时备份失败")
 		return
@@ -276,14 +470,6 @@ func (s *Scheduler) backupDatabase() {
 		Int64("size", result.Size).
 		Int("records", result.Records).
 		Msg("定时备份完成")
-
-	// 清理旧备份（7天前）
-	deleted, err := backupSvc.CleanOldBackups(7)
-	if err != nil {
-		logger.Warn().Err(err).Msg("清理旧备份失败")
-	} else if deleted > 0 {
-		logger.Info().Int("deleted", deleted).Msg("已清理旧备份")
-	}
 }
 
 // generateDayPlayRanks 生成用户日播放榜
@@ -333,6 +519,10 @@ func (s *Scheduler) RunNow(taskName string) error {
 		s.generateDayRanks()
 	case "weekrank":
 		s.generateWeekRanks()
+	case "daymediarank":
+		s.generateDayMediaRanks()
+	case "weekmediarank":
+		s.generateWeekMediaRanks()
 	case "dayplayrank":
 		s.generateDayPlayRanks()
 	case "weekplayrank":
@@ -345,6 +535,8 @@ func (s *Scheduler) RunNow(taskName string) error {
 		s.backupDatabase()
 	case "sync_favorites":
 		s.syncFavorites()
+	case "sharing":
+		s.detectSharing()
 	default:
 		logger.Warn().Str("task", taskName).Msg("未知任务")
 	}
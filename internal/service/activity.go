@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"time"
 
-	tele "gopkg.in/telebot.v3"
-
 	"github.com/smysle/sakura-embyboss-go/internal/config"
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -19,7 +18,7 @@ type ActivityService struct {
 	embyRepo   *repository.EmbyRepository
 	embyClient *emby.Client
 	cfg        *config.Config
-	bot        *tele.Bot
+	notifier   notify.Sender
 }
 
 // ActivityResult 活跃度检测结果
@@ -38,14 +37,10 @@ func NewActivityService() *ActivityService {
 		embyRepo:   repository.NewEmbyRepository(),
 		embyClient: emby.GetClient(),
 		cfg:        config.Get(),
+		notifier:   notify.Get(),
 	}
 }
 
-// SetBot 设置 Bot 实例
-func (s *ActivityService) SetBot(bot *tele.Bot) {
-	s.bot = bot
-}
-
 // CheckLowActivity 检测低活跃用户
 func (s *ActivityService) CheckLowActivity() (*ActivityResult, error) {
 	result := &ActivityResult{
@@ -64,6 +59,14 @@ func (s *ActivityService) CheckLowActivity() (*ActivityResult, error) {
 		return nil, fmt.Errorf("获取 Emby 用户列表失败: %w", err)
 	}
 
+	// 启用本地播放记录时，用最近一次播放时间补充 Emby 的最后活动时间
+	var lastPlayed map[string]time.Time
+	if s.cfg.PlaybackHistory.Enabled {
+		if lastPlayed, err = NewPlaybackHistoryService().LastPlayed(); err != nil {
+			logger.Warn().Err(err).Msg("查询本地播放记录失败，仅使用 Emby 最后活动时间")
+		}
+	}
+
 	result.Checked = len(users)
 	now := time.Now()
 	cutoffDate := now.AddDate(0, 0, -checkDays)
@@ -85,6 +88,11 @@ func (s *ActivityService) CheckLowActivity() (*ActivityResult, error) {
 			continue
 		}
 
+		// 过期账户由到期检测的生命周期流程处理
+		if embyUser.CurrentStatus() != models.StatusActive {
+			continue
+		}
+
 		// 处理已禁用用户（等级 c）
 		if embyUser.Lv == models.LevelC {
 			// 检查是否需要删除
@@ -97,7 +105,7 @@ func (s *ActivityService) CheckLowActivity() (*ActivityResult, error) {
 		// 处理正常用户（等级 b）
 		if embyUser.Lv == models.LevelB {
 			// 获取最后活跃时间
-			lastActivity := user.LastSeen
+			lastActivity := latestActivity(user.LastSeen, lastPlayed[user.ID])
 			isInactive := false
 
 			if lastActivity == nil {
@@ -122,6 +130,7 @@ func (s *ActivityService) CheckLowActivity() (*ActivityResult, error) {
 					s.embyRepo.UpdateFields(embyUser.TG, map[string]interface{}{
 						"lv": models.LevelC,
 					})
+					DisableServers(embyUser.TG)
 					result.Disabled++
 
 					// 通知用户
@@ -139,6 +148,17 @@ func (s *ActivityService) CheckLowActivity() (*ActivityResult, error) {
 	return result, nil
 }
 
+// latestActivity 取 Emby 最后活动时间与本地最后播放时间中较晚的一个
+func latestActivity(lastSeen *time.Time, lastPlayed time.Time) *time.Time {
+	if lastPlayed.IsZero() {
+		return lastSeen
+	}
+	if lastSeen == nil || lastPlayed.After(*lastSeen) {
+		return &lastPlayed
+	}
+	return lastSeen
+}
+
 // handleDisabledUser 处理已禁用的用户（检查是否需要删除）
 func (s *ActivityService) handleDisabledUser(embyUser *models.Emby, user emby.User, result *ActivityResult) error {
 	// 检查是否超过冻结期
@@ -164,6 +184,7 @@ func (s *ActivityService) handleDisabledUser(embyUser *models.Emby, user emby.Us
 				return fmt.Errorf("删除用户失败: %w", err)
 			}
 		}
+		DeleteServerAccounts(embyUser.TG)
 
 		// 清空数据库记录
 		s.embyRepo.UpdateFields(embyUser.TG, map[string]interface{}{
@@ -188,10 +209,6 @@ func (s *ActivityService) handleDisabledUser(embyUser *models.Emby, user emby.Us
 
 // notifyInactiveUser 通知不活跃用户
 func (s *ActivityService) notifyInactiveUser(tgID int64, days int) {
-	if s.bot == nil {
-		return
-	}
-
 	text := fmt.Sprintf(
 		"⚠️ **账户已被禁用**\n\n"+
 			"由于您 **%d 天**未使用 Emby，账户已被暂停。\n\n"+
@@ -199,24 +216,20 @@ func (s *ActivityService) notifyInactiveUser(tgID int64, days int) {
 		days,
 	)
 
-	chat := &tele.Chat{ID: tgID}
-	if _, err := s.bot.Send(chat, text, tele.ModeMarkdown); err != nil {
+	msg := &notify.Message{Event: notify.EventInactive, Title: "账户已被禁用", Text: text}
+	if err := s.notifier.NotifyUser(tgID, msg); err != nil {
 		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送不活跃通知失败")
 	}
 }
 
 // notifyDeletedUser 通知被删除用户
 func (s *ActivityService) notifyDeletedUser(tgID int64) {
-	if s.bot == nil {
-		return
-	}
-
 	text := "🗑️ **账户已被删除**\n\n" +
 		"由于长期未使用且未解封，您的 Emby 账户已被删除。\n\n" +
 		"如需重新注册，请联系管理员。"
 
-	chat := &tele.Chat{ID: tgID}
-	if _, err := s.bot.Send(chat, text, tele.ModeMarkdown); err != nil {
+	msg := &notify.Message{Event: notify.EventInactive, Title: "账户已被删除", Text: text}
+	if err := s.notifier.NotifyUser(tgID, msg); err != nil {
 		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送删除通知失败")
 	}
 }
@@ -0,0 +1,55 @@
+// Package service 反皮套人（频道身份发言）检测
+package service
+
+import (
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// AntiChannelAction 对频道身份发言的处理方式
+type AntiChannelAction int
+
+const (
+	AntiChannelIgnore AntiChannelAction = iota // 不处理
+	AntiChannelWarn                            // 仅警告
+	AntiChannelBan                             // 删除消息并封禁该频道
+)
+
+// AntiChannelActionFor 判断群组消息是否以需要处理的频道身份发送
+// 匿名管理员（以群组身份发言）、群组的关联频道（msg.Chat.LinkedChatID，需调用方通过 getChat 填充）
+// 与关联频道的自动转发不处理
+func AntiChannelActionFor(cfg *config.Config, msg *tele.Message) AntiChannelAction {
+	if !cfg.AntiChannel.Enabled || msg == nil || msg.SenderChat == nil || msg.Chat == nil {
+		return AntiChannelIgnore
+	}
+	if msg.Chat.Type != tele.ChatGroup && msg.Chat.Type != tele.ChatSuperGroup {
+		return AntiChannelIgnore
+	}
+	if !cfg.IsInGroup(msg.Chat.ID) {
+		return AntiChannelIgnore
+	}
+	if msg.SenderChat.ID == msg.Chat.ID || msg.AutomaticForward {
+		return AntiChannelIgnore
+	}
+	if msg.Chat.LinkedChatID != 0 && msg.SenderChat.ID == msg.Chat.LinkedChatID {
+		return AntiChannelIgnore
+	}
+	if containsID(cfg.AntiChannel.WhiteList, msg.SenderChat.ID) {
+		return AntiChannelIgnore
+	}
+	if containsID(cfg.AntiChannel.WarnOnly, msg.Chat.ID) {
+		return AntiChannelWarn
+	}
+	return AntiChannelBan
+}
+
+// containsID 判断 ID 是否在列表中
+func containsID(ids []int64, id int64) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,73 @@
+// Package service 反皮套人检测测试
+package service
+
+import (
+	"testing"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+func TestAntiChannelActionFor(t *testing.T) {
+	const (
+		group     int64 = -1001
+		warnGroup int64 = -1002
+		channel   int64 = -2001
+		white     int64 = -2002
+	)
+	cfg := &config.Config{
+		Groups: []int64{group, warnGroup},
+		AntiChannel: config.AntiChannelConfig{
+			Enabled:   true,
+			WhiteList: []int64{white},
+			WarnOnly:  []int64{warnGroup},
+		},
+	}
+	msg := func(chatID int64, chatType tele.ChatType, sender *tele.Chat) *tele.Message {
+		return &tele.Message{Chat: &tele.Chat{ID: chatID, Type: chatType}, SenderChat: sender}
+	}
+
+	tests := []struct {
+		name     string
+		msg      *tele.Message
+		expected AntiChannelAction
+	}{
+		{"普通用户发言", msg(group, tele.ChatSuperGroup, nil), AntiChannelIgnore},
+		{"频道身份发言", msg(group, tele.ChatSuperGroup, &tele.Chat{ID: channel}), AntiChannelBan},
+		{"仅警告群组", msg(warnGroup, tele.ChatSuperGroup, &tele.Chat{ID: channel}), AntiChannelWarn},
+		{"白名单频道", msg(group, tele.ChatSuperGroup, &tele.Chat{ID: white}), AntiChannelIgnore},
+		{"匿名管理员", msg(group, tele.ChatSuperGroup, &tele.Chat{ID: group}), AntiChannelIgnore},
+		{"未配置的群组", msg(-1003, tele.ChatSuperGroup, &tele.Chat{ID: channel}), AntiChannelIgnore},
+		{"私聊", msg(group, tele.ChatPrivate, &tele.Chat{ID: channel}), AntiChannelIgnore},
+		{"关联频道自动转发", func() *tele.Message {
+			m := msg(group, tele.ChatSuperGroup, &tele.Chat{ID: channel})
+			m.AutomaticForward = true
+			return m
+		}(), AntiChannelIgnore},
+		{"群组关联频道", func() *tele.Message {
+			m := msg(group, tele.ChatSuperGroup, &tele.Chat{ID: channel})
+			m.Chat.LinkedChatID = channel
+			return m
+		}(), AntiChannelIgnore},
+		{"其他群组的关联频道", func() *tele.Message {
+			m := msg(group, tele.ChatSuperGroup, &tele.Chat{ID: channel})
+			m.Chat.LinkedChatID = white
+			return m
+		}(), AntiChannelBan},
+		{"空消息", nil, AntiChannelIgnore},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := AntiChannelActionFor(cfg, tt.msg); got != tt.expected {
+				t.Errorf("AntiChannelActionFor() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+
+	cfg.AntiChannel.Enabled = false
+	if got := AntiChannelActionFor(cfg, msg(group, tele.ChatSuperGroup, &tele.Chat{ID: channel})); got != AntiChannelIgnore {
+		t.Errorf("关闭后应不处理, got %d", got)
+	}
+}
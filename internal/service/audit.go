@@ -0,0 +1,190 @@
+// Package service 播放审计服务
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
+)
+
+// auditLimit 单次审计最多返回的分组数
+const auditLimit = 200
+
+// AuditService 播放审计服务
+// 启用本地播放记录时查询 playback_sessions 表，否则通过 user_usage_stats 插件查询 Emby
+type AuditService struct {
+	sessionRepo *repository.PlaybackSessionRepository
+	embyClient  *emby.Client
+	cfg         *config.Config
+}
+
+// NewAuditService 创建播放审计服务
+func NewAuditService() *AuditService {
+	return &AuditService{
+		sessionRepo: repository.NewPlaybackSessionRepository(),
+		embyClient:  emby.GetClient(),
+		cfg:         config.Get(),
+	}
+}
+
+// local 是否使用本地播放记录
+func (s *AuditService) local() bool {
+	return s.cfg.PlaybackHistory.Enabled
+}
+
+// Query 按条件查询播放活动，按用户、设备、客户端、IP 分组
+func (s *AuditService) Query(filter emby.AuditFilter) ([]emby.AuditResult, error) {
+	if filter.Limit <= 0 {
+		filter.Limit = auditLimit
+	}
+	if !s.local() {
+		return s.embyClient.AuditActivity(filter)
+	}
+
+	groups, err := s.sessionRepo.Audit(repository.PlaybackSessionFilter{
+		EmbyUserID: filter.UserID,
+		ClientIP:   filter.IP,
+		Device:     filter.Device,
+		Client:     filter.Client,
+		ItemID:     filter.ItemID,
+		ItemName:   filter.ItemName,
+		Since:      filter.Since,
+		Until:      filter.Until,
+	}, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("查询本地播放记录失败: %w", err)
+	}
+
+	results := make([]emby.AuditResult, 0, len(groups))
+	for _, g := range groups {
+		results = append(results, emby.AuditResult{
+			UserID:        g.EmbyUserID,
+			Username:      g.UserName,
+			DeviceName:    g.DeviceName,
+			ClientName:    g.Client,
+			RemoteAddress: g.ClientIP,
+			LastActivity:  g.LastActivity,
+			ActivityCount: g.Count,
+		})
+	}
+	return results, nil
+}
+
+// UserActivity 查询指定用户的设备与 IP 记录
+func (s *AuditService) UserActivity(username string, days int) ([]emby.AuditResult, error) {
+	user, err := s.embyClient.GetUserByName(username)
+	if err != nil {
+		return nil, fmt.Errorf("用户不存在: %w", err)
+	}
+
+	filter := emby.AuditFilter{UserID: user.ID}
+	if days > 0 {
+		filter.Since = time.Now().AddDate(0, 0, -days)
+	}
+	return s.Query(filter)
+}
+
+// SharedUsage 查询最近 days 天被多个用户共用的 IP 或设备（by 为 emby.SharedByIP / emby.SharedByDevice）
+// 本地记录按设备 ID 聚类，Emby 插件只有设备名可用
+func (s *AuditService) SharedUsage(by string, days int) ([]emby.SharedCluster, error) {
+	since := time.Now().AddDate(0, 0, -days)
+	if !s.local() {
+		return s.embyClient.GetSharedUsage(by, since)
+	}
+
+	column := repository.ShareByIP
+	if by == emby.SharedByDevice {
+		column = repository.ShareByDevice
+	}
+	shares, err := s.sessionRepo.Shares(column, since)
+	if err != nil {
+		return nil, fmt.Errorf("查询本地播放记录失败: %w", err)
+	}
+
+	usage := make([]emby.SharedUsage, 0, len(shares))
+	for _, sh := range shares {
+		usage = append(usage, emby.SharedUsage{
+			Key:          sh.Key,
+			Label:        sh.Label,
+			UserID:       sh.EmbyUserID,
+			UserName:     sh.UserName,
+			Count:        sh.Count,
+			LastActivity: sh.LastActivity,
+		})
+	}
+	return emby.ClusterSharedUsage(usage, 2), nil
+}
+
+// AuditRange 审计时间范围
+type AuditRange struct {
+	Since time.Time // 含
+	Until time.Time // 不含，零值表示至今
+	Label string    // 显示文本
+}
+
+// ParseAuditRange 解析时间范围参数：天数（如 30）或日期区间（如 2024-01-01~2024-01-31，含结束日）
+func ParseAuditRange(arg string, now time.Time) (AuditRange, bool) {
+	if days, err := strconv.Atoi(arg); err == nil {
+		if days <= 0 {
+			return AuditRange{}, false
+		}
+		return AuditRange{
+			Since: now.AddDate(0, 0, -days),
+			Label: fmt.Sprintf("最近 %d 天", days),
+		}, true
+	}
+
+	parts := strings.SplitN(arg, "~", 2)
+	if len(parts) != 2 {
+		return AuditRange{}, false
+	}
+	start, err := time.ParseInLocation("2006-01-02", parts[0], now.Location())
+	if err != nil {
+		return AuditRange{}, false
+	}
+	end, err := time.ParseInLocation("2006-01-02", parts[1], now.Location())
+	if err != nil || end.Before(start) {
+		return AuditRange{}, false
+	}
+	return AuditRange{
+		Since: start,
+		Until: end.AddDate(0, 0, 1),
+		Label: fmt.Sprintf("%s ~ %s", parts[0], parts[1]),
+	}, true
+}
+
+// FormatSharedClusters 格式化共用检测报告
+func FormatSharedClusters(clusters []emby.SharedCluster, by string, days int) string {
+	dimension := "IP"
+	if by == emby.SharedByDevice {
+		dimension = "设备"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🕵️ **共用%s检测**\n\n", dimension))
+	sb.WriteString(fmt.Sprintf("**时间范围**: 最近 %d 天\n", days))
+	sb.WriteString(fmt.Sprintf("**共用%s**: %d 个\n", dimension, len(clusters)))
+
+	for i, cluster := range clusters {
+		if i >= 15 {
+			sb.WriteString(fmt.Sprintf("\n... 还有 %d 个%s", len(clusters)-15, dimension))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("\n**%d. %s** · %d 人 · %d 次 · 最后 %s\n",
+			i+1, utils.EscapeMarkdown(cluster.Label), len(cluster.Users), cluster.Total, cluster.LastActivity.Format("01-02 15:04")))
+		for _, u := range cluster.Users {
+			name := u.UserName
+			if name == "" {
+				name = u.UserID
+			}
+			sb.WriteString(fmt.Sprintf("   · %s %d 次\n", utils.MarkdownCode(name), u.Count))
+		}
+	}
+	return sb.String()
+}
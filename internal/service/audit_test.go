@@ -0,0 +1,68 @@
+// Package service 播放审计测试
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+)
+
+func TestParseAuditRange(t *testing.T) {
+	now := time.Date(2024, 3, 15, 10, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		name      string
+		arg       string
+		ok        bool
+		wantSince time.Time
+		wantUntil time.Time
+		wantLabel string
+	}{
+		{"天数", "30", true, now.AddDate(0, 0, -30), time.Time{}, "最近 30 天"},
+		{"日期区间含结束日", "2024-03-01~2024-03-07", true,
+			time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 3, 8, 0, 0, 0, 0, time.Local), "2024-03-01 ~ 2024-03-07"},
+		{"同一天", "2024-03-01~2024-03-01", true,
+			time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), time.Date(2024, 3, 2, 0, 0, 0, 0, time.Local), "2024-03-01 ~ 2024-03-01"},
+		{"零天", "0", false, time.Time{}, time.Time{}, ""},
+		{"负数", "-3", false, time.Time{}, time.Time{}, ""},
+		{"结束早于开始", "2024-03-07~2024-03-01", false, time.Time{}, time.Time{}, ""},
+		{"日期格式错误", "2024/03/01~2024/03/07", false, time.Time{}, time.Time{}, ""},
+		{"关键词", "iPhone", false, time.Time{}, time.Time{}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseAuditRange(tt.arg, now)
+			if ok != tt.ok {
+				t.Fatalf("ParseAuditRange(%q) ok = %v, want %v", tt.arg, ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if !got.Since.Equal(tt.wantSince) || !got.Until.Equal(tt.wantUntil) || got.Label != tt.wantLabel {
+				t.Errorf("ParseAuditRange(%q) = %+v", tt.arg, got)
+			}
+		})
+	}
+}
+
+func TestFormatSharedClusters(t *testing.T) {
+	clusters := []emby.SharedCluster{{
+		Key:   "dev-1",
+		Label: "客厅_电视*",
+		Users: []emby.SharedUsage{
+			{UserID: "u1", UserName: "al`ice", Count: 5},
+			{UserID: "u2", Count: 2},
+		},
+		Total: 7,
+	}}
+
+	text := FormatSharedClusters(clusters, emby.SharedByDevice, 7)
+	for _, want := range []string{"共用设备检测", "最近 7 天", `客厅\_电视\*`, "2 人", "`al'ice` 5 次", "`u2` 2 次"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("报告缺少 %q:\n%s", want, text)
+		}
+	}
+}
@@ -3,14 +3,23 @@ package service
 
 import (
 	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
+	"strings"
+	"sync"
 	"time"
 
+	tele "gopkg.in/telebot.v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+
 	"github.com/smysle/sakura-embyboss-go/internal/config"
 	"github.com/smysle/sakura-embyboss-go/internal/database"
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
@@ -21,27 +30,56 @@ import (
 type BackupService struct {
 	cfg       *config.Config
 	backupDir string
+	bot       *tele.Bot // Telegram 异地备份目标使用
 }
 
+// BackupFormatVersion 备份文件格式版本
+//
+//	1: 旧格式，仅包含 emby / codes / red_envelopes 三个字段（version: "1.0"）
+//	2: 按表名保存所有数据表，每行以列名为键
+const BackupFormatVersion = 2
+
+var (
+	ErrBackupFormatUnsupported = errors.New("备份文件格式版本过新，请升级后再恢复")
+	ErrBackupSchemaNewer       = errors.New("备份来自更新的数据库结构版本，请升级后再恢复")
+	ErrBackupNotFound          = errors.New("备份文件不存在")
+)
+
 // BackupData 备份数据结构
 type BackupData struct {
-	Version   string           `json:"version"`
-	CreatedAt time.Time        `json:"created_at"`
-	Emby      []models.Emby    `json:"emby"`
-	Codes     []models.Code   `json:"codes"`
-	Envelopes []models.RedEnvelope `json:"red_envelopes"`
+	FormatVersion int                        `json:"format_version"`
+	SchemaVersion int                        `json:"schema_version"` // 备份时的 database.SchemaVersion
+	CreatedAt     time.Time                  `json:"created_at"`
+	Tables        map[string]json.RawMessage `json:"tables"` // 表名 → 记录数组（列名 → 值）
+
+	// 旧格式（1.0）字段，仅用于读取旧备份
+	Version   string               `json:"version,omitempty"`
+	Emby      []models.Emby        `json:"emby,omitempty"`
+	Codes     []models.Code        `json:"codes,omitempty"`
+	Envelopes []models.RedEnvelope `json:"red_envelopes,omitempty"`
 }
 
 // BackupResult 备份结果
 type BackupResult struct {
-	Filename  string
-	FilePath  string
-	Size      int64
-	Duration  time.Duration
-	Records   int
+	Filename   string
+	FilePath   string
+	Size       int64
+	Duration   time.Duration
+	Records    int
+	Tables     map[string]int // 表名 → 记录数
 	Compressed bool
+	Uploads    []TargetUpload // 异地备份上传结果
+}
+
+// backupTable 参与备份的数据表
+type backupTable struct {
+	Name   string
+	Schema *schema.Schema
 }
 
+// backupRow 以列名为键的一行记录
+type backupRow map[string]interface{}
+
 // NewBackupService 创建备份服务
 func NewBackupService() *BackupService {
 	cfg := config.Get()
@@ -59,29 +97,107 @@ func NewBackupService() *BackupService {
 	}
 }
 
-// Backup 执行备份
+// SetBot 设置 Bot 实例（Telegram 异地备份目标需要）
+func (s *BackupService) SetBot(bot *tele.Bot) {
+	s.bot = bot
+}
+
+// parseBackupTables 解析所有数据表模型的表结构
+func parseBackupTables(namer schema.Namer) ([]backupTable, error) {
+	cache := &sync.Map{}
+	var tables []backupTable
+	for _, model := range database.AllModels() {
+		sch, err := schema.Parse(model, cache, namer)
+		if err != nil {
+			return nil, fmt.Errorf("解析表结构失败: %w", err)
+		}
+		tables = append(tables, backupTable{Name: sch.Table, Schema: sch})
+	}
+	return tables, nil
+}
+
+// encodeRows 将模型切片按列名编码（不受 json 标签影响，如 emby_accounts.pwd）
+func encodeRows(sch *schema.Schema, slice reflect.Value) []backupRow {
+	ctx := context.Background()
+	rows := make([]backupRow, 0, slice.Len())
+	for i := 0; i < slice.Len(); i++ {
+		rv := reflect.Indirect(slice.Index(i))
+		row := make(backupRow, len(sch.DBNames))
+		for _, name := range sch.DBNames {
+			value, _ := sch.FieldsByDBName[name].ValueOf(ctx, rv)
+			row[name] = value
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// decodeRows 按当前表结构解码备份中的记录，返回当前结构中不存在的列
+func decodeRows(sch *schema.Schema, raw json.RawMessage) ([]backupRow, []string, error) {
+	var items []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, nil, err
+	}
+
+	unknown := make(map[string]bool)
+	rows := make([]backupRow, 0, len(items))
+	for _, item := range items {
+		row := make(backupRow, len(item))
+		for column, value := range item {
+			field := sch.FieldsByDBName[column]
+			if field == nil {
+				unknown[column] = true
+				continue
+			}
+			ptr := reflect.New(field.FieldType)
+			if err := json.Unmarshal(value, ptr.Interface()); err != nil {
+				return nil, nil, fmt.Errorf("列 %s: %w", column, err)
+			}
+			row[column] = ptr.Elem().Interface()
+		}
+		rows = append(rows, row)
+	}
+	return rows, sortedKeys(unknown), nil
+}
+
+// Backup 执行备份，覆盖所有已迁移的数据表
 func (s *BackupService) Backup(compress bool) (*BackupResult, error) {
 	startTime := time.Now()
 	db := database.GetDB()
 
-	// 收集数据
-	var data BackupData
-	data.Version = "1.0"
-	data.CreatedAt = time.Now()
-
-	// 备份 Emby 用户
-	if err := db.Find(&data.Emby).Error; err != nil {
-		return nil, fmt.Errorf("备份 Emby 用户失败: %w", err)
+	tables, err := parseBackupTables(db.NamingStrategy)
+	if err != nil {
+		return nil, err
 	}
 
-	// 备份注册码
-	if err := db.Find(&data.Codes).Error; err != nil {
-		return nil, fmt.Errorf("备份注册码失败: %w", err)
+	data := BackupData{
+		FormatVersion: BackupFormatVersion,
+		SchemaVersion: database.SchemaVersion,
+		CreatedAt:     time.Now(),
+		Tables:        make(map[string]json.RawMessage, len(tables)),
 	}
+	counts := make(map[string]int, len(tables))
+	totalRecords := 0
+
+	for _, t := range tables {
+		// 可选表可能未创建
+		if !db.Migrator().HasTable(t.Name) {
+			continue
+		}
 
-	// 备份红包
-	if err := db.Find(&data.Envelopes).Error; err != nil {
-		return nil, fmt.Errorf("备份红包失败: %w", err)
+		slice := reflect.New(reflect.SliceOf(t.Schema.ModelType))
+		if err := db.Table(t.Name).Find(slice.Interface()).Error; err != nil {
+			return nil, fmt.Errorf("备份表 %s 失败: %w", t.Name, err)
+		}
+
+		rows := encodeRows(t.Schema, slice.Elem())
+		raw, err := json.Marshal(rows)
+		if err != nil {
+			return nil, fmt.Errorf("序列化表 %s 失败: %w", t.Name, err)
+		}
+		data.Tables[t.Name] = raw
+		counts[t.Name] = len(rows)
+		totalRecords += len(rows)
 	}
 
 	// 生成文件名
@@ -95,7 +211,7 @@ func (s *BackupService) Backup(compress bool) (*BackupResult, error) {
 	filePath := filepath.Join(s.backupDir, filename)
 
 	// 序列化
-	jsonData, err := json.MarshalIndent(data, "", "  ")
+	jsonData, err := json.Marshal(data)
 	if err != nil {
 		return nil, fmt.Errorf("序列化失败: %w", err)
 	}
@@ -111,11 +227,10 @@ func (s *BackupService) Backup(compress bool) (*BackupResult, error) {
 		return nil, err
 	}
 
-	totalRecords := len(data.Emby) + len(data.Codes) + len(data.Envelopes)
-
 	logger.Info().
 		Str("file", filename).
 		Int64("size", fileSize).
+		Int("tables", len(counts)).
 		Int("records", totalRecords).
 		Msg("数据库备份完成")
 
@@ -125,6 +240,7 @@ func (s *BackupService) Backup(compress bool) (*BackupResult, error) {
 		Size:       fileSize,
 		Duration:   time.Since(startTime),
 		Records:    totalRecords,
+		Tables:     counts,
 		Compressed: compress,
 	}, nil
 }
@@ -160,51 +276,300 @@ func (s *BackupService) writeCompressed(path string, data []byte) (int64, error)
 	return info.Size(), nil
 }
 
-// Restore 从备份恢复
-func (s *BackupService) Restore(filePath string) error {
-	// 读取文件
-	var data []byte
-	var err error
+// TableDiff 单表的恢复差异
+type TableDiff struct {
+	Table          string
+	Current        int      // 当前记录数
+	Backup         int      // 备份记录数
+	Added          int      // 仅在备份中存在的记录
+	Removed        int      // 仅在当前数据库中存在的记录
+	Changed        int      // 主键相同但内容不同的记录
+	Skipped        bool     // 备份中没有该表，恢复时保持不变
+	UnknownColumns []string // 备份中有但当前结构没有的列（恢复时忽略）
+}
 
-	if filepath.Ext(filePath) == ".gz" {
-		data, err = s.readCompressed(filePath)
-	} else {
-		data, err = os.ReadFile(filePath)
+// RestorePlan 恢复计划（dry-run 差异预览）
+type RestorePlan struct {
+	Filename      string
+	FormatVersion int
+	SchemaVersion int
+	CreatedAt     time.Time
+	Tables        []TableDiff
+	UnknownTables []string // 当前版本不存在的表（恢复时忽略）
+
+	rows map[string][]backupRow
+}
+
+// IsJSONBackup 是否为可恢复的 JSON 备份文件
+func IsJSONBackup(filename string) bool {
+	return strings.HasSuffix(filename, ".json") || strings.HasSuffix(filename, ".json.gz")
+}
+
+// PlanRestore 读取备份并与当前数据库比较，不做任何修改
+func (s *BackupService) PlanRestore(filename string) (*RestorePlan, error) {
+	db := database.GetDB()
+
+	data, err := s.readBackup(filename)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBackupVersion(data); err != nil {
+		return nil, err
 	}
+
+	tables, err := parseBackupTables(db.NamingStrategy)
 	if err != nil {
-		return fmt.Errorf("读取备份文件失败: %w", err)
+		return nil, err
 	}
+	plan, err := buildRestorePlan(data, tables)
+	if err != nil {
+		return nil, err
+	}
+	plan.Filename = filename
 
-	// 解析
-	var backupData BackupData
-	if err := json.Unmarshal(data, &backupData); err != nil {
-		return fmt.Errorf("解析备份数据失败: %w", err)
+	for i := range plan.Tables {
+		diff := &plan.Tables[i]
+		if diff.Skipped || !db.Migrator().HasTable(diff.Table) {
+			continue
+		}
+		t := tables[i]
+		slice := reflect.New(reflect.SliceOf(t.Schema.ModelType))
+		if err := db.Table(t.Name).Find(slice.Interface()).Error; err != nil {
+			return nil, fmt.Errorf("读取表 %s 失败: %w", t.Name, err)
+		}
+		current := encodeRows(t.Schema, slice.Elem())
+		diff.Current = len(current)
+		diff.Added, diff.Removed, diff.Changed = diffRows(t.Schema.PrimaryFieldDBNames, current, plan.rows[t.Name])
 	}
 
-	db := database.GetDB()
+	return plan, nil
+}
 
-	// 恢复 Emby 用户
-	for _, emby := range backupData.Emby {
-		if err := db.Save(&emby).Error; err != nil {
-			logger.Warn().Err(err).Int64("tg", emby.TG).Msg("恢复用户失败")
-		}
+// Restore 在单个事务中用备份替换各表数据：备份中包含的表先清空再写入，未包含的表保持不变
+func (s *BackupService) Restore(filename string) (*RestorePlan, error) {
+	plan, err := s.PlanRestore(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	db := database.GetDB()
+	tables, err := parseBackupTables(db.NamingStrategy)
+	if err != nil {
+		return nil, err
 	}
 
-	// 恢复注册码
-	for _, code := range backupData.Codes {
-		if err := db.Save(&code).Error; err != nil {
-			logger.Warn().Err(err).Str("code", code.Code).Msg("恢复注册码失败")
+	err = db.Transaction(func(tx *gorm.DB) error {
+		for i, t := range tables {
+			if plan.Tables[i].Skipped {
+				continue
+			}
+			if !tx.Migrator().HasTable(t.Name) {
+				if err := tx.Table(t.Name).AutoMigrate(reflect.New(t.Schema.ModelType).Interface()); err != nil {
+					return fmt.Errorf("创建表 %s 失败: %w", t.Name, err)
+				}
+			}
+
+			model := reflect.New(t.Schema.ModelType).Interface()
+			if err := tx.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(model).Error; err != nil {
+				return fmt.Errorf("清空表 %s 失败: %w", t.Name, err)
+			}
+
+			rows := plan.rows[t.Name]
+			if len(rows) == 0 {
+				continue
+			}
+			values := make([]map[string]interface{}, len(rows))
+			for j, row := range rows {
+				values[j] = row
+			}
+			if err := tx.Model(model).CreateInBatches(values, 200).Error; err != nil {
+				return fmt.Errorf("写入表 %s 失败: %w", t.Name, err)
+			}
 		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	logger.Info().
-		Int("emby", len(backupData.Emby)).
-		Int("codes", len(backupData.Codes)).
+		Str("file", filename).
+		Int("format", plan.FormatVersion).
+		Int("schema", plan.SchemaVersion).
+		Int("tables", len(plan.rows)).
 		Msg("数据库恢复完成")
 
+	return plan, nil
+}
+
+// readBackup 读取并解析备份目录中的备份文件
+func (s *BackupService) readBackup(filename string) (*BackupData, error) {
+	if filename != filepath.Base(filename) || !IsJSONBackup(filename) {
+		return nil, ErrBackupNotFound
+	}
+	filePath := s.GetBackupFilePath(filename)
+	if _, err := os.Stat(filePath); err != nil {
+		return nil, ErrBackupNotFound
+	}
+
+	var raw []byte
+	var err error
+	if filepath.Ext(filePath) == ".gz" {
+		raw, err = s.readCompressed(filePath)
+	} else {
+		raw, err = os.ReadFile(filePath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取备份文件失败: %w", err)
+	}
+
+	var data BackupData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("解析备份数据失败: %w", err)
+	}
+	return &data, nil
+}
+
+// checkBackupVersion 校验备份格式与表结构版本
+func checkBackupVersion(data *BackupData) error {
+	if data.FormatVersion == 0 {
+		if data.Version == "" {
+			return fmt.Errorf("无法识别的备份文件")
+		}
+		return nil // 旧格式
+	}
+	if data.FormatVersion > BackupFormatVersion {
+		return ErrBackupFormatUnsupported
+	}
+	if data.SchemaVersion > database.SchemaVersion {
+		return ErrBackupSchemaNewer
+	}
 	return nil
 }
 
+// buildRestorePlan 按当前表结构解码备份数据（旧格式转换为按表保存）
+func buildRestorePlan(data *BackupData, tables []backupTable) (*RestorePlan, error) {
+	plan := &RestorePlan{
+		FormatVersion: data.FormatVersion,
+		SchemaVersion: data.SchemaVersion,
+		CreatedAt:     data.CreatedAt,
+		rows:          make(map[string][]backupRow),
+	}
+
+	known := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		known[t.Name] = true
+		diff := TableDiff{Table: t.Name}
+
+		switch {
+		case data.FormatVersion == 0:
+			legacy := legacyTableRows(data, t.Name)
+			if !legacy.IsValid() {
+				diff.Skipped = true
+				break
+			}
+			plan.rows[t.Name] = encodeRows(t.Schema, legacy)
+		default:
+			raw, ok := data.Tables[t.Name]
+			if !ok {
+				diff.Skipped = true
+				break
+			}
+			rows, unknown, err := decodeRows(t.Schema, raw)
+			if err != nil {
+				return nil, fmt.Errorf("解析表 %s 失败: %w", t.Name, err)
+			}
+			plan.rows[t.Name] = rows
+			diff.UnknownColumns = unknown
+		}
+
+		diff.Backup = len(plan.rows[t.Name])
+		plan.Tables = append(plan.Tables, diff)
+	}
+
+	for name := range data.Tables {
+		if !known[name] {
+			plan.UnknownTables = append(plan.UnknownTables, name)
+		}
+	}
+	sort.Strings(plan.UnknownTables)
+
+	return plan, nil
+}
+
+// legacyTableRows 旧格式备份中对应表的数据
+func legacyTableRows(data *BackupData, table string) reflect.Value {
+	switch table {
+	case models.Emby{}.TableName():
+		return reflect.ValueOf(data.Emby)
+	case models.Code{}.TableName():
+		return reflect.ValueOf(data.Codes)
+	case models.RedEnvelope{}.TableName():
+		return reflect.ValueOf(data.Envelopes)
+	}
+	return reflect.Value{}
+}
+
+// diffRows 按主键比较当前记录与备份记录；没有主键时按整行内容比较
+func diffRows(primaryKeys []string, current, backup []backupRow) (added, removed, changed int) {
+	index := func(rows []backupRow) map[string][]string {
+		m := make(map[string][]string, len(rows))
+		for _, row := range rows {
+			content := rowContent(row)
+			key := content
+			if len(primaryKeys) > 0 {
+				parts := make([]string, len(primaryKeys))
+				for i, pk := range primaryKeys {
+					parts[i] = fmt.Sprint(row[pk])
+				}
+				key = strings.Join(parts, "|")
+			}
+			m[key] = append(m[key], content)
+		}
+		return m
+	}
+
+	cur, bak := index(current), index(backup)
+	for key, contents := range bak {
+		existing, ok := cur[key]
+		switch {
+		case !ok:
+			added += len(contents)
+		case len(existing) < len(contents):
+			added += len(contents) - len(existing)
+		}
+		if ok && len(primaryKeys) > 0 && existing[0] != contents[0] {
+			changed++
+		}
+	}
+	for key, contents := range cur {
+		existing, ok := bak[key]
+		switch {
+		case !ok:
+			removed += len(contents)
+		case len(existing) < len(contents):
+			removed += len(contents) - len(existing)
+		}
+	}
+	return added, removed, changed
+}
+
+// rowContent 行内容的规范化表示（JSON 对象键按字母排序）
+func rowContent(row backupRow) string {
+	b, _ := json.Marshal(map[string]interface{}(row))
+	return string(b)
+}
+
+// sortedKeys 集合的有序键
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for k := range set {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // readCompressed 读取压缩文件
 func (s *BackupService) readCompressed(path string) ([]byte, error) {
 	file, err := os.Open(path)
@@ -231,7 +596,8 @@ func (s *BackupService) ListBackups() ([]BackupInfo, error) {
 
 	var backups []BackupInfo
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// 隐藏文件为异地备份索引等内部文件
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
 			continue
 		}
 		info, err := entry.Info()
@@ -259,6 +625,7 @@ type BackupInfo struct {
 	Filename  string
 	Size      int64
 	CreatedAt time.Time
+	Target    string // 异地备份目标名称，本地备份为空
 }
 
 // CleanOldBackups 清理旧备份
@@ -0,0 +1,244 @@
+// Package service mysqldump 备份引擎
+package service
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// 备份方式
+const (
+	BackupEngineJSON      = "json"      // 通过 GORM 导出所有表为 JSON
+	BackupEngineMysqldump = "mysqldump" // 调用 mysqldump（或 docker exec）导出 SQL
+)
+
+// dumpTimeout mysqldump / mysql 命令的最长执行时间
+const dumpTimeout = 30 * time.Minute
+
+// ErrUnknownBackupEngine 未知的备份方式
+var ErrUnknownBackupEngine = errors.New("未知的备份方式，可选 json 或 mysqldump")
+
+// IsDumpBackup 是否为 mysqldump 导出的 SQL 备份文件
+func IsDumpBackup(filename string) bool {
+	return strings.HasSuffix(filename, ".sql") || strings.HasSuffix(filename, ".sql.gz")
+}
+
+// BackupEngineOf 备份文件对应的备份方式（无法识别时为空）
+func BackupEngineOf(filename string) string {
+	switch {
+	case IsJSONBackup(filename):
+		return BackupEngineJSON
+	case IsDumpBackup(filename):
+		return BackupEngineMysqldump
+	}
+	return ""
+}
+
+// Run 按指定方式执行备份（为空时使用 database.backup_engine），按 backup_max_count 清理同类旧备份，
+// 并上传到所有异地备份目标
+func (s *BackupService) Run(engine string) (*BackupResult, error) {
+	if engine == "" {
+		engine = s.cfg.Database.BackupEngine
+	}
+
+	var result *BackupResult
+	var err error
+	switch engine {
+	case BackupEngineJSON:
+		result, err = s.Backup(true)
+	case BackupEngineMysqldump:
+		result, err = s.Dump()
+	default:
+		return nil, ErrUnknownBackupEngine
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deleted, err := s.PruneBackups(engine, s.cfg.Database.BackupMaxCount); err != nil {
+		logger.Warn().Err(err).Msg("清理旧备份失败")
+	} else if deleted > 0 {
+		logger.Info().Int("deleted", deleted).Str("engine", engine).Msg("已清理旧备份")
+	}
+
+	result.Uploads = s.UploadToTargets(result.Filename)
+	return result, nil
+}
+
+// mysqlCommand 构造 mysqldump / mysql 命令：is_docker 时通过 docker exec 在容器内执行
+// 密码通过 MYSQL_PWD 环境变量传递，不出现在命令行参数中
+func mysqlCommand(cfg config.DatabaseConfig, tool string, args ...string) (name string, cmdArgs []string) {
+	toolArgs := []string{"-u", cfg.User}
+	if !cfg.IsDocker {
+		toolArgs = append(toolArgs, "-h", cfg.Host, "-P", strconv.Itoa(cfg.Port))
+	}
+	toolArgs = append(toolArgs, args...)
+
+	if cfg.IsDocker {
+		// -e MYSQL_PWD 不带值时从 docker 客户端的环境变量传入容器
+		return "docker", append([]string{"exec", "-i", "-e", "MYSQL_PWD", cfg.DockerName, tool}, toolArgs...)
+	}
+	return tool, toolArgs
+}
+
+// newMysqlCmd 创建带超时与密码环境变量的命令
+func newMysqlCmd(ctx context.Context, cfg config.DatabaseConfig, tool string, args ...string) *exec.Cmd {
+	name, cmdArgs := mysqlCommand(cfg, tool, args...)
+	cmd := exec.CommandContext(ctx, name, cmdArgs...)
+	cmd.Env = append(os.Environ(), "MYSQL_PWD="+cfg.Password)
+	return cmd
+}
+
+// Dump 调用 mysqldump 导出整个数据库，输出经 gzip 压缩后写入备份目录
+func (s *BackupService) Dump() (*BackupResult, error) {
+	startTime := time.Now()
+	dbCfg := s.cfg.Database
+	if dbCfg.IsDocker && dbCfg.DockerName == "" {
+		return nil, fmt.Errorf("已启用 is_docker 但未配置 docker_name")
+	}
+
+	filename := fmt.Sprintf("backup_%s.sql.gz", time.Now().Format("20060102_150405"))
+	filePath := filepath.Join(s.backupDir, filename)
+
+	file, err := os.Create(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("创建文件失败: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dumpTimeout)
+	defer cancel()
+
+	cmd := newMysqlCmd(ctx, dbCfg, "mysqldump",
+		"--single-transaction", "--routines", "--triggers", "--default-character-set=utf8mb4",
+		dbCfg.Name)
+	gz := gzip.NewWriter(file)
+	var stderr bytes.Buffer
+	cmd.Stdout = gz
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	closeErr := gz.Close()
+	if err := file.Close(); closeErr == nil {
+		closeErr = err
+	}
+	if runErr != nil || closeErr != nil {
+		os.Remove(filePath)
+		if runErr != nil {
+			return nil, fmt.Errorf("mysqldump 执行失败: %w %s", runErr, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("写入备份文件失败: %w", closeErr)
+	}
+
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Info().
+		Str("file", filename).
+		Int64("size", info.Size()).
+		Bool("docker", dbCfg.IsDocker).
+		Msg("mysqldump 备份完成")
+
+	return &BackupResult{
+		Filename:   filename,
+		FilePath:   filePath,
+		Size:       info.Size(),
+		Duration:   time.Since(startTime),
+		Compressed: true,
+	}, nil
+}
+
+// RestoreDump 将 mysqldump 备份流式导入 mysql
+func (s *BackupService) RestoreDump(filename string) error {
+	if filename != filepath.Base(filename) || !IsDumpBackup(filename) {
+		return ErrBackupNotFound
+	}
+	dbCfg := s.cfg.Database
+	if dbCfg.IsDocker && dbCfg.DockerName == "" {
+		return fmt.Errorf("已启用 is_docker 但未配置 docker_name")
+	}
+
+	file, err := os.Open(s.GetBackupFilePath(filename))
+	if err != nil {
+		return ErrBackupNotFound
+	}
+	defer file.Close()
+
+	var input io.Reader = file
+	if strings.HasSuffix(filename, ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("解压备份文件失败: %w", err)
+		}
+		defer gz.Close()
+		input = gz
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), dumpTimeout)
+	defer cancel()
+
+	cmd := newMysqlCmd(ctx, dbCfg, "mysql", "--default-character-set=utf8mb4", dbCfg.Name)
+	var stderr bytes.Buffer
+	cmd.Stdin = input
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("mysql 导入失败: %w %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	logger.Info().Str("file", filename).Bool("docker", dbCfg.IsDocker).Msg("mysqldump 备份导入完成")
+	return nil
+}
+
+// PruneBackups 按备份方式只保留最新的 maxCount 个备份文件，返回删除的数量
+func (s *BackupService) PruneBackups(engine string, maxCount int) (int, error) {
+	if maxCount <= 0 {
+		return 0, nil
+	}
+
+	backups, err := s.ListBackups()
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := 0
+	for _, name := range backupsToPrune(backups, engine, maxCount) {
+		if err := os.Remove(s.GetBackupFilePath(name)); err != nil {
+			logger.Warn().Err(err).Str("file", name).Msg("删除旧备份失败")
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// backupsToPrune 超出保留数量的同类备份（backups 按时间倒序）
+func backupsToPrune(backups []BackupInfo, engine string, maxCount int) []string {
+	var names []string
+	kept := 0
+	for _, b := range backups {
+		if BackupEngineOf(b.Filename) != engine {
+			continue
+		}
+		if kept < maxCount {
+			kept++
+			continue
+		}
+		names = append(names, b.Filename)
+	}
+	return names
+}
@@ -0,0 +1,70 @@
+// Package service mysqldump 备份测试
+package service
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+func TestMysqlCommand(t *testing.T) {
+	cfg := config.DatabaseConfig{Host: "db", Port: 3307, User: "root", Password: "pw", Name: "embyboss", DockerName: "mysql"}
+
+	name, args := mysqlCommand(cfg, "mysqldump", "--single-transaction", "embyboss")
+	if name != "mysqldump" || !reflect.DeepEqual(args, []string{"-u", "root", "-h", "db", "-P", "3307", "--single-transaction", "embyboss"}) {
+		t.Errorf("mysqlCommand() = %s %v", name, args)
+	}
+
+	cfg.IsDocker = true
+	name, args = mysqlCommand(cfg, "mysql", "embyboss")
+	if name != "docker" || !reflect.DeepEqual(args, []string{"exec", "-i", "-e", "MYSQL_PWD", "mysql", "mysql", "-u", "root", "embyboss"}) {
+		t.Errorf("mysqlCommand(docker) = %s %v", name, args)
+	}
+
+	for _, arg := range args {
+		if strings.Contains(arg, "pw") {
+			t.Errorf("密码不应出现在命令参数中: %v", args)
+		}
+	}
+}
+
+func TestBackupEngineOf(t *testing.T) {
+	tests := map[string]string{
+		"backup_20260101_030000.json.gz": BackupEngineJSON,
+		"backup_20260101_030000.json":    BackupEngineJSON,
+		"backup_20260101_030000.sql.gz":  BackupEngineMysqldump,
+		"manual.sql":                     BackupEngineMysqldump,
+		"notes.txt":                      "",
+	}
+	for filename, expected := range tests {
+		if got := BackupEngineOf(filename); got != expected {
+			t.Errorf("BackupEngineOf(%q) = %q, want %q", filename, got, expected)
+		}
+	}
+}
+
+func TestBackupsToPrune(t *testing.T) {
+	now := time.Now()
+	backups := []BackupInfo{
+		{Filename: "d3.sql.gz", CreatedAt: now},
+		{Filename: "j3.json.gz", CreatedAt: now.Add(-time.Hour)},
+		{Filename: "d2.sql.gz", CreatedAt: now.Add(-2 * time.Hour)},
+		{Filename: "j2.json.gz", CreatedAt: now.Add(-3 * time.Hour)},
+		{Filename: "d1.sql.gz", CreatedAt: now.Add(-4 * time.Hour)},
+		{Filename: "readme.txt", CreatedAt: now.Add(-5 * time.Hour)},
+		{Filename: "j1.json", CreatedAt: now.Add(-6 * time.Hour)},
+	}
+
+	if got := backupsToPrune(backups, BackupEngineMysqldump, 2); !reflect.DeepEqual(got, []string{"d1.sql.gz"}) {
+		t.Errorf("mysqldump = %v", got)
+	}
+	if got := backupsToPrune(backups, BackupEngineJSON, 1); !reflect.DeepEqual(got, []string{"j2.json.gz", "j1.json"}) {
+		t.Errorf("json = %v", got)
+	}
+	if got := backupsToPrune(backups, BackupEngineJSON, 5); len(got) != 0 {
+		t.Errorf("未超出保留数量时不应删除, got %v", got)
+	}
+}
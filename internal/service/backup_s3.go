@@ -0,0 +1,294 @@
+// Package service S3 / MinIO 异地备份目标
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// unsignedPayload 不对请求体签名（流式上传时无需预先计算哈希）
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Target S3 兼容存储（AWS S3、MinIO、R2 等），使用 AWS Signature V4 签名
+type S3Target struct {
+	cfg    config.BackupTargetConfig
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3Target 创建 S3 备份目标
+func NewS3Target(cfg config.BackupTargetConfig) (*S3Target, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, fmt.Errorf("S3 目标 %s 缺少 endpoint 或 bucket", cfg.Name)
+	}
+	if cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, fmt.Errorf("S3 目标 %s 缺少 access_key 或 secret_key", cfg.Name)
+	}
+	if _, err := url.Parse(cfg.Endpoint); err != nil {
+		return nil, fmt.Errorf("S3 目标 %s 的 endpoint 无效: %w", cfg.Name, err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	return &S3Target{cfg: cfg, client: &http.Client{}, now: time.Now}, nil
+}
+
+// Name 目标名称
+func (t *S3Target) Name() string {
+	return t.cfg.Name
+}
+
+// bucketURL 存储桶地址（路径风格: endpoint/bucket/，虚拟主机风格: bucket.endpoint/）
+func (t *S3Target) bucketURL() *url.URL {
+	u, _ := url.Parse(strings.TrimRight(t.cfg.Endpoint, "/"))
+	if t.cfg.PathStyle {
+		u.Path = "/" + t.cfg.Bucket + "/"
+	} else {
+		u.Host = t.cfg.Bucket + "." + u.Host
+		u.Path = "/"
+	}
+	return u
+}
+
+// prefix 对象键前缀（非空时以 / 结尾）
+func (t *S3Target) prefix() string {
+	p := strings.Trim(t.cfg.Prefix, "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
+
+// objectURL 备份文件对应的对象地址
+func (t *S3Target) objectURL(filename string) *url.URL {
+	u := t.bucketURL()
+	u.Path += t.prefix() + filename
+	return u
+}
+
+// do 签名并发送请求，非 2xx 响应返回错误
+func (t *S3Target) do(req *http.Request, payloadHash string) (*http.Response, error) {
+	signV4(req, t.cfg.AccessKey, t.cfg.SecretKey, t.cfg.Region, "s3", payloadHash, t.now())
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("S3 返回 %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// Upload 上传备份文件
+func (t *S3Target) Upload(ctx context.Context, filename string, r io.Reader, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.objectURL(filename).String(), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := t.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// s3ListResult ListObjectsV2 响应
+type s3ListResult struct {
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+	Contents              []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+}
+
+// List 列出前缀下的备份文件（不含子目录）
+func (t *S3Target) List(ctx context.Context) ([]BackupInfo, error) {
+	prefix := t.prefix()
+	var backups []BackupInfo
+	token := ""
+	for {
+		u := t.bucketURL()
+		q := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		u.RawQuery = q.Encode()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := t.do(req, unsignedPayload)
+		if err != nil {
+			return nil, err
+		}
+		var result s3ListResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("解析 S3 列表失败: %w", err)
+		}
+
+		for _, obj := range result.Contents {
+			name := strings.TrimPrefix(obj.Key, prefix)
+			if name == "" || strings.Contains(name, "/") {
+				continue
+			}
+			backups = append(backups, BackupInfo{Filename: name, Size: obj.Size, CreatedAt: obj.LastModified})
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return backups, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// Download 下载备份文件
+func (t *S3Target) Download(ctx context.Context, filename string, w io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.objectURL(filename).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Delete 删除备份文件
+func (t *S3Target) Delete(ctx context.Context, filename string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodDelete, t.objectURL(filename).String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, unsignedPayload)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// signV4 按 AWS Signature Version 4 为请求添加 Authorization 头
+// 参与签名的头: host、x-amz-*、content-type、content-md5、range
+func signV4(req *http.Request, accessKey, secretKey, region, service, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	headers := map[string]string{"host": host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		switch {
+		case strings.HasPrefix(lower, "x-amz-"), lower == "content-type", lower == "content-md5", lower == "range":
+			headers[lower] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscape(req.URL.Path, false),
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+// canonicalQuery 按键、值排序并编码的查询字符串
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), q[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, awsEscape(k, true)+"="+awsEscape(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// awsEscape 按 SigV4 规则编码：仅保留 A-Z a-z 0-9 - _ . ~，路径中的 / 不编码
+func awsEscape(s string, escapeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !escapeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,238 @@
+// Package service 异地备份目标
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// 异地备份目标类型
+const (
+	BackupTargetS3       = "s3"
+	BackupTargetWebDAV   = "webdav"
+	BackupTargetTelegram = "telegram"
+)
+
+// targetTimeout 单次上传 / 下载 / 列表操作的最长时间
+const targetTimeout = 10 * time.Minute
+
+// ErrBackupTargetNotFound 异地备份目标不存在
+var ErrBackupTargetNotFound = errors.New("异地备份目标不存在")
+
+// BackupTarget 异地备份目标，备份完成后上传一份副本
+type BackupTarget interface {
+	// Name 目标名称（配置中的 name）
+	Name() string
+	// Upload 上传备份文件
+	Upload(ctx context.Context, filename string, r io.Reader, size int64) error
+	// List 列出远程备份（不要求有序）
+	List(ctx context.Context) ([]BackupInfo, error)
+	// Download 下载远程备份写入 w
+	Download(ctx context.Context, filename string, w io.Writer) error
+	// Delete 删除远程备份
+	Delete(ctx context.Context, filename string) error
+}
+
+// TargetUpload 单个目标的上传结果
+type TargetUpload struct {
+	Target string
+	Pruned int // 按 keep 清理的远程旧备份数量
+	Err    error
+}
+
+// NewBackupTarget 按配置创建异地备份目标（bot 仅 Telegram 目标使用）
+func NewBackupTarget(cfg config.BackupTargetConfig, backupDir string, bot *tele.Bot) (BackupTarget, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("异地备份目标缺少 name")
+	}
+	switch cfg.Type {
+	case BackupTargetS3:
+		return NewS3Target(cfg)
+	case BackupTargetWebDAV:
+		return NewWebDAVTarget(cfg)
+	case BackupTargetTelegram:
+		return NewTelegramTarget(cfg, backupDir, bot), nil
+	}
+	return nil, fmt.Errorf("未知的异地备份类型: %s", cfg.Type)
+}
+
+// Targets 已配置的异地备份目标（配置有误的目标会被跳过）
+func (s *BackupService) Targets() []BackupTarget {
+	var targets []BackupTarget
+	for _, tc := range s.cfg.Database.BackupTargets {
+		t, err := NewBackupTarget(tc, s.backupDir, s.bot)
+		if err != nil {
+			logger.Warn().Err(err).Str("target", tc.Name).Msg("异地备份目标配置无效")
+			continue
+		}
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// targetConfig 按名称查找目标配置
+func (s *BackupService) targetConfig(name string) (config.BackupTargetConfig, bool) {
+	for _, tc := range s.cfg.Database.BackupTargets {
+		if tc.Name == name {
+			return tc, true
+		}
+	}
+	return config.BackupTargetConfig{}, false
+}
+
+// UploadToTargets 将本地备份上传到所有异地备份目标，并按各目标的 keep 清理远程旧备份
+func (s *BackupService) UploadToTargets(filename string) []TargetUpload {
+	var results []TargetUpload
+	for _, t := range s.Targets() {
+		result := TargetUpload{Target: t.Name()}
+		result.Err = s.uploadTo(t, filename)
+		if result.Err == nil {
+			tc, _ := s.targetConfig(t.Name())
+			pruned, err := pruneTarget(t, BackupEngineOf(filename), tc.Keep)
+			if err != nil {
+				logger.Warn().Err(err).Str("target", t.Name()).Msg("清理远程旧备份失败")
+			}
+			result.Pruned = pruned
+		}
+
+		if result.Err != nil {
+			logger.Error().Err(result.Err).Str("target", t.Name()).Str("file", filename).Msg("上传异地备份失败")
+		} else {
+			logger.Info().Str("target", t.Name()).Str("file", filename).Int("pruned", result.Pruned).Msg("异地备份上传完成")
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// uploadTo 上传单个备份文件
+func (s *BackupService) uploadTo(t BackupTarget, filename string) error {
+	file, err := os.Open(s.GetBackupFilePath(filename))
+	if err != nil {
+		return ErrBackupNotFound
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), targetTimeout)
+	defer cancel()
+	return t.Upload(ctx, filename, file, info.Size())
+}
+
+// pruneTarget 只保留目标上最新的 keep 个同类备份，返回删除的数量
+func pruneTarget(t BackupTarget, engine string, keep int) (int, error) {
+	if keep <= 0 || engine == "" {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), targetTimeout)
+	defer cancel()
+
+	backups, err := t.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+	sortBackups(backups)
+
+	deleted := 0
+	for _, name := range backupsToPrune(backups, engine, keep) {
+		if err := t.Delete(ctx, name); err != nil {
+			logger.Warn().Err(err).Str("target", t.Name()).Str("file", name).Msg("删除远程旧备份失败")
+			continue
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// sortBackups 按时间倒序，时间相同时按文件名倒序（文件名包含时间戳）
+func sortBackups(backups []BackupInfo) {
+	sort.SliceStable(backups, func(i, j int) bool {
+		if !backups[i].CreatedAt.Equal(backups[j].CreatedAt) {
+			return backups[i].CreatedAt.After(backups[j].CreatedAt)
+		}
+		return backups[i].Filename > backups[j].Filename
+	})
+}
+
+// ListRemoteBackups 列出所有异地备份目标上的备份（按时间倒序），无法访问的目标记录日志后跳过
+func (s *BackupService) ListRemoteBackups() []BackupInfo {
+	var backups []BackupInfo
+	for _, t := range s.Targets() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		list, err := t.List(ctx)
+		cancel()
+		if err != nil {
+			logger.Warn().Err(err).Str("target", t.Name()).Msg("获取异地备份列表失败")
+			continue
+		}
+		for _, b := range list {
+			if BackupEngineOf(b.Filename) == "" {
+				continue
+			}
+			b.Target = t.Name()
+			backups = append(backups, b)
+		}
+	}
+	sortBackups(backups)
+	return backups
+}
+
+// FetchRemoteBackup 将异地备份下载到本地备份目录（本地已存在同名文件时直接使用），之后可按本地备份恢复
+func (s *BackupService) FetchRemoteBackup(targetName, filename string) error {
+	if filename != filepath.Base(filename) || BackupEngineOf(filename) == "" {
+		return ErrBackupNotFound
+	}
+	localPath := s.GetBackupFilePath(filename)
+	if _, err := os.Stat(localPath); err == nil {
+		return nil
+	}
+
+	tc, ok := s.targetConfig(targetName)
+	if !ok {
+		return ErrBackupTargetNotFound
+	}
+	t, err := NewBackupTarget(tc, s.backupDir, s.bot)
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件，下载完整后再改名，避免留下不完整的备份
+	tmp, err := os.CreateTemp(s.backupDir, ".download-*")
+	if err != nil {
+		return fmt.Errorf("创建临时文件失败: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	ctx, cancel := context.WithTimeout(context.Background(), targetTimeout)
+	defer cancel()
+
+	if err := t.Download(ctx, filename, tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("下载异地备份失败: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), localPath); err != nil {
+		return err
+	}
+
+	logger.Info().Str("target", targetName).Str("file", filename).Msg("已下载异地备份")
+	return nil
+}
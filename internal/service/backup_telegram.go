@@ -0,0 +1,306 @@
+// Package service Telegram 异地备份目标
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// telegramFileLimit Bot API 可下载的文件上限，超过的备份无法用于恢复，因此拒绝上传
+const telegramFileLimit = 20 << 20
+
+// telegramIndexFile 置顶在会话中的备份索引文件名
+const telegramIndexFile = "backup_index.json"
+
+var (
+	telegramMu  sync.Mutex // 保护索引读写
+	errNoBotSet = errors.New("Bot 未初始化，无法发送备份")
+)
+
+// telegramBackup 已发送到 Telegram 的备份记录
+type telegramBackup struct {
+	Filename  string    `json:"filename"`
+	FileID    string    `json:"file_id"`
+	ChatID    int64     `json:"chat_id"`
+	MessageID int       `json:"message_id"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// telegramIndex 会话中所有 Telegram 目标的备份索引（按目标名称分组）
+type telegramIndex struct {
+	Targets   map[string][]telegramBackup `json:"targets"`
+	messageID int                         // 当前置顶的索引消息
+}
+
+// TelegramTarget 以文件形式发送到 Owner（或指定会话）的备份目标
+// Telegram 无法列出历史文件，已发送的备份记录在会话中置顶的索引文件里（本地备份目录保留一份缓存），
+// 本地备份目录丢失后仍可从会话中找回索引
+type TelegramTarget struct {
+	cfg       config.BackupTargetConfig
+	bot       *tele.Bot
+	backupDir string
+}
+
+// NewTelegramTarget 创建 Telegram 备份目标
+func NewTelegramTarget(cfg config.BackupTargetConfig, backupDir string, bot *tele.Bot) *TelegramTarget {
+	return &TelegramTarget{
+		cfg:       cfg,
+		bot:       bot,
+		backupDir: backupDir,
+	}
+}
+
+// Name 目标名称
+func (t *TelegramTarget) Name() string {
+	return t.cfg.Name
+}
+
+// chatID 接收备份的会话
+func (t *TelegramTarget) chatID() int64 {
+	if t.cfg.ChatID != 0 {
+		return t.cfg.ChatID
+	}
+	return config.Get().Owner
+}
+
+// cachePath 本地索引缓存路径
+func (t *TelegramTarget) cachePath() string {
+	return filepath.Join(t.backupDir, fmt.Sprintf(".telegram_index_%d.json", t.chatID()))
+}
+
+// load 读取索引：优先使用会话中置顶的索引文件，找不到时（如置顶被其他消息覆盖）使用本地缓存
+func (t *TelegramTarget) load() (*telegramIndex, error) {
+	idx, err := t.loadPinned()
+	if err != nil {
+		return nil, err
+	}
+	if idx != nil {
+		return idx, nil
+	}
+
+	idx = &telegramIndex{Targets: make(map[string][]telegramBackup)}
+	data, err := os.ReadFile(t.cachePath())
+	if os.IsNotExist(err) {
+		return idx, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, idx); err != nil {
+		return nil, fmt.Errorf("解析 Telegram 备份索引缓存失败: %w", err)
+	}
+	return idx, nil
+}
+
+// loadPinned 读取会话中置顶的索引文件，没有置顶索引时返回 nil
+func (t *TelegramTarget) loadPinned() (*telegramIndex, error) {
+	chat, err := t.bot.ChatByID(t.chatID())
+	if err != nil {
+		return nil, fmt.Errorf("获取备份会话失败: %w", err)
+	}
+	pinned := chat.PinnedMessage
+	if pinned == nil || pinned.Document == nil || pinned.Document.FileName != telegramIndexFile {
+		return nil, nil
+	}
+
+	rc, err := t.bot.File(&pinned.Document.File)
+	if err != nil {
+		return nil, fmt.Errorf("下载 Telegram 备份索引失败: %w", err)
+	}
+	defer rc.Close()
+
+	idx := &telegramIndex{}
+	if err := json.NewDecoder(rc).Decode(idx); err != nil {
+		return nil, fmt.Errorf("解析 Telegram 备份索引失败: %w", err)
+	}
+	if idx.Targets == nil {
+		idx.Targets = make(map[string][]telegramBackup)
+	}
+	idx.messageID = pinned.ID
+	return idx, nil
+}
+
+// save 发送并置顶新的索引文件，删除旧的索引消息，同时更新本地缓存
+func (t *TelegramTarget) save(idx *telegramIndex) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(t.cachePath(), data, 0644); err != nil {
+		logger.Warn().Err(err).Str("target", t.cfg.Name).Msg("写入 Telegram 备份索引缓存失败")
+	}
+
+	chatID := t.chatID()
+	doc := &tele.Document{
+		File:     tele.FromReader(bytes.NewReader(data)),
+		FileName: telegramIndexFile,
+		Caption:  "🗂 备份索引（请勿删除或取消置顶）",
+	}
+	msg, err := t.bot.Send(&tele.Chat{ID: chatID}, doc, tele.Silent)
+	if err != nil {
+		return fmt.Errorf("发送 Telegram 备份索引失败: %w", err)
+	}
+	if err := t.bot.Pin(msg, tele.Silent); err != nil {
+		return fmt.Errorf("置顶 Telegram 备份索引失败: %w", err)
+	}
+
+	if idx.messageID != 0 {
+		old := &tele.StoredMessage{MessageID: strconv.Itoa(idx.messageID), ChatID: chatID}
+		if err := t.bot.Delete(old); err != nil {
+			logger.Debug().Err(err).Msg("删除旧的 Telegram 备份索引失败")
+		}
+	}
+	idx.messageID = msg.ID
+	return nil
+}
+
+// find 按文件名查找记录
+func (t *TelegramTarget) find(filename string) (*telegramBackup, error) {
+	telegramMu.Lock()
+	defer telegramMu.Unlock()
+
+	idx, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	entries := idx.Targets[t.cfg.Name]
+	for i := range entries {
+		if entries[i].Filename == filename {
+			return &entries[i], nil
+		}
+	}
+	return nil, ErrBackupNotFound
+}
+
+// Upload 以文件形式发送备份
+// Bot 只能下载 20MB 以内的文件，超过的备份无法恢复，直接拒绝
+func (t *TelegramTarget) Upload(ctx context.Context, filename string, r io.Reader, size int64) error {
+	if t.bot == nil {
+		return errNoBotSet
+	}
+	if size > telegramFileLimit {
+		return fmt.Errorf("备份文件 %.1fMB 超过 Telegram 上限 %dMB，请改用 S3 或 WebDAV 目标",
+			float64(size)/(1<<20), telegramFileLimit>>20)
+	}
+	chatID := t.chatID()
+	if chatID == 0 {
+		return fmt.Errorf("Telegram 目标 %s 未配置 chat_id 且未设置 owner", t.cfg.Name)
+	}
+
+	doc := &tele.Document{
+		File:     tele.FromReader(r),
+		FileName: filename,
+		Caption:  fmt.Sprintf("💾 数据库备份 %s", filename),
+	}
+	msg, err := t.bot.Send(&tele.Chat{ID: chatID}, doc)
+	if err != nil {
+		return err
+	}
+	if msg.Document == nil {
+		return fmt.Errorf("发送备份文件失败")
+	}
+
+	telegramMu.Lock()
+	defer telegramMu.Unlock()
+
+	idx, err := t.load()
+	if err != nil {
+		return err
+	}
+	idx.Targets[t.cfg.Name] = append(idx.Targets[t.cfg.Name], telegramBackup{
+		Filename:  filename,
+		FileID:    msg.Document.FileID,
+		ChatID:    chatID,
+		MessageID: msg.ID,
+		Size:      size,
+		CreatedAt: time.Now(),
+	})
+	return t.save(idx)
+}
+
+// List 列出已发送的备份
+func (t *TelegramTarget) List(ctx context.Context) ([]BackupInfo, error) {
+	if t.bot == nil {
+		return nil, errNoBotSet
+	}
+	telegramMu.Lock()
+	defer telegramMu.Unlock()
+
+	idx, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+	entries := idx.Targets[t.cfg.Name]
+	backups := make([]BackupInfo, 0, len(entries))
+	for _, e := range entries {
+		backups = append(backups, BackupInfo{Filename: e.Filename, Size: e.Size, CreatedAt: e.CreatedAt})
+	}
+	return backups, nil
+}
+
+// Download 下载已发送的备份（Bot API 仅支持下载 20MB 以内的文件）
+func (t *TelegramTarget) Download(ctx context.Context, filename string, w io.Writer) error {
+	if t.bot == nil {
+		return errNoBotSet
+	}
+	entry, err := t.find(filename)
+	if err != nil {
+		return err
+	}
+	if entry.Size > telegramFileLimit {
+		return fmt.Errorf("备份文件 %.1fMB 超过 Telegram Bot 下载上限 %dMB，请在 Telegram 中手动下载后放入备份目录",
+			float64(entry.Size)/(1<<20), telegramFileLimit>>20)
+	}
+
+	rc, err := t.bot.File(&tele.File{FileID: entry.FileID})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(w, rc)
+	return err
+}
+
+// Delete 删除备份消息并移除索引记录（消息删除失败时仅记录日志）
+func (t *TelegramTarget) Delete(ctx context.Context, filename string) error {
+	if t.bot == nil {
+		return errNoBotSet
+	}
+	telegramMu.Lock()
+	defer telegramMu.Unlock()
+
+	idx, err := t.load()
+	if err != nil {
+		return err
+	}
+
+	entries := idx.Targets[t.cfg.Name]
+	kept := entries[:0]
+	for _, e := range entries {
+		if e.Filename != filename {
+			kept = append(kept, e)
+			continue
+		}
+		msg := &tele.StoredMessage{MessageID: strconv.Itoa(e.MessageID), ChatID: e.ChatID}
+		if err := t.bot.Delete(msg); err != nil {
+			logger.Warn().Err(err).Str("file", filename).Msg("删除 Telegram 备份消息失败")
+		}
+	}
+	idx.Targets[t.cfg.Name] = kept
+	return t.save(idx)
+}
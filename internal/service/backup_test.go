@@ -0,0 +1,183 @@
+// Package service 数据库备份测试
+package service
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/gorm/schema"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func testBackupTables(t *testing.T) []backupTable {
+	t.Helper()
+	tables, err := parseBackupTables(schema.NamingStrategy{})
+	if err != nil {
+		t.Fatalf("parseBackupTables() error = %v", err)
+	}
+	return tables
+}
+
+func findBackupTable(t *testing.T, tables []backupTable, name string) backupTable {
+	t.Helper()
+	for _, table := range tables {
+		if table.Name == name {
+			return table
+		}
+	}
+	t.Fatalf("缺少表 %s", name)
+	return backupTable{}
+}
+
+func TestParseBackupTables(t *testing.T) {
+	tables := testBackupTables(t)
+	if len(tables) != len(database.AllModels()) {
+		t.Errorf("表数量 = %d, want %d", len(tables), len(database.AllModels()))
+	}
+	for _, name := range []string{"emby", "Rcode", "red_envelopes", "red_envelope_records", "favorites", "request_records", "points_ledger"} {
+		findBackupTable(t, tables, name)
+	}
+}
+
+func TestBackupRowsRoundTrip(t *testing.T) {
+	tables := testBackupTables(t)
+	table := findBackupTable(t, tables, models.EmbyAccount{}.TableName())
+
+	created := time.Date(2026, 3, 1, 12, 0, 0, 0, time.FixedZone("CST", 8*3600))
+	accounts := []models.EmbyAccount{{ID: 7, TG: 42, Pwd: "secret", CreatedAt: created}}
+	rows := encodeRows(table.Schema, reflect.ValueOf(accounts))
+
+	raw, err := json.Marshal(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, unknown, err := decodeRows(table.Schema, raw)
+	if err != nil {
+		t.Fatalf("decodeRows() error = %v", err)
+	}
+	if len(unknown) != 0 {
+		t.Errorf("unknown = %v", unknown)
+	}
+	if len(decoded) != 1 || decoded[0]["pwd"] != "secret" || decoded[0]["tg"] != int64(42) {
+		t.Errorf("按列名备份应保留 json:\"-\" 字段, got %v", decoded)
+	}
+	if got, ok := decoded[0]["created_at"].(time.Time); !ok || !got.Equal(created) {
+		t.Errorf("created_at = %v", decoded[0]["created_at"])
+	}
+
+	_, unknown, err = decodeRows(table.Schema, json.RawMessage(`[{"id":1,"legacy_col":"x"}]`))
+	if err != nil || len(unknown) != 1 || unknown[0] != "legacy_col" {
+		t.Errorf("未知列 = %v, err = %v", unknown, err)
+	}
+}
+
+func TestCheckBackupVersion(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    BackupData
+		wantErr error
+		ok      bool
+	}{
+		{"旧格式", BackupData{Version: "1.0"}, nil, true},
+		{"当前版本", BackupData{FormatVersion: BackupFormatVersion, SchemaVersion: database.SchemaVersion}, nil, true},
+		{"较旧的表结构", BackupData{FormatVersion: BackupFormatVersion, SchemaVersion: 1}, nil, true},
+		{"格式过新", BackupData{FormatVersion: BackupFormatVersion + 1}, ErrBackupFormatUnsupported, false},
+		{"表结构过新", BackupData{FormatVersion: BackupFormatVersion, SchemaVersion: database.SchemaVersion + 1}, ErrBackupSchemaNewer, false},
+		{"无法识别", BackupData{}, nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkBackupVersion(&tt.data)
+			if (err == nil) != tt.ok {
+				t.Fatalf("checkBackupVersion() error = %v", err)
+			}
+			if tt.wantErr != nil && err != tt.wantErr {
+				t.Errorf("checkBackupVersion() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestBuildRestorePlan(t *testing.T) {
+	tables := testBackupTables(t)
+
+	t.Run("旧格式", func(t *testing.T) {
+		data := &BackupData{
+			Version: "1.0",
+			Emby:    []models.Emby{{TG: 1}, {TG: 2}},
+			Codes:   []models.Code{{Code: "abc"}},
+		}
+		plan, err := buildRestorePlan(data, tables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, diff := range plan.Tables {
+			switch diff.Table {
+			case "emby":
+				if diff.Skipped || diff.Backup != 2 {
+					t.Errorf("emby = %+v", diff)
+				}
+			case "Rcode", "red_envelopes":
+				if diff.Skipped {
+					t.Errorf("%s 不应跳过", diff.Table)
+				}
+			default:
+				if !diff.Skipped {
+					t.Errorf("旧格式不包含 %s，应保持不变", diff.Table)
+				}
+			}
+		}
+	})
+
+	t.Run("新格式", func(t *testing.T) {
+		data := &BackupData{
+			FormatVersion: BackupFormatVersion,
+			SchemaVersion: database.SchemaVersion,
+			Tables: map[string]json.RawMessage{
+				"favorites":   json.RawMessage(`[{"id":1,"tg":5,"item_id":"x"}]`),
+				"dropped_tbl": json.RawMessage(`[]`),
+			},
+		}
+		plan, err := buildRestorePlan(data, tables)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(plan.UnknownTables) != 1 || plan.UnknownTables[0] != "dropped_tbl" {
+			t.Errorf("UnknownTables = %v", plan.UnknownTables)
+		}
+		rows := plan.rows["favorites"]
+		if len(rows) != 1 || rows[0]["tg"] != int64(5) {
+			t.Errorf("favorites = %v", rows)
+		}
+	})
+}
+
+func TestDiffRows(t *testing.T) {
+	current := []backupRow{
+		{"id": 1, "name": "a"},
+		{"id": 2, "name": "b"},
+		{"id": 3, "name": "c"},
+	}
+	backup := []backupRow{
+		{"id": 1, "name": "a"},
+		{"id": 2, "name": "B"},
+		{"id": 4, "name": "d"},
+		{"id": 5, "name": "e"},
+	}
+
+	added, removed, changed := diffRows([]string{"id"}, current, backup)
+	if added != 2 || removed != 1 || changed != 1 {
+		t.Errorf("diffRows() = %d, %d, %d, want 2, 1, 1", added, removed, changed)
+	}
+
+	// 没有主键时按整行比较
+	added, removed, changed = diffRows(nil, current, backup)
+	if added != 3 || removed != 2 || changed != 0 {
+		t.Errorf("diffRows(无主键) = %d, %d, %d, want 3, 2, 0", added, removed, changed)
+	}
+}
@@ -0,0 +1,212 @@
+// Package service WebDAV 异地备份目标
+package service
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+// propfindBody PROPFIND 请求体，只查询需要的属性
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// WebDAVTarget WebDAV 存储（Nextcloud、坚果云、Alist 等）
+type WebDAVTarget struct {
+	cfg    config.BackupTargetConfig
+	dir    string // 备份目录地址，以 / 结尾
+	client *http.Client
+}
+
+// NewWebDAVTarget 创建 WebDAV 备份目标
+func NewWebDAVTarget(cfg config.BackupTargetConfig) (*WebDAVTarget, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("WebDAV 目标 %s 缺少 url", cfg.Name)
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("WebDAV 目标 %s 的 url 无效", cfg.Name)
+	}
+	u.Path = strings.TrimRight(u.Path, "/") + "/"
+	if p := strings.Trim(cfg.Prefix, "/"); p != "" {
+		u.Path += p + "/"
+	}
+	return &WebDAVTarget{cfg: cfg, dir: u.String(), client: &http.Client{}}, nil
+}
+
+// Name 目标名称
+func (t *WebDAVTarget) Name() string {
+	return t.cfg.Name
+}
+
+// request 创建带认证的请求
+func (t *WebDAVTarget) request(ctx context.Context, method, target string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if t.cfg.Username != "" {
+		req.SetBasicAuth(t.cfg.Username, t.cfg.Password)
+	}
+	return req, nil
+}
+
+// fileURL 备份文件地址
+func (t *WebDAVTarget) fileURL(filename string) string {
+	return t.dir + url.PathEscape(filename)
+}
+
+// do 发送请求，状态码不在 ok 中时返回错误
+func (t *WebDAVTarget) do(req *http.Request, ok ...int) (*http.Response, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, code := range ok {
+		if resp.StatusCode == code {
+			return resp, nil
+		}
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return nil, fmt.Errorf("WebDAV %s 返回 %s: %s", req.Method, resp.Status, strings.TrimSpace(string(body)))
+}
+
+// ensureDir 创建备份目录（已存在时忽略）
+func (t *WebDAVTarget) ensureDir(ctx context.Context) error {
+	req, err := t.request(ctx, "MKCOL", t.dir, nil)
+	if err != nil {
+		return err
+	}
+	// 201 新建；405 已存在
+	resp, err := t.do(req, http.StatusCreated, http.StatusOK, http.StatusMethodNotAllowed)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Upload 上传备份文件
+func (t *WebDAVTarget) Upload(ctx context.Context, filename string, r io.Reader, size int64) error {
+	if err := t.ensureDir(ctx); err != nil {
+		return err
+	}
+	req, err := t.request(ctx, http.MethodPut, t.fileURL(filename), r)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	resp, err := t.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// davMultistatus PROPFIND 响应
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// List 列出备份目录下的文件
+func (t *WebDAVTarget) List(ctx context.Context) ([]BackupInfo, error) {
+	req, err := t.request(ctx, "PROPFIND", t.dir, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml; charset=utf-8")
+
+	resp, err := t.do(req, http.StatusMultiStatus, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil // 目录尚未创建
+	}
+
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("解析 WebDAV 列表失败: %w", err)
+	}
+
+	var backups []BackupInfo
+	for _, r := range ms.Responses {
+		href, err := url.PathUnescape(r.Href)
+		if err != nil {
+			href = r.Href
+		}
+		if strings.HasSuffix(href, "/") {
+			continue
+		}
+		info := BackupInfo{Filename: path.Base(href)}
+		isDir := false
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				isDir = true
+			}
+			info.Size = ps.Prop.ContentLength
+			if t, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+				info.CreatedAt = t
+			}
+		}
+		if !isDir {
+			backups = append(backups, info)
+		}
+	}
+	return backups, nil
+}
+
+// Download 下载备份文件
+func (t *WebDAVTarget) Download(ctx context.Context, filename string, w io.Writer) error {
+	req, err := t.request(ctx, http.MethodGet, t.fileURL(filename), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, http.StatusOK)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// Delete 删除备份文件
+func (t *WebDAVTarget) Delete(ctx context.Context, filename string) error {
+	req, err := t.request(ctx, http.MethodDelete, t.fileURL(filename), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(req, http.StatusNoContent, http.StatusOK, http.StatusNotFound)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -5,12 +5,11 @@ import (
 	"fmt"
 	"time"
 
-	tele "gopkg.in/telebot.v3"
-
 	"github.com/smysle/sakura-embyboss-go/internal/config"
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -19,7 +18,7 @@ type BatchService struct {
 	embyRepo   *repository.EmbyRepository
 	embyClient *emby.Client
 	cfg        *config.Config
-	bot        *tele.Bot
+	notifier   notify.Sender
 }
 
 // BatchResult 批量操作结果
@@ -37,14 +36,10 @@ func NewBatchService() *BatchService {
 		embyRepo:   repository.NewEmbyRepository(),
 		embyClient: emby.GetClient(),
 		cfg:        config.Get(),
+		notifier:   notify.Get(),
 	}
 }
 
-// SetBot 设置 Bot 实例
-func (s *BatchService) SetBot(bot *tele.Bot) {
-	s.bot = bot
-}
-
 // SyncGroupMembers 同步群组成员（删除不在群组的用户）
 func (s *BatchService) SyncGroupMembers(groupID int64, memberIDs []int64) (*BatchResult, error) {
 	result := &BatchResult{
@@ -80,6 +75,7 @@ func (s *BatchService) SyncGroupMembers(groupID int64, memberIDs []int64) (*Batc
 				continue
 			}
 		}
+		DeleteServerAccounts(user.TG)
 
 		// 更新数据库
 		s.embyRepo.UpdateFields(user.TG, map[string]interface{}{
@@ -100,10 +96,11 @@ func (s *BatchService) SyncGroupMembers(groupID int64, memberIDs []int64) (*Batc
 		result.Details = append(result.Details, fmt.Sprintf("已删除: %s (TG: %d)", username, user.TG))
 
 		// 通知用户
-		if s.bot != nil {
-			chat := &tele.Chat{ID: user.TG}
-			s.bot.Send(chat, "⚠️ 您的 Emby 账户已被删除，因为您已不在群组中。")
-		}
+		s.notifier.NotifyUser(user.TG, &notify.Message{
+			Event: notify.EventMemberLeft,
+			Title: "账户已被删除",
+			Text:  "⚠️ 您的 Emby 账户已被删除，因为您已不在群组中。",
+		})
 	}
 
 	return result, nil
@@ -195,6 +192,7 @@ func (s *BatchService) BanAll() (*BatchResult, error) {
 		s.embyRepo.UpdateFields(user.TG, map[string]interface{}{
 			"lv": models.LevelE,
 		})
+		DisableServers(user.TG)
 
 		result.Success++
 	}
@@ -233,6 +231,7 @@ func (s *BatchService) UnbanAll() (*BatchResult, error) {
 		s.embyRepo.UpdateFields(user.TG, map[string]interface{}{
 			"lv": models.LevelD,
 		})
+		SyncServers(user.TG)
 
 		result.Success++
 	}
@@ -263,8 +262,12 @@ func (s *BatchService) BindAllIDs() (*BatchResult, error) {
 			continue
 		}
 
-		// 更新 EmbyID
-		if err := s.embyRepo.UpdateFields(dbUser.TG, map[string]interface{}{
+		// 更新 EmbyID，原先没有账户（或账户已变化）时视为重新绑定
+		update := s.embyRepo.UpdateFields
+		if dbUser.EmbyID == nil || *dbUser.EmbyID != user.ID {
+			update = s.embyRepo.BindAccount
+		}
+		if err := update(dbUser.TG, map[string]interface{}{
 			"embyid": user.ID,
 		}); err != nil {
 			result.Failed++
@@ -298,6 +301,7 @@ func (s *BatchService) RenewAll(days int, level models.UserLevel) (*BatchResult,
 
 	result.Total = len(users)
 	newExpiry := time.Now().AddDate(0, 0, days)
+	expirySvc := NewExpiryService()
 
 	for _, user := range users {
 		if user.Lv == models.LevelA {
@@ -312,6 +316,10 @@ func (s *BatchService) RenewAll(days int, level models.UserLevel) (*BatchResult,
 			continue
 		}
 
+		if err := expirySvc.Renewed(user.TG); err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("恢复续期用户失败")
+		}
+
 		result.Success++
 	}
 
@@ -345,6 +353,7 @@ func (s *BatchService) DeleteAll(confirm bool) (*BatchResult, error) {
 				continue
 			}
 		}
+		DeleteServerAccounts(user.TG)
 
 		// 清空数据库记录
 		s.embyRepo.UpdateFields(user.TG, map[string]interface{}{
@@ -0,0 +1,734 @@
+// Package service 广播服务
+package service
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// broadcastBatch 每次从数据库读取的待发送接收者数量
+const broadcastBatch = 100
+
+var (
+	ErrBroadcastEmpty     = errors.New("消息内容不能为空")
+	ErrBroadcastNoUsers   = errors.New("没有符合条件的用户")
+	ErrBroadcastState     = errors.New("当前状态无法执行该操作")
+	ErrBroadcastNotReady  = errors.New("Bot 未初始化，无法发送广播")
+	errBroadcastInterrupt = errors.New("广播已暂停或取消")
+)
+
+// BroadcastAudience 广播受众条件，各条件同时满足
+type BroadcastAudience struct {
+	Levels   []models.UserLevel // lv=a,b
+	HasEmby  *bool              // emby=1 有 Emby 账户 / emby=0 没有
+	Expire   int                // expire=N N 天内到期（尚未过期）
+	Expired  int                // expired=N 过期不超过 N 天
+	Active   int                // active=N N 天内有观看或登录
+	Inactive int                // inactive=N N 天内没有观看或登录
+}
+
+// ParseBroadcastArgs 解析 /broadcast 参数：开头的 key=value（或 all）为受众条件，其余为消息模板
+func ParseBroadcastArgs(payload string) (BroadcastAudience, string, error) {
+	var audience BroadcastAudience
+	rest := strings.TrimLeftFunc(payload, unicode.IsSpace)
+	for rest != "" {
+		end := strings.IndexFunc(rest, unicode.IsSpace)
+		if end < 0 {
+			end = len(rest)
+		}
+		token := rest[:end]
+		if !isAudienceToken(token) {
+			break
+		}
+		if err := audience.set(token); err != nil {
+			return audience, "", err
+		}
+		rest = strings.TrimLeftFunc(rest[end:], unicode.IsSpace)
+	}
+	return audience, strings.TrimSpace(rest), audience.validate()
+}
+
+// ParseBroadcastAudience 解析保存的受众条件（BroadcastAudience.String 的结果）
+func ParseBroadcastAudience(s string) (BroadcastAudience, error) {
+	audience, rest, err := ParseBroadcastArgs(s)
+	if err == nil && rest != "" {
+		err = fmt.Errorf("无法识别的受众条件: %s", rest)
+	}
+	return audience, err
+}
+
+// isAudienceToken 是否为受众条件参数
+func isAudienceToken(token string) bool {
+	if token == "all" {
+		return true
+	}
+	key, _, ok := strings.Cut(token, "=")
+	if !ok {
+		return false
+	}
+	switch key {
+	case "lv", "emby", "expire", "expired", "active", "inactive":
+		return true
+	}
+	return false
+}
+
+// set 设置单个受众条件
+func (a *BroadcastAudience) set(token string) error {
+	if token == "all" {
+		return nil
+	}
+	key, value, _ := strings.Cut(token, "=")
+
+	switch key {
+	case "lv":
+		a.Levels = nil
+		for _, lv := range strings.Split(value, ",") {
+			switch level := models.UserLevel(strings.ToLower(lv)); level {
+			case models.LevelA, models.LevelB, models.LevelC, models.LevelD, models.LevelE:
+				a.Levels = append(a.Levels, level)
+			default:
+				return fmt.Errorf("无效的等级: %s（可选 a/b/c/d/e）", lv)
+			}
+		}
+		return nil
+	case "emby":
+		has := value == "1" || value == "yes" || value == "true"
+		if !has && value != "0" && value != "no" && value != "false" {
+			return fmt.Errorf("emby 只能为 1 或 0")
+		}
+		a.HasEmby = &has
+		return nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err != nil || days <= 0 {
+		return fmt.Errorf("%s 需要正整数天数", key)
+	}
+	switch key {
+	case "expire":
+		a.Expire = days
+	case "expired":
+		a.Expired = days
+	case "active":
+		a.Active = days
+	case "inactive":
+		a.Inactive = days
+	}
+	return nil
+}
+
+// validate 检查互斥条件
+func (a BroadcastAudience) validate() error {
+	if a.Expire > 0 && a.Expired > 0 {
+		return fmt.Errorf("expire 与 expired 不能同时使用")
+	}
+	if a.Active > 0 && a.Inactive > 0 {
+		return fmt.Errorf("active 与 inactive 不能同时使用")
+	}
+	return nil
+}
+
+// String 受众条件的参数形式（保存到任务中，可再次解析）
+func (a BroadcastAudience) String() string {
+	var parts []string
+	if len(a.Levels) > 0 {
+		levels := make([]string, len(a.Levels))
+		for i, lv := range a.Levels {
+			levels[i] = string(lv)
+		}
+		parts = append(parts, "lv="+strings.Join(levels, ","))
+	}
+	if a.HasEmby != nil {
+		if *a.HasEmby {
+			parts = append(parts, "emby=1")
+		} else {
+			parts = append(parts, "emby=0")
+		}
+	}
+	for _, p := range []struct {
+		key  string
+		days int
+	}{{"expire", a.Expire}, {"expired", a.Expired}, {"active", a.Active}, {"inactive", a.Inactive}} {
+		if p.days > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", p.key, p.days))
+		}
+	}
+	if len(parts) == 0 {
+		return "all"
+	}
+	return strings.Join(parts, " ")
+}
+
+// Describe 受众条件说明
+func (a BroadcastAudience) Describe() string {
+	var parts []string
+	if len(a.Levels) > 0 {
+		levels := make([]string, len(a.Levels))
+		for i, lv := range a.Levels {
+			levels[i] = string(lv)
+		}
+		parts = append(parts, "等级 "+strings.Join(levels, "/"))
+	}
+	if a.HasEmby != nil {
+		if *a.HasEmby {
+			parts = append(parts, "有 Emby 账户")
+		} else {
+			parts = append(parts, "无 Emby 账户")
+		}
+	}
+	if a.Expire > 0 {
+		parts = append(parts, fmt.Sprintf("%d 天内到期", a.Expire))
+	}
+	if a.Expired > 0 {
+		parts = append(parts, fmt.Sprintf("已过期 %d 天内", a.Expired))
+	}
+	if a.Active > 0 {
+		parts = append(parts, fmt.Sprintf("%d 天内活跃", a.Active))
+	}
+	if a.Inactive > 0 {
+		parts = append(parts, fmt.Sprintf("%d 天未活跃", a.Inactive))
+	}
+	if len(parts) == 0 {
+		return "所有用户"
+	}
+	return strings.Join(parts, " · ")
+}
+
+// filter 数据库查询条件
+func (a BroadcastAudience) filter(now time.Time) repository.BroadcastFilter {
+	f := repository.BroadcastFilter{Levels: a.Levels, HasEmby: a.HasEmby}
+	if a.Expire > 0 {
+		to := now.AddDate(0, 0, a.Expire)
+		f.ExpireFrom, f.ExpireTo = &now, &to
+	}
+	if a.Expired > 0 {
+		from := now.AddDate(0, 0, -a.Expired)
+		f.ExpireFrom, f.ExpireTo = &from, &now
+	}
+	return f
+}
+
+// matchActivity 按最后活动时间判断是否满足活跃条件
+func (a BroadcastAudience) matchActivity(lastActivity *time.Time, now time.Time) bool {
+	if a.Active > 0 {
+		return lastActivity != nil && lastActivity.After(now.AddDate(0, 0, -a.Active))
+	}
+	if a.Inactive > 0 {
+		return lastActivity == nil || lastActivity.Before(now.AddDate(0, 0, -a.Inactive))
+	}
+	return true
+}
+
+// BroadcastVariables 消息模板支持的变量
+var BroadcastVariables = []string{"{name}", "{tg}", "{level}", "{expiry}", "{days}", "{balance}", "{coins}"}
+
+// RenderBroadcast 按用户渲染消息模板（模板为 HTML，变量值会转义）
+func RenderBroadcast(tpl string, u *models.Emby, now time.Time) string {
+	name := "用户"
+	if u.Name != nil && *u.Name != "" {
+		name = *u.Name
+	}
+
+	expiry, days := "未注册", "-"
+	switch {
+	case u.Lv == models.LevelA:
+		expiry, days = "永久", "∞"
+	case u.Ex != nil:
+		expiry = u.Ex.Format("2006-01-02")
+		days = "0"
+		if remaining := u.Ex.Sub(now); remaining > 0 {
+			days = strconv.Itoa(int((remaining + 24*time.Hour - 1) / (24 * time.Hour)))
+		}
+	}
+
+	return strings.NewReplacer(
+		"{name}", html.EscapeString(name),
+		"{tg}", strconv.FormatInt(u.TG, 10),
+		"{level}", html.EscapeString(u.GetLevelName()),
+		"{expiry}", expiry,
+		"{days}", days,
+		"{balance}", strconv.Itoa(u.Us),
+		"{coins}", strconv.Itoa(u.Iv),
+	).Replace(tpl)
+}
+
+// broadcastSender 发送广播所需的 Bot 能力
+type broadcastSender interface {
+	Send(to tele.Recipient, what interface{}, opts ...interface{}) (*tele.Message, error)
+	Edit(msg tele.Editable, what interface{}, opts ...interface{}) (*tele.Message, error)
+}
+
+// broadcastRun 正在执行的广播
+type broadcastRun struct {
+	mu        sync.Mutex
+	requested models.BroadcastStatus // 请求的暂停 / 取消状态
+	interrupt chan struct{}
+	done      chan struct{} // 发送协程退出后关闭
+}
+
+// stop 请求停止发送
+func (r *broadcastRun) stop(status models.BroadcastStatus) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.requested == "" {
+		r.requested = status
+		close(r.interrupt)
+	}
+}
+
+// stopped 已请求的停止状态（未请求时为空）
+func (r *broadcastRun) stopped() models.BroadcastStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.requested
+}
+
+var (
+	broadcastMu   sync.Mutex
+	broadcastBot  broadcastSender
+	broadcastRuns = map[uint]*broadcastRun{}
+)
+
+// BroadcastService 广播服务
+type BroadcastService struct {
+	repo     *repository.BroadcastRepository
+	embyRepo *repository.EmbyRepository
+	cfg      *config.Config
+}
+
+// NewBroadcastService 创建广播服务
+func NewBroadcastService() *BroadcastService {
+	return &BroadcastService{
+		repo:     repository.NewBroadcastRepository(),
+		embyRepo: repository.NewEmbyRepository(),
+		cfg:      config.Get(),
+	}
+}
+
+// InitBroadcast 设置发送广播的 Bot，并继续重启前未完成的广播
+func InitBroadcast(bot *tele.Bot) {
+	broadcastMu.Lock()
+	broadcastBot = bot
+	broadcastMu.Unlock()
+
+	svc := NewBroadcastService()
+	jobs, err := svc.repo.ListByStatus(models.BroadcastRunning)
+	if err != nil {
+		logger.Error().Err(err).Msg("查询未完成的广播失败")
+		return
+	}
+	for i := range jobs {
+		logger.Info().Uint("job", jobs[i].ID).Int("processed", jobs[i].Processed()).Int("total", jobs[i].Total).Msg("继续未完成的广播")
+		svc.launch(&jobs[i])
+	}
+}
+
+// Create 按受众条件生成接收者并创建待确认的广播任务
+func (s *BroadcastService) Create(creator int64, template string, audience BroadcastAudience) (*models.BroadcastJob, error) {
+	if strings.TrimSpace(template) == "" {
+		return nil, ErrBroadcastEmpty
+	}
+	tgs, err := s.resolveAudience(audience, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if len(tgs) == 0 {
+		return nil, ErrBroadcastNoUsers
+	}
+
+	job := &models.BroadcastJob{
+		Creator:   creator,
+		Template:  template,
+		Audience:  audience.String(),
+		Status:    models.BroadcastDraft,
+		CreatedAt: time.Now(),
+	}
+	if err := s.repo.Create(job, tgs); err != nil {
+		return nil, err
+	}
+	logger.Info().Uint("job", job.ID).Int64("creator", creator).Str("audience", job.Audience).Int("total", job.Total).Msg("创建广播任务")
+	return job, nil
+}
+
+// resolveAudience 查询符合条件的用户 TG ID
+func (s *BroadcastService) resolveAudience(audience BroadcastAudience, now time.Time) ([]int64, error) {
+	users, err := s.embyRepo.ListForBroadcast(audience.filter(now))
+	if err != nil {
+		return nil, err
+	}
+
+	var activity map[string]*time.Time
+	if audience.Active > 0 || audience.Inactive > 0 {
+		if activity, err = s.lastActivity(); err != nil {
+			return nil, err
+		}
+	}
+
+	tgs := make([]int64, 0, len(users))
+	for i := range users {
+		if activity != nil {
+			// 活跃条件只针对有 Emby 账户的用户
+			if !users[i].HasEmbyAccount() || !audience.matchActivity(activity[*users[i].EmbyID], now) {
+				continue
+			}
+		}
+		tgs = append(tgs, users[i].TG)
+	}
+	return tgs, nil
+}
+
+// lastActivity 各 Emby 用户的最后活动时间（Emby 最后活动与本地最近播放取较晚者）
+func (s *BroadcastService) lastActivity() (map[string]*time.Time, error) {
+	users, err := emby.GetClient().GetUsers()
+	if err != nil {
+		return nil, fmt.Errorf("获取 Emby 用户列表失败: %w", err)
+	}
+
+	var lastPlayed map[string]time.Time
+	if s.cfg.PlaybackHistory.Enabled {
+		if lastPlayed, err = NewPlaybackHistoryService().LastPlayed(); err != nil {
+			logger.Warn().Err(err).Msg("查询本地播放记录失败，仅使用 Emby 最后活动时间")
+		}
+	}
+
+	activity := make(map[string]*time.Time, len(users))
+	for _, u := range users {
+		activity[u.ID] = latestActivity(u.LastSeen, lastPlayed[u.ID])
+	}
+	return activity, nil
+}
+
+// Get 获取广播任务
+func (s *BroadcastService) Get(id uint) (*models.BroadcastJob, error) {
+	return s.repo.GetByID(id)
+}
+
+// Recent 最近的广播任务
+func (s *BroadcastService) Recent(limit int) ([]models.BroadcastJob, error) {
+	return s.repo.ListRecent(limit)
+}
+
+// Preview 以第一个接收者渲染模板，用于发送前预览
+func (s *BroadcastService) Preview(job *models.BroadcastJob) string {
+	u := &models.Emby{TG: job.Creator, Lv: models.LevelD}
+	if r, err := s.repo.FirstRecipient(job.ID); err == nil {
+		if user, err := s.embyRepo.GetByTG(r.TG); err == nil {
+			u = user
+		}
+	}
+	return RenderBroadcast(job.Template, u, time.Now())
+}
+
+// Start 开始或继续发送，进度消息为 chatID 中的 messageID
+func (s *BroadcastService) Start(id uint, chatID int64, messageID int) (*models.BroadcastJob, error) {
+	broadcastMu.Lock()
+	ready := broadcastBot != nil
+	broadcastMu.Unlock()
+	if !ready {
+		return nil, ErrBroadcastNotReady
+	}
+
+	ok, err := s.repo.UpdateStatus(id, models.BroadcastRunning, models.BroadcastDraft, models.BroadcastPaused)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBroadcastState
+	}
+	if messageID != 0 {
+		if err := s.repo.SetProgressMessage(id, chatID, messageID); err != nil {
+			logger.Warn().Err(err).Uint("job", id).Msg("记录广播进度消息失败")
+		}
+	}
+
+	job, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.launch(job)
+	return job, nil
+}
+
+// Pause 暂停发送，可通过 Start 继续
+func (s *BroadcastService) Pause(id uint) error {
+	return s.stop(id, models.BroadcastPaused, models.BroadcastRunning)
+}
+
+// Cancel 取消广播，未发送的用户不再发送
+func (s *BroadcastService) Cancel(id uint) error {
+	return s.stop(id, models.BroadcastCancelled, models.BroadcastDraft, models.BroadcastRunning, models.BroadcastPaused)
+}
+
+// stop 更新状态并通知正在执行的发送协程
+func (s *BroadcastService) stop(id uint, to models.BroadcastStatus, from ...models.BroadcastStatus) error {
+	ok, err := s.repo.UpdateStatus(id, to, from...)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrBroadcastState
+	}
+
+	broadcastMu.Lock()
+	run := broadcastRuns[id]
+	broadcastMu.Unlock()
+	if run != nil {
+		run.stop(to)
+	}
+	logger.Info().Uint("job", id).Str("status", string(to)).Msg("广播状态变更")
+	return nil
+}
+
+// launch 启动发送协程（同一任务只运行一个）
+func (s *BroadcastService) launch(job *models.BroadcastJob) {
+	broadcastMu.Lock()
+	defer broadcastMu.Unlock()
+	if broadcastBot == nil {
+		return
+	}
+	if prev, running := broadcastRuns[job.ID]; running {
+		// 暂停后立即继续时，等上一个协程退出再启动
+		go func() {
+			<-prev.done
+			s.launch(job)
+		}()
+		return
+	}
+	run := &broadcastRun{interrupt: make(chan struct{}), done: make(chan struct{})}
+	broadcastRuns[job.ID] = run
+	go s.run(job, run, broadcastBot)
+}
+
+// run 按速率逐个发送，定期刷新进度消息
+func (s *BroadcastService) run(job *models.BroadcastJob, run *broadcastRun, bot broadcastSender) {
+	defer func() {
+		broadcastMu.Lock()
+		delete(broadcastRuns, job.ID)
+		broadcastMu.Unlock()
+		close(run.done)
+	}()
+
+	rate := s.cfg.Broadcast.Rate
+	if rate <= 0 {
+		rate = 20
+	}
+	limiter := time.NewTicker(time.Second / time.Duration(rate))
+	defer limiter.Stop()
+	progressEvery := time.Duration(s.cfg.Broadcast.ProgressSeconds) * time.Second
+	lastProgress := time.Now()
+
+	s.refreshProgress(bot, job.ID)
+	for run.stopped() == "" {
+		recipients, err := s.repo.NextPending(job.ID, broadcastBatch)
+		if err != nil {
+			// 保持 running 状态，重启后继续
+			logger.Error().Err(err).Uint("job", job.ID).Msg("读取广播接收者失败")
+			return
+		}
+		if len(recipients) == 0 {
+			if _, err := s.repo.UpdateStatus(job.ID, models.BroadcastDone, models.BroadcastRunning); err != nil {
+				logger.Error().Err(err).Uint("job", job.ID).Msg("更新广播状态失败")
+			}
+			break
+		}
+
+		tgs := make([]int64, len(recipients))
+		for i, r := range recipients {
+			tgs[i] = r.TG
+		}
+		users, err := s.embyRepo.GetByTGs(tgs)
+		if err != nil {
+			logger.Error().Err(err).Uint("job", job.ID).Msg("读取广播用户失败")
+			return
+		}
+		byTG := make(map[int64]*models.Emby, len(users))
+		for i := range users {
+			byTG[users[i].TG] = &users[i]
+		}
+
+		for i := range recipients {
+			select {
+			case <-run.interrupt:
+			case <-limiter.C:
+			}
+			if run.stopped() != "" {
+				break
+			}
+
+			r := &recipients[i]
+			u := byTG[r.TG]
+			if u == nil {
+				u = &models.Emby{TG: r.TG, Lv: models.LevelD}
+			}
+			status, errMsg := s.deliver(bot, run, r.TG, RenderBroadcast(job.Template, u, time.Now()))
+			if status == "" {
+				break // 等待重试时被暂停或取消，保持待发送
+			}
+			if err := s.repo.MarkRecipient(r, status, errMsg); err != nil {
+				logger.Error().Err(err).Uint("job", job.ID).Int64("tg", r.TG).Msg("记录广播结果失败")
+			}
+			if status == models.RecipientBlocked {
+				if err := s.embyRepo.MarkBlocked(r.TG, time.Now()); err != nil {
+					logger.Warn().Err(err).Int64("tg", r.TG).Msg("标记屏蔽用户失败")
+				}
+			}
+
+			if time.Since(lastProgress) >= progressEvery {
+				s.refreshProgress(bot, job.ID)
+				lastProgress = time.Now()
+			}
+		}
+	}
+
+	final := s.refreshProgress(bot, job.ID)
+	if final != nil {
+		logger.Info().Uint("job", job.ID).Str("status", string(final.Status)).
+			Int("sent", final.Sent).Int("failed", final.Failed).Int("blocked", final.Blocked).
+			Msg("广播结束")
+	}
+}
+
+// deliver 发送一条消息，遇到 429 按 retry_after 等待后重试；返回接收者状态（被中断时为空）
+func (s *BroadcastService) deliver(bot broadcastSender, run *broadcastRun, tg int64, text string) (string, string) {
+	for attempt := 0; ; attempt++ {
+		_, err := bot.Send(&tele.Chat{ID: tg}, text, tele.ModeHTML, tele.NoPreview)
+		if err == nil {
+			return models.RecipientSent, ""
+		}
+
+		var flood tele.FloodError
+		if errors.As(err, &flood) && attempt < s.cfg.Broadcast.MaxRetries {
+			wait := time.Duration(flood.RetryAfter)*time.Second + 500*time.Millisecond
+			logger.Warn().Int64("tg", tg).Dur("wait", wait).Msg("广播触发限流，等待后重试")
+			select {
+			case <-run.interrupt:
+				return "", errBroadcastInterrupt.Error()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		logger.Debug().Err(err).Int64("tg", tg).Msg("发送广播失败")
+		return classifyBroadcastError(err), err.Error()
+	}
+}
+
+// classifyBroadcastError 区分用户不可达（屏蔽、注销、未启动 Bot）与其他发送失败
+func classifyBroadcastError(err error) string {
+	for _, target := range []error{tele.ErrBlockedByUser, tele.ErrUserIsDeactivated, tele.ErrNotStartedByUser, tele.ErrChatNotFound} {
+		if errors.Is(err, target) {
+			return models.RecipientBlocked
+		}
+	}
+	return models.RecipientFailed
+}
+
+// refreshProgress 重新读取任务并更新进度消息
+func (s *BroadcastService) refreshProgress(bot broadcastSender, id uint) *models.BroadcastJob {
+	job, err := s.repo.GetByID(id)
+	if err != nil {
+		logger.Warn().Err(err).Uint("job", id).Msg("读取广播任务失败")
+		return nil
+	}
+	if job.MessageID == 0 {
+		return job
+	}
+
+	msg := &tele.StoredMessage{MessageID: strconv.Itoa(job.MessageID), ChatID: job.ChatID}
+	if _, err := bot.Edit(msg, FormatBroadcastProgress(job), BroadcastControls(job)); err != nil &&
+		!errors.Is(err, tele.ErrSameMessageContent) {
+		logger.Debug().Err(err).Uint("job", id).Msg("更新广播进度失败")
+	}
+	return job
+}
+
+// broadcastStatusText 任务状态说明
+var broadcastStatusText = map[models.BroadcastStatus]string{
+	models.BroadcastDraft:     "📝 待确认",
+	models.BroadcastRunning:   "📤 发送中",
+	models.BroadcastPaused:    "⏸ 已暂停",
+	models.BroadcastCancelled: "🚫 已取消",
+	models.BroadcastDone:      "✅ 已完成",
+}
+
+// BroadcastStatusText 任务状态说明
+func BroadcastStatusText(status models.BroadcastStatus) string {
+	if text, ok := broadcastStatusText[status]; ok {
+		return text
+	}
+	return string(status)
+}
+
+// FormatBroadcastProgress 广播进度文本
+func FormatBroadcastProgress(job *models.BroadcastJob) string {
+	audience, _ := ParseBroadcastAudience(job.Audience)
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📢 广播 #%d  %s\n\n", job.ID, BroadcastStatusText(job.Status)))
+	sb.WriteString(fmt.Sprintf("受众: %s\n", audience.Describe()))
+	sb.WriteString(fmt.Sprintf("进度: %s %d/%d\n", progressBar(job.Processed(), job.Total, 12), job.Processed(), job.Total))
+	sb.WriteString(fmt.Sprintf("成功: %d | 失败: %d | 已屏蔽: %d\n", job.Sent, job.Failed, job.Blocked))
+	if job.StartedAt != nil {
+		end := time.Now()
+		if job.FinishedAt != nil {
+			end = *job.FinishedAt
+		}
+		sb.WriteString(fmt.Sprintf("用时: %s\n", end.Sub(*job.StartedAt).Round(time.Second)))
+	}
+	return sb.String()
+}
+
+// progressBar 文本进度条
+func progressBar(done, total, width int) string {
+	filled := 0
+	if total > 0 {
+		filled = done * width / total
+	}
+	if filled > width {
+		filled = width
+	}
+	return strings.Repeat("█", filled) + strings.Repeat("░", width-filled)
+}
+
+// BroadcastControls 按任务状态生成控制按钮（broadcast|start|id 等）
+func BroadcastControls(job *models.BroadcastJob) *tele.ReplyMarkup {
+	markup := &tele.ReplyMarkup{}
+	id := strconv.FormatUint(uint64(job.ID), 10)
+	refresh := markup.Data("🔄 刷新", "broadcast", "view", id)
+
+	switch job.Status {
+	case models.BroadcastDraft:
+		markup.Inline(markup.Row(
+			markup.Data("✅ 开始发送", "broadcast", "start", id),
+			markup.Data("🚫 取消", "broadcast", "cancel", id),
+		))
+	case models.BroadcastRunning:
+		markup.Inline(markup.Row(
+			markup.Data("⏸ 暂停", "broadcast", "pause", id),
+			markup.Data("🚫 取消", "broadcast", "cancel", id),
+		), markup.Row(refresh))
+	case models.BroadcastPaused:
+		markup.Inline(markup.Row(
+			markup.Data("▶️ 继续", "broadcast", "start", id),
+			markup.Data("🚫 取消", "broadcast", "cancel", id),
+		), markup.Row(refresh))
+	default:
+		markup.Inline(markup.Row(refresh))
+	}
+	return markup
+}
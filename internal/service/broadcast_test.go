@@ -0,0 +1,236 @@
+// Package service 广播服务测试
+package service
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestParseBroadcastArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		payload  string
+		audience string
+		template string
+		wantErr  bool
+	}{
+		{"仅消息", "维护通知\n今晚 23 点", "all", "维护通知\n今晚 23 点", false},
+		{"组合条件", "lv=a,B emby=1 expire=7 {name} 即将到期", "lv=a,b emby=1 expire=7", "{name} 即将到期", false},
+		{"保留换行", "inactive=30\n好久不见 {name}\n\n欢迎回来", "inactive=30", "好久不见 {name}\n\n欢迎回来", false},
+		{"all", "all 公告", "all", "公告", false},
+		{"普通等号不视为条件", "a=b 公告", "all", "a=b 公告", false},
+		{"无效等级", "lv=x 公告", "", "", true},
+		{"无效天数", "expire=0 公告", "", "", true},
+		{"无效 emby", "emby=maybe 公告", "", "", true},
+		{"互斥条件", "active=7 inactive=30 公告", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			audience, template, err := ParseBroadcastArgs(tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseBroadcastArgs() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if audience.String() != tt.audience || template != tt.template {
+				t.Errorf("ParseBroadcastArgs() = %q, %q", audience.String(), template)
+			}
+
+			parsed, err := ParseBroadcastAudience(audience.String())
+			if err != nil || parsed.String() != audience.String() {
+				t.Errorf("ParseBroadcastAudience(%q) = %q, %v", audience.String(), parsed.String(), err)
+			}
+		})
+	}
+}
+
+func TestBroadcastAudienceFilter(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+
+	f := BroadcastAudience{Expire: 7}.filter(now)
+	if !f.ExpireFrom.Equal(now) || !f.ExpireTo.Equal(now.AddDate(0, 0, 7)) {
+		t.Errorf("expire filter = %v ~ %v", f.ExpireFrom, f.ExpireTo)
+	}
+	f = BroadcastAudience{Expired: 30}.filter(now)
+	if !f.ExpireFrom.Equal(now.AddDate(0, 0, -30)) || !f.ExpireTo.Equal(now) {
+		t.Errorf("expired filter = %v ~ %v", f.ExpireFrom, f.ExpireTo)
+	}
+	if f := (BroadcastAudience{}).filter(now); f.ExpireFrom != nil || f.ExpireTo != nil || f.HasEmby != nil {
+		t.Errorf("empty filter = %+v", f)
+	}
+}
+
+func TestMatchActivity(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	recent := now.AddDate(0, 0, -2)
+	old := now.AddDate(0, 0, -40)
+
+	tests := []struct {
+		name     string
+		audience BroadcastAudience
+		last     *time.Time
+		expected bool
+	}{
+		{"活跃-最近有活动", BroadcastAudience{Active: 7}, &recent, true},
+		{"活跃-很久没活动", BroadcastAudience{Active: 7}, &old, false},
+		{"活跃-从未活动", BroadcastAudience{Active: 7}, nil, false},
+		{"不活跃-很久没活动", BroadcastAudience{Inactive: 30}, &old, true},
+		{"不活跃-从未活动", BroadcastAudience{Inactive: 30}, nil, true},
+		{"不活跃-最近有活动", BroadcastAudience{Inactive: 30}, &recent, false},
+		{"无条件", BroadcastAudience{}, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.audience.matchActivity(tt.last, now); got != tt.expected {
+				t.Errorf("matchActivity() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestRenderBroadcast(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	name := "<Sakura>"
+	ex := now.Add(36 * time.Hour)
+	past := now.Add(-time.Hour)
+
+	tests := []struct {
+		name     string
+		user     *models.Emby
+		expected string
+	}{
+		{"普通用户", &models.Emby{TG: 1, Name: &name, Lv: models.LevelB, Ex: &ex, Us: 30, Iv: 5},
+			"&lt;Sakura&gt; 1 2026-03-12 2 30 5"},
+		{"已过期", &models.Emby{TG: 2, Lv: models.LevelB, Ex: &past},
+			"用户 2 2026-03-10 0 0 0"},
+		{"白名单", &models.Emby{TG: 3, Lv: models.LevelA},
+			"用户 3 永久 ∞ 0 0"},
+		{"未注册", &models.Emby{TG: 4, Lv: models.LevelD},
+			"用户 4 未注册 - 0 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := RenderBroadcast("{name} {tg} {expiry} {days} {balance} {coins}", tt.user, now)
+			if got != tt.expected {
+				t.Errorf("RenderBroadcast() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestClassifyBroadcastError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected string
+	}{
+		{tele.ErrBlockedByUser, models.RecipientBlocked},
+		{tele.ErrUserIsDeactivated, models.RecipientBlocked},
+		{fmt.Errorf("send: %w", tele.ErrChatNotFound), models.RecipientBlocked},
+		{errors.New("telegram: Bad Request: can't parse entities (400)"), models.RecipientFailed},
+	}
+	for _, tt := range tests {
+		if got := classifyBroadcastError(tt.err); got != tt.expected {
+			t.Errorf("classifyBroadcastError(%v) = %s, want %s", tt.err, got, tt.expected)
+		}
+	}
+}
+
+// fakeBroadcastBot 按顺序返回预设错误的发送者
+type fakeBroadcastBot struct {
+	errs  []error
+	sends int
+}
+
+func (b *fakeBroadcastBot) Send(to tele.Recipient, what interface{}, opts ...interface{}) (*tele.Message, error) {
+	b.sends++
+	if len(b.errs) > 0 {
+		err := b.errs[0]
+		b.errs = b.errs[1:]
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &tele.Message{}, nil
+}
+
+func (b *fakeBroadcastBot) Edit(msg tele.Editable, what interface{}, opts ...interface{}) (*tele.Message, error) {
+	return &tele.Message{}, nil
+}
+
+func TestDeliver(t *testing.T) {
+	svc := &BroadcastService{cfg: &config.Config{Broadcast: config.BroadcastConfig{MaxRetries: 2}}}
+
+	// 429 后按 retry_after 等待并重试
+	bot := &fakeBroadcastBot{errs: []error{tele.FloodError{RetryAfter: 0}}}
+	run := &broadcastRun{interrupt: make(chan struct{})}
+	if status, _ := svc.deliver(bot, run, 1, "hi"); status != models.RecipientSent || bot.sends != 2 {
+		t.Errorf("deliver() = %s, sends = %d", status, bot.sends)
+	}
+
+	bot = &fakeBroadcastBot{errs: []error{tele.ErrBlockedByUser}}
+	if status, errMsg := svc.deliver(bot, run, 1, "hi"); status != models.RecipientBlocked || errMsg == "" {
+		t.Errorf("deliver() = %s, %s", status, errMsg)
+	}
+
+	// 等待重试时被暂停，接收者保持待发送
+	bot = &fakeBroadcastBot{errs: []error{tele.FloodError{RetryAfter: 60}}}
+	run.stop(models.BroadcastPaused)
+	if status, _ := svc.deliver(bot, run, 1, "hi"); status != "" {
+		t.Errorf("deliver() 被中断时 = %s", status)
+	}
+}
+
+func TestBroadcastControls(t *testing.T) {
+	tests := []struct {
+		status  models.BroadcastStatus
+		actions []string
+	}{
+		{models.BroadcastDraft, []string{"start", "cancel"}},
+		{models.BroadcastRunning, []string{"pause", "cancel", "view"}},
+		{models.BroadcastPaused, []string{"start", "cancel", "view"}},
+		{models.BroadcastDone, []string{"view"}},
+	}
+	for _, tt := range tests {
+		markup := BroadcastControls(&models.BroadcastJob{ID: 7, Status: tt.status})
+		var actions []string
+		for _, row := range markup.InlineKeyboard {
+			for _, btn := range row {
+				actions = append(actions, btn.Unique+"|"+btn.Data)
+			}
+		}
+		if len(actions) != len(tt.actions) {
+			t.Fatalf("%s: buttons = %v", tt.status, actions)
+		}
+		for i, action := range tt.actions {
+			if expected := "broadcast|" + action + "|7"; actions[i] != expected {
+				t.Errorf("%s: button %d = %q, want %q", tt.status, i, actions[i], expected)
+			}
+		}
+	}
+}
+
+func TestProgressBar(t *testing.T) {
+	tests := []struct {
+		done, total int
+		expected    string
+	}{
+		{0, 10, "░░░░"},
+		{5, 10, "██░░"},
+		{10, 10, "████"},
+		{0, 0, "░░░░"},
+	}
+	for _, tt := range tests {
+		if got := progressBar(tt.done, tt.total, 4); got != tt.expected {
+			t.Errorf("progressBar(%d, %d) = %q, want %q", tt.done, tt.total, got, tt.expected)
+		}
+	}
+}
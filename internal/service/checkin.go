@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"math/rand"
+	"sort"
 	"time"
 
 	"github.com/smysle/sakura-embyboss-go/internal/config"
@@ -19,12 +20,21 @@ var (
 	ErrAlreadyCheckedIn = errors.New("今日已签到")
 	ErrLevelNotAllowed  = errors.New("您的等级不允许签到")
 	ErrUserNotFound     = errors.New("用户不存在")
+	ErrRepairDisabled   = errors.New("补签卡暂未开放")
+	ErrNoRepairCard     = errors.New("您没有补签卡")
+	ErrNothingToRepair  = errors.New("最近没有可补签的日期")
 )
 
+// defaultCheckinStreakBonus 未配置奖励档位时的默认连签加成（连续天数 → 额外积分）
+var defaultCheckinStreakBonus = []struct{ streak, bonus int }{
+	{1, 0}, {3, 2}, {7, 5}, {14, 10}, {30, 15},
+}
+
 // CheckinResult 签到结果
 type CheckinResult struct {
 	Success     bool
-	Reward      int       // 获得的积分
+	Reward      int       // 获得的积分（含等级加成）
+	LevelBonus  int       // 等级加成积分
 	TotalScore  int       // 当前总积分
 	Consecutive int       // 连续签到天数
 	CheckinTime time.Time // 签到时间
@@ -33,15 +43,19 @@ type CheckinResult struct {
 
 // CheckinService 签到服务
 type CheckinService struct {
-	repo *repository.EmbyRepository
-	cfg  *config.Config
+	repo    *repository.EmbyRepository
+	logRepo *repository.CheckinLogRepository
+	points  *PointsService
+	cfg     *config.Config
 }
 
 // NewCheckinService 创建签到服务
 func NewCheckinService() *CheckinService {
 	return &CheckinService{
-		repo: repository.NewEmbyRepository(),
-		cfg:  config.Get(),
+		repo:    repository.NewEmbyRepository(),
+		logRepo: repository.NewCheckinLogRepository(),
+		points:  NewPointsService(),
+		cfg:     config.Get(),
 	}
 }
 
@@ -69,24 +83,40 @@ func (s *CheckinService) Checkin(tgID int64) (*CheckinResult, error) {
 		return nil, ErrAlreadyCheckedIn
 	}
 
-	// 计算连续签到天数
+	// 计算连续签到天数：优先按昨日签到记录（含补签），没有记录时按用户表的签到时间
 	consecutive := s.calculateConsecutiveDays(user, now)
+	if prev, err := s.logRepo.GetByDay(tgID, now.AddDate(0, 0, -1).Format(models.CheckinDayLayout)); err == nil {
+		consecutive = prev.Streak + 1
+	}
 
 	// 计算奖励
-	reward := s.calculateReward(consecutive)
+	levelBonus := s.levelBonus(user.Lv)
+	reward := s.calculateReward(consecutive) + levelBonus
 
-	// 更新用户信息
-	newScore := user.Us + reward
-	updates := map[string]interface{}{
-		"ch": now,          // 签到时间
-		"us": newScore,     // 更新积分
-		"ck": consecutive,  // 连续签到天数
+	// 占用今日签到（条件更新防止并发重复签到）、签到记录与奖励流水在同一事务中完成
+	todayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	entry := &models.PointsLedger{
+		TG:       tgID,
+		Currency: models.CurrencyScore,
+		Amount:   reward,
+		Reason:   models.LedgerReasonCheckin,
+		RefID:    now.Format("2006-01-02"),
 	}
-
-	if err := s.repo.UpdateFields(tgID, updates); err != nil {
-		logger.Error().Err(err).Int64("tg", tgID).Msg("签到更新失败")
+	checked, err := s.logRepo.Checkin(&models.CheckinLog{
+		TG:        tgID,
+		Day:       now.Format(models.CheckinDayLayout),
+		Reward:    reward,
+		Streak:    consecutive,
+		CreatedAt: now,
+	}, todayStart, entry)
+	if err != nil {
+		logger.Error().Err(err).Int64("tg", tgID).Msg("签到失败")
 		return nil, fmt.Errorf("签到失败: %w", err)
 	}
+	if !checked {
+		return nil, ErrAlreadyCheckedIn
+	}
+	newScore := entry.Balance
 
 	logger.Info().
 		Int64("tg", tgID).
@@ -97,6 +127,7 @@ func (s *CheckinService) Checkin(tgID int64) (*CheckinResult, error) {
 	return &CheckinResult{
 		Success:     true,
 		Reward:      reward,
+		LevelBonus:  levelBonus,
 		TotalScore:  newScore,
 		Consecutive: consecutive,
 		CheckinTime: now,
@@ -135,32 +166,50 @@ func (s *CheckinService) calculateConsecutiveDays(user *models.Emby, now time.Ti
 	return 1 // 断签，重新计算
 }
 
-// calculateReward 计算签到奖励
-func (s *CheckinService) calculateReward(consecutive int) int {
-	rewardRange := s.cfg.Open.CheckinReward
+// CheckinTiers 签到奖励档位（按连续天数升序）
+// 未配置 checkin.tiers 时，以 open.checkin_reward 为基础区间并叠加默认连签加成
+func CheckinTiers(cfg *config.Config) []config.CheckinTier {
+	if len(cfg.Checkin.Tiers) > 0 {
+		tiers := append([]config.CheckinTier(nil), cfg.Checkin.Tiers...)
+		sort.Slice(tiers, func(i, j int) bool { return tiers[i].Streak < tiers[j].Streak })
+		return tiers
+	}
+
+	rewardRange := cfg.Open.CheckinReward
 	if len(rewardRange) < 2 {
 		rewardRange = []int{1, 10} // 默认值
 	}
+	tiers := make([]config.CheckinTier, len(defaultCheckinStreakBonus))
+	for i, b := range defaultCheckinStreakBonus {
+		tiers[i] = config.CheckinTier{Streak: b.streak, Min: rewardRange[0] + b.bonus, Max: rewardRange[1] + b.bonus}
+	}
+	return tiers
+}
 
-	minReward := rewardRange[0]
-	maxReward := rewardRange[1]
-
-	// 基础随机奖励
-	baseReward := minReward + rand.Intn(maxReward-minReward+1)
+// rewardTier 连续签到天数达到的最高档位
+func (s *CheckinService) rewardTier(consecutive int) config.CheckinTier {
+	tiers := CheckinTiers(s.cfg)
+	tier := tiers[0]
+	for _, t := range tiers {
+		if consecutive >= t.Streak {
+			tier = t
+		}
+	}
+	return tier
+}
 
-	// 连续签到加成
-	bonus := 0
-	if consecutive >= 30 {
-		bonus = 15 // 连续30天加15分
-	} else if consecutive >= 14 {
-		bonus = 10 // 连续14天加10分
-	} else if consecutive >= 7 {
-		bonus = 5 // 连续7天加5分
-	} else if consecutive >= 3 {
-		bonus = 2 // 连续3天加2分
+// calculateReward 计算签到奖励（所在档位区间内随机）
+func (s *CheckinService) calculateReward(consecutive int) int {
+	tier := s.rewardTier(consecutive)
+	if tier.Max <= tier.Min {
+		return tier.Min
 	}
+	return tier.Min + rand.Intn(tier.Max-tier.Min+1)
+}
 
-	return baseReward + bonus
+// levelBonus 用户等级的额外奖励
+func (s *CheckinService) levelBonus(level models.UserLevel) int {
+	return s.cfg.Checkin.LevelBonus[string(level)]
 }
 
 // isLevelAllowed 检查用户等级是否允许签到
@@ -201,14 +250,8 @@ func (s *CheckinService) generateMessage(reward, consecutive int) string {
 
 	msg := messages[rand.Intn(len(messages))]
 
-	if consecutive >= 30 {
-		msg += " 🏆 连续签到30天，超级奖励！"
-	} else if consecutive >= 14 {
-		msg += " 🔥 连续签到14天，获得高额奖励！"
-	} else if consecutive >= 7 {
-		msg += " 🔥 连续签到7天，获得额外奖励！"
-	} else if consecutive >= 3 {
-		msg += " ⭐ 连续签到3天，获得小额加成！"
+	if tier := s.rewardTier(consecutive); tier.Streak > 1 {
+		msg += fmt.Sprintf(" 🔥 连续签到%d天，已达%d天奖励档！", consecutive, tier.Streak)
 	}
 
 	return msg
@@ -226,3 +269,345 @@ func (s *CheckinService) GetCheckinStatus(tgID int64) (hasCheckedIn bool, consec
 
 	return hasCheckedIn, user.Ck, user.Ch, nil
 }
+
+// BuyRepairCard 使用积分购买一张补签卡，返回当前补签卡数量和积分余额
+func (s *CheckinService) BuyRepairCard(tgID int64) (cards int, balance int, err error) {
+	cost := s.cfg.Checkin.RepairCost
+	if cost <= 0 {
+		return 0, 0, ErrRepairDisabled
+	}
+
+	user, err := s.repo.GetByTG(tgID)
+	if err != nil {
+		return 0, 0, ErrUserNotFound
+	}
+
+	entry, err := s.points.Debit(&PointsChange{
+		TG:       tgID,
+		Currency: models.CurrencyScore,
+		Amount:   cost,
+		Reason:   models.LedgerReasonRepairCard,
+		Actor:    tgID,
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if err := s.repo.AddRepairCards(tgID, 1); err != nil {
+		logger.Error().Err(err).Int64("tg", tgID).Msg("发放补签卡失败，退还积分")
+		if _, refundErr := s.points.Credit(&PointsChange{
+			TG:       tgID,
+			Currency: models.CurrencyScore,
+			Amount:   cost,
+			Reason:   models.LedgerReasonRepairCard,
+			Actor:    tgID,
+		}); refundErr != nil {
+			logger.Error().Err(refundErr).Int64("tg", tgID).Msg("退还补签卡积分失败")
+		}
+		return 0, 0, fmt.Errorf("购买失败: %w", err)
+	}
+
+	return user.RepairCards + 1, entry.Balance, nil
+}
+
+// RepairResult 补签结果
+type RepairResult struct {
+	Day    string // 补签的日期
+	Streak int    // 补签后的当前连续天数
+}
+
+// RepairStreak 消耗一张补签卡，补上最近可补签范围内最早漏签的一天并重算连续天数
+func (s *CheckinService) RepairStreak(tgID int64) (*RepairResult, error) {
+	if !s.cfg.Open.Checkin {
+		return nil, ErrCheckinDisabled
+	}
+	if s.cfg.Checkin.RepairCost <= 0 {
+		return nil, ErrRepairDisabled
+	}
+
+	user, err := s.repo.GetByTG(tgID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+	if user.RepairCards <= 0 {
+		return nil, ErrNoRepairCard
+	}
+
+	now := utils.TimeNowCST()
+	day, err := s.findRepairDay(tgID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	used, err := s.repo.UseRepairCard(tgID)
+	if err != nil {
+		return nil, fmt.Errorf("补签失败: %w", err)
+	}
+	if !used {
+		return nil, ErrNoRepairCard
+	}
+
+	created, err := s.logRepo.Create(&models.CheckinLog{
+		TG:        tgID,
+		Day:       day,
+		Repaired:  true,
+		CreatedAt: now,
+	})
+	if err != nil || !created {
+		// 补签记录未写入（并发补签或数据库错误），归还补签卡
+		if refundErr := s.repo.AddRepairCards(tgID, 1); refundErr != nil {
+			logger.Error().Err(refundErr).Int64("tg", tgID).Msg("归还补签卡失败")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("补签失败: %w", err)
+		}
+		return nil, ErrNothingToRepair
+	}
+
+	streak, err := s.recomputeStreaks(tgID, day, now)
+	if err != nil {
+		logger.Error().Err(err).Int64("tg", tgID).Str("day", day).Msg("重算连续签到天数失败")
+	}
+
+	logger.Info().Int64("tg", tgID).Str("day", day).Int("streak", streak).Msg("用户补签成功")
+
+	return &RepairResult{Day: day, Streak: streak}, nil
+}
+
+// findRepairDay 查找可补签的日期：最近 RepairDays 天内（不含今天）、首次签到之后最早漏签的一天
+func (s *CheckinService) findRepairDay(tgID int64, now time.Time) (string, error) {
+	repairDays := s.cfg.Checkin.RepairDays
+	if repairDays <= 0 {
+		return "", ErrNothingToRepair
+	}
+
+	first, err := s.logRepo.GetFirst(tgID)
+	if err != nil {
+		return "", ErrNothingToRepair
+	}
+
+	from := now.AddDate(0, 0, -repairDays).Format(models.CheckinDayLayout)
+	to := now.AddDate(0, 0, -1).Format(models.CheckinDayLayout)
+	if first.Day > from {
+		from = first.Day
+	}
+
+	logs, err := s.logRepo.ListRange(tgID, from, to)
+	if err != nil {
+		return "", err
+	}
+
+	day, ok := firstMissingDay(logs, from, to)
+	if !ok {
+		return "", ErrNothingToRepair
+	}
+	return day, nil
+}
+
+// firstMissingDay 返回 [from, to] 范围内第一个没有签到记录的日期
+func firstMissingDay(logs []models.CheckinLog, from, to string) (string, bool) {
+	start, err := time.Parse(models.CheckinDayLayout, from)
+	if err != nil {
+		return "", false
+	}
+	end, err := time.Parse(models.CheckinDayLayout, to)
+	if err != nil {
+		return "", false
+	}
+
+	logged := make(map[string]bool, len(logs))
+	for _, log := range logs {
+		logged[log.Day] = true
+	}
+
+	for d := start; !d.After(end); d = d.AddDate(0, 0, 1) {
+		if day := d.Format(models.CheckinDayLayout); !logged[day] {
+			return day, true
+		}
+	}
+	return "", false
+}
+
+// recalcStreaks 按日期升序的签到记录重算 from 及之后记录的连续天数，
+// from 之前的记录作为基准保持不变，返回需要更新的记录
+func recalcStreaks(logs []models.CheckinLog, from string) []models.CheckinLog {
+	var changed []models.CheckinLog
+	var prevDay time.Time
+	prevStreak := 0
+
+	for _, log := range logs {
+		day, err := time.Parse(models.CheckinDayLayout, log.Day)
+		if err != nil {
+			continue
+		}
+
+		if log.Day >= from {
+			streak := 1
+			if !prevDay.IsZero() && day.Equal(prevDay.AddDate(0, 0, 1)) {
+				streak = prevStreak + 1
+			}
+			if streak != log.Streak {
+				log.Streak = streak
+				changed = append(changed, log)
+			}
+		}
+
+		prevDay, prevStreak = day, log.Streak
+	}
+	return changed
+}
+
+// recomputeStreaks 补签后重算该日期之后的连续天数并同步用户表，返回当前连续天数
+func (s *CheckinService) recomputeStreaks(tgID int64, day string, now time.Time) (int, error) {
+	repaired, err := time.Parse(models.CheckinDayLayout, day)
+	if err != nil {
+		return 0, err
+	}
+
+	from := repaired.AddDate(0, 0, -1).Format(models.CheckinDayLayout)
+	today := now.Format(models.CheckinDayLayout)
+	logs, err := s.logRepo.ListRange(tgID, from, today)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := recalcStreaks(logs, day)
+	streaks := make(map[uint]int, len(changed))
+	for _, log := range changed {
+		if err := s.logRepo.UpdateStreak(log.ID, log.Streak); err != nil {
+			return 0, err
+		}
+		streaks[log.ID] = log.Streak
+	}
+
+	if len(logs) == 0 {
+		return 0, nil
+	}
+	last := logs[len(logs)-1]
+	streak := last.Streak
+	if v, ok := streaks[last.ID]; ok {
+		streak = v
+	}
+
+	// 最近一次签到是今天或昨天时，连续天数仍有效，同步到用户表
+	yesterday := now.AddDate(0, 0, -1).Format(models.CheckinDayLayout)
+	if last.Day != today && last.Day != yesterday {
+		return 0, nil
+	}
+	if err := s.repo.UpdateFields(tgID, map[string]interface{}{"ck": streak}); err != nil {
+		return streak, err
+	}
+	return streak, nil
+}
+
+// CheckinCalendar 用户某月的签到日历
+type CheckinCalendar struct {
+	Year        int
+	Month       time.Month
+	Days        map[int]bool // 日期 → 是否为补签
+	Today       int          // 当月中今天的日期（非当月为 0）
+	Streak      int          // 当前连续签到天数
+	CheckedIn   bool         // 今日是否已签到
+	RepairCards int
+	RepairDay   string // 可补签的日期（为空表示无）
+}
+
+// Calendar 获取用户某月的签到日历
+func (s *CheckinService) Calendar(tgID int64, year int, month time.Month) (*CheckinCalendar, error) {
+	user, err := s.repo.GetByTG(tgID)
+	if err != nil {
+		return nil, ErrUserNotFound
+	}
+
+	now := utils.TimeNowCST()
+	first := time.Date(year, month, 1, 0, 0, 0, 0, now.Location())
+	last := first.AddDate(0, 1, -1)
+	logs, err := s.logRepo.ListRange(tgID, first.Format(models.CheckinDayLayout), last.Format(models.CheckinDayLayout))
+	if err != nil {
+		return nil, err
+	}
+
+	cal := &CheckinCalendar{
+		Year:        year,
+		Month:       month,
+		Days:        make(map[int]bool, len(logs)),
+		CheckedIn:   s.hasCheckedInToday(user, now),
+		RepairCards: user.RepairCards,
+	}
+	for _, log := range logs {
+		if day, err := time.Parse(models.CheckinDayLayout, log.Day); err == nil {
+			cal.Days[day.Day()] = log.Repaired
+		}
+	}
+	if now.Year() == year && now.Month() == month {
+		cal.Today = now.Day()
+	}
+
+	// 今天或昨天有签到时连续天数有效
+	for _, day := range []string{now.Format(models.CheckinDayLayout), now.AddDate(0, 0, -1).Format(models.CheckinDayLayout)} {
+		if log, err := s.logRepo.GetByDay(tgID, day); err == nil {
+			cal.Streak = log.Streak
+			break
+		}
+	}
+
+	if s.cfg.Checkin.RepairCost > 0 {
+		if day, err := s.findRepairDay(tgID, now); err == nil {
+			cal.RepairDay = day
+		}
+	}
+
+	return cal, nil
+}
+
+// CheckinStats 签到参与统计
+type CheckinStats struct {
+	Days          int
+	Daily         []repository.CheckinDayStat
+	ActiveUsers   int64   // 统计周期内签到过的用户数（不含补签）
+	Accounts      int64   // 拥有 Emby 账户的用户数
+	Participation float64 // 参与率
+	TopStreaks    []models.Emby
+}
+
+// Stats 统计最近 days 天的签到参与情况
+func (s *CheckinService) Stats(days int) (*CheckinStats, error) {
+	if days <= 0 {
+		days = 7
+	}
+
+	now := utils.TimeNowCST()
+	from := now.AddDate(0, 0, -(days - 1)).Format(models.CheckinDayLayout)
+
+	daily, err := s.logRepo.DailyStats(from)
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.logRepo.CountUsersSince(from)
+	if err != nil {
+		return nil, err
+	}
+	_, accounts, _, err := s.repo.CountStats()
+	if err != nil {
+		return nil, err
+	}
+
+	// 昨天以来签到过的用户，连续天数仍然有效
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, now.Location())
+	top, err := s.repo.GetTopStreaks(yesterday, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &CheckinStats{
+		Days:        days,
+		Daily:       daily,
+		ActiveUsers: active,
+		Accounts:    accounts,
+		TopStreaks:  top,
+	}
+	if accounts > 0 {
+		stats.Participation = float64(active) / float64(accounts) * 100
+	}
+	return stats, nil
+}
@@ -158,3 +158,130 @@ func TestCheckinService_isLevelAllowed(t *testing.T) {
 		t.Error("封禁用户不应该被允许签到")
 	}
 }
+
+func TestCheckinTiers(t *testing.T) {
+	t.Run("未配置档位时使用默认加成", func(t *testing.T) {
+		cfg := &config.Config{Open: config.OpenConfig{CheckinReward: []int{5, 10}}}
+		tiers := CheckinTiers(cfg)
+		if len(tiers) != 5 || tiers[0] != (config.CheckinTier{Streak: 1, Min: 5, Max: 10}) ||
+			tiers[4] != (config.CheckinTier{Streak: 30, Min: 20, Max: 25}) {
+			t.Errorf("CheckinTiers() = %v", tiers)
+		}
+	})
+
+	t.Run("配置档位按连续天数排序", func(t *testing.T) {
+		cfg := &config.Config{Checkin: config.CheckinConfig{Tiers: []config.CheckinTier{
+			{Streak: 7, Min: 20, Max: 20}, {Streak: 1, Min: 1, Max: 1},
+		}}}
+		tiers := CheckinTiers(cfg)
+		if tiers[0].Streak != 1 || tiers[1].Streak != 7 {
+			t.Errorf("CheckinTiers() = %v", tiers)
+		}
+		if cfg.Checkin.Tiers[0].Streak != 7 {
+			t.Error("不应修改原配置")
+		}
+	})
+}
+
+func TestCheckinService_rewardTier(t *testing.T) {
+	svc := &CheckinService{cfg: &config.Config{Checkin: config.CheckinConfig{Tiers: []config.CheckinTier{
+		{Streak: 1, Min: 1, Max: 1}, {Streak: 3, Min: 3, Max: 3}, {Streak: 7, Min: 7, Max: 7},
+	}}}}
+
+	tests := []struct {
+		consecutive int
+		expected    int
+	}{
+		{1, 1}, {2, 1}, {3, 3}, {6, 3}, {7, 7}, {100, 7},
+	}
+
+	for _, tt := range tests {
+		if got := svc.calculateReward(tt.consecutive); got != tt.expected {
+			t.Errorf("calculateReward(%d) = %d, want %d", tt.consecutive, got, tt.expected)
+		}
+	}
+}
+
+func TestCheckinService_levelBonus(t *testing.T) {
+	svc := &CheckinService{cfg: &config.Config{Checkin: config.CheckinConfig{
+		LevelBonus: map[string]int{"a": 5, "b": 1},
+	}}}
+
+	tests := []struct {
+		level    models.UserLevel
+		expected int
+	}{
+		{models.LevelA, 5},
+		{models.LevelB, 1},
+		{models.LevelC, 0},
+	}
+
+	for _, tt := range tests {
+		if got := svc.levelBonus(tt.level); got != tt.expected {
+			t.Errorf("levelBonus(%s) = %d, want %d", tt.level, got, tt.expected)
+		}
+	}
+}
+
+func TestFirstMissingDay(t *testing.T) {
+	logs := func(days ...string) []models.CheckinLog {
+		var list []models.CheckinLog
+		for _, d := range days {
+			list = append(list, models.CheckinLog{Day: d})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name     string
+		logs     []models.CheckinLog
+		from, to string
+		expected string
+		ok       bool
+	}{
+		{"中间漏签", logs("2026-03-01", "2026-03-03"), "2026-03-01", "2026-03-03", "2026-03-02", true},
+		{"最早漏签优先", logs("2026-03-02"), "2026-03-01", "2026-03-03", "2026-03-01", true},
+		{"跨月", logs("2026-02-28"), "2026-02-28", "2026-03-01", "2026-03-01", true},
+		{"无漏签", logs("2026-03-01", "2026-03-02"), "2026-03-01", "2026-03-02", "", false},
+		{"范围无效", nil, "2026-03-05", "2026-03-01", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := firstMissingDay(tt.logs, tt.from, tt.to)
+			if got != tt.expected || ok != tt.ok {
+				t.Errorf("firstMissingDay() = %q, %v, want %q, %v", got, ok, tt.expected, tt.ok)
+			}
+		})
+	}
+}
+
+func TestRecalcStreaks(t *testing.T) {
+	// 3 月 1、2 日连签，3 日漏签后补签，4、5 日签到时连续天数从 1 重新计算
+	logs := []models.CheckinLog{
+		{ID: 1, Day: "2026-03-02", Streak: 2},
+		{ID: 2, Day: "2026-03-03", Streak: 0, Repaired: true},
+		{ID: 3, Day: "2026-03-04", Streak: 1},
+		{ID: 4, Day: "2026-03-05", Streak: 2},
+	}
+
+	changed := recalcStreaks(logs, "2026-03-03")
+	want := map[uint]int{2: 3, 3: 4, 4: 5}
+	if len(changed) != len(want) {
+		t.Fatalf("recalcStreaks() 更新了 %d 条记录, want %d", len(changed), len(want))
+	}
+	for _, log := range changed {
+		if want[log.ID] != log.Streak {
+			t.Errorf("记录 %d 连续天数 = %d, want %d", log.ID, log.Streak, want[log.ID])
+		}
+	}
+
+	// 补签日前一天没有记录时从 1 开始
+	changed = recalcStreaks([]models.CheckinLog{
+		{ID: 1, Day: "2026-03-03", Repaired: true},
+		{ID: 2, Day: "2026-03-04", Streak: 1},
+	}, "2026-03-03")
+	if len(changed) != 2 || changed[0].Streak != 1 || changed[1].Streak != 2 {
+		t.Errorf("recalcStreaks() = %v", changed)
+	}
+}
@@ -2,27 +2,48 @@
 package service
 
 import (
+	"bytes"
 	"crypto/rand"
+	"encoding/base64"
+	"encoding/csv"
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"gorm.io/gorm"
 )
 
 var (
-	ErrCodeNotFound      = errors.New("注册码不存在")
-	ErrCodeAlreadyUsed   = errors.New("注册码已被使用")
-	ErrCodeInvalid       = errors.New("无效的注册码")
-	ErrAlreadyHasAccount = errors.New("您已有账户")
-	ErrExchangeDisabled  = errors.New("兑换功能已关闭")
+	ErrCodeNotFound       = errors.New("注册码不存在")
+	ErrCodeAlreadyUsed    = errors.New("注册码已被使用")
+	ErrCodeInvalid        = errors.New("无效的注册码")
+	ErrCodeExpired        = errors.New("注册码已过期")
+	ErrCodeRevoked        = errors.New("注册码已作废")
+	ErrCodeRedeemedBefore = errors.New("您已使用过该注册码")
+	ErrAlreadyHasAccount  = errors.New("您已有账户")
+	ErrExchangeDisabled   = errors.New("兑换功能已关闭")
+	ErrBatchNotFound      = errors.New("批次不存在")
+	ErrBatchRevoked       = errors.New("批次已作废")
 )
 
+// 批次限制
+const (
+	MaxCodeBatchSize = 10000 // 单批最多生成的注册码数
+	MaxCodeUses      = 10000 // 单个注册码最多可使用次数
+	maxBatchLabelLen = 50    // 批次标签最大长度
+)
+
+// defaultCodeLevel 注册码未指定等级时新账户的等级
+const defaultCodeLevel = models.LevelB
+
 // CodeService 注册码服务
 type CodeService struct {
 	codeRepo *repository.CodeRepository
@@ -39,53 +60,179 @@ func NewCodeService() *CodeService {
 	}
 }
 
+// CodeBatchOptions 批次生成参数
+type CodeBatchOptions struct {
+	CreatedBy int64
+	Days      int
+	Count     int
+	Label     string     // 批次标签（活动名等）
+	MaxUses   int        // 每个注册码可使用次数，<= 0 按 1 次
+	Level     string     // 目标等级（a/b/c/d），为空时按默认等级
+	ExpiresAt *time.Time // 注册码失效时间，为空表示不过期
+}
+
+// Validate 校验并规范化参数
+func (o *CodeBatchOptions) Validate(now time.Time) error {
+	if o.Count <= 0 || o.Count > MaxCodeBatchSize {
+		return fmt.Errorf("生成数量应在 1-%d 之间", MaxCodeBatchSize)
+	}
+	if o.Days <= 0 {
+		return errors.New("有效天数必须大于 0")
+	}
+	if o.MaxUses <= 0 {
+		o.MaxUses = 1
+	}
+	if o.MaxUses > MaxCodeUses {
+		return fmt.Errorf("可使用次数应在 1-%d 之间", MaxCodeUses)
+	}
+	o.Level = strings.ToLower(o.Level)
+	switch models.UserLevel(o.Level) {
+	case "", models.LevelA, models.LevelB, models.LevelC, models.LevelD:
+	default:
+		return fmt.Errorf("无效的目标等级 %q（可选 a/b/c/d）", o.Level)
+	}
+	if o.ExpiresAt != nil && !o.ExpiresAt.After(now) {
+		return errors.New("失效时间必须晚于当前时间")
+	}
+	o.Label = strings.TrimSpace(o.Label)
+	if len([]rune(o.Label)) > maxBatchLabelLen {
+		return fmt.Errorf("批次标签不能超过 %d 个字符", maxBatchLabelLen)
+	}
+	return nil
+}
+
+// ParseCodeOptions 解析 /code 的附加参数：label=标签 uses=次数 lv=等级 expire=天数或日期(YYYY-MM-DD)
+func ParseCodeOptions(args []string, now time.Time) (CodeBatchOptions, error) {
+	var opts CodeBatchOptions
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return opts, fmt.Errorf("无效的参数 %q", arg)
+		}
+		switch strings.ToLower(key) {
+		case "label":
+			opts.Label = strings.ReplaceAll(value, "_", " ")
+		case "uses":
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				return opts, fmt.Errorf("无效的可使用次数 %q", value)
+			}
+			opts.MaxUses = n
+		case "lv":
+			opts.Level = value
+		case "expire":
+			expiresAt, err := parseCodeExpiry(value, now)
+			if err != nil {
+				return opts, err
+			}
+			opts.ExpiresAt = &expiresAt
+		default:
+			return opts, fmt.Errorf("未知的参数 %q", key)
+		}
+	}
+	return opts, nil
+}
+
+// parseCodeExpiry 解析失效时间：天数（从现在起）或日期（当天结束时失效）
+func parseCodeExpiry(value string, now time.Time) (time.Time, error) {
+	if days, err := strconv.Atoi(value); err == nil {
+		if days <= 0 {
+			return time.Time{}, fmt.Errorf("无效的失效天数 %q", value)
+		}
+		return now.AddDate(0, 0, days), nil
+	}
+	date, err := time.ParseInLocation("2006-01-02", value, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("无效的失效时间 %q（天数或 YYYY-MM-DD）", value)
+	}
+	return date.AddDate(0, 0, 1), nil
+}
+
 // GenerateResult 生成结果
 type GenerateResult struct {
-	Codes    []string
-	Count    int
-	Days     int
-	CreateBy int64
+	Codes     []string
+	Count     int
+	Days      int
+	CreateBy  int64
+	BatchID   uint
+	Label     string
+	MaxUses   int
+	Level     string
+	ExpiresAt *time.Time
 }
 
-// GenerateCodes 生成注册码
+// GenerateCodes 生成注册码（单次使用、不过期、默认等级）
 func (s *CodeService) GenerateCodes(createBy int64, days int, count int) (*GenerateResult, error) {
-	if count <= 0 || count > 100 {
-		return nil, errors.New("生成数量应在 1-100 之间")
-	}
+	return s.GenerateBatch(CodeBatchOptions{CreatedBy: createBy, Days: days, Count: count})
+}
 
-	if days <= 0 {
-		return nil, errors.New("有效天数必须大于 0")
+// GenerateBatch 按批次生成注册码
+func (s *CodeService) GenerateBatch(opts CodeBatchOptions) (*GenerateResult, error) {
+	now := time.Now()
+	if err := opts.Validate(now); err != nil {
+		return nil, err
 	}
 
 	// 生成注册码
-	codes := make([]string, 0, count)
-	prefix := s.cfg.Ranks.Logo
-	if prefix == "" {
-		prefix = "SAKURA"
-	}
-
-	for i := 0; i < count; i++ {
+	prefix := CodePrefix()
+	codes := make([]string, 0, opts.Count)
+	rows := make([]models.Code, 0, opts.Count)
+	seen := make(map[string]bool, opts.Count)
+	for len(codes) < opts.Count {
 		code := s.generateCodeString(prefix)
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
 		codes = append(codes, code)
+		rows = append(rows, models.Code{
+			Code:      code,
+			TG:        opts.CreatedBy,
+			Us:        opts.Days,
+			MaxUses:   opts.MaxUses,
+			Level:     opts.Level,
+			ExpiresAt: opts.ExpiresAt,
+			CreatedAt: &now,
+		})
+	}
+
+	batch := &models.CodeBatch{
+		Label:     opts.Label,
+		TG:        opts.CreatedBy,
+		Days:      opts.Days,
+		Count:     opts.Count,
+		MaxUses:   opts.MaxUses,
+		Level:     opts.Level,
+		ExpiresAt: opts.ExpiresAt,
+		CreatedAt: now,
 	}
 
 	// 批量保存到数据库
-	if err := s.codeRepo.BatchCreate(codes, createBy, days); err != nil {
-		logger.Error().Err(err).Int64("createBy", createBy).Msg("保存注册码失败")
+	if err := s.codeRepo.CreateBatch(batch, rows); err != nil {
+		logger.Error().Err(err).Int64("createBy", opts.CreatedBy).Msg("保存注册码失败")
 		return nil, fmt.Errorf("保存注册码失败: %w", err)
 	}
 
 	logger.Info().
-		Int64("createBy", createBy).
-		Int("count", count).
-		Int("days", days).
+		Int64("createBy", opts.CreatedBy).
+		Uint("batch", batch.ID).
+		Str("label", opts.Label).
+		Int("count", opts.Count).
+		Int("days", opts.Days).
+		Int("maxUses", opts.MaxUses).
+		Str("lv", opts.Level).
 		Msg("成功生成注册码")
 
 	return &GenerateResult{
-		Codes:    codes,
-		Count:    count,
-		Days:     days,
-		CreateBy: createBy,
+		Codes:     codes,
+		Count:     opts.Count,
+		Days:      opts.Days,
+		CreateBy:  opts.CreatedBy,
+		BatchID:   batch.ID,
+		Label:     opts.Label,
+		MaxUses:   opts.MaxUses,
+		Level:     opts.Level,
+		ExpiresAt: opts.ExpiresAt,
 	}, nil
 }
 
@@ -97,31 +244,149 @@ func (s *CodeService) generateCodeString(prefix string) string {
 	return fmt.Sprintf("%s-%s", prefix, randomPart[:12])
 }
 
+// CodePrefix 注册码前缀（ranks.logo，未配置时为 SAKURA）
+func CodePrefix() string {
+	if logo := config.Get().Ranks.Logo; logo != "" {
+		return logo
+	}
+	return "SAKURA"
+}
+
+// codeArgPrefix 编码后的注册码 /start 参数前缀
+const codeArgPrefix = "code_"
+
+// CodeDeepLink 注册码的 Bot 深链接，打开后自动进入使用流程
+func CodeDeepLink(botName, code string) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s", botName, CodeStartArg(code))
+}
+
+// CodeStartArg 注册码的 /start 参数
+// Telegram 只允许 [A-Za-z0-9_-]，前缀（ranks.logo）含其他字符时改用 base64url 编码
+func CodeStartArg(code string) string {
+	if isStartArgSafe(code) {
+		return code
+	}
+	return codeArgPrefix + base64.RawURLEncoding.EncodeToString([]byte(code))
+}
+
+// ParseCodeStartArg 解析 CodeStartArg 编码的注册码，未编码的参数返回 false
+func ParseCodeStartArg(arg string) (string, bool) {
+	raw, ok := strings.CutPrefix(arg, codeArgPrefix)
+	if !ok {
+		return "", false
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || !strings.Contains(string(decoded), "-") {
+		return "", false
+	}
+	return string(decoded), true
+}
+
+// isStartArgSafe 判断字符串能否直接作为 /start 参数
+func isStartArgSafe(s string) bool {
+	for _, r := range s {
+		switch {
+		case r >= 'A' && r <= 'Z', r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '_', r == '-':
+		default:
+			return false
+		}
+	}
+	return s != ""
+}
+
 // GenerateCode 生成单个注册码字符串（公开方法）
 func GenerateCode() string {
-	cfg := config.Get()
-	prefix := cfg.Ranks.Logo
-	if prefix == "" {
-		prefix = "SAKURA"
-	}
+	prefix := CodePrefix()
 	bytes := make([]byte, 8)
 	rand.Read(bytes)
 	randomPart := strings.ToUpper(hex.EncodeToString(bytes))
 	return fmt.Sprintf("%s-%s", prefix, randomPart[:12])
 }
 
+// CheckCode 检查注册码当前是否可用
+func CheckCode(code *models.Code, now time.Time) error {
+	switch {
+	case code.IsRevoked():
+		return ErrCodeRevoked
+	case code.IsExpired(now):
+		return ErrCodeExpired
+	case code.IsUsed():
+		return ErrCodeAlreadyUsed
+	}
+	return nil
+}
+
+// redeem 校验并占用一次注册码，后续步骤失败时需调用 release 归还
+func (s *CodeService) redeem(codeStr string, tgID int64) (*models.Code, error) {
+	code, err := s.codeRepo.GetByCode(codeStr)
+	if err != nil {
+		return nil, ErrCodeNotFound
+	}
+	now := time.Now()
+	if err := CheckCode(code, now); err != nil {
+		return nil, err
+	}
+
+	if err := s.codeRepo.Redeem(codeStr, tgID, now); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrCodeRedeemed):
+			return nil, ErrCodeRedeemedBefore
+		case errors.Is(err, repository.ErrCodeUnavailable):
+			// 并发使用或状态刚刚变化，重新读取以返回具体原因
+			if latest, getErr := s.codeRepo.GetByCode(codeStr); getErr == nil {
+				if checkErr := CheckCode(latest, now); checkErr != nil {
+					return nil, checkErr
+				}
+			}
+			return nil, ErrCodeAlreadyUsed
+		default:
+			return nil, fmt.Errorf("处理注册码失败: %w", err)
+		}
+	}
+	return code, nil
+}
+
+// release 归还占用的注册码
+func (s *CodeService) release(codeStr string, tgID int64) {
+	if err := s.codeRepo.Unredeem(codeStr, tgID); err != nil {
+		logger.Error().Err(err).Str("code", codeStr).Int64("tg", tgID).Msg("归还注册码失败")
+	}
+}
+
+// codeLevel 使用注册码创建账户时的等级
+func codeLevel(code *models.Code) models.UserLevel {
+	if code.Level == "" {
+		return defaultCodeLevel
+	}
+	return models.UserLevel(code.Level)
+}
+
+// upgradesLevel 续期码的目标等级是否高于用户当前等级（a 最高，封禁用户不变）
+func upgradesLevel(current models.UserLevel, target string) bool {
+	if target == "" || current == models.LevelE {
+		return false
+	}
+	return target < string(current)
+}
+
 // UseCodeResult 使用注册码结果
 type UseCodeResult struct {
 	Success    bool
-	UserID     string    // Emby 用户 ID
-	Username   string    // Emby 用户名
-	Password   string    // Emby 密码
-	ExpiryDate time.Time // 到期时间
-	Days       int       // 获得的天数
+	UserID     string           // Emby 用户 ID
+	Username   string           // Emby 用户名
+	Password   string           // Emby 密码
+	ExpiryDate time.Time        // 到期时间
+	Days       int              // 获得的天数
+	Level      models.UserLevel // 账户等级
 }
 
 // UseCode 使用注册码
 func (s *CodeService) UseCode(tgID int64, username string, codeStr string) (*UseCodeResult, error) {
+	return s.createByCode(tgID, username, codeStr, nil)
+}
+
+// createByCode 使用注册码创建账户，extra 为额外写入用户记录的字段
+func (s *CodeService) createByCode(tgID int64, username string, codeStr string, extra map[string]interface{}) (*UseCodeResult, error) {
 	// 检查兑换功能是否开启
 	if !s.cfg.Open.Exchange {
 		return nil, ErrExchangeDisabled
@@ -133,50 +398,63 @@ func (s *CodeService) UseCode(tgID int64, username string, codeStr string) (*Use
 		return nil, ErrAlreadyHasAccount
 	}
 
-	// 查找注册码
-	code, err := s.codeRepo.GetByCode(codeStr)
+	// 占用注册码，创建失败时归还
+	code, err := s.redeem(codeStr, tgID)
 	if err != nil {
-		return nil, ErrCodeNotFound
-	}
-
-	// 检查注册码是否已使用
-	if code.IsUsed() {
-		return nil, ErrCodeAlreadyUsed
+		return nil, err
 	}
 
 	// 创建 Emby 账户
 	embyClient := emby.GetClient()
 	createResult, err := embyClient.CreateUser(username, code.Us)
 	if err != nil {
+		s.release(codeStr, tgID)
 		logger.Error().Err(err).Int64("tg", tgID).Str("code", codeStr).Msg("使用注册码创建账户失败")
 		return nil, fmt.Errorf("创建账户失败: %w", err)
 	}
 
-	// 标记注册码已使用
-	if err := s.codeRepo.MarkUsed(codeStr, tgID); err != nil {
-		logger.Error().Err(err).Str("code", codeStr).Msg("标记注册码失败")
-		// 注意：这里账户已创建，但标记失败，可能需要清理
+	// 账户创建成功后，使用他人购买的邀请码时记录邀请关系（管理员生成的注册码不计）
+	// 须在写入账户信息前绑定，否则被邀请人会被视为已注册过
+	referrals := NewReferralService()
+	if code.TG != tgID && !s.cfg.IsAdmin(code.TG) {
+		if err := referrals.Bind(code.TG, tgID, models.ReferralSourceCode); err != nil {
+			logger.Debug().Err(err).Int64("tg", tgID).Int64("inviter", code.TG).Msg("未记录邀请码邀请关系")
+		}
 	}
 
 	// 更新用户数据库记录
+	level := codeLevel(code)
 	updates := map[string]interface{}{
 		"embyid": createResult.UserID,
 		"name":   username,
 		"pwd":    createResult.Password,
 		"ex":     createResult.ExpiryDate,
 		"cr":     time.Now(),
+		"lv":     level,
+	}
+	for k, v := range extra {
+		updates[k] = v
 	}
 
 	// 确保用户存在
 	s.embyRepo.EnsureExists(tgID)
-	if err := s.embyRepo.UpdateFields(tgID, updates); err != nil {
+	if err := s.embyRepo.BindAccount(tgID, updates); err != nil {
 		logger.Error().Err(err).Int64("tg", tgID).Msg("更新用户记录失败")
 	}
+	SyncServers(tgID)
+	referrals.OnRegistered(tgID)
+	if level != defaultCodeLevel {
+		if err := NewStreamLimitService().ApplyByTG(tgID); err != nil {
+			logger.Warn().Err(err).Int64("tg", tgID).Msg("同步并发播放限制失败")
+		}
+	}
 
 	logger.Info().
 		Int64("tg", tgID).
 		Str("code", codeStr).
 		Str("embyID", createResult.UserID).
+		Str("username", username).
+		Str("lv", string(level)).
 		Msg("用户使用注册码成功")
 
 	return &UseCodeResult{
@@ -186,10 +464,11 @@ func (s *CodeService) UseCode(tgID int64, username string, codeStr string) (*Use
 		Password:   createResult.Password,
 		ExpiryDate: createResult.ExpiryDate,
 		Days:       code.Us,
+		Level:      level,
 	}, nil
 }
 
-// ExtendByCode 使用注册码续期（已有账户）
+// ExtendByCode 使用注册码续期（已有账户），目标等级高于当前等级时同时升级
 func (s *CodeService) ExtendByCode(tgID int64, codeStr string) (int, error) {
 	// 检查用户是否有账户
 	user, err := s.embyRepo.GetByTG(tgID)
@@ -197,15 +476,10 @@ func (s *CodeService) ExtendByCode(tgID int64, codeStr string) (int, error) {
 		return 0, errors.New("您还没有账户，请先注册")
 	}
 
-	// 查找注册码
-	code, err := s.codeRepo.GetByCode(codeStr)
+	// 占用注册码，续期失败时归还
+	code, err := s.redeem(codeStr, tgID)
 	if err != nil {
-		return 0, ErrCodeNotFound
-	}
-
-	// 检查注册码是否已使用
-	if code.IsUsed() {
-		return 0, ErrCodeAlreadyUsed
+		return 0, err
 	}
 
 	// 计算新的到期时间
@@ -222,22 +496,33 @@ func (s *CodeService) ExtendByCode(tgID int64, codeStr string) (int, error) {
 		newExpiry = time.Now().AddDate(0, 0, code.Us)
 	}
 
-	// 标记注册码已使用
-	if err := s.codeRepo.MarkUsed(codeStr, tgID); err != nil {
-		logger.Error().Err(err).Str("code", codeStr).Msg("标记注册码失败")
-		return 0, fmt.Errorf("处理注册码失败: %w", err)
+	// 更新用户到期时间与等级
+	updates := map[string]interface{}{"ex": newExpiry}
+	upgraded := upgradesLevel(user.Lv, code.Level)
+	if upgraded {
+		updates["lv"] = code.Level
 	}
-
-	// 更新用户到期时间
-	if err := s.embyRepo.UpdateFields(tgID, map[string]interface{}{"ex": newExpiry}); err != nil {
+	if err := s.embyRepo.UpdateFields(tgID, updates); err != nil {
+		s.release(codeStr, tgID)
 		logger.Error().Err(err).Int64("tg", tgID).Msg("更新到期时间失败")
 		return 0, fmt.Errorf("更新到期时间失败: %w", err)
 	}
 
+	// 过期阶段中的账户恢复访问，结算邀请续期奖励
+	if err := NewExpiryService().Renewed(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("恢复续期用户失败")
+	}
+	if upgraded {
+		if err := NewStreamLimitService().ApplyByTG(tgID); err != nil {
+			logger.Warn().Err(err).Int64("tg", tgID).Msg("同步并发播放限制失败")
+		}
+	}
+
 	logger.Info().
 		Int64("tg", tgID).
 		Str("code", codeStr).
 		Int("days", code.Us).
+		Bool("upgraded", upgraded).
 		Time("newExpiry", newExpiry).
 		Msg("用户使用注册码续期成功")
 
@@ -256,8 +541,8 @@ func (s *CodeService) ValidateCode(codeStr string) (days int, err error) {
 		return 0, ErrCodeNotFound
 	}
 
-	if code.IsUsed() {
-		return 0, ErrCodeAlreadyUsed
+	if err := CheckCode(code, time.Now()); err != nil {
+		return 0, err
 	}
 
 	return code.Us, nil
@@ -273,71 +558,142 @@ func (s *CodeService) DeleteUnusedCodes(days []int, tgID *int64) (int64, error)
 
 // UseCodeWithSecurity 使用注册码（带安全码）
 func (s *CodeService) UseCodeWithSecurity(tgID int64, username string, codeStr string, securityCode string) (*UseCodeResult, error) {
-	// 检查兑换功能是否开启
-	if !s.cfg.Open.Exchange {
-		return nil, ErrExchangeDisabled
+	return s.createByCode(tgID, username, codeStr, map[string]interface{}{"pwd2": securityCode})
+}
+
+// ListBatches 分页获取批次列表
+func (s *CodeService) ListBatches(page, pageSize int) ([]repository.CodeBatchInfo, int64, error) {
+	return s.codeRepo.ListBatches(page, pageSize)
+}
+
+// GetBatch 获取批次
+func (s *CodeService) GetBatch(id uint) (*models.CodeBatch, error) {
+	batch, err := s.codeRepo.GetBatch(id)
+	if err != nil {
+		return nil, ErrBatchNotFound
 	}
+	return batch, nil
+}
 
-	// 检查用户是否已有账户
-	user, err := s.embyRepo.GetByTG(tgID)
-	if err == nil && user.HasEmbyAccount() {
-		return nil, ErrAlreadyHasAccount
+// RevokeBatch 作废整个批次，返回作废的注册码数量
+func (s *CodeService) RevokeBatch(id uint, operator int64) (int64, error) {
+	batch, err := s.GetBatch(id)
+	if err != nil {
+		return 0, err
+	}
+	if batch.RevokedAt != nil {
+		return 0, ErrBatchRevoked
 	}
 
-	// 查找注册码
-	code, err := s.codeRepo.GetByCode(codeStr)
+	count, err := s.codeRepo.RevokeBatch(id, time.Now())
 	if err != nil {
-		return nil, ErrCodeNotFound
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, ErrBatchRevoked
+		}
+		return 0, fmt.Errorf("作废批次失败: %w", err)
 	}
 
-	// 检查注册码是否已使用
-	if code.IsUsed() {
-		return nil, ErrCodeAlreadyUsed
+	logger.Info().Uint("batch", id).Int64("operator", operator).Int64("codes", count).Msg("已作废注册码批次")
+	return count, nil
+}
+
+// 导出格式
+const (
+	CodeExportCSV = "csv"
+	CodeExportTXT = "txt"
+)
+
+// BatchCodes 获取批次及其全部注册码
+func (s *CodeService) BatchCodes(id uint) (*models.CodeBatch, []models.Code, error) {
+	batch, err := s.GetBatch(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	codes, err := s.codeRepo.GetByBatch(id)
+	if err != nil {
+		return nil, nil, fmt.Errorf("获取注册码失败: %w", err)
 	}
+	return batch, codes, nil
+}
 
-	// 创建 Emby 账户
-	embyClient := emby.GetClient()
-	createResult, err := embyClient.CreateUser(username, code.Us)
+// ExportBatch 导出批次内的注册码（csv 或 txt）
+func (s *CodeService) ExportBatch(id uint, format string) (*models.CodeBatch, []byte, error) {
+	batch, codes, err := s.BatchCodes(id)
 	if err != nil {
-		logger.Error().Err(err).Int64("tg", tgID).Str("code", codeStr).Msg("使用注册码创建账户失败")
-		return nil, fmt.Errorf("创建账户失败: %w", err)
+		return nil, nil, err
 	}
 
-	// 标记注册码已使用
-	if err := s.codeRepo.MarkUsed(codeStr, tgID); err != nil {
-		logger.Error().Err(err).Str("code", codeStr).Msg("标记注册码失败")
+	var data []byte
+	switch format {
+	case CodeExportTXT:
+		data = CodesTXT(codes)
+	case CodeExportCSV:
+		data, err = CodesCSV(codes, time.Now())
+	default:
+		return nil, nil, fmt.Errorf("不支持的导出格式 %q", format)
 	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return batch, data, nil
+}
 
-	// 更新用户数据库记录（包含安全码）
-	updates := map[string]interface{}{
-		"embyid": createResult.UserID,
-		"name":   username,
-		"pwd":    createResult.Password,
-		"pwd2":   securityCode, // 安全码
-		"ex":     createResult.ExpiryDate,
-		"cr":     time.Now(),
-		"lv":     "b", // 普通用户
+// CodeStatusText 注册码状态文本
+func CodeStatusText(code *models.Code, now time.Time) string {
+	switch {
+	case code.IsRevoked():
+		return "已作废"
+	case code.IsExpired(now):
+		return "已过期"
+	case code.IsUsed():
+		return "已用完"
+	default:
+		return "可用"
 	}
+}
 
-	// 确保用户存在
-	s.embyRepo.EnsureExists(tgID)
-	if err := s.embyRepo.UpdateFields(tgID, updates); err != nil {
-		logger.Error().Err(err).Int64("tg", tgID).Msg("更新用户记录失败")
+// CodesCSV 生成注册码 CSV（含 UTF-8 BOM，便于 Excel 打开）
+func CodesCSV(codes []models.Code, now time.Time) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\uFEFF")
+	w := csv.NewWriter(&buf)
+	w.Write([]string{"code", "days", "level", "max_uses", "use_count", "expires_at", "status", "last_used_by", "last_used_at"})
+	for i := range codes {
+		c := &codes[i]
+		row := []string{
+			c.Code,
+			strconv.Itoa(c.Us),
+			string(codeLevel(c)),
+			strconv.Itoa(max(c.MaxUses, 1)),
+			strconv.Itoa(c.UseCount),
+			formatOptionalTime(c.ExpiresAt),
+			CodeStatusText(c, now),
+			"",
+			formatOptionalTime(c.UsedTime),
+		}
+		if c.Used != nil {
+			row[7] = strconv.FormatInt(*c.Used, 10)
+		}
+		w.Write(row)
 	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
 
-	logger.Info().
-		Int64("tg", tgID).
-		Str("code", codeStr).
-		Str("embyID", createResult.UserID).
-		Str("username", username).
-		Msg("用户使用注册码成功（带安全码）")
+// CodesTXT 生成注册码 TXT，每行一个
+func CodesTXT(codes []models.Code) []byte {
+	var buf bytes.Buffer
+	for _, c := range codes {
+		buf.WriteString(c.Code)
+		buf.WriteString("\n")
+	}
+	return buf.Bytes()
+}
 
-	return &UseCodeResult{
-		Success:    true,
-		UserID:     createResult.UserID,
-		Username:   username,
-		Password:   createResult.Password,
-		ExpiryDate: createResult.ExpiryDate,
-		Days:       code.Us,
-	}, nil
+// formatOptionalTime 格式化可为空的时间
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format("2006-01-02 15:04:05")
 }
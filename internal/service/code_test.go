@@ -0,0 +1,206 @@
+// Package service 注册码服务测试
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestCodeBatchOptions_Validate(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name    string
+		opts    CodeBatchOptions
+		wantErr bool
+	}{
+		{"默认参数", CodeBatchOptions{Days: 30, Count: 1}, false},
+		{"大批量", CodeBatchOptions{Days: 30, Count: MaxCodeBatchSize}, false},
+		{"超过批量上限", CodeBatchOptions{Days: 30, Count: MaxCodeBatchSize + 1}, true},
+		{"数量为 0", CodeBatchOptions{Days: 30}, true},
+		{"天数为 0", CodeBatchOptions{Count: 1}, true},
+		{"次数超限", CodeBatchOptions{Days: 30, Count: 1, MaxUses: MaxCodeUses + 1}, true},
+		{"大写等级", CodeBatchOptions{Days: 30, Count: 1, Level: "A"}, false},
+		{"无效等级", CodeBatchOptions{Days: 30, Count: 1, Level: "e"}, true},
+		{"失效时间已过", CodeBatchOptions{Days: 30, Count: 1, ExpiresAt: &past}, true},
+		{"失效时间在未来", CodeBatchOptions{Days: 30, Count: 1, ExpiresAt: &future}, false},
+		{"标签过长", CodeBatchOptions{Days: 30, Count: 1, Label: strings.Repeat("活", maxBatchLabelLen+1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.Validate(now)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	opts := CodeBatchOptions{Days: 30, Count: 1, Level: "A", Label: "  春节  "}
+	if err := opts.Validate(now); err != nil {
+		t.Fatal(err)
+	}
+	if opts.MaxUses != 1 || opts.Level != "a" || opts.Label != "春节" {
+		t.Errorf("规范化结果错误: %+v", opts)
+	}
+}
+
+func TestParseCodeOptions(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+
+	opts, err := ParseCodeOptions([]string{"label=春节_活动", "uses=5", "lv=a", "expire=7"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Label != "春节 活动" || opts.MaxUses != 5 || opts.Level != "a" {
+		t.Errorf("解析结果错误: %+v", opts)
+	}
+	if opts.ExpiresAt == nil || !opts.ExpiresAt.Equal(now.AddDate(0, 0, 7)) {
+		t.Errorf("ExpiresAt = %v", opts.ExpiresAt)
+	}
+
+	opts, err = ParseCodeOptions([]string{"expire=2024-12-31"}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local); !opts.ExpiresAt.Equal(want) {
+		t.Errorf("日期应在当天结束时失效，实际 %v", opts.ExpiresAt)
+	}
+
+	for _, args := range [][]string{{"foo=1"}, {"uses=0"}, {"uses=x"}, {"expire=0"}, {"expire=31/12"}, {"label="}, {"abc"}} {
+		if _, err := ParseCodeOptions(args, now); err == nil {
+			t.Errorf("ParseCodeOptions(%v) 应返回错误", args)
+		}
+	}
+}
+
+func TestCheckCode(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	past := now.Add(-time.Hour)
+	tg := int64(1)
+
+	tests := []struct {
+		name string
+		code models.Code
+		want error
+	}{
+		{"可用", models.Code{MaxUses: 1}, nil},
+		{"已使用", models.Code{MaxUses: 1, Used: &tg}, ErrCodeAlreadyUsed},
+		{"多次码仍可用", models.Code{MaxUses: 2, UseCount: 1, Used: &tg}, nil},
+		{"已过期", models.Code{MaxUses: 1, ExpiresAt: &past}, ErrCodeExpired},
+		{"已作废优先", models.Code{MaxUses: 1, Used: &tg, ExpiresAt: &past, RevokedAt: &past}, ErrCodeRevoked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CheckCode(&tt.code, now); !errors.Is(got, tt.want) {
+				t.Errorf("CheckCode() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpgradesLevel(t *testing.T) {
+	tests := []struct {
+		current  models.UserLevel
+		target   string
+		expected bool
+	}{
+		{models.LevelB, "a", true},
+		{models.LevelD, "b", true},
+		{models.LevelA, "b", false},
+		{models.LevelB, "b", false},
+		{models.LevelB, "", false},
+		{models.LevelE, "a", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.current)+"->"+tt.target, func(t *testing.T) {
+			if got := upgradesLevel(tt.current, tt.target); got != tt.expected {
+				t.Errorf("upgradesLevel(%q, %q) = %v, want %v", tt.current, tt.target, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestCodesCSV(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+	usedAt := now.Add(-time.Hour)
+	tg := int64(42)
+
+	data, err := CodesCSV([]models.Code{
+		{Code: "SAKURA-AAA", Us: 30, MaxUses: 1},
+		{Code: "SAKURA-BBB", Us: 90, MaxUses: 3, UseCount: 3, Level: "a", Used: &tg, UsedTime: &usedAt},
+	}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := string(data)
+	if !strings.HasPrefix(text, "\uFEFFcode,days,level") {
+		t.Errorf("缺少 BOM 或表头:\n%s", text)
+	}
+	lines := strings.Split(strings.TrimSpace(text), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("行数 = %d, want 3", len(lines))
+	}
+	if want := "SAKURA-AAA,30,b,1,0,,可用,,"; lines[1] != want {
+		t.Errorf("第 1 行 = %q, want %q", lines[1], want)
+	}
+	if want := "SAKURA-BBB,90,a,3,3,,已用完,42,2024-06-01 11:00:00"; lines[2] != want {
+		t.Errorf("第 2 行 = %q, want %q", lines[2], want)
+	}
+}
+
+func TestCodesTXT(t *testing.T) {
+	got := string(CodesTXT([]models.Code{{Code: "SAKURA-AAA"}, {Code: "SAKURA-BBB"}}))
+	if want := "SAKURA-AAA\nSAKURA-BBB\n"; got != want {
+		t.Errorf("CodesTXT() = %q, want %q", got, want)
+	}
+	if got := CodesTXT(nil); len(got) != 0 {
+		t.Errorf("空列表应返回空内容，实际 %q", got)
+	}
+}
+
+func TestCodeDeepLink(t *testing.T) {
+	if got, want := CodeDeepLink("sakura_bot", "SAKURA-ABC"), "https://t.me/sakura_bot?start=SAKURA-ABC"; got != want {
+		t.Errorf("CodeDeepLink() = %q, want %q", got, want)
+	}
+}
+
+func TestCodeStartArg(t *testing.T) {
+	tests := []struct {
+		name    string
+		code    string
+		encoded bool
+	}{
+		{"ASCII 前缀原样使用", "SAKURA-ABC123", false},
+		{"中文前缀", "樱花-ABC123", true},
+		{"含空格的前缀", "My Emby-ABC123", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			arg := CodeStartArg(tt.code)
+			if !isStartArgSafe(arg) {
+				t.Errorf("CodeStartArg(%q) = %q 含 Telegram 不允许的字符", tt.code, arg)
+			}
+			decoded, ok := ParseCodeStartArg(arg)
+			if ok != tt.encoded {
+				t.Fatalf("ParseCodeStartArg(%q) ok = %v, want %v", arg, ok, tt.encoded)
+			}
+			if ok && decoded != tt.code {
+				t.Errorf("ParseCodeStartArg(%q) = %q, want %q", arg, decoded, tt.code)
+			}
+			if !tt.encoded && arg != tt.code {
+				t.Errorf("CodeStartArg(%q) = %q, want unchanged", tt.code, arg)
+			}
+		})
+	}
+}
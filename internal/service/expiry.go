@@ -3,23 +3,24 @@ package service
 
 import (
 	"fmt"
+	"math"
 	"time"
 
-	tele "gopkg.in/telebot.v3"
-
 	"github.com/smysle/sakura-embyboss-go/internal/config"
 	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
 // ExpiryService 到期检测服务
 type ExpiryService struct {
-	embyRepo   *repository.EmbyRepository
-	embyClient *emby.Client
-	cfg        *config.Config
-	bot        *tele.Bot
+	embyRepo    *repository.EmbyRepository
+	warningRepo *repository.ExpiryWarningRepository
+	embyClient  *emby.Client
+	cfg         *config.Config
+	notifier    notify.Sender
 }
 
 // ExpiryResult 检测结果
@@ -27,26 +28,29 @@ type ExpiryResult struct {
 	Checked      int      // 检测的用户数
 	Expired      int      // 已过期用户数
 	Disabled     int      // 成功禁用数
-	Failed       int      // 禁用失败数
+	Failed       int      // 处理失败数
 	WarningSent  int      // 发送预警数
 	ExpiredUsers []string // 过期用户列表
+
+	Grace    int // 进入宽限期数
+	Frozen   int // 封存数
+	Deleted  int // 删除数
+	Restored int // 续期恢复数
 }
 
 // NewExpiryService 创建到期检测服务
 func NewExpiryService() *ExpiryService {
 	return &ExpiryService{
-		embyRepo:   repository.NewEmbyRepository(),
-		embyClient: emby.GetClient(),
-		cfg:        config.Get(),
+		embyRepo:    repository.NewEmbyRepository(),
+		warningRepo: repository.NewExpiryWarningRepository(),
+		embyClient:  emby.GetClient(),
+		cfg:         config.Get(),
+		notifier:    notify.Get(),
 	}
 }
 
-// SetBot 设置 Bot 实例（用于发送通知）
-func (s *ExpiryService) SetBot(bot *tele.Bot) {
-	s.bot = bot
-}
-
-// CheckExpired 检测并处理过期用户
+// CheckExpired 检测过期用户并推进生命周期
+// 正常 → 宽限期 → 禁用 → 封存 → 删除，已续期的账户恢复为正常
 func (s *ExpiryService) CheckExpired() (*ExpiryResult, error) {
 	result := &ExpiryResult{
 		ExpiredUsers: make([]string, 0),
@@ -61,19 +65,32 @@ func (s *ExpiryService) CheckExpired() (*ExpiryResult, error) {
 	result.Checked = len(users)
 	now := time.Now()
 
-	for _, user := range users {
-		// 跳过白名单用户
-		if user.Lv == models.LevelA {
+	for i := range users {
+		user := &users[i]
+
+		// 跳过白名单及封禁用户（封禁由管理员处理，不参与生命周期）
+		if user.Lv == models.LevelA || user.IsBanned() {
 			continue
 		}
 
-		// 检查是否过期
 		if user.Ex == nil {
 			continue
 		}
 
+		// 已续期但状态未恢复（如其他入口续期）
 		if user.Ex.After(now) {
-			continue // 未过期
+			if restored, err := s.restore(user); err != nil {
+				logger.Warn().Err(err).Int64("tg", user.TG).Msg("恢复续期用户失败")
+				result.Failed++
+			} else if restored {
+				result.Restored++
+			}
+			continue
+		}
+
+		target := LifecycleStatusAt(user, now, s.cfg.Lifecycle, s.cfg.FreezeDays)
+		if lifecycleOrder[target] <= lifecycleOrder[user.CurrentStatus()] {
+			continue
 		}
 
 		result.Expired++
@@ -85,42 +102,26 @@ func (s *ExpiryService) CheckExpired() (*ExpiryResult, error) {
 		}
 		result.ExpiredUsers = append(result.ExpiredUsers, username)
 
-		// 禁用 Emby 账户
-		if user.EmbyID != nil && *user.EmbyID != "" {
-			if err := s.embyClient.DisableUser(*user.EmbyID); err != nil {
-				logger.Warn().
-					Err(err).
-					Int64("tg", user.TG).
-					Str("emby_id", *user.EmbyID).
-					Msg("禁用过期用户失败")
-				result.Failed++
-			} else {
-				result.Disabled++
-				logger.Info().
-					Int64("tg", user.TG).
-					Str("username", username).
-					Msg("已禁用过期用户")
-			}
+		if err := s.advanceLifecycle(user, target, now, result); err != nil {
+			logger.Warn().
+				Err(err).
+				Int64("tg", user.TG).
+				Str("target", string(target)).
+				Msg("推进过期用户生命周期失败")
+			result.Failed++
 		}
-
-		// 更新用户等级为封禁
-		s.embyRepo.UpdateFields(user.TG, map[string]interface{}{
-			"lv": models.LevelE,
-		})
-
-		// 发送通知给用户
-		s.notifyUser(user.TG, "expired")
 	}
 
 	return result, nil
 }
 
-// CheckWarning 检测即将过期的用户并发送预警
-func (s *ExpiryService) CheckWarning(daysBeforeExpiry int) (*ExpiryResult, error) {
+// CheckWarning 检测即将过期的用户并按提前天数发送预警
+// leadDays 如 [7, 3, 1]，每个到期时间的每档预警只发送一次
+func (s *ExpiryService) CheckWarning(leadDays []int) (*ExpiryResult, error) {
 	result := &ExpiryResult{}
 
-	if daysBeforeExpiry <= 0 {
-		daysBeforeExpiry = 3 // 默认提前 3 天预警
+	if len(leadDays) == 0 {
+		leadDays = []int{3} // 默认提前 3 天预警
 	}
 
 	users, err := s.embyRepo.GetActiveUsers()
@@ -130,73 +131,140 @@ func (s *ExpiryService) CheckWarning(daysBeforeExpiry int) (*ExpiryResult, error
 
 	result.Checked = len(users)
 	now := time.Now()
-	warningDate := now.AddDate(0, 0, daysBeforeExpiry)
 
 	for _, user := range users {
-		// 跳过白名单用户
-		if user.Lv == models.LevelA {
+		// 跳过白名单及已封禁用户
+		if user.Lv == models.LevelA || user.IsBanned() {
 			continue
 		}
 
-		if user.Ex == nil {
+		if user.Ex == nil || !user.Ex.After(now) {
+			continue
+		}
+
+		lead, ok := PickWarningLead(user.Ex.Sub(now), leadDays)
+		if !ok {
 			continue
 		}
 
-		// 检查是否在预警期内（未过期但即将过期）
-		if user.Ex.After(now) && user.Ex.Before(warningDate) {
-			daysLeft := int(user.Ex.Sub(now).Hours() / 24)
-			s.notifyUserWarning(user.TG, daysLeft)
-			result.WarningSent++
+		expiryAt := user.Ex.Truncate(time.Second)
+		sent, err := s.warningRepo.Exists(user.TG, expiryAt, lead)
+		if err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("查询预警记录失败")
+			continue
 		}
+		if sent {
+			continue
+		}
+
+		daysLeft := int(math.Ceil(user.Ex.Sub(now).Hours() / 24))
+		if !s.notifyUserWarning(user.TG, daysLeft, *user.Ex) {
+			continue
+		}
+
+		if err := s.warningRepo.Create(&models.ExpiryWarning{
+			TG:       user.TG,
+			ExpiryAt: expiryAt,
+			LeadDays: lead,
+			SentAt:   now,
+		}); err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("保存预警记录失败")
+		}
+		result.WarningSent++
+	}
+
+	// 清理已失效的预警记录
+	if _, err := s.warningRepo.DeleteBefore(now.AddDate(0, 0, -30)); err != nil {
+		logger.Debug().Err(err).Msg("清理预警记录失败")
 	}
 
 	return result, nil
 }
 
-// notifyUser 通知用户
-func (s *ExpiryService) notifyUser(tgID int64, notifyType string) {
-	if s.bot == nil {
-		return
+// PickWarningLead 根据剩余时间选择应发送的预警档位（满足条件的最小提前天数）
+func PickWarningLead(remaining time.Duration, leadDays []int) (int, bool) {
+	best, found := 0, false
+	for _, lead := range leadDays {
+		if lead <= 0 || remaining > time.Duration(lead)*24*time.Hour {
+			continue
+		}
+		if !found || lead < best {
+			best, found = lead, true
+		}
 	}
+	return best, found
+}
 
+// notifyUser 通知用户生命周期变化
+func (s *ExpiryService) notifyUser(tgID int64, notifyType string, expiry time.Time) {
 	var text string
 	switch notifyType {
+	case "grace":
+		text = fmt.Sprintf("⏳ **账户已过期**\n\n"+
+			"您的 Emby 账户已于 %s 到期，宽限期内仍可正常使用。\n\n"+
+			"宽限期结束后账户将被停用，请尽快续期。",
+			expiry.Format("2006-01-02 15:04"))
 	case "expired":
 		text = "⚠️ **账户已过期**\n\n" +
 			"您的 Emby 账户已到期，访问权限已被暂停。\n\n" +
-			"如需续期，请联系管理员或使用注册码续期。"
-	case "disabled":
-		text = "🚫 **账户已被禁用**\n\n" +
-			"您的 Emby 账户已被禁用，如有疑问请联系管理员。"
+			"续期后将自动恢复，可使用积分或注册码续期。"
+	case "frozen":
+		if s.cfg.Lifecycle.KeepFrozen {
+			text = "❄️ **账户已封存**\n\n" +
+				"您的 Emby 账户已封存，续期后可恢复账户及观看记录。"
+			break
+		}
+		text = fmt.Sprintf("❄️ **账户已封存**\n\n"+
+			"您的 Emby 账户将于 %s 被删除。\n\n"+
+			"在此之前续期仍可恢复账户及观看记录。",
+			s.deleteAt(time.Now()).Format("2006-01-02"))
+	case "deleted":
+		text = "🗑 **账户已删除**\n\n" +
+			"您的 Emby 账户因长期未续期已被删除，如需继续使用请重新注册。"
 	}
 
-	chat := &tele.Chat{ID: tgID}
-	if _, err := s.bot.Send(chat, text, tele.ModeMarkdown); err != nil {
-		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送过期通知失败")
+	msg := &notify.Message{Event: notify.EventLifecycle, Title: "账户状态变更", Text: text}
+	if notifyType != "deleted" {
+		msg.Action = &notify.Action{Kind: notify.ActionRenew}
 	}
-}
 
-// notifyUserWarning 发送预警通知
-func (s *ExpiryService) notifyUserWarning(tgID int64, daysLeft int) {
-	if s.bot == nil {
-		return
+	if err := s.notifier.NotifyUser(tgID, msg); err != nil {
+		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送过期通知失败")
 	}
+}
 
+// notifyUserWarning 发送预警通知（附带续期按钮），返回是否发送成功
+func (s *ExpiryService) notifyUserWarning(tgID int64, daysLeft int, expiry time.Time) bool {
 	text := fmt.Sprintf(
 		"⏰ **账户即将过期**\n\n"+
-			"您的 Emby 账户将在 **%d 天**后到期。\n\n"+
-			"请及时续期以免影响使用。",
-		daysLeft,
+			"您的 Emby 账户将在 **%d 天**后（%s）到期。\n\n"+
+			"请及时续期以免影响使用，可使用%s或注册码续期。",
+		daysLeft, expiry.Format("2006-01-02 15:04"), s.cfg.Money,
 	)
 
-	chat := &tele.Chat{ID: tgID}
-	if _, err := s.bot.Send(chat, text, tele.ModeMarkdown); err != nil {
+	if err := s.notifier.NotifyUser(tgID, &notify.Message{
+		Event:  notify.EventExpiryWarning,
+		Title:  "账户即将过期",
+		Text:   text,
+		Action: &notify.Action{Kind: notify.ActionRenew},
+	}); err != nil {
 		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送预警通知失败")
+		return false
 	}
+	return true
 }
 
-// RenewUser 续期用户
+// RenewUser 续期用户（管理员命令、Web 接口），续期后结算邀请续期奖励
 func (s *ExpiryService) RenewUser(tgID int64, days int) error {
+	if err := s.ExtendExpiry(tgID, days); err != nil {
+		return err
+	}
+	NewReferralService().OnRenewed(tgID)
+	return nil
+}
+
+// ExtendExpiry 延长到期时间并恢复过期阶段中的账户，不结算邀请奖励（用于发放奖励天数）
+func (s *ExpiryService) ExtendExpiry(tgID int64, days int) error {
 	user, err := s.embyRepo.GetByTG(tgID)
 	if err != nil {
 		return fmt.Errorf("用户不存在: %w", err)
@@ -213,23 +281,18 @@ func (s *ExpiryService) RenewUser(tgID int64, days int) error {
 	}
 
 	// 更新数据库
-	updates := map[string]interface{}{
+	if err := s.embyRepo.UpdateFields(tgID, map[string]interface{}{
 		"ex": newExpiry,
+	}); err != nil {
+		return err
 	}
 
-	// 如果用户被封禁，恢复为普通用户
-	if user.Lv == models.LevelE {
-		updates["lv"] = models.LevelD
-
-		// 重新启用 Emby 账户
-		if user.EmbyID != nil && *user.EmbyID != "" {
-			if err := s.embyClient.EnableUser(*user.EmbyID); err != nil {
-				logger.Warn().Err(err).Int64("tg", tgID).Msg("启用 Emby 账户失败")
-			}
-		}
+	// 过期阶段中的账户恢复访问（封禁状态不受续期影响）
+	user.Ex = &newExpiry
+	if _, err := s.restore(user); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("恢复续期用户失败")
 	}
-
-	return s.embyRepo.UpdateFields(tgID, updates)
+	return nil
 }
 
 // GetUserExpiry 获取用户到期信息
@@ -242,7 +305,8 @@ func (s *ExpiryService) GetUserExpiry(tgID int64) (*UserExpiryInfo, error) {
 	info := &UserExpiryInfo{
 		TG:          tgID,
 		IsWhitelist: user.Lv == models.LevelA,
-		IsBanned:    user.Lv == models.LevelE,
+		IsBanned:    user.IsBanned(),
+		Status:      user.CurrentStatus(),
 	}
 
 	if user.Name != nil {
@@ -269,6 +333,7 @@ type UserExpiryInfo struct {
 	IsExpired   bool
 	IsWhitelist bool
 	IsBanned    bool
+	Status      models.LifecycleStatus
 }
 
 // FormatExpiryInfo 格式化到期信息
@@ -283,6 +348,12 @@ func (info *UserExpiryInfo) FormatExpiryInfo() string {
 		return "❓ 未设置到期时间"
 	}
 	if info.IsExpired {
+		switch info.Status {
+		case models.StatusGrace:
+			return fmt.Sprintf("⏳ 已于 %s 过期（宽限期内）", info.ExpiryTime.Format("2006-01-02"))
+		case models.StatusDisabled, models.StatusFrozen:
+			return fmt.Sprintf("❌ 已于 %s 过期，账户已停用（续期后自动恢复）", info.ExpiryTime.Format("2006-01-02"))
+		}
 		return fmt.Sprintf("❌ 已于 %s 过期", info.ExpiryTime.Format("2006-01-02"))
 	}
 	return fmt.Sprintf("✅ %s 到期（剩余 %d 天）",
@@ -0,0 +1,89 @@
+// Package service 到期预警与通知测试
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+)
+
+func TestPickWarningLead(t *testing.T) {
+	day := 24 * time.Hour
+	leads := []int{7, 3, 1}
+
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		leads     []int
+		want      int
+		wantOK    bool
+	}{
+		{"超出最大提前天数", 10 * day, leads, 0, false},
+		{"恰好 7 天", 7 * day, leads, 7, true},
+		{"5 天选 7 天档", 5 * day, leads, 7, true},
+		{"2 天选 3 天档", 2*day + time.Hour, leads, 3, true},
+		{"不足 1 天", 6 * time.Hour, leads, 1, true},
+		{"乱序配置", 2 * day, []int{1, 7, 3}, 3, true},
+		{"忽略非法天数", day / 2, []int{0, -1, 3}, 3, true},
+		{"空配置", day, nil, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PickWarningLead(tt.remaining, tt.leads)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("PickWarningLead() = (%d, %v), want (%d, %v)", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+// fakeSender 记录服务发出的通知
+type fakeSender struct {
+	err   error
+	users []int64
+	msgs  []*notify.Message
+}
+
+func (f *fakeSender) NotifyUser(tgID int64, msg *notify.Message) error {
+	f.users = append(f.users, tgID)
+	f.msgs = append(f.msgs, msg)
+	return f.err
+}
+
+func (f *fakeSender) NotifyAdmins(msg *notify.Message) error {
+	f.msgs = append(f.msgs, msg)
+	return f.err
+}
+
+func (f *fakeSender) NotifyOwner(msg *notify.Message) error {
+	f.msgs = append(f.msgs, msg)
+	return f.err
+}
+
+func TestNotifyUserWarning(t *testing.T) {
+	sender := &fakeSender{}
+	s := &ExpiryService{cfg: &config.Config{Money: "花币"}, notifier: sender}
+
+	if !s.notifyUserWarning(1001, 3, time.Now().Add(72*time.Hour)) {
+		t.Fatal("发送成功应返回 true")
+	}
+	if len(sender.users) != 1 || sender.users[0] != 1001 {
+		t.Fatalf("users = %v, want [1001]", sender.users)
+	}
+	msg := sender.msgs[0]
+	if msg.Event != notify.EventExpiryWarning {
+		t.Errorf("Event = %s, want %s", msg.Event, notify.EventExpiryWarning)
+	}
+	if msg.Action == nil || msg.Action.Kind != notify.ActionRenew {
+		t.Error("预警通知应附带续期按钮")
+	}
+
+	sender.err = errors.New("failed")
+	if s.notifyUserWarning(1001, 3, time.Now()) {
+		t.Error("发送失败应返回 false，以便下次重试")
+	}
+}
@@ -2,6 +2,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
 )
 
 // RankType 排行榜类型
@@ -37,6 +39,7 @@ type RankResult struct {
 	Type      RankType
 	Title     string
 	Items     []RankItem
+	Source    emby.DataSource // 实际使用的数据源
 	StartDate time.Time
 	EndDate   time.Time
 	Generated time.Time
@@ -60,23 +63,14 @@ func NewLeaderboardService() *LeaderboardService {
 
 // GetDayRank 获取日榜
 func (s *LeaderboardService) GetDayRank(limit int) (*RankResult, error) {
-	now := time.Now()
-	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-
-	return s.getRank(RankTypeDay, startOfDay, now, limit)
+	start, end := RankRange(RankTypeDay, time.Now())
+	return s.getRank(RankTypeDay, start, end, limit)
 }
 
 // GetWeekRank 获取周榜
 func (s *LeaderboardService) GetWeekRank(limit int) (*RankResult, error) {
-	now := time.Now()
-	// 获取本周一
-	weekday := int(now.Weekday())
-	if weekday == 0 {
-		weekday = 7 // 周日
-	}
-	startOfWeek := time.Date(now.Year(), now.Month(), now.Day()-weekday+1, 0, 0, 0, 0, now.Location())
-
-	return s.getRank(RankTypeWeek, startOfWeek, now, limit)
+	start, end := RankRange(RankTypeWeek, time.Now())
+	return s.getRank(RankTypeWeek, start, end, limit)
 }
 
 // getRank 获取排行榜
@@ -86,9 +80,13 @@ func (s *LeaderboardService) getRank(rankType RankType, startDate, endDate time.
 	}
 
 	// 从 Emby 获取播放统计
-	stats, err := s.getPlaybackStats(startDate, endDate, limit)
+	stats, source, err := s.getPlaybackStats(startDate, endDate, limit)
 	if err != nil {
-		logger.Error().Err(err).Msg("获取播放统计失败")
+		if errors.Is(err, emby.ErrNoPlaybackData) {
+			logger.Info().Err(err).Msg("暂无播放统计数据")
+		} else {
+			logger.Error().Err(err).Msg("获取播放统计失败")
+		}
 		return nil, err
 	}
 
@@ -123,6 +121,7 @@ func (s *LeaderboardService) getRank(rankType RankType, startDate, endDate time.
 		Type:      rankType,
 		Title:     fmt.Sprintf("📊 %s 播放排行榜", title),
 		Items:     items,
+		Source:    source,
 		StartDate: startDate,
 		EndDate:   endDate,
 		Generated: time.Now(),
@@ -137,19 +136,18 @@ type PlaybackStat struct {
 	WatchTime int64 // 秒
 }
 
-// getPlaybackStats 从 Emby 获取播放统计
-func (s *LeaderboardService) getPlaybackStats(startDate, endDate time.Time, limit int) ([]PlaybackStat, error) {
+// getPlaybackStats 从 Emby 获取播放统计，返回实际使用的数据源
+// 所有数据源都无数据时返回的错误满足 errors.Is(err, emby.ErrNoPlaybackData)
+func (s *LeaderboardService) getPlaybackStats(startDate, endDate time.Time, limit int) ([]PlaybackStat, emby.DataSource, error) {
 	logger.Debug().
 		Time("start", startDate).
 		Time("end", endDate).
 		Int("limit", limit).
 		Msg("获取播放统计")
 
-	// 尝试从 Emby API 获取真实数据
-	ranking, err := s.embyClient.GetUserRanking(startDate, endDate, limit)
+	ranking, source, err := s.embyClient.GetUserRanking(startDate, endDate, limit)
 	if err != nil {
-		logger.Warn().Err(err).Msg("从 Emby 获取播放统计失败，使用模拟数据")
-		return s.mockPlaybackStats(limit), nil
+		return nil, "", err
 	}
 
 	// 转换为 PlaybackStat
@@ -163,46 +161,7 @@ func (s *LeaderboardService) getPlaybackStats(startDate, endDate time.Time, limi
 		})
 	}
 
-	// 如果没有数据，返回模拟数据
-	if len(stats) == 0 {
-		logger.Debug().Msg("Emby 返回空数据，使用模拟数据")
-		return s.mockPlaybackStats(limit), nil
-	}
-
-	return stats, nil
-}
-
-// mockPlaybackStats 模拟播放统计数据（测试用或 Emby API 不可用时）
-func (s *LeaderboardService) mockPlaybackStats(limit int) []PlaybackStat {
-	// 获取数据库中的用户作为测试数据
-	users, err := s.embyRepo.GetActiveUsers()
-	if err != nil || len(users) == 0 {
-		return []PlaybackStat{}
-	}
-
-	stats := make([]PlaybackStat, 0, limit)
-	for i, user := range users {
-		if i >= limit {
-			break
-		}
-		if user.EmbyID == nil {
-			continue
-		}
-
-		username := "用户"
-		if user.Name != nil {
-			username = *user.Name
-		}
-
-		stats = append(stats, PlaybackStat{
-			UserID:    *user.EmbyID,
-			Username:  username,
-			PlayCount: 100 - i*10, // 模拟数据
-			WatchTime: int64((100 - i*10) * 3600), // 模拟观看时长
-		})
-	}
-
-	return stats
+	return stats, source, nil
 }
 
 // FormatWatchTime 格式化观看时长
@@ -223,10 +182,13 @@ func (r *RankResult) FormatRankText() string {
 
 	for _, item := range r.Items {
 		medal := getMedal(item.Rank)
-		text += fmt.Sprintf("%s **%d.** %s\n", medal, item.Rank, item.Username)
+		text += fmt.Sprintf("%s **%d.** %s\n", medal, item.Rank, utils.EscapeMarkdown(item.Username))
 		text += fmt.Sprintf("   ▸ 播放 %d 次 | %s\n", item.PlayCount, FormatWatchTime(item.WatchTime))
 	}
 
+	if r.Source != "" {
+		text += fmt.Sprintf("\n📡 数据来源: %s", utils.EscapeMarkdown(r.Source.Label()))
+	}
 	text += fmt.Sprintf("\n⏰ 生成于 %s", r.Generated.Format("2006-01-02 15:04:05"))
 	return text
 }
@@ -261,7 +223,7 @@ func (s *LeaderboardService) GetUserPlayStats(limit int) ([]UserPlayStat, error)
 	now := time.Now()
 	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
 
-	ranking, err := s.embyClient.GetUserRanking(startOfMonth, now, limit)
+	ranking, _, err := s.embyClient.GetUserRanking(startOfMonth, now, limit)
 	if err != nil {
 		logger.Warn().Err(err).Msg("获取用户播放统计失败")
 		return nil, err
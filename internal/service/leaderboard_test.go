@@ -0,0 +1,27 @@
+// Package service 播放排行榜测试
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+)
+
+func TestRankResultFormatRankText(t *testing.T) {
+	r := &RankResult{
+		Title:     "日榜",
+		StartDate: time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2024, 6, 1, 23, 0, 0, 0, time.UTC),
+		Items:     []RankItem{{Rank: 1, Username: "snake_case*", PlayCount: 3, WatchTime: 3600}},
+		Source:    emby.SourcePlaybackReporting,
+	}
+
+	text := r.FormatRankText()
+	for _, want := range []string{`snake\_case\*`, `playback\_reporting 插件`} {
+		if !strings.Contains(text, want) {
+			t.Errorf("排行榜缺少转义后的 %q:\n%s", want, text)
+		}
+	}
+}
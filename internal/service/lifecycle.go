@@ -0,0 +1,194 @@
+// Package service 过期账户生命周期
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// lifecycleOrder 生命周期阶段顺序，只允许向后推进（续期恢复除外）
+var lifecycleOrder = map[models.LifecycleStatus]int{
+	models.StatusActive:   0,
+	models.StatusGrace:    1,
+	models.StatusDisabled: 2,
+	models.StatusFrozen:   3,
+	models.StatusDeleted:  4,
+}
+
+// LifecycleStatusAt 计算账户在 now 时刻应处的阶段
+// 各阶段从上一阶段结束时起算，已进入的阶段至少持续配置的天数（从进入时间起算），
+// 避免升级迁移或长时间停机后一次跳过多个阶段；未封存的账户不会直接删除，
+// 配置 keep_frozen 时停留在封存阶段
+func LifecycleStatusAt(user *models.Emby, now time.Time, lc config.LifecycleConfig, freezeDays int) models.LifecycleStatus {
+	if user.Ex == nil || now.Before(*user.Ex) {
+		return models.StatusActive
+	}
+
+	graceEnd := stageEnd(*user.Ex, user.GraceAt, user.DisabledAt, lc.GraceDays)
+	if now.Before(graceEnd) {
+		return models.StatusGrace
+	}
+
+	disableEnd := stageEnd(graceEnd, user.DisabledAt, user.FrozenAt, lc.DisableDays)
+	if now.Before(disableEnd) {
+		return models.StatusDisabled
+	}
+
+	if lc.KeepFrozen || user.FrozenAt == nil || now.Before(stageEnd(disableEnd, user.FrozenAt, nil, freezeDays)) {
+		return models.StatusFrozen
+	}
+	return models.StatusDeleted
+}
+
+// stageEnd 阶段结束时间：已进入下一阶段时为下一阶段的进入时间，
+// 否则为上一阶段结束后 days 天，且不早于进入该阶段后 days 天
+func stageEnd(prevEnd time.Time, enteredAt, nextAt *time.Time, days int) time.Time {
+	if nextAt != nil {
+		return *nextAt
+	}
+	end := prevEnd.AddDate(0, 0, nonNegative(days))
+	if enteredAt != nil {
+		if t := enteredAt.AddDate(0, 0, nonNegative(days)); t.After(end) {
+			end = t
+		}
+	}
+	return end
+}
+
+// deleteAt 计算在 frozenAt 封存的账户将被删除的时间
+func (s *ExpiryService) deleteAt(frozenAt time.Time) time.Time {
+	return frozenAt.AddDate(0, 0, nonNegative(s.cfg.FreezeDays))
+}
+
+// advanceLifecycle 将用户推进到目标阶段，可一次跨越多个阶段
+// 禁用或删除 Emby 账户失败时不更新状态，等待下次检测重试
+func (s *ExpiryService) advanceLifecycle(user *models.Emby, target models.LifecycleStatus, now time.Time, result *ExpiryResult) error {
+	current := user.CurrentStatus()
+	from, to := lifecycleOrder[current], lifecycleOrder[target]
+	if to <= from {
+		return nil
+	}
+
+	updates := map[string]interface{}{"status": target}
+	reached := func(stage models.LifecycleStatus) bool {
+		return from < lifecycleOrder[stage] && to >= lifecycleOrder[stage]
+	}
+
+	if reached(models.StatusGrace) {
+		updates["grace_at"] = now
+		result.Grace++
+	}
+
+	if reached(models.StatusDisabled) {
+		if err := s.embyClient.DisableUser(*user.EmbyID); err != nil {
+			return fmt.Errorf("禁用 Emby 账户失败: %w", err)
+		}
+		DisableServers(user.TG)
+		updates["disabled_at"] = now
+		result.Disabled++
+	}
+
+	if reached(models.StatusFrozen) {
+		updates["frozen_at"] = now
+		result.Frozen++
+	}
+
+	if target == models.StatusDeleted {
+		if err := s.embyClient.DeleteUser(*user.EmbyID); err != nil {
+			return fmt.Errorf("删除 Emby 账户失败: %w", err)
+		}
+		DeleteServerAccounts(user.TG)
+		updates["removed_at"] = now
+		updates["embyid"] = nil
+		updates["name"] = nil
+		updates["pwd"] = nil
+		updates["pwd2"] = nil
+		updates["cr"] = nil
+		updates["ex"] = nil
+		result.Deleted++
+	}
+
+	if err := s.embyRepo.UpdateFields(user.TG, updates); err != nil {
+		return fmt.Errorf("更新生命周期状态失败: %w", err)
+	}
+
+	logger.Info().
+		Int64("tg", user.TG).
+		Str("from", string(current)).
+		Str("to", string(target)).
+		Msg("账户生命周期已推进")
+
+	// 一次跨越多个阶段时依次发送每个阶段的通知
+	for _, stage := range []struct {
+		status     models.LifecycleStatus
+		notifyType string
+	}{
+		{models.StatusGrace, "grace"},
+		{models.StatusDisabled, "expired"},
+		{models.StatusFrozen, "frozen"},
+		{models.StatusDeleted, "deleted"},
+	} {
+		if reached(stage.status) {
+			s.notifyUser(user.TG, stage.notifyType, *user.Ex)
+		}
+	}
+
+	return nil
+}
+
+// Renewed 续期入口更新到期时间后调用：恢复账户并结算邀请人的首次续期奖励
+func (s *ExpiryService) Renewed(tgID int64) error {
+	err := s.RestoreAccess(tgID)
+	NewReferralService().OnRenewed(tgID)
+	return err
+}
+
+// RestoreAccess 续期后恢复账户（删除前的任意阶段均可恢复）
+// 续期入口在更新到期时间后调用，未续期到未来的账户保持原状态
+func (s *ExpiryService) RestoreAccess(tgID int64) error {
+	user, err := s.embyRepo.GetByTG(tgID)
+	if err != nil {
+		return fmt.Errorf("用户不存在: %w", err)
+	}
+	_, err = s.restore(user)
+	return err
+}
+
+// restore 将已续期的账户恢复为正常状态，返回是否发生了恢复
+func (s *ExpiryService) restore(user *models.Emby) (bool, error) {
+	status := user.CurrentStatus()
+	if status == models.StatusActive || !user.HasEmbyAccount() {
+		return false, nil
+	}
+	if user.Lv != models.LevelA && (user.Ex == nil || !user.Ex.After(time.Now())) {
+		return false, nil
+	}
+
+	// 封禁用户保持禁用，仅恢复生命周期状态
+	if (status == models.StatusDisabled || status == models.StatusFrozen) && !user.IsBanned() {
+		if err := s.embyClient.EnableUser(*user.EmbyID); err != nil {
+			return false, fmt.Errorf("启用 Emby 账户失败: %w", err)
+		}
+	}
+
+	if err := s.embyRepo.ResetLifecycle(user.TG); err != nil {
+		return false, err
+	}
+
+	SyncServers(user.TG)
+
+	logger.Info().Int64("tg", user.TG).Str("from", string(status)).Msg("账户已续期，恢复正常状态")
+	return true, nil
+}
+
+// nonNegative 负数按 0 处理
+func nonNegative(n int) int {
+	if n < 0 {
+		return 0
+	}
+	return n
+}
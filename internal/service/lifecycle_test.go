@@ -0,0 +1,59 @@
+// Package service 账户生命周期测试
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestLifecycleStatusAt(t *testing.T) {
+	ex := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	lc := config.LifecycleConfig{GraceDays: 3, DisableDays: 7}
+	freezeDays := 5
+	day := 24 * time.Hour
+
+	at := func(d time.Duration) *time.Time {
+		t := ex.Add(d)
+		return &t
+	}
+	expired := func() *models.Emby { return &models.Emby{Ex: &ex} }
+	frozen := &models.Emby{Ex: &ex, GraceAt: at(0), DisabledAt: at(3 * day), FrozenAt: at(10 * day)}
+	// 旧版迁移的账户：到期很久后才标记为禁用
+	migrated := &models.Emby{Ex: &ex, GraceAt: at(100 * day), DisabledAt: at(100 * day)}
+
+	tests := []struct {
+		name string
+		user *models.Emby
+		now  time.Time
+		lc   config.LifecycleConfig
+		want models.LifecycleStatus
+	}{
+		{"未到期", expired(), ex.Add(-time.Hour), lc, models.StatusActive},
+		{"刚到期进入宽限期", expired(), ex, lc, models.StatusGrace},
+		{"宽限期内", expired(), ex.Add(2 * day), lc, models.StatusGrace},
+		{"宽限期结束禁用", expired(), ex.Add(3 * day), lc, models.StatusDisabled},
+		{"禁用期内", expired(), ex.Add(9 * day), lc, models.StatusDisabled},
+		{"进入封存", expired(), ex.Add(10 * day), lc, models.StatusFrozen},
+		{"封存期内", frozen, ex.Add(14 * day), lc, models.StatusFrozen},
+		{"封存期结束删除", frozen, ex.Add(15 * day), lc, models.StatusDeleted},
+		{"未封存不直接删除", expired(), ex.Add(30 * day), lc, models.StatusFrozen},
+		{"封存时间晚于计划时间", &models.Emby{Ex: &ex, FrozenAt: at(20 * day)}, ex.Add(24 * day), lc, models.StatusFrozen},
+		{"迁移账户禁用期内", migrated, ex.Add(106 * day), lc, models.StatusDisabled},
+		{"迁移账户禁用期结束封存", migrated, ex.Add(107 * day), lc, models.StatusFrozen},
+		{"保留封存账户", frozen, ex.Add(365 * day), config.LifecycleConfig{GraceDays: 3, DisableDays: 7, KeepFrozen: true}, models.StatusFrozen},
+		{"无宽限期直接禁用", expired(), ex.Add(time.Minute), config.LifecycleConfig{DisableDays: 7}, models.StatusDisabled},
+		{"负数按 0 处理", expired(), ex.Add(time.Minute), config.LifecycleConfig{GraceDays: -1, DisableDays: 7}, models.StatusDisabled},
+		{"没有到期时间", &models.Emby{}, ex, lc, models.StatusActive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := LifecycleStatusAt(tt.user, tt.now, tt.lc, freezeDays); got != tt.want {
+				t.Errorf("LifecycleStatusAt() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+// Package service 媒体播放排行榜服务
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/jpeg" // Emby 海报多为 JPEG
+	_ "image/png"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/pkg/imggen"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
+)
+
+// MediaRankEntry 媒体排行榜条目
+type MediaRankEntry struct {
+	emby.MediaRankItem
+	Rank     int
+	PosterID string // 海报所属项目 ID，剧集为剧集本身的 ID
+}
+
+// MediaRankResult 媒体排行榜结果（电影与剧集两栏）
+type MediaRankResult struct {
+	Type      RankType
+	Title     string
+	Movies    []MediaRankEntry
+	Series    []MediaRankEntry
+	StartDate time.Time
+	EndDate   time.Time
+	Generated time.Time
+}
+
+// MediaRankService 媒体播放排行榜服务
+type MediaRankService struct {
+	embyClient *emby.Client
+	cfg        *config.Config
+}
+
+// NewMediaRankService 创建媒体播放排行榜服务
+func NewMediaRankService() *MediaRankService {
+	return &MediaRankService{
+		embyClient: emby.GetClient(),
+		cfg:        config.Get(),
+	}
+}
+
+// RankRange 榜单统计区间：日榜为今天，周榜为本周一至今
+func RankRange(rankType RankType, now time.Time) (time.Time, time.Time) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	if rankType == RankTypeWeek {
+		weekday := int(now.Weekday())
+		if weekday == 0 {
+			weekday = 7 // 周日
+		}
+		start = start.AddDate(0, 0, -weekday+1)
+	}
+	return start, now
+}
+
+// RankPeriod 榜单周期标识，同一周期内重复推送会编辑原消息
+func RankPeriod(rankType RankType, now time.Time) string {
+	if rankType == RankTypeWeek {
+		year, week := now.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	}
+	return now.Format("2006-01-02")
+}
+
+// GetMediaRank 获取电影与剧集播放排行，任一栏获取成功即返回
+func (s *MediaRankService) GetMediaRank(rankType RankType, limit int) (*MediaRankResult, error) {
+	if limit <= 0 {
+		limit = 10
+	}
+
+	startDate, endDate := RankRange(rankType, time.Now())
+
+	movies, movieErr := s.embyClient.GetMediaRanking(startDate, endDate, emby.MediaTypeMovie, limit)
+	if movieErr != nil {
+		logger.Warn().Err(movieErr).Msg("获取电影播放排行失败")
+	}
+	series, seriesErr := s.embyClient.GetMediaRanking(startDate, endDate, emby.MediaTypeSeries, limit)
+	if seriesErr != nil {
+		logger.Warn().Err(seriesErr).Msg("获取剧集播放排行失败")
+	}
+	if movieErr != nil && seriesErr != nil {
+		return nil, fmt.Errorf("获取媒体播放排行失败: %w", movieErr)
+	}
+
+	title := "日榜"
+	if rankType == RankTypeWeek {
+		title = "周榜"
+	}
+
+	return &MediaRankResult{
+		Type:      rankType,
+		Title:     fmt.Sprintf("🎬 %s 媒体播放%s", s.cfg.Ranks.Logo, title),
+		Movies:    s.toEntries(movies, false),
+		Series:    s.toEntries(series, true),
+		StartDate: startDate,
+		EndDate:   endDate,
+		Generated: time.Now(),
+	}, nil
+}
+
+// toEntries 编号并确定海报 ID，剧集使用所属剧集的海报
+func (s *MediaRankService) toEntries(items []emby.MediaRankItem, series bool) []MediaRankEntry {
+	entries := make([]MediaRankEntry, 0, len(items))
+	for i, item := range items {
+		entry := MediaRankEntry{MediaRankItem: item, Rank: i + 1, PosterID: item.ItemID}
+		if series && item.ItemID != "" {
+			if seriesID, err := s.embyClient.GetSeriesID(item.ItemID); err == nil {
+				entry.PosterID = seriesID
+			} else {
+				logger.Debug().Err(err).Str("item", item.ItemID).Msg("获取剧集 ID 失败，使用单集海报")
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// IsEmpty 是否没有任何播放数据
+func (r *MediaRankResult) IsEmpty() bool {
+	return len(r.Movies) == 0 && len(r.Series) == 0
+}
+
+// RenderImage 渲染带海报的榜单图片
+func (s *MediaRankService) RenderImage(result *MediaRankResult) ([]byte, error) {
+	rankTypeStr := "day"
+	if result.Type == RankTypeWeek {
+		rankTypeStr = "week"
+	}
+
+	return imggen.GenerateMediaLeaderboard(imggen.MediaRankConfig{
+		Title:    result.Title,
+		Subtitle: result.StartDate.Format("01-02") + " ~ " + result.EndDate.Format("01-02"),
+		RankType: rankTypeStr,
+		Sections: []imggen.MediaRankSection{
+			{Title: "电影", Items: s.toImageItems(result.Movies)},
+			{Title: "剧集", Items: s.toImageItems(result.Series)},
+		},
+		GeneratedAt: result.Generated,
+	})
+}
+
+// toImageItems 转换为图片条目并下载海报
+func (s *MediaRankService) toImageItems(entries []MediaRankEntry) []imggen.MediaRankData {
+	items := make([]imggen.MediaRankData, 0, len(entries))
+	for _, entry := range entries {
+		items = append(items, imggen.MediaRankData{
+			Rank:      entry.Rank,
+			Name:      entry.Name,
+			PlayCount: entry.PlayCount,
+			Users:     entry.Users,
+			WatchTime: FormatWatchTime(entry.Duration),
+			Poster:    s.fetchPoster(entry.PosterID),
+		})
+	}
+	return items
+}
+
+// fetchPoster 下载并解码海报，失败时返回 nil 由图片绘制占位块
+func (s *MediaRankService) fetchPoster(itemID string) image.Image {
+	if itemID == "" {
+		return nil
+	}
+
+	data, err := s.embyClient.GetImage(itemID, "Primary", 210, 140)
+	if err != nil {
+		logger.Debug().Err(err).Str("item", itemID).Msg("下载海报失败")
+		return nil
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		logger.Debug().Err(err).Str("item", itemID).Msg("解码海报失败")
+		return nil
+	}
+	return img
+}
+
+// FormatRankText 格式化榜单文本（图片生成失败时使用）
+func (r *MediaRankResult) FormatRankText() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("**%s**\n", r.Title))
+	sb.WriteString(fmt.Sprintf("📅 %s ~ %s\n", r.StartDate.Format("01-02"), r.EndDate.Format("01-02 15:04")))
+
+	sections := []struct {
+		title   string
+		entries []MediaRankEntry
+	}{
+		{"🎞 电影", r.Movies},
+		{"📺 剧集", r.Series},
+	}
+	for _, section := range sections {
+		sb.WriteString(fmt.Sprintf("\n**%s**\n", section.title))
+		if len(section.entries) == 0 {
+			sb.WriteString("暂无数据\n")
+			continue
+		}
+		for _, entry := range section.entries {
+			sb.WriteString(fmt.Sprintf("%s **%d.** %s\n", getMedal(entry.Rank), entry.Rank, utils.EscapeMarkdown(entry.Name)))
+			sb.WriteString(fmt.Sprintf("   ▸ 播放 %d 次 | %d 人 | %s\n", entry.PlayCount, entry.Users, FormatWatchTime(entry.Duration)))
+		}
+	}
+
+	sb.WriteString(fmt.Sprintf("\n⏰ 生成于 %s", r.Generated.Format("2006-01-02 15:04:05")))
+	return sb.String()
+}
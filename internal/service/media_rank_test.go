@@ -0,0 +1,67 @@
+// Package service 媒体播放排行榜测试
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+)
+
+func TestRankRange(t *testing.T) {
+	// 2024-01-03 是周三
+	now := time.Date(2024, 1, 3, 15, 30, 0, 0, time.UTC)
+
+	start, end := RankRange(RankTypeDay, now)
+	if !start.Equal(time.Date(2024, 1, 3, 0, 0, 0, 0, time.UTC)) || !end.Equal(now) {
+		t.Errorf("日榜区间 = %v ~ %v", start, end)
+	}
+
+	start, _ = RankRange(RankTypeWeek, now)
+	if !start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("周榜起始应为本周一，实际是 %v", start)
+	}
+
+	// 周日属于当前周
+	sunday := time.Date(2024, 1, 7, 22, 0, 0, 0, time.UTC)
+	start, _ = RankRange(RankTypeWeek, sunday)
+	if !start.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("周日的周榜起始应为周一，实际是 %v", start)
+	}
+}
+
+func TestRankPeriod(t *testing.T) {
+	tests := []struct {
+		name     string
+		rankType RankType
+		now      time.Time
+		expected string
+	}{
+		{"日榜", RankTypeDay, time.Date(2024, 1, 3, 22, 0, 0, 0, time.UTC), "2024-01-03"},
+		{"周榜", RankTypeWeek, time.Date(2024, 1, 7, 22, 0, 0, 0, time.UTC), "2024-W01"},
+		{"跨年周榜", RankTypeWeek, time.Date(2024, 12, 30, 22, 0, 0, 0, time.UTC), "2025-W01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RankPeriod(tt.rankType, tt.now); got != tt.expected {
+				t.Errorf("RankPeriod() = %s, want %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMediaRankResultFormatRankText(t *testing.T) {
+	r := &MediaRankResult{
+		Title:  "媒体日榜",
+		Movies: []MediaRankEntry{{MediaRankItem: emby.MediaRankItem{Name: "Fast_and_*Furious*", PlayCount: 2}, Rank: 1}},
+	}
+
+	text := r.FormatRankText()
+	for _, want := range []string{`Fast\_and\_\*Furious\*`, "暂无数据"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("媒体榜文本缺少 %q:\n%s", want, text)
+		}
+	}
+}
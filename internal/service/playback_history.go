@@ -0,0 +1,260 @@
+// Package service 本地播放记录服务
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// PlaybackHistoryService 本地播放记录服务
+// Webhook 记录播放开始/结束，/Sessions 轮询补齐遗漏的会话并更新进度，
+// 排行、审计与活跃度检测可直接查询 playback_sessions 表而不依赖 Emby 插件
+type PlaybackHistoryService struct {
+	repo       *repository.PlaybackSessionRepository
+	embyRepo   *repository.EmbyRepository
+	embyClient *emby.Client
+	cfg        *config.Config
+}
+
+// PollResult 轮询结果
+type PollResult struct {
+	Sessions int // 正在播放的会话数
+	Started  int // 新记录数
+	Updated  int // 更新进度的记录数
+	Stopped  int // 结束的记录数
+}
+
+// NewPlaybackHistoryService 创建本地播放记录服务
+func NewPlaybackHistoryService() *PlaybackHistoryService {
+	return &PlaybackHistoryService{
+		repo:       repository.NewPlaybackSessionRepository(),
+		embyRepo:   repository.NewEmbyRepository(),
+		embyClient: emby.GetClient(),
+		cfg:        config.Get(),
+	}
+}
+
+// Enabled 是否启用本地播放记录
+func (s *PlaybackHistoryService) Enabled() bool {
+	return s.cfg.PlaybackHistory.Enabled
+}
+
+// RecordEvent 根据 Webhook 播放事件更新播放记录
+func (s *PlaybackHistoryService) RecordEvent(event *models.PlaybackEvent) error {
+	sessionID := event.SessionID
+	if sessionID == "" {
+		sessionID = event.DeviceID
+	}
+	if sessionID == "" || event.ItemID == "" {
+		return nil
+	}
+
+	at := event.CreatedAt
+	if at.IsZero() {
+		at = time.Now()
+	}
+
+	switch event.Event {
+	case models.EventPlaybackStart:
+		return s.startFromEvent(sessionID, event, at)
+	case models.EventPlaybackStop:
+		return s.updateOpen(sessionID, event.ItemID, func(rec *models.PlaybackSession) {
+			rec.Stop(at, event.PositionTicks)
+		})
+	case models.EventPlaybackPause, models.EventPlaybackUnpause:
+		return s.updateOpen(sessionID, event.ItemID, func(rec *models.PlaybackSession) {
+			rec.Touch(at, event.PositionTicks)
+		})
+	}
+	return nil
+}
+
+// startFromEvent 开始播放：结束同一会话中的其他媒体，并创建新记录
+func (s *PlaybackHistoryService) startFromEvent(sessionID string, event *models.PlaybackEvent, at time.Time) error {
+	open, err := s.repo.ListOpenBySession(sessionID)
+	if err != nil {
+		return fmt.Errorf("查询播放记录失败: %w", err)
+	}
+	for i := range open {
+		rec := &open[i]
+		if rec.ItemID == event.ItemID {
+			rec.Touch(at, event.PositionTicks)
+			return s.repo.Save(rec)
+		}
+		rec.Stop(at, 0)
+		if err := s.repo.Save(rec); err != nil {
+			logger.Warn().Err(err).Uint("id", rec.ID).Msg("结束播放记录失败")
+		}
+	}
+
+	return s.repo.Create(&models.PlaybackSession{
+		SessionID:     sessionID,
+		EmbyUserID:    event.EmbyUserID,
+		UserName:      event.UserName,
+		TG:            event.TG,
+		ItemID:        event.ItemID,
+		ItemName:      event.ItemName,
+		ItemType:      event.ItemType,
+		Client:        event.Client,
+		DeviceName:    event.DeviceName,
+		DeviceID:      event.DeviceID,
+		ClientIP:      NormalizeIP(event.ClientIP),
+		StartedAt:     at,
+		LastSeenAt:    at,
+		PositionTicks: event.PositionTicks,
+		Source:        models.SessionSourceWebhook,
+	})
+}
+
+// updateOpen 更新仍在播放的记录，找不到时忽略
+func (s *PlaybackHistoryService) updateOpen(sessionID, itemID string, update func(*models.PlaybackSession)) error {
+	rec, err := s.repo.GetOpen(sessionID, itemID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("查询播放记录失败: %w", err)
+	}
+	update(rec)
+	return s.repo.Save(rec)
+}
+
+// Poll 轮询 Emby 活动会话：更新进行中的记录，补录 Webhook 遗漏的播放，结束已消失的会话
+func (s *PlaybackHistoryService) Poll() (*PollResult, error) {
+	pollStart := time.Now()
+	sessions, err := s.embyClient.GetSessions()
+	if err != nil {
+		return nil, err
+	}
+
+	open, err := s.repo.ListOpen()
+	if err != nil {
+		return nil, fmt.Errorf("查询播放记录失败: %w", err)
+	}
+	openByKey := make(map[string]*models.PlaybackSession, len(open))
+	for i := range open {
+		openByKey[playbackKey(open[i].SessionID, open[i].ItemID)] = &open[i]
+	}
+
+	result := &PollResult{}
+	now := time.Now()
+	seen := make(map[string]bool)
+	for _, sess := range sessions {
+		if !sess.IsPlaying() || sess.NowPlayingID == "" {
+			continue
+		}
+		result.Sessions++
+		key := playbackKey(sess.ID, sess.NowPlayingID)
+		seen[key] = true
+
+		if rec, ok := openByKey[key]; ok {
+			rec.Touch(now, sess.PositionTicks)
+			if err := s.repo.Save(rec); err != nil {
+				logger.Warn().Err(err).Uint("id", rec.ID).Msg("更新播放记录失败")
+				continue
+			}
+			result.Updated++
+			continue
+		}
+
+		rec := &models.PlaybackSession{
+			SessionID:     sess.ID,
+			EmbyUserID:    sess.UserID,
+			UserName:      sess.UserName,
+			ItemID:        sess.NowPlayingID,
+			ItemName:      sess.NowPlaying,
+			ItemType:      sess.NowPlayingType,
+			Client:        sess.Client,
+			DeviceName:    sess.DeviceName,
+			DeviceID:      sess.DeviceID,
+			ClientIP:      NormalizeIP(sess.RemoteEndPoint),
+			StartedAt:     now,
+			LastSeenAt:    now,
+			PositionTicks: sess.PositionTicks,
+			Source:        models.SessionSourcePoller,
+		}
+		if user, err := s.embyRepo.GetByEmbyID(sess.UserID); err == nil && user != nil {
+			rec.TG = user.TG
+		}
+		if err := s.repo.Create(rec); err != nil {
+			logger.Warn().Err(err).Str("session", sess.ID).Msg("创建播放记录失败")
+			continue
+		}
+		result.Started++
+	}
+
+	// 本次轮询未出现的会话视为已结束（跳过轮询开始后才由 Webhook 创建的记录）
+	for key, rec := range openByKey {
+		if seen[key] || !rec.LastSeenAt.Before(pollStart) {
+			continue
+		}
+		rec.Stop(rec.LastSeenAt, 0)
+		if err := s.repo.Save(rec); err != nil {
+			logger.Warn().Err(err).Uint("id", rec.ID).Msg("结束播放记录失败")
+			continue
+		}
+		result.Stopped++
+	}
+
+	return result, nil
+}
+
+// UserStats 按用户汇总时间范围内的播放，作为排行榜的本地数据源
+func (s *PlaybackHistoryService) UserStats(startDate, endDate time.Time) ([]emby.PlaybackStats, error) {
+	rows, err := s.repo.UserStats(startDate, endDate)
+	if err != nil {
+		return nil, fmt.Errorf("查询本地播放记录失败: %w", err)
+	}
+
+	stats := make([]emby.PlaybackStats, 0, len(rows))
+	for _, row := range rows {
+		stats = append(stats, emby.PlaybackStats{
+			UserID:        row.EmbyUserID,
+			UserName:      row.UserName,
+			PlayCount:     row.PlayCount,
+			TotalTime:     row.TotalTime,
+			TotalPlayTime: row.TotalTime,
+		})
+	}
+	return stats, nil
+}
+
+// LastPlayed 各用户最近一次播放时间（key 为 Emby 用户 ID）
+func (s *PlaybackHistoryService) LastPlayed() (map[string]time.Time, error) {
+	return s.repo.LastPlayed()
+}
+
+// Cleanup 删除超过保留天数的记录
+func (s *PlaybackHistoryService) Cleanup() (int64, error) {
+	days := s.cfg.PlaybackHistory.RetentionDays
+	if days <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteBefore(time.Now().AddDate(0, 0, -days))
+}
+
+// playbackKey 播放记录键：会话 + 媒体
+func playbackKey(sessionID, itemID string) string {
+	return sessionID + "|" + itemID
+}
+
+// NormalizeIP 去掉 RemoteEndPoint 中的端口与 IPv6 方括号
+func NormalizeIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	if len(addr) > 2 && addr[0] == '[' && addr[len(addr)-1] == ']' {
+		return addr[1 : len(addr)-1]
+	}
+	return addr
+}
@@ -0,0 +1,56 @@
+// Package service 本地播放记录测试
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNormalizeIP(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"192.168.1.10", "192.168.1.10"},
+		{"192.168.1.10:8096", "192.168.1.10"},
+		{"[2001:db8::1]:8096", "2001:db8::1"},
+		{"[2001:db8::1]", "2001:db8::1"},
+		{"2001:db8::1", "2001:db8::1"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := NormalizeIP(tt.input); got != tt.expected {
+				t.Errorf("NormalizeIP(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLatestActivity(t *testing.T) {
+	older := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(48 * time.Hour)
+
+	tests := []struct {
+		name       string
+		lastSeen   *time.Time
+		lastPlayed time.Time
+		expected   *time.Time
+	}{
+		{"都没有", nil, time.Time{}, nil},
+		{"只有 Emby 时间", &older, time.Time{}, &older},
+		{"只有本地记录", nil, newer, &newer},
+		{"本地记录更晚", &older, newer, &newer},
+		{"Emby 时间更晚", &newer, older, &newer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := latestActivity(tt.lastSeen, tt.lastPlayed)
+			if (got == nil) != (tt.expected == nil) || (got != nil && !got.Equal(*tt.expected)) {
+				t.Errorf("latestActivity() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+// Package service 积分服务
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidPointsAmount = errors.New("变动数额必须大于 0")
+	ErrTransferToSelf      = errors.New("不能转账给自己")
+)
+
+// PointsChange 余额变动请求
+type PointsChange struct {
+	TG           int64
+	Currency     models.Currency
+	Amount       int    // 变动数额（正数，方向由 Credit/Debit 决定）
+	Reason       string // 变动原因，见 models.LedgerReason*
+	Actor        int64  // 操作者 TG ID（0 表示系统）
+	Counterparty *int64 // 交易对方 TG ID
+	RefID        string // 关联业务 ID
+}
+
+// TransferRequest 转账请求
+type TransferRequest struct {
+	FromTG   int64
+	ToTG     int64
+	Currency models.Currency
+	Amount   int
+	Reason   string
+	Actor    int64
+	RefID    string
+}
+
+// PointsService 积分服务
+// 所有余额变动都应通过此服务完成，以保证原子性并留下流水
+type PointsService struct {
+	ledgerRepo *repository.LedgerRepository
+}
+
+// NewPointsService 创建积分服务
+func NewPointsService() *PointsService {
+	return &PointsService{
+		ledgerRepo: repository.NewLedgerRepository(),
+	}
+}
+
+// Credit 增加余额
+func (s *PointsService) Credit(change *PointsChange) (*models.PointsLedger, error) {
+	return s.apply(change, 1)
+}
+
+// Debit 扣减余额，余额不足时返回 ErrInsufficientBalance
+func (s *PointsService) Debit(change *PointsChange) (*models.PointsLedger, error) {
+	return s.apply(change, -1)
+}
+
+// DebitUpTo 扣减余额，余额不足时扣到 0（按扣减时的余额计算，避免使用过期的余额）
+// 返回实际扣减的流水，余额为 0 未扣减时返回 nil
+func (s *PointsService) DebitUpTo(change *PointsChange) (*models.PointsLedger, error) {
+	if change.Amount <= 0 {
+		return nil, ErrInvalidPointsAmount
+	}
+
+	entry := &models.PointsLedger{
+		TG:           change.TG,
+		Currency:     change.Currency,
+		Amount:       -change.Amount,
+		Reason:       change.Reason,
+		Actor:        change.Actor,
+		Counterparty: change.Counterparty,
+		RefID:        change.RefID,
+	}
+	debited, err := s.ledgerRepo.DebitUpTo(entry)
+	if err != nil {
+		return nil, s.mapError(err)
+	}
+	if !debited {
+		return nil, nil
+	}
+	logEntry(entry)
+	return entry, nil
+}
+
+// Transfer 在两个用户之间转移余额（同一事务内完成扣款与入账）
+func (s *PointsService) Transfer(req *TransferRequest) ([]models.PointsLedger, error) {
+	if req.Amount <= 0 {
+		return nil, ErrInvalidPointsAmount
+	}
+	if req.FromTG == req.ToTG {
+		return nil, ErrTransferToSelf
+	}
+
+	fromTG, toTG := req.FromTG, req.ToTG
+	entries := []*models.PointsLedger{
+		{
+			TG:           req.FromTG,
+			Currency:     req.Currency,
+			Amount:       -req.Amount,
+			Reason:       req.Reason,
+			Actor:        req.Actor,
+			Counterparty: &toTG,
+			RefID:        req.RefID,
+		},
+		{
+			TG:           req.ToTG,
+			Currency:     req.Currency,
+			Amount:       req.Amount,
+			Reason:       req.Reason,
+			Actor:        req.Actor,
+			Counterparty: &fromTG,
+			RefID:        req.RefID,
+		},
+	}
+
+	if err := s.ledgerRepo.Apply(entries); err != nil {
+		return nil, s.mapError(err)
+	}
+
+	logger.Info().
+		Int64("from", req.FromTG).
+		Int64("to", req.ToTG).
+		Str("currency", string(req.Currency)).
+		Int("amount", req.Amount).
+		Str("reason", req.Reason).
+		Msg("余额转移成功")
+
+	return []models.PointsLedger{*entries[0], *entries[1]}, nil
+}
+
+// History 分页查询用户流水
+func (s *PointsService) History(tg int64, page, pageSize int) ([]models.PointsLedger, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	return s.ledgerRepo.ListByTG(tg, page, pageSize)
+}
+
+// apply 执行单条余额变动
+func (s *PointsService) apply(change *PointsChange, sign int) (*models.PointsLedger, error) {
+	if change.Amount <= 0 {
+		return nil, ErrInvalidPointsAmount
+	}
+
+	entry := &models.PointsLedger{
+		TG:           change.TG,
+		Currency:     change.Currency,
+		Amount:       sign * change.Amount,
+		Reason:       change.Reason,
+		Actor:        change.Actor,
+		Counterparty: change.Counterparty,
+		RefID:        change.RefID,
+	}
+
+	if err := s.ledgerRepo.Apply([]*models.PointsLedger{entry}); err != nil {
+		return nil, s.mapError(err)
+	}
+	logEntry(entry)
+	return entry, nil
+}
+
+// logEntry 记录余额变动日志
+func logEntry(entry *models.PointsLedger) {
+	logger.Info().
+		Int64("tg", entry.TG).
+		Str("currency", string(entry.Currency)).
+		Int("amount", entry.Amount).
+		Int("balance", entry.Balance).
+		Str("reason", entry.Reason).
+		Int64("actor", entry.Actor).
+		Msg("余额变动")
+}
+
+// mapError 将仓库层错误转换为服务层错误
+func (s *PointsService) mapError(err error) error {
+	switch {
+	case errors.Is(err, repository.ErrBalanceNotEnough):
+		return ErrInsufficientBalance
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return ErrUserNotFound
+	default:
+		return fmt.Errorf("余额变动失败: %w", err)
+	}
+}
+
+// LedgerReasonName 流水原因的显示名称
+func LedgerReasonName(reason string) string {
+	switch reason {
+	case models.LedgerReasonAdmin:
+		return "管理员调整"
+	case models.LedgerReasonAdminBatch:
+		return "批量发放"
+	case models.LedgerReasonAdminClear:
+		return "管理员清空"
+	case models.LedgerReasonCheckin:
+		return "签到奖励"
+	case models.LedgerReasonRepairCard:
+		return "购买补签卡"
+	case models.LedgerReasonRedEnvelope:
+		return "发红包"
+	case models.LedgerReasonRedGrab:
+		return "抢红包"
+	case models.LedgerReasonRedRefund:
+		return "红包退款"
+	case models.LedgerReasonStoreRenew:
+		return "兑换续期"
+	case models.LedgerReasonStoreWhite:
+		return "兑换白名单"
+	case models.LedgerReasonStoreReborn:
+		return "兑换解封"
+	case models.LedgerReasonStoreInvite:
+		return "兑换邀请码"
+	case models.LedgerReasonStoreRefund:
+		return "商城退款"
+	case models.LedgerReasonMoviePilot:
+		return "求片下载"
+	case models.LedgerReasonMPRefund:
+		return "求片退款"
+	case models.LedgerReasonPlayRank:
+		return "播放榜奖励"
+	case models.LedgerReasonChangeTG:
+		return "换绑转移"
+	case models.LedgerReasonReferral:
+		return "邀请返利"
+	default:
+		return reason
+	}
+}
@@ -0,0 +1,53 @@
+// Package service 积分服务测试
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestPointsService_InvalidAmount(t *testing.T) {
+	svc := &PointsService{}
+
+	for _, amount := range []int{0, -5} {
+		change := &PointsChange{TG: 1, Currency: models.CurrencyScore, Amount: amount}
+		if _, err := svc.Credit(change); !errors.Is(err, ErrInvalidPointsAmount) {
+			t.Errorf("Credit(%d) error = %v, want ErrInvalidPointsAmount", amount, err)
+		}
+		if _, err := svc.Debit(change); !errors.Is(err, ErrInvalidPointsAmount) {
+			t.Errorf("Debit(%d) error = %v, want ErrInvalidPointsAmount", amount, err)
+		}
+	}
+}
+
+func TestPointsService_TransferValidation(t *testing.T) {
+	svc := &PointsService{}
+
+	tests := []struct {
+		name string
+		req  *TransferRequest
+		want error
+	}{
+		{"金额为 0", &TransferRequest{FromTG: 1, ToTG: 2, Currency: models.CurrencyCoin, Amount: 0}, ErrInvalidPointsAmount},
+		{"转给自己", &TransferRequest{FromTG: 1, ToTG: 1, Currency: models.CurrencyCoin, Amount: 10}, ErrTransferToSelf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := svc.Transfer(tt.req); !errors.Is(err, tt.want) {
+				t.Errorf("Transfer() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLedgerReasonName(t *testing.T) {
+	if got := LedgerReasonName(models.LedgerReasonCheckin); got != "签到奖励" {
+		t.Errorf("LedgerReasonName(checkin) = %q", got)
+	}
+	if got := LedgerReasonName("custom"); got != "custom" {
+		t.Errorf("未知原因应原样返回, got %q", got)
+	}
+}
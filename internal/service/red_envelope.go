@@ -28,10 +28,23 @@ var (
 	ErrCannotReceiveOwnRed  = errors.New("不能领取自己的红包")
 )
 
+// redEnvelopeStore 红包服务使用的红包数据操作（测试时替换为内存实现）
+type redEnvelopeStore interface {
+	Create(envelope *models.RedEnvelope) error
+	GetByUUID(uuid string) (*models.RedEnvelope, error)
+	Grab(record *models.RedEnvelopeRecord, entry *models.PointsLedger) (bool, error)
+	SetFinished(uuid string) error
+	SetExpired(uuid string) error
+	HasReceived(uuid string, tgID int64) bool
+	GetLuckyRecord(uuid string) (*models.RedEnvelopeRecord, error)
+	GetRecordsByEnvelope(uuid string) ([]models.RedEnvelopeRecord, error)
+}
+
 // RedEnvelopeService 红包服务
 type RedEnvelopeService struct {
-	redRepo  *repository.RedEnvelopeRepository
+	redRepo  redEnvelopeStore
 	embyRepo *repository.EmbyRepository
+	points   *PointsService
 	cfg      *config.Config
 	mu       sync.Mutex // 防止并发抢红包问题
 }
@@ -41,6 +54,7 @@ func NewRedEnvelopeService() *RedEnvelopeService {
 	return &RedEnvelopeService{
 		redRepo:  repository.NewRedEnvelopeRepository(),
 		embyRepo: repository.NewEmbyRepository(),
+		points:   NewPointsService(),
 		cfg:      config.Get(),
 	}
 }
@@ -90,23 +104,29 @@ func (s *RedEnvelopeService) CreateEnvelope(req *CreateEnvelopeRequest) (*Create
 		return nil, errors.New("红包金额不能少于红包个数")
 	}
 
-	// 检查发送者积分
-	sender, err := s.embyRepo.GetByTG(req.SenderTG)
-	if err != nil {
+	// 检查发送者账户
+	if _, err := s.embyRepo.GetByTG(req.SenderTG); err != nil {
 		return nil, errors.New("请先 /start 初始化账户")
 	}
-	if sender.Us < req.TotalAmount {
-		return nil, ErrInsufficientBalance
-	}
 
-	// 扣除发送者积分
-	newScore := sender.Us - req.TotalAmount
-	if err := s.embyRepo.UpdateFields(req.SenderTG, map[string]interface{}{"us": newScore}); err != nil {
+	// 扣除发送者积分（条件更新，余额不足直接失败）
+	envelopeUUID := uuid.New().String()
+	if _, err := s.points.Debit(&PointsChange{
+		TG:           req.SenderTG,
+		Currency:     models.CurrencyScore,
+		Amount:       req.TotalAmount,
+		Reason:       models.LedgerReasonRedEnvelope,
+		Actor:        req.SenderTG,
+		Counterparty: req.TargetTG,
+		RefID:        envelopeUUID,
+	}); err != nil {
+		if errors.Is(err, ErrInsufficientBalance) {
+			return nil, ErrInsufficientBalance
+		}
 		return nil, fmt.Errorf("扣除积分失败: %w", err)
 	}
 
 	// 创建红包
-	envelopeUUID := uuid.New().String()
 	message := req.Message
 	if message == "" {
 		message = "恭喜发财，大吉大利！"
@@ -131,8 +151,16 @@ func (s *RedEnvelopeService) CreateEnvelope(req *CreateEnvelopeRequest) (*Create
 	}
 
 	if err := s.redRepo.Create(envelope); err != nil {
-		// 回滚积分
-		s.embyRepo.UpdateFields(req.SenderTG, map[string]interface{}{"us": sender.Us})
+		// 退还积分
+		if _, refundErr := s.points.Credit(&PointsChange{
+			TG:       req.SenderTG,
+			Currency: models.CurrencyScore,
+			Amount:   req.TotalAmount,
+			Reason:   models.LedgerReasonRedRefund,
+			RefID:    envelopeUUID,
+		}); refundErr != nil {
+			logger.Error().Err(refundErr).Int64("sender", req.SenderTG).Msg("红包创建失败后退还积分失败")
+		}
 		return nil, fmt.Errorf("创建红包失败: %w", err)
 	}
 
@@ -210,16 +238,8 @@ func (s *RedEnvelopeService) ReceiveEnvelope(uuid string, receiverTG int64, rece
 	// 计算领取金额
 	amount := s.calculateAmount(envelope)
 
-	// 更新红包剩余
-	if err := s.redRepo.UpdateRemain(uuid, amount); err != nil {
-		return nil, fmt.Errorf("领取失败: %w", err)
-	}
-
-	// 更新内存中的值（用于后续判断）
-	envelope.RemainAmount -= amount
-	envelope.RemainCount--
-
-	// 创建领取记录
+	// 扣减红包剩余、写入领取记录与领取者入账在同一事务中完成
+	senderTG := envelope.SenderTG
 	record := &models.RedEnvelopeRecord{
 		EnvelopeID:   envelope.ID,
 		EnvelopeUUID: uuid,
@@ -229,13 +249,27 @@ func (s *RedEnvelopeService) ReceiveEnvelope(uuid string, receiverTG int64, rece
 		IsLucky:      false,
 		CreatedAt:    time.Now(),
 	}
-	s.redRepo.CreateRecord(record)
-
-	// 给领取者加积分
-	receiver, _ := s.embyRepo.GetByTG(receiverTG)
-	if receiver != nil {
-		s.embyRepo.UpdateFields(receiverTG, map[string]interface{}{"us": receiver.Us + amount})
+	entry := &models.PointsLedger{
+		TG:           receiverTG,
+		Currency:     models.CurrencyScore,
+		Amount:       amount,
+		Reason:       models.LedgerReasonRedGrab,
+		Actor:        receiverTG,
+		Counterparty: &senderTG,
+		RefID:        uuid,
+	}
+	grabbed, err := s.redRepo.Grab(record, entry)
+	if err != nil {
+		return nil, fmt.Errorf("领取失败: %w", s.points.mapError(err))
 	}
+	if !grabbed {
+		return nil, ErrEnvelopeFinished
+	}
+	logEntry(entry)
+
+	// 更新内存中的值（用于后续判断）
+	envelope.RemainAmount -= amount
+	envelope.RemainCount--
 
 	// 检查红包是否已抢完
 	isFinished := envelope.RemainCount <= 0
@@ -0,0 +1,250 @@
+// Package service 红包服务测试
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+// fakeRedEnvelopeStore 内存实现的红包数据操作
+type fakeRedEnvelopeStore struct {
+	envelopes map[string]*models.RedEnvelope
+	records   []models.RedEnvelopeRecord
+	ledger    []*models.PointsLedger
+	// exhausted 模拟并发下红包已被其他人抢完（条件更新未命中）
+	exhausted bool
+	grabErr   error
+}
+
+func newFakeRedEnvelopeStore(envelopes ...*models.RedEnvelope) *fakeRedEnvelopeStore {
+	store := &fakeRedEnvelopeStore{envelopes: make(map[string]*models.RedEnvelope)}
+	for _, e := range envelopes {
+		store.envelopes[e.UUID] = e
+	}
+	return store
+}
+
+func (f *fakeRedEnvelopeStore) Create(envelope *models.RedEnvelope) error {
+	f.envelopes[envelope.UUID] = envelope
+	return nil
+}
+
+func (f *fakeRedEnvelopeStore) GetByUUID(uuid string) (*models.RedEnvelope, error) {
+	e, ok := f.envelopes[uuid]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	copied := *e
+	return &copied, nil
+}
+
+func (f *fakeRedEnvelopeStore) Grab(record *models.RedEnvelopeRecord, entry *models.PointsLedger) (bool, error) {
+	if f.grabErr != nil {
+		return false, f.grabErr
+	}
+	e := f.envelopes[record.EnvelopeUUID]
+	if f.exhausted || e.RemainCount <= 0 || e.RemainAmount < record.Amount {
+		return false, nil
+	}
+	e.RemainAmount -= record.Amount
+	e.RemainCount--
+	f.records = append(f.records, *record)
+	f.ledger = append(f.ledger, entry)
+	return true, nil
+}
+
+func (f *fakeRedEnvelopeStore) SetFinished(uuid string) error {
+	f.envelopes[uuid].Status = "finished"
+	return nil
+}
+
+func (f *fakeRedEnvelopeStore) SetExpired(uuid string) error {
+	f.envelopes[uuid].Status = "expired"
+	return nil
+}
+
+func (f *fakeRedEnvelopeStore) HasReceived(uuid string, tgID int64) bool {
+	for _, r := range f.records {
+		if r.EnvelopeUUID == uuid && r.ReceiverTG == tgID {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *fakeRedEnvelopeStore) GetLuckyRecord(uuid string) (*models.RedEnvelopeRecord, error) {
+	var lucky *models.RedEnvelopeRecord
+	for i, r := range f.records {
+		if r.EnvelopeUUID == uuid && (lucky == nil || r.Amount > lucky.Amount) {
+			lucky = &f.records[i]
+		}
+	}
+	if lucky == nil {
+		return nil, errors.New("not found")
+	}
+	return lucky, nil
+}
+
+func (f *fakeRedEnvelopeStore) GetRecordsByEnvelope(uuid string) ([]models.RedEnvelopeRecord, error) {
+	var records []models.RedEnvelopeRecord
+	for _, r := range f.records {
+		if r.EnvelopeUUID == uuid {
+			records = append(records, r)
+		}
+	}
+	return records, nil
+}
+
+// testEnvelope 构造一个进行中的均分红包
+func testEnvelope(uuid string, amount, count int) *models.RedEnvelope {
+	return &models.RedEnvelope{
+		ID:           1,
+		UUID:         uuid,
+		SenderTG:     100,
+		SenderName:   "sender",
+		TotalAmount:  amount,
+		TotalCount:   count,
+		RemainAmount: amount,
+		RemainCount:  count,
+		Type:         "equal",
+		Status:       "active",
+		ExpiredAt:    time.Now().Add(time.Hour),
+	}
+}
+
+func TestRedEnvelopeService_ReceiveEnvelope(t *testing.T) {
+	store := newFakeRedEnvelopeStore(testEnvelope("env", 30, 3))
+	svc := &RedEnvelopeService{redRepo: store, points: &PointsService{}}
+
+	result, err := svc.ReceiveEnvelope("env", 200, "alice")
+	if err != nil {
+		t.Fatalf("ReceiveEnvelope() error = %v", err)
+	}
+	if result.Amount != 10 || result.RemainCount != 2 || result.IsFinished {
+		t.Errorf("result = %+v, want amount 10, remain 2, not finished", result)
+	}
+
+	if len(store.records) != 1 || store.records[0].ReceiverTG != 200 || store.records[0].Amount != 10 {
+		t.Errorf("records = %+v, want one record of 10 for 200", store.records)
+	}
+	if len(store.ledger) != 1 {
+		t.Fatalf("ledger = %d entries, want 1", len(store.ledger))
+	}
+	entry := store.ledger[0]
+	if entry.TG != 200 || entry.Amount != 10 || entry.Currency != models.CurrencyScore ||
+		entry.Reason != models.LedgerReasonRedGrab || entry.RefID != "env" ||
+		entry.Counterparty == nil || *entry.Counterparty != 100 {
+		t.Errorf("ledger entry = %+v", entry)
+	}
+
+	e := store.envelopes["env"]
+	if e.RemainAmount != 20 || e.RemainCount != 2 || e.Status != "active" {
+		t.Errorf("envelope remain = %d/%d status %s, want 20/2 active", e.RemainAmount, e.RemainCount, e.Status)
+	}
+}
+
+func TestRedEnvelopeService_ReceiveLastShare(t *testing.T) {
+	store := newFakeRedEnvelopeStore(testEnvelope("env", 20, 2))
+	svc := &RedEnvelopeService{redRepo: store, points: &PointsService{}}
+
+	if _, err := svc.ReceiveEnvelope("env", 200, "alice"); err != nil {
+		t.Fatalf("第一次领取 error = %v", err)
+	}
+	result, err := svc.ReceiveEnvelope("env", 300, "bob")
+	if err != nil {
+		t.Fatalf("第二次领取 error = %v", err)
+	}
+	if !result.IsFinished || result.RemainCount != 0 {
+		t.Errorf("result = %+v, want finished", result)
+	}
+	if got := store.envelopes["env"].Status; got != "finished" {
+		t.Errorf("status = %s, want finished", got)
+	}
+	if len(store.ledger) != 2 {
+		t.Errorf("ledger = %d entries, want 2", len(store.ledger))
+	}
+}
+
+func TestRedEnvelopeService_ReceiveRejected(t *testing.T) {
+	target := int64(999)
+
+	tests := []struct {
+		name     string
+		envelope func() *models.RedEnvelope
+		setup    func(store *fakeRedEnvelopeStore)
+		want     error
+	}{
+		{
+			name:     "红包不存在",
+			envelope: func() *models.RedEnvelope { return testEnvelope("other", 30, 3) },
+			want:     ErrEnvelopeNotFound,
+		},
+		{
+			name: "已过期",
+			envelope: func() *models.RedEnvelope {
+				e := testEnvelope("env", 30, 3)
+				e.ExpiredAt = time.Now().Add(-time.Minute)
+				return e
+			},
+			want: ErrEnvelopeExpired,
+		},
+		{
+			name: "专属红包非目标用户",
+			envelope: func() *models.RedEnvelope {
+				e := testEnvelope("env", 30, 3)
+				e.IsPrivate = true
+				e.TargetTG = &target
+				return e
+			},
+			want: ErrNotTargetUser,
+		},
+		{
+			name:     "重复领取",
+			envelope: func() *models.RedEnvelope { return testEnvelope("env", 30, 3) },
+			setup: func(store *fakeRedEnvelopeStore) {
+				store.records = append(store.records, models.RedEnvelopeRecord{EnvelopeUUID: "env", ReceiverTG: 200, Amount: 10})
+			},
+			want: ErrAlreadyReceived,
+		},
+		{
+			name:     "并发下已被抢完",
+			envelope: func() *models.RedEnvelope { return testEnvelope("env", 30, 3) },
+			setup:    func(store *fakeRedEnvelopeStore) { store.exhausted = true },
+			want:     ErrEnvelopeFinished,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newFakeRedEnvelopeStore(tt.envelope())
+			if tt.setup != nil {
+				tt.setup(store)
+			}
+			svc := &RedEnvelopeService{redRepo: store, points: &PointsService{}}
+			recordsBefore := len(store.records)
+
+			if _, err := svc.ReceiveEnvelope("env", 200, "alice"); !errors.Is(err, tt.want) {
+				t.Errorf("ReceiveEnvelope() error = %v, want %v", err, tt.want)
+			}
+			if len(store.ledger) != 0 || len(store.records) != recordsBefore {
+				t.Errorf("被拒绝的领取不应入账或写记录: ledger %d, records %d", len(store.ledger), len(store.records))
+			}
+		})
+	}
+}
+
+func TestRedEnvelopeService_ReceiveGrabError(t *testing.T) {
+	store := newFakeRedEnvelopeStore(testEnvelope("env", 30, 3))
+	store.grabErr = errors.New("db down")
+	svc := &RedEnvelopeService{redRepo: store, points: &PointsService{}}
+
+	if _, err := svc.ReceiveEnvelope("env", 200, "alice"); err == nil {
+		t.Fatal("入账失败时领取应返回错误")
+	}
+	if e := store.envelopes["env"]; e.RemainCount != 3 || e.RemainAmount != 30 {
+		t.Errorf("入账失败时红包剩余不应变化: %d/%d", e.RemainAmount, e.RemainCount)
+	}
+}
@@ -0,0 +1,472 @@
+// Package service 邀请返利服务
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+var (
+	ErrReferralDisabled   = errors.New("邀请功能未开启")
+	ErrReferralSelf       = errors.New("不能邀请自己")
+	ErrReferralInviter    = errors.New("邀请人无效")
+	ErrReferralIneligible = errors.New("您已注册过账户，无法接受邀请")
+	ErrReferralExists     = errors.New("您已接受过邀请")
+)
+
+// referralArgPrefix 邀请链接的 /start 参数前缀
+const referralArgPrefix = "ref_"
+
+// 邀请树限制
+const (
+	referralTreeDepth    = 3   // 最多展开的层数
+	referralTreeMaxNodes = 200 // 最多展示的节点数
+)
+
+// 奖励阶段
+const (
+	referralStageRegister = "register"
+	referralStageRenew    = "renew"
+)
+
+// ReferralService 邀请返利服务
+type ReferralService struct {
+	repo     *repository.ReferralRepository
+	embyRepo *repository.EmbyRepository
+	points   *PointsService
+	cfg      *config.Config
+	notifier notify.Sender
+}
+
+// NewReferralService 创建邀请返利服务
+func NewReferralService() *ReferralService {
+	return &ReferralService{
+		repo:     repository.NewReferralRepository(),
+		embyRepo: repository.NewEmbyRepository(),
+		points:   NewPointsService(),
+		cfg:      config.Get(),
+		notifier: notify.Get(),
+	}
+}
+
+// ReferralLink 用户的专属邀请链接
+func ReferralLink(botName string, tg int64) string {
+	return fmt.Sprintf("https://t.me/%s?start=%s%d", botName, referralArgPrefix, tg)
+}
+
+// ParseReferralArg 解析 /start 参数中的邀请人 TG ID
+func ParseReferralArg(arg string) (int64, bool) {
+	raw, ok := strings.CutPrefix(arg, referralArgPrefix)
+	if !ok {
+		return 0, false
+	}
+	tg, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || tg <= 0 {
+		return 0, false
+	}
+	return tg, true
+}
+
+// ReferralCurrency 返利币种
+func ReferralCurrency(cfg *config.Config) models.Currency {
+	if models.Currency(cfg.Referral.Currency) == models.CurrencyCoin {
+		return models.CurrencyCoin
+	}
+	return models.CurrencyScore
+}
+
+// FormatReferralReward 奖励说明，如 "10 积分、3 天有效期"
+func FormatReferralReward(cfg *config.Config, reward config.ReferralReward) string {
+	var parts []string
+	if reward.Points > 0 {
+		unit := "积分"
+		if ReferralCurrency(cfg) == models.CurrencyCoin {
+			unit = cfg.Money
+		}
+		parts = append(parts, fmt.Sprintf("%d %s", reward.Points, unit))
+	}
+	if reward.Days > 0 {
+		parts = append(parts, fmt.Sprintf("%d 天有效期", reward.Days))
+	}
+	if len(parts) == 0 {
+		return "无"
+	}
+	return strings.Join(parts, "、")
+}
+
+// Bind 记录邀请关系，被邀请人须从未注册过账户
+func (s *ReferralService) Bind(inviter, invitee int64, source string) error {
+	if !s.cfg.Referral.Enabled {
+		return ErrReferralDisabled
+	}
+	if inviter == invitee {
+		return ErrReferralSelf
+	}
+
+	inviterUser, err := s.embyRepo.GetByTG(inviter)
+	if err != nil || !inviterUser.HasEmbyAccount() || inviterUser.IsBanned() {
+		return ErrReferralInviter
+	}
+	if user, err := s.embyRepo.GetByTG(invitee); err == nil && (user.HasEmbyAccount() || user.Cr != nil) {
+		return ErrReferralIneligible
+	}
+
+	created, err := s.repo.Create(&models.Referral{
+		InviterTG: inviter,
+		InviteeTG: invitee,
+		Source:    source,
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("保存邀请关系失败: %w", err)
+	}
+	if !created {
+		return ErrReferralExists
+	}
+
+	logger.Info().Int64("inviter", inviter).Int64("invitee", invitee).Str("source", source).Msg("记录邀请关系")
+	return nil
+}
+
+// OnRegistered 被邀请人注册成功后发放注册奖励（每个被邀请人只发放一次）
+func (s *ReferralService) OnRegistered(invitee int64) {
+	s.settle(invitee, referralStageRegister)
+}
+
+// OnRenewed 被邀请人首次续期后发放续期奖励（每个被邀请人只发放一次）
+func (s *ReferralService) OnRenewed(invitee int64) {
+	s.settle(invitee, referralStageRenew)
+}
+
+// settle 标记阶段完成并向邀请人发放对应奖励
+func (s *ReferralService) settle(invitee int64, stage string) {
+	if !s.cfg.Referral.Enabled {
+		return
+	}
+
+	now := time.Now()
+	var claimed bool
+	var err error
+	reward := s.cfg.Referral.RegisterReward
+	if stage == referralStageRenew {
+		reward = s.cfg.Referral.RenewReward
+		claimed, err = s.repo.ClaimRenewed(invitee, now)
+	} else {
+		claimed, err = s.repo.ClaimRegistered(invitee, now)
+	}
+	if err != nil {
+		logger.Error().Err(err).Int64("invitee", invitee).Str("stage", stage).Msg("更新邀请状态失败")
+		return
+	}
+	if !claimed {
+		return
+	}
+
+	referral, err := s.repo.GetByInvitee(invitee)
+	if err != nil {
+		logger.Error().Err(err).Int64("invitee", invitee).Msg("获取邀请关系失败")
+		return
+	}
+	s.pay(referral, stage, reward)
+}
+
+// pay 发放奖励并通知邀请人
+func (s *ReferralService) pay(referral *models.Referral, stage string, reward config.ReferralReward) {
+	inviter := referral.InviterTG
+	paid := config.ReferralReward{}
+
+	if reward.Points > 0 {
+		invitee := referral.InviteeTG
+		_, err := s.points.Credit(&PointsChange{
+			TG:           inviter,
+			Currency:     ReferralCurrency(s.cfg),
+			Amount:       reward.Points,
+			Reason:       models.LedgerReasonReferral,
+			Counterparty: &invitee,
+			RefID:        fmt.Sprintf("referral:%d:%s", referral.ID, stage),
+		})
+		if err != nil {
+			logger.Error().Err(err).Int64("inviter", inviter).Str("stage", stage).Msg("发放邀请积分失败")
+		} else {
+			paid.Points = reward.Points
+		}
+	}
+
+	if reward.Days > 0 {
+		// 永久账户（无到期时间）与没有账户的邀请人不发放天数
+		user, err := s.embyRepo.GetByTG(inviter)
+		if err == nil && user.HasEmbyAccount() && user.Ex != nil {
+			if err := NewExpiryService().ExtendExpiry(inviter, reward.Days); err != nil {
+				logger.Error().Err(err).Int64("inviter", inviter).Str("stage", stage).Msg("发放邀请天数失败")
+			} else {
+				paid.Days = reward.Days
+			}
+		}
+	}
+
+	logger.Info().
+		Int64("inviter", inviter).
+		Int64("invitee", referral.InviteeTG).
+		Str("stage", stage).
+		Int("points", paid.Points).
+		Int("days", paid.Days).
+		Msg("发放邀请奖励")
+
+	if paid.Points == 0 && paid.Days == 0 {
+		return
+	}
+
+	action := "完成注册"
+	if stage == referralStageRenew {
+		action = "完成首次续期"
+	}
+	msg := &notify.Message{
+		Event: notify.EventReferral,
+		Title: "邀请奖励到账",
+		Text: fmt.Sprintf("🎉 **邀请奖励到账**\n\n您邀请的用户 %s 已%s，获得 %s",
+			s.inviteeName(referral.InviteeTG), action, FormatReferralReward(s.cfg, paid)),
+	}
+	if err := s.notifier.NotifyUser(inviter, msg); err != nil {
+		logger.Debug().Err(err).Int64("inviter", inviter).Msg("发送邀请奖励通知失败")
+	}
+}
+
+// inviteeName 被邀请人展示名称（有 Emby 账户时显示用户名，否则显示打码的 TG ID）
+func (s *ReferralService) inviteeName(tg int64) string {
+	if user, err := s.embyRepo.GetByTG(tg); err == nil && user.Name != nil && *user.Name != "" {
+		return "`" + *user.Name + "`"
+	}
+	return "`" + MaskTG(tg) + "`"
+}
+
+// MaskTG 打码显示 TG ID，只保留末四位
+func MaskTG(tg int64) string {
+	s := strconv.FormatInt(tg, 10)
+	if len(s) <= 4 {
+		return s
+	}
+	return strings.Repeat("*", len(s)-4) + s[len(s)-4:]
+}
+
+// InviteeInfo 被邀请人信息
+type InviteeInfo struct {
+	models.Referral
+	Name string // Emby 用户名，未注册时为空
+}
+
+// Invitees 分页获取用户邀请的人
+func (s *ReferralService) Invitees(inviter int64, page, pageSize int) ([]InviteeInfo, int64, error) {
+	if page < 1 {
+		page = 1
+	}
+	referrals, total, err := s.repo.ListByInviter(inviter, page, pageSize)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	names, err := s.names(referralInvitees(referrals))
+	if err != nil {
+		return nil, 0, err
+	}
+	infos := make([]InviteeInfo, len(referrals))
+	for i, r := range referrals {
+		infos[i] = InviteeInfo{Referral: r, Name: names[r.InviteeTG]}
+	}
+	return infos, total, nil
+}
+
+// Stats 获取用户的邀请统计
+func (s *ReferralService) Stats(inviter int64) (*repository.ReferralStats, error) {
+	return s.repo.StatsByInviter(inviter)
+}
+
+// TopInviters 邀请人数排行
+func (s *ReferralService) TopInviters(limit int) ([]repository.ReferralStats, map[int64]string, error) {
+	stats, err := s.repo.TopInviters(limit)
+	if err != nil {
+		return nil, nil, err
+	}
+	tgs := make([]int64, len(stats))
+	for i, st := range stats {
+		tgs[i] = st.InviterTG
+	}
+	names, err := s.names(tgs)
+	return stats, names, err
+}
+
+// Upline 获取用户的邀请人（没有时返回 nil）
+func (s *ReferralService) Upline(tg int64) *models.Referral {
+	referral, err := s.repo.GetByInvitee(tg)
+	if err != nil {
+		return nil
+	}
+	return referral
+}
+
+// ReferralNode 邀请树节点
+type ReferralNode struct {
+	TG       int64
+	Name     string
+	Referral *models.Referral // 与上级的邀请关系（根节点为 nil）
+	Children []*ReferralNode
+}
+
+// ReferralTree 以 root 为根的邀请树
+type ReferralTree struct {
+	Root      *ReferralNode
+	Nodes     int  // 已展开的节点数（不含根）
+	Truncated bool // 是否因层数或节点数限制未完整展开
+}
+
+// Tree 按层展开用户的邀请树
+func (s *ReferralService) Tree(root int64) (*ReferralTree, error) {
+	tree := &ReferralTree{Root: &ReferralNode{TG: root}}
+	level := []*ReferralNode{tree.Root}
+	seen := map[int64]bool{root: true}
+	all := []*ReferralNode{tree.Root}
+
+	for depth := 0; depth < referralTreeDepth && len(level) > 0; depth++ {
+		byTG := make(map[int64]*ReferralNode, len(level))
+		tgs := make([]int64, len(level))
+		for i, node := range level {
+			byTG[node.TG] = node
+			tgs[i] = node.TG
+		}
+
+		referrals, err := s.repo.ListByInviters(tgs)
+		if err != nil {
+			return nil, err
+		}
+
+		var next []*ReferralNode
+		for i := range referrals {
+			r := &referrals[i]
+			if seen[r.InviteeTG] {
+				continue
+			}
+			if tree.Nodes >= referralTreeMaxNodes {
+				tree.Truncated = true
+				break
+			}
+			seen[r.InviteeTG] = true
+			child := &ReferralNode{TG: r.InviteeTG, Referral: r}
+			parent := byTG[r.InviterTG]
+			parent.Children = append(parent.Children, child)
+			next = append(next, child)
+			all = append(all, child)
+			tree.Nodes++
+		}
+		level = next
+	}
+
+	// 最后一层若还有下级则标记为未完整展开
+	if !tree.Truncated && len(level) > 0 {
+		tgs := make([]int64, len(level))
+		for i, node := range level {
+			tgs[i] = node.TG
+		}
+		if more, err := s.repo.ListByInviters(tgs); err == nil && len(more) > 0 {
+			tree.Truncated = true
+		}
+	}
+
+	tgs := make([]int64, len(all))
+	for i, node := range all {
+		tgs[i] = node.TG
+	}
+	names, err := s.names(tgs)
+	if err != nil {
+		return nil, err
+	}
+	for _, node := range all {
+		node.Name = names[node.TG]
+	}
+	return tree, nil
+}
+
+// names 批量获取用户的 Emby 用户名
+func (s *ReferralService) names(tgs []int64) (map[int64]string, error) {
+	users, err := s.embyRepo.GetByTGs(tgs)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[int64]string, len(users))
+	for _, u := range users {
+		if u.Name != nil {
+			names[u.TG] = *u.Name
+		}
+	}
+	return names, nil
+}
+
+// referralInvitees 提取被邀请人 TG ID
+func referralInvitees(referrals []models.Referral) []int64 {
+	tgs := make([]int64, len(referrals))
+	for i, r := range referrals {
+		tgs[i] = r.InviteeTG
+	}
+	return tgs
+}
+
+// ReferralStatusIcon 邀请状态图标：⏳ 未注册、✅ 已注册、🔁 已续期
+func ReferralStatusIcon(r *models.Referral) string {
+	switch {
+	case r.IsRenewed():
+		return "🔁"
+	case r.IsRegistered():
+		return "✅"
+	default:
+		return "⏳"
+	}
+}
+
+// FormatReferralTree 以缩进文本展示邀请树，每个节点附带其直接邀请的注册情况
+func FormatReferralTree(tree *ReferralTree) string {
+	var sb strings.Builder
+	sb.WriteString(referralNodeLabel(tree.Root))
+	sb.WriteString("\n")
+	writeReferralChildren(&sb, tree.Root, "")
+	if tree.Truncated {
+		sb.WriteString(fmt.Sprintf("…（仅展示 %d 层、最多 %d 人）\n", referralTreeDepth, referralTreeMaxNodes))
+	}
+	return sb.String()
+}
+
+// writeReferralChildren 递归写入子节点
+func writeReferralChildren(sb *strings.Builder, node *ReferralNode, prefix string) {
+	for i, child := range node.Children {
+		branch, indent := "├ ", "│ "
+		if i == len(node.Children)-1 {
+			branch, indent = "└ ", "  "
+		}
+		sb.WriteString(prefix + branch + ReferralStatusIcon(child.Referral) + " " + referralNodeLabel(child) + "\n")
+		writeReferralChildren(sb, child, prefix+indent)
+	}
+}
+
+// referralNodeLabel 节点名称与直接邀请统计
+func referralNodeLabel(node *ReferralNode) string {
+	label := fmt.Sprintf("`%d`", node.TG)
+	if node.Name != "" {
+		label = fmt.Sprintf("`%s` (%d)", node.Name, node.TG)
+	}
+	if n := len(node.Children); n > 0 {
+		registered := 0
+		for _, child := range node.Children {
+			if child.Referral.IsRegistered() {
+				registered++
+			}
+		}
+		label += fmt.Sprintf(" [邀请 %d / 注册 %d]", n, registered)
+	}
+	return label
+}
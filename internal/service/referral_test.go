@@ -0,0 +1,111 @@
+// Package service 邀请返利测试
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestParseReferralArg(t *testing.T) {
+	tests := []struct {
+		arg    string
+		tg     int64
+		wantOK bool
+	}{
+		{"ref_123456", 123456, true},
+		{"ref_", 0, false},
+		{"ref_abc", 0, false},
+		{"ref_-5", 0, false},
+		{"SAKURA-ABC", 0, false},
+		{"123456", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arg, func(t *testing.T) {
+			tg, ok := ParseReferralArg(tt.arg)
+			if tg != tt.tg || ok != tt.wantOK {
+				t.Errorf("ParseReferralArg(%q) = (%d, %v), want (%d, %v)", tt.arg, tg, ok, tt.tg, tt.wantOK)
+			}
+		})
+	}
+
+	if tg, ok := ParseReferralArg(strings.TrimPrefix(ReferralLink("bot", 42), "https://t.me/bot?start=")); !ok || tg != 42 {
+		t.Errorf("ReferralLink 生成的参数无法解析: %d %v", tg, ok)
+	}
+}
+
+func TestMaskTG(t *testing.T) {
+	tests := []struct {
+		tg       int64
+		expected string
+	}{
+		{123456789, "*****6789"},
+		{1234, "1234"},
+		{12, "12"},
+	}
+
+	for _, tt := range tests {
+		if got := MaskTG(tt.tg); got != tt.expected {
+			t.Errorf("MaskTG(%d) = %q, want %q", tt.tg, got, tt.expected)
+		}
+	}
+}
+
+func TestFormatReferralReward(t *testing.T) {
+	cfg := &config.Config{Money: "花币"}
+
+	tests := []struct {
+		name     string
+		currency string
+		reward   config.ReferralReward
+		expected string
+	}{
+		{"积分与天数", "us", config.ReferralReward{Points: 10, Days: 3}, "10 积分、3 天有效期"},
+		{"花币", "iv", config.ReferralReward{Points: 5}, "5 花币"},
+		{"仅天数", "", config.ReferralReward{Days: 7}, "7 天有效期"},
+		{"无奖励", "us", config.ReferralReward{}, "无"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Referral.Currency = tt.currency
+			if got := FormatReferralReward(cfg, tt.reward); got != tt.expected {
+				t.Errorf("FormatReferralReward() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatReferralTree(t *testing.T) {
+	now := time.Now()
+	pending := &models.Referral{}
+	registered := &models.Referral{RegisteredAt: &now}
+	renewed := &models.Referral{RegisteredAt: &now, RenewedAt: &now}
+
+	tree := &ReferralTree{
+		Root: &ReferralNode{TG: 1, Name: "root", Children: []*ReferralNode{
+			{TG: 2, Name: "alice", Referral: renewed, Children: []*ReferralNode{
+				{TG: 4, Referral: pending},
+			}},
+			{TG: 3, Referral: registered},
+		}},
+		Nodes: 3,
+	}
+
+	want := "`root` (1) [邀请 2 / 注册 2]\n" +
+		"├ 🔁 `alice` (2) [邀请 1 / 注册 0]\n" +
+		"│ └ ⏳ `4`\n" +
+		"└ ✅ `3`\n"
+	if got := FormatReferralTree(tree); got != want {
+		t.Errorf("FormatReferralTree() =\n%s\nwant\n%s", got, want)
+	}
+
+	tree.Truncated = true
+	if got := FormatReferralTree(tree); !strings.Contains(got, "仅展示") {
+		t.Errorf("未完整展开时应有提示:\n%s", got)
+	}
+}
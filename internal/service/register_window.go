@@ -0,0 +1,433 @@
+// Package service 定时注册窗口服务
+// 管理员设置开放时间、时长与名额，由调度器每分钟检查并开放 / 关闭注册，名额在数据库中原子占用
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tele "gopkg.in/telebot.v3"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+var (
+	ErrRegisterClosed       = errors.New("注册暂未开放")
+	ErrRegisterWindowFull   = errors.New("本轮注册名额已满")
+	ErrRegisterLimitReached = errors.New("注册人数已达上限")
+	ErrRegisterWindowExists = errors.New("已有等待开放或开放中的定时注册，请先取消")
+	ErrRegisterWindowUsage  = errors.New("格式错误，请输入：时长(分钟) 人数 [开始时间]")
+)
+
+// RegisterWindowPlan 定时注册参数
+type RegisterWindowPlan struct {
+	OpensAt time.Time
+	Minutes int // 0 表示直到名额用完
+	Seats   int // 0 表示不限名额
+}
+
+// ParseRegisterWindow 解析 "时长(分钟) 人数 [开始时间]"
+// 开始时间支持 15:04（已过则为次日）与 2006-01-02 15:04，省略时立即开放
+func ParseRegisterWindow(text string, now time.Time) (*RegisterWindowPlan, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 || len(fields) > 4 {
+		return nil, ErrRegisterWindowUsage
+	}
+
+	minutes, err := strconv.Atoi(fields[0])
+	if err != nil || minutes < 0 {
+		return nil, errors.New("时长必须是非负整数（分钟）")
+	}
+	seats, err := strconv.Atoi(fields[1])
+	if err != nil || seats < 0 {
+		return nil, errors.New("人数必须是非负整数")
+	}
+	if minutes == 0 && seats == 0 {
+		return nil, errors.New("时长和人数不能同时为 0")
+	}
+
+	plan := &RegisterWindowPlan{OpensAt: now, Minutes: minutes, Seats: seats}
+	switch len(fields) {
+	case 3:
+		t, err := time.ParseInLocation("15:04", fields[2], now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("无效的开始时间 %s，请使用 HH:MM", fields[2])
+		}
+		opensAt := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+		if opensAt.Before(now.Truncate(time.Minute)) {
+			opensAt = opensAt.AddDate(0, 0, 1)
+		}
+		plan.OpensAt = opensAt
+	case 4:
+		opensAt, err := time.ParseInLocation("2006-01-02 15:04", fields[2]+" "+fields[3], now.Location())
+		if err != nil {
+			return nil, fmt.Errorf("无效的开始时间 %s %s，请使用 YYYY-MM-DD HH:MM", fields[2], fields[3])
+		}
+		if opensAt.Before(now.Truncate(time.Minute)) {
+			return nil, errors.New("开始时间已过")
+		}
+		plan.OpensAt = opensAt
+	}
+	return plan, nil
+}
+
+// registerWindowAction 窗口在 now 时应执行的操作：open、timeout、full 或空
+func registerWindowAction(w *models.RegisterWindow, now time.Time) string {
+	switch w.Status {
+	case models.RegisterWindowScheduled:
+		if !now.Before(w.OpensAt) {
+			return "open"
+		}
+	case models.RegisterWindowOpen:
+		if closesAt := w.ClosesAt(); closesAt != nil && !now.Before(*closesAt) {
+			return models.RegisterCloseTimeout
+		}
+		if w.IsFull() {
+			return models.RegisterCloseFull
+		}
+	}
+	return ""
+}
+
+// RegisterWindowService 定时注册窗口服务
+type RegisterWindowService struct {
+	repo     *repository.RegisterWindowRepository
+	embyRepo *repository.EmbyRepository
+	cfg      *config.Config
+	notifier notify.Sender
+	bot      *tele.Bot // 发送开放 / 关闭群组公告，未设置时不发公告
+}
+
+// NewRegisterWindowService 创建定时注册窗口服务
+func NewRegisterWindowService() *RegisterWindowService {
+	return &RegisterWindowService{
+		repo:     repository.NewRegisterWindowRepository(),
+		embyRepo: repository.NewEmbyRepository(),
+		cfg:      config.Get(),
+		notifier: notify.Get(),
+	}
+}
+
+// SetBot 设置发送群组公告使用的 Bot 实例
+func (s *RegisterWindowService) SetBot(bot *tele.Bot) {
+	s.bot = bot
+}
+
+// Current 当前开放中或等待开放的窗口，没有时返回 nil
+func (s *RegisterWindowService) Current() (*models.RegisterWindow, error) {
+	windows, err := s.repo.ListByStatus(models.RegisterWindowOpen, models.RegisterWindowScheduled)
+	if err != nil || len(windows) == 0 {
+		return nil, err
+	}
+	for i := range windows {
+		if windows[i].Status == models.RegisterWindowOpen {
+			return &windows[i], nil
+		}
+	}
+	return &windows[0], nil
+}
+
+// Schedule 创建定时注册窗口，开始时间已到时立即开放
+func (s *RegisterWindowService) Schedule(creator int64, plan *RegisterWindowPlan) (*models.RegisterWindow, error) {
+	current, err := s.Current()
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		return nil, ErrRegisterWindowExists
+	}
+
+	w := &models.RegisterWindow{
+		Creator: creator,
+		OpensAt: plan.OpensAt,
+		Minutes: plan.Minutes,
+		Seats:   plan.Seats,
+		Status:  models.RegisterWindowScheduled,
+	}
+	if err := s.repo.Create(w); err != nil {
+		return nil, err
+	}
+	logger.Info().Uint("window", w.ID).Time("opens_at", w.OpensAt).Int("minutes", w.Minutes).
+		Int("seats", w.Seats).Int64("admin", creator).Msg("已创建定时注册")
+
+	s.apply(w, time.Now())
+	return s.repo.GetByID(w.ID)
+}
+
+// Cancel 取消当前窗口，开放中的窗口会发送关闭公告与报告；没有窗口时返回 nil
+func (s *RegisterWindowService) Cancel() (*models.RegisterWindow, error) {
+	w, err := s.Current()
+	if err != nil || w == nil {
+		return nil, err
+	}
+	if err := s.close(w, models.RegisterCloseCancelled, time.Now()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Tick 开放到时间的窗口，关闭超时或名额已满的窗口（调度器每分钟调用）
+func (s *RegisterWindowService) Tick(now time.Time) {
+	windows, err := s.repo.ListByStatus(models.RegisterWindowScheduled, models.RegisterWindowOpen)
+	if err != nil {
+		logger.Error().Err(err).Msg("查询定时注册失败")
+		return
+	}
+	for i := range windows {
+		s.apply(&windows[i], now)
+	}
+}
+
+// apply 执行窗口在 now 时应有的状态变化
+func (s *RegisterWindowService) apply(w *models.RegisterWindow, now time.Time) {
+	var err error
+	switch action := registerWindowAction(w, now); action {
+	case "open":
+		if closesAt := w.ClosesAt(); closesAt != nil && !now.Before(*closesAt) {
+			// 停机期间错过了整个开放时段，不再开放
+			logger.Warn().Uint("window", w.ID).Time("opens_at", w.OpensAt).Msg("定时注册已错过开放时段，直接关闭")
+			err = s.close(w, models.RegisterCloseTimeout, now)
+		} else {
+			err = s.open(w, now)
+		}
+	case models.RegisterCloseTimeout, models.RegisterCloseFull:
+		err = s.close(w, action, now)
+	}
+	if err != nil {
+		logger.Error().Err(err).Uint("window", w.ID).Msg("更新定时注册状态失败")
+	}
+}
+
+// open 开放注册并发送群组公告
+func (s *RegisterWindowService) open(w *models.RegisterWindow, now time.Time) error {
+	ok, err := s.repo.Open(w.ID, now)
+	if err != nil || !ok {
+		return err
+	}
+	w.Status = models.RegisterWindowOpen
+	w.OpenedAt = &now
+	logger.Info().Uint("window", w.ID).Int("seats", w.Seats).Int("minutes", w.Minutes).Msg("定时注册已开放")
+
+	s.announce(FormatRegisterWindowOpened(w))
+	return nil
+}
+
+// close 关闭窗口，开放过的窗口发送群组公告并向 Owner 报告
+func (s *RegisterWindowService) close(w *models.RegisterWindow, reason string, now time.Time) error {
+	ok, err := s.repo.Close(w.ID, reason, now)
+	if err != nil || !ok {
+		return err
+	}
+	wasOpen := w.Status == models.RegisterWindowOpen
+	if latest, err := s.repo.GetByID(w.ID); err == nil {
+		*w = *latest
+	} else {
+		w.Status, w.CloseReason, w.ClosedAt = models.RegisterWindowClosed, reason, &now
+	}
+	logger.Info().Uint("window", w.ID).Str("reason", reason).Int("taken", w.Taken).Msg("定时注册已关闭")
+
+	if !wasOpen {
+		return nil
+	}
+	s.announce(FormatRegisterWindowClosed(w))
+
+	msg := &notify.Message{Event: notify.EventReport, Title: "定时注册报告", Text: FormatRegisterWindowReport(w)}
+	if err := s.notifier.NotifyOwner(msg); err != nil {
+		logger.Debug().Err(err).Uint("window", w.ID).Msg("发送定时注册报告失败")
+	}
+	return nil
+}
+
+// announce 在主群组发送公告
+func (s *RegisterWindowService) announce(text string) {
+	if s.bot == nil || len(s.cfg.Groups) == 0 {
+		return
+	}
+	if _, err := s.bot.Send(&tele.Chat{ID: s.cfg.Groups[0]}, text, tele.ModeMarkdown); err != nil {
+		logger.Warn().Err(err).Int64("group", s.cfg.Groups[0]).Msg("发送注册公告失败")
+	}
+}
+
+// Reserve 为自助注册占用名额：有开放中的窗口时占用窗口名额，否则按自由注册开关放行
+// 返回的窗口不为 nil 时，创建账户失败需调用 Release 归还名额，成功后调用 Registered
+func (s *RegisterWindowService) Reserve() (*models.RegisterWindow, error) {
+	if s.cfg.Open.MaxUsers > 0 {
+		_, withEmby, _, err := s.embyRepo.CountStats()
+		if err != nil {
+			return nil, err
+		}
+		if withEmby >= int64(s.cfg.Open.MaxUsers) {
+			return nil, ErrRegisterLimitReached
+		}
+	}
+
+	w, err := s.Current()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if w != nil && w.Status == models.RegisterWindowOpen {
+		if action := registerWindowAction(w, now); action == models.RegisterCloseTimeout {
+			// 调度器尚未执行时也不再接受超时后的注册
+			s.apply(w, now)
+		} else {
+			ok, err := s.repo.ClaimSeat(w.ID)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, ErrRegisterWindowFull
+			}
+			return w, nil
+		}
+	}
+
+	if !s.cfg.Open.Status {
+		return nil, ErrRegisterClosed
+	}
+	return nil, nil
+}
+
+// Release 归还 Reserve 占用的名额
+// 窗口已关闭（如占满名额后被调度器关闭）时不再归还，关闭报告中的人数保持不变
+func (s *RegisterWindowService) Release(w *models.RegisterWindow) {
+	if w == nil {
+		return
+	}
+	ok, err := s.repo.ReleaseSeat(w.ID)
+	if err != nil {
+		logger.Error().Err(err).Uint("window", w.ID).Msg("归还注册名额失败")
+		return
+	}
+	if !ok {
+		logger.Warn().Uint("window", w.ID).Msg("定时注册已关闭，名额不再归还")
+	}
+}
+
+// Registered 注册成功后检查名额，用完时立即关闭窗口
+func (s *RegisterWindowService) Registered(w *models.RegisterWindow) {
+	if w == nil {
+		return
+	}
+	latest, err := s.repo.GetByID(w.ID)
+	if err != nil {
+		logger.Error().Err(err).Uint("window", w.ID).Msg("查询定时注册失败")
+		return
+	}
+	if latest.Status == models.RegisterWindowOpen && latest.IsFull() {
+		s.apply(latest, time.Now())
+	}
+}
+
+// Remaining 当前可注册席位：开放中的窗口按窗口名额，否则按总注册限制；-1 表示不限
+func (s *RegisterWindowService) Remaining() (open bool, remaining int, err error) {
+	remaining = -1
+	if s.cfg.Open.MaxUsers > 0 {
+		_, withEmby, _, err := s.embyRepo.CountStats()
+		if err != nil {
+			return false, 0, err
+		}
+		remaining = s.cfg.Open.MaxUsers - int(withEmby)
+		if remaining < 0 {
+			remaining = 0
+		}
+	}
+
+	w, err := s.Current()
+	if err != nil {
+		return false, 0, err
+	}
+	if w != nil && w.Status == models.RegisterWindowOpen {
+		if r := w.Remaining(); r >= 0 && (remaining < 0 || r < remaining) {
+			remaining = r
+		}
+		return true, remaining, nil
+	}
+	return s.cfg.Open.Status, remaining, nil
+}
+
+// formatSeats 名额文本
+func formatSeats(n int) string {
+	if n < 0 {
+		return "不限"
+	}
+	return strconv.Itoa(n)
+}
+
+// seatLimit 名额上限，不限时返回 -1
+func seatLimit(w *models.RegisterWindow) int {
+	if w.Seats <= 0 {
+		return -1
+	}
+	return w.Seats
+}
+
+// registerCloseReasonText 关闭原因
+var registerCloseReasonText = map[string]string{
+	models.RegisterCloseTimeout:   "开放时间已到",
+	models.RegisterCloseFull:      "名额已满",
+	models.RegisterCloseCancelled: "管理员关闭",
+}
+
+// FormatRegisterWindowOpened 开放公告
+func FormatRegisterWindowOpened(w *models.RegisterWindow) string {
+	var sb strings.Builder
+	sb.WriteString("🎉 **定时注册已开放！**\n\n")
+	sb.WriteString(fmt.Sprintf("🎟️ 名额：%s\n", formatSeats(seatLimit(w))))
+	if closesAt := w.ClosesAt(); closesAt != nil {
+		sb.WriteString(fmt.Sprintf("⏰ 开放至：%s（%d 分钟）\n", closesAt.Format("01-02 15:04"), w.Minutes))
+	} else {
+		sb.WriteString("⏰ 开放至：名额用完为止\n")
+	}
+	sb.WriteString("\n私聊 Bot 发送 /start 点击注册，先到先得")
+	return sb.String()
+}
+
+// FormatRegisterWindowClosed 关闭公告
+func FormatRegisterWindowClosed(w *models.RegisterWindow) string {
+	return fmt.Sprintf("🔒 **定时注册已关闭**（%s）\n\n✅ 本轮注册：%d 人\n🎟️ 剩余名额：%s",
+		registerCloseReasonText[w.CloseReason], w.Taken, formatSeats(w.Remaining()))
+}
+
+// FormatRegisterWindowReport 关闭后发送给 Owner 的报告
+func FormatRegisterWindowReport(w *models.RegisterWindow) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("📋 **定时注册报告** #%d\n\n", w.ID))
+	sb.WriteString(fmt.Sprintf("计划开放：%s\n", w.OpensAt.Format("2006-01-02 15:04")))
+	if w.OpenedAt != nil {
+		sb.WriteString(fmt.Sprintf("实际开放：%s\n", w.OpenedAt.Format("2006-01-02 15:04")))
+	}
+	if w.ClosedAt != nil {
+		sb.WriteString(fmt.Sprintf("关闭时间：%s\n", w.ClosedAt.Format("2006-01-02 15:04")))
+		if w.OpenedAt != nil {
+			sb.WriteString(fmt.Sprintf("持续时长：%s\n", w.ClosedAt.Sub(*w.OpenedAt).Round(time.Minute)))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("关闭原因：%s\n", registerCloseReasonText[w.CloseReason]))
+	sb.WriteString(fmt.Sprintf("注册人数：%d / %s\n", w.Taken, formatSeats(seatLimit(w))))
+	sb.WriteString(fmt.Sprintf("剩余名额：%s", formatSeats(w.Remaining())))
+	return sb.String()
+}
+
+// DescribeRegisterWindow 定时注册概要（管理面板显示）
+func DescribeRegisterWindow(w *models.RegisterWindow) string {
+	seats := "名额不限"
+	if w.Seats > 0 {
+		seats = fmt.Sprintf("%d/%d 人", w.Taken, w.Seats)
+	}
+	duration := "直到名额用完"
+	if w.Minutes > 0 {
+		duration = fmt.Sprintf("%d 分钟", w.Minutes)
+	}
+	if w.Status == models.RegisterWindowOpen {
+		return fmt.Sprintf("开放中 · %s · %s", seats, duration)
+	}
+	return fmt.Sprintf("%s 开放 · %s · %s", w.OpensAt.Format("01-02 15:04"), seats, duration)
+}
@@ -0,0 +1,115 @@
+// Package service 定时注册窗口测试
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestParseRegisterWindow(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	now := time.Date(2026, 3, 10, 12, 30, 20, 0, loc)
+
+	tests := []struct {
+		name    string
+		text    string
+		opensAt time.Time
+		minutes int
+		seats   int
+		wantErr bool
+	}{
+		{"立即开放", "30 10", now, 30, 10, false},
+		{"今天稍后", "60 0 20:00", time.Date(2026, 3, 10, 20, 0, 0, 0, loc), 60, 0, false},
+		{"当前分钟", "60 5 12:30", time.Date(2026, 3, 10, 12, 30, 0, 0, loc), 60, 5, false},
+		{"已过则次日", "60 5 08:00", time.Date(2026, 3, 11, 8, 0, 0, 0, loc), 60, 5, false},
+		{"指定日期", "0 50 2026-04-01 00:00", time.Date(2026, 4, 1, 0, 0, 0, 0, loc), 0, 50, false},
+		{"日期已过", "30 10 2026-03-01 00:00", time.Time{}, 0, 0, true},
+		{"同时为 0", "0 0", time.Time{}, 0, 0, true},
+		{"缺少人数", "30", time.Time{}, 0, 0, true},
+		{"负数", "-5 10", time.Time{}, 0, 0, true},
+		{"无效时间", "30 10 25:99", time.Time{}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := ParseRegisterWindow(tt.text, now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseRegisterWindow() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !plan.OpensAt.Equal(tt.opensAt) || plan.Minutes != tt.minutes || plan.Seats != tt.seats {
+				t.Errorf("ParseRegisterWindow() = %v %d %d", plan.OpensAt, plan.Minutes, plan.Seats)
+			}
+		})
+	}
+}
+
+func TestRegisterWindowAction(t *testing.T) {
+	opensAt := time.Date(2026, 3, 10, 20, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		window   models.RegisterWindow
+		now      time.Time
+		expected string
+	}{
+		{"未到开放时间", models.RegisterWindow{Status: models.RegisterWindowScheduled, OpensAt: opensAt, Minutes: 30}, opensAt.Add(-time.Second), ""},
+		{"到达开放时间", models.RegisterWindow{Status: models.RegisterWindowScheduled, OpensAt: opensAt, Minutes: 30}, opensAt, "open"},
+		{"开放中", models.RegisterWindow{Status: models.RegisterWindowOpen, OpensAt: opensAt, Minutes: 30, Seats: 10, Taken: 3}, opensAt.Add(10 * time.Minute), ""},
+		{"时间到", models.RegisterWindow{Status: models.RegisterWindowOpen, OpensAt: opensAt, Minutes: 30, Seats: 10, Taken: 3}, opensAt.Add(30 * time.Minute), models.RegisterCloseTimeout},
+		{"名额已满", models.RegisterWindow{Status: models.RegisterWindowOpen, OpensAt: opensAt, Minutes: 30, Seats: 10, Taken: 10}, opensAt.Add(time.Minute), models.RegisterCloseFull},
+		{"不限时长", models.RegisterWindow{Status: models.RegisterWindowOpen, OpensAt: opensAt, Seats: 10, Taken: 9}, opensAt.Add(72 * time.Hour), ""},
+		{"不限名额", models.RegisterWindow{Status: models.RegisterWindowOpen, OpensAt: opensAt, Minutes: 30, Taken: 500}, opensAt.Add(time.Minute), ""},
+		{"已关闭", models.RegisterWindow{Status: models.RegisterWindowClosed, OpensAt: opensAt, Minutes: 30}, opensAt.Add(time.Hour), ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := registerWindowAction(&tt.window, tt.now); got != tt.expected {
+				t.Errorf("registerWindowAction() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFormatRegisterWindow(t *testing.T) {
+	opensAt := time.Date(2026, 3, 10, 20, 0, 0, 0, time.UTC)
+	closedAt := opensAt.Add(12 * time.Minute)
+	w := &models.RegisterWindow{
+		ID: 3, OpensAt: opensAt, OpenedAt: &opensAt, ClosedAt: &closedAt,
+		Minutes: 30, Seats: 10, Taken: 10, Status: models.RegisterWindowClosed, CloseReason: models.RegisterCloseFull,
+	}
+
+	opened := FormatRegisterWindowOpened(w)
+	for _, want := range []string{"名额：10", "03-10 20:30（30 分钟）"} {
+		if !strings.Contains(opened, want) {
+			t.Errorf("开放公告缺少 %q:\n%s", want, opened)
+		}
+	}
+
+	closed := FormatRegisterWindowClosed(w)
+	for _, want := range []string{"名额已满", "本轮注册：10 人", "剩余名额：0"} {
+		if !strings.Contains(closed, want) {
+			t.Errorf("关闭公告缺少 %q:\n%s", want, closed)
+		}
+	}
+
+	report := FormatRegisterWindowReport(w)
+	for _, want := range []string{"#3", "持续时长：12m0s", "注册人数：10 / 10"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("报告缺少 %q:\n%s", want, report)
+		}
+	}
+
+	unlimited := &models.RegisterWindow{OpensAt: opensAt, Seats: 0, Taken: 4, CloseReason: models.RegisterCloseCancelled}
+	if got := FormatRegisterWindowClosed(unlimited); !strings.Contains(got, "剩余名额：不限") || !strings.Contains(got, "管理员关闭") {
+		t.Errorf("不限名额关闭公告 = %s", got)
+	}
+	if got := FormatRegisterWindowOpened(unlimited); !strings.Contains(got, "名额用完为止") || !strings.Contains(got, "名额：不限") {
+		t.Errorf("不限时长开放公告 = %s", got)
+	}
+}
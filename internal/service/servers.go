@@ -0,0 +1,340 @@
+// Package service 多服务器账户同步服务
+package service
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// ServerService 多服务器账户同步服务
+// 主服务器账户由原有流程管理，本服务把创建、启用、禁用、删除与媒体库开关同步到附加服务器
+type ServerService struct {
+	embyRepo    *repository.EmbyRepository
+	accountRepo *repository.EmbyAccountRepository
+	embyClient  *emby.Client
+	servers     []*emby.Server
+	cfg         *config.Config
+}
+
+// ServerAccount 用户在某台服务器上的账户信息
+type ServerAccount struct {
+	Server   string
+	Line     string
+	Name     string
+	Password string
+	Disabled bool
+	Main     bool // 是否为主服务器
+}
+
+// NewServerService 创建多服务器账户同步服务
+func NewServerService() *ServerService {
+	return &ServerService{
+		embyRepo:    repository.NewEmbyRepository(),
+		accountRepo: repository.NewEmbyAccountRepository(),
+		embyClient:  emby.GetClient(),
+		servers:     emby.GetAdditionalServers(),
+		cfg:         config.Get(),
+	}
+}
+
+// Enabled 是否配置了附加服务器
+func (s *ServerService) Enabled() bool {
+	return len(s.servers) > 0
+}
+
+// Sync 按用户当前状态同步附加服务器账户
+// 正常用户：补建可用服务器上缺失的账户并启用，不可用的服务器禁用；
+// 封禁、到期停用或无主账户的用户：禁用所有附加服务器账户
+func (s *ServerService) Sync(tgID int64) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	user, err := s.embyRepo.GetByTG(tgID)
+	if err != nil {
+		return fmt.Errorf("用户不存在: %w", err)
+	}
+
+	if !user.HasEmbyAccount() || user.IsBanned() || user.IsSuspended() {
+		return s.Disable(tgID)
+	}
+	return s.enable(user)
+}
+
+// enable 启用用户可用的服务器账户，必要时补建
+func (s *ServerService) enable(user *models.Emby) error {
+	accounts, err := s.accountMap(user.TG)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, srv := range s.servers {
+		account, exists := accounts[srv.Config.Name]
+		allowed := srv.Config.Allows(string(user.Lv))
+
+		switch {
+		case allowed && !exists:
+			if err := s.provision(srv, user); err != nil {
+				errs = append(errs, err)
+			}
+		case allowed && account.Disabled:
+			errs = append(errs, s.setDisabled(srv, account, false))
+		case !allowed && exists && !account.Disabled:
+			errs = append(errs, s.setDisabled(srv, account, true))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// provision 在附加服务器上创建与主服务器同名同密码的账户
+func (s *ServerService) provision(srv *emby.Server, user *models.Emby) error {
+	if user.Name == nil || *user.Name == "" {
+		return fmt.Errorf("用户 %d 没有用户名", user.TG)
+	}
+	password := ""
+	if user.Pwd != nil {
+		password = *user.Pwd
+	}
+
+	limit := s.cfg.StreamLimit.ForLevel(string(user.Lv)).Streams
+	blocked := append(append([]string{}, srv.Config.BlockedLibs...), s.cfg.Emby.Main().ExtraLibs...)
+	embyID, err := srv.Client.CreateUserWithPassword(*user.Name, password, blocked, limit)
+	if err != nil {
+		return fmt.Errorf("[%s] %w", srv.Config.Name, err)
+	}
+
+	account := &models.EmbyAccount{
+		TG:     user.TG,
+		Server: srv.Config.Name,
+		EmbyID: embyID,
+		Name:   *user.Name,
+		Pwd:    password,
+	}
+	if err := s.accountRepo.Create(account); err != nil {
+		srv.Client.DeleteUser(embyID)
+		return fmt.Errorf("[%s] 保存账户失败: %w", srv.Config.Name, err)
+	}
+
+	logger.Info().Int64("tg", user.TG).Str("server", srv.Config.Name).Msg("已在附加服务器创建账户")
+	return nil
+}
+
+// Disable 禁用用户在所有附加服务器上的账户
+func (s *ServerService) Disable(tgID int64) error {
+	if !s.Enabled() {
+		return nil
+	}
+
+	accounts, err := s.accountMap(tgID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, srv := range s.servers {
+		if account, ok := accounts[srv.Config.Name]; ok && !account.Disabled {
+			errs = append(errs, s.setDisabled(srv, account, true))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// setDisabled 启用或禁用单个附加服务器账户
+func (s *ServerService) setDisabled(srv *emby.Server, account *models.EmbyAccount, disabled bool) error {
+	var err error
+	if disabled {
+		err = srv.Client.DisableUser(account.EmbyID)
+	} else {
+		err = srv.Client.EnableUser(account.EmbyID)
+	}
+	if err != nil {
+		return fmt.Errorf("[%s] %w", srv.Config.Name, err)
+	}
+	return s.accountRepo.SetDisabled(account.ID, disabled)
+}
+
+// ResetPasswords 将用户所有附加服务器账户重置为空密码并更新密码记录
+func (s *ServerService) ResetPasswords(tgID int64) error {
+	accounts, err := s.accountRepo.ListByTG(tgID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range accounts {
+		account := &accounts[i]
+		srv := emby.GetServer(account.Server)
+		if srv == nil {
+			continue
+		}
+		if err := srv.Client.ResetPassword(account.EmbyID); err != nil {
+			errs = append(errs, fmt.Errorf("[%s] %w", account.Server, err))
+			continue
+		}
+		errs = append(errs, s.accountRepo.SetPassword(account.ID, ""))
+	}
+	return errors.Join(errs...)
+}
+
+// DeleteAll 删除用户在所有附加服务器上的账户
+func (s *ServerService) DeleteAll(tgID int64) error {
+	accounts, err := s.accountRepo.ListByTG(tgID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range accounts {
+		account := &accounts[i]
+		if srv := emby.GetServer(account.Server); srv != nil {
+			if err := srv.Client.DeleteUser(account.EmbyID); err != nil {
+				errs = append(errs, fmt.Errorf("[%s] %w", account.Server, err))
+				continue
+			}
+		}
+		errs = append(errs, s.accountRepo.Delete(account.ID))
+	}
+	return errors.Join(errs...)
+}
+
+// SetFolders 在用户的所有附加服务器账户上显示或隐藏指定媒体库
+func (s *ServerService) SetFolders(tgID int64, folders []string, show bool) error {
+	if !s.Enabled() || len(folders) == 0 {
+		return nil
+	}
+
+	accounts, err := s.accountMap(tgID)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, srv := range s.servers {
+		account, ok := accounts[srv.Config.Name]
+		if !ok {
+			continue
+		}
+		if show {
+			err = srv.Client.ShowFolders(account.EmbyID, folders)
+		} else {
+			err = srv.Client.HideFolders(account.EmbyID, folders)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("[%s] %w", srv.Config.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SyncAll 同步所有有主账户的用户（新增服务器或调整等级范围后使用）
+func (s *ServerService) SyncAll() (*BatchResult, error) {
+	users, err := s.embyRepo.GetActiveUsers()
+	if err != nil {
+		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+
+	result := &BatchResult{
+		Total:   len(users),
+		Details: make([]string, 0),
+	}
+	for _, user := range users {
+		if err := s.Sync(user.TG); err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("同步附加服务器账户失败")
+			result.Failed++
+			result.Details = append(result.Details, fmt.Sprintf("失败: %d (%v)", user.TG, err))
+			continue
+		}
+		result.Success++
+	}
+	return result, nil
+}
+
+// Accounts 获取用户在各服务器上的账户（主服务器在前）
+func (s *ServerService) Accounts(user *models.Emby) ([]ServerAccount, error) {
+	level := string(user.Lv)
+	list := make([]ServerAccount, 0, len(s.servers)+1)
+
+	if user.HasEmbyAccount() {
+		mainCfg := s.cfg.Emby.Main()
+		main := ServerAccount{
+			Server:   mainCfg.Name,
+			Line:     mainCfg.LineFor(level),
+			Disabled: user.IsBanned() || user.IsSuspended(),
+			Main:     true,
+		}
+		if user.Name != nil {
+			main.Name = *user.Name
+		}
+		if user.Pwd != nil {
+			main.Password = *user.Pwd
+		}
+		list = append(list, main)
+	}
+
+	accounts, err := s.accountMap(user.TG)
+	if err != nil {
+		return list, err
+	}
+	for _, srv := range s.servers {
+		account, ok := accounts[srv.Config.Name]
+		if !ok {
+			continue
+		}
+		list = append(list, ServerAccount{
+			Server:   srv.Config.Name,
+			Line:     srv.Config.LineFor(level),
+			Name:     account.Name,
+			Password: account.Pwd,
+			Disabled: account.Disabled,
+		})
+	}
+	return list, nil
+}
+
+// accountMap 按服务器名称索引用户的附加服务器账户
+func (s *ServerService) accountMap(tgID int64) (map[string]*models.EmbyAccount, error) {
+	accounts, err := s.accountRepo.ListByTG(tgID)
+	if err != nil {
+		return nil, err
+	}
+	m := make(map[string]*models.EmbyAccount, len(accounts))
+	for i := range accounts {
+		m[accounts[i].Server] = &accounts[i]
+	}
+	return m, nil
+}
+
+// SyncServers 同步用户的附加服务器账户，失败只记录日志不影响主流程
+func SyncServers(tgID int64) {
+	if err := NewServerService().Sync(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("同步附加服务器账户失败")
+	}
+}
+
+// DisableServers 禁用用户的附加服务器账户，失败只记录日志
+func DisableServers(tgID int64) {
+	if err := NewServerService().Disable(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("禁用附加服务器账户失败")
+	}
+}
+
+// ResetServerPasswords 重置用户附加服务器账户的密码，失败只记录日志
+func ResetServerPasswords(tgID int64) {
+	if err := NewServerService().ResetPasswords(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("重置附加服务器账户密码失败")
+	}
+}
+
+// DeleteServerAccounts 删除用户的附加服务器账户，失败只记录日志
+func DeleteServerAccounts(tgID int64) {
+	if err := NewServerService().DeleteAll(tgID); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("删除附加服务器账户失败")
+	}
+}
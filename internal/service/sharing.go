@@ -0,0 +1,484 @@
+// Package service 账户共享检测服务
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
+)
+
+var (
+	ErrSharingDecisionNotFound = errors.New("检测记录不存在")
+	ErrSharingDecided          = errors.New("该记录已被处理")
+	ErrInvalidSharingAction    = errors.New("无效的处理方式")
+)
+
+// sharingDetailItems 上报消息中每类最多列出的网段 / 设备数
+const sharingDetailItems = 8
+
+// SharingDetector 账户共享检测服务
+// 按滚动窗口统计每个账户的 IP 网段数、设备数与不同设备同时播放次数，得分超过阈值时上报管理员
+type SharingDetector struct {
+	embyRepo     *repository.EmbyRepository
+	decisionRepo *repository.SharingDecisionRepository
+	sessionRepo  *repository.PlaybackSessionRepository
+	audit        *AuditService
+	embyClient   *emby.Client
+	cfg          *config.Config
+	notifier     notify.Sender
+}
+
+// SharingEvidence 单个账户在检测窗口内的共享证据
+type SharingEvidence struct {
+	Subnets  []string // 不同的 IP 网段（IPv4 /24，IPv6 /64）
+	Devices  []string // 不同的设备名
+	Overlaps int      // 不同设备同时播放的次数
+}
+
+// SharingResult 检测结果
+type SharingResult struct {
+	Checked  int // 检查的账户数
+	Flagged  int // 超过阈值的账户数
+	Reported int // 新上报的账户数
+	Skipped  int // 窗口内已上报过而跳过的账户数
+	Failed   int // 获取记录失败的账户数
+}
+
+// NewSharingDetector 创建账户共享检测服务
+func NewSharingDetector() *SharingDetector {
+	return &SharingDetector{
+		embyRepo:     repository.NewEmbyRepository(),
+		decisionRepo: repository.NewSharingDecisionRepository(),
+		sessionRepo:  repository.NewPlaybackSessionRepository(),
+		audit:        NewAuditService(),
+		embyClient:   emby.GetClient(),
+		cfg:          config.Get(),
+		notifier:     notify.Get(),
+	}
+}
+
+// local 是否使用本地播放记录
+func (d *SharingDetector) local() bool {
+	return d.cfg.PlaybackHistory.Enabled
+}
+
+// Run 检查所有账户，超过阈值且窗口内未上报过的账户通知管理员处理
+func (d *SharingDetector) Run() (*SharingResult, error) {
+	users, err := d.embyRepo.GetAllWithEmby()
+	if err != nil {
+		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+
+	cfg := d.cfg.Sharing
+	now := time.Now()
+	since := now.AddDate(0, 0, -cfg.WindowDays)
+
+	// 未启用本地播放记录时只能以当前会话判断同时播放
+	var live map[string][]emby.Session
+	if !d.local() {
+		sessions, err := d.embyClient.GetSessions()
+		if err != nil {
+			logger.Warn().Err(err).Msg("获取活动会话失败，本次不统计同时播放")
+		}
+		live = groupPlayingSessions(sessions)
+	}
+
+	result := &SharingResult{}
+	for i := range users {
+		user := &users[i]
+		if user.Lv == models.LevelE {
+			continue
+		}
+		result.Checked++
+
+		evidence, err := d.Collect(*user.EmbyID, since, live)
+		if err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("获取账户使用记录失败")
+			result.Failed++
+			continue
+		}
+		score := evidence.Score(cfg)
+		if score < cfg.Threshold {
+			continue
+		}
+		result.Flagged++
+
+		reported, err := d.decisionRepo.ReportedSince(user.TG, since)
+		if err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("查询共享检测记录失败")
+			result.Failed++
+			continue
+		}
+		if reported {
+			result.Skipped++
+			continue
+		}
+
+		name := *user.EmbyID
+		if user.Name != nil {
+			name = *user.Name
+		}
+		decision := &models.SharingDecision{
+			TG:        user.TG,
+			EmbyID:    *user.EmbyID,
+			Name:      name,
+			Score:     score,
+			Subnets:   len(evidence.Subnets),
+			Devices:   len(evidence.Devices),
+			Overlaps:  evidence.Overlaps,
+			Detail:    evidence.Detail(),
+			Action:    models.SharingActionPending,
+			CreatedAt: now,
+		}
+		if err := d.decisionRepo.Create(decision); err != nil {
+			logger.Error().Err(err).Int64("tg", user.TG).Msg("保存共享检测记录失败")
+			result.Failed++
+			continue
+		}
+		result.Reported++
+
+		logger.Info().
+			Int64("tg", user.TG).
+			Float64("score", score).
+			Int("subnets", decision.Subnets).
+			Int("devices", decision.Devices).
+			Int("overlaps", decision.Overlaps).
+			Msg("疑似账户共享")
+		d.notifyAdmins(decision)
+	}
+
+	return result, nil
+}
+
+// Collect 汇总账户自 since 以来的 IP、设备与同时播放记录
+// 历史记录来自本地播放记录或 Emby 插件，并合并当前在线设备；live 为未启用本地记录时按用户分组的正在播放会话
+func (d *SharingDetector) Collect(embyID string, since time.Time, live map[string][]emby.Session) (*SharingEvidence, error) {
+	var history []emby.AuditResult
+	var err error
+	if d.local() {
+		history, err = d.audit.Query(emby.AuditFilter{UserID: embyID, Since: since})
+	} else {
+		history, err = d.embyClient.GetUserIPHistory(embyID, d.cfg.Sharing.WindowDays)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	b := newEvidenceBuilder()
+	for _, h := range history {
+		b.add(h.RemoteAddress, h.DeviceName)
+	}
+	if devices, _, err := d.embyClient.GetUserDevices(embyID, 0, 100); err == nil {
+		for _, dev := range devices {
+			b.add(dev.RemoteAddr, dev.DeviceName)
+		}
+	} else {
+		logger.Debug().Err(err).Str("embyID", embyID).Msg("获取在线设备失败")
+	}
+
+	evidence := b.build()
+	if d.local() {
+		sessions, err := d.sessionRepo.ListByUser(embyID, since)
+		if err != nil {
+			return nil, fmt.Errorf("查询本地播放记录失败: %w", err)
+		}
+		evidence.Overlaps = CountOverlaps(sessions)
+	} else {
+		evidence.Overlaps = liveOverlaps(live[embyID])
+	}
+	return evidence, nil
+}
+
+// Decide 执行管理员对检测记录的处理并记录结果
+func (d *SharingDetector) Decide(id uint, action string, adminID int64) (*models.SharingDecision, error) {
+	switch action {
+	case models.SharingActionWarn, models.SharingActionReset, models.SharingActionDisable, models.SharingActionIgnore:
+	default:
+		return nil, ErrInvalidSharingAction
+	}
+
+	decision, err := d.decisionRepo.GetByID(id)
+	if err != nil {
+		return nil, ErrSharingDecisionNotFound
+	}
+
+	now := time.Now()
+	claimed, err := d.decisionRepo.Claim(id, action, adminID, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, ErrSharingDecided
+	}
+
+	if err := d.apply(decision, action); err != nil {
+		if relErr := d.decisionRepo.Release(id); relErr != nil {
+			logger.Error().Err(relErr).Uint("id", id).Msg("恢复共享检测记录失败")
+		}
+		return nil, err
+	}
+
+	decision.Action = action
+	decision.AdminID = adminID
+	decision.DecidedAt = &now
+	logger.Info().
+		Uint("id", id).
+		Int64("tg", decision.TG).
+		Int64("admin", adminID).
+		Str("action", action).
+		Msg("已处理疑似共享账户")
+	return decision, nil
+}
+
+// apply 执行处理动作
+func (d *SharingDetector) apply(decision *models.SharingDecision, action string) error {
+	switch action {
+	case models.SharingActionWarn:
+		d.notifyUser(decision.TG, "⚠️ **账户使用异常提醒**\n\n"+
+			"检测到您的账户近期在多个网络或设备上使用，账户仅限本人使用，请勿分享。\n"+
+			"如继续出现异常，账户可能被重置密码或禁用。")
+	case models.SharingActionReset:
+		if err := d.embyClient.ResetPassword(decision.EmbyID); err != nil {
+			return err
+		}
+		if err := d.embyRepo.UpdateFields(decision.TG, map[string]interface{}{"pwd": nil}); err != nil {
+			logger.Error().Err(err).Int64("tg", decision.TG).Msg("清除密码记录失败")
+		}
+		ResetServerPasswords(decision.TG)
+		d.notifyUser(decision.TG, "🔑 **账户密码已被重置**\n\n"+
+			"由于账户存在共享使用迹象，管理员已将密码重置为空密码。\n"+
+			"请尽快在个人面板中设置新密码。")
+	case models.SharingActionDisable:
+		if err := d.embyClient.DisableUser(decision.EmbyID); err != nil {
+			return err
+		}
+		if err := d.embyRepo.UpdateFields(decision.TG, map[string]interface{}{"lv": models.LevelE}); err != nil {
+			logger.Error().Err(err).Int64("tg", decision.TG).Msg("更新用户等级失败")
+		}
+		DisableServers(decision.TG)
+		d.notifyUser(decision.TG, "💢 **账户已被禁用**\n\n由于账户存在共享使用行为，管理员已禁用您的账户，如有疑问请联系管理员。")
+	}
+	return nil
+}
+
+// notifyAdmins 向管理员发送上报消息与处理按钮
+func (d *SharingDetector) notifyAdmins(decision *models.SharingDecision) {
+	msg := &notify.Message{
+		Event:  notify.EventSharing,
+		Title:  "疑似账户共享",
+		Text:   FormatSharingDecision(decision, d.cfg.Sharing),
+		Action: &notify.Action{Kind: notify.ActionSharingDecision, ID: decision.ID},
+	}
+	if err := d.notifier.NotifyAdmins(msg); err != nil {
+		logger.Debug().Err(err).Int64("tg", decision.TG).Msg("发送共享检测通知失败")
+	}
+}
+
+// notifyUser 通知用户处理结果
+func (d *SharingDetector) notifyUser(tgID int64, text string) {
+	msg := &notify.Message{Event: notify.EventSharing, Title: "账户安全提醒", Text: text}
+	if err := d.notifier.NotifyUser(tgID, msg); err != nil {
+		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送共享处理通知失败")
+	}
+}
+
+// Score 计算共享得分
+func (e *SharingEvidence) Score(cfg config.SharingConfig) float64 {
+	extraSubnets := len(e.Subnets) - cfg.FreeSubnets
+	if extraSubnets < 0 {
+		extraSubnets = 0
+	}
+	extraDevices := len(e.Devices) - cfg.FreeDevices
+	if extraDevices < 0 {
+		extraDevices = 0
+	}
+	return float64(extraSubnets)*cfg.SubnetWeight +
+		float64(extraDevices)*cfg.DeviceWeight +
+		float64(e.Overlaps)*cfg.OverlapWeight
+}
+
+// Detail 网段与设备摘要，保存到检测记录
+func (e *SharingEvidence) Detail() string {
+	return fmt.Sprintf("网段: %s\n设备: %s", summarize(e.Subnets), summarize(e.Devices))
+}
+
+// summarize 列出前若干项，其余以数量代替
+func summarize(items []string) string {
+	if len(items) == 0 {
+		return "无"
+	}
+	if len(items) <= sharingDetailItems {
+		return strings.Join(items, ", ")
+	}
+	return fmt.Sprintf("%s 等 %d 个", strings.Join(items[:sharingDetailItems], ", "), len(items))
+}
+
+// evidenceBuilder 去重收集网段与设备
+type evidenceBuilder struct {
+	subnets map[string]bool
+	devices map[string]string // 小写设备名 -> 原始设备名
+}
+
+func newEvidenceBuilder() *evidenceBuilder {
+	return &evidenceBuilder{subnets: make(map[string]bool), devices: make(map[string]string)}
+}
+
+// add 记录一条 IP 与设备，空值忽略
+func (b *evidenceBuilder) add(addr, device string) {
+	if subnet := SubnetOf(NormalizeIP(strings.TrimSpace(addr))); subnet != "" {
+		b.subnets[subnet] = true
+	}
+	if name := strings.TrimSpace(device); name != "" {
+		key := strings.ToLower(name)
+		if _, ok := b.devices[key]; !ok {
+			b.devices[key] = name
+		}
+	}
+}
+
+// build 生成排序后的证据
+func (b *evidenceBuilder) build() *SharingEvidence {
+	e := &SharingEvidence{
+		Subnets: make([]string, 0, len(b.subnets)),
+		Devices: make([]string, 0, len(b.devices)),
+	}
+	for subnet := range b.subnets {
+		e.Subnets = append(e.Subnets, subnet)
+	}
+	for _, name := range b.devices {
+		e.Devices = append(e.Devices, name)
+	}
+	sort.Strings(e.Subnets)
+	sort.Strings(e.Devices)
+	return e
+}
+
+// SubnetOf IP 所在网段：IPv4 取 /24，IPv6 取 /64，无法解析时返回原值
+func SubnetOf(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ip
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(64, 128)), Mask: net.CIDRMask(64, 128)}).String()
+}
+
+// CountOverlaps 统计不同设备播放时间重叠的次数（sessions 需按开始时间排序）
+// 未结束的记录以最近一次确认播放的时间作为结束时间
+func CountOverlaps(sessions []models.PlaybackSession) int {
+	overlaps := 0
+	for i := range sessions {
+		end := sessionEnd(&sessions[i])
+		for j := i + 1; j < len(sessions); j++ {
+			if !sessions[j].StartedAt.Before(end) {
+				break
+			}
+			if sessionDevice(&sessions[i]) != sessionDevice(&sessions[j]) {
+				overlaps++
+			}
+		}
+	}
+	return overlaps
+}
+
+// sessionEnd 播放记录的结束时间
+func sessionEnd(s *models.PlaybackSession) time.Time {
+	if s.StoppedAt != nil {
+		return *s.StoppedAt
+	}
+	return s.LastSeenAt
+}
+
+// sessionDevice 播放记录的设备标识
+func sessionDevice(s *models.PlaybackSession) string {
+	if s.DeviceID != "" {
+		return s.DeviceID
+	}
+	if s.DeviceName != "" {
+		return s.DeviceName
+	}
+	return s.SessionID
+}
+
+// groupPlayingSessions 按用户分组正在播放的会话
+func groupPlayingSessions(sessions []emby.Session) map[string][]emby.Session {
+	byUser := make(map[string][]emby.Session)
+	for _, sess := range sessions {
+		if sess.IsPlaying() {
+			byUser[sess.UserID] = append(byUser[sess.UserID], sess)
+		}
+	}
+	return byUser
+}
+
+// liveOverlaps 当前正在播放的不同设备数减一
+func liveOverlaps(sessions []emby.Session) int {
+	devices := make(map[string]bool)
+	for _, sess := range sessions {
+		device := sess.DeviceID
+		if device == "" {
+			device = sess.ID
+		}
+		devices[device] = true
+	}
+	if len(devices) <= 1 {
+		return 0
+	}
+	return len(devices) - 1
+}
+
+// SharingActionLabel 处理结果显示文本
+func SharingActionLabel(action string) string {
+	switch action {
+	case models.SharingActionWarn:
+		return "⚠️ 已警告"
+	case models.SharingActionReset:
+		return "🔑 已重置密码"
+	case models.SharingActionDisable:
+		return "💢 已禁用账户"
+	case models.SharingActionIgnore:
+		return "🙈 已忽略"
+	default:
+		return "⏳ 待处理"
+	}
+}
+
+// FormatSharingDecision 格式化检测记录
+func FormatSharingDecision(d *models.SharingDecision, cfg config.SharingConfig) string {
+	var sb strings.Builder
+	sb.WriteString("🕵️ **疑似账户共享**\n\n")
+	sb.WriteString(fmt.Sprintf("**用户**: %s (TG `%d`)\n", utils.MarkdownCode(d.Name), d.TG))
+	sb.WriteString(fmt.Sprintf("**得分**: %.1f（阈值 %.1f）\n", d.Score, cfg.Threshold))
+	sb.WriteString(fmt.Sprintf("**时间范围**: 最近 %d 天\n", cfg.WindowDays))
+	sb.WriteString(fmt.Sprintf("**IP 网段**: %d 个\n", d.Subnets))
+	sb.WriteString(fmt.Sprintf("**设备**: %d 个\n", d.Devices))
+	sb.WriteString(fmt.Sprintf("**同时播放**: %d 次\n", d.Overlaps))
+	if d.Detail != "" {
+		sb.WriteString("\n")
+		sb.WriteString(utils.EscapeMarkdown(d.Detail))
+		sb.WriteString("\n")
+	}
+	if !d.IsPending() {
+		sb.WriteString(fmt.Sprintf("\n**处理结果**: %s", SharingActionLabel(d.Action)))
+		if d.AdminID != 0 {
+			sb.WriteString(fmt.Sprintf("（管理员 `%d`）", d.AdminID))
+		}
+	}
+	return sb.String()
+}
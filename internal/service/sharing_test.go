@@ -0,0 +1,175 @@
+// Package service 账户共享检测测试
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+)
+
+func TestSubnetOf(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"192.168.1.10", "192.168.1.0/24"},
+		{"192.168.1.200", "192.168.1.0/24"},
+		{"10.0.2.1", "10.0.2.0/24"},
+		{"2001:db8:1:2:3:4:5:6", "2001:db8:1:2::/64"},
+		{"::ffff:1.2.3.4", "1.2.3.0/24"},
+		{"unknown", "unknown"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			if got := SubnetOf(tt.input); got != tt.expected {
+				t.Errorf("SubnetOf(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestSharingEvidence_Score(t *testing.T) {
+	cfg := config.SharingConfig{
+		FreeSubnets:   2,
+		FreeDevices:   3,
+		SubnetWeight:  2,
+		DeviceWeight:  1,
+		OverlapWeight: 3,
+	}
+
+	tests := []struct {
+		name     string
+		evidence SharingEvidence
+		expected float64
+	}{
+		{"未超出免检数", SharingEvidence{Subnets: []string{"a", "b"}, Devices: []string{"x", "y", "z"}}, 0},
+		{"网段超出", SharingEvidence{Subnets: []string{"a", "b", "c", "d"}}, 4},
+		{"设备超出", SharingEvidence{Devices: []string{"v", "w", "x", "y", "z"}}, 2},
+		{"同时播放", SharingEvidence{Overlaps: 2}, 6},
+		{"综合", SharingEvidence{Subnets: []string{"a", "b", "c"}, Devices: []string{"w", "x", "y", "z"}, Overlaps: 1}, 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.evidence.Score(cfg); got != tt.expected {
+				t.Errorf("Score() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestEvidenceBuilder(t *testing.T) {
+	b := newEvidenceBuilder()
+	b.add("1.2.3.4:8096", "Living Room TV")
+	b.add("1.2.3.99", "living room tv")
+	b.add("[2001:db8::1]:443", "iPhone")
+	b.add("", "")
+
+	e := b.build()
+	if len(e.Subnets) != 2 || e.Subnets[0] != "1.2.3.0/24" || e.Subnets[1] != "2001:db8::/64" {
+		t.Errorf("Subnets = %v", e.Subnets)
+	}
+	if len(e.Devices) != 2 {
+		t.Errorf("设备名应忽略大小写去重，实际 %v", e.Devices)
+	}
+}
+
+func TestCountOverlaps(t *testing.T) {
+	base := time.Date(2024, 3, 1, 20, 0, 0, 0, time.Local)
+	at := func(minutes int) time.Time { return base.Add(time.Duration(minutes) * time.Minute) }
+	stopped := func(minutes int) *time.Time { t := at(minutes); return &t }
+
+	tests := []struct {
+		name     string
+		sessions []models.PlaybackSession
+		expected int
+	}{
+		{"无重叠", []models.PlaybackSession{
+			{DeviceID: "a", StartedAt: at(0), StoppedAt: stopped(30)},
+			{DeviceID: "b", StartedAt: at(30), StoppedAt: stopped(60)},
+		}, 0},
+		{"同一设备连续播放", []models.PlaybackSession{
+			{DeviceID: "a", StartedAt: at(0), StoppedAt: stopped(30)},
+			{DeviceID: "a", StartedAt: at(10), StoppedAt: stopped(40)},
+		}, 0},
+		{"不同设备重叠", []models.PlaybackSession{
+			{DeviceID: "a", StartedAt: at(0), StoppedAt: stopped(60)},
+			{DeviceID: "b", StartedAt: at(10), StoppedAt: stopped(20)},
+			{DeviceID: "c", StartedAt: at(15), StoppedAt: stopped(90)},
+		}, 3},
+		{"未结束按最近确认时间", []models.PlaybackSession{
+			{DeviceID: "a", StartedAt: at(0), LastSeenAt: at(5)},
+			{DeviceName: "TV", StartedAt: at(3), StoppedAt: stopped(10)},
+			{DeviceName: "Phone", StartedAt: at(8), StoppedAt: stopped(12)},
+		}, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := CountOverlaps(tt.sessions); got != tt.expected {
+				t.Errorf("CountOverlaps() = %d, want %d", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLiveOverlaps(t *testing.T) {
+	sessions := []emby.Session{
+		{ID: "s1", DeviceID: "a", NowPlaying: "Movie"},
+		{ID: "s2", DeviceID: "a", NowPlaying: "Movie"},
+		{ID: "s3", DeviceID: "b", NowPlaying: "Episode"},
+		{ID: "s4", DeviceID: "c"},
+	}
+
+	grouped := groupPlayingSessions(append(sessions, emby.Session{ID: "s5", UserID: "u2", NowPlaying: "x"}))
+	if len(grouped[""]) != 3 || len(grouped["u2"]) != 1 {
+		t.Fatalf("groupPlayingSessions() = %v", grouped)
+	}
+	if got := liveOverlaps(grouped[""]); got != 1 {
+		t.Errorf("liveOverlaps() = %d, want 1", got)
+	}
+	if got := liveOverlaps(nil); got != 0 {
+		t.Errorf("liveOverlaps(nil) = %d, want 0", got)
+	}
+}
+
+func TestFormatSharingDecision(t *testing.T) {
+	cfg := config.SharingConfig{WindowDays: 7, Threshold: 6}
+	d := &models.SharingDecision{
+		TG: 123, Name: "alice", Score: 8, Subnets: 4, Devices: 3, Overlaps: 1,
+		Detail: "网段: 1.2.3.0/24\n设备: TV",
+		Action: models.SharingActionPending,
+	}
+
+	text := FormatSharingDecision(d, cfg)
+	for _, want := range []string{"`alice`", "8.0（阈值 6.0）", "最近 7 天", "设备: TV"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("缺少 %q:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "处理结果") {
+		t.Error("待处理记录不应显示处理结果")
+	}
+
+	// 用户名与设备名来自 Emby，需转义 Markdown
+	d.Name = "a`b"
+	d.Detail = "设备: my_tv*[1]`"
+	text = FormatSharingDecision(d, cfg)
+	for _, want := range []string{"`a'b`", "设备: my\\_tv\\*\\[1]\\`"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("缺少转义后的 %q:\n%s", want, text)
+		}
+	}
+
+	d.Action = models.SharingActionDisable
+	d.AdminID = 42
+	if text := FormatSharingDecision(d, cfg); !strings.Contains(text, "已禁用账户") || !strings.Contains(text, "`42`") {
+		t.Errorf("处理结果显示错误:\n%s", text)
+	}
+}
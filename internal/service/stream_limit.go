@@ -0,0 +1,295 @@
+// Package service 并发播放限制服务
+package service
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// StreamLimitService 并发播放限制服务
+// 按用户等级设置各服务器的 Emby 同时播放数，并巡检所有服务器的活动会话终止超限的播放
+type StreamLimitService struct {
+	embyRepo    *repository.EmbyRepository
+	accountRepo *repository.EmbyAccountRepository
+	embyClient  *emby.Client
+	cfg         *config.Config
+	notifier    notify.Sender
+
+	// 会话首次出现时间（按 服务器/会话 ID 记录），用于先到先得地保留会话
+	firstSeen map[string]time.Time
+	mu        sync.Mutex
+}
+
+// LimitViolation 超限会话
+type LimitViolation struct {
+	Session emby.Session
+	Reason  string
+}
+
+// StreamEnforceResult 巡检结果
+type StreamEnforceResult struct {
+	Sessions   int // 活动会话数
+	Users      int // 检查的用户数
+	Terminated int // 已终止的会话数
+	Failed     int // 终止失败数
+}
+
+// NewStreamLimitService 创建并发播放限制服务
+func NewStreamLimitService() *StreamLimitService {
+	return &StreamLimitService{
+		embyRepo:    repository.NewEmbyRepository(),
+		accountRepo: repository.NewEmbyAccountRepository(),
+		embyClient:  emby.GetClient(),
+		cfg:         config.Get(),
+		notifier:    notify.Get(),
+		firstSeen:   make(map[string]time.Time),
+	}
+}
+
+// ApplyToUser 按用户当前等级设置主服务器及附加服务器账户的 Emby 同时播放数
+func (s *StreamLimitService) ApplyToUser(user *models.Emby) error {
+	if !user.HasEmbyAccount() || user.Lv == models.LevelE {
+		return nil
+	}
+	limit := s.cfg.StreamLimit.ForLevel(string(user.Lv))
+	if err := s.embyClient.SetStreamLimit(*user.EmbyID, limit.Streams); err != nil {
+		return err
+	}
+
+	accounts, err := s.accountRepo.ListByTG(user.TG)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, account := range accounts {
+		if srv := emby.GetServer(account.Server); srv != nil && !srv.IsMain() {
+			if err := srv.Client.SetStreamLimit(account.EmbyID, limit.Streams); err != nil {
+				errs = append(errs, fmt.Errorf("[%s] %w", account.Server, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	logger.Debug().Int64("tg", user.TG).Str("lv", string(user.Lv)).Int("streams", limit.Streams).Msg("已应用并发播放限制")
+	return nil
+}
+
+// ApplyByTG 等级变更后重新应用限制
+func (s *StreamLimitService) ApplyByTG(tgID int64) error {
+	user, err := s.embyRepo.GetByTG(tgID)
+	if err != nil {
+		return err
+	}
+	return s.ApplyToUser(user)
+}
+
+// ApplyAll 为所有有 Emby 账户的用户重新应用限制
+func (s *StreamLimitService) ApplyAll() (*BatchResult, error) {
+	users, err := s.embyRepo.GetActiveUsers()
+	if err != nil {
+		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+	}
+
+	result := &BatchResult{
+		Total:   len(users),
+		Details: make([]string, 0),
+	}
+	for i := range users {
+		user := &users[i]
+		if !user.HasEmbyAccount() || user.Lv == models.LevelE {
+			result.Skipped++
+			continue
+		}
+		if err := s.ApplyToUser(user); err != nil {
+			logger.Warn().Err(err).Int64("tg", user.TG).Msg("应用并发播放限制失败")
+			result.Failed++
+			result.Details = append(result.Details, fmt.Sprintf("失败: %d (%v)", user.TG, err))
+			continue
+		}
+		result.Success++
+	}
+
+	return result, nil
+}
+
+// Enforce 巡检所有服务器的活动会话，终止超出等级限制的会话并通知用户
+// 各服务器分别计数；只有全部服务器都无法获取会话时才返回错误
+func (s *StreamLimitService) Enforce() (*StreamEnforceResult, error) {
+	servers := emby.GetServers()
+	result := &StreamEnforceResult{}
+
+	var errs []error
+	for _, srv := range servers {
+		if err := s.enforceServer(srv, result); err != nil {
+			logger.Warn().Err(err).Str("server", srv.Config.Name).Msg("获取活动会话失败")
+			errs = append(errs, fmt.Errorf("[%s] %w", srv.Config.Name, err))
+		}
+	}
+	if len(servers) > 0 && len(errs) == len(servers) {
+		return nil, errors.Join(errs...)
+	}
+	return result, nil
+}
+
+// enforceServer 巡检单台服务器的活动会话
+func (s *StreamLimitService) enforceServer(srv *emby.Server, result *StreamEnforceResult) error {
+	sessions, err := srv.Client.GetSessions()
+	if err != nil {
+		return err
+	}
+
+	result.Sessions += len(sessions)
+	byUser := s.groupByUser(srv.Config.Name, sessions)
+
+	for embyID, userSessions := range byUser {
+		user, err := s.userOn(srv, embyID)
+		if err != nil || user.Lv == models.LevelE {
+			continue
+		}
+		result.Users++
+
+		violations := SelectOverLimit(userSessions, s.cfg.StreamLimit.ForLevel(string(user.Lv)))
+		if len(violations) == 0 {
+			continue
+		}
+
+		var terminated []LimitViolation
+		for _, v := range violations {
+			if err := srv.Client.TerminateSession(v.Session.ID, v.Reason); err != nil {
+				logger.Warn().Err(err).Str("server", srv.Config.Name).Str("session", v.Session.ID).Msg("终止超限会话失败")
+				result.Failed++
+				continue
+			}
+			result.Terminated++
+			terminated = append(terminated, v)
+		}
+
+		if len(terminated) > 0 {
+			logger.Info().
+				Str("server", srv.Config.Name).
+				Int64("tg", user.TG).
+				Str("lv", string(user.Lv)).
+				Int("terminated", len(terminated)).
+				Msg("已终止超出并发限制的会话")
+			s.notifyUser(user.TG, srv, terminated)
+		}
+	}
+	return nil
+}
+
+// userOn 根据服务器上的 Emby 用户 ID 找到对应用户（附加服务器通过 emby_accounts 关联）
+func (s *StreamLimitService) userOn(srv *emby.Server, embyID string) (*models.Emby, error) {
+	if srv.IsMain() {
+		return s.embyRepo.GetByEmbyID(embyID)
+	}
+	account, err := s.accountRepo.GetByEmbyID(srv.Config.Name, embyID)
+	if err != nil {
+		return nil, err
+	}
+	return s.embyRepo.GetByTG(account.TG)
+}
+
+// groupByUser 按用户分组某台服务器的会话，组内按首次出现时间排序
+func (s *StreamLimitService) groupByUser(server string, sessions []emby.Session) map[string][]emby.Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	prefix := server + "/"
+	alive := make(map[string]bool, len(sessions))
+	byUser := make(map[string][]emby.Session)
+	for _, sess := range sessions {
+		key := prefix + sess.ID
+		alive[key] = true
+		if _, ok := s.firstSeen[key]; !ok {
+			s.firstSeen[key] = now
+		}
+		byUser[sess.UserID] = append(byUser[sess.UserID], sess)
+	}
+	for key := range s.firstSeen {
+		if strings.HasPrefix(key, prefix) && !alive[key] {
+			delete(s.firstSeen, key)
+		}
+	}
+
+	for _, list := range byUser {
+		sort.SliceStable(list, func(i, j int) bool {
+			ti, tj := s.firstSeen[prefix+list[i].ID], s.firstSeen[prefix+list[j].ID]
+			if !ti.Equal(tj) {
+				return ti.Before(tj)
+			}
+			return list[i].LastActivity.Before(list[j].LastActivity)
+		})
+	}
+	return byUser
+}
+
+// notifyUser 通知用户会话已被终止
+func (s *StreamLimitService) notifyUser(tgID int64, srv *emby.Server, violations []LimitViolation) {
+	var sb strings.Builder
+	sb.WriteString("⚠️ **播放会话已被终止**\n\n")
+	if !srv.IsMain() {
+		sb.WriteString(fmt.Sprintf("服务器：`%s`\n", srv.Config.Name))
+	}
+	for _, v := range violations {
+		sb.WriteString(fmt.Sprintf("· %s (%s)：%s\n", v.Session.DeviceName, v.Session.Client, v.Reason))
+	}
+	sb.WriteString("\n请关闭其他设备上的播放后重试")
+
+	msg := &notify.Message{Event: notify.EventStreamLimit, Title: "播放会话已被终止", Text: sb.String()}
+	if err := s.notifier.NotifyUser(tgID, msg); err != nil {
+		logger.Debug().Err(err).Int64("tg", tgID).Msg("发送超限通知失败")
+	}
+}
+
+// SelectOverLimit 按先到先得选出超出限制的会话（sessions 需按先后顺序排列）
+// 超出设备数的新设备上的会话全部终止；已允许设备上的播放超出同时播放数时终止较晚的播放
+func SelectOverLimit(sessions []emby.Session, limit config.LevelLimit) []LimitViolation {
+	var violations []LimitViolation
+	allowedDevices := make(map[string]bool)
+	playing := 0
+
+	for _, sess := range sessions {
+		device := sess.DeviceID
+		if device == "" {
+			device = sess.ID
+		}
+
+		if !allowedDevices[device] {
+			if limit.Devices > 0 && len(allowedDevices) >= limit.Devices {
+				violations = append(violations, LimitViolation{
+					Session: sess,
+					Reason:  fmt.Sprintf("超出设备数限制 (%d)", limit.Devices),
+				})
+				continue
+			}
+			allowedDevices[device] = true
+		}
+
+		if !sess.IsPlaying() {
+			continue
+		}
+		if limit.Streams > 0 && playing >= limit.Streams {
+			violations = append(violations, LimitViolation{
+				Session: sess,
+				Reason:  fmt.Sprintf("超出同时播放数限制 (%d)", limit.Streams),
+			})
+			continue
+		}
+		playing++
+	}
+
+	return violations
+}
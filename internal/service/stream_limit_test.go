@@ -0,0 +1,70 @@
+// Package service 并发播放限制测试
+package service
+
+import (
+	"testing"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+)
+
+func TestSelectOverLimit(t *testing.T) {
+	playing := func(id, device string) emby.Session {
+		return emby.Session{ID: id, DeviceID: device, NowPlaying: "movie"}
+	}
+	idle := func(id, device string) emby.Session {
+		return emby.Session{ID: id, DeviceID: device}
+	}
+
+	tests := []struct {
+		name     string
+		sessions []emby.Session
+		limit    config.LevelLimit
+		want     []string
+	}{
+		{
+			name:     "未超限",
+			sessions: []emby.Session{playing("s1", "d1"), playing("s2", "d2")},
+			limit:    config.LevelLimit{Streams: 2, Devices: 2},
+			want:     nil,
+		},
+		{
+			name:     "超出同时播放数",
+			sessions: []emby.Session{playing("s1", "d1"), playing("s2", "d2"), playing("s3", "d3")},
+			limit:    config.LevelLimit{Streams: 2},
+			want:     []string{"s3"},
+		},
+		{
+			name:     "空闲会话不计入播放数",
+			sessions: []emby.Session{idle("s1", "d1"), playing("s2", "d2")},
+			limit:    config.LevelLimit{Streams: 1},
+			want:     nil,
+		},
+		{
+			name:     "超出设备数",
+			sessions: []emby.Session{idle("s1", "d1"), playing("s2", "d2"), playing("s3", "d1")},
+			limit:    config.LevelLimit{Devices: 1},
+			want:     []string{"s2"},
+		},
+		{
+			name:     "不限制",
+			sessions: []emby.Session{playing("s1", "d1"), playing("s2", "d2"), playing("s3", "d3")},
+			limit:    config.LevelLimit{},
+			want:     nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SelectOverLimit(tt.sessions, tt.limit)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d violations, want %d", len(got), len(tt.want))
+			}
+			for i, v := range got {
+				if v.Session.ID != tt.want[i] {
+					t.Errorf("violation[%d] = %s, want %s", i, v.Session.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
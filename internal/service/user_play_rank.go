@@ -2,6 +2,7 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"math"
 	"strings"
@@ -10,6 +11,7 @@ import (
 	tele "gopkg.in/telebot.v3"
 
 	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
 	"github.com/smysle/sakura-embyboss-go/pkg/logger"
@@ -82,7 +84,11 @@ func (s *UserPlayRankService) GetUserPlayRank(days int) (*UserPlayRankResult, er
 	startDate := endDate.AddDate(0, 0, -days)
 
 	// 获取用户播放统计
-	stats, err := s.embyClient.GetAllUsersPlaybackStats(startDate, endDate)
+	stats, _, err := s.embyClient.GetAllUsersPlaybackStats(startDate, endDate)
+	if errors.Is(err, emby.ErrNoPlaybackData) {
+		logger.Info().Err(err).Int("days", days).Msg("暂无用户播放数据")
+		stats, err = nil, nil
+	}
 	if err != nil {
 		logger.Error().Err(err).Int("days", days).Msg("获取用户播放统计失败")
 		return nil, fmt.Errorf("获取用户播放统计失败: %v", err)
@@ -169,33 +175,28 @@ func (s *UserPlayRankService) GetUserPlayRank(days int) (*UserPlayRankResult, er
 // AwardPoints 发放积分奖励
 func (s *UserPlayRankService) AwardPoints(entries []RankEntry) ([]RankEntry, error) {
 	var awarded []RankEntry
-	var updates []struct {
-		TG int64
-		IV int
-	}
 
+	pointsSvc := NewPointsService()
+	refID := fmt.Sprintf("play_rank:%s", time.Now().Format("2006-01-02"))
 	for _, entry := range entries {
-		if entry.TelegramID > 0 && entry.Points > 0 {
-			updates = append(updates, struct {
-				TG int64
-				IV int
-			}{
-				TG: entry.TelegramID,
-				IV: entry.NewTotal,
-			})
-			awarded = append(awarded, entry)
+		if entry.TelegramID <= 0 || entry.Points <= 0 {
+			continue
 		}
-	}
 
-	if len(updates) == 0 {
-		return awarded, nil
-	}
-
-	// 批量更新数据库
-	for _, u := range updates {
-		if err := s.embyRepo.UpdateFields(u.TG, map[string]interface{}{"iv": u.IV}); err != nil {
-			logger.Error().Err(err).Int64("tg", u.TG).Msg("更新用户积分失败")
+		ledger, err := pointsSvc.Credit(&PointsChange{
+			TG:       entry.TelegramID,
+			Currency: models.CurrencyCoin,
+			Amount:   entry.Points,
+			Reason:   models.LedgerReasonPlayRank,
+			RefID:    refID,
+		})
+		if err != nil {
+			logger.Error().Err(err).Int64("tg", entry.TelegramID).Msg("更新用户积分失败")
+			continue
 		}
+
+		entry.NewTotal = ledger.Balance
+		awarded = append(awarded, entry)
 	}
 
 	logger.Info().Int("count", len(awarded)).Msg("成功发放播放榜积分奖励")
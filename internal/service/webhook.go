@@ -0,0 +1,319 @@
+// Package service Emby Webhook 事件处理服务
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/notify"
+	"github.com/smysle/sakura-embyboss-go/pkg/logger"
+	"github.com/smysle/sakura-embyboss-go/pkg/utils"
+)
+
+// WebhookService Emby Webhook 事件处理服务
+// 处理流程：确定来源服务器 → 关联 TG 用户 → 客户端黑名单检测 → 终止会话/禁用用户 → 入库 → 更新本地播放记录 → 通知管理员
+type WebhookService struct {
+	eventRepo   *repository.PlaybackEventRepository
+	embyRepo    *repository.EmbyRepository
+	accountRepo *repository.EmbyAccountRepository
+	cfg         *config.Config
+	notifier    notify.Sender
+	history     *PlaybackHistoryService
+}
+
+// ErrUnknownServer Webhook 来源服务器未配置
+var ErrUnknownServer = errors.New("未知的服务器")
+
+// NewWebhookService 创建 Webhook 事件处理服务
+func NewWebhookService() *WebhookService {
+	return &WebhookService{
+		eventRepo:   repository.NewPlaybackEventRepository(),
+		embyRepo:    repository.NewEmbyRepository(),
+		accountRepo: repository.NewEmbyAccountRepository(),
+		cfg:         config.Get(),
+		notifier:    notify.Get(),
+		history:     NewPlaybackHistoryService(),
+	}
+}
+
+// HandleEvent 处理一条 Webhook 事件，event.Server 为来源服务器名称（为空表示主服务器）
+func (s *WebhookService) HandleEvent(event *models.PlaybackEvent) error {
+	srv := emby.GetServer(event.Server)
+	if srv == nil {
+		return fmt.Errorf("%w: %s", ErrUnknownServer, event.Server)
+	}
+	event.Server = srv.Config.Name
+
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	// 关联 TG 用户
+	s.resolveUser(srv, event)
+
+	// 客户端黑名单检测（登录与开始播放时检测）
+	if event.Event == models.EventPlaybackStart || event.Event == models.EventUserAuthenticated {
+		if rule, ok := s.blockedClients(srv).Match(event.Client, event.DeviceName); ok {
+			event.Filtered = true
+			event.MatchedRule = rule
+			event.Action = s.enforce(srv, event)
+		}
+	}
+
+	if err := s.eventRepo.Create(event); err != nil {
+		logger.Error().Err(err).Str("event", event.Event).Msg("保存 Webhook 事件失败")
+		return fmt.Errorf("保存事件失败: %w", err)
+	}
+
+	// 更新本地播放记录（播放记录与排行只统计主服务器）
+	if srv.IsMain() && s.history.Enabled() {
+		if err := s.history.RecordEvent(event); err != nil {
+			logger.Warn().Err(err).Str("event", event.Event).Msg("更新本地播放记录失败")
+		}
+	}
+
+	logger.Info().
+		Str("server", event.Server).
+		Str("event", event.Event).
+		Str("user", event.DisplayUser()).
+		Str("item", event.ItemName).
+		Str("client", event.Client).
+		Str("ip", event.ClientIP).
+		Bool("filtered", event.Filtered).
+		Msg("处理 Emby Webhook 事件")
+
+	if event.Filtered || shouldNotify(srv.Config.WebhookNotifyEvents, event.Event) {
+		s.notifyAdmins(event)
+	}
+
+	return nil
+}
+
+// resolveUser 根据 Emby 用户 ID / 用户名关联 TG 用户
+// 主服务器查 emby 表，附加服务器查 emby_accounts 表
+func (s *WebhookService) resolveUser(srv *emby.Server, event *models.PlaybackEvent) {
+	if !srv.IsMain() {
+		var account *models.EmbyAccount
+		var err error
+		if event.EmbyUserID != "" {
+			account, err = s.accountRepo.GetByEmbyID(srv.Config.Name, event.EmbyUserID)
+		}
+		if account == nil && event.UserName != "" {
+			account, err = s.accountRepo.GetByName(srv.Config.Name, event.UserName)
+		}
+		if err != nil || account == nil {
+			return
+		}
+		event.TG = account.TG
+		if event.EmbyUserID == "" {
+			event.EmbyUserID = account.EmbyID
+		}
+		return
+	}
+
+	var user *models.Emby
+	var err error
+	if event.EmbyUserID != "" {
+		user, err = s.embyRepo.GetByEmbyID(event.EmbyUserID)
+	}
+	if user == nil && event.UserName != "" {
+		user, err = s.embyRepo.GetByName(event.UserName)
+	}
+	if err != nil || user == nil {
+		return
+	}
+
+	event.TG = user.TG
+	if event.EmbyUserID == "" && user.EmbyID != nil {
+		event.EmbyUserID = *user.EmbyID
+	}
+}
+
+// enforce 对命中黑名单的会话执行处理动作（使用来源服务器的配置与客户端）
+func (s *WebhookService) enforce(srv *emby.Server, event *models.PlaybackEvent) string {
+	action := models.EventActionFlagged
+	reason := fmt.Sprintf("客户端 %s 已被禁止使用", event.Client)
+
+	if srv.Config.TerminateOnFilter && event.SessionID != "" {
+		if err := srv.Client.TerminateSession(event.SessionID, reason); err != nil {
+			logger.Warn().Err(err).Str("server", srv.Config.Name).Str("session", event.SessionID).Msg("终止违规会话失败")
+		} else {
+			action = models.EventActionTerminated
+		}
+	}
+
+	if srv.Config.BlockUserOnFilter && event.EmbyUserID != "" {
+		if err := srv.Client.DisableUser(event.EmbyUserID); err != nil {
+			logger.Warn().Err(err).Str("server", srv.Config.Name).Str("emby_id", event.EmbyUserID).Msg("禁用违规用户失败")
+		} else {
+			action = models.EventActionBlocked
+			if event.TG != 0 {
+				s.blockUser(event.TG)
+			}
+		}
+	}
+
+	logger.Warn().
+		Str("user", event.DisplayUser()).
+		Str("client", event.Client).
+		Str("device", event.DeviceName).
+		Str("rule", event.MatchedRule).
+		Str("action", action).
+		Msg("检测到被禁止的客户端")
+
+	return action
+}
+
+// blockUser 封禁违规用户：禁用主服务器账户并同步禁用所有附加服务器账户
+func (s *WebhookService) blockUser(tgID int64) {
+	user, err := s.embyRepo.GetByTG(tgID)
+	if err != nil {
+		return
+	}
+	if user.HasEmbyAccount() {
+		if err := emby.GetClient().DisableUser(*user.EmbyID); err != nil {
+			logger.Warn().Err(err).Int64("tg", tgID).Msg("禁用违规用户主服务器账户失败")
+		}
+	}
+	if err := s.embyRepo.UpdateFields(tgID, map[string]interface{}{"lv": models.LevelE}); err != nil {
+		logger.Warn().Err(err).Int64("tg", tgID).Msg("更新违规用户等级失败")
+	}
+	DisableServers(tgID)
+}
+
+// shouldNotify 判断事件类型是否需要转发给管理员
+func shouldNotify(events []string, eventType string) bool {
+	for _, e := range events {
+		if e == eventType || e == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyAdmins 通知 Owner 和管理员
+func (s *WebhookService) notifyAdmins(event *models.PlaybackEvent) {
+	msg := &notify.Message{
+		Event: notify.EventEmbyWebhook,
+		Title: "Emby 事件 " + event.Event,
+		Text:  FormatWebhookEvent(event),
+	}
+	if err := s.notifier.NotifyAdmins(msg); err != nil {
+		logger.Warn().Err(err).Str("event", event.Event).Msg("发送 Webhook 通知失败")
+	}
+}
+
+// FormatWebhookEvent 格式化事件通知文本
+func FormatWebhookEvent(event *models.PlaybackEvent) string {
+	var sb strings.Builder
+
+	if event.Filtered {
+		sb.WriteString("🚨 **检测到被禁止的客户端**\n\n")
+	} else {
+		sb.WriteString(fmt.Sprintf("📡 **Emby 事件** %s\n\n", utils.MarkdownCode(event.Event)))
+	}
+
+	if event.Server != "" {
+		sb.WriteString(fmt.Sprintf("· 服务器 | %s\n", utils.MarkdownCode(event.Server)))
+	}
+	sb.WriteString(fmt.Sprintf("· 用户 | %s", utils.MarkdownCode(event.DisplayUser())))
+	if event.TG != 0 {
+		sb.WriteString(fmt.Sprintf(" ([TG](tg://user?id=%d))", event.TG))
+	}
+	sb.WriteString("\n")
+	if event.ItemName != "" {
+		sb.WriteString(fmt.Sprintf("· 媒体 | %s\n", utils.EscapeMarkdown(event.ItemName)))
+	}
+	sb.WriteString(fmt.Sprintf("· 客户端 | %s\n", utils.EscapeMarkdown(event.Client)))
+	sb.WriteString(fmt.Sprintf("· 设备 | %s\n", utils.EscapeMarkdown(event.DeviceName)))
+	if event.ClientIP != "" {
+		sb.WriteString(fmt.Sprintf("· IP | %s\n", utils.MarkdownCode(event.ClientIP)))
+	}
+
+	if event.Filtered {
+		sb.WriteString(fmt.Sprintf("· 规则 | %s\n", utils.MarkdownCode(event.MatchedRule)))
+		switch event.Action {
+		case models.EventActionBlocked:
+			sb.WriteString("\n⛔ 已终止会话并禁用该用户")
+		case models.EventActionTerminated:
+			sb.WriteString("\n✂️ 已终止该会话")
+		default:
+			sb.WriteString("\n⚠️ 未配置自动处理，请手动检查")
+		}
+	}
+
+	return sb.String()
+}
+
+// BlockedClientMatcher 预编译的客户端黑名单规则（正则，不区分大小写）
+type BlockedClientMatcher struct {
+	patterns []string
+	rules    []*regexp.Regexp
+}
+
+// CompileBlockedClients 编译黑名单规则，无效规则记录日志后跳过
+func CompileBlockedClients(patterns []string) *BlockedClientMatcher {
+	m := &BlockedClientMatcher{}
+	for _, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			logger.Warn().Err(err).Str("pattern", pattern).Msg("无效的客户端黑名单规则")
+			continue
+		}
+		m.patterns = append(m.patterns, pattern)
+		m.rules = append(m.rules, re)
+	}
+	return m
+}
+
+// Match 检查客户端或设备名是否命中黑名单规则，返回命中的规则
+func (m *BlockedClientMatcher) Match(values ...string) (string, bool) {
+	for i, re := range m.rules {
+		for _, v := range values {
+			if v != "" && re.MatchString(v) {
+				return m.patterns[i], true
+			}
+		}
+	}
+	return "", false
+}
+
+// blockedClientCache 按服务器缓存编译后的黑名单规则，重新加载配置后重新编译
+var blockedClientCache struct {
+	sync.Mutex
+	cfg      *config.Config
+	matchers map[string]*BlockedClientMatcher
+}
+
+// blockedClients 获取服务器的黑名单规则，每次加载配置只编译一次
+func (s *WebhookService) blockedClients(srv *emby.Server) *BlockedClientMatcher {
+	blockedClientCache.Lock()
+	defer blockedClientCache.Unlock()
+
+	if blockedClientCache.cfg != s.cfg {
+		blockedClientCache.cfg = s.cfg
+		blockedClientCache.matchers = make(map[string]*BlockedClientMatcher)
+	}
+	name := srv.Config.Name
+	m, ok := blockedClientCache.matchers[name]
+	if !ok {
+		patterns := srv.Config.BlockedClients
+		if sc := s.cfg.Emby.Get(name); sc != nil {
+			patterns = sc.BlockedClients
+		}
+		m = CompileBlockedClients(patterns)
+		blockedClientCache.matchers[name] = m
+	}
+	return m
+}
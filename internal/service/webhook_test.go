@@ -0,0 +1,55 @@
+// Package service Webhook 事件处理测试
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+)
+
+func TestBlockedClientMatcher(t *testing.T) {
+	m := CompileBlockedClients([]string{".*curl.*", ".*python.*", "", "([invalid"})
+
+	tests := []struct {
+		name     string
+		values   []string
+		wantRule string
+		wantOK   bool
+	}{
+		{"命中客户端", []string{"curl/8.0", "Linux"}, ".*curl.*", true},
+		{"命中设备名且不区分大小写", []string{"Emby Web", "Python-Requests"}, ".*python.*", true},
+		{"正常客户端", []string{"Emby Web", "Chrome"}, "", false},
+		{"空值", []string{"", ""}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, ok := m.Match(tt.values...)
+			if ok != tt.wantOK || rule != tt.wantRule {
+				t.Errorf("Match() = (%q, %v), want (%q, %v)", rule, ok, tt.wantRule, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestFormatWebhookEventEscapes(t *testing.T) {
+	event := &models.PlaybackEvent{
+		Event:       models.EventPlaybackStart,
+		Server:      "main",
+		UserName:    "bob_1",
+		ItemName:    "Star_Wars *Special*",
+		Client:      "curl_client",
+		DeviceName:  "my_box",
+		Filtered:    true,
+		MatchedRule: "`curl`_.*",
+		Action:      models.EventActionTerminated,
+	}
+
+	text := FormatWebhookEvent(event)
+	for _, want := range []string{"`bob_1`", `Star\_Wars \*Special\*`, `curl\_client`, `my\_box`, "`'curl'_.*`"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("通知缺少 %q:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,550 @@
+// Package web 管理员 API（对应 Telegram 管理命令）
+package web
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+	pkglogger "github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// 分页参数
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// 与 EmbyRepository.ListWithPagination / CodeRepository.ListWithPagination 一致的过滤条件
+var (
+	userFilters = []string{"whitelist", "banned", "with_emby", "expired"}
+	codeFilters = []string{"used", "unused"}
+)
+
+// registerAdminRoutes 注册管理员路由 /api/v1/admin
+func (s *Server) registerAdminRoutes(v1 fiber.Router) {
+	admin := v1.Group("/admin", s.requireScope(config.ScopeAdmin))
+
+	// 用户管理
+	admin.Get("/users", s.adminListUsers)                // /kk 列表
+	admin.Post("/users/renew", s.adminRenewAll)          // /renewall
+	admin.Get("/users/:id", s.adminGetUser)              // /kk
+	admin.Delete("/users/:id", s.adminDeleteUser)        // /rmemby
+	admin.Post("/users/:id/renew", s.adminRenewUser)     // /renew
+	admin.Post("/users/:id/score", s.adminScore)         // /score
+	admin.Put("/users/:id/whitelist", s.adminProUser)    // /prouser
+	admin.Delete("/users/:id/whitelist", s.adminRevUser) // /revuser
+	admin.Put("/users/:id/ban", s.adminBanUser)          // 用户面板禁用
+	admin.Delete("/users/:id/ban", s.adminUnbanUser)     // 用户面板解禁
+
+	// 注册码管理
+	admin.Get("/codes", s.adminListCodes)     // 注册码列表
+	admin.Post("/codes", s.adminCreateCodes)  // /code
+	admin.Delete("/codes", s.adminDeleteCode) // /delcode
+}
+
+// PageResponse 分页响应
+type PageResponse struct {
+	Items    interface{} `json:"items"`
+	Total    int64       `json:"total"`
+	Page     int         `json:"page"`
+	PageSize int         `json:"page_size"`
+}
+
+// AdminUserResponse 管理员视角的用户信息
+type AdminUserResponse struct {
+	TG          int64   `json:"tg"`
+	Name        *string `json:"name"`
+	EmbyID      *string `json:"emby_id"`
+	Level       string  `json:"level"`
+	LevelName   string  `json:"level_name"`
+	Score       int     `json:"score"`
+	Coins       int     `json:"coins"`
+	CreatedAt   *string `json:"created_at"`
+	ExpiryAt    *string `json:"expiry_at"`
+	CheckinAt   *string `json:"checkin_at"`
+	CheckinDays int     `json:"checkin_days"`
+	IsExpired   bool    `json:"is_expired"`
+	Status      string  `json:"status"`
+}
+
+// newAdminUserResponse 转换用户信息（不包含密码等敏感字段）
+func newAdminUserResponse(user *models.Emby) AdminUserResponse {
+	return AdminUserResponse{
+		TG:          user.TG,
+		Name:        user.Name,
+		EmbyID:      user.EmbyID,
+		Level:       string(user.Lv),
+		LevelName:   user.GetLevelName(),
+		Score:       user.Us,
+		Coins:       user.Iv,
+		CreatedAt:   formatTime(user.Cr),
+		ExpiryAt:    formatTime(user.Ex),
+		CheckinAt:   formatTime(user.Ch),
+		CheckinDays: user.Ck,
+		IsExpired:   user.IsExpired(),
+		Status:      string(user.CurrentStatus()),
+	}
+}
+
+// adminListUsers 分页获取用户列表
+// GET /api/v1/admin/users?page=1&page_size=20&filter=whitelist|banned|with_emby|expired
+func (s *Server) adminListUsers(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	filter, ok := parseFilter(c.Query("filter"), userFilters)
+	if !ok {
+		return badRequest(c, "无效的过滤条件")
+	}
+
+	users, total, err := repository.NewEmbyRepository().ListWithPagination(page, pageSize, filter)
+	if err != nil {
+		pkglogger.Error().Err(err).Msg("【API服务】获取用户列表失败")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "获取用户列表失败",
+		})
+	}
+
+	items := make([]AdminUserResponse, 0, len(users))
+	for i := range users {
+		items = append(items, newAdminUserResponse(&users[i]))
+	}
+
+	return c.JSON(PageResponse{
+		Items:    items,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// adminGetUser 查询用户（TG ID 或 Emby 用户名）
+// GET /api/v1/admin/users/:id
+func (s *Server) adminGetUser(c *fiber.Ctx) error {
+	user, err := s.users.Find(c.Params("id"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return c.JSON(newAdminUserResponse(user))
+}
+
+// renewRequest 续期请求
+type renewRequest struct {
+	Days  int    `json:"days"`
+	Level string `json:"level"` // 仅批量续期使用
+}
+
+// adminRenewUser 为用户续期
+// POST /api/v1/admin/users/:id/renew {"days": 30}
+func (s *Server) adminRenewUser(c *fiber.Ctx) error {
+	var req renewRequest
+	if err := c.BodyParser(&req); err != nil || req.Days == 0 {
+		return badRequest(c, "无效的天数")
+	}
+
+	user, err := s.users.Find(c.Params("id"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := s.users.Renew(user.TG, req.Days); err != nil {
+		pkglogger.Error().Err(err).Int64("tg", user.TG).Msg("【API服务】续期失败")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "更新到期时间失败",
+		})
+	}
+
+	s.auditLog(c, "renew").Int64("tg", user.TG).Int("days", req.Days).Msg("【API服务】管理操作")
+	return s.respondUser(c, user.TG)
+}
+
+// adminRenewAll 批量续期
+// POST /api/v1/admin/users/renew {"days": 30, "level": "b"}
+func (s *Server) adminRenewAll(c *fiber.Ctx) error {
+	var req renewRequest
+	if err := c.BodyParser(&req); err != nil || req.Days <= 0 {
+		return badRequest(c, "请输入有效的天数")
+	}
+
+	level := models.UserLevel(strings.ToLower(req.Level))
+	result, err := service.NewBatchService().RenewAll(req.Days, level)
+	if err != nil {
+		pkglogger.Error().Err(err).Msg("【API服务】批量续期失败")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+
+	s.auditLog(c, "renewall").Int("days", req.Days).Str("level", string(level)).Int("success", result.Success).Msg("【API服务】管理操作")
+	return c.JSON(fiber.Map{
+		"total":   result.Total,
+		"success": result.Success,
+		"failed":  result.Failed,
+		"skipped": result.Skipped,
+	})
+}
+
+// scoreRequest 积分调整请求
+type scoreRequest struct {
+	Amount   int    `json:"amount"`   // 正数增加，负数扣减（最多扣到 0）
+	Currency string `json:"currency"` // us（积分，默认）或 iv（花币）
+}
+
+// adminScore 调整用户积分/花币
+// POST /api/v1/admin/users/:id/score {"amount": -10, "currency": "us"}
+func (s *Server) adminScore(c *fiber.Ctx) error {
+	var req scoreRequest
+	if err := c.BodyParser(&req); err != nil || req.Amount == 0 {
+		return badRequest(c, "积分变动不能为 0")
+	}
+
+	currency := models.CurrencyScore
+	switch req.Currency {
+	case "", string(models.CurrencyScore):
+	case string(models.CurrencyCoin):
+		currency = models.CurrencyCoin
+	default:
+		return badRequest(c, "无效的币种")
+	}
+
+	user, err := s.users.Find(c.Params("id"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	balance := user.Us
+	if currency == models.CurrencyCoin {
+		balance = user.Iv
+	}
+
+	change := &service.PointsChange{
+		TG:       user.TG,
+		Currency: currency,
+		Amount:   req.Amount,
+		Reason:   models.LedgerReasonAdmin,
+		RefID:    "api:" + tokenName(c),
+	}
+	var entry *models.PointsLedger
+	if req.Amount > 0 {
+		entry, err = s.users.Credit(change)
+	} else {
+		// 扣减时最多扣到 0，与 /score 一致
+		change.Amount = -req.Amount
+		entry, err = s.users.DebitUpTo(change)
+	}
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
+	}
+	newBalance := balance
+	if entry != nil {
+		balance, newBalance = entry.Balance-entry.Amount, entry.Balance
+	}
+
+	s.auditLog(c, "score").Int64("tg", user.TG).Str("currency", string(currency)).Int("amount", req.Amount).Msg("【API服务】管理操作")
+	return c.JSON(fiber.Map{
+		"tg":       user.TG,
+		"currency": currency,
+		"before":   balance,
+		"after":    newBalance,
+	})
+}
+
+// adminProUser 设为白名单
+// PUT /api/v1/admin/users/:id/whitelist
+func (s *Server) adminProUser(c *fiber.Ctx) error {
+	return s.setUserLevel(c, models.LevelA, "prouser")
+}
+
+// adminRevUser 取消白名单
+// DELETE /api/v1/admin/users/:id/whitelist
+func (s *Server) adminRevUser(c *fiber.Ctx) error {
+	return s.setUserLevel(c, models.LevelD, "revuser")
+}
+
+// setUserLevel 修改用户等级
+func (s *Server) setUserLevel(c *fiber.Ctx, level models.UserLevel, action string) error {
+	user, err := s.users.Find(c.Params("id"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	repo := repository.NewEmbyRepository()
+	if err := repo.UpdateFields(user.TG, map[string]interface{}{"lv": level}); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "更新用户等级失败",
+		})
+	}
+	if err := service.NewStreamLimitService().ApplyByTG(user.TG); err != nil {
+		pkglogger.Warn().Err(err).Int64("tg", user.TG).Msg("【API服务】同步并发播放限制失败")
+	}
+	service.SyncServers(user.TG)
+
+	s.auditLog(c, action).Int64("tg", user.TG).Msg("【API服务】管理操作")
+	return s.respondUser(c, user.TG)
+}
+
+// adminBanUser 禁用用户的 Emby 账户
+// PUT /api/v1/admin/users/:id/ban
+func (s *Server) adminBanUser(c *fiber.Ctx) error {
+	return s.setUserBanned(c, true, "ban")
+}
+
+// adminUnbanUser 解禁用户的 Emby 账户
+// DELETE /api/v1/admin/users/:id/ban
+func (s *Server) adminUnbanUser(c *fiber.Ctx) error {
+	return s.setUserBanned(c, false, "unban")
+}
+
+// setUserBanned 禁用或解禁用户
+func (s *Server) setUserBanned(c *fiber.Ctx, banned bool, action string) error {
+	user, err := s.users.Find(c.Params("id"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if err := s.users.SetBanned(user, banned); err != nil {
+		if errors.Is(err, errNoEmbyAccount) {
+			return badRequest(c, err.Error())
+		}
+		pkglogger.Error().Err(err).Int64("tg", user.TG).Str("action", action).Msg("【API服务】修改封禁状态失败")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "修改封禁状态失败",
+		})
+	}
+
+	s.auditLog(c, action).Int64("tg", user.TG).Msg("【API服务】管理操作")
+	return s.respondUser(c, user.TG)
+}
+
+// adminDeleteUser 删除用户（Emby 账户与数据库记录）
+// DELETE /api/v1/admin/users/:id
+func (s *Server) adminDeleteUser(c *fiber.Ctx) error {
+	user, err := s.users.Find(c.Params("id"))
+	if err != nil {
+		return userLookupError(c, err)
+	}
+
+	if user.HasEmbyAccount() {
+		if client := emby.GetClient(); client != nil {
+			if err := client.DeleteUser(*user.EmbyID); err != nil {
+				pkglogger.Warn().Err(err).Str("embyID", *user.EmbyID).Msg("【API服务】删除 Emby 账户失败")
+			}
+		}
+	}
+	service.DeleteServerAccounts(user.TG)
+
+	if err := repository.NewEmbyRepository().Delete(user.TG); err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "删除数据库记录失败",
+		})
+	}
+
+	s.auditLog(c, "rmemby").Int64("tg", user.TG).Msg("【API服务】管理操作")
+	return c.JSON(fiber.Map{
+		"deleted": user.TG,
+	})
+}
+
+// adminListCodes 分页获取注册码列表
+// GET /api/v1/admin/codes?page=1&page_size=20&filter=used|unused
+func (s *Server) adminListCodes(c *fiber.Ctx) error {
+	page, pageSize := parsePagination(c)
+	filter, ok := parseFilter(c.Query("filter"), codeFilters)
+	if !ok {
+		return badRequest(c, "无效的过滤条件")
+	}
+
+	codes, total, err := repository.NewCodeRepository().ListWithPagination(page, pageSize, filter)
+	if err != nil {
+		pkglogger.Error().Err(err).Msg("【API服务】获取注册码列表失败")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "获取注册码列表失败",
+		})
+	}
+	if codes == nil {
+		codes = []repository.CodeInfo{}
+	}
+
+	return c.JSON(PageResponse{
+		Items:    codes,
+		Total:    total,
+		Page:     page,
+		PageSize: pageSize,
+	})
+}
+
+// createCodesRequest 生成注册码请求
+type createCodesRequest struct {
+	Days      int        `json:"days"`
+	Count     int        `json:"count"`
+	Label     string     `json:"label"`
+	MaxUses   int        `json:"max_uses"`
+	Level     string     `json:"level"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// adminCreateCodes 生成注册码
+// POST /api/v1/admin/codes {"days": 30, "count": 5, "label": "活动", "max_uses": 1, "level": "b", "expires_at": "2024-12-31T23:59:59+08:00"}
+func (s *Server) adminCreateCodes(c *fiber.Ctx) error {
+	var req createCodesRequest
+	if err := c.BodyParser(&req); err != nil {
+		return badRequest(c, "无效的请求参数")
+	}
+	if req.Count == 0 {
+		req.Count = 1
+	}
+
+	// API 生成的注册码记在 Owner 名下
+	result, err := service.NewCodeService().GenerateBatch(service.CodeBatchOptions{
+		CreatedBy: config.Get().Owner,
+		Days:      req.Days,
+		Count:     req.Count,
+		Label:     req.Label,
+		MaxUses:   req.MaxUses,
+		Level:     req.Level,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		return badRequest(c, err.Error())
+	}
+
+	s.auditLog(c, "code").Uint("batch", result.BatchID).Int("days", req.Days).Int("count", result.Count).Msg("【API服务】管理操作")
+	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+		"codes":      result.Codes,
+		"count":      result.Count,
+		"days":       result.Days,
+		"batch_id":   result.BatchID,
+		"label":      result.Label,
+		"max_uses":   result.MaxUses,
+		"level":      result.Level,
+		"expires_at": result.ExpiresAt,
+	})
+}
+
+// adminDeleteCode 删除未使用的注册码
+// DELETE /api/v1/admin/codes?days=30,90 或 ?days=all
+func (s *Server) adminDeleteCode(c *fiber.Ctx) error {
+	raw := strings.TrimSpace(c.Query("days"))
+	if raw == "" {
+		return badRequest(c, "请指定 days 参数（all 或逗号分隔的天数）")
+	}
+
+	var days []int
+	if raw != "all" {
+		for _, ds := range strings.Split(raw, ",") {
+			d, err := strconv.Atoi(strings.TrimSpace(ds))
+			if err == nil && d > 0 {
+				days = append(days, d)
+			}
+		}
+		if len(days) == 0 {
+			return badRequest(c, "无效的天数参数")
+		}
+	}
+
+	deleted, err := service.NewCodeService().DeleteUnusedCodes(days, nil)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "删除失败: " + err.Error(),
+		})
+	}
+
+	s.auditLog(c, "delcode").Str("days", raw).Int64("deleted", deleted).Msg("【API服务】管理操作")
+	return c.JSON(fiber.Map{
+		"deleted": deleted,
+	})
+}
+
+// respondUser 返回用户最新信息
+func (s *Server) respondUser(c *fiber.Ctx, tgID int64) error {
+	user, err := s.users.Get(tgID)
+	if err != nil {
+		return userLookupError(c, err)
+	}
+	return c.JSON(newAdminUserResponse(user))
+}
+
+// auditLog 记录管理员 API 操作
+func (s *Server) auditLog(c *fiber.Ctx, action string) *zerolog.Event {
+	return pkglogger.Info().
+		Str("token", tokenName(c)).
+		Str("ip", c.IP()).
+		Str("action", action)
+}
+
+// userLookupError 用户查找失败的响应
+func userLookupError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "用户不存在",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error": "查询用户失败",
+	})
+}
+
+// parsePagination 解析分页参数
+func parsePagination(c *fiber.Ctx) (int, int) {
+	page := c.QueryInt("page", 1)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := c.QueryInt("page_size", defaultPageSize)
+	if pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+	return page, pageSize
+}
+
+// parseFilter 校验过滤条件，空值或 all 表示不过滤
+func parseFilter(filter string, allowed []string) (string, bool) {
+	filter = strings.ToLower(strings.TrimSpace(filter))
+	if filter == "" || filter == "all" {
+		return "", true
+	}
+	for _, f := range allowed {
+		if f == filter {
+			return filter, true
+		}
+	}
+	return "", false
+}
+
+// badRequest 返回 400
+func badRequest(c *fiber.Ctx, msg string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error": msg,
+	})
+}
+
+// tokenName 当前请求使用的令牌名称
+func tokenName(c *fiber.Ctx) string {
+	if name, ok := c.Locals(localsToken).(string); ok {
+		return name
+	}
+	return ""
+}
+
+// formatTime 格式化可空时间
+func formatTime(t *time.Time) *string {
+	if t == nil {
+		return nil
+	}
+	s := t.Format("2006-01-02 15:04:05")
+	return &s
+}
@@ -0,0 +1,312 @@
+// Package web 管理员 API 测试
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+)
+
+func TestParseFilter(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter string
+		want   string
+		ok     bool
+	}{
+		{"空值", "", "", true},
+		{"全部", "all", "", true},
+		{"白名单", "whitelist", "whitelist", true},
+		{"大小写", " Expired ", "expired", true},
+		{"非法值", "lv = 'a'", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := parseFilter(tt.filter, userFilters)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("parseFilter(%q) = (%q, %v), want (%q, %v)", tt.filter, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		page, pageSize := parsePagination(c)
+		return c.SendString(fmt.Sprintf("%d/%d", page, pageSize))
+	})
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"默认值", "", "1/20"},
+		{"指定分页", "?page=3&page_size=50", "3/50"},
+		{"页码非法", "?page=0", "1/20"},
+		{"超出上限", "?page_size=1000", "1/100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", "/"+tt.query, nil))
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if string(body) != tt.want {
+				t.Errorf("got %s, want %s", body, tt.want)
+			}
+		})
+	}
+}
+
+// fakeAdminUsers 内存中的用户操作
+type fakeAdminUsers struct {
+	users   map[int64]*models.Emby
+	renewed map[int64]int
+}
+
+func (f *fakeAdminUsers) Find(target string) (*models.Emby, error) {
+	if tgID, err := strconv.ParseInt(target, 10, 64); err == nil {
+		return f.Get(tgID)
+	}
+	for _, u := range f.users {
+		if u.Name != nil && *u.Name == target {
+			return u, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAdminUsers) Get(tgID int64) (*models.Emby, error) {
+	if u, ok := f.users[tgID]; ok {
+		return u, nil
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (f *fakeAdminUsers) Renew(tgID int64, days int) error {
+	f.renewed[tgID] += days
+	return nil
+}
+
+func (f *fakeAdminUsers) SetBanned(user *models.Emby, banned bool) error {
+	if !user.HasEmbyAccount() {
+		return errNoEmbyAccount
+	}
+	user.Lv = models.LevelB
+	if banned {
+		user.Lv = models.LevelE
+	}
+	return nil
+}
+
+func (f *fakeAdminUsers) balance(change *service.PointsChange) *int {
+	u := f.users[change.TG]
+	if change.Currency == models.CurrencyCoin {
+		return &u.Iv
+	}
+	return &u.Us
+}
+
+func (f *fakeAdminUsers) Credit(change *service.PointsChange) (*models.PointsLedger, error) {
+	b := f.balance(change)
+	*b += change.Amount
+	return &models.PointsLedger{TG: change.TG, Currency: change.Currency, Amount: change.Amount, Balance: *b}, nil
+}
+
+func (f *fakeAdminUsers) DebitUpTo(change *service.PointsChange) (*models.PointsLedger, error) {
+	b := f.balance(change)
+	amount := change.Amount
+	if amount > *b {
+		amount = *b
+	}
+	if amount == 0 {
+		return nil, nil
+	}
+	*b -= amount
+	return &models.PointsLedger{TG: change.TG, Currency: change.Currency, Amount: -amount, Balance: *b}, nil
+}
+
+// newAdminTestServer 创建使用内存用户的管理员 API：
+// 1 有 Emby 账户（积分 5，花币 0），2 没有 Emby 账户
+func newAdminTestServer() (*Server, *fakeAdminUsers) {
+	name, embyID := "alice", "emby-1"
+	users := &fakeAdminUsers{
+		users: map[int64]*models.Emby{
+			1: {TG: 1, Name: &name, EmbyID: &embyID, Lv: models.LevelB, Us: 5},
+			2: {TG: 2, Lv: models.LevelD},
+		},
+		renewed: make(map[int64]int),
+	}
+	s := &Server{
+		app: fiber.New(),
+		cfg: &config.APIConfig{
+			Tokens: []config.APIToken{
+				{Name: "reader", Token: "reader-token", Scopes: []string{config.ScopeReadStats, config.ScopeReadUser}},
+				{Name: "admin", Token: "admin-token", Scopes: []string{config.ScopeAdmin}},
+			},
+		},
+		users: users,
+	}
+	s.registerAdminRoutes(s.app.Group("/api/v1"))
+	return s, users
+}
+
+// adminRequest 使用管理员令牌发送请求，返回状态码与解析后的响应
+func adminRequest(t *testing.T, s *Server, method, path, body string) (int, map[string]interface{}) {
+	t.Helper()
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", "admin-token")
+	resp, err := s.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		t.Fatalf("解析响应失败: %v", err)
+	}
+	return resp.StatusCode, out
+}
+
+func TestAdminRequireScope(t *testing.T) {
+	s, _ := newAdminTestServer()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"缺少令牌", "GET", "/api/v1/admin/users/1", "", fiber.StatusUnauthorized},
+		{"无效令牌", "GET", "/api/v1/admin/users/1", "wrong", fiber.StatusUnauthorized},
+		{"只读令牌查询", "GET", "/api/v1/admin/users/1", "reader-token", fiber.StatusForbidden},
+		{"只读令牌续期", "POST", "/api/v1/admin/users/1/renew", "reader-token", fiber.StatusForbidden},
+		{"只读令牌封禁", "PUT", "/api/v1/admin/users/1/ban", "reader-token", fiber.StatusForbidden},
+		{"管理员令牌", "GET", "/api/v1/admin/users/1", "admin-token", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(`{"days": 30}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.token != "" {
+				req.Header.Set("X-API-Key", tt.token)
+			}
+			resp, err := s.app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestAdminRenewUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		want   int
+		days   int
+	}{
+		{"按 TG ID 续期", "1", `{"days": 30}`, fiber.StatusOK, 30},
+		{"按用户名续期", "alice", `{"days": -7}`, fiber.StatusOK, -7},
+		{"天数为 0", "1", `{"days": 0}`, fiber.StatusBadRequest, 0},
+		{"请求体无效", "1", `{"days": "x"}`, fiber.StatusBadRequest, 0},
+		{"用户不存在", "99", `{"days": 30}`, fiber.StatusNotFound, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, users := newAdminTestServer()
+			status, _ := adminRequest(t, s, "POST", "/api/v1/admin/users/"+tt.target+"/renew", tt.body)
+			if status != tt.want {
+				t.Errorf("status = %d, want %d", status, tt.want)
+			}
+			if users.renewed[1] != tt.days {
+				t.Errorf("续期天数 = %d, want %d", users.renewed[1], tt.days)
+			}
+		})
+	}
+}
+
+func TestAdminBanUser(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		target string
+		want   int
+		level  string
+	}{
+		{"封禁", "PUT", "1", fiber.StatusOK, "e"},
+		{"解禁", "DELETE", "1", fiber.StatusOK, "b"},
+		{"没有 Emby 账户", "PUT", "2", fiber.StatusBadRequest, ""},
+		{"用户不存在", "PUT", "99", fiber.StatusNotFound, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newAdminTestServer()
+			status, body := adminRequest(t, s, tt.method, "/api/v1/admin/users/"+tt.target+"/ban", "")
+			if status != tt.want {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.want, body)
+			}
+			if tt.level != "" && body["level"] != tt.level {
+				t.Errorf("level = %v, want %s", body["level"], tt.level)
+			}
+		})
+	}
+}
+
+func TestAdminScore(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		want   int
+		before float64
+		after  float64
+	}{
+		{"增加积分", `{"amount": 10}`, fiber.StatusOK, 5, 15},
+		{"扣减积分", `{"amount": -3}`, fiber.StatusOK, 5, 2},
+		{"扣减超过余额", `{"amount": -20}`, fiber.StatusOK, 5, 0},
+		{"余额为 0 时扣减", `{"amount": -5, "currency": "iv"}`, fiber.StatusOK, 0, 0},
+		{"增加花币", `{"amount": 8, "currency": "iv"}`, fiber.StatusOK, 0, 8},
+		{"变动为 0", `{"amount": 0}`, fiber.StatusBadRequest, 0, 0},
+		{"无效币种", `{"amount": 1, "currency": "btc"}`, fiber.StatusBadRequest, 0, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newAdminTestServer()
+			status, body := adminRequest(t, s, "POST", "/api/v1/admin/users/1/score", tt.body)
+			if status != tt.want {
+				t.Fatalf("status = %d, want %d (%v)", status, tt.want, body)
+			}
+			if status != fiber.StatusOK {
+				return
+			}
+			if body["before"] != tt.before || body["after"] != tt.after {
+				t.Errorf("before/after = %v/%v, want %v/%v", body["before"], body["after"], tt.before, tt.after)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+// Package web 管理员 API 的用户操作
+package web
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
+	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
+	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
+)
+
+// errNoEmbyAccount 用户没有 Emby 账户
+var errNoEmbyAccount = errors.New("用户没有 Emby 账户")
+
+// adminUsers 管理员 API 依赖的用户操作（测试时替换为内存实现）
+type adminUsers interface {
+	// Find 按 TG ID 或 Emby 用户名查找用户
+	Find(target string) (*models.Emby, error)
+	// Get 按 TG ID 查找用户
+	Get(tgID int64) (*models.Emby, error)
+	// Renew 续期，天数为负时缩短
+	Renew(tgID int64, days int) error
+	// SetBanned 禁用或解禁用户的 Emby 账户（包括其他服务器上的账户）
+	SetBanned(user *models.Emby, banned bool) error
+	// Credit 增加余额
+	Credit(change *service.PointsChange) (*models.PointsLedger, error)
+	// DebitUpTo 扣减余额，最多扣到 0
+	DebitUpTo(change *service.PointsChange) (*models.PointsLedger, error)
+}
+
+// serviceAdminUsers 基于数据库与 Emby 的默认实现
+type serviceAdminUsers struct{}
+
+// Find 按 TG ID 或 Emby 用户名查找用户
+func (serviceAdminUsers) Find(target string) (*models.Emby, error) {
+	target = strings.TrimPrefix(strings.TrimSpace(target), "@")
+	repo := repository.NewEmbyRepository()
+	if tgID, err := strconv.ParseInt(target, 10, 64); err == nil {
+		return repo.GetByTG(tgID)
+	}
+	return repo.GetByName(target)
+}
+
+// Get 按 TG ID 查找用户
+func (serviceAdminUsers) Get(tgID int64) (*models.Emby, error) {
+	return repository.NewEmbyRepository().GetByTG(tgID)
+}
+
+// Renew 续期
+func (serviceAdminUsers) Renew(tgID int64, days int) error {
+	return service.NewExpiryService().RenewUser(tgID, days)
+}
+
+// SetBanned 禁用（等级 e）或解禁（等级 b）用户，与用户面板的禁用/解禁按钮一致
+func (serviceAdminUsers) SetBanned(user *models.Emby, banned bool) error {
+	if !user.HasEmbyAccount() {
+		return errNoEmbyAccount
+	}
+
+	client := emby.GetClient()
+	level := models.LevelB
+	if banned {
+		level = models.LevelE
+		if err := client.DisableUser(*user.EmbyID); err != nil {
+			return err
+		}
+	} else if err := client.EnableUser(*user.EmbyID); err != nil {
+		return err
+	}
+
+	if err := repository.NewEmbyRepository().UpdateFields(user.TG, map[string]interface{}{"lv": level}); err != nil {
+		return err
+	}
+	if banned {
+		service.DisableServers(user.TG)
+	} else {
+		service.SyncServers(user.TG)
+	}
+	return nil
+}
+
+// Credit 增加余额
+func (serviceAdminUsers) Credit(change *service.PointsChange) (*models.PointsLedger, error) {
+	return service.NewPointsService().Credit(change)
+}
+
+// DebitUpTo 扣减余额，最多扣到 0
+func (serviceAdminUsers) DebitUpTo(change *service.PointsChange) (*models.PointsLedger, error) {
+	return service.NewPointsService().DebitUpTo(change)
+}
@@ -0,0 +1,136 @@
+// Package web API 鉴权中间件
+package web
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+	pkglogger "github.com/smysle/sakura-embyboss-go/pkg/logger"
+)
+
+// 鉴权相关请求头
+const (
+	headerAPIKey    = "X-API-Key"
+	headerSignature = "X-Webhook-Signature"
+	localsToken     = "api_token"
+)
+
+// requireScope 校验 API 令牌及权限范围
+// 令牌可通过 Authorization: Bearer <token> 或 X-API-Key 传递
+func (s *Server) requireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		raw := extractToken(c)
+		if raw == "" {
+			return s.reject(c, fiber.StatusUnauthorized, "缺少 API 令牌", scope)
+		}
+
+		token := s.findToken(raw)
+		if token == nil {
+			return s.reject(c, fiber.StatusUnauthorized, "无效的 API 令牌", scope)
+		}
+
+		if !token.HasScope(scope) {
+			pkglogger.Warn().
+				Str("ip", c.IP()).
+				Str("path", c.Path()).
+				Str("token", token.Name).
+				Str("scope", scope).
+				Msg("【API服务】令牌权限不足")
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"error": "权限不足",
+			})
+		}
+
+		c.Locals(localsToken, token.Name)
+		return c.Next()
+	}
+}
+
+// requireWebhookSecret 校验 Webhook 签名
+// 支持 X-Webhook-Signature: sha256=<hex(HMAC-SHA256(body))> 或 ?secret=<webhook_secret>
+func (s *Server) requireWebhookSecret() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		secret := s.cfg.WebhookSecret
+		if secret == "" {
+			return s.reject(c, fiber.StatusUnauthorized, "未配置 Webhook 密钥", "webhook")
+		}
+
+		if sig := c.Get(headerSignature); sig != "" {
+			if VerifySignature(secret, c.Body(), sig) {
+				return c.Next()
+			}
+			return s.reject(c, fiber.StatusUnauthorized, "Webhook 签名无效", "webhook")
+		}
+
+		if q := c.Query("secret"); q != "" {
+			if subtle.ConstantTimeCompare([]byte(q), []byte(secret)) == 1 {
+				return c.Next()
+			}
+			return s.reject(c, fiber.StatusUnauthorized, "Webhook 密钥无效", "webhook")
+		}
+
+		return s.reject(c, fiber.StatusUnauthorized, "缺少 Webhook 签名", "webhook")
+	}
+}
+
+// reject 拒绝请求并记录来源
+func (s *Server) reject(c *fiber.Ctx, status int, reason, scope string) error {
+	pkglogger.Warn().
+		Str("ip", c.IP()).
+		Str("method", c.Method()).
+		Str("path", c.Path()).
+		Str("scope", scope).
+		Str("reason", reason).
+		Msg("【API服务】拒绝未授权请求")
+	return c.Status(status).JSON(fiber.Map{
+		"error": reason,
+	})
+}
+
+// findToken 查找匹配的令牌（常量时间比较）
+func (s *Server) findToken(raw string) *config.APIToken {
+	for i := range s.cfg.Tokens {
+		t := &s.cfg.Tokens[i]
+		if t.Token == "" {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(raw), []byte(t.Token)) == 1 {
+			return t
+		}
+	}
+	return nil
+}
+
+// extractToken 从请求头中提取令牌
+func extractToken(c *fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); auth != "" {
+		if strings.HasPrefix(auth, "Bearer ") {
+			return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+		}
+	}
+	return strings.TrimSpace(c.Get(headerAPIKey))
+}
+
+// SignPayload 计算请求体的 HMAC-SHA256 签名（十六进制）
+func SignPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature 校验签名，兼容带 "sha256=" 前缀的格式
+func VerifySignature(secret string, body []byte, signature string) bool {
+	signature = strings.TrimPrefix(strings.TrimSpace(signature), "sha256=")
+	got, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	want, _ := hex.DecodeString(SignPayload(secret, body))
+	return hmac.Equal(got, want)
+}
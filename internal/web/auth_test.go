@@ -0,0 +1,115 @@
+// Package web API 鉴权测试
+package web
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+
+	"github.com/smysle/sakura-embyboss-go/internal/config"
+)
+
+func newAuthTestServer() *Server {
+	s := &Server{
+		app: fiber.New(),
+		cfg: &config.APIConfig{
+			Tokens: []config.APIToken{
+				{Name: "reader", Token: "reader-token", Scopes: []string{config.ScopeReadStats}},
+				{Name: "root", Token: "root-token", Scopes: []string{config.ScopeAll}},
+			},
+			WebhookSecret: "hook-secret",
+		},
+	}
+	ok := func(c *fiber.Ctx) error { return c.SendStatus(fiber.StatusOK) }
+	s.app.Get("/stats", s.requireScope(config.ScopeReadStats), ok)
+	s.app.Get("/user", s.requireScope(config.ScopeReadUser), ok)
+	s.app.Post("/hook", s.requireWebhookSecret(), ok)
+	return s
+}
+
+func TestRequireScope(t *testing.T) {
+	s := newAuthTestServer()
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		value  string
+		want   int
+	}{
+		{"缺少令牌", "/stats", "", "", fiber.StatusUnauthorized},
+		{"无效令牌", "/stats", "Authorization", "Bearer wrong", fiber.StatusUnauthorized},
+		{"Bearer 令牌", "/stats", "Authorization", "Bearer reader-token", fiber.StatusOK},
+		{"X-API-Key 令牌", "/stats", "X-API-Key", "reader-token", fiber.StatusOK},
+		{"权限不足", "/user", "X-API-Key", "reader-token", fiber.StatusForbidden},
+		{"通配权限", "/user", "X-API-Key", "root-token", fiber.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			resp, err := s.app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireWebhookSecret(t *testing.T) {
+	s := newAuthTestServer()
+	body := `{"Event":"playback.start"}`
+
+	tests := []struct {
+		name string
+		url  string
+		sig  string
+		want int
+	}{
+		{"无签名", "/hook", "", fiber.StatusUnauthorized},
+		{"正确签名", "/hook", "sha256=" + SignPayload("hook-secret", []byte(body)), fiber.StatusOK},
+		{"无前缀签名", "/hook", SignPayload("hook-secret", []byte(body)), fiber.StatusOK},
+		{"错误签名", "/hook", SignPayload("other", []byte(body)), fiber.StatusUnauthorized},
+		{"正确查询参数", "/hook?secret=hook-secret", "", fiber.StatusOK},
+		{"错误查询参数", "/hook?secret=nope", "", fiber.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.url, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if tt.sig != "" {
+				req.Header.Set(headerSignature, tt.sig)
+			}
+			resp, err := s.app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireWebhookSecret_NotConfigured(t *testing.T) {
+	s := newAuthTestServer()
+	s.cfg.WebhookSecret = ""
+
+	req := httptest.NewRequest("POST", "/hook?secret=", strings.NewReader("{}"))
+	resp, err := s.app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusUnauthorized {
+		t.Errorf("未配置密钥时应拒绝, status = %d", resp.StatusCode)
+	}
+}
@@ -2,9 +2,12 @@
 package web
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"runtime"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -14,8 +17,10 @@ import (
 
 	"github.com/smysle/sakura-embyboss-go/internal/config"
 	"github.com/smysle/sakura-embyboss-go/internal/database"
+	"github.com/smysle/sakura-embyboss-go/internal/database/models"
 	"github.com/smysle/sakura-embyboss-go/internal/database/repository"
 	"github.com/smysle/sakura-embyboss-go/internal/emby"
+	"github.com/smysle/sakura-embyboss-go/internal/service"
 	pkglogger "github.com/smysle/sakura-embyboss-go/pkg/logger"
 )
 
@@ -23,6 +28,7 @@ import (
 type Server struct {
 	app       *fiber.App
 	cfg       *config.APIConfig
+	users     adminUsers
 	startTime time.Time
 }
 
@@ -45,14 +51,15 @@ func New(cfg *config.APIConfig) *Server {
 	app.Use(recover.New())
 	app.Use(logger.New())
 	app.Use(cors.New(cors.Config{
-		AllowOrigins: "*",
+		AllowOrigins: strings.Join(cfg.AllowOrigins, ","),
 		AllowMethods: "GET,POST,PUT,DELETE,OPTIONS",
-		AllowHeaders: "Origin, Content-Type, Accept, Authorization",
+		AllowHeaders: "Origin, Content-Type, Accept, Authorization, X-API-Key, X-Webhook-Signature",
 	}))
 
 	server := &Server{
 		app:       app,
 		cfg:       cfg,
+		users:     serviceAdminUsers{},
 		startTime: time.Now(),
 	}
 
@@ -69,22 +76,27 @@ func (s *Server) registerRoutes() {
 	s.app.Get("/", s.healthCheck)
 
 	// 详细状态
-	s.app.Get("/status", s.detailedStatus)
+	s.app.Get("/status", s.requireScope(config.ScopeReadStats), s.detailedStatus)
 
 	// API v1
 	v1 := s.app.Group("/api/v1")
 
 	// 用户相关
-	v1.Get("/user/:id", s.getUser)
+	v1.Get("/user/:id", s.requireScope(config.ScopeReadUser), s.getUser)
 
 	// 统计
-	v1.Get("/stats", s.getStats)
-	v1.Get("/stats/users", s.getUserStats)
-	v1.Get("/stats/media", s.getMediaStats)
+	stats := v1.Group("/stats", s.requireScope(config.ScopeReadStats))
+	stats.Get("", s.getStats)
+	stats.Get("/users", s.getUserStats)
+	stats.Get("/media", s.getMediaStats)
+
+	// 管理员接口
+	s.registerAdminRoutes(v1)
 
 	// Webhook
-	webhook := v1.Group("/webhook")
-	webhook.Post("/emby", s.embyWebhook)
+	webhook := v1.Group("/webhook", s.requireWebhookSecret())
+	webhook.Post("/emby", s.embyWebhook)         // 主服务器
+	webhook.Post("/emby/:server", s.embyWebhook) // 按名称区分服务器
 	webhook.Post("/favorites", s.favoritesWebhook)
 }
 
@@ -95,6 +107,18 @@ func (s *Server) Start() error {
 		return nil
 	}
 
+	// 拒绝使用示例配置中的占位密钥，避免暴露公开已知的管理令牌
+	if names := s.cfg.PlaceholderSecrets(); len(names) > 0 {
+		return fmt.Errorf("API 令牌或 Webhook 密钥仍是示例占位值，请修改后重启: %s", strings.Join(names, ", "))
+	}
+
+	if len(s.cfg.Tokens) == 0 {
+		pkglogger.Warn().Msg("【API服务】未配置 API 令牌，所有受保护接口将拒绝访问")
+	}
+	if s.cfg.WebhookSecret == "" {
+		pkglogger.Warn().Msg("【API服务】未配置 webhook_secret，Webhook 接口将拒绝所有请求")
+	}
+
 	addr := fmt.Sprintf("%s:%d", s.cfg.Host, s.cfg.Port)
 	pkglogger.Info().Str("addr", addr).Msg("【API服务】启动中...")
 
@@ -151,6 +175,8 @@ type EmbyStatus struct {
 	Connected  bool   `json:"connected"`
 	URL        string `json:"url"`
 	PlayingNow int    `json:"playing_now"`
+	// DataSources 播放统计数据源可用性（启动时探测）
+	DataSources []emby.SourceStatus `json:"data_sources"`
 }
 
 // detailedStatus 详细状态
@@ -174,12 +200,14 @@ func (s *Server) detailedStatus(c *fiber.Ctx) error {
 	// Emby 状态
 	embyConnected := false
 	playingNow := 0
+	var dataSources []emby.SourceStatus
 	cfg := config.Get()
 	if embyClient := emby.GetClient(); embyClient != nil {
 		if count, err := embyClient.GetCurrentPlayingCount(); err == nil {
 			embyConnected = true
 			playingNow = count
 		}
+		dataSources = embyClient.DataSourceStatus()
 	}
 
 	return c.JSON(StatusResponse{
@@ -197,9 +225,10 @@ func (s *Server) detailedStatus(c *fiber.Ctx) error {
 			UserCount: userCount,
 		},
 		Emby: EmbyStatus{
-			Connected:  embyConnected,
-			URL:        cfg.Emby.URL,
-			PlayingNow: playingNow,
+			Connected:   embyConnected,
+			URL:         cfg.Emby.Main().URL,
+			PlayingNow:  playingNow,
+			DataSources: dataSources,
 		},
 	})
 }
@@ -325,9 +354,15 @@ func (s *Server) getUser(c *fiber.Ctx) error {
 }
 
 // EmbyWebhookPayload Emby Webhook 载荷
+// 兼容 Emby 原生 Webhook（嵌套 User/Item/Session）与旧版扁平格式
 type EmbyWebhookPayload struct {
-	Event     string `json:"Event"`
-	User      string `json:"User"`
+	Event        string              `json:"Event"`
+	User         EmbyWebhookUser     `json:"User"`
+	Item         EmbyWebhookItem     `json:"Item"`
+	Session      EmbyWebhookSession  `json:"Session"`
+	PlaybackInfo EmbyWebhookPlayback `json:"PlaybackInfo"`
+
+	// 旧版扁平字段
 	ItemName  string `json:"ItemName"`
 	ItemType  string `json:"ItemType"`
 	ClientIP  string `json:"ClientIP"`
@@ -337,47 +372,133 @@ type EmbyWebhookPayload struct {
 	SessionID string `json:"SessionId"`
 }
 
+// EmbyWebhookUser Webhook 用户信息（可能是字符串或对象）
+type EmbyWebhookUser struct {
+	ID   string `json:"Id"`
+	Name string `json:"Name"`
+}
+
+// UnmarshalJSON 兼容 "User": "name" 与 "User": {"Id": "...", "Name": "..."}
+func (u *EmbyWebhookUser) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		u.Name = name
+		return nil
+	}
+	type alias EmbyWebhookUser
+	return json.Unmarshal(data, (*alias)(u))
+}
+
+// EmbyWebhookItem Webhook 媒体信息
+type EmbyWebhookItem struct {
+	ID         string `json:"Id"`
+	Name       string `json:"Name"`
+	Type       string `json:"Type"`
+	SeriesName string `json:"SeriesName"`
+}
+
+// EmbyWebhookSession Webhook 会话信息
+type EmbyWebhookSession struct {
+	ID             string `json:"Id"`
+	Client         string `json:"Client"`
+	DeviceName     string `json:"DeviceName"`
+	DeviceID       string `json:"DeviceId"`
+	RemoteEndPoint string `json:"RemoteEndPoint"`
+}
+
+// EmbyWebhookPlayback Webhook 播放进度
+type EmbyWebhookPlayback struct {
+	PositionTicks int64 `json:"PositionTicks"`
+}
+
+// ToEvent 转换为事件记录
+func (p *EmbyWebhookPayload) ToEvent() *models.PlaybackEvent {
+	event := &models.PlaybackEvent{
+		Event:         p.Event,
+		EmbyUserID:    p.User.ID,
+		UserName:      p.User.Name,
+		ItemID:        p.Item.ID,
+		ItemName:      firstNonEmpty(p.Item.Name, p.ItemName),
+		ItemType:      firstNonEmpty(p.Item.Type, p.ItemType),
+		Client:        firstNonEmpty(p.Session.Client, p.Client),
+		DeviceName:    firstNonEmpty(p.Session.DeviceName, p.Device),
+		DeviceID:      firstNonEmpty(p.Session.DeviceID, p.DeviceID),
+		ClientIP:      firstNonEmpty(p.Session.RemoteEndPoint, p.ClientIP),
+		SessionID:     firstNonEmpty(p.Session.ID, p.SessionID),
+		PositionTicks: p.PlaybackInfo.PositionTicks,
+	}
+	if p.Item.SeriesName != "" && event.ItemName != "" {
+		event.ItemName = p.Item.SeriesName + " - " + event.ItemName
+	}
+	return event
+}
+
+// firstNonEmpty 返回第一个非空字符串
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// parseEmbyWebhook 解析 Webhook 请求体
+// Emby 可配置为 application/json 或 multipart/form-data（JSON 位于 data 字段）
+func parseEmbyWebhook(c *fiber.Ctx) (*EmbyWebhookPayload, error) {
+	var payload EmbyWebhookPayload
+	body := c.Body()
+	if data := c.FormValue("data"); data != "" {
+		body = []byte(data)
+	}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, err
+	}
+	if payload.Event == "" {
+		return nil, fmt.Errorf("缺少 Event 字段")
+	}
+	return &payload, nil
+}
+
 // embyWebhook 处理 Emby Webhook
 func (s *Server) embyWebhook(c *fiber.Ctx) error {
-	var payload EmbyWebhookPayload
-	if err := c.BodyParser(&payload); err != nil {
+	payload, err := parseEmbyWebhook(c)
+	if err != nil {
 		pkglogger.Warn().Err(err).Msg("解析 Emby Webhook 失败")
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "无效的请求体",
 		})
 	}
 
+	event := payload.ToEvent()
+	event.Server = c.Params("server")
 	pkglogger.Debug().
-		Str("event", payload.Event).
-		Str("user", payload.User).
-		Str("item", payload.ItemName).
-		Str("client", payload.Client).
-		Str("ip", payload.ClientIP).
+		Str("server", event.Server).
+		Str("event", event.Event).
+		Str("user", event.DisplayUser()).
+		Str("item", event.ItemName).
+		Str("client", event.Client).
+		Str("ip", event.ClientIP).
 		Msg("收到 Emby Webhook")
 
-	// 根据事件类型处理
-	switch payload.Event {
-	case "playback.start":
-		// 可以在这里检查客户端限制等
-		pkglogger.Info().
-			Str("user", payload.User).
-			Str("item", payload.ItemName).
-			Msg("用户开始播放")
-
-	case "playback.stop":
-		pkglogger.Info().
-			Str("user", payload.User).
-			Str("item", payload.ItemName).
-			Msg("用户停止播放")
-
-	case "user.authenticated":
-		pkglogger.Info().
-			Str("user", payload.User).
-			Str("ip", payload.ClientIP).
-			Msg("用户登录")
+	webhookSvc := service.NewWebhookService()
+	if err := webhookSvc.HandleEvent(event); err != nil {
+		if errors.Is(err, service.ErrUnknownServer) {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": err.Error(),
+		})
 	}
 
-	return c.SendStatus(fiber.StatusOK)
+	return c.JSON(fiber.Map{
+		"server":   event.Server,
+		"event":    event.Event,
+		"filtered": event.Filtered,
+		"action":   event.Action,
+	})
 }
 
 // FavoritesWebhookPayload 收藏 Webhook 载荷
@@ -0,0 +1,113 @@
+// Package imggen 签到日历图片
+package imggen
+
+import (
+	"fmt"
+	"image/color"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// CheckinCalendarConfig 签到日历配置
+type CheckinCalendarConfig struct {
+	Title  string // 标题（如用户名）
+	Year   int
+	Month  time.Month
+	Days   map[int]bool // 已签到的日期 → 是否为补签
+	Today  int          // 当月中今天的日期（非当月为 0）
+	Footer string       // 底部说明（如连续天数）
+}
+
+// 日历布局
+const (
+	calendarCell    = 64
+	calendarGap     = 8
+	calendarPadding = 30
+	calendarHeader  = 110
+	calendarFooter  = 60
+)
+
+var (
+	checkedColor  = color.RGBA{46, 160, 90, 255}  // 已签到
+	repairedColor = color.RGBA{230, 145, 40, 255} // 补签
+	emptyDayColor = color.RGBA{50, 50, 70, 255}   // 未签到
+)
+
+// GenerateCheckinCalendar 生成月度签到日历
+func GenerateCheckinCalendar(cfg CheckinCalendarConfig) ([]byte, error) {
+	first := time.Date(cfg.Year, cfg.Month, 1, 0, 0, 0, 0, time.UTC)
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+	offset := (int(first.Weekday()) + 6) % 7 // 周一为每周第一天
+	rows := (offset + daysInMonth + 6) / 7
+
+	width := calendarPadding*2 + 7*calendarCell + 6*calendarGap
+	height := calendarHeader + 30 + rows*(calendarCell+calendarGap) + calendarFooter
+
+	dc := gg.NewContext(width, height)
+	dc.SetColor(bgColor)
+	dc.Clear()
+
+	// 标题
+	dc.SetColor(goldColor)
+	dc.DrawStringAnchored(cfg.Title, float64(width)/2, 40, 0.5, 0.5)
+	dc.SetColor(textColor)
+	dc.DrawStringAnchored(fmt.Sprintf("%s %d", cfg.Month.String(), cfg.Year), float64(width)/2, 75, 0.5, 0.5)
+
+	// 星期
+	dc.SetColor(subTextColor)
+	for i, name := range []string{"Mon", "Tue", "Wed", "Thu", "Fri", "Sat", "Sun"} {
+		x := float64(calendarPadding + i*(calendarCell+calendarGap) + calendarCell/2)
+		dc.DrawStringAnchored(name, x, calendarHeader, 0.5, 0.5)
+	}
+
+	// 日期格
+	top := calendarHeader + 25
+	for day := 1; day <= daysInMonth; day++ {
+		pos := offset + day - 1
+		x := float64(calendarPadding + (pos%7)*(calendarCell+calendarGap))
+		y := float64(top + (pos/7)*(calendarCell+calendarGap))
+
+		fill := emptyDayColor
+		if repaired, ok := cfg.Days[day]; ok {
+			fill = checkedColor
+			if repaired {
+				fill = repairedColor
+			}
+		}
+		dc.SetColor(fill)
+		drawRoundedRect(dc, x, y, calendarCell, calendarCell, 10)
+		dc.Fill()
+
+		if day == cfg.Today {
+			dc.SetColor(goldColor)
+			dc.SetLineWidth(3)
+			drawRoundedRect(dc, x, y, calendarCell, calendarCell, 10)
+			dc.Stroke()
+		}
+
+		dc.SetColor(textColor)
+		dc.DrawStringAnchored(fmt.Sprintf("%d", day), x+calendarCell/2, y+calendarCell/2, 0.5, 0.5)
+	}
+
+	// 图例与说明
+	footerY := float64(height - calendarFooter/2)
+	legendX := float64(calendarPadding)
+	for _, item := range []struct {
+		label string
+		fill  color.RGBA
+	}{{"Checked", checkedColor}, {"Repaired", repairedColor}} {
+		dc.SetColor(item.fill)
+		drawRoundedRect(dc, legendX, footerY-8, 16, 16, 4)
+		dc.Fill()
+		dc.SetColor(subTextColor)
+		dc.DrawStringAnchored(item.label, legendX+24, footerY, 0, 0.5)
+		legendX += 110
+	}
+	if cfg.Footer != "" {
+		dc.SetColor(textColor)
+		dc.DrawStringAnchored(cfg.Footer, float64(width-calendarPadding), footerY, 1, 0.5)
+	}
+
+	return exportPNG(dc)
+}
@@ -0,0 +1,71 @@
+// Package imggen 注册码二维码卡片
+package imggen
+
+import (
+	"fmt"
+	"image/color"
+
+	"github.com/fogleman/gg"
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// CodeCardConfig 注册码卡片配置
+type CodeCardConfig struct {
+	Title    string // 卡片标题（如站点名）
+	Code     string
+	Link     string // 二维码内容（Bot 深链接）
+	Subtitle string // 附加说明（如有效天数）
+}
+
+// 卡片布局
+const (
+	codeCardWidth   = 440
+	codeCardHeight  = 560
+	codeCardQRSize  = 340
+	codeCardPadding = 20
+)
+
+var qrBgColor = color.RGBA{255, 255, 255, 255}
+
+// GenerateCodeCard 生成注册码二维码卡片，便于打印或分发
+func GenerateCodeCard(cfg CodeCardConfig) ([]byte, error) {
+	if cfg.Link == "" {
+		return nil, fmt.Errorf("二维码内容为空")
+	}
+
+	qr, err := qrcode.New(cfg.Link, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("生成二维码失败: %w", err)
+	}
+	qr.DisableBorder = true
+
+	dc := gg.NewContext(codeCardWidth, codeCardHeight)
+	dc.SetColor(bgColor)
+	dc.Clear()
+
+	dc.SetColor(cardColor)
+	drawRoundedRect(dc, codeCardPadding/2, codeCardPadding/2, codeCardWidth-codeCardPadding, codeCardHeight-codeCardPadding, 16)
+	dc.Fill()
+
+	// 标题
+	dc.SetColor(goldColor)
+	dc.DrawStringAnchored(cfg.Title, codeCardWidth/2, 50, 0.5, 0.5)
+
+	// 二维码（白底留出静区，保证可识别）
+	qrX := float64(codeCardWidth-codeCardQRSize) / 2
+	qrY := 85.0
+	dc.SetColor(qrBgColor)
+	drawRoundedRect(dc, qrX-12, qrY-12, codeCardQRSize+24, codeCardQRSize+24, 8)
+	dc.Fill()
+	dc.DrawImage(qr.Image(codeCardQRSize), int(qrX), int(qrY))
+
+	// 注册码与说明
+	dc.SetColor(textColor)
+	dc.DrawStringAnchored(cfg.Code, codeCardWidth/2, qrY+codeCardQRSize+50, 0.5, 0.5)
+	if cfg.Subtitle != "" {
+		dc.SetColor(subTextColor)
+		dc.DrawStringAnchored(cfg.Subtitle, codeCardWidth/2, qrY+codeCardQRSize+80, 0.5, 0.5)
+	}
+
+	return exportPNG(dc)
+}
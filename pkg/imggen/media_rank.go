@@ -0,0 +1,183 @@
+// Package imggen 媒体排行榜图片
+package imggen
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"github.com/fogleman/gg"
+)
+
+// MediaRankData 媒体排行条目
+type MediaRankData struct {
+	Rank      int
+	Name      string
+	PlayCount int
+	Users     int
+	WatchTime string      // 格式化后的播放时长
+	Poster    image.Image // 海报缩略图，可为空
+}
+
+// MediaRankSection 榜单分栏（如电影、剧集）
+type MediaRankSection struct {
+	Title string
+	Items []MediaRankData
+}
+
+// MediaRankConfig 媒体排行榜图片配置
+type MediaRankConfig struct {
+	Title       string
+	Subtitle    string
+	RankType    string // "day" 或 "week"
+	Sections    []MediaRankSection
+	GeneratedAt time.Time
+}
+
+// 媒体榜布局
+const (
+	mediaColumnWidth  = 520
+	mediaHeaderHeight = 120
+	mediaSectionTitle = 40
+	mediaItemHeight   = 120
+	mediaFooterHeight = 50
+	mediaPosterWidth  = 70
+	mediaPosterHeight = 105
+	mediaMaxItems     = 10
+)
+
+// GenerateMediaLeaderboard 生成带海报的媒体排行榜图片，每个分栏占一列
+func GenerateMediaLeaderboard(cfg MediaRankConfig) ([]byte, error) {
+	if len(cfg.Sections) == 0 {
+		return nil, fmt.Errorf("没有榜单数据")
+	}
+
+	rows := 0
+	for _, section := range cfg.Sections {
+		if n := len(section.Items); n > rows {
+			rows = n
+		}
+	}
+	if rows > mediaMaxItems {
+		rows = mediaMaxItems
+	}
+	if rows == 0 {
+		rows = 1
+	}
+
+	width := mediaColumnWidth * len(cfg.Sections)
+	height := mediaHeaderHeight + mediaSectionTitle + rows*mediaItemHeight + mediaFooterHeight + 20
+
+	dc := gg.NewContext(width, height)
+	drawBackground(dc, width, height, cfg.RankType)
+	drawHeader(dc, width, LeaderboardConfig{
+		Title:    cfg.Title,
+		Subtitle: cfg.Subtitle,
+		RankType: cfg.RankType,
+	})
+
+	for col, section := range cfg.Sections {
+		x := float64(col * mediaColumnWidth)
+		y := float64(mediaHeaderHeight)
+
+		dc.SetColor(goldColor)
+		dc.DrawStringAnchored(section.Title, x+mediaColumnWidth/2, y+mediaSectionTitle/2, 0.5, 0.5)
+		y += mediaSectionTitle
+
+		if len(section.Items) == 0 {
+			dc.SetColor(subTextColor)
+			dc.DrawStringAnchored("暂无数据", x+mediaColumnWidth/2, y+mediaItemHeight/2, 0.5, 0.5)
+			continue
+		}
+
+		for i, item := range section.Items {
+			if i >= mediaMaxItems {
+				break
+			}
+			drawMediaItem(dc, x, y+float64(i*mediaItemHeight), item)
+		}
+	}
+
+	drawFooter(dc, width, height, cfg.GeneratedAt)
+	return exportPNG(dc)
+}
+
+// drawMediaItem 绘制单个媒体条目：排名、海报、名称与播放数据
+func drawMediaItem(dc *gg.Context, x, y float64, item MediaRankData) {
+	cardX := x + 15
+	cardY := y + 5
+	cardW := float64(mediaColumnWidth - 30)
+	cardH := float64(mediaItemHeight - 10)
+
+	dc.SetColor(color.RGBA{cardColor.R, cardColor.G, cardColor.B, 200})
+	drawRoundedRect(dc, cardX, cardY, cardW, cardH, 10)
+	dc.Fill()
+
+	centerY := cardY + cardH/2
+
+	dc.SetColor(mediaRankColor(item.Rank))
+	dc.DrawStringAnchored(fmt.Sprintf("%d", item.Rank), cardX+22, centerY, 0.5, 0.5)
+
+	posterX := cardX + 45
+	posterY := cardY + (cardH-mediaPosterHeight)/2
+	drawPoster(dc, item.Poster, posterX, posterY)
+
+	textX := posterX + mediaPosterWidth + 15
+	dc.SetColor(textColor)
+	dc.DrawStringAnchored(truncateRunes(item.Name, 22), textX, centerY-18, 0, 0.5)
+
+	dc.SetColor(subTextColor)
+	dc.DrawStringAnchored(fmt.Sprintf("播放 %d 次 | %d 人观看", item.PlayCount, item.Users), textX, centerY+6, 0, 0.5)
+	dc.DrawStringAnchored(item.WatchTime, textX, centerY+28, 0, 0.5)
+}
+
+// drawPoster 绘制圆角海报，缺失时绘制占位块
+func drawPoster(dc *gg.Context, poster image.Image, x, y float64) {
+	dc.Push()
+	defer dc.Pop()
+
+	drawRoundedRect(dc, x, y, mediaPosterWidth, mediaPosterHeight, 6)
+	if poster == nil {
+		dc.SetColor(accentColor)
+		dc.Fill()
+		return
+	}
+	dc.Clip()
+	defer dc.ResetClip() // Pop 不会恢复裁剪区域
+
+	bounds := poster.Bounds()
+	sx := float64(mediaPosterWidth) / float64(bounds.Dx())
+	sy := float64(mediaPosterHeight) / float64(bounds.Dy())
+	scale := sx
+	if sy > scale {
+		scale = sy // 铺满海报区域，多余部分裁掉
+	}
+
+	dc.Translate(x, y)
+	dc.Scale(scale, scale)
+	dc.DrawImage(poster, -bounds.Min.X, -bounds.Min.Y)
+}
+
+// mediaRankColor 排名颜色
+func mediaRankColor(rank int) color.RGBA {
+	switch rank {
+	case 1:
+		return goldColor
+	case 2:
+		return silverColor
+	case 3:
+		return bronzeColor
+	default:
+		return subTextColor
+	}
+}
+
+// truncateRunes 按字符截断过长的名称
+func truncateRunes(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) <= max {
+		return s
+	}
+	return string(runes[:max-1]) + "…"
+}
@@ -0,0 +1,17 @@
+// Package utils Telegram Markdown 转义
+package utils
+
+import "strings"
+
+// markdownEscaper 转义 Telegram Markdown（ModeMarkdown）的特殊字符
+var markdownEscaper = strings.NewReplacer("_", `\_`, "*", `\*`, "`", "\\`", "[", `\[`)
+
+// EscapeMarkdown 转义插入 Markdown 消息的外部文本（用户名、设备名、媒体名等）
+func EscapeMarkdown(s string) string {
+	return markdownEscaper.Replace(s)
+}
+
+// MarkdownCode 生成行内代码，代码块内无法转义，反引号替换为单引号
+func MarkdownCode(s string) string {
+	return "`" + strings.ReplaceAll(s, "`", "'") + "`"
+}